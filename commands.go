@@ -0,0 +1,397 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/syifan/akita_demo/demo"
+)
+
+// command is a subcommand of the binary. The flags after its name are its
+// own: the commands that take a configuration bind the flags of the fields
+// they use and a config file, which the flags take precedence over.
+type command struct {
+	name    string
+	args    string // Arguments after the flags, for the usage
+	summary string
+	run     func(cfg *demo.Config, args []string)
+}
+
+// commands returns the subcommands in the order the usage lists them
+func commands() []command {
+	return []command{
+		{"run", "", "Run the simulation, or the scenario it selects, and print its report (the default)", runCommand},
+		{"validate-config", "[config file]", "Check a configuration and build its model without running it", validateConfigCommand},
+		{"sweep", "", "Run the simulation over seeds, port capacities, or the parameter ranges of the config file", sweepCommand},
+		{"visualize", "", "Draw the topology as a Graphviz or Mermaid diagram without running", visualizeCommand},
+		{"compare", "<baseline> <candidate>", "Check the metrics two runs exported with -metrics-out for regressions", compareCommand},
+		{"describe", "", "Print the model as it was instantiated", describeCommand},
+		{"explain", "", "Follow one message through the event database of a run", explainCommand},
+		{"diff", "<checkpoint> <checkpoint>", "Compare the state saved in two checkpoints", diffCommand},
+	}
+}
+
+// lookupCommand returns the command of the given name
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands() {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// usage writes the commands and the flags of run, which the simulation runs
+// with if no command is given
+func usage(run *flag.FlagSet) {
+	w := run.Output()
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(w, "Usage: %s [command] [flags] [arguments]\n\n", name)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nWithout a command, the simulation runs. See \"%s <command> -h\" for the flags of a command.\n\n", name)
+	fmt.Fprintln(w, "Flags of run:")
+	run.PrintDefaults()
+}
+
+// newCommandFlags returns the flag set of the named command. Commands that
+// take a configuration pass the groups of its flags they use, and take a
+// config file as well.
+func newCommandFlags(name string, cfg *demo.Config, groups ...func(*demo.Config, *flag.FlagSet)) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	for _, register := range groups {
+		register(cfg, fs)
+	}
+	if len(groups) > 0 {
+		fs.String("config", "", "Path to a JSON config file")
+	}
+	c, _ := lookupCommand(name)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "Usage: %s %s [flags]", filepath.Base(os.Args[0]), name)
+		if c.args != "" {
+			fmt.Fprintf(w, " %s", c.args)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s\n\nFlags:\n", c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// newRunFlags returns the flag set of run, which takes every flag of the
+// configuration
+func newRunFlags(cfg *demo.Config) *flag.FlagSet {
+	fs := newCommandFlags("run", cfg, (*demo.Config).RegisterFlags)
+	fs.Usage = func() { usage(fs) }
+	return fs
+}
+
+// parseCommandFlags parses the flags of a command into cfg. A config file
+// given after the command loads on top of the configuration so far; the
+// flags given explicitly still take precedence.
+func parseCommandFlags(cfg *demo.Config, fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if path := fs.Lookup("config").Value.String(); path != "" {
+		loadConfig(cfg, fs, args, path)
+	}
+}
+
+// loadConfig loads a config file into cfg and parses the flags of the
+// command again
+func loadConfig(cfg *demo.Config, fs *flag.FlagSet, args []string, path string) {
+	if err := cfg.Load(path); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fs.Parse(args)
+}
+
+// startOutput checks the configuration and sends the output of its runs to
+// the selected sink, which it returns. A bundle captures the output and
+// packages the run once the output is closed, also when the run fails.
+func startOutput(cfg *demo.Config, command string) demo.EventSink {
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	sink, err := cfg.EventSink()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	cfg.Sink = sink
+	if cfg.Bundle != "" {
+		cfg.Sink = demo.NewBundleSink(sink, cfg, os.Args[1:], command)
+	}
+	return cfg.Sink
+}
+
+// closeOutput flushes the output, reporting a failure on the standard error
+func closeOutput(out demo.EventSink) {
+	if err := out.Close(); err != nil {
+		log.Printf("Error: %v", err)
+	}
+}
+
+// exit flushes the output and ends the program with the given status
+func exit(out demo.EventSink, code int) {
+	closeOutput(out)
+	os.Exit(code)
+}
+
+// fatalf flushes the output and ends the program with an error message
+func fatalf(out demo.EventSink, format string, args ...interface{}) {
+	closeOutput(out)
+	log.Fatalf(format, args...)
+}
+
+// noArgs ends the program if a command that takes none got arguments
+func noArgs(fs *flag.FlagSet) {
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "Unexpected argument %q\n\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+// runCommand runs the simulation
+func runCommand(cfg *demo.Config, args []string) {
+	fs := newRunFlags(cfg)
+	parseCommandFlags(cfg, fs, args)
+	noArgs(fs)
+
+	// A restored run takes the model of its checkpoint, which the flags
+	// cannot change, and keeps its own run and output options
+	var replayed *demo.Checkpoint
+	if cfg.Replay != "" {
+		model := flag.NewFlagSet("model", flag.ContinueOnError)
+		cfg.RegisterModelFlags(model)
+		fs.Visit(func(f *flag.Flag) {
+			if model.Lookup(f.Name) != nil {
+				log.Fatalf("Error: -%s changes the model, which a restored run takes from its checkpoint", f.Name)
+			}
+		})
+		var err error
+		replayed, err = cfg.LoadCheckpoint(cfg.Replay)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	startOutput(cfg, "")
+	run(cfg, replayed)
+}
+
+// validateConfigCommand checks a configuration, given as a file or by flags,
+// and builds the model it describes without running it
+func validateConfigCommand(cfg *demo.Config, args []string) {
+	fs := newCommandFlags("validate-config", cfg, (*demo.Config).RegisterModelFlags)
+	parseCommandFlags(cfg, fs, args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if fs.NArg() == 1 {
+		loadConfig(cfg, fs, args, fs.Arg(0))
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	simulation, err := demo.NewSimulation(cfg)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Configuration OK: %d producers, %d consumers\n",
+		len(simulation.Producers()), len(simulation.Consumers()))
+}
+
+// sweepScenarios are the scenarios sweep runs, by the parameters they sweep
+var sweepScenarios = map[string]string{
+	"param":    "param-sweep",
+	"seed":     "seed-sweep",
+	"capacity": "capacity-sweep",
+}
+
+// sweepCommand runs the scenario that sweeps the selected parameters
+func sweepCommand(cfg *demo.Config, args []string) {
+	fs := newCommandFlags("sweep", cfg, (*demo.Config).RegisterModelFlags, (*demo.Config).RegisterSweepFlags, (*demo.Config).RegisterOutputFlags)
+	over := fs.String("over", "", "Parameters to sweep: param (the ranges of the sweep in the config file), seed, or capacity; param if the config file has a sweep, seed otherwise")
+	parseCommandFlags(cfg, fs, args)
+	noArgs(fs)
+
+	if *over == "" {
+		*over = "seed"
+		if cfg.Sweep != nil {
+			*over = "param"
+		}
+	}
+	scenario, ok := sweepScenarios[*over]
+	if !ok {
+		log.Fatalf("Error: unknown sweep %q", *over)
+	}
+	cfg.Scenario = scenario
+
+	startOutput(cfg, "")
+	run(cfg, nil)
+}
+
+// visualizeCommand builds the model and draws its topology without running
+func visualizeCommand(cfg *demo.Config, args []string) {
+	fs := newCommandFlags("visualize", cfg, (*demo.Config).RegisterModelFlags)
+	format := fs.String("format", "dot", "Diagram format: dot (Graphviz) or mermaid")
+	path := fs.String("o", "", "Write the diagram to this file instead of the standard output")
+	parseCommandFlags(cfg, fs, args)
+	noArgs(fs)
+
+	write := map[string]func(t *demo.Topology, w io.Writer) error{
+		"dot":     (*demo.Topology).WriteDOT,
+		"mermaid": (*demo.Topology).WriteMermaid,
+	}[*format]
+	if write == nil {
+		log.Fatalf("Error: unknown diagram format %q", *format)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	simulation, err := demo.NewSimulation(cfg)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *path == "" {
+		if err := write(simulation.Topology(), os.Stdout); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	f, err := os.Create(*path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer f.Close()
+	if err := write(simulation.Topology(), f); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Topology written to %s\n", *path)
+}
+
+// compareCommand compares the metrics of "compare A B" and fails if any of
+// them regressed
+func compareCommand(cfg *demo.Config, args []string) {
+	out := startOutput(cfg, "")
+	ok, err := compareMetricsFiles(out, args)
+	if err != nil {
+		fatalf(out, "Error: %v", err)
+	}
+	if !ok {
+		exit(out, 1)
+	}
+}
+
+// compareMetricsFiles compares the metrics files of "compare [-threshold P]
+// <baseline> <candidate>" and reports whether no metric regressed
+func compareMetricsFiles(out demo.EventSink, args []string) (bool, error) {
+	fs := newCommandFlags("compare", nil)
+	threshold := fs.Float64("threshold", 5, "Change in percent by which a metric may get worse before it counts as a regression")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if fs.NArg() != 2 {
+		return false, fmt.Errorf("usage: compare [-threshold percent] <baseline metrics> <candidate metrics>")
+	}
+	if *threshold < 0 {
+		return false, fmt.Errorf("threshold must not be negative")
+	}
+	baseline, err := demo.ReadRunMetrics(fs.Arg(0))
+	if err != nil {
+		return false, err
+	}
+	candidate, err := demo.ReadRunMetrics(fs.Arg(1))
+	if err != nil {
+		return false, err
+	}
+
+	deltas := demo.CompareRunMetrics(baseline, candidate, *threshold)
+	demo.PrintMetricDeltas(out, fs.Arg(0), fs.Arg(1), deltas, *threshold)
+	for _, d := range deltas {
+		if d.Regressed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// describeCommand prints the model of the configuration, which any flag
+// after "describe" may change
+func describeCommand(cfg *demo.Config, args []string) {
+	fs := newCommandFlags("describe", cfg, (*demo.Config).RegisterModelFlags, (*demo.Config).RegisterOutputFlags)
+	parseCommandFlags(cfg, fs, args)
+	noArgs(fs)
+
+	out := startOutput(cfg, "describe")
+	if err := demo.DescribeModel(cfg); err != nil {
+		fatalf(out, "Error: %v", err)
+	}
+}
+
+// explainCommand follows the message of "explain -db FILE --msg-id N"
+// through the event database a run wrote with -db
+func explainCommand(cfg *demo.Config, args []string) {
+	fs := newCommandFlags("explain", cfg, (*demo.Config).RegisterOutputFlags)
+	path := fs.String("db", "", "SQLite database a run wrote with -db")
+	msgID := fs.Uint64("msg-id", 0, "ID of the message to explain")
+	parseCommandFlags(cfg, fs, args)
+	noArgs(fs)
+	if *path == "" || *msgID == 0 {
+		log.Fatalf("Error: explain needs the -db of a run and the --msg-id of a message")
+	}
+
+	out := startOutput(cfg, "")
+	if err := demo.ExplainMessage(out, *path, *msgID); err != nil {
+		fatalf(out, "Error: %v", err)
+	}
+}
+
+// diffCommand compares the state saved in the two checkpoints of "diff A B"
+// and fails if they differ
+func diffCommand(cfg *demo.Config, args []string) {
+	fs := newCommandFlags("diff", nil)
+	fs.Parse(args)
+
+	out := startOutput(cfg, "")
+	same, err := diffCheckpointFiles(out, fs.Args())
+	if err != nil {
+		fatalf(out, "Error: %v", err)
+	}
+	if !same {
+		exit(out, 1)
+	}
+}
+
+// diffCheckpointFiles reads two checkpoints and prints their differences.
+// It returns whether they describe the same state.
+func diffCheckpointFiles(out demo.EventSink, args []string) (bool, error) {
+	if len(args) != 2 {
+		return false, fmt.Errorf("usage: diff <checkpoint> <checkpoint>")
+	}
+	a, err := demo.ReadCheckpoint(args[0])
+	if err != nil {
+		return false, err
+	}
+	b, err := demo.ReadCheckpoint(args[1])
+	if err != nil {
+		return false, err
+	}
+	diffs, err := demo.DiffCheckpoints(a, b)
+	if err != nil {
+		return false, err
+	}
+	demo.PrintCheckpointDiff(out, args[0], args[1], a, b, diffs)
+	return len(diffs) == 0, nil
+}
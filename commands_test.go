@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/syifan/akita_demo/demo"
+)
+
+// TestCommands verifies that every command is found by its name and that
+// sweep runs valid scenarios
+func TestCommands(t *testing.T) {
+	names := make(map[string]bool)
+	for _, c := range commands() {
+		if names[c.name] {
+			t.Errorf("Expected one command named %s", c.name)
+		}
+		names[c.name] = true
+		if found, ok := lookupCommand(c.name); !ok || found.summary != c.summary {
+			t.Errorf("Expected to find the command %s", c.name)
+		}
+	}
+	if _, ok := lookupCommand("bogus"); ok {
+		t.Errorf("Expected no command named bogus")
+	}
+	
+	for over, scenario := range sweepScenarios {
+		cfg := demo.DefaultConfig()
+		cfg.Scenario = scenario
+		if over == "param" {
+			cfg.Sweep = &demo.ParamSweep{Consumers: demo.SweepValues{1, 2}}
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Expected sweep -over %s to run a valid scenario, got %v", over, err)
+		}
+	}
+}
+
+// TestCompareMetricsFiles verifies that the metrics a run exports are read
+// back and that the comparison fails on a regression
+func TestCompareMetricsFiles(t *testing.T) {
+	dir := t.TempDir()
+	base, slow := filepath.Join(dir, "base.json"), filepath.Join(dir, "slow.json")
+	for _, c := range []struct {
+		path     string
+		interval float64
+	}{{base, 1}, {slow, 3}} {
+		cfg := demo.DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 100
+		cfg.ConsumeInterval = c.interval
+		cfg.MetricsOut = c.path
+		cfg.Sink = demo.NullSink{}
+		simulation, err := demo.NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.PrintReport(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	m, err := demo.ReadRunMetrics(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Consumed == 0 || m.MeanLatency == 0 {
+		t.Errorf("Expected the metrics of the run, got %+v", m)
+	}
+	if ok, err := compareMetricsFiles(demo.NullSink{}, []string{base, base}); err != nil || !ok {
+		t.Errorf("Expected a run to match itself, got %v (%v)", ok, err)
+	}
+	if ok, err := compareMetricsFiles(demo.NullSink{}, []string{base, slow}); err != nil || ok {
+		t.Errorf("Expected slower consumers to regress, got %v (%v)", ok, err)
+	}
+	if ok, err := compareMetricsFiles(demo.NullSink{}, []string{"-threshold", "1000", base, slow}); err != nil || !ok {
+		t.Errorf("Expected a threshold of 1000%% to accept slower consumers, got %v (%v)", ok, err)
+	}
+	if _, err := compareMetricsFiles(demo.NullSink{}, []string{base}); err == nil {
+		t.Errorf("Expected a single file to be rejected")
+	}
+}
@@ -0,0 +1,27 @@
+package component
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Parameter is a setting of a component as it was instantiated
+type Parameter struct {
+	Name  string
+	Value string
+}
+
+// FormatFreq writes a frequency in Hz
+func FormatFreq(freq sim.Freq) string {
+	return fmt.Sprintf("%g Hz", float64(freq))
+}
+
+// Summary describes a model a component was configured with by its String
+// method, or else by its type
+func Summary(v interface{}) string {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,30 @@
+package component
+
+import (
+	"github.com/syifan/akita_demo/msg"
+)
+
+// ErrorKind is a category of the errors a run can run into
+type ErrorKind string
+
+// Kinds of errors
+const (
+	ErrUnknownDestination ErrorKind = "unknown-destination" // Message for a destination the distributor does not know
+	ErrNoRoute            ErrorKind = "no-route"            // Message for a destination no consumer registered for
+	ErrNilRemotePort      ErrorKind = "nil-remote-port"     // Registration without a port to route to
+	ErrBufferOverflow     ErrorKind = "buffer-overflow"     // Message evicted from a full buffer
+	ErrTTLExpiry          ErrorKind = "ttl-expiry"          // Message dropped once its TTL ran out
+	ErrTypeMismatch       ErrorKind = "type-mismatch"       // Message of a type the receiver does not handle
+)
+
+// DeadLetterKind returns the kind of the errors that dead-letter a message
+// for the reason
+func DeadLetterKind(reason msg.DeadLetterReason) ErrorKind {
+	switch reason {
+	case msg.ReasonInvalidType:
+		return ErrTypeMismatch
+	case msg.ReasonNoRoute, msg.ReasonNoSubscriber:
+		return ErrNoRoute
+	}
+	return ErrUnknownDestination
+}
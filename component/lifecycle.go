@@ -0,0 +1,66 @@
+// Package component holds what the components of the demo share: the phases
+// of a run, the outcome of a tick, the tasks they report to hooks, and the
+// parameters they describe themselves with.
+package component
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Lifecycle is implemented by the components the Simulation drives through
+// the phases of a run
+type Lifecycle interface {
+	// Init checks that the component is ready to run, before the run starts
+	Init() error
+	// Start kicks off the component at the beginning of the run
+	Start(now sim.VTimeInSec)
+	// Drain tells the component that no more messages are generated
+	Drain(now sim.VTimeInSec)
+	// Finalize flushes the statistics of the component after the run
+	Finalize(now sim.VTimeInSec)
+}
+
+// BaseLifecycle implements every phase as a no-op. Components embed it and
+// override the phases they take part in.
+type BaseLifecycle struct{}
+
+// Init does nothing
+func (BaseLifecycle) Init() error { return nil }
+
+// Start does nothing
+func (BaseLifecycle) Start(now sim.VTimeInSec) {}
+
+// Drain does nothing
+func (BaseLifecycle) Drain(now sim.VTimeInSec) {}
+
+// Finalize does nothing
+func (BaseLifecycle) Finalize(now sim.VTimeInSec) {}
+
+// SelfStarting marks the components that tick at the beginning of the run
+// instead of waiting for a message to wake them up
+type SelfStarting interface {
+	SelfStarting()
+}
+
+// TickOutcome classifies what a component did in a tick
+type TickOutcome int
+
+// Outcomes of a tick
+const (
+	TickIdle    TickOutcome = iota // Nothing to do
+	TickBusy                       // Did useful work
+	TickBlocked                    // Had work but could not make progress
+)
+
+// ProgressOutcome classifies a tick that had work to do
+func ProgressOutcome(madeProgress bool) TickOutcome {
+	if madeProgress {
+		return TickBusy
+	}
+	return TickBlocked
+}
+
+// ClockTolerance absorbs the rounding of the tick times of clocks whose
+// period is not a power of two, so that an interval of whole periods ends
+// on a tick
+const ClockTolerance = 1e-9
@@ -0,0 +1,12 @@
+package component
+
+// Logger receives the events a component reports as it runs
+type Logger interface {
+	Printf(format string, a ...interface{})
+}
+
+// Discard is a logger that drops every event
+type Discard struct{}
+
+// Printf does nothing
+func (Discard) Printf(format string, a ...interface{}) {}
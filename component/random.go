@@ -0,0 +1,33 @@
+package component
+
+import (
+	"math/rand"
+)
+
+// CountingSource is a random source that counts the values drawn from it, so
+// that the state of a generator is its initial seed and the number of draws
+type CountingSource struct {
+	rand.Source64 `json:"-"`
+	InitialSeed   int64
+	Draws         uint64
+}
+
+// NewCountingSource creates a source seeded with seed
+func NewCountingSource(seed int64) *CountingSource {
+	return &CountingSource{
+		Source64:    rand.NewSource(seed).(rand.Source64),
+		InitialSeed: seed,
+	}
+}
+
+// Int63 draws a value
+func (s *CountingSource) Int63() int64 {
+	s.Draws++
+	return s.Source64.Int63()
+}
+
+// Uint64 draws a value
+func (s *CountingSource) Uint64() uint64 {
+	s.Draws++
+	return s.Source64.Uint64()
+}
@@ -0,0 +1,42 @@
+package component
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/tracing"
+)
+
+// StartTask reports the start of a task of the given kind on a message to
+// the Akita tracers of the domain: the producer generates a message and
+// waits for its ACK, the distributor routes it, a link transfers it, and a
+// consumer consumes it from the head of an RX queue. The other tasks are
+// children of the generation.
+func StartTask(domain tracing.NamedHookable, kind string, msgID uint64) {
+	if domain.NumHooks() == 0 {
+		return
+	}
+
+	parentID := ""
+	if kind != "generate" {
+		parentID = taskID(domain, "generate", msgID)
+	}
+	tracing.StartTask(taskID(domain, kind, msgID), parentID, domain, kind, "DemoMessage", nil)
+}
+
+// EndTask reports the end of a task of the given kind on a message
+func EndTask(domain tracing.NamedHookable, kind string, msgID uint64) {
+	if domain.NumHooks() == 0 {
+		return
+	}
+
+	tracing.EndTask(taskID(domain, kind, msgID), domain)
+}
+
+// taskID names the task of a message. A message is generated once, but may
+// be routed, transferred, or consumed by several components.
+func taskID(domain tracing.NamedHookable, kind string, msgID uint64) string {
+	if kind == "generate" {
+		return fmt.Sprintf("generate-%d", msgID)
+	}
+	return fmt.Sprintf("%s-%d@%s", kind, msgID, domain.Name())
+}
@@ -0,0 +1,35 @@
+package component
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// wakeupEvent wakes up a ticking component at a later time. Unlike TickNow
+// with a future time, it does not suppress the earlier wake-ups of the
+// component, such as message arrivals.
+type wakeupEvent struct {
+	*sim.EventBase
+}
+
+type wakeupHandler struct {
+	component *sim.TickingComponent
+}
+
+// Handle ticks the component at the wake-up time
+func (h *wakeupHandler) Handle(e sim.Event) error {
+	h.component.TickNow(e.Time())
+	return nil
+}
+
+// ScheduleWakeup makes the component tick at the given time
+func ScheduleWakeup(component *sim.TickingComponent, t sim.VTimeInSec) {
+	component.Engine.Schedule(&wakeupEvent{
+		EventBase: sim.NewEventBase(t, &wakeupHandler{component: component}),
+	})
+}
+
+// CheckpointTypes returns the events of this package, which a checkpoint
+// restores among the pending events of the engine
+func CheckpointTypes() []interface{} {
+	return []interface{}{&wakeupEvent{}, &wakeupHandler{}}
+}
@@ -0,0 +1,79 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// InputPort returns the input port of the first RX queue
+func (c *Consumer) InputPort() sim.Port {
+	return c.inputPort
+}
+
+// CtrlPort returns the port the consumer registers and sends its ACKs on
+func (c *Consumer) CtrlPort() sim.Port {
+	return c.ctrlPort
+}
+
+// Interval returns the time between consuming messages per RX queue
+func (c *Consumer) Interval() sim.VTimeInSec {
+	return c.consumeRate
+}
+
+// SetInterval changes the time between consuming messages during the run
+func (c *Consumer) SetInterval(interval sim.VTimeInSec) {
+	c.consumeRate = interval
+}
+
+// Registered reports whether the consumer registered with the distributor
+func (c *Consumer) Registered() bool {
+	return c.registered
+}
+
+// PendingAcks returns the number of ACKs waiting for the control port
+func (c *Consumer) PendingAcks() int {
+	return len(c.pendingAcks)
+}
+
+// Received returns the number of messages that arrived in the RX queues
+func (c *Consumer) Received() int {
+	return c.received
+}
+
+// Consumed returns the number of messages every RX queue consumed
+func (c *Consumer) Consumed() []int {
+	consumed := make([]int, len(c.rxQueues))
+	for i, q := range c.rxQueues {
+		consumed[i] = q.consumed
+	}
+	return consumed
+}
+
+// Queued returns the number of messages queued in every RX queue
+func (c *Consumer) Queued() []int {
+	queued := make([]int, len(c.rxQueues))
+	for i, q := range c.rxQueues {
+		queued[i] = q.buf.Size()
+	}
+	return queued
+}
+
+// TotalConsumed returns the number of messages the consumer consumed
+func (c *Consumer) TotalConsumed() int {
+	total := 0
+	for _, q := range c.rxQueues {
+		total += q.consumed
+	}
+	return total
+}
+
+// LastConsumed returns the time the consumer consumed its last message, 0 if
+// it consumed none
+func (c *Consumer) LastConsumed() sim.VTimeInSec {
+	var last sim.VTimeInSec
+	for _, q := range c.rxQueues {
+		if q.consumed > 0 && q.lastConsumed > last {
+			last = q.lastConsumed
+		}
+	}
+	return last
+}
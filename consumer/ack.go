@@ -0,0 +1,46 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// queueAck prepares the acknowledgment of a consumed message for the
+// producer that sent it
+func (c *Consumer) queueAck(m *msg.DemoMessage) {
+	ackPort, ok := c.ackPorts[m.Source]
+	if !ok {
+		return
+	}
+
+	ack := &msg.AckMsg{MsgID: m.ID, Consumer: c.Name()}
+	ack.Meta().Src = c.ctrlPort
+	ack.Meta().Dst = ackPort
+	c.pendingAcks = append(c.pendingAcks, ack)
+}
+
+// queueWindowAck prepares the acknowledgment of a consumed or dropped message
+// for the distributor, which frees a slot of the consumer's window
+func (c *Consumer) queueWindowAck(m *msg.DemoMessage) {
+	if c.windowAcks == nil {
+		return
+	}
+
+	ack := &msg.AckMsg{MsgID: m.ID, Consumer: c.Name()}
+	ack.Meta().Src = c.ctrlPort
+	ack.Meta().Dst = c.windowAcks
+	c.pendingAcks = append(c.pendingAcks, ack)
+}
+
+// flushAcks sends the queued acknowledgments until the control port is busy.
+// The consumer is woken up again when the port becomes free.
+func (c *Consumer) flushAcks(now sim.VTimeInSec) {
+	for len(c.pendingAcks) > 0 {
+		ack := c.pendingAcks[0]
+		ack.Meta().SendTime = now
+		if err := c.ctrlPort.Send(ack); err != nil {
+			return
+		}
+		c.pendingAcks = c.pendingAcks[1:]
+	}
+}
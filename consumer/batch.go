@@ -0,0 +1,28 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Drain wakes up a batch consumer to process its partial batches
+func (c *Consumer) Drain(now sim.VTimeInSec) {
+	if c.batchSize > 0 {
+		c.TickNow(now)
+	}
+}
+
+// batchReady reports whether the messages of an RX queue may be processed.
+// It starts a new batch once enough messages are queued.
+func (c *Consumer) batchReady(q *rxQueue, now sim.VTimeInSec) bool {
+	if c.batchSize == 0 || q.batchLeft > 0 || now >= c.flushAt {
+		return true
+	}
+
+	if q.buf.Size() < c.batchSize {
+		return false
+	}
+
+	q.batchLeft = c.batchSize
+	c.log.Printf("[%.2f] Consumer %s: Batch of %d messages complete\n", now, c.Name(), c.batchSize)
+	return true
+}
@@ -0,0 +1,43 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// EvictBelow takes the latest queued message of the lowest priority below
+// priority out of the RX queues, to make room in a shared buffer, and
+// returns it with the port of its queue. The messages are rotated through
+// the buffer of a queue to find it, which keeps the others in order. It
+// returns nil if no queued message has a lower priority.
+func (c *Consumer) EvictBelow(now sim.VTimeInSec, priority int) (*msg.DemoMessage, sim.Port) {
+	var victim *msg.DemoMessage
+	var victimQueue *rxQueue
+	for _, q := range c.rxQueues {
+		for i, n := 0, q.buf.Size(); i < n; i++ {
+			item := q.buf.Pop()
+			m, ok := item.(*msg.DemoMessage)
+			if ok && m.Priority < priority && (victim == nil || m.Priority <= victim.Priority) {
+				victim, victimQueue = m, q
+			}
+			q.buf.Push(item)
+		}
+	}
+	if victim == nil {
+		return nil, nil
+	}
+	for i, n := 0, victimQueue.buf.Size(); i < n; i++ {
+		item := victimQueue.buf.Pop()
+		if item != victim {
+			victimQueue.buf.Push(item)
+		}
+	}
+
+	component.EndTask(c, "consume", victim.ID)
+	c.queueWindowAck(victim)
+	c.log.Printf("[%.2f] Consumer %s: Evicted message of priority %d for one of priority %d: %s\n",
+		now, c.Name(), victim.Priority, priority, victim.Content)
+	c.conclude(now, victim.ID, "evicted from the shared buffer by a message of priority %d", priority)
+	return victim, victimQueue.port
+}
@@ -0,0 +1,36 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// QueueCheckpoint is the progress of an RX queue saved in checkpoints
+type QueueCheckpoint struct {
+	Consumed     int
+	LastConsumed sim.VTimeInSec
+	BatchLeft    int
+}
+
+// Checkpoint is the state of a consumer saved in checkpoints
+type Checkpoint struct {
+	Registered  bool
+	PendingAcks []uint64
+	Queues      []QueueCheckpoint
+}
+
+// CheckpointState returns the registration, the pending ACKs, and the
+// progress of every RX queue of the consumer
+func (c *Consumer) CheckpointState() interface{} {
+	state := Checkpoint{Registered: c.registered}
+	for _, ack := range c.pendingAcks {
+		state.PendingAcks = append(state.PendingAcks, ack.MsgID)
+	}
+	for _, q := range c.rxQueues {
+		state.Queues = append(state.Queues, QueueCheckpoint{
+			Consumed:     q.consumed,
+			LastConsumed: q.lastConsumed,
+			BatchLeft:    q.batchLeft,
+		})
+	}
+	return state
+}
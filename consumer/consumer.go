@@ -0,0 +1,388 @@
+// Package consumer holds the component of the demo that receives the
+// messages from the distributor and serves them. How it serves them, and
+// what it reports to, is given as options.
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Consumer consumes messages at a fixed rate
+type Consumer struct {
+	*sim.TickingComponent
+	component.BaseLifecycle
+	inputPort       sim.Port   // Input port of the first RX queue
+	rxQueues        []*rxQueue // RX queues, each with its own processing context
+	numQueues       int        // RX queues to create
+	queueCapacity   int        // Messages every RX queue holds
+	ctrlPort        sim.Port   // Control-plane port for registration
+	registry        sim.Port   // Distributor's control port, nil if routes are configured statically
+	registered      bool
+	registerAt      sim.VTimeInSec // Time the consumer subscribes, later than 0 for late subscribers
+	subscriptions   []string       // Topic patterns to subscribe to once registered
+	unsubscriptions []string       // Topic patterns to unsubscribe from at unsubscribeAt
+	unsubscribeAt   sim.VTimeInSec // Time to unsubscribe, 0 never unsubscribes
+	subscribed      bool
+	unsubscribed    bool
+	joins           []string       // Multicast groups to join at joinAt
+	joinAt          sim.VTimeInSec // Time to join, 0 joins once registered
+	leaves          []string       // Multicast groups to leave at leaveAt
+	leaveAt         sim.VTimeInSec // Time to leave, 0 never leaves
+	joined          bool
+	left            bool
+	pendingControl  []sim.Msg      // Subscriptions and membership changes waiting for the control port
+	leaveGroupAt    sim.VTimeInSec // Time to leave the consumer group, 0 never leaves
+	leftGroup       bool
+	workPool        sim.Port   // Work pool to pull the messages from, nil if they are pushed
+	pulling         bool       // Whether a pull is waiting for a message
+	stealPort       sim.Port   // Sends and receives steal requests, nil if the consumer does not steal
+	peers           []sim.Port // Steal ports of the other consumers, asked in turn
+	stealThreshold  int        // Messages queued from which the consumer gives work away
+	stealing        Stealing   // Counts the steal requests, nil counts none
+	nextPeer        int
+	refusals        int                 // Peers that refused since the last arrival
+	stealPending    bool                // Whether a steal request is on its way
+	stolen          []*msg.DemoMessage  // Stolen messages waiting for room in the RX queue
+	ackPorts        map[string]sim.Port // Control ports of the producers by name, which receive their ACKs
+	windowAcks      sim.Port            // Distributor's control port if it limits the messages outstanding here, nil otherwise
+	pendingAcks     []*msg.AckMsg       // ACKs waiting for the control port
+	consumeRate     sim.VTimeInSec      // Time between consuming messages per RX queue
+	service         ServiceDist         // Draws the service times around consumeRate, nil serves every message in consumeRate
+	queueing        Queueing            // Measures the queue, nil measures nothing
+	coalesced       bool                // Only wake up on interrupts, not on message arrival
+	polling         bool                // Tick every cycle instead of being woken up by events
+	pollUntil       sim.VTimeInSec      // Time after which a polling consumer stops polling empty queues
+	batchSize       int                 // Messages to collect before processing, 0 processes on arrival
+	flushAt         sim.VTimeInSec      // Time after which partial batches are processed
+	backpressure    Backpressure        // Measures the congestion of the consumer, nil measures nothing
+	verifier        Verifier            // Checks the order of consumed messages, nil skips the check
+	reorder         Reorder             // Delivers consumed messages in order, nil delivers them as consumed
+	timestamps      Timestamps          // Records the latencies from raw and corrected stamps, nil records none
+	overflow        Overflow            // Latency of rerouted messages, nil if nothing is rerouted
+	middleware      *middleware.Chain   // Intercepts messages before serving them, nil serves them as they come
+	faults          Faults              // Takes the consumer down, nil keeps it up
+	downUntil       sim.VTimeInSec      // End of the downtime whose wake-up is scheduled
+	heartbeat       sim.VTimeInSec      // Time between heartbeats, 0 sends none
+	heartbeatUntil  sim.VTimeInSec      // Time after which no heartbeat is sent
+	nextHeartbeat   sim.VTimeInSec
+	attribution     Attribution    // Latencies by the decisions taken for the messages, nil records none
+	retransmitter   Retransmitter  // Discards the messages consumed before, nil serves them again
+	auditor         Auditor        // Checks that every message sent is consumed once, nil checks none
+	sizeService     SizeService    // Sizes and service times of consumed messages, nil records none
+	pauses          Pauses         // Windows in which no message is served, nil never pauses
+	pausedUntil     sim.VTimeInSec // End of the pause whose wake-up is scheduled
+	received        int            // Messages that arrived in the RX queues
+	removing        bool           // Set once the consumer is being removed
+	removeStarted   sim.VTimeInSec
+	routed          int                      // Messages routed to the consumer before its removal
+	onDetach        func(now sim.VTimeInSec) // Detaches the ports of a drained consumer being removed
+	detached        bool
+	events          Events // Records the fate of messages, nil records nothing
+	errors          Errors // Reports the errors, nil logs them
+	stats           Stats  // Counts the messages, nil counts none
+	log             component.Logger
+}
+
+// New creates a consumer that serves a message every interval on each of
+// its RX queues. Without options, it ticks at 1 Hz, has a single RX queue of
+// 10 messages, and never registers, its route is static.
+func New(name string, engine sim.Engine, interval sim.VTimeInSec, opts ...Option) *Consumer {
+	c := &Consumer{
+		consumeRate:   interval,
+		numQueues:     1,
+		queueCapacity: 10,
+		log:           component.Discard{},
+	}
+	c.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, c)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	for i := 0; i < c.numQueues; i++ {
+		portName := name + ".In"
+		if i > 0 {
+			portName = fmt.Sprintf("%s.In%d", name, i)
+		}
+		c.rxQueues = append(c.rxQueues, newRxQueue(c, portName, c.queueCapacity))
+	}
+	c.inputPort = c.rxQueues[0].port
+	c.ctrlPort = sim.NewLimitNumMsgPort(c, 1, name+".Ctrl")
+	return c
+}
+
+// NotifyRecv wakes up the consumer when a message arrives, unless the
+// consumer waits for coalesced interrupts from the distributor
+func (c *Consumer) NotifyRecv(now sim.VTimeInSec, port sim.Port) {
+	if port == c.stealPort {
+		// Steal requests and answers are not messages to consume
+		c.TickingComponent.NotifyRecv(now, port)
+		return
+	}
+	c.received++
+	c.refusals = 0
+	c.pulling = false
+	if c.backpressure != nil {
+		c.backpressure.ObserveDepth(now, c.Name(), c.QueueDepth())
+	}
+	if c.pauses != nil {
+		c.pauses.Arrived(now, c.QueueDepth())
+	}
+	if c.queueing != nil {
+		c.queueing.Arrived()
+	}
+	if c.coalesced {
+		return
+	}
+	if c.stats != nil {
+		c.stats.RecordNotification()
+	}
+	c.TickingComponent.NotifyRecv(now, port)
+}
+
+// Interrupt wakes up the consumer to process all the queued messages
+func (c *Consumer) Interrupt(now sim.VTimeInSec) {
+	if c.stats != nil {
+		c.stats.RecordNotification()
+	}
+	c.TickLater(now)
+}
+
+// Tick processes messages at a fixed rate on every RX queue
+func (c *Consumer) Tick(now sim.VTimeInSec) bool {
+	if c.detached {
+		return false
+	}
+	outcome := component.TickIdle
+	if c.stats != nil {
+		c.stats.RecordConsumerTick()
+		defer func() { c.stats.RecordTick(c.Name(), outcome) }()
+	}
+
+	// Announce this consumer to the distributor before anything else
+	if c.registry != nil && !c.registered && !c.register(now) {
+		// Control port busy, will be woken up when it becomes free
+		return false
+	}
+	if len(c.subscriptions) > 0 {
+		c.updateSubscriptions(now)
+	}
+	if c.leaveGroupAt > 0 && !c.leftGroup {
+		c.leaveGroup(now)
+	}
+	if len(c.joins) > 0 || len(c.leaves) > 0 {
+		c.updateMemberships(now)
+	}
+
+	// A consumer that is down loses what it is sent until it is back
+	if c.downFault(now) {
+		return false
+	}
+
+	// A consumer that is up tells the distributor
+	c.sendHeartbeat(now)
+
+	// A paused consumer serves no message until the pause ends
+	if c.pauseConsumer(now) {
+		if c.QueueDepth() > 0 {
+			outcome = component.TickBlocked
+		}
+		return false
+	}
+
+	if c.stealPort != nil {
+		c.handleSteals(now)
+	}
+	madeProgress, pending := c.consumeAll(now)
+	if madeProgress || pending {
+		outcome = component.ProgressOutcome(madeProgress)
+	}
+	if c.backpressure != nil {
+		c.backpressure.ObserveDepth(now, c.Name(), c.QueueDepth())
+	}
+	c.flushAcks(now)
+	if c.workPool != nil {
+		c.pull(now)
+	}
+	if c.stealPort != nil {
+		c.steal(now)
+	}
+
+	// A consumer being removed is detached once it has consumed everything
+	// it was sent
+	if c.removing && c.drainedForRemoval() {
+		c.detach(now)
+		return false
+	}
+
+	if c.polling {
+		// A polling consumer checks its queues every cycle, whether or not
+		// there is anything to consume, until the run ends and it is drained
+		return now < c.pollUntil || pending
+	}
+
+	if !madeProgress {
+		// Nothing consumed (no messages or rate limited), return false to
+		// stop ticking. If messages are still queued, schedule a wake-up so
+		// the queues drain even when no new message arrives.
+		if pending {
+			c.TickLater(now)
+		}
+		return false
+	}
+
+	// Message consumed, continue ticking if more messages available
+	return pending
+}
+
+// consumeAll tries to consume from every RX queue. It reports whether any
+// message was consumed and whether messages are still queued.
+func (c *Consumer) consumeAll(now sim.VTimeInSec) (madeProgress, pending bool) {
+	for i, q := range c.rxQueues {
+		if c.consumeFrom(i, q, now) {
+			madeProgress = true
+		}
+		if q.port.Peek() != nil && c.batchReady(q, now) {
+			pending = true
+		}
+	}
+	return madeProgress, pending
+}
+
+// consumeFrom consumes at most one message from the given RX queue and
+// returns true if a message was taken out of the queue
+func (c *Consumer) consumeFrom(index int, q *rxQueue, now sim.VTimeInSec) bool {
+	// The consume task of a message starts when it reaches the head of the
+	// queue, which may happen after the messages ahead are taken out
+	c.traceHead(q)
+	defer c.traceHead(q)
+
+	// Check if enough time has passed since last consumption
+	service := c.serviceTime(q)
+	if now-q.lastConsumed < service-component.ClockTolerance {
+		return false
+	}
+
+	// Expired messages are dropped without using the processing slot
+	c.dropExpired(q, now)
+	c.dropDuplicates(q, now)
+
+	head := q.port.Peek()
+	if head == nil {
+		return false
+	}
+
+	// A batch consumer waits until a full batch is queued
+	if !c.batchReady(q, now) {
+		return false
+	}
+
+	m, ok := head.(*msg.DemoMessage)
+	if !ok {
+		// Invalid message, consume and discard it
+		q.port.Retrieve(now)
+		c.report(now, component.ErrTypeMismatch, "Discarded message of type %T", head)
+		return true
+	}
+
+	// Middlewares may drop or hold the message before it is served
+	if c.middleware.Active() {
+		if taken, done := c.intercept(q, now, m); done {
+			return taken
+		}
+	}
+
+	q.port.Retrieve(now)
+	component.EndTask(c, "consume", m.ID)
+	q.lastConsumed = now
+	q.consumed++
+	latency := now - m.CreateTime
+	if c.queueing != nil {
+		c.queueing.Served(now, c.Freq.NextTick(m.Meta().RecvTime))
+	}
+	if c.verifier != nil {
+		c.verifier.Check(now, m.Addressee(), m)
+	}
+	if c.reorder != nil {
+		c.reorder.Serve(now, m)
+	}
+	if c.timestamps != nil {
+		c.timestamps.Consumed(now, c.Name(), m)
+	}
+	if q.batchLeft > 0 {
+		q.batchLeft--
+	}
+	if c.stats != nil {
+		c.stats.RecordConsumed(now, m)
+		if c.stats.Measures(m.CreateTime) {
+			c.stats.RecordPairLatency(m.Source, c.Name(), latency)
+		}
+	}
+	if c.overflow != nil {
+		c.overflow.Consumed(m, latency)
+	}
+	if c.attribution != nil {
+		c.attribution.Consumed(c.Name(), m, latency)
+	}
+	if c.retransmitter != nil {
+		c.retransmitter.Consumed(c.Name(), m.ID)
+	}
+	if c.auditor != nil {
+		c.auditor.Delivered(c.Name(), m)
+	}
+	if c.sizeService != nil {
+		c.sizeService.Consumed(m, service, latency)
+	}
+	if c.pauses != nil {
+		c.pauses.Consumed(now, m.CreateTime)
+	}
+	if c.faults != nil {
+		c.faults.Consumed(now, c.Name(), m.CreateTime)
+	}
+	c.queueAck(m)
+	c.queueWindowAck(m)
+	c.conclude(now, m.ID, "consumed %.2f s after its creation", float64(latency))
+	if len(c.rxQueues) > 1 {
+		c.log.Printf("[%.2f] Consumer %s: Consumed message: %s (rx %d, queue: %d)\n",
+			now, c.Name(), m.Content, index, q.buf.Size())
+	} else {
+		c.log.Printf("[%.2f] Consumer %s: Consumed message: %s (queue: %d)\n",
+			now, c.Name(), m.Content, q.buf.Size())
+	}
+	m.Release()
+
+	return true
+}
+
+// conclude records the fate of a message
+func (c *Consumer) conclude(now sim.VTimeInSec, id uint64, format string, args ...interface{}) {
+	if c.events != nil {
+		c.events.Conclude(now, c.Name(), id, format, args...)
+	}
+}
+
+// report passes an error to the error log, or logs it if there is none
+func (c *Consumer) report(now sim.VTimeInSec, kind component.ErrorKind, format string, args ...interface{}) {
+	source := "Consumer " + c.Name()
+	if c.errors != nil {
+		c.errors.Report(now, source, kind, format, args...)
+		return
+	}
+	c.log.Printf("[%.2f] %s: %s\n", now, source, fmt.Sprintf(format, args...))
+}
+
+// traceHead starts the consume task of the message at the head of an RX
+// queue
+func (c *Consumer) traceHead(q *rxQueue) {
+	if m, ok := q.port.Peek().(*msg.DemoMessage); ok {
+		component.StartTask(c, "consume", m.ID)
+	}
+}
+
+// SelfStarting makes the consumer tick from the beginning, it registers with
+// the distributor
+func (c *Consumer) SelfStarting() {}
@@ -0,0 +1,180 @@
+package consumer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// countingStats counts what the consumer records
+type countingStats struct {
+	consumed, expired int
+}
+
+func (s *countingStats) RecordNotification()                                                 {}
+func (s *countingStats) RecordConsumerTick()                                                 {}
+func (s *countingStats) RecordTick(name string, outcome component.TickOutcome)               {}
+func (s *countingStats) RecordConsumed(now sim.VTimeInSec, m *msg.DemoMessage)               { s.consumed++ }
+func (s *countingStats) RecordExpired(consumer string)                                       { s.expired++ }
+func (s *countingStats) Measures(t sim.VTimeInSec) bool                                      { return true }
+func (s *countingStats) RecordPairLatency(producer, consumer string, latency sim.VTimeInSec) {}
+
+// stealCounts counts the messages the consumers gave away
+type stealCounts map[string]int
+
+func (s stealCounts) Asked(thief string)        {}
+func (s stealCounts) Stole(thief string, n int) {}
+func (s stealCounts) Gave(victim string, n int) { s[victim] += n }
+
+// peer is a component at the other end of a connection to the consumer
+type peer struct {
+	*sim.ComponentBase
+	port sim.Port
+}
+
+func newPeer(name string) *peer {
+	p := &peer{ComponentBase: sim.NewComponentBase(name)}
+	p.port = sim.NewLimitNumMsgPort(p, 4, name+".Port")
+	return p
+}
+
+func (p *peer) Handle(e sim.Event) error                         { return nil }
+func (p *peer) NotifyRecv(now sim.VTimeInSec, port sim.Port)     {}
+func (p *peer) NotifyPortFree(now sim.VTimeInSec, port sim.Port) {}
+
+// sentRecorder records the messages sent through a port
+type sentRecorder struct {
+	msgs []sim.Msg
+}
+
+func (r *sentRecorder) Func(ctx sim.HookCtx) {
+	if ctx.Pos == sim.HookPosPortMsgSend {
+		r.msgs = append(r.msgs, ctx.Item.(sim.Msg))
+	}
+}
+
+// queue delivers a message to the first RX queue of the consumer
+func queue(c *Consumer, m *msg.DemoMessage) {
+	m.Meta().Dst = c.inputPort
+	c.inputPort.Recv(m)
+}
+
+// TestNewAppliesOptions verifies that the options configure the consumer
+// and its RX queues
+func TestNewAppliesOptions(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	c := New("Consumer1", engine, 2,
+		WithFreq(3*sim.Hz),
+		WithQueues(2, 4),
+		WithBatches(5, 10))
+	
+	if c.Freq != 3*sim.Hz || c.Interval() != 2 || c.batchSize != 5 || c.flushAt != 10 {
+		t.Errorf("Expected 3 Hz, an interval of 2, and batches of 5 until 10, got %v, %v, and %d until %v",
+			c.Freq, c.Interval(), c.batchSize, c.flushAt)
+	}
+	ports := c.RxPorts()
+	if len(ports) != 2 || ports[0] != c.InputPort() || ports[1].Name() != "Consumer1.In1" {
+		t.Fatalf("Expected the RX queues Consumer1.In and Consumer1.In1, got %d queues", len(ports))
+	}
+	for i := 0; i < 5; i++ {
+		queue(c, &msg.DemoMessage{ID: uint64(i + 1)})
+	}
+	if depth := c.QueueDepth(); depth != 4 {
+		t.Errorf("Expected the RX queue to hold 4 messages, got %d", depth)
+	}
+}
+
+// TestConsumerReturnsFalseWhenRateLimiting verifies that the consumer
+// returns false when rate limiting prevents consumption
+func TestConsumerReturnsFalseWhenRateLimiting(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	c := New("Consumer1", engine, 1.0)
+	
+	// Simulate that a message was just consumed
+	c.rxQueues[0].lastConsumed = 0
+	queue(c, &msg.DemoMessage{Content: "Test message", Destination: "Consumer1"})
+	
+	// Try to tick before consume rate has passed (at time 0.5)
+	result := c.Tick(0.5)
+	
+	if result != false {
+		t.Errorf("Expected Consumer.Tick() to return false when rate limiting, got %v", result)
+	}
+}
+
+// TestConsumerKeepsItsRateOnAThreeHertzClock verifies that a consumer whose
+// consume interval spans whole periods of its clock consumes on the tick
+// that ends the interval, despite the rounding of the tick times
+func TestConsumerKeepsItsRateOnAThreeHertzClock(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := &countingStats{}
+	c := New("Consumer1", engine, 1.0, WithFreq(3), WithStats(stats))
+	queue(c, &msg.DemoMessage{ID: 1, Destination: "Consumer1"})
+	// As if a message was consumed on the tick at 5/3 s, which is rounded to
+	// less than 1 s before the one at 8/3 s
+	c.rxQueues[0].lastConsumed = 5.0 / 3
+	
+	c.TickLater(0)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if stats.consumed != 1 {
+		t.Fatalf("Expected 1 message consumed, got %d", stats.consumed)
+	}
+	if last := c.LastConsumed(); math.Abs(float64(last)-8.0/3) > 1e-6 {
+		t.Errorf("Expected the message consumed at 8/3 s, got %v", last)
+	}
+}
+
+// TestGiveWorkTakesNewerHalf verifies that a victim gives away the newest
+// messages of its queue, as many as the thief has room for, and refuses
+// below the threshold
+func TestGiveWorkTakesNewerHalf(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	victim := New("Victim", engine, 1)
+	thief := newPeer("Thief")
+	given := stealCounts{}
+	ConnectPeers([]*Consumer{victim}, 3, given)
+	conn := sim.NewDirectConnection("Peers", engine, 1*sim.Hz)
+	conn.PlugIn(victim.stealPort, 4)
+	conn.PlugIn(thief.port, 4)
+	sent := &sentRecorder{}
+	victim.stealPort.AcceptHook(sent)
+	
+	ask := func(room int) *msg.StealRsp {
+		req := &msg.StealReq{Thief: "Thief", Room: room}
+		req.Meta().Src = thief.port
+		if !victim.giveWork(0, req) {
+			t.Fatal("Expected the answer to be sent")
+		}
+		return sent.msgs[len(sent.msgs)-1].(*msg.StealRsp)
+	}
+	
+	q := victim.rxQueues[0]
+	for id := uint64(1); id <= 2; id++ {
+		q.buf.Push(&msg.DemoMessage{ID: id})
+	}
+	if rsp := ask(10); len(rsp.Msgs) != 0 {
+		t.Errorf("Expected a refusal below the threshold, got %d messages", len(rsp.Msgs))
+	}
+	for id := uint64(3); id <= 5; id++ {
+		q.buf.Push(&msg.DemoMessage{ID: id})
+	}
+	rsp := ask(10)
+	if len(rsp.Msgs) != 2 || rsp.Msgs[0].ID != 4 || rsp.Msgs[1].ID != 5 {
+		t.Errorf("Expected messages 4 and 5, got %v", rsp.Msgs)
+	}
+	if q.buf.Size() != 3 || q.buf.Peek().(*msg.DemoMessage).ID != 1 {
+		t.Errorf("Expected the victim to keep messages 1 to 3 in order, got %d", q.buf.Size())
+	}
+	if rsp := ask(1); len(rsp.Msgs) != 1 || rsp.Msgs[0].ID != 3 {
+		t.Errorf("Expected message 3 for a thief with room for one, got %v", rsp.Msgs)
+	}
+	if given["Victim"] != 3 {
+		t.Errorf("Expected the victim to give away 3 messages, got %d", given["Victim"])
+	}
+}
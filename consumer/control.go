@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// register sends the consumer's registration to the distributor once its
+// registration delay has passed. It returns false if the registration could
+// not be sent yet.
+func (c *Consumer) register(now sim.VTimeInSec) bool {
+	if now < c.registerAt {
+		c.TickNow(c.registerAt)
+		return false
+	}
+
+	m := &msg.RegisterMsg{
+		Name:  c.Name(),
+		Ports: c.RxPorts(),
+	}
+	m.Meta().Src = c.ctrlPort
+	m.Meta().Dst = c.registry
+	m.Meta().SendTime = now
+
+	if err := c.ctrlPort.Send(m); err != nil {
+		return false
+	}
+
+	c.registered = true
+	return true
+}
+
+// updateSubscriptions queues the consumer's subscriptions once it has
+// registered, and its unsubscriptions once their time has come, and sends
+// them in order
+func (c *Consumer) updateSubscriptions(now sim.VTimeInSec) {
+	if !c.subscribed {
+		c.subscribed = true
+		for _, pattern := range c.subscriptions {
+			c.queueSubscription(pattern, false)
+		}
+		if c.unsubscribeAt > 0 {
+			component.ScheduleWakeup(c.TickingComponent, c.unsubscribeAt)
+		}
+	}
+	if c.unsubscribeAt > 0 && now >= c.unsubscribeAt && !c.unsubscribed {
+		c.unsubscribed = true
+		for _, pattern := range c.unsubscriptions {
+			c.queueSubscription(pattern, true)
+		}
+	}
+	c.flushControl(now)
+}
+
+func (c *Consumer) queueSubscription(pattern string, unsubscribe bool) {
+	c.queueControl(&msg.SubscribeMsg{Consumer: c.Name(), Pattern: pattern, Unsubscribe: unsubscribe})
+}
+
+// updateMemberships queues the consumer's joins once their time has come,
+// and its leaves once theirs has, and sends them in order
+func (c *Consumer) updateMemberships(now sim.VTimeInSec) {
+	if !c.joined && now >= c.joinAt {
+		c.joined = true
+		for _, group := range c.joins {
+			c.queueControl(&msg.MembershipMsg{Consumer: c.Name(), Group: group})
+		}
+	}
+	if c.leaveAt > 0 && now >= c.leaveAt && !c.left {
+		c.left = true
+		for _, group := range c.leaves {
+			c.queueControl(&msg.MembershipMsg{Consumer: c.Name(), Group: group, Leave: true})
+		}
+	}
+	c.flushControl(now)
+}
+
+// leaveGroup tells the distributor that the consumer leaves its consumer
+// group, once its time has come
+func (c *Consumer) leaveGroup(now sim.VTimeInSec) {
+	if now < c.leaveGroupAt {
+		return
+	}
+	m := &msg.LeaveGroupMsg{Consumer: c.Name()}
+	m.Meta().Src = c.ctrlPort
+	m.Meta().Dst = c.registry
+	m.Meta().SendTime = now
+	if err := c.ctrlPort.Send(m); err != nil {
+		// Control port busy, will be woken up when it becomes free
+		return
+	}
+	c.leftGroup = true
+}
+
+// sendHeartbeat sends the distributor a heartbeat when one is due and wakes
+// the consumer up for the next one
+func (c *Consumer) sendHeartbeat(now sim.VTimeInSec) {
+	if c.heartbeat == 0 || !c.registered || now < c.nextHeartbeat || now >= c.heartbeatUntil {
+		return
+	}
+	c.queueControl(&msg.HeartbeatMsg{Consumer: c.Name()})
+	c.flushControl(now)
+	c.nextHeartbeat = now + c.heartbeat
+	if c.nextHeartbeat < c.heartbeatUntil {
+		component.ScheduleWakeup(c.TickingComponent, c.nextHeartbeat)
+	}
+}
+
+// queueControl queues a control message for the distributor
+func (c *Consumer) queueControl(m sim.Msg) {
+	m.Meta().Src = c.ctrlPort
+	m.Meta().Dst = c.registry
+	c.pendingControl = append(c.pendingControl, m)
+}
+
+// flushControl sends the queued control messages in order until the
+// control port is busy
+func (c *Consumer) flushControl(now sim.VTimeInSec) {
+	for len(c.pendingControl) > 0 {
+		m := c.pendingControl[0]
+		m.Meta().SendTime = now
+		if err := c.ctrlPort.Send(m); err != nil {
+			return
+		}
+		c.pendingControl = c.pendingControl[1:]
+	}
+}
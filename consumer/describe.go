@@ -0,0 +1,61 @@
+package consumer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/syifan/akita_demo/component"
+)
+
+// Describe lists how the consumer serves its RX queues
+func (c *Consumer) Describe() []component.Parameter {
+	mode := "event-driven"
+	if c.polling {
+		mode = "polling"
+	} else if c.coalesced {
+		mode = "interrupts"
+	}
+	params := []component.Parameter{
+		{Name: "Frequency", Value: component.FormatFreq(c.Freq)},
+		{Name: "Consumes", Value: fmt.Sprintf("1 message every %.2f s per RX queue", float64(c.consumeRate))},
+		{Name: "RX queues", Value: fmt.Sprint(len(c.rxQueues))},
+		{Name: "Mode", Value: mode},
+	}
+	if c.service != nil {
+		params = append(params, component.Parameter{Name: "Service times",
+			Value: component.Summary(c.service) + ", around the interval"})
+	}
+	if c.batchSize > 0 {
+		params = append(params, component.Parameter{Name: "Batches", Value: fmt.Sprintf("%d messages", c.batchSize)})
+	}
+	if c.registry != nil {
+		params = append(params, component.Parameter{Name: "Registers",
+			Value: fmt.Sprintf("with %s at %.2f", c.registry.Component().Name(), float64(c.registerAt))})
+	} else {
+		params = append(params, component.Parameter{Name: "Registers", Value: "never, the route is static"})
+	}
+	if len(c.subscriptions) > 0 {
+		params = append(params, component.Parameter{Name: "Subscribes", Value: strings.Join(c.subscriptions, ", ")})
+	}
+	if c.unsubscribeAt > 0 {
+		params = append(params, component.Parameter{Name: "Unsubscribes",
+			Value: fmt.Sprintf("from %s at %.2f", strings.Join(c.unsubscriptions, ", "), float64(c.unsubscribeAt))})
+	}
+	if c.pauses != nil {
+		params = append(params, component.Parameter{Name: "Pauses", Value: component.Summary(c.pauses)})
+	}
+	if c.faults != nil {
+		if n := c.faults.Downtimes(c.Name()); n > 0 {
+			params = append(params, component.Parameter{Name: "Downtimes",
+				Value: fmt.Sprintf("%d windows, loses what it holds", n)})
+		}
+	}
+	if c.workPool != nil {
+		params = append(params, component.Parameter{Name: "Pulls", Value: "from " + c.workPool.Component().Name()})
+	}
+	if c.stealPort != nil {
+		params = append(params, component.Parameter{Name: "Steals",
+			Value: fmt.Sprintf("from %d peers with %d or more messages queued", len(c.peers), c.stealThreshold)})
+	}
+	return params
+}
@@ -0,0 +1,57 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// dropExpired removes the expired messages at the head of an RX queue
+func (c *Consumer) dropExpired(q *rxQueue, now sim.VTimeInSec) {
+	for {
+		m, ok := q.port.Peek().(*msg.DemoMessage)
+		if !ok || !m.Expired(now) {
+			return
+		}
+
+		q.port.Retrieve(now)
+		component.EndTask(c, "consume", m.ID)
+		if q.batchLeft > 0 {
+			q.batchLeft--
+		}
+		if c.stats != nil {
+			c.stats.RecordExpired(c.Name())
+		}
+		c.queueWindowAck(m)
+		c.report(now, component.ErrTTLExpiry, "Dropped expired message: %s", m.Content)
+		c.conclude(now, m.ID, "dropped, its TTL of %.2f s ran out", float64(m.TTL))
+		m.Release()
+	}
+}
+
+// dropDuplicates discards the messages at the head of an RX queue that the
+// consumer consumed already, and ACKs them again in case the first ACK was
+// too late for the producer
+func (c *Consumer) dropDuplicates(q *rxQueue, now sim.VTimeInSec) {
+	if c.retransmitter == nil {
+		return
+	}
+	for {
+		m, ok := q.port.Peek().(*msg.DemoMessage)
+		if !ok || !c.retransmitter.Duplicate(c.Name(), m.ID) {
+			return
+		}
+
+		q.port.Retrieve(now)
+		component.EndTask(c, "consume", m.ID)
+		if q.batchLeft > 0 {
+			q.batchLeft--
+		}
+		c.retransmitter.Discarded(c.Name())
+		c.queueAck(m)
+		c.queueWindowAck(m)
+		c.log.Printf("[%.2f] Consumer %s: Discarded duplicate of message %d\n", now, c.Name(), m.ID)
+		c.conclude(now, m.ID, "discarded, %s consumed it before", c.Name())
+		m.Release()
+	}
+}
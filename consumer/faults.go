@@ -0,0 +1,68 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// downFault makes a consumer that is down lose the messages it holds, or
+// leave them queued if it refuses them, and wake up once it is back. It
+// returns false if the consumer is up.
+func (c *Consumer) downFault(now sim.VTimeInSec) bool {
+	if c.faults == nil {
+		return false
+	}
+	end, down := c.faults.Down(now, c.Name())
+	if !down {
+		return false
+	}
+	if end != c.downUntil {
+		c.downUntil = end
+		c.log.Printf("[%.2f] Consumer %s: Down until %.2f\n", now, c.Name(), end)
+		component.ScheduleWakeup(c.TickingComponent, end)
+	}
+	if c.faults.Refuses() {
+		// The messages wait in the RX queues, and the ones behind them at
+		// the distributor
+		return true
+	}
+	for _, q := range c.rxQueues {
+		for {
+			m, ok := q.port.Peek().(*msg.DemoMessage)
+			if !ok {
+				break
+			}
+			q.port.Retrieve(now)
+			component.EndTask(c, "consume", m.ID)
+			q.batchLeft = 0
+			c.queueWindowAck(m)
+			c.faults.Lost(now, c.Name())
+			c.log.Printf("[%.2f] Consumer %s: Lost message while down: %s\n", now, c.Name(), m.Content)
+			c.conclude(now, m.ID, "lost, %s was down", c.Name())
+			m.Release()
+		}
+	}
+	c.flushAcks(now)
+	return true
+}
+
+// pauseConsumer makes a paused consumer wake up at the end of the pause. It
+// returns false if the consumer is not paused.
+func (c *Consumer) pauseConsumer(now sim.VTimeInSec) bool {
+	if c.pauses == nil {
+		return false
+	}
+	end, paused := c.pauses.Until(now)
+	if !paused {
+		return false
+	}
+
+	// Wake up once per pause, no matter how many messages arrive during it
+	if end != c.pausedUntil {
+		c.pausedUntil = end
+		c.log.Printf("[%.2f] Consumer %s: Paused until %.2f\n", now, c.Name(), end)
+		component.ScheduleWakeup(c.TickingComponent, end)
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// intercept passes the message at the head of an RX queue through the
+// middlewares, once. It reports whether the middlewares dropped or hold the
+// message, and then whether the message was taken out of the queue.
+func (c *Consumer) intercept(q *rxQueue, now sim.VTimeInSec, m *msg.DemoMessage) (taken, done bool) {
+	if !q.intercepted.Holds(m) {
+		q.intercepted = c.middleware.Intercept(now, middleware.StageConsume, c.Name(), m)
+		if q.intercepted.Delay > 0 {
+			c.log.Printf("[%.2f] Consumer %s: Middleware holds message for %.2f s: %s\n",
+				now, c.Name(), float64(q.intercepted.Delay), m.Content)
+			m.Held += q.intercepted.Delay
+		}
+	}
+	i := q.intercepted
+	if i.Drop {
+		q.port.Retrieve(now)
+		q.intercepted = nil
+		component.EndTask(c, "consume", m.ID)
+		if q.batchLeft > 0 {
+			q.batchLeft--
+		}
+		c.queueWindowAck(m)
+		c.log.Printf("[%.2f] Consumer %s: Middleware dropped message: %s\n", now, c.Name(), m.Content)
+		c.conclude(now, m.ID, "dropped by a middleware")
+		m.Release()
+		return true, true
+	}
+	if now < i.ReadyAt() {
+		component.ScheduleWakeup(c.TickingComponent, i.ReadyAt())
+		return false, true
+	}
+	return false, false
+}
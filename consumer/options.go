@@ -0,0 +1,339 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Stats counts the messages of the consumer
+type Stats interface {
+	RecordNotification()
+	RecordConsumerTick()
+	RecordTick(name string, outcome component.TickOutcome)
+	RecordConsumed(now sim.VTimeInSec, m *msg.DemoMessage)
+	RecordExpired(consumer string)
+	// Measures reports whether the latency of a message created at t counts
+	Measures(t sim.VTimeInSec) bool
+	RecordPairLatency(producer, consumer string, latency sim.VTimeInSec)
+}
+
+// Events records the decisions taken for the messages and their fate
+type Events interface {
+	Decide(now sim.VTimeInSec, component string, msgID uint64, format string, args ...interface{})
+	Conclude(now sim.VTimeInSec, component string, msgID uint64, format string, args ...interface{})
+}
+
+// Errors collects the errors the consumer runs into
+type Errors interface {
+	Report(now sim.VTimeInSec, source string, kind component.ErrorKind, format string, args ...interface{})
+}
+
+// ServiceDist draws the service times of the messages, as multiples of the
+// interval of the consumer
+type ServiceDist interface {
+	Draw() float64
+}
+
+// Queueing measures the queue of the consumer
+type Queueing interface {
+	Arrived()
+	// Served records a message served at now that was ready at ready
+	Served(now, ready sim.VTimeInSec)
+}
+
+// Backpressure measures the congestion of the consumer
+type Backpressure interface {
+	ObserveDepth(now sim.VTimeInSec, consumer string, depth int)
+}
+
+// Verifier checks the order of the consumed messages
+type Verifier interface {
+	Check(now sim.VTimeInSec, consumer string, m *msg.DemoMessage)
+}
+
+// Reorder delivers the consumed messages in order
+type Reorder interface {
+	Serve(now sim.VTimeInSec, m *msg.DemoMessage)
+}
+
+// Timestamps records the latencies of the consumed messages from their raw
+// and their corrected stamps
+type Timestamps interface {
+	Consumed(now sim.VTimeInSec, consumer string, m *msg.DemoMessage)
+}
+
+// Overflow records the latencies of the consumed messages that were
+// rerouted and of the others
+type Overflow interface {
+	Consumed(m *msg.DemoMessage, latency sim.VTimeInSec)
+}
+
+// Attribution breaks the latencies down by the decisions taken for the
+// messages
+type Attribution interface {
+	Consumed(consumer string, m *msg.DemoMessage, latency sim.VTimeInSec)
+}
+
+// SizeService records the sizes, service times, and latencies of the
+// consumed messages
+type SizeService interface {
+	Consumed(m *msg.DemoMessage, service, latency sim.VTimeInSec)
+}
+
+// Retransmitter tells the messages sent again apart from the ones consumed
+// before
+type Retransmitter interface {
+	// Duplicate reports whether the consumer consumed the message before
+	Duplicate(consumer string, id uint64) bool
+	Discarded(consumer string)
+	Consumed(consumer string, id uint64)
+}
+
+// Auditor checks that every message sent is consumed once
+type Auditor interface {
+	Delivered(consumer string, m *msg.DemoMessage)
+}
+
+// Faults takes consumers down and measures the latency of the messages
+// delayed by it
+type Faults interface {
+	// Down returns the end of the downtime of the consumer at now, and
+	// false if it is up
+	Down(now sim.VTimeInSec, consumer string) (sim.VTimeInSec, bool)
+	// Refuses reports whether a consumer that is down leaves its messages
+	// queued instead of losing them
+	Refuses() bool
+	// Lost counts a message the consumer lost while down at now
+	Lost(now sim.VTimeInSec, consumer string)
+	Consumed(now sim.VTimeInSec, consumer string, createTime sim.VTimeInSec)
+	// Downtimes returns the number of times the consumer is down
+	Downtimes(consumer string) int
+}
+
+// Pauses are the windows in which the consumer serves no message, such as
+// garbage collections
+type Pauses interface {
+	// Until returns the end of the pause at now, and false if the consumer
+	// is not paused
+	Until(now sim.VTimeInSec) (sim.VTimeInSec, bool)
+	Arrived(now sim.VTimeInSec, depth int)
+	Consumed(now, createTime sim.VTimeInSec)
+}
+
+// Stealing counts the work the consumers steal from each other
+type Stealing interface {
+	Asked(thief string)
+	Stole(thief string, n int)
+	Gave(victim string, n int)
+}
+
+// Option configures a consumer
+type Option func(c *Consumer)
+
+// WithFreq sets the frequency the consumer ticks at
+func WithFreq(freq sim.Freq) Option {
+	return func(c *Consumer) { c.Freq = freq }
+}
+
+// WithQueues gives the consumer n RX queues that hold capacity messages
+// each. Each queue is served independently at the interval of the
+// consumer.
+func WithQueues(n, capacity int) Option {
+	return func(c *Consumer) {
+		c.numQueues = n
+		c.queueCapacity = capacity
+	}
+}
+
+// WithServiceTimes draws the service time of every message around the
+// interval
+func WithServiceTimes(dist ServiceDist) Option {
+	return func(c *Consumer) { c.service = dist }
+}
+
+// WithPolling makes the consumer tick every cycle until the given time,
+// instead of being woken up by arrivals
+func WithPolling(until sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.polling = true
+		c.pollUntil = until
+	}
+}
+
+// WithInterrupts makes the consumer wake up only when it is interrupted, not
+// on every arrival
+func WithInterrupts() Option {
+	return func(c *Consumer) { c.coalesced = true }
+}
+
+// WithBatches turns the consumer into a bulk-synchronous consumer. Instead
+// of processing messages as they arrive, it waits until size messages are
+// queued and then processes the whole batch. Partial batches are processed
+// after flushAt, when no more messages are generated.
+func WithBatches(size int, flushAt sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.batchSize = size
+		c.flushAt = flushAt
+	}
+}
+
+// WithRegistry makes the consumer register with the control port of a
+// distributor at the given time
+func WithRegistry(registry sim.Port, at sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.registry = registry
+		c.registerAt = at
+	}
+}
+
+// WithSubscriptions makes the consumer subscribe to the topic patterns once
+// registered, and unsubscribe from the others at the given time, 0 never
+func WithSubscriptions(patterns, unsubscriptions []string, unsubscribeAt sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.subscriptions = patterns
+		c.unsubscriptions = unsubscriptions
+		c.unsubscribeAt = unsubscribeAt
+	}
+}
+
+// WithMemberships makes the consumer join the multicast groups at joinAt,
+// 0 once registered, and leave the others at leaveAt, 0 never
+func WithMemberships(joins []string, joinAt sim.VTimeInSec, leaves []string, leaveAt sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.joins = joins
+		c.joinAt = joinAt
+		c.leaves = leaves
+		c.leaveAt = leaveAt
+		for _, at := range []sim.VTimeInSec{joinAt, leaveAt} {
+			if at > 0 {
+				component.ScheduleWakeup(c.TickingComponent, at)
+			}
+		}
+	}
+}
+
+// WithGroupLeave makes the consumer leave its consumer group at the given
+// time
+func WithGroupLeave(at sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.leaveGroupAt = at
+		component.ScheduleWakeup(c.TickingComponent, at)
+	}
+}
+
+// WithHeartbeats makes the consumer send the distributor a heartbeat every
+// interval while it is up, until the given time
+func WithHeartbeats(interval, until sim.VTimeInSec) Option {
+	return func(c *Consumer) {
+		c.heartbeat = interval
+		c.heartbeatUntil = until
+	}
+}
+
+// WithAckPorts makes the consumer acknowledge every consumed message to its
+// producer, through the control port named after it
+func WithAckPorts(ports map[string]sim.Port) Option {
+	return func(c *Consumer) { c.ackPorts = ports }
+}
+
+// WithWindowAcks makes the consumer acknowledge every consumed or dropped
+// message to the distributor, whose window of outstanding messages it frees
+func WithWindowAcks(port sim.Port) Option {
+	return func(c *Consumer) { c.windowAcks = port }
+}
+
+// WithWorkPool makes the consumer pull its messages from a work pool once
+// its queue is empty
+func WithWorkPool(port sim.Port) Option {
+	return func(c *Consumer) { c.workPool = port }
+}
+
+// WithMiddleware passes the messages through the chain before they are
+// served
+func WithMiddleware(chain *middleware.Chain) Option {
+	return func(c *Consumer) { c.middleware = chain }
+}
+
+// WithStats sets the counters the consumer records its messages in
+func WithStats(stats Stats) Option {
+	return func(c *Consumer) { c.stats = stats }
+}
+
+// WithEvents records the fate of the messages
+func WithEvents(events Events) Option {
+	return func(c *Consumer) { c.events = events }
+}
+
+// WithErrors reports the errors to the collector instead of logging them
+func WithErrors(errors Errors) Option {
+	return func(c *Consumer) { c.errors = errors }
+}
+
+// WithQueueing measures the queue of the consumer
+func WithQueueing(queueing Queueing) Option {
+	return func(c *Consumer) { c.queueing = queueing }
+}
+
+// WithBackpressure measures the congestion of the consumer
+func WithBackpressure(backpressure Backpressure) Option {
+	return func(c *Consumer) { c.backpressure = backpressure }
+}
+
+// WithVerifier checks the order of the consumed messages
+func WithVerifier(verifier Verifier) Option {
+	return func(c *Consumer) { c.verifier = verifier }
+}
+
+// WithReorder delivers the consumed messages in order
+func WithReorder(reorder Reorder) Option {
+	return func(c *Consumer) { c.reorder = reorder }
+}
+
+// WithTimestamps records the latencies from raw and corrected stamps
+func WithTimestamps(timestamps Timestamps) Option {
+	return func(c *Consumer) { c.timestamps = timestamps }
+}
+
+// WithOverflow records the latencies of the rerouted messages
+func WithOverflow(overflow Overflow) Option {
+	return func(c *Consumer) { c.overflow = overflow }
+}
+
+// WithAttribution breaks the latencies down by the decisions taken for the
+// messages
+func WithAttribution(attribution Attribution) Option {
+	return func(c *Consumer) { c.attribution = attribution }
+}
+
+// WithSizeService records the sizes and service times of the consumed
+// messages
+func WithSizeService(sizeService SizeService) Option {
+	return func(c *Consumer) { c.sizeService = sizeService }
+}
+
+// WithRetransmitter discards the messages consumed before
+func WithRetransmitter(r Retransmitter) Option {
+	return func(c *Consumer) { c.retransmitter = r }
+}
+
+// WithAuditor reports every consumed message to the auditor
+func WithAuditor(auditor Auditor) Option {
+	return func(c *Consumer) { c.auditor = auditor }
+}
+
+// WithFaults takes the consumer down as the faults say
+func WithFaults(faults Faults) Option {
+	return func(c *Consumer) { c.faults = faults }
+}
+
+// WithPauses makes the consumer serve no message during the pauses
+func WithPauses(pauses Pauses) Option {
+	return func(c *Consumer) { c.pauses = pauses }
+}
+
+// WithLogger sets the logger of the events of the consumer
+func WithLogger(log component.Logger) Option {
+	return func(c *Consumer) { c.log = log }
+}
@@ -0,0 +1,43 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Remove starts the graceful removal of the consumer, which was routed the
+// given number of messages. The consumer serves the messages it was sent,
+// without waiting for the rest of a partial batch, and once it has drained
+// and its ACKs are out, it is detached and calls detached.
+func (c *Consumer) Remove(now sim.VTimeInSec, routed int, detached func(now sim.VTimeInSec)) {
+	c.removing = true
+	c.removeStarted = now
+	c.routed = routed
+	c.onDetach = detached
+	if c.batchSize > 0 {
+		c.flushAt = now
+	}
+
+	// The consumer checks whether it has drained on its next tick
+	c.TickLater(now)
+}
+
+// Removing reports whether the consumer is being removed
+func (c *Consumer) Removing() bool {
+	return c.removing
+}
+
+// drainedForRemoval reports whether a consumer being removed has served
+// every message it was sent and its ACKs are out
+func (c *Consumer) drainedForRemoval() bool {
+	return c.received >= c.routed && c.QueueDepth() == 0 && len(c.pendingAcks) == 0
+}
+
+// detach ends the removal of a drained consumer
+func (c *Consumer) detach(now sim.VTimeInSec) {
+	c.detached = true
+	c.registered = false
+	c.log.Printf("[%.2f] Consumer %s: Drained in %.2f seconds, detached\n", now, c.Name(), float64(now-c.removeStarted))
+	if c.onDetach != nil {
+		c.onDetach(now)
+	}
+}
@@ -0,0 +1,49 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// rxQueue is one receive queue of a consumer. Like the RX queues of a
+// multi-queue NIC, every queue has its own processing context, so queues are
+// served independently of each other.
+type rxQueue struct {
+	port         sim.Port
+	buf          sim.Buffer // Backing buffer of port, used to report queue depth
+	lastConsumed sim.VTimeInSec
+	consumed     int
+	batchLeft    int                      // Messages of the current batch still to be processed
+	intercepted  *middleware.Interception // Decision of the middlewares on the message at the head
+	drawnFor     *msg.DemoMessage         // Message at the head the service time was drawn for
+	drawnID      uint64
+	drawn        sim.VTimeInSec
+}
+
+func newRxQueue(c *Consumer, portName string, capacity int) *rxQueue {
+	q := &rxQueue{
+		lastConsumed: -1000, // Start with a large negative value
+	}
+	q.buf = sim.NewBuffer(portName+"Buf", capacity)
+	q.port = sim.NewLimitNumMsgPortWithExternalBuffer(c, q.buf, portName)
+	return q
+}
+
+// QueueDepth returns the number of messages queued in all RX queues
+func (c *Consumer) QueueDepth() int {
+	depth := 0
+	for _, q := range c.rxQueues {
+		depth += q.buf.Size()
+	}
+	return depth
+}
+
+// RxPorts returns the input ports of all the RX queues
+func (c *Consumer) RxPorts() []sim.Port {
+	ports := make([]sim.Port, len(c.rxQueues))
+	for i, q := range c.rxQueues {
+		ports[i] = q.port
+	}
+	return ports
+}
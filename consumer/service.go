@@ -0,0 +1,34 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// serviceTime returns the time the message at the head of a queue takes to
+// serve, the consumer's interval unless it is drawn or the message scales
+// it
+func (c *Consumer) serviceTime(q *rxQueue) sim.VTimeInSec {
+	m, ok := q.port.Peek().(*msg.DemoMessage)
+	if !ok {
+		return c.consumeRate
+	}
+	service := c.consumeRate
+	if c.service != nil {
+		service = c.drawService(q, m)
+	}
+	if m.ServiceScale > 0 {
+		return service * sim.VTimeInSec(m.ServiceScale)
+	}
+	return service
+}
+
+// drawService returns the service time of the message at the head of a
+// queue, drawn once when the message gets there
+func (c *Consumer) drawService(q *rxQueue, m *msg.DemoMessage) sim.VTimeInSec {
+	if q.drawnFor != m || q.drawnID != m.ID {
+		q.drawnFor, q.drawnID = m, m.ID
+		q.drawn = c.consumeRate * sim.VTimeInSec(c.service.Draw())
+	}
+	return q.drawn
+}
@@ -0,0 +1,141 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// ConnectPeers gives every consumer a steal port, through which it asks the
+// others in turn for work once its queue runs empty, starting with the next
+// one. A peer with at least threshold messages queued gives away the newer
+// half. It returns the steal ports, which are to be connected to each
+// other.
+func ConnectPeers(consumers []*Consumer, threshold int, stealing Stealing) []sim.Port {
+	ports := make([]sim.Port, len(consumers))
+	for i, c := range consumers {
+		c.stealPort = sim.NewLimitNumMsgPort(c, len(consumers), c.Name()+".Steal")
+		c.stealThreshold = threshold
+		c.stealing = stealing
+		ports[i] = c.stealPort
+	}
+	for i, c := range consumers {
+		for j := 1; j < len(consumers); j++ {
+			c.peers = append(c.peers, ports[(i+j)%len(consumers)])
+		}
+	}
+	return ports
+}
+
+// handleSteals answers the steal requests of the peers and queues the
+// messages stolen from them
+func (c *Consumer) handleSteals(now sim.VTimeInSec) {
+	for m := c.stealPort.Peek(); m != nil; m = c.stealPort.Peek() {
+		if req, ok := m.(*msg.StealReq); ok && !c.giveWork(now, req) {
+			// Connection busy, will be woken up when it becomes free
+			break
+		}
+		switch m := m.(type) {
+		case *msg.StealRsp:
+			c.stealPending = false
+			if len(m.Msgs) == 0 {
+				c.refusals++
+			} else {
+				c.refusals = 0
+				if c.stealing != nil {
+					c.stealing.Stole(c.Name(), len(m.Msgs))
+				}
+				c.stolen = append(c.stolen, m.Msgs...)
+			}
+		}
+		c.stealPort.Retrieve(now)
+	}
+	c.admitStolen()
+}
+
+// giveWork answers a steal request with the newer half of the queue if it
+// holds at least the threshold, and with nothing otherwise. It reports
+// whether the answer was sent.
+func (c *Consumer) giveWork(now sim.VTimeInSec, req *msg.StealReq) bool {
+	q := c.rxQueues[0]
+	queued := q.buf.Size()
+	n := 0
+	if queued >= c.stealThreshold {
+		n = queued / 2
+	}
+	if n > req.Room {
+		n = req.Room
+	}
+
+	// Take the newest messages off the tail of the queue
+	kept := make([]interface{}, 0, queued)
+	for q.buf.Size() > 0 {
+		kept = append(kept, q.buf.Pop())
+	}
+	rsp := &msg.StealRsp{Victim: c.Name()}
+	for _, m := range kept[queued-n:] {
+		rsp.Msgs = append(rsp.Msgs, m.(*msg.DemoMessage))
+	}
+	rsp.Meta().Src = c.stealPort
+	rsp.Meta().Dst = req.Meta().Src
+	rsp.Meta().SendTime = now
+	err := c.stealPort.Send(rsp)
+	if err != nil {
+		n = 0
+	}
+	for _, m := range kept[:queued-n] {
+		q.buf.Push(m)
+	}
+	if err != nil {
+		return false
+	}
+
+	if n == 0 {
+		c.log.Printf("[%.2f] Consumer %s: Refused %s (queue: %d)\n", now, c.Name(), req.Thief, q.buf.Size())
+		return true
+	}
+	if c.stealing != nil {
+		c.stealing.Gave(c.Name(), n)
+	}
+	if c.events != nil {
+		for _, m := range rsp.Msgs {
+			c.events.Decide(now, c.Name(), m.ID, "stolen by %s", req.Thief)
+		}
+	}
+	c.log.Printf("[%.2f] Consumer %s: Gave %d messages to %s (queue: %d)\n", now, c.Name(), n, req.Thief, q.buf.Size())
+	return true
+}
+
+// admitStolen moves the stolen messages into the RX queue as far as it has
+// room. Messages that arrived since the request may have taken the room.
+func (c *Consumer) admitStolen() {
+	q := c.rxQueues[0]
+	for len(c.stolen) > 0 && q.buf.CanPush() {
+		q.buf.Push(c.stolen[0])
+		c.stolen = c.stolen[1:]
+	}
+}
+
+// steal asks the next peer for work once the consumer's queue is empty,
+// unless a request is on its way or all the peers refused since the last
+// message arrived
+func (c *Consumer) steal(now sim.VTimeInSec) {
+	if c.stealPending || c.QueueDepth() > 0 || len(c.stolen) > 0 || c.refusals >= len(c.peers) {
+		return
+	}
+	q := c.rxQueues[0]
+	peer := c.peers[c.nextPeer]
+	req := &msg.StealReq{Thief: c.Name(), Room: q.buf.Capacity() - q.buf.Size()}
+	req.Meta().Src = c.stealPort
+	req.Meta().Dst = peer
+	req.Meta().SendTime = now
+	if err := c.stealPort.Send(req); err != nil {
+		// Connection busy, will be woken up when it becomes free
+		return
+	}
+	c.nextPeer = (c.nextPeer + 1) % len(c.peers)
+	c.stealPending = true
+	if c.stealing != nil {
+		c.stealing.Asked(c.Name())
+	}
+	c.log.Printf("[%.2f] Consumer %s: Asked %s for work\n", now, c.Name(), peer.Component().Name())
+}
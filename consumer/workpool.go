@@ -0,0 +1,23 @@
+package consumer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// pull asks the work pool for the next message once the consumer's queue is
+// empty, unless it is waiting for one already
+func (c *Consumer) pull(now sim.VTimeInSec) {
+	if c.pulling || c.QueueDepth() > 0 || c.removing {
+		return
+	}
+	m := &msg.PullMsg{Consumer: c.Name()}
+	m.Meta().Src = c.inputPort
+	m.Meta().Dst = c.workPool
+	m.Meta().SendTime = now
+	if err := c.inputPort.Send(m); err != nil {
+		// Connection busy, will be woken up when it becomes free
+		return
+	}
+	c.pulling = true
+}
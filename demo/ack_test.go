@@ -0,0 +1,38 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// TestConsumerAcksConsumedMessages verifies that a consumer acknowledges
+// every consumed message to the producer
+func TestConsumerAcksConsumedMessages(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	ackPorts := make(map[string]sim.Port)
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithAckPorts(ackPorts))
+	p := producer.New("Producer", engine, 1,
+		producer.WithConsumers([]string{"Consumer1"}),
+		producer.WithTraffic(&producer.RandomTraffic{Probability: 1}),
+		producer.WithDestination(c.InputPort()),
+		producer.WithStats(stats))
+	ackPorts[p.Name()] = p.CtrlPort()
+	
+	dataConn := sim.NewDirectConnection("ProducerToConsumer", engine, 1*sim.Hz)
+	dataConn.PlugIn(p.OutputPort(), 1)
+	dataConn.PlugIn(c.InputPort(), 1)
+	ctrlConn := sim.NewDirectConnection("ControlPlane", engine, 1*sim.Hz)
+	ctrlConn.PlugIn(p.CtrlPort(), 1)
+	ctrlConn.PlugIn(c.CtrlPort(), 1)
+	
+	p.TickNow(0)
+	engine.Run()
+	
+	if p.Unacked() != 0 || stats.Acked != 1 {
+		t.Errorf("Expected the message to be acknowledged, got %d ACKs", stats.Acked)
+	}
+}
@@ -0,0 +1,413 @@
+package demo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+)
+
+// arbiterKinds are the policies that grant a link to the ports waiting to
+// transmit:
+//
+//	round-robin  the ports take turns in their order on the link
+//	wfq          weighted fair queueing, the message that would finish
+//	             first if every port got its weighted share of the link
+var arbiterKinds = []string{"round-robin", "wfq"}
+
+// Arbiter picks the port a link transmits from next
+type Arbiter interface {
+	// Pick returns the index of the port granted the link among the ports
+	// in their order on the link, -1 if none has a message waiting. head
+	// returns the message waiting at a port, nil if none, and cost the
+	// units of the link a message takes.
+	Pick(ports []sim.Port, head func(sim.Port) sim.Msg, cost func(sim.Msg) float64) int
+	// Unplugged forgets the port at index i, which left the link
+	Unplugged(i int)
+}
+
+// RoundRobinArbiter grants the link to the ports in turn. Its contract: a
+// port with a message waiting is passed over by at most one grant to every
+// other port.
+type RoundRobinArbiter struct {
+	next int // Port to grant first
+}
+
+// Pick grants the next port in turn that has a message waiting
+func (a *RoundRobinArbiter) Pick(ports []sim.Port, head func(sim.Port) sim.Msg, _ func(sim.Msg) float64) int {
+	for i := range ports {
+		index := (a.next + i) % len(ports)
+		if head(ports[index]) != nil {
+			a.next = index + 1
+			return index
+		}
+	}
+	return -1
+}
+
+// Unplugged keeps the turn of the ports after the one that left
+func (a *RoundRobinArbiter) Unplugged(i int) {
+	if a.next > i {
+		a.next--
+	}
+}
+
+// WFQArbiter grants the link by self-clocked weighted fair queueing. Every
+// message at the head of a port is stamped with a virtual finish time, its
+// cost divided by the weight of the port after the later of the previous
+// finish time of the port and the finish time of the message in service,
+// and the smallest stamp wins. Its contract: two ports with messages
+// waiting all along receive service in proportion to their weights, up to
+// one largest message of each.
+type WFQArbiter struct {
+	Weights map[string]float64 // Weight of every port by name, 1 if not given
+
+	virtual float64              // Finish time of the message in service
+	finish  map[sim.Port]float64 // Finish time of the last stamped message of every port
+	stamps  map[sim.Msg]float64  // Finish times of the messages at the heads
+}
+
+// NewWFQArbiter creates a weighted fair queueing arbiter
+func NewWFQArbiter(weights map[string]float64) *WFQArbiter {
+	return &WFQArbiter{
+		Weights: weights,
+		finish:  make(map[sim.Port]float64),
+		stamps:  make(map[sim.Msg]float64),
+	}
+}
+
+// Weight returns the weight of a port
+func (a *WFQArbiter) Weight(port sim.Port) float64 {
+	if w, ok := a.Weights[port.Name()]; ok {
+		return w
+	}
+	return 1
+}
+
+// Pick grants the port whose waiting message has the smallest finish time,
+// the first port on the link if several tie
+func (a *WFQArbiter) Pick(ports []sim.Port, head func(sim.Port) sim.Msg, cost func(sim.Msg) float64) int {
+	picked := -1
+	var smallest float64
+	for i, port := range ports {
+		msg := head(port)
+		if msg == nil {
+			continue
+		}
+		stamp, ok := a.stamps[msg]
+		if !ok {
+			// Messages without a size count as one unit
+			stamp = math.Max(a.virtual, a.finish[port]) + math.Max(cost(msg), 1)/a.Weight(port)
+			a.stamps[msg] = stamp
+			a.finish[port] = stamp
+		}
+		if picked < 0 || stamp < smallest {
+			picked, smallest = i, stamp
+		}
+	}
+	if picked >= 0 {
+		a.virtual = smallest
+		delete(a.stamps, head(ports[picked]))
+	}
+	return picked
+}
+
+// Unplugged does nothing, the finish times are kept by port
+func (a *WFQArbiter) Unplugged(int) {}
+
+// NewArbiter creates an arbiter of a kind, with the weights of the ports
+// for weighted fair queueing
+func NewArbiter(kind string, weights map[string]float64) Arbiter {
+	if kind == "wfq" {
+		return NewWFQArbiter(weights)
+	}
+	return &RoundRobinArbiter{}
+}
+
+// Grant is the link granted to a port, and the ports that were waiting
+type Grant struct {
+	Time    sim.VTimeInSec
+	Link    string
+	Port    string
+	Cost    float64  // Units of the link the message takes
+	Waiting []string // Ports with a message waiting, the granted one included
+}
+
+// FairnessViolation is a grant that broke the contract of the arbiter of a
+// link, with the grants of the link that led to it
+type FairnessViolation struct {
+	Time  sim.VTimeInSec
+	Link  string
+	Port  string // Port treated unfairly
+	What  string
+	Trace []Grant
+}
+
+// auditTraceLength is the number of grants of a link kept as the trace of
+// a violation
+const auditTraceLength = 8
+
+// ArbitrationAudit records the grants of the links and checks them against
+// the contracts of their arbiters: the bound on how long round-robin keeps
+// a waiting port waiting, and the share of the link weighted fair queueing
+// gives to ports that keep messages waiting. Its methods are safe to call
+// on a nil audit.
+type ArbitrationAudit struct {
+	Grants     []Grant
+	Violations []FairnessViolation
+	links      map[string]*linkAudit
+}
+
+// linkAudit is the state of the contract of a link
+type linkAudit struct {
+	recent   []Grant
+	passed   map[string]map[string]bool // Ports granted since a waiting port began waiting
+	service  map[string]float64         // Weighted service of every port
+	baseline map[[2]string][2]float64   // Weighted services of a pair of ports when both began waiting
+	largest  map[string]float64         // Cost of the largest message of every port
+	grants   map[string]int             // Grants to every port
+}
+
+// NewArbitrationAudit creates an audit that has seen no grants
+func NewArbitrationAudit() *ArbitrationAudit {
+	return &ArbitrationAudit{links: make(map[string]*linkAudit)}
+}
+
+// Granted records the grant of a link to the port at index granted, before
+// its message leaves the send buffer, and checks the contract of the
+// arbiter
+func (a *ArbitrationAudit) Granted(now sim.VTimeInSec, l *Link, granted int, cost float64) {
+	if a == nil {
+		return
+	}
+	la := a.links[l.Name()]
+	if la == nil {
+		la = &linkAudit{
+			passed:   make(map[string]map[string]bool),
+			service:  make(map[string]float64),
+			baseline: make(map[[2]string][2]float64),
+			largest:  make(map[string]float64),
+			grants:   make(map[string]int),
+		}
+		a.links[l.Name()] = la
+	}
+
+	grant := Grant{Time: now, Link: l.Name(), Port: l.ports[granted].Name(), Cost: cost}
+	for _, port := range l.ports {
+		if len(l.ends[port].buf) > 0 {
+			grant.Waiting = append(grant.Waiting, port.Name())
+		}
+	}
+	a.Grants = append(a.Grants, grant)
+	la.recent = append(la.recent, grant)
+	if len(la.recent) > auditTraceLength {
+		la.recent = la.recent[1:]
+	}
+	la.grants[grant.Port]++
+
+	switch arbiter := l.arbiter.(type) {
+	case *RoundRobinArbiter:
+		a.checkRoundRobin(la, grant)
+	case *WFQArbiter:
+		a.checkWFQ(la, grant, arbiter, l)
+	}
+}
+
+// checkRoundRobin flags a waiting port passed over by a second grant to
+// another port
+func (a *ArbitrationAudit) checkRoundRobin(la *linkAudit, grant Grant) {
+	waiting := make(map[string]bool)
+	for _, port := range grant.Waiting {
+		waiting[port] = true
+		if port == grant.Port {
+			delete(la.passed, port)
+			continue
+		}
+		if la.passed[port] == nil {
+			la.passed[port] = make(map[string]bool)
+		}
+		if la.passed[port][grant.Port] {
+			a.violate(la, grant, port, fmt.Sprintf("passed over by a second grant to %s, round-robin allows one", grant.Port))
+		}
+		la.passed[port][grant.Port] = true
+	}
+	for port := range la.passed {
+		if !waiting[port] {
+			delete(la.passed, port)
+		}
+	}
+}
+
+// checkWFQ flags two ports that kept messages waiting and whose weighted
+// services drifted apart by more than one largest message of each
+func (a *ArbitrationAudit) checkWFQ(la *linkAudit, grant Grant, arbiter *WFQArbiter, l *Link) {
+	weights := make(map[string]float64)
+	for _, port := range l.ports {
+		weights[port.Name()] = arbiter.Weight(port)
+	}
+	cost := math.Max(grant.Cost, 1)
+	la.largest[grant.Port] = math.Max(la.largest[grant.Port], cost)
+
+	waiting := make(map[[2]string]bool)
+	for i, p := range grant.Waiting {
+		for _, q := range grant.Waiting[i+1:] {
+			pair := [2]string{p, q}
+			waiting[pair] = true
+			if _, ok := la.baseline[pair]; !ok {
+				la.baseline[pair] = [2]float64{la.service[p], la.service[q]}
+			}
+		}
+	}
+	for pair := range la.baseline {
+		if !waiting[pair] {
+			// One of the ports ran out of messages, the interval ends
+			delete(la.baseline, pair)
+		}
+	}
+
+	la.service[grant.Port] += cost / weights[grant.Port]
+	for _, pair := range sortedPairs(la.baseline) {
+		p, q := pair[0], pair[1]
+		base := la.baseline[pair]
+		drift := (la.service[p] - base[0]) - (la.service[q] - base[1])
+		bound := largestOr1(la.largest[p])/weights[p] + largestOr1(la.largest[q])/weights[q]
+		if math.Abs(drift) > bound+component.ClockTolerance {
+			ahead, behind := p, q
+			if drift < 0 {
+				ahead, behind = q, p
+			}
+			a.violate(la, grant, behind, fmt.Sprintf("fell %.2f weighted units behind %s, WFQ allows %.2f",
+				math.Abs(drift), ahead, bound))
+			// Start over, so that a lasting imbalance is flagged once per bound
+			la.baseline[pair] = [2]float64{la.service[p], la.service[q]}
+		}
+	}
+}
+
+func largestOr1(cost float64) float64 {
+	return math.Max(cost, 1)
+}
+
+// sortedPairs returns the pairs of ports in a fixed order
+func sortedPairs(pairs map[[2]string][2]float64) [][2]string {
+	keys := make([][2]string, 0, len(pairs))
+	for pair := range pairs {
+		keys = append(keys, pair)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+func (a *ArbitrationAudit) violate(la *linkAudit, grant Grant, port, what string) {
+	a.Violations = append(a.Violations, FairnessViolation{
+		Time:  grant.Time,
+		Link:  grant.Link,
+		Port:  port,
+		What:  what,
+		Trace: append([]Grant(nil), la.recent...),
+	})
+}
+
+// maxPrintedViolations is the number of violations printed with their traces
+const maxPrintedViolations = 3
+
+// Print writes the grants of every sending port of the audited links and the
+// violations of the contracts, the first ones with the grants that led to
+// them
+func (a *ArbitrationAudit) Print(out EventSink, links []*Link) {
+	out.Println("=== Arbitration Audit ===")
+	out.Printf("%-28s %-28s %7s %11s\n", "Link", "Port", "Grants", "Violations")
+	violations := make(map[string]int)
+	for _, v := range a.Violations {
+		violations[v.Link+" "+v.Port]++
+	}
+	for _, l := range links {
+		la := a.links[l.Name()]
+		if la == nil {
+			continue
+		}
+		for _, port := range l.ports {
+			if la.grants[port.Name()] == 0 && violations[l.Name()+" "+port.Name()] == 0 {
+				// Ports that only receive are never granted the link
+				continue
+			}
+			out.Printf("%-28s %-28s %7d %11d\n", l.Name(), port.Name(), la.grants[port.Name()],
+				violations[l.Name()+" "+port.Name()])
+		}
+	}
+	out.Printf("Grants:            %d\n", len(a.Grants))
+	out.Printf("Violations:        %d\n", len(a.Violations))
+	for i, v := range a.Violations {
+		if i == maxPrintedViolations {
+			out.Printf("... %d more\n", len(a.Violations)-maxPrintedViolations)
+			break
+		}
+		out.Printf("[%.2f] %s: %s %s\n", float64(v.Time), v.Link, v.Port, v.What)
+		for _, g := range v.Trace {
+			out.Printf("  [%.2f] granted %s, waiting %s\n", float64(g.Time), g.Port, strings.Join(g.Waiting, " "))
+		}
+	}
+}
+
+// WriteCSV writes every grant with the ports that were waiting
+func (a *ArbitrationAudit) WriteCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"time", "link", "port", "cost", "waiting"}); err != nil {
+		return err
+	}
+	for _, g := range a.Grants {
+		record := []string{
+			fmt.Sprintf("%.6f", float64(g.Time)),
+			g.Link,
+			g.Port,
+			fmt.Sprintf("%g", g.Cost),
+			strings.Join(g.Waiting, " "),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// validateArbitration checks the arbiter and its weights, and that there are
+// links to arbitrate
+func (c *Config) validateArbitration() error {
+	known := false
+	for _, kind := range arbiterKinds {
+		known = known || c.Arbiter == kind
+	}
+	if !known {
+		return fmt.Errorf("unknown arbiter %q, must be one of %v", c.Arbiter, arbiterKinds)
+	}
+	for name, weight := range c.ArbiterWeights {
+		if weight <= 0 {
+			return fmt.Errorf("arbiter weight of %s must be positive", name)
+		}
+	}
+	if len(c.ArbiterWeights) > 0 && c.Arbiter != "wfq" {
+		return fmt.Errorf("arbiter-weights need the wfq arbiter")
+	}
+	links := c.LinkLatency > 0 || c.LinkBandwidth > 0 || len(c.LinkLatencies) > 0 || len(c.LinkBandwidths) > 0
+	if !links && (c.Arbiter != "round-robin" || c.ArbitrationAudit || c.GrantTraceFile != "") {
+		return fmt.Errorf("arbiter, arbitration-audit, and grant-trace need links, a link-latency or a link-bandwidth")
+	}
+	return nil
+}
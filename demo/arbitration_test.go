@@ -0,0 +1,161 @@
+package demo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// pickAll lets an arbiter pick n times among ports that always have a
+// message waiting and counts the grants of every port
+func pickAll(a Arbiter, ports []sim.Port, n int) map[string]int {
+	waiting := make(map[sim.Port]sim.Msg)
+	for _, port := range ports {
+		waiting[port] = &msg.DemoMessage{}
+	}
+	head := func(port sim.Port) sim.Msg { return waiting[port] }
+	cost := func(sim.Msg) float64 { return 1 }
+	grants := make(map[string]int)
+	for i := 0; i < n; i++ {
+		picked := ports[a.Pick(ports, head, cost)]
+		grants[picked.Name()]++
+		waiting[picked] = &msg.DemoMessage{}
+	}
+	return grants
+}
+
+// TestRoundRobinArbiterTakesTurns verifies that the round-robin arbiter
+// grants the waiting ports in turn and skips the ones with nothing waiting
+func TestRoundRobinArbiterTakesTurns(t *testing.T) {
+	a, b, c := newLinkEndpoint("A"), newLinkEndpoint("B"), newLinkEndpoint("C")
+	ports := []sim.Port{a.port, b.port, c.port}
+	waiting := map[sim.Port]sim.Msg{a.port: &msg.DemoMessage{}, c.port: &msg.DemoMessage{}}
+	head := func(port sim.Port) sim.Msg { return waiting[port] }
+	
+	arbiter := &RoundRobinArbiter{}
+	var order []int
+	for i := 0; i < 4; i++ {
+		order = append(order, arbiter.Pick(ports, head, nil))
+	}
+	if fmt.Sprint(order) != "[0 2 0 2]" {
+		t.Errorf("Expected the grants [0 2 0 2], got %v", order)
+	}
+	
+	waiting = map[sim.Port]sim.Msg{}
+	if picked := arbiter.Pick(ports, head, nil); picked != -1 {
+		t.Errorf("Expected no grant without waiting messages, got %d", picked)
+	}
+}
+
+// TestWFQArbiterSharesByWeight verifies that weighted fair queueing grants
+// ports that keep messages waiting in proportion to their weights
+func TestWFQArbiterSharesByWeight(t *testing.T) {
+	a, b, c := newLinkEndpoint("A"), newLinkEndpoint("B"), newLinkEndpoint("C")
+	ports := []sim.Port{a.port, b.port, c.port}
+	
+	grants := pickAll(NewWFQArbiter(map[string]float64{"A.Port": 3}), ports, 50)
+	if grants["A.Port"] != 30 || grants["B.Port"] != 10 || grants["C.Port"] != 10 {
+		t.Errorf("Expected 30, 10, and 10 grants, got %v", grants)
+	}
+	
+	grants = pickAll(NewWFQArbiter(nil), ports, 30)
+	if grants["A.Port"] != 10 || grants["B.Port"] != 10 || grants["C.Port"] != 10 {
+		t.Errorf("Expected equal grants without weights, got %v", grants)
+	}
+}
+
+// auditedLink plugs the ports of endpoints into a link with the given
+// arbiter and queues a message at each of them
+func auditedLink(arbiter Arbiter, endpoints ...*linkEndpoint) *Link {
+	link := NewLink("Link", sim.NewSerialEngine(), 1*sim.Hz, LinkSpec{Bandwidth: 1})
+	link.arbiter = arbiter
+	for _, e := range endpoints {
+		link.PlugIn(e.port, 4)
+		link.ends[e.port].buf = append(link.ends[e.port].buf, &msg.DemoMessage{})
+	}
+	return link
+}
+
+// TestAuditFlagsRoundRobinViolations feeds the audit the grants of a broken
+// round-robin arbiter that favors one port, and verifies that the waiting
+// port is flagged with the grants that led to it
+func TestAuditFlagsRoundRobinViolations(t *testing.T) {
+	a, b := newLinkEndpoint("A"), newLinkEndpoint("B")
+	link := auditedLink(&RoundRobinArbiter{}, a, b)
+	audit := NewArbitrationAudit()
+	
+	for i := 0; i < 3; i++ {
+		audit.Granted(sim.VTimeInSec(i), link, 0, 1)
+	}
+	if len(audit.Grants) != 3 {
+		t.Fatalf("Expected 3 grants, got %d", len(audit.Grants))
+	}
+	if len(audit.Violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %+v", audit.Violations)
+	}
+	v := audit.Violations[0]
+	if v.Time != 1 || v.Port != "B.Port" || len(v.Trace) != 2 {
+		t.Errorf("Expected B.Port to be flagged at 1 with 2 grants, got %+v", v)
+	}
+	
+	audit.Granted(3, link, 1, 1)
+	audit.Granted(4, link, 0, 1)
+	if len(audit.Violations) != 2 {
+		t.Errorf("Expected no violations once the ports alternate, got %d", len(audit.Violations))
+	}
+}
+
+// TestAuditFlagsWFQViolations verifies that the audit flags a port whose
+// weighted service falls behind another waiting port by more than one
+// largest message of each
+func TestAuditFlagsWFQViolations(t *testing.T) {
+	a, b := newLinkEndpoint("A"), newLinkEndpoint("B")
+	link := auditedLink(NewWFQArbiter(map[string]float64{"A.Port": 2}), a, b)
+	audit := NewArbitrationAudit()
+	
+	// A weighs twice as much, two grants to A per grant to B are fair
+	for i, port := range []int{0, 0, 1, 0, 0, 1} {
+		audit.Granted(sim.VTimeInSec(i), link, port, 1)
+	}
+	if len(audit.Violations) != 0 {
+		t.Fatalf("Expected no violations, got %+v", audit.Violations)
+	}
+	
+	for i := 6; i < 12; i++ {
+		audit.Granted(sim.VTimeInSec(i), link, 0, 1)
+	}
+	if len(audit.Violations) == 0 || audit.Violations[0].Port != "B.Port" {
+		t.Errorf("Expected B.Port to be flagged, got %+v", audit.Violations)
+	}
+}
+
+// TestLinkArbitrationIsFair verifies that senders contending for a link
+// whose transmissions take longer than a cycle take turns, which requires
+// the link to arbitrate only when it is free to transmit
+func TestLinkArbitrationIsFair(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	a, b, c, dst := newLinkEndpoint("A"), newLinkEndpoint("B"), newLinkEndpoint("C"), newLinkEndpoint("Dst")
+	link := NewLink("Link", engine, 1*sim.Hz, LinkSpec{Bandwidth: 0.7})
+	link.audit = NewArbitrationAudit()
+	for _, e := range []*linkEndpoint{a, b, c, dst} {
+		link.PlugIn(e.port, 4)
+	}
+	
+	for i := 0; i < 4; i++ {
+		for _, e := range []*linkEndpoint{a, b, c} {
+			sendOver(t, e, dst, 0)
+		}
+	}
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(dst.arrivals) != 12 {
+		t.Fatalf("Expected 12 arrivals, got %d", len(dst.arrivals))
+	}
+	if len(link.audit.Violations) != 0 {
+		t.Errorf("Expected no violations, got %+v", link.audit.Violations[0])
+	}
+}
@@ -0,0 +1,127 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// attributionDimensions are the kinds of decisions the latency is broken
+// down by, in the order they are reported
+var attributionDimensions = []string{"route", "consumer", "priority", "middleware", "retransmission"}
+
+// Decision is what was decided for a message in one dimension
+type Decision struct {
+	Dimension string
+	Choice    string
+}
+
+func (d Decision) String() string {
+	return d.Dimension + "=" + d.Choice
+}
+
+// messageDecisions returns what was decided for a message on its way to the
+// consumer that serves it: how it was routed, by whom it is served, its
+// priority, whether a middleware copied or held it, and whether the producer
+// sent it again
+func messageDecisions(m *msg.DemoMessage, consumer string) []Decision {
+	route := "direct"
+	switch {
+	case m.Group != "":
+		route = "multicast"
+	case m.ConsumerGroup != "":
+		route = "consumer group"
+	case m.RuleFrom != "":
+		route = "rule"
+	case m.RedirectedFrom != "":
+		route = "redirected"
+	case m.FailoverFrom != "":
+		route = "failover"
+	case m.OverflowFrom != "":
+		route = "overflow"
+	}
+	middleware := "passed"
+	if m.Duplicate {
+		middleware = "copy"
+	} else if m.Held > 0 {
+		middleware = "held"
+	}
+	retransmission := "original"
+	if m.Retransmits > 0 {
+		retransmission = "retransmitted"
+	}
+	return []Decision{
+		{"route", route},
+		{"consumer", consumer},
+		{"priority", fmt.Sprintf("P%d", m.Priority)},
+		{"middleware", middleware},
+		{"retransmission", retransmission},
+	}
+}
+
+// LatencyAttribution breaks the end-to-end latencies of the consumed
+// messages down by the decisions taken for them, so that one run tells
+// whether a policy paid off for the messages it applied to. Its methods are
+// safe to call on a nil attribution.
+type LatencyAttribution struct {
+	mu        sync.Mutex // Consumers record from the goroutines of the parallel engine
+	latencies map[Decision][]float64
+}
+
+// NewLatencyAttribution creates an empty attribution
+func NewLatencyAttribution() *LatencyAttribution {
+	return &LatencyAttribution{latencies: make(map[Decision][]float64)}
+}
+
+// Consumed records the latency of a message under every decision taken for
+// it
+func (a *LatencyAttribution) Consumed(consumer string, m *msg.DemoMessage, latency sim.VTimeInSec) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, d := range messageDecisions(m, consumer) {
+		a.latencies[d] = append(a.latencies[d], float64(latency))
+	}
+}
+
+// Latencies returns the latencies of the messages a decision was taken for
+func (a *LatencyAttribution) Latencies(d Decision) []float64 {
+	return a.latencies[d]
+}
+
+// Choices returns the choices made in a dimension, sorted
+func (a *LatencyAttribution) Choices(dimension string) []string {
+	var choices []string
+	for d := range a.latencies {
+		if d.Dimension == dimension {
+			choices = append(choices, d.Choice)
+		}
+	}
+	sort.Strings(choices)
+	return choices
+}
+
+// Print writes the latency percentiles of the messages of every decision.
+// Dimensions in which every message got the same decision tell nothing and
+// are left out.
+func (a *LatencyAttribution) Print(out EventSink) {
+	out.Println("=== Latency Attribution ===")
+	out.Printf("%-30s %9s %9s %9s %9s\n", "Decision", "Messages", "Mean", "p50", "p99")
+	for _, dimension := range attributionDimensions {
+		choices := a.Choices(dimension)
+		if len(choices) < 2 {
+			continue
+		}
+		for _, choice := range choices {
+			d := Decision{dimension, choice}
+			latencies := a.latencies[d]
+			out.Printf("%-30s %9d %7.2f s %7.2f s %7.2f s\n", d, len(latencies),
+				mean(latencies), percentile(latencies, 50), percentile(latencies, 99))
+		}
+	}
+}
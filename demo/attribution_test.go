@@ -0,0 +1,85 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestMessageDecisions verifies what is reported as decided for a message in
+// every dimension
+func TestMessageDecisions(t *testing.T) {
+	for _, c := range []struct {
+		msg  msg.DemoMessage
+		want [5]string
+	}{
+		{msg.DemoMessage{Destination: "Consumer1"}, [5]string{"direct", "Consumer1", "P0", "passed", "original"}},
+		{msg.DemoMessage{FailoverFrom: "Consumer2", Priority: 2}, [5]string{"failover", "Consumer1", "P2", "passed", "original"}},
+		{msg.DemoMessage{OverflowFrom: "Consumer2", Held: 3}, [5]string{"overflow", "Consumer1", "P0", "held", "original"}},
+		{msg.DemoMessage{RuleFrom: "Consumer2", Duplicate: true, Held: 3}, [5]string{"rule", "Consumer1", "P0", "copy", "original"}},
+		{msg.DemoMessage{Retransmits: 2}, [5]string{"direct", "Consumer1", "P0", "passed", "retransmitted"}},
+	} {
+		decisions := messageDecisions(&c.msg, "Consumer1")
+		for i, d := range decisions {
+			if d.Dimension != attributionDimensions[i] || d.Choice != c.want[i] {
+				t.Errorf("Expected %s=%s for %+v, got %s", attributionDimensions[i], c.want[i], c.msg, d)
+			}
+		}
+	}
+}
+
+// TestLatencyAttribution verifies that every consumed message is counted once
+// per dimension, under the decisions actually taken for it
+func TestLatencyAttribution(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 200
+	cfg.FaultSchedule = writeFaults(t, "30,60,down,Consumer2\n")
+	cfg.HealthInterval = 2
+	cfg.PriorityLevels = 2
+	cfg.Attribution = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	produced := 0
+	simulation.Use(middleware.Funcs{
+		Produce: func(i *middleware.Interception) {
+			produced++
+			if produced%4 == 0 {
+				i.Copies = 1
+			} else if produced%4 == 1 {
+				i.Delay = 5
+			}
+		},
+	})
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	a := simulation.attribution
+	for _, dimension := range attributionDimensions {
+		total := 0
+		for _, choice := range a.Choices(dimension) {
+			total += len(a.Latencies(Decision{dimension, choice}))
+		}
+		if total != simulation.stats.Consumed {
+			t.Errorf("Expected all %d consumed messages under %s, got %d", simulation.stats.Consumed, dimension, total)
+		}
+	}
+	if n := len(a.Latencies(Decision{"route", "failover"})); n == 0 || n != simulation.routing.health.TotalRerouted() {
+		t.Errorf("Expected the failed-over messages under route=failover, got %d", n)
+	}
+	if n := len(a.Latencies(Decision{"middleware", "copy"})); n != simulation.middleware.TotalCopies() {
+		t.Errorf("Expected the %d copies under middleware=copy, got %d", simulation.middleware.TotalCopies(), n)
+	}
+	held, passed := a.Latencies(Decision{"middleware", "held"}), a.Latencies(Decision{"middleware", "passed"})
+	if len(held) == 0 || mean(held) < mean(passed)+5 {
+		t.Errorf("Expected the held messages to be 5 s later, got %.2f and %.2f s", mean(held), mean(passed))
+	}
+}
@@ -0,0 +1,168 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// deliveryKey is what must be consumed exactly once: a message, or the copy
+// of a multicast message for one member of its group
+type deliveryKey struct {
+	ID       uint64
+	Consumer string // Member the copy is for, empty for a message addressed to one consumer
+}
+
+// DeliveryAuditor checks that every message the producers sent is consumed
+// exactly once, counting the copies of a multicast message once per member
+// of its group. The producers tell it the ID of every message they send for
+// the first time and the consumers the ID of every message they consume.
+// Unlike the conservation check, which balances the messages in the network,
+// it follows every ID, so a duplicate cannot make up for a loss. Its methods
+// are safe to call on a nil auditor.
+type DeliveryAuditor struct {
+	mu        sync.Mutex // Producers and consumers run on the goroutines of the parallel engine
+	produced  map[uint64]bool
+	delivered map[deliveryKey]int
+	unknown   []uint64 // Consumed IDs no producer sent
+}
+
+// NewDeliveryAuditor creates an auditor that has seen no messages
+func NewDeliveryAuditor() *DeliveryAuditor {
+	return &DeliveryAuditor{
+		produced:  make(map[uint64]bool),
+		delivered: make(map[deliveryKey]int),
+	}
+}
+
+// Produced records a message a producer sent for the first time
+func (a *DeliveryAuditor) Produced(m *msg.DemoMessage) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.produced[m.ID] = true
+}
+
+// Delivered records a message a consumer consumed
+func (a *DeliveryAuditor) Delivered(consumer string, m *msg.DemoMessage) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.produced[m.ID] {
+		a.unknown = append(a.unknown, m.ID)
+		return
+	}
+	key := deliveryKey{ID: m.ID}
+	if m.Group != "" {
+		key.Consumer = consumer
+	}
+	a.delivered[key]++
+}
+
+// DeliveryAudit is the end-of-run result of the auditor
+type DeliveryAudit struct {
+	Produced    int
+	ExactlyOnce int            // Messages and multicast copies consumed once
+	Duplicated  map[uint64]int // Times the messages consumed more than once were consumed, by ID
+	Undelivered []uint64       // IDs of the messages never consumed
+	Unknown     []uint64       // Consumed IDs no producer sent
+}
+
+// Audit checks every message sent so far
+func (a *DeliveryAuditor) Audit() DeliveryAudit {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	audit := DeliveryAudit{
+		Produced:   len(a.produced),
+		Duplicated: make(map[uint64]int),
+		Unknown:    append([]uint64(nil), a.unknown...),
+	}
+	consumed := make(map[uint64]bool)
+	for key, n := range a.delivered {
+		consumed[key.ID] = true
+		if n == 1 {
+			audit.ExactlyOnce++
+		} else if n > audit.Duplicated[key.ID] {
+			audit.Duplicated[key.ID] = n
+		}
+	}
+	for id := range a.produced {
+		if !consumed[id] {
+			audit.Undelivered = append(audit.Undelivered, id)
+		}
+	}
+	sort.Slice(audit.Undelivered, func(i, j int) bool { return audit.Undelivered[i] < audit.Undelivered[j] })
+	sort.Slice(audit.Unknown, func(i, j int) bool { return audit.Unknown[i] < audit.Unknown[j] })
+	return audit
+}
+
+// Holds reports whether every message was consumed once and nothing
+// else was
+func (a DeliveryAudit) Holds() bool {
+	return len(a.Duplicated) == 0 && len(a.Undelivered) == 0 && len(a.Unknown) == 0
+}
+
+// maxAuditIDs is the number of IDs listed per finding
+const maxAuditIDs = 10
+
+// formatIDs lists the first IDs
+func formatIDs(ids []uint64) string {
+	var b strings.Builder
+	for i, id := range ids {
+		if i == maxAuditIDs {
+			fmt.Fprintf(&b, " and %d more", len(ids)-maxAuditIDs)
+			break
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "#%d", id)
+	}
+	return b.String()
+}
+
+// Print writes the audit and whether every message was delivered exactly
+// once
+func (a DeliveryAudit) Print(out EventSink) {
+	out.Println("=== Delivery Audit ===")
+	out.Printf("Sent:              %d messages\n", a.Produced)
+	out.Printf("Exactly once:      %d\n", a.ExactlyOnce)
+
+	duplicated := make([]uint64, 0, len(a.Duplicated))
+	for id := range a.Duplicated {
+		duplicated = append(duplicated, id)
+	}
+	sort.Slice(duplicated, func(i, j int) bool { return duplicated[i] < duplicated[j] })
+	out.Printf("Duplicated:        %d\n", len(duplicated))
+	if len(duplicated) > 0 {
+		var times []string
+		for i, id := range duplicated {
+			if i == maxAuditIDs {
+				times = append(times, fmt.Sprintf("and %d more", len(duplicated)-maxAuditIDs))
+				break
+			}
+			times = append(times, fmt.Sprintf("#%d x%d", id, a.Duplicated[id]))
+		}
+		out.Printf("  %s\n", strings.Join(times, ", "))
+	}
+	out.Printf("Not delivered:     %d\n", len(a.Undelivered))
+	if len(a.Undelivered) > 0 {
+		out.Printf("  %s\n", formatIDs(a.Undelivered))
+	}
+	if len(a.Unknown) > 0 {
+		out.Printf("Unknown:           %d (consumed, never sent)\n", len(a.Unknown))
+		out.Printf("  %s\n", formatIDs(a.Unknown))
+	}
+	if a.Holds() {
+		out.Println("Result:            OK")
+	} else {
+		out.Println("Result:            VIOLATED")
+	}
+}
@@ -0,0 +1,99 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestDeliveryAuditorFindsDuplicatesAndLosses verifies that every message is
+// expected once, and every copy of a multicast message once per member
+func TestDeliveryAuditorFindsDuplicatesAndLosses(t *testing.T) {
+	a := NewDeliveryAuditor()
+	for id := uint64(1); id <= 4; id++ {
+		m := &msg.DemoMessage{ID: id}
+		if id == 4 {
+			m.Group = "Front"
+		}
+		a.Produced(m)
+	}
+	a.Delivered("Consumer1", &msg.DemoMessage{ID: 1})
+	a.Delivered("Consumer2", &msg.DemoMessage{ID: 1})
+	a.Delivered("Consumer1", &msg.DemoMessage{ID: 1})
+	a.Delivered("Consumer3", &msg.DemoMessage{ID: 3})
+	a.Delivered("Consumer1", &msg.DemoMessage{ID: 4, Group: "Front"})
+	a.Delivered("Consumer2", &msg.DemoMessage{ID: 4, Group: "Front"})
+	
+	audit := a.Audit()
+	if audit.Produced != 4 || audit.ExactlyOnce != 3 {
+		t.Errorf("Expected 3 of 4 messages delivered exactly once, got %d of %d", audit.ExactlyOnce, audit.Produced)
+	}
+	if len(audit.Duplicated) != 1 || audit.Duplicated[1] != 3 {
+		t.Errorf("Expected #1 delivered 3 times, got %v", audit.Duplicated)
+	}
+	if len(audit.Undelivered) != 1 || audit.Undelivered[0] != 2 {
+		t.Errorf("Expected #2 undelivered, got %v", audit.Undelivered)
+	}
+	if audit.Holds() {
+		t.Errorf("Expected the audit to fail")
+	}
+	
+	a.Delivered("Consumer1", &msg.DemoMessage{ID: 9})
+	if audit := a.Audit(); len(audit.Unknown) != 1 || audit.Unknown[0] != 9 {
+		t.Errorf("Expected #9 unknown, got %v", audit.Unknown)
+	}
+}
+
+// TestDeliveryAuditOfRuns verifies that the audit flags the messages lost to
+// faults and the copies sent by middlewares, and passes once retransmission
+// recovers the losses and discards the duplicates
+func TestDeliveryAuditOfRuns(t *testing.T) {
+	run := func(retransmitTimeout float64, copies bool) DeliveryAudit {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 200
+		cfg.FaultLoss = 0.1
+		cfg.RetransmitTimeout = retransmitTimeout
+		cfg.AuditDelivery = true
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if copies {
+			simulation.Use(middleware.Funcs{
+				Route: func(i *middleware.Interception) {
+					if i.Msg.ID%5 == 0 {
+						i.Copies = 1
+					}
+				},
+			})
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		audit := simulation.auditor.Audit()
+		if audit.Produced != simulation.stats.Produced {
+			t.Errorf("Expected all %d messages sent audited, got %d", simulation.stats.Produced, audit.Produced)
+		}
+		if !copies && len(audit.Undelivered) != simulation.stats.Produced-simulation.stats.Consumed {
+			t.Errorf("Expected the %d lost messages undelivered, got %v",
+				simulation.stats.Produced-simulation.stats.Consumed, audit.Undelivered)
+		}
+		return audit
+	}
+	
+	if lossy := run(0, false); lossy.Holds() || len(lossy.Undelivered) == 0 || len(lossy.Duplicated) != 0 {
+		t.Errorf("Expected losses without retransmission, got %+v", lossy)
+	}
+	if copied := run(0, true); len(copied.Duplicated) == 0 {
+		t.Errorf("Expected the middleware copies to be duplicates, got %+v", copied)
+	}
+	if recovered := run(1, true); !recovered.Holds() {
+		t.Errorf("Expected every message delivered exactly once with retransmission, got %+v", recovered)
+	}
+}
@@ -0,0 +1,99 @@
+package demo
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// BackpressureTracker measures how long it takes for congestion at a consumer
+// to slow down the producer. A consumer is congested while the messages
+// queued in its RX queues reach the threshold. The producer reacts when it
+// stalls at its in-flight limit or shrinks its window. The lag between the
+// onset of congestion and the next reaction determines how much buffering
+// the consumer needs. Its methods are safe to call on a nil tracker.
+type BackpressureTracker struct {
+	threshold int
+	out       EventSink
+	congested map[string]bool
+	onsets    []sim.VTimeInSec // Congestion onsets the producer has not reacted to yet
+	lags      []float64
+
+	Episodes   int // Times a consumer became congested
+	Unanswered int // Episodes that cleared before the producer reacted
+}
+
+// NewBackpressureTracker creates a tracker that considers a consumer
+// congested once threshold messages are queued
+func NewBackpressureTracker(threshold int, out EventSink) *BackpressureTracker {
+	return &BackpressureTracker{
+		threshold: threshold,
+		out:       out,
+		congested: make(map[string]bool),
+	}
+}
+
+// ObserveDepth records the number of messages queued at a consumer
+func (b *BackpressureTracker) ObserveDepth(now sim.VTimeInSec, consumer string, depth int) {
+	if b == nil {
+		return
+	}
+
+	congested := depth >= b.threshold
+	if congested == b.congested[consumer] {
+		return
+	}
+	b.congested[consumer] = congested
+
+	if congested {
+		b.Episodes++
+		b.onsets = append(b.onsets, now)
+		b.out.Printf("[%.2f] Backpressure: %s congested (%d queued)\n", now, consumer, depth)
+		return
+	}
+
+	// Episodes still waiting for a reaction when no consumer is congested
+	// anymore were absorbed by the buffers
+	for _, c := range b.congested {
+		if c {
+			return
+		}
+	}
+	b.Unanswered += len(b.onsets)
+	b.onsets = b.onsets[:0]
+}
+
+// ProducerReacted records that the producer slowed down, answering all the
+// pending congestion episodes
+func (b *BackpressureTracker) ProducerReacted(now sim.VTimeInSec) {
+	if b == nil || len(b.onsets) == 0 {
+		return
+	}
+
+	for _, onset := range b.onsets {
+		b.lags = append(b.lags, float64(now-onset))
+	}
+	b.out.Printf("[%.2f] Backpressure: Producer slowed down %.2f s after congestion\n", now, float64(now-b.onsets[0]))
+	b.onsets = b.onsets[:0]
+}
+
+// MeanLag returns the average lag between congestion and the producer's
+// reaction in seconds
+func (b *BackpressureTracker) MeanLag() float64 {
+	return mean(b.lags)
+}
+
+// MaxLag returns the longest lag between congestion and the producer's
+// reaction in seconds
+func (b *BackpressureTracker) MaxLag() float64 {
+	return percentile(b.lags, 100)
+}
+
+// Print writes the backpressure propagation statistics
+func (b *BackpressureTracker) Print() {
+	b.out.Println("=== Backpressure Propagation ===")
+	b.out.Printf("Congestion threshold: %d messages\n", b.threshold)
+	b.out.Printf("Congestion episodes:  %d\n", b.Episodes)
+	b.out.Printf("Producer reactions:   %d\n", len(b.lags))
+	b.out.Printf("Absorbed by buffers:  %d\n", b.Unanswered)
+	b.out.Printf("Mean lag:             %.2f s\n", b.MeanLag())
+	b.out.Printf("Max lag:              %.2f s\n", b.MaxLag())
+}
@@ -0,0 +1,44 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestBackpressureLag verifies that the lag is measured from the onset of
+// congestion to the next reaction of the producer
+func TestBackpressureLag(t *testing.T) {
+	b := NewBackpressureTracker(3, NullSink{})
+	b.ObserveDepth(1, "Consumer1", 2)
+	b.ObserveDepth(2, "Consumer1", 3)
+	b.ObserveDepth(3, "Consumer1", 4)
+	b.ProducerReacted(6)
+	b.ProducerReacted(7)
+	
+	if b.Episodes != 1 {
+		t.Errorf("Expected 1 congestion episode, got %d", b.Episodes)
+	}
+	if b.MeanLag() != 4 {
+		t.Errorf("Expected a lag of 4 s, got %.2f", b.MeanLag())
+	}
+}
+
+// TestBackpressureAbsorbedEpisode verifies that congestion that clears before
+// the producer reacts is counted as absorbed by the buffers
+func TestBackpressureAbsorbedEpisode(t *testing.T) {
+	b := NewBackpressureTracker(2, NullSink{})
+	b.ObserveDepth(1, "Consumer1", 2)
+	b.ObserveDepth(2, "Consumer2", 2)
+	b.ObserveDepth(3, "Consumer1", 1)
+	
+	if b.Unanswered != 0 {
+		t.Fatalf("Expected episodes to stay pending while Consumer2 is congested, got %d absorbed", b.Unanswered)
+	}
+	
+	b.ObserveDepth(4, "Consumer2", 0)
+	b.ProducerReacted(5)
+	
+	if b.Unanswered != 2 || len(b.lags) != 0 {
+		t.Errorf("Expected 2 absorbed episodes and no reaction, got %d and %d", b.Unanswered, len(b.lags))
+	}
+}
+
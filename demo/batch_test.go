@@ -0,0 +1,87 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/msg"
+)
+
+func queueMessages(c *consumer.Consumer, n int) {
+	for i := 0; i < n; i++ {
+		m := &msg.DemoMessage{ID: uint64(i + 1), Destination: c.Name()}
+		m.Meta().Dst = c.InputPort()
+		c.InputPort().Recv(m)
+	}
+}
+
+// TestBatchConsumerWaitsForFullBatch verifies that a batch consumer does not
+// process a partial batch before the flush time
+func TestBatchConsumerWaitsForFullBatch(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithStats(stats), consumer.WithBatches(3, 100))
+	
+	queueMessages(c, 2)
+	c.Tick(0)
+	
+	if stats.Consumed != 0 {
+		t.Errorf("Expected the partial batch to wait, got %d messages consumed", stats.Consumed)
+	}
+	
+	queueMessages(c, 1)
+	c.Tick(1)
+	
+	if stats.Consumed != 1 || c.QueueDepth() != 2 {
+		t.Errorf("Expected the full batch to start processing, got %d consumed and %d left",
+			stats.Consumed, c.QueueDepth())
+	}
+}
+
+// TestBatchConsumerFlushesPartialBatch verifies that a partial batch is
+// processed when the consumer drains at the flush time
+func TestBatchConsumerFlushesPartialBatch(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithStats(stats), consumer.WithBatches(5, 10))
+	scheduleDrain(engine, []component.Lifecycle{c}, 10)
+	
+	queueMessages(c, 2)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if stats.Consumed != 2 {
+		t.Errorf("Expected the partial batch to be flushed, got %d messages consumed", stats.Consumed)
+	}
+	if engine.CurrentTime() < 10 {
+		t.Errorf("Expected the batch to be flushed at time 10, finished at %.2f", engine.CurrentTime())
+	}
+}
+
+// TestBatchVsStreamingScenario verifies that both runs of the scenario see
+// the same traffic and that batching does not complete earlier
+func TestBatchVsStreamingScenario(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	cfg.Cycles = 30
+	
+	results, err := RunBatchVsStreaming(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Produced != results[1].Produced {
+		t.Errorf("Expected both runs to produce the same traffic, got %d and %d",
+			results[0].Produced, results[1].Produced)
+	}
+	if results[1].Completion < results[0].Completion {
+		t.Errorf("Expected the batch run to complete no earlier than streaming, got %.2f and %.2f",
+			results[1].Completion, results[0].Completion)
+	}
+}
@@ -0,0 +1,194 @@
+package demo
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// memSampleEvents is the number of events between two samples of the heap
+const memSampleEvents = 4096
+
+// BenchTopologySpec returns n producers and n consumers with RX queues of
+// the given capacity
+func BenchTopologySpec(n int, interval float64, capacity int) TopologySpec {
+	var spec TopologySpec
+	for i := 1; i <= n; i++ {
+		spec.Producers = append(spec.Producers, ProducerSpec{Name: fmt.Sprintf("Producer%d", i)})
+		spec.Consumers = append(spec.Consumers, ConsumerSpec{
+			Name:          fmt.Sprintf("Consumer%d", i),
+			Interval:      interval,
+			QueueCapacity: capacity,
+		})
+	}
+	return spec
+}
+
+// BenchProfiler is an engine hook that measures the wall-clock time spent
+// handling the events of every component type and samples the heap
+type BenchProfiler struct {
+	Events   int
+	PeakHeap uint64
+	ByType   map[string]time.Duration
+	start    time.Time
+}
+
+// NewBenchProfiler creates a profiler that has seen no events
+func NewBenchProfiler() *BenchProfiler {
+	return &BenchProfiler{ByType: make(map[string]time.Duration)}
+}
+
+// Func times every event and samples the heap every memSampleEvents events
+func (p *BenchProfiler) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosBeforeEvent:
+		p.start = time.Now()
+	case sim.HookPosAfterEvent:
+		elapsed := time.Since(p.start)
+		p.ByType[componentType(ctx.Item.(sim.Event).Handler())] += elapsed
+		p.Events++
+		if p.Events%memSampleEvents == 0 {
+			p.sampleHeap()
+		}
+	}
+}
+
+func (p *BenchProfiler) sampleHeap() {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	if m.HeapAlloc > p.PeakHeap {
+		p.PeakHeap = m.HeapAlloc
+	}
+}
+
+// componentType names the type of the component that handles an event:
+// its name without the trailing number, or its Go type
+func componentType(h sim.Handler) string {
+	if named, ok := h.(sim.Named); ok {
+		return strings.TrimRight(named.Name(), "0123456789")
+	}
+	t := reflect.TypeOf(h)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// BenchResult is the performance of the run at one scale
+type BenchResult struct {
+	Scale     int // Number of producers and of consumers
+	Events    int
+	WallClock time.Duration
+	PeakHeap  uint64
+	Allocated uint64 // Bytes allocated during the run
+	ByType    map[string]time.Duration
+}
+
+// EventsPerSecond returns the events handled per wall-clock second
+func (r BenchResult) EventsPerSecond() float64 {
+	if r.WallClock <= 0 {
+		return 0
+	}
+	return float64(r.Events) / r.WallClock.Seconds()
+}
+
+// RunBench runs the configuration with 10, 100, ... producers and as many
+// consumers, up to cfg.Bench, and measures every run. The runs are silent.
+func RunBench(cfg *Config) ([]BenchResult, error) {
+	var results []BenchResult
+	for scale := 10; ; scale *= 10 {
+		if scale > cfg.Bench {
+			scale = cfg.Bench
+		}
+		result, err := benchRun(cfg, scale)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+		if scale == cfg.Bench {
+			return results, nil
+		}
+	}
+}
+
+func benchRun(cfg *Config, scale int) (BenchResult, error) {
+	runCfg := *cfg
+	runCfg.Bench = scale
+	runCfg.Sink = NullSink{}
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	simulation, err := NewSimulation(&runCfg)
+	if err != nil {
+		return BenchResult{}, err
+	}
+	profiler := NewBenchProfiler()
+	simulation.engine.AcceptHook(profiler)
+
+	start := time.Now()
+	if err := simulation.Run(); err != nil {
+		return BenchResult{}, err
+	}
+	wallClock := time.Since(start)
+
+	profiler.sampleHeap()
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	return BenchResult{
+		Scale:     scale,
+		Events:    profiler.Events,
+		WallClock: wallClock,
+		PeakHeap:  profiler.PeakHeap,
+		Allocated: after.TotalAlloc - before.TotalAlloc,
+		ByType:    profiler.ByType,
+	}, nil
+}
+
+// PrintBench writes the performance of every scale and the time spent per
+// component type at the largest one
+func PrintBench(out EventSink, results []BenchResult) {
+	out.Println("=== Benchmark ===")
+	out.Printf("%9s %9s %10s %11s %12s %10s %12s\n",
+		"Producers", "Consumers", "Events", "Wall clock", "Events/s", "Peak heap", "Alloc/event")
+	for _, r := range results {
+		allocPerEvent := 0.0
+		if r.Events > 0 {
+			allocPerEvent = float64(r.Allocated) / float64(r.Events)
+		}
+		out.Printf("%9d %9d %10d %9.2f s %12.0f %7.1f MB %10.0f B\n",
+			r.Scale, r.Scale, r.Events, r.WallClock.Seconds(), r.EventsPerSecond(),
+			float64(r.PeakHeap)/(1<<20), allocPerEvent)
+	}
+
+	largest := results[len(results)-1]
+	types := make([]string, 0, len(largest.ByType))
+	var total time.Duration
+	width := len("Component type")
+	for name, d := range largest.ByType {
+		types = append(types, name)
+		total += d
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return largest.ByType[types[i]] > largest.ByType[types[j]] })
+
+	out.Println()
+	out.Printf("=== Time per Component Type (%d producers, %d consumers) ===\n", largest.Scale, largest.Scale)
+	out.Printf("%-*s %13s %7s\n", width, "Component type", "Wall clock", "Share")
+	for _, name := range types {
+		d := largest.ByType[name]
+		share := 0.0
+		if total > 0 {
+			share = float64(d) / float64(total) * 100
+		}
+		out.Printf("%-*s %10.1f ms %6.1f%%\n", width, name, float64(d.Microseconds())/1000, share)
+	}
+}
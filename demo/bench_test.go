@@ -0,0 +1,62 @@
+package demo
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestRunBenchScalesUpToLimit verifies that the benchmark runs every power
+// of ten below the limit and the limit itself, and attributes the time to
+// the component types
+func TestRunBenchScalesUpToLimit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Bench = 30
+	
+	results, err := RunBench(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(results) != 2 || results[0].Scale != 10 || results[1].Scale != 30 {
+		t.Fatalf("Expected runs with 10 and 30 producers, got %v", results)
+	}
+	for _, r := range results {
+		if r.Events == 0 || r.PeakHeap == 0 {
+			t.Errorf("Expected events and a heap sample with %d producers, got %d and %d", r.Scale, r.Events, r.PeakHeap)
+		}
+		for _, name := range []string{"Producer", "Distributor", "Consumer"} {
+			if _, ok := r.ByType[name]; !ok {
+				t.Errorf("Expected time of %s with %d producers, got %v", name, r.Scale, r.ByType)
+			}
+		}
+	}
+}
+
+// BenchmarkSimulation runs the simulation with growing numbers of producers
+// and consumers
+func BenchmarkSimulation(b *testing.B) {
+	for _, scale := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("scale=%d", scale), func(b *testing.B) {
+			cfg := DefaultConfig()
+			cfg.Seed = 1
+			cfg.Cycles = 100
+			cfg.Bench = scale
+			silence(b, cfg)
+			
+			events := 0
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				simulation, err := NewSimulation(cfg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := simulation.Run(); err != nil {
+					b.Fatal(err)
+				}
+				events += simulation.stats.EngineEvents
+			}
+			b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package demo
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Budgets declares the expected performance of a run. A zero value disables
+// the corresponding check.
+type Budgets struct {
+	MaxP99Latency float64 `json:"max_p99_latency"` // seconds
+	MinThroughput float64 `json:"min_throughput"`  // messages per second
+}
+
+// BudgetViolation describes a metric that is outside of its budget
+type BudgetViolation struct {
+	Metric   string
+	Measured float64
+	Budget   float64
+	Unit     string
+}
+
+// String formats the violation as a diff between the budget and the measured
+// value
+func (v BudgetViolation) String() string {
+	delta := 0.0
+	if v.Budget != 0 {
+		delta = (v.Measured - v.Budget) / v.Budget * 100
+	}
+	return fmt.Sprintf("- %s budget: %.3f %s\n+ %s measured: %.3f %s (%+.1f%%)",
+		v.Metric, v.Budget, v.Unit, v.Metric, v.Measured, v.Unit, delta)
+}
+
+// Check compares the collected statistics against the budgets and returns
+// all violations
+func (b Budgets) Check(stats *Stats, duration sim.VTimeInSec) []BudgetViolation {
+	var violations []BudgetViolation
+
+	if b.MaxP99Latency > 0 {
+		p99 := stats.LatencyPercentile(99)
+		if p99 > b.MaxP99Latency {
+			violations = append(violations, BudgetViolation{
+				Metric:   "p99 latency",
+				Measured: p99,
+				Budget:   b.MaxP99Latency,
+				Unit:     "s",
+			})
+		}
+	}
+
+	if b.MinThroughput > 0 {
+		throughput := stats.Throughput(duration)
+		if throughput < b.MinThroughput {
+			violations = append(violations, BudgetViolation{
+				Metric:   "throughput",
+				Measured: throughput,
+				Budget:   b.MinThroughput,
+				Unit:     "msg/s",
+			})
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,55 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestBudgetsPassWhenWithinLimits verifies that no violation is reported when
+// the metrics are within their budgets
+func TestBudgetsPassWhenWithinLimits(t *testing.T) {
+	stats := NewStats()
+	stats.RecordConsumed(1, &msg.DemoMessage{})
+	stats.RecordConsumed(2, &msg.DemoMessage{})
+	
+	budgets := Budgets{MaxP99Latency: 2, MinThroughput: 0.1}
+	violations := budgets.Check(stats, 10)
+	
+	if len(violations) != 0 {
+		t.Errorf("Expected no budget violations, got %v", violations)
+	}
+}
+
+// TestBudgetsReportViolations verifies that both the latency and throughput
+// budgets are reported when exceeded
+func TestBudgetsReportViolations(t *testing.T) {
+	stats := NewStats()
+	stats.RecordConsumed(1, &msg.DemoMessage{})
+	stats.RecordConsumed(5, &msg.DemoMessage{})
+	
+	budgets := Budgets{MaxP99Latency: 2, MinThroughput: 1}
+	violations := budgets.Check(stats, 10)
+	
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 budget violations, got %d", len(violations))
+	}
+	if violations[0].Metric != "p99 latency" || violations[0].Measured != 5 {
+		t.Errorf("Unexpected latency violation %+v", violations[0])
+	}
+	if violations[1].Metric != "throughput" || violations[1].Measured != 0.2 {
+		t.Errorf("Unexpected throughput violation %+v", violations[1])
+	}
+}
+
+// TestBudgetsDisabledByDefault verifies that zero budgets never fail a run
+func TestBudgetsDisabledByDefault(t *testing.T) {
+	stats := NewStats()
+	stats.RecordConsumed(100, &msg.DemoMessage{})
+	
+	violations := Budgets{}.Check(stats, 1000)
+	
+	if len(violations) != 0 {
+		t.Errorf("Expected no budget violations with empty budgets, got %v", violations)
+	}
+}
@@ -0,0 +1,267 @@
+package demo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BundleSink passes the output of a run on to its sink and captures it.
+// Once the output is closed, at the end of the run or when it fails, it
+// packages the captured log with the configuration, the input and output
+// files, and the build of the binary into a gzipped tar archive, from which
+// a collaborator can reproduce or inspect the run:
+//
+//	config.json   the configuration, with the seed fixed and the files
+//	              renamed to the ones in the archive
+//	reproduce.sh  runs the configuration again
+//	version.txt   the build of the binary that ran it
+//	output.log    the log and the reports, unless they went to a file
+//	inputs/       the trace, fault schedule, traffic matrix, and checkpoint
+//	              the run read
+//	outputs/      the files the run wrote
+type BundleSink struct {
+	EventSink
+	path    string
+	args    []string          // Command line of the run
+	command string            // Subcommand run instead of the simulation, if any
+	cfg     Config            // Configuration as bundled
+	inputs  map[string]string // Archive name of every file read
+	outputs map[string]string // Archive name of every file written
+	log     bytes.Buffer
+	created time.Time
+}
+
+// NewBundleSink captures the output sent to sink for the bundle of cfg. A
+// random seed is fixed first, so that the bundled run can be repeated.
+func NewBundleSink(sink EventSink, cfg *Config, args []string, command string) *BundleSink {
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	s := &BundleSink{
+		EventSink: sink,
+		path:      cfg.Bundle,
+		args:      args,
+		command:   command,
+		cfg:       *cfg,
+		inputs:    make(map[string]string),
+		outputs:   make(map[string]string),
+		created:   time.Now(),
+	}
+	s.cfg.Bundle = ""
+
+	inputs, outputs := s.cfg.bundledFiles()
+	taken := make(map[string]bool)
+	for _, path := range inputs {
+		if *path != "" {
+			name := bundleName("inputs", *path, taken)
+			s.inputs[*path] = name
+			*path = name
+		}
+	}
+	for _, path := range outputs {
+		if *path != "" {
+			name := bundleName("outputs", *path, taken)
+			s.outputs[*path] = name
+			*path = name
+		}
+	}
+	return s
+}
+
+// bundledFiles returns the files a run reads and the files it writes
+func (c *Config) bundledFiles() (inputs, outputs []*string) {
+	inputs = []*string{&c.TraceFile, &c.FaultSchedule, &c.TrafficMatrixFile, &c.Replay}
+	outputs = []*string{
+		&c.CompareHTML, &c.GrantTraceFile, &c.LatencyCDFFile, &c.TimestampFile,
+		&c.TraceOutFile, &c.Checkpoint, &c.DBFile, &c.VisualTraceFile, &c.DotFile,
+		&c.MermaidFile, &c.QueueSampleFile, &c.PortTimelineFile, &c.SweepCSV, &c.MetricsOut,
+	}
+	if c.Output == "file" {
+		outputs = append(outputs, &c.OutputFile)
+	}
+	return inputs, outputs
+}
+
+// bundleName returns the name of a file in a directory of the archive,
+// numbered if another file of the same name is already there
+func bundleName(dir, path string, taken map[string]bool) string {
+	base := filepath.Base(path)
+	name := dir + "/" + base
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s/%d-%s", dir, i, base)
+	}
+	taken[name] = true
+	return name
+}
+
+// Printf formats and writes a line to the sink and the log
+func (s *BundleSink) Printf(format string, args ...interface{}) {
+	s.EventSink.Printf(format, args...)
+	fmt.Fprintf(&s.log, format, args...)
+}
+
+// Println writes the arguments followed by a newline to the sink and the log
+func (s *BundleSink) Println(args ...interface{}) {
+	s.EventSink.Println(args...)
+	fmt.Fprintln(&s.log, args...)
+}
+
+// Close flushes the sink, so that the output file is complete, and writes
+// the bundle
+func (s *BundleSink) Close() error {
+	err := s.EventSink.Close()
+	if bundleErr := s.write(); bundleErr != nil {
+		return fmt.Errorf("writing bundle %s: %w", s.path, bundleErr)
+	}
+	return err
+}
+
+// write packages the run into the archive
+func (s *BundleSink) write() error {
+	f, err := os.Create(s.path)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = s.writeFiles(tw)
+	if closeErr := tw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeFiles adds the files of the bundle under a directory named after the
+// archive
+func (s *BundleSink) writeFiles(tw *tar.Writer) error {
+	dir := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(s.path), ".tgz"), ".tar.gz")
+	add := func(name string, data []byte, mode int64) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    dir + "/" + name,
+			Mode:    mode,
+			Size:    int64(len(data)),
+			ModTime: s.created,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	config, err := json.MarshalIndent(&s.cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add("config.json", append(config, '\n'), 0o644); err != nil {
+		return err
+	}
+	if err := add("reproduce.sh", s.script(), 0o755); err != nil {
+		return err
+	}
+	if err := add("version.txt", s.version(), 0o644); err != nil {
+		return err
+	}
+	if s.cfg.Output != "file" {
+		if err := add("output.log", s.log.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+
+	for _, files := range []map[string]string{s.inputs, s.outputs} {
+		var paths []string
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if os.IsNotExist(err) {
+				// Not written, e.g. a checkpoint the run did not reach
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := add(files[path], data, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// script returns the shell script that runs the bundled configuration
+func (s *BundleSink) script() []byte {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString("# Reproduces the run of\n")
+	fmt.Fprintf(&b, "#   akita_demo %s\n", strings.Join(s.args, " "))
+	b.WriteString("# with the configuration and the inputs of this bundle. Use the build in\n")
+	b.WriteString("# version.txt, or set AKITA_DEMO to the binary to run.\n")
+	b.WriteString("set -e\n")
+	b.WriteString("cd \"$(dirname \"$0\")\"\n")
+	b.WriteString("mkdir -p outputs\n")
+	b.WriteString("exec \"${AKITA_DEMO:-akita_demo}\" -config config.json")
+	if s.command != "" {
+		b.WriteString(" " + s.command)
+	}
+	b.WriteString(" \"$@\"\n")
+	return []byte(b.String())
+}
+
+// version returns the build of the running binary: the Go version, the
+// revision of the module, and the versions of its dependencies
+func (s *BundleSink) version() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Created:           %s\n", s.created.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Go:                %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		b.WriteString("Build:             unknown\n")
+		return []byte(b.String())
+	}
+	fmt.Fprintf(&b, "Module:            %s %s\n", info.Main.Path, info.Main.Version)
+	for _, setting := range info.Settings {
+		if strings.HasPrefix(setting.Key, "vcs") {
+			fmt.Fprintf(&b, "%-18s %s\n", setting.Key+":", setting.Value)
+		}
+	}
+	for _, dep := range info.Deps {
+		fmt.Fprintf(&b, "Dependency:        %s %s\n", dep.Path, dep.Version)
+	}
+	return []byte(b.String())
+}
+
+// validateBundle checks that the bundle is a gzipped tar archive of a run
+// that is not driven from outside
+func (c *Config) validateBundle() error {
+	if c.Bundle == "" {
+		return nil
+	}
+	if !strings.HasSuffix(c.Bundle, ".tar.gz") && !strings.HasSuffix(c.Bundle, ".tgz") {
+		return fmt.Errorf("bundle must be a .tar.gz or .tgz file")
+	}
+	if c.RPC || c.Interactive || c.Control != "" {
+		return fmt.Errorf("a bundle cannot reproduce runs driven by RPC, the REPL, or the control API")
+	}
+	return nil
+}
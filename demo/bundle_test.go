@@ -0,0 +1,142 @@
+package demo
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readBundle returns the files of a bundle by their names in the archive
+func readBundle(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(data)
+	}
+}
+
+// TestBundleReproducesRun verifies that a bundle holds the log, the inputs,
+// and the outputs of a run, and a configuration with the seed fixed and the
+// files renamed that repeats the run
+func TestBundleReproducesRun(t *testing.T) {
+	dir := t.TempDir()
+	schedule := filepath.Join(dir, "faults.csv")
+	if err := os.WriteFile(schedule, []byte("0,5,loss,ProducerToDistributor,0.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Cycles = 50
+	cfg.FaultSchedule = schedule
+	cfg.LatencyCDFFile = filepath.Join(dir, "cdf.csv")
+	cfg.Bundle = filepath.Join(dir, "run.tar.gz")
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	
+	bundle := NewBundleSink(NullSink{}, cfg, []string{"-faults", schedule}, "")
+	cfg.Sink = bundle
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.PrintReport(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bundle.Close(); err != nil {
+		t.Fatal(err)
+	}
+	
+	files := readBundle(t, cfg.Bundle)
+	for _, name := range []string{"config.json", "reproduce.sh", "version.txt", "output.log", "inputs/faults.csv", "outputs/cdf.csv"} {
+		if _, ok := files["run/"+name]; !ok {
+			t.Errorf("Expected the bundle to hold %s, got %d files", name, len(files))
+		}
+	}
+	if !strings.Contains(files["run/output.log"], "=== Faults ===") {
+		t.Errorf("Expected the bundle to hold the log of the run")
+	}
+	
+	var bundled Config
+	if err := json.Unmarshal([]byte(files["run/config.json"]), &bundled); err != nil {
+		t.Fatal(err)
+	}
+	if bundled.Seed == 0 || bundled.Seed != cfg.Seed {
+		t.Errorf("Expected the random seed to be fixed and bundled, got %d and %d", bundled.Seed, cfg.Seed)
+	}
+	if bundled.FaultSchedule != "inputs/faults.csv" || bundled.LatencyCDFFile != "outputs/cdf.csv" || bundled.Bundle != "" {
+		t.Errorf("Expected the files renamed to the ones in the bundle, got %+v", bundled)
+	}
+	
+	// The bundled configuration runs again from the extracted files
+	extracted := filepath.Join(dir, "extracted")
+	if err := os.MkdirAll(filepath.Join(extracted, "inputs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(extracted, "inputs", "faults.csv"), []byte(files["run/inputs/faults.csv"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bundled.FaultSchedule = filepath.Join(extracted, bundled.FaultSchedule)
+	bundled.LatencyCDFFile = ""
+	bundled.Sink = NullSink{}
+	again, err := NewSimulation(&bundled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if again.stats.Produced != simulation.stats.Produced || again.stats.Consumed != simulation.stats.Consumed ||
+		again.faults.TotalLost() != simulation.faults.TotalLost() {
+		t.Errorf("Expected the bundled run to repeat, got %d/%d/%d and %d/%d/%d",
+			simulation.stats.Produced, simulation.stats.Consumed, simulation.faults.TotalLost(),
+			again.stats.Produced, again.stats.Consumed, again.faults.TotalLost())
+	}
+}
+
+// TestBundleValidation verifies that bundles are gzipped tar archives of runs
+// not driven from outside
+func TestBundleValidation(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		apply func(cfg *Config)
+	}{
+		{"zip archive", func(cfg *Config) { cfg.Bundle = "run.zip" }},
+		{"RPC server", func(cfg *Config) { cfg.RPC = true }},
+		{"control API", func(cfg *Config) { cfg.Control = ":8081" }},
+	} {
+		cfg := DefaultConfig()
+		cfg.Bundle = "run.tar.gz"
+		c.apply(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected a bundle of a %s to be rejected", c.name)
+		}
+	}
+}
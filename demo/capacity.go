@@ -0,0 +1,139 @@
+package demo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// capacitySweep is the capacities the capacity-sweep scenario gives every
+// port in turn
+var capacitySweep = []int{1, 2, 5, 10, 20}
+
+// CapacityResult is the outcome of a run with one port capacity changed
+type CapacityResult struct {
+	Port     string // Flag of the port, without "-capacity"
+	Capacity int
+	Produced int
+	Consumed int
+	Dropped  int // Messages whose TTL ran out
+	// Ticks of all producers and of the distributor, and the ones in which
+	// they could not make progress
+	ProducerTicks    TickCounts
+	DistributorTicks TickCounts
+	Completion       sim.VTimeInSec // Time the last message was consumed
+	Configured       bool           // The capacity is the configured one
+}
+
+// DropRate returns the share of the produced messages that were dropped
+func (r CapacityResult) DropRate() float64 {
+	return percent(r.Dropped, r.Produced)
+}
+
+// ProducerStallRate returns the share of the producer ticks that stalled on
+// a full output port or the in-flight limit
+func (r CapacityResult) ProducerStallRate() float64 {
+	return percent(r.ProducerTicks.Blocked, r.ProducerTicks.Total())
+}
+
+// DistributorStallRate returns the share of the distributor ticks that
+// stalled on a busy output port
+func (r CapacityResult) DistributorStallRate() float64 {
+	return percent(r.DistributorTicks.Blocked, r.DistributorTicks.Total())
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+// capacityPort is a port capacity the sweep varies
+type capacityPort struct {
+	name     string
+	capacity func(cfg *Config) *int
+}
+
+var capacityPorts = []capacityPort{
+	{"producer-out", func(c *Config) *int { return &c.ProducerOutCapacity }},
+	{"distributor-in", func(c *Config) *int { return &c.DistributorInCapacity }},
+	{"distributor-out", func(c *Config) *int { return &c.DistributorOutCapacity }},
+	{"consumer-in", func(c *Config) *int { return &c.ConsumerInCapacity }},
+}
+
+// RunCapacitySweep runs the same workload once per capacity of capacitySweep
+// for every port on the data path, with the other ports at their configured
+// capacities. Batch consumers skip the RX queues smaller than a batch. The
+// runs are silent.
+func RunCapacitySweep(cfg *Config) ([]CapacityResult, error) {
+	// All runs must see the same traffic
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var results []CapacityResult
+	for _, port := range capacityPorts {
+		for _, capacity := range capacitySweep {
+			runCfg := *cfg
+			runCfg.Seed = seed
+			*port.capacity(&runCfg) = capacity
+			runCfg.Sink = NullSink{}
+			if runCfg.ConsumerMode == "batch" && runCfg.ConsumerInCapacity < runCfg.BatchSize {
+				continue
+			}
+
+			simulation, err := NewSimulation(&runCfg)
+			if err != nil {
+				return nil, err
+			}
+
+			err = simulation.Run()
+			if err != nil {
+				return nil, fmt.Errorf("%s capacity %d: %w", port.name, capacity, err)
+			}
+
+			stats := simulation.stats
+			result := CapacityResult{
+				Port:             port.name,
+				Capacity:         capacity,
+				Produced:         stats.Produced,
+				Consumed:         stats.Consumed,
+				Dropped:          stats.Expired,
+				DistributorTicks: stats.Ticks(simulation.distributor.Name()),
+				Completion:       simulation.Completion(),
+				Configured:       capacity == *port.capacity(cfg),
+			}
+			for _, p := range simulation.producers {
+				ticks := stats.Ticks(p.Name())
+				result.ProducerTicks.Busy += ticks.Busy
+				result.ProducerTicks.Idle += ticks.Idle
+				result.ProducerTicks.Blocked += ticks.Blocked
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// PrintCapacitySweep writes the drop and stall rates of every run, grouped
+// by port. The configured capacity of a port is marked with an asterisk.
+func PrintCapacitySweep(out EventSink, results []CapacityResult) {
+	out.Println("=== Capacity Sweep ===")
+	out.Printf("%-16s %9s %9s %9s %8s %7s %15s %18s %11s\n",
+		"Port", "Capacity", "Produced", "Consumed", "Dropped", "Drop%", "Producer stall", "Distributor stall", "Completion")
+	for i, r := range results {
+		if i > 0 && r.Port != results[i-1].Port {
+			out.Println()
+		}
+		capacity := fmt.Sprintf("%d", r.Capacity)
+		if r.Configured {
+			capacity += "*"
+		}
+		out.Printf("%-16s %9s %9d %9d %8d %6.1f%% %14.1f%% %17.1f%% %9.2f s\n",
+			r.Port, capacity, r.Produced, r.Consumed, r.Dropped, r.DropRate(),
+			r.ProducerStallRate(), r.DistributorStallRate(), float64(r.Completion))
+	}
+}
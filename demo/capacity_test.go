@@ -0,0 +1,114 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// TestSmallerConsumerQueuesStallTheDistributor verifies that the sweep runs
+// every capacity of every port with the same traffic, and that consumers
+// with shorter RX queues make the distributor stall more
+func TestSmallerConsumerQueuesStallTheDistributor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	cfg.Traffic = "bursty"
+	cfg.IdlePeriod = 2
+	cfg.ConsumeInterval = 6
+	cfg.TTL = 20
+	
+	results, err := RunCapacitySweep(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(capacityPorts)*len(capacitySweep) {
+		t.Fatalf("Expected %d runs, got %d", len(capacityPorts)*len(capacitySweep), len(results))
+	}
+	
+	byCapacity := make(map[int]CapacityResult)
+	for _, r := range results {
+		if r.Port == "consumer-in" {
+			byCapacity[r.Capacity] = r
+		}
+		if r.Configured != (r.Capacity == producerOutCapacity && r.Port == "producer-out" ||
+			r.Capacity == distributorInCapacity && r.Port == "distributor-in" ||
+			r.Capacity == distributorOutCapacity && r.Port == "distributor-out" ||
+			r.Capacity == consumerInCapacity && r.Port == "consumer-in") {
+			t.Errorf("Expected only the configured capacity to be marked, got %+v", r)
+		}
+	}
+	small, large := byCapacity[1], byCapacity[20]
+	if small.DistributorStallRate() <= large.DistributorStallRate() {
+		t.Errorf("Expected RX queues of 1 to stall the distributor more than of 20, got %.1f%% and %.1f%%",
+			small.DistributorStallRate(), large.DistributorStallRate())
+	}
+	if small.Consumed >= large.Consumed {
+		t.Errorf("Expected RX queues of 1 to consume fewer messages than of 20, got %d and %d", small.Consumed, large.Consumed)
+	}
+}
+
+// TestCapacitySweepSkipsQueuesSmallerThanABatch verifies that batch
+// consumers are never given an RX queue their batch does not fit into
+func TestCapacitySweepSkipsQueuesSmallerThanABatch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.ConsumerMode = "batch"
+	cfg.BatchSize = 5
+	
+	results, err := RunCapacitySweep(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Port == "consumer-in" && r.Capacity < cfg.BatchSize {
+			t.Errorf("Expected no RX queue smaller than the batch, got %d", r.Capacity)
+		}
+	}
+}
+
+// TestDistributorInputCapacity verifies that the input port of the
+// distributor refuses messages beyond its capacity
+func TestDistributorInputCapacity(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"}, distributor.WithCapacity(2))
+	
+	for i := 0; i < 3; i++ {
+		m := &msg.DemoMessage{ID: uint64(i + 1), Destination: "Consumer1"}
+		m.Meta().Dst = d.InputPort()
+		err := d.InputPort().Recv(m)
+		if i < 2 && err != nil {
+			t.Fatalf("Expected message %d to be accepted, got %v", i+1, err)
+		}
+		if i == 2 && err == nil {
+			t.Error("Expected the third message to be refused by a port of capacity 2")
+		}
+	}
+}
+
+// TestOutputCapacityIsTheSendBuffer verifies that an output port can send as
+// many messages as its send buffer holds before they are delivered
+func TestOutputCapacityIsTheSendBuffer(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	p := producer.New("Producer", engine, 100)
+	d := distributor.New("Distributor", engine, []string{"Consumer1"})
+	topology := &Topology{}
+	topology.SetSendBuffer(p.OutputPort(), 2)
+	topology.Connect("ProducerToDistributor", engine, p.OutputPort(), d.InputPort())
+	
+	for i := 0; i < 3; i++ {
+		m := &msg.DemoMessage{ID: uint64(i + 1), Destination: "Consumer1"}
+		m.Meta().Src = p.OutputPort()
+		m.Meta().Dst = d.InputPort()
+		err := p.OutputPort().Send(m)
+		if i < 2 && err != nil {
+			t.Fatalf("Expected message %d to be buffered, got %v", i+1, err)
+		}
+		if i == 2 && err == nil {
+			t.Error("Expected the third message to be refused by a send buffer of 2")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package demo
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Pair identifies a (producer, consumer) pair of a message
+type Pair struct {
+	Producer string
+	Consumer string
+}
+
+// String formats the pair as "producer->consumer"
+func (p Pair) String() string {
+	return p.Producer + "->" + p.Consumer
+}
+
+// Pairs returns the pairs with recorded latencies in sorted order
+func (s *Stats) Pairs() []Pair {
+	pairs := make([]Pair, 0, len(s.pairLatencies))
+	for pair := range s.pairLatencies {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Producer != pairs[j].Producer {
+			return pairs[i].Producer < pairs[j].Producer
+		}
+		return pairs[i].Consumer < pairs[j].Consumer
+	})
+	return pairs
+}
+
+// PairCDF returns the sorted latencies of a pair and the cumulative fraction
+// of messages with a latency less than or equal to each of them. Repeated
+// latencies are collapsed into a single point.
+func (s *Stats) PairCDF(pair Pair) (latencies []float64, fractions []float64) {
+	return cdf(s.pairLatencies[pair])
+}
+
+// LatencyCDF returns the CDF of the latencies of all consumed messages, in
+// the form of PairCDF
+func (s *Stats) LatencyCDF() (latencies []float64, fractions []float64) {
+	return cdf(s.latencies)
+}
+
+func cdf(values []float64) (latencies []float64, fractions []float64) {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	for i, l := range sorted {
+		if i+1 < len(sorted) && sorted[i+1] == l {
+			continue
+		}
+		latencies = append(latencies, l)
+		fractions = append(fractions, float64(i+1)/float64(len(sorted)))
+	}
+	return latencies, fractions
+}
+
+// WriteLatencyCDF writes the latency CDF of every pair as CSV with the
+// columns producer, consumer, latency (s), and cumulative fraction
+func (s *Stats) WriteLatencyCDF(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"producer", "consumer", "latency", "fraction"}); err != nil {
+		return err
+	}
+
+	for _, pair := range s.Pairs() {
+		latencies, fractions := s.PairCDF(pair)
+		for i := range latencies {
+			err := cw.Write([]string{
+				pair.Producer,
+				pair.Consumer,
+				strconv.FormatFloat(latencies[i], 'f', -1, 64),
+				strconv.FormatFloat(fractions[i], 'f', 4, 64),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportLatencyCDF writes the per-pair latency CDFs to a CSV file
+func (s *Stats) ExportLatencyCDF(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.WriteLatencyCDF(f)
+}
+
+// PrintPairLatencies prints a latency summary of every pair
+func (s *Stats) PrintPairLatencies(out EventSink) {
+	out.Println("=== Latency per Pair ===")
+	for _, pair := range s.Pairs() {
+		latencies := s.pairLatencies[pair]
+		out.Printf("%-24s n=%-4d mean=%.2f s  p50=%.2f s  p99=%.2f s\n",
+			pair, len(latencies), mean(latencies),
+			percentile(latencies, 50), percentile(latencies, 99))
+	}
+}
@@ -0,0 +1,50 @@
+package demo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// TestPairCDFCollapsesRepeatedLatencies verifies the CDF points of a pair
+func TestPairCDFCollapsesRepeatedLatencies(t *testing.T) {
+	stats := NewStats()
+	for _, l := range []float64{3, 1, 3, 2} {
+		stats.RecordPairLatency("Producer", "Consumer1", sim.VTimeInSec(l))
+	}
+	
+	latencies, fractions := stats.PairCDF(Pair{"Producer", "Consumer1"})
+	
+	expectedLatencies := []float64{1, 2, 3}
+	expectedFractions := []float64{0.25, 0.5, 1}
+	if len(latencies) != 3 {
+		t.Fatalf("Expected 3 CDF points, got %v", latencies)
+	}
+	for i := range latencies {
+		if latencies[i] != expectedLatencies[i] || fractions[i] != expectedFractions[i] {
+			t.Errorf("Point %d: expected (%v, %v), got (%v, %v)",
+				i, expectedLatencies[i], expectedFractions[i], latencies[i], fractions[i])
+		}
+	}
+}
+
+// TestWriteLatencyCDFSeparatesPairs verifies that each pair gets its own CDF
+// in the CSV export
+func TestWriteLatencyCDFSeparatesPairs(t *testing.T) {
+	stats := NewStats()
+	stats.RecordPairLatency("Producer", "Consumer2", 4)
+	stats.RecordPairLatency("Producer", "Consumer1", 2)
+	
+	var buf bytes.Buffer
+	if err := stats.WriteLatencyCDF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	
+	expected := "producer,consumer,latency,fraction\n" +
+		"Producer,Consumer1,2,1.0000\n" +
+		"Producer,Consumer2,4,1.0000\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected CSV output:\n%s", buf.String())
+	}
+}
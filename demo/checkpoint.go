@@ -0,0 +1,326 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Checkpointable is a component whose state is saved in checkpoints
+type Checkpointable interface {
+	Name() string
+	// CheckpointState returns the fields of the component that change during
+	// a run, as a value that encodes to the same JSON for the same state
+	CheckpointState() interface{}
+}
+
+// Checkpoint is the state of a run after its last event before a virtual
+// time. State holds everything a run needs to resume from there: the pending
+// events of the engine, the messages in the buffers of the ports, the random
+// number generators, and the fields of the components. The states of the
+// components and the queued messages are also recorded as JSON and by
+// description, which the diff command compares.
+type Checkpoint struct {
+	Config     []byte         // Configuration of the run as JSON
+	Time       sim.VTimeInSec // Time of the first event after the checkpoint
+	NextEvent  string         // First event after the checkpoint
+	Components []ComponentCheckpoint
+	Ports      []PortCheckpoint
+	State      *snapshot
+}
+
+// ComponentCheckpoint is the state of a component as JSON
+type ComponentCheckpoint struct {
+	Name  string
+	State []byte
+}
+
+// PortCheckpoint lists the messages queued in the buffer of a port
+type PortCheckpoint struct {
+	Name     string
+	Messages []string
+}
+
+// WriteCheckpoint saves a checkpoint to a file
+func WriteCheckpoint(path string, c *Checkpoint) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadCheckpoint loads a checkpoint from a file
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Checkpoint{}
+	if err := gob.NewDecoder(f).Decode(c); err != nil {
+		return nil, fmt.Errorf("reading checkpoint %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// LoadCheckpoint reads the checkpoint to restore and replaces the model of
+// the configuration with the one of the saved run. The options of how the
+// run is executed and reported, such as its output, are the ones already in
+// the configuration.
+func (c *Config) LoadCheckpoint(path string) (*Checkpoint, error) {
+	checkpoint, err := ReadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	given := *c
+	if err := json.Unmarshal(checkpoint.Config, c); err != nil {
+		return nil, fmt.Errorf("reading checkpoint %s: %w", path, err)
+	}
+	c.copyRunOptions(&given)
+	c.Replay = path
+	return checkpoint, nil
+}
+
+// copyRunOptions sets the options that are not part of the model to the ones
+// of another configuration
+func (c *Config) copyRunOptions(from *Config) {
+	options, fromOptions := c.runOptionFlags(), from.runOptionFlags()
+	options.VisitAll(func(f *flag.Flag) {
+		value := fromOptions.Lookup(f.Name).Value
+		reflect.ValueOf(f.Value).Elem().Set(reflect.ValueOf(value).Elem())
+	})
+}
+
+// runOptionFlags returns the flags of the options that are not part of the
+// model
+func (c *Config) runOptionFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("options", flag.ContinueOnError)
+	c.RegisterSweepFlags(fs)
+	c.RegisterRunFlags(fs)
+	c.RegisterOutputFlags(fs)
+	return fs
+}
+
+// CheckpointState returns the dead letters counted by reason
+func (s *DeadLetterSink) CheckpointState() interface{} {
+	return s.counts
+}
+
+// checkpointables returns the components saved in checkpoints
+func (s *Simulation) checkpointables() []Checkpointable {
+	var components []Checkpointable
+	for _, p := range s.producers {
+		components = append(components, p)
+	}
+	for _, d := range s.tree.Distributors() {
+		components = append(components, d)
+	}
+	components = append(components, s.deadLetters)
+	for _, c := range s.consumers {
+		components = append(components, c)
+	}
+	return components
+}
+
+// takeCheckpoint captures the state of the run before the given event
+func (s *Simulation) takeCheckpoint(next sim.Event, contents portContents) (*Checkpoint, error) {
+	config, err := json.Marshal(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{
+		Config:    config,
+		Time:      next.Time(),
+		NextEvent: describeEvent(next),
+		State:     s.takeSnapshot(),
+	}
+
+	// The counters of the run are saved along with the components
+	stats, err := json.Marshal(s.stats)
+	if err != nil {
+		return nil, err
+	}
+	c.Components = append(c.Components, ComponentCheckpoint{Name: "Stats", State: stats})
+	for _, component := range s.checkpointables() {
+		state, err := json.Marshal(component.CheckpointState())
+		if err != nil {
+			return nil, err
+		}
+		c.Components = append(c.Components, ComponentCheckpoint{Name: component.Name(), State: state})
+	}
+
+	for port, msgs := range contents {
+		if len(msgs) == 0 {
+			continue
+		}
+		pc := PortCheckpoint{Name: port.Name()}
+		for _, msg := range msgs {
+			pc.Messages = append(pc.Messages, describeMsg(msg))
+		}
+		c.Ports = append(c.Ports, pc)
+	}
+	sort.Slice(c.Ports, func(i, j int) bool { return c.Ports[i].Name < c.Ports[j].Name })
+	return c, nil
+}
+
+// checkpointHook takes a checkpoint after the last event before a time and
+// hands it to taken, which saves it
+type checkpointHook struct {
+	sim      *Simulation
+	at       sim.VTimeInSec
+	contents portContents
+	taken    func(c *Checkpoint) error
+	done     bool
+	err      error
+}
+
+func (s *Simulation) addCheckpointHook(at sim.VTimeInSec, taken func(c *Checkpoint) error) *checkpointHook {
+	h := &checkpointHook{
+		sim:      s,
+		at:       at,
+		contents: make(portContents),
+		taken:    taken,
+	}
+	_, ports := s.topology.portsByComponent()
+	for _, component := range ports {
+		for _, port := range component {
+			port.AcceptHook(h.contents)
+		}
+	}
+	s.engine.AcceptHook(h)
+	s.checkpoints = append(s.checkpoints, h)
+	return h
+}
+
+// Func takes the checkpoint after an event once the next one is at or after
+// its time. The hook is registered after the ones of the model, which have
+// handled the event by then.
+func (h *checkpointHook) Func(ctx sim.HookCtx) {
+	if ctx.Pos == sim.HookPosAfterEvent {
+		h.check()
+	}
+}
+
+// check takes the checkpoint if the next event is at or after its time
+func (h *checkpointHook) check() {
+	if h.done {
+		return
+	}
+	next := nextEvent(h.sim.engine)
+	if next == nil || next.Time() < h.at {
+		return
+	}
+
+	h.done = true
+	c, err := h.sim.takeCheckpoint(next, h.contents)
+	if err == nil {
+		err = h.taken(c)
+	}
+	h.err = err
+}
+
+// finish reports whether the checkpoint was taken successfully
+func (h *checkpointHook) finish() error {
+	if h.err != nil {
+		return h.err
+	}
+	if !h.done {
+		return fmt.Errorf("the run ended at %.2f before the checkpoint at %.2f",
+			float64(h.sim.Duration()), float64(h.at))
+	}
+	return nil
+}
+
+// nextEvent returns the event the engine handles next, or nil if none is
+// left. Akita's serial engine keeps its events in unexported queues, and the
+// engines of the run wrap it.
+func nextEvent(engine sim.Engine) sim.Event {
+	v := reflect.ValueOf(engine)
+	for {
+		if _, ok := engine.(*sim.SerialEngine); ok {
+			break
+		}
+		inner := v.Elem().FieldByName("Engine")
+		if !inner.IsValid() || inner.IsNil() {
+			return nil
+		}
+		engine = inner.Interface().(sim.Engine)
+		v = inner.Elem()
+	}
+
+	var next sim.Event
+	for _, name := range []string{"queue", "secondaryQueue"} {
+		queue := writable(v.Elem().FieldByName(name)).Interface().(sim.EventQueue)
+		if queue.Len() == 0 {
+			continue
+		}
+		// The primary queue goes first at the same time
+		if evt := queue.Peek(); next == nil || evt.Time() < next.Time() {
+			next = evt
+		}
+	}
+	return next
+}
+
+// SaveCheckpoint writes the state of the run to a file after its last event
+// before the given time
+func (s *Simulation) SaveCheckpoint(path string, at sim.VTimeInSec) {
+	s.addCheckpointHook(at, func(c *Checkpoint) error {
+		if err := WriteCheckpoint(path, c); err != nil {
+			return err
+		}
+		s.out.Printf("[%.2f] Checkpoint written to %s\n", float64(c.Time), path)
+		return nil
+	})
+}
+
+// Restore resumes a run from a checkpoint. The simulation must be built from
+// the configuration of the checkpoint and not have run yet; its state is
+// replaced by the saved one, and Run goes on with the pending events of the
+// saved run instead of starting the components.
+func (s *Simulation) Restore(saved *Checkpoint) error {
+	if saved.State == nil {
+		return fmt.Errorf("checkpoint %s holds no state to restore", s.cfg.Replay)
+	}
+	model := DefaultConfig()
+	if err := json.Unmarshal(saved.Config, model); err != nil {
+		return fmt.Errorf("reading checkpoint %s: %w", s.cfg.Replay, err)
+	}
+	model.copyRunOptions(s.cfg)
+	savedModel, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+	runModel, err := json.Marshal(s.cfg)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(savedModel, runModel) {
+		return fmt.Errorf("checkpoint %s was saved by a run of another model", s.cfg.Replay)
+	}
+	for _, h := range s.checkpoints {
+		if h.at < saved.Time {
+			return fmt.Errorf("the checkpoint at %.2f is before the restored one at %.2f",
+				float64(h.at), float64(saved.Time))
+		}
+	}
+	if err := s.restoreSnapshot(saved.State); err != nil {
+		return fmt.Errorf("restoring checkpoint %s: %w", s.cfg.Replay, err)
+	}
+	s.restored = true
+	s.out.Printf("[%.2f] Restored checkpoint %s\n", float64(saved.Time), s.cfg.Replay)
+	return nil
+}
@@ -0,0 +1,167 @@
+package demo
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// writeCheckpoint saves the checkpoint of a silent run of cfg at the given
+// time to a file and returns its path
+func writeCheckpoint(t *testing.T, cfg *Config, at sim.VTimeInSec) string {
+	path := filepath.Join(t.TempDir(), "checkpoint.bin")
+	if err := WriteCheckpoint(path, saveCheckpoint(t, cfg, at)); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// restoreRun runs cfg silently, saving a checkpoint at the given time, then
+// restores the checkpoint into a new simulation that writes to sink and runs
+// it to the end
+func restoreRun(t *testing.T, cfg *Config, at sim.VTimeInSec, sink EventSink) (saved, restored *Simulation, checkpoint *Checkpoint) {
+	path := filepath.Join(t.TempDir(), "checkpoint.bin")
+	silence(t, cfg)
+	saved, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.SaveCheckpoint(path, at)
+	if err := saved.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	restoreCfg := DefaultConfig()
+	restoreCfg.Sink = sink
+	checkpoint, err = restoreCfg.LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err = NewSimulation(restoreCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Restore(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return saved, restored, checkpoint
+}
+
+// checkSameEnd fails the test unless the restored run ended like the saved one
+func checkSameEnd(t *testing.T, saved, restored *Simulation) {
+	if restored.Duration() != saved.Duration() || restored.Conservation() != saved.Conservation() ||
+		restored.stats.EngineEvents != saved.stats.EngineEvents || restored.stats.MeanLatency() != saved.stats.MeanLatency() {
+		t.Errorf("Expected the restored run to end like the saved one, got %+v and %+v",
+			restored.Conservation(), saved.Conservation())
+	}
+}
+
+// TestRestoreContinuesCheckpointedRun verifies that a run restored from a
+// checkpoint goes on from the saved state, without simulating the events
+// before it again, and ends like the run that saved it
+func TestRestoreContinuesCheckpointedRun(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "restored.log")
+	sink, err := NewFileSink(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Seed = 3
+	cfg.Cycles = 200
+	cfg.ConsumeInterval = 10
+	saved, restored, checkpoint := restoreRun(t, cfg, 80, sink)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(checkpoint.Ports) == 0 {
+		t.Error("Expected messages queued in the ports of a congested run")
+	}
+	checkSameEnd(t, saved, restored)
+	log, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(log), "\n") {
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		now, err := strconv.ParseFloat(line[1:strings.Index(line, "]")], 64)
+		if err == nil && sim.VTimeInSec(now) < checkpoint.Time {
+			t.Fatalf("Expected the restored run to start at %.2f, got %q", float64(checkpoint.Time), line)
+		}
+	}
+}
+
+// TestRestoreContinuesRandomDraws verifies that the random number generators
+// of the models are restored with the rest of the state
+func TestRestoreContinuesRandomDraws(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 7
+	cfg.Cycles = 100
+	cfg.ServiceDist = "exponential"
+	cfg.FaultLoss = 0.1
+	cfg.RetransmitTimeout = 5
+	saved, restored, _ := restoreRun(t, cfg, 50, NullSink{})
+	checkSameEnd(t, saved, restored)
+}
+
+// TestLoadCheckpointKeepsRunOptions verifies that a restored run takes the
+// model from the checkpoint and its output and run options from its own
+// configuration
+func TestLoadCheckpointKeepsRunOptions(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Seed = 5
+	cfg.Consumers = 4
+	cfg.Output = "null"
+	cfg.QueueSampleFile = filepath.Join(dir, "samples.csv")
+	path := writeCheckpoint(t, cfg, 10)
+	
+	restoreCfg := DefaultConfig()
+	restoreCfg.MetricsOut = filepath.Join(dir, "metrics.json")
+	if _, err := restoreCfg.LoadCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	if restoreCfg.Seed != 5 || restoreCfg.Consumers != 4 {
+		t.Errorf("Expected the model of the checkpoint, got seed %d and %d consumers", restoreCfg.Seed, restoreCfg.Consumers)
+	}
+	if restoreCfg.Output != "console" || restoreCfg.QueueSampleFile != "" || restoreCfg.Checkpoint != "" {
+		t.Errorf("Expected the output and run options of the saved run to be dropped, got %+v", restoreCfg)
+	}
+	if restoreCfg.MetricsOut == "" || restoreCfg.Replay != path {
+		t.Errorf("Expected the options of the restored run to be kept, got %+v", restoreCfg)
+	}
+}
+
+// TestRestoreNeedsTheSavedModel verifies that a checkpoint is not restored
+// into a simulation of a different model
+func TestRestoreNeedsTheSavedModel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	path := writeCheckpoint(t, cfg, 40)
+	
+	restoreCfg := DefaultConfig()
+	silence(t, restoreCfg)
+	checkpoint, err := restoreCfg.LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreCfg.Seed = 2
+	restored, err := NewSimulation(restoreCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restored.Restore(checkpoint)
+	if err == nil || !strings.Contains(err.Error(), "another model") {
+		t.Errorf("Expected the checkpoint of another model to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// StateDiff is a value of the state that differs between two checkpoints
+type StateDiff struct {
+	Path  string // Component or port followed by the field, e.g. Consumer3.Queues[0].Consumed
+	Left  string // Value in the first checkpoint, "-" if it has none
+	Right string // Value in the second checkpoint, "-" if it has none
+}
+
+// DiffCheckpoints compares two checkpoints field by field: the
+// configurations, the time and the next event, the state of every
+// component, and the messages queued in every port. Checkpoints of the same
+// run taken at the same time differ only where the run behaved differently.
+func DiffCheckpoints(a, b *Checkpoint) ([]StateDiff, error) {
+	var diffs []StateDiff
+	add := func(path string, left, right interface{}) {
+		diffValues(path, left, right, &diffs)
+	}
+
+	// The files the checkpoints were saved to or replayed from do not
+	// change the run
+	var configs [2]map[string]interface{}
+	for i, c := range []*Checkpoint{a, b} {
+		if err := json.Unmarshal(c.Config, &configs[i]); err != nil {
+			return nil, fmt.Errorf("reading the configuration: %w", err)
+		}
+		delete(configs[i], "checkpoint")
+		delete(configs[i], "replay")
+	}
+	add("Config", configs[0], configs[1])
+	add("Time", float64(a.Time), float64(b.Time))
+	add("NextEvent", a.NextEvent, b.NextEvent)
+
+	// Components are matched by name, in the order of the first checkpoint
+	states := make([]map[string]interface{}, 2)
+	for i, c := range []*Checkpoint{a, b} {
+		states[i] = make(map[string]interface{})
+		for _, component := range c.Components {
+			var state interface{}
+			if err := json.Unmarshal(component.State, &state); err != nil {
+				return nil, fmt.Errorf("reading the state of %s: %w", component.Name, err)
+			}
+			states[i][component.Name] = state
+		}
+	}
+	var names []string
+	for _, component := range a.Components {
+		names = append(names, component.Name)
+	}
+	for _, component := range b.Components {
+		if _, ok := states[0][component.Name]; !ok {
+			names = append(names, component.Name)
+		}
+	}
+	for _, name := range names {
+		add(name, states[0][name], states[1][name])
+	}
+
+	// Queued messages are compared position by position, a port missing
+	// from a checkpoint was empty
+	queues := make([]map[string][]interface{}, 2)
+	ports := make(map[string]interface{})
+	for i, c := range []*Checkpoint{a, b} {
+		queues[i] = make(map[string][]interface{})
+		for _, port := range c.Ports {
+			for _, msg := range port.Messages {
+				queues[i][port.Name] = append(queues[i][port.Name], msg)
+			}
+			ports[port.Name] = nil
+		}
+	}
+	for _, name := range sortedKeys(ports, nil) {
+		add(name, queues[0][name], queues[1][name])
+	}
+	return diffs, nil
+}
+
+// diffValues appends the differences between two values decoded from JSON.
+// Objects are compared key by key and arrays element by element, so that
+// every difference is reported at the innermost field.
+func diffValues(path string, left, right interface{}, diffs *[]StateDiff) {
+	switch l := left.(type) {
+	case map[string]interface{}:
+		if r, ok := right.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(l, r) {
+				diffValues(path+"."+key, l[key], r[key], diffs)
+			}
+			return
+		}
+	case []interface{}:
+		if r, ok := right.([]interface{}); ok {
+			for i := 0; i < len(l) || i < len(r); i++ {
+				var le, re interface{}
+				if i < len(l) {
+					le = l[i]
+				}
+				if i < len(r) {
+					re = r[i]
+				}
+				diffValues(fmt.Sprintf("%s[%d]", path, i), le, re, diffs)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(left, right) {
+		*diffs = append(*diffs, StateDiff{Path: path, Left: formatValue(left), Right: formatValue(right)})
+	}
+}
+
+// formatValue writes a value decoded from JSON, "-" for a missing one
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return v
+	}
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
+
+func sortedKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// PrintCheckpointDiff writes the differences between the checkpoints saved
+// in two files, after the components they were found in
+func PrintCheckpointDiff(out EventSink, pathA, pathB string, a, b *Checkpoint, diffs []StateDiff) {
+	out.Println("=== Checkpoint Diff ===")
+	out.Printf("A: %s (%.2f, before %s)\n", pathA, float64(a.Time), a.NextEvent)
+	out.Printf("B: %s (%.2f, before %s)\n", pathB, float64(b.Time), b.NextEvent)
+	out.Printf("Differences:       %d\n", len(diffs))
+	if len(diffs) == 0 {
+		out.Println("The checkpoints describe the same state")
+		return
+	}
+
+	// The component a field or port belongs to is the first part of its path
+	var owners []string
+	seen := make(map[string]bool)
+	width, leftWidth := len("Path"), 1
+	for _, d := range diffs {
+		owner := d.Path
+		if i := strings.IndexAny(owner, ".["); i >= 0 {
+			owner = owner[:i]
+		}
+		if !seen[owner] {
+			seen[owner] = true
+			owners = append(owners, owner)
+		}
+		if len(d.Path) > width {
+			width = len(d.Path)
+		}
+		if len(d.Left) > leftWidth {
+			leftWidth = len(d.Left)
+		}
+	}
+	out.Printf("Diverged in:       %s\n", strings.Join(owners, ", "))
+	out.Printf("%-*s  %-*s  %s\n", width, "Path", leftWidth, "A", "B")
+	for _, d := range diffs {
+		out.Printf("%-*s  %-*s  %s\n", width, d.Path, leftWidth, d.Left, d.Right)
+	}
+}
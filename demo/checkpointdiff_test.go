@@ -0,0 +1,81 @@
+package demo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// saveCheckpoint runs a simulation of cfg silently and returns the
+// checkpoint it saved at the given time
+func saveCheckpoint(t *testing.T, cfg *Config, at sim.VTimeInSec) *Checkpoint {
+	path := filepath.Join(t.TempDir(), "checkpoint.bin")
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.SaveCheckpoint(path, at)
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	checkpoint, err := ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checkpoint
+}
+
+// TestDiffCheckpointsOfSameRun verifies that the checkpoints of two runs
+// with the same seed describe the same state
+func TestDiffCheckpointsOfSameRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 40
+	a := saveCheckpoint(t, cfg, 20)
+	b := saveCheckpoint(t, cfg, 20)
+	
+	diffs, err := DiffCheckpoints(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("Expected no differences, got %+v", diffs)
+	}
+}
+
+// TestDiffCheckpointsPinpointsFields verifies that the checkpoints of runs
+// with different consume intervals differ in the fields of the consumers and
+// the queued messages, and not in the fields both runs agree on
+func TestDiffCheckpointsPinpointsFields(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 40
+	a := saveCheckpoint(t, cfg, 20)
+	slow := *cfg
+	slow.ConsumeInterval = 5
+	b := saveCheckpoint(t, &slow, 20)
+	
+	diffs, err := DiffCheckpoints(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]StateDiff)
+	for _, d := range diffs {
+		found[d.Path] = d
+	}
+	if d, ok := found["Config.consume_interval"]; !ok || d.Left != "1" || d.Right != "5" {
+		t.Errorf("Expected the consume intervals 1 and 5, got %+v", d)
+	}
+	if d, ok := found["Consumer3.Queues[0].Consumed"]; !ok || d.Left != "3" || d.Right != "2" {
+		t.Errorf("Expected the slow Consumer3 to have consumed 2 messages instead of 3, got %+v", d)
+	}
+	if _, ok := found["Config.seed"]; ok {
+		t.Error("Expected the seeds not to differ")
+	}
+	if _, ok := found["Consumer3.In[1]"]; !ok {
+		t.Errorf("Expected a message queued at the slow Consumer3 only, got %+v", diffs)
+	}
+}
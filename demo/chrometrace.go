@@ -0,0 +1,143 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// chromeEvent is an event of the Chrome trace_event format
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	ID    string                 `json:"id,omitempty"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// ChromeTrace records the journey of every message as a Chrome trace, with
+// one track (process) per component: an instant when the producer sends the message,
+// and a span from its arrival at the distributor and at its consumer until it
+// is taken out of the queue. Spans are async slices, since messages queued
+// together overlap. The trace opens in chrome://tracing and Perfetto.
+type ChromeTrace struct {
+	timeTeller sim.TimeTeller
+	tracks     map[sim.Port]int
+	names      []string
+	events     []chromeEvent
+}
+
+// NewChromeTrace creates an empty trace
+func NewChromeTrace(timeTeller sim.TimeTeller) *ChromeTrace {
+	return &ChromeTrace{
+		timeTeller: timeTeller,
+		tracks:     make(map[sim.Port]int),
+	}
+}
+
+// Track records the messages of a port on the track with the given name.
+// Ports sharing a name share a track.
+func (t *ChromeTrace) Track(port sim.Port, name string) {
+	pid := -1
+	for i, n := range t.names {
+		if n == name {
+			pid = i + 1
+		}
+	}
+	if pid < 0 {
+		t.names = append(t.names, name)
+		pid = len(t.names)
+	}
+	t.tracks[port] = pid
+	port.AcceptHook(t)
+}
+
+// Func records sends as instants and the arrivals and retrievals as the
+// begin and end of spans
+func (t *ChromeTrace) Func(ctx sim.HookCtx) {
+	port, ok := ctx.Domain.(sim.Port)
+	if !ok {
+		return
+	}
+	m, ok := ctx.Item.(*msg.DemoMessage)
+	if !ok {
+		return
+	}
+	now := t.timeTeller.CurrentTime()
+
+	switch ctx.Pos {
+	case sim.HookPosPortMsgSend:
+		t.events = append(t.events, chromeEvent{
+			Name:  fmt.Sprintf("produce #%d", m.ID),
+			Cat:   "message",
+			Ph:    "i",
+			Ts:    micros(now),
+			Pid:   t.tracks[port],
+			Scope: "p",
+			Args:  map[string]interface{}{"destination": m.Destination},
+		})
+	case sim.HookPosPortMsgRecvd:
+		t.events = append(t.events, t.span("b", port, m, now))
+	case sim.HookPosPortMsgRetrieve:
+		t.events = append(t.events, t.span("e", port, m, now))
+	}
+}
+
+// span returns the begin or end event of the async slice of a message at a
+// port
+func (t *ChromeTrace) span(ph string, port sim.Port, m *msg.DemoMessage, now sim.VTimeInSec) chromeEvent {
+	return chromeEvent{
+		Name: fmt.Sprintf("#%d", m.ID),
+		Cat:  "message",
+		Ph:   ph,
+		Ts:   micros(now),
+		Pid:  t.tracks[port],
+		ID:   fmt.Sprintf("%s#%d", port.Name(), m.ID),
+		Args: map[string]interface{}{"port": port.Name(), "destination": m.Destination},
+	}
+}
+
+func micros(t sim.VTimeInSec) float64 {
+	return float64(t) * 1e6
+}
+
+// WriteTrace writes the trace as trace_event JSON
+func (t *ChromeTrace) WriteTrace(w io.Writer) error {
+	events := make([]chromeEvent, 0, len(t.names)+len(t.events))
+	for i, name := range t.names {
+		events = append(events, chromeEvent{
+			Name: "process_name",
+			Ph:   "M",
+			Pid:  i + 1,
+			Args: map[string]interface{}{"name": name},
+		})
+	}
+	sorted := append([]chromeEvent(nil), t.events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Ts < sorted[j].Ts })
+	events = append(events, sorted...)
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+// ExportTrace writes the trace to a JSON file
+func (t *ChromeTrace) ExportTrace(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.WriteTrace(f)
+}
@@ -0,0 +1,53 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestChromeTraceRecordsSpans verifies that a message queued at a port shows
+// up as a span from its arrival to its retrieval on the port's track
+func TestChromeTraceRecordsSpans(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	clock := &fixedTime{}
+	c := consumer.New("Consumer1", engine, 1.0)
+	trace := NewChromeTrace(clock)
+	trace.Track(c.InputPort(), "Consumer1")
+	
+	clock.now = 2
+	m := &msg.DemoMessage{ID: 7, Destination: "Consumer1"}
+	m.Meta().Dst = c.InputPort()
+	c.InputPort().Recv(m)
+	clock.now = 5
+	c.InputPort().Retrieve(5)
+	
+	var buf bytes.Buffer
+	if err := trace.WriteTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		TraceEvents []chromeEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	
+	if len(out.TraceEvents) != 3 {
+		t.Fatalf("Expected a track name and two span events, got %+v", out.TraceEvents)
+	}
+	track, begin, end := out.TraceEvents[0], out.TraceEvents[1], out.TraceEvents[2]
+	if track.Ph != "M" || track.Args["name"] != "Consumer1" {
+		t.Errorf("Unexpected track metadata %+v", track)
+	}
+	if begin.Ph != "b" || begin.Ts != 2e6 || end.Ph != "e" || end.Ts != 5e6 {
+		t.Errorf("Expected a span from 2 s to 5 s, got %+v and %+v", begin, end)
+	}
+	if begin.ID != end.ID || begin.Pid != track.Pid || begin.Name != "#7" {
+		t.Errorf("Expected the span of #7 on the Consumer1 track, got %+v and %+v", begin, end)
+	}
+}
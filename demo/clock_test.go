@@ -0,0 +1,97 @@
+package demo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// tickRecorder records the times at which every component ticked
+type tickRecorder map[string][]sim.VTimeInSec
+
+func (r tickRecorder) Func(ctx sim.HookCtx) {
+	if ctx.Pos != sim.HookPosBeforeEvent {
+		return
+	}
+	evt, ok := ctx.Item.(sim.TickEvent)
+	if !ok {
+		return
+	}
+	if named, ok := evt.Handler().(sim.Named); ok {
+		r[named.Name()] = append(r[named.Name()], evt.Time())
+	}
+}
+
+// TestComponentsTickOnTheirClocks verifies that every component ticks on
+// the edges of its own clock
+func TestComponentsTickOnTheirClocks(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 3
+	cfg.Cycles = 40
+	cfg.DistributorFreq = 4
+	cfg.ConsumerFreq = 3
+	cfg.Frequencies = map[string]float64{"Consumer3": 0.5}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticks := make(tickRecorder)
+	simulation.engine.AcceptHook(ticks)
+	
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	for name, freq := range map[string]float64{"Producer": 1, "Distributor": 4, "Consumer1": 3, "Consumer3": 0.5} {
+		if len(ticks[name]) == 0 {
+			t.Errorf("Expected %s to tick", name)
+		}
+		for _, at := range ticks[name] {
+			cycles := float64(at) * freq
+			if math.Abs(cycles-math.Round(cycles)) > 1e-6 {
+				t.Errorf("Expected %s to tick at multiples of %g s, got a tick at %v", name, 1/freq, at)
+				break
+			}
+		}
+	}
+}
+
+// TestMismatchedClocksConserveMessages verifies that runs whose components
+// tick at different frequencies consume every message once and in order
+func TestMismatchedClocksConserveMessages(t *testing.T) {
+	for _, clocks := range []struct {
+		producer, distributor, consumer float64
+	}{
+		{1, 4, 2},
+		{2, 0.5, 1},
+		{1, 3, 0.25},
+		{3, 7, 5},
+	} {
+		cfg := DefaultConfig()
+		cfg.Seed = 4
+		cfg.Cycles = 60
+		cfg.ProducerFreq = clocks.producer
+		cfg.DistributorFreq = clocks.distributor
+		cfg.ConsumerFreq = clocks.consumer
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		
+		err = simulation.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		
+		c := simulation.Conservation()
+		if !c.Holds() || c.Produced == 0 || c.Consumed != c.Produced {
+			t.Errorf("Expected every message consumed at %+v, got %+v", clocks, c)
+		}
+		if v := simulation.verifier; v.Duplicates > 0 || v.Reordered > 0 {
+			t.Errorf("Expected no duplicates or reordering at %+v, got %d and %d", clocks, v.Duplicates, v.Reordered)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package demo
+
+import (
+	"fmt"
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Interruptible is implemented by components that can be woken up by an
+// interrupt-style notification instead of by every message arrival
+type Interruptible interface {
+	Interrupt(now sim.VTimeInSec)
+}
+
+// Coalescer implements interrupt coalescing for the Distributor. Rather than
+// waking a consumer for every routed message, the consumer is notified once K
+// messages are pending or once the oldest pending message has waited T
+// seconds, whichever happens first.
+type Coalescer struct {
+	engine     sim.Engine
+	maxCount   int            // K, 0 means only the timer triggers notifications
+	maxDelay   sim.VTimeInSec // T
+	targets    map[string]Interruptible
+	pending    map[string]int
+	generation map[string]int // Invalidates timers of already notified batches
+
+	Interrupts int
+}
+
+// NewCoalescer creates a coalescer that notifies after maxCount messages or
+// maxDelay seconds
+func NewCoalescer(engine sim.Engine, maxCount int, maxDelay sim.VTimeInSec) *Coalescer {
+	return &Coalescer{
+		engine:     engine,
+		maxCount:   maxCount,
+		maxDelay:   maxDelay,
+		targets:    make(map[string]Interruptible),
+		pending:    make(map[string]int),
+		generation: make(map[string]int),
+	}
+}
+
+// String describes when the batched notifications are sent
+func (c *Coalescer) String() string {
+	return fmt.Sprintf("%d messages or %.2f s", c.maxCount, float64(c.maxDelay))
+}
+
+// AddTarget registers the component to notify for a destination
+func (c *Coalescer) AddTarget(dest string, target Interruptible) {
+	c.targets[dest] = target
+}
+
+// MessageRouted records a message sent to dest and notifies the destination
+// if the batch is complete
+func (c *Coalescer) MessageRouted(now sim.VTimeInSec, dest string) {
+	c.pending[dest]++
+
+	if c.maxCount > 0 && c.pending[dest] >= c.maxCount {
+		c.notify(now, dest)
+		return
+	}
+
+	if c.pending[dest] == 1 {
+		evt := &coalesceTimerEvent{
+			EventBase:  sim.NewEventBase(now+c.maxDelay, c),
+			dest:       dest,
+			generation: c.generation[dest],
+		}
+		c.engine.Schedule(evt)
+	}
+}
+
+// Handle fires the coalescing timer of a batch
+func (c *Coalescer) Handle(e sim.Event) error {
+	evt := e.(*coalesceTimerEvent)
+	if evt.generation == c.generation[evt.dest] && c.pending[evt.dest] > 0 {
+		c.notify(evt.Time(), evt.dest)
+	}
+	return nil
+}
+
+func (c *Coalescer) notify(now sim.VTimeInSec, dest string) {
+	c.pending[dest] = 0
+	c.generation[dest]++
+	c.Interrupts++
+
+	if target, ok := c.targets[dest]; ok {
+		target.Interrupt(now)
+	}
+}
+
+type coalesceTimerEvent struct {
+	*sim.EventBase
+	dest       string
+	generation int
+}
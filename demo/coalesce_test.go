@@ -0,0 +1,57 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+type interruptRecorder struct {
+	times []sim.VTimeInSec
+}
+
+func (r *interruptRecorder) Interrupt(now sim.VTimeInSec) {
+	r.times = append(r.times, now)
+}
+
+// TestCoalescerNotifiesAfterCount verifies that a full batch of K messages
+// triggers a notification immediately
+func TestCoalescerNotifiesAfterCount(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	coalescer := NewCoalescer(engine, 3, 10)
+	recorder := &interruptRecorder{}
+	coalescer.AddTarget("Consumer1", recorder)
+	
+	coalescer.MessageRouted(1, "Consumer1")
+	coalescer.MessageRouted(2, "Consumer1")
+	if len(recorder.times) != 0 {
+		t.Fatalf("Expected no notification before the batch is full, got %v", recorder.times)
+	}
+	
+	coalescer.MessageRouted(3, "Consumer1")
+	if len(recorder.times) != 1 || recorder.times[0] != 3 {
+		t.Errorf("Expected a notification at time 3, got %v", recorder.times)
+	}
+	
+	// The timer of the completed batch must not fire a second notification
+	engine.Run()
+	if len(recorder.times) != 1 {
+		t.Errorf("Expected the stale timer to be ignored, got %v", recorder.times)
+	}
+}
+
+// TestCoalescerNotifiesAfterTimeout verifies that a partial batch is
+// delivered once the oldest message has waited T seconds
+func TestCoalescerNotifiesAfterTimeout(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	coalescer := NewCoalescer(engine, 3, 5)
+	recorder := &interruptRecorder{}
+	coalescer.AddTarget("Consumer1", recorder)
+	
+	coalescer.MessageRouted(1, "Consumer1")
+	engine.Run()
+	
+	if len(recorder.times) != 1 || recorder.times[0] != 6 {
+		t.Errorf("Expected a notification at time 6, got %v", recorder.times)
+	}
+}
@@ -0,0 +1,237 @@
+package demo
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+// Size and margins of the CDF chart, in pixels
+const (
+	chartWidth  = 640
+	chartHeight = 360
+	chartMargin = 48
+)
+
+// chartColors are the colors of the runs in the chart, repeated if there
+// are more runs
+var chartColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b"}
+
+// comparisonMetric is a row of the delta table
+type comparisonMetric struct {
+	name          string
+	unit          string
+	lowerIsBetter bool
+	value         func(r ScenarioResult) float64
+}
+
+var comparisonMetrics = []comparisonMetric{
+	{"Produced", "", false, func(r ScenarioResult) float64 { return float64(r.Produced) }},
+	{"Consumed", "", false, func(r ScenarioResult) float64 { return float64(r.Consumed) }},
+	{"Mean latency", "s", true, func(r ScenarioResult) float64 { return r.MeanLatency }},
+	{"p99 latency", "s", true, func(r ScenarioResult) float64 { return r.P99Latency }},
+	{"Completion", "s", true, func(r ScenarioResult) float64 { return float64(r.Completion) }},
+}
+
+type comparisonCell struct {
+	Value string
+	Delta string // Change relative to the first run, empty for the first run
+	Class string // better, worse, or empty
+}
+
+type comparisonRow struct {
+	Name  string
+	Cells []comparisonCell
+}
+
+type comparisonSeries struct {
+	Name   string
+	Color  string
+	Points string // Polyline of the CDF in chart coordinates
+}
+
+type comparisonTick struct {
+	X, Y  float64
+	Label string
+}
+
+type comparisonPage struct {
+	Title  string
+	Runs   []string
+	Rows   []comparisonRow
+	Series []comparisonSeries
+	XTicks []comparisonTick
+	YTicks []comparisonTick
+	Width  int
+	Height int
+	Left   int
+	Right  int
+	Top    int
+	Bottom int
+}
+
+var comparisonTemplate = template.Must(template.New("compare").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.delta { color: #666; font-size: 90%; }
+.better .delta { color: #2ca02c; }
+.worse .delta { color: #d62728; }
+svg text { font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>Metrics</h2>
+<table>
+<tr><th>Metric</th>{{range .Runs}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Name}}</td>{{range .Cells}}<td class="{{.Class}}">{{.Value}}{{if .Delta}} <span class="delta">{{.Delta}}</span>{{end}}</td>{{end}}</tr>
+{{end}}</table>
+<h2>Latency CDF</h2>
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+<line x1="{{.Left}}" y1="{{.Bottom}}" x2="{{.Right}}" y2="{{.Bottom}}" stroke="black"/>
+<line x1="{{.Left}}" y1="{{.Top}}" x2="{{.Left}}" y2="{{.Bottom}}" stroke="black"/>
+{{range .XTicks}}<text x="{{.X}}" y="{{.Y}}" text-anchor="middle">{{.Label}}</text>
+{{end}}{{range .YTicks}}<text x="{{.X}}" y="{{.Y}}" text-anchor="end">{{.Label}}</text>
+{{end}}<text x="{{.Right}}" y="{{.Height}}" text-anchor="end">Latency (s)</text>
+{{range $i, $s := .Series}}<polyline fill="none" stroke="{{$s.Color}}" stroke-width="2" points="{{$s.Points}}"/>
+<text x="{{$.Left}}" y="{{$.Top}}" dx="1em" dy="{{$i}}em" fill="{{$s.Color}}">{{$s.Name}}</text>
+{{end}}</svg>
+</body>
+</html>
+`))
+
+// WriteComparisonHTML writes the results of a comparison scenario as an HTML
+// page with a table of the metrics of every run, their change relative to
+// the first run, and the latency CDFs of all runs in one chart
+func WriteComparisonHTML(w io.Writer, title string, results []ScenarioResult) error {
+	page := comparisonPage{
+		Title:  title,
+		Width:  chartWidth,
+		Height: chartHeight,
+		Left:   chartMargin,
+		Right:  chartWidth - chartMargin/2,
+		Top:    chartMargin / 2,
+		Bottom: chartHeight - chartMargin,
+	}
+	for _, r := range results {
+		page.Runs = append(page.Runs, r.Name)
+	}
+	for _, m := range comparisonMetrics {
+		page.Rows = append(page.Rows, m.row(results))
+	}
+
+	maxLatency := 0.0
+	for _, r := range results {
+		if n := len(r.Latencies); n > 0 && r.Latencies[n-1] > maxLatency {
+			maxLatency = r.Latencies[n-1]
+		}
+	}
+	if maxLatency == 0 {
+		maxLatency = 1
+	}
+	x := func(latency float64) float64 {
+		return float64(page.Left) + latency/maxLatency*float64(page.Right-page.Left)
+	}
+	y := func(fraction float64) float64 {
+		return float64(page.Bottom) - fraction*float64(page.Bottom-page.Top)
+	}
+	for i := 0; i <= 4; i++ {
+		latency := maxLatency * float64(i) / 4
+		page.XTicks = append(page.XTicks, comparisonTick{
+			X: x(latency), Y: float64(page.Bottom + 16), Label: fmt.Sprintf("%.2f", latency),
+		})
+		fraction := float64(i) / 4
+		page.YTicks = append(page.YTicks, comparisonTick{
+			X: float64(page.Left - 6), Y: y(fraction) + 4, Label: fmt.Sprintf("%.2f", fraction),
+		})
+	}
+
+	for i, r := range results {
+		// Steps up at every latency, starting from no messages at 0
+		var points strings.Builder
+		fmt.Fprintf(&points, "%.1f,%.1f", x(0), y(0))
+		previous := 0.0
+		for j, latency := range r.Latencies {
+			fmt.Fprintf(&points, " %.1f,%.1f %.1f,%.1f",
+				x(latency), y(previous), x(latency), y(r.Fractions[j]))
+			previous = r.Fractions[j]
+		}
+		page.Series = append(page.Series, comparisonSeries{
+			Name:   r.Name,
+			Color:  chartColors[i%len(chartColors)],
+			Points: points.String(),
+		})
+	}
+
+	return comparisonTemplate.Execute(w, page)
+}
+
+// row formats the metric of every run and its change relative to the first
+func (m comparisonMetric) row(results []ScenarioResult) comparisonRow {
+	row := comparisonRow{Name: m.name}
+	for i, r := range results {
+		v := m.value(r)
+		cell := comparisonCell{Value: formatMetric(v, m.unit)}
+		if i > 0 {
+			base := m.value(results[0])
+			diff := v - base
+			cell.Delta = formatMetric(diff, m.unit)
+			if diff >= 0 {
+				cell.Delta = "+" + cell.Delta
+			}
+			if base != 0 {
+				cell.Delta += fmt.Sprintf(", %+.1f%%", diff/base*100)
+			}
+			cell.Delta = "(" + cell.Delta + ")"
+			if diff != 0 && (diff < 0) == m.lowerIsBetter {
+				cell.Class = "better"
+			} else if diff != 0 {
+				cell.Class = "worse"
+			}
+		}
+		row.Cells = append(row.Cells, cell)
+	}
+	return row
+}
+
+func formatMetric(v float64, unit string) string {
+	if unit == "" {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2f %s", v, unit)
+}
+
+// ExportComparisonHTML writes the HTML comparison report to a file
+func ExportComparisonHTML(path, title string, results []ScenarioResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return WriteComparisonHTML(f, title, results)
+}
+
+// ReportComparison prints the results of a scenario and writes them as an
+// HTML report if one is requested
+func ReportComparison(cfg *Config, title string, results []ScenarioResult) error {
+	out := cfg.Out()
+	PrintScenarioComparison(out, title, results)
+	if cfg.CompareHTML == "" {
+		return nil
+	}
+	if err := ExportComparisonHTML(cfg.CompareHTML, title, results); err != nil {
+		return err
+	}
+	out.Printf("Comparison report written to %s\n", cfg.CompareHTML)
+	return nil
+}
@@ -0,0 +1,38 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestWriteComparisonHTMLShowsDeltasAndCDFs verifies that the report shows
+// the change of every metric relative to the first run and one CDF per run
+func TestWriteComparisonHTMLShowsDeltasAndCDFs(t *testing.T) {
+	results := []ScenarioResult{
+		{Name: "base", Produced: 10, Consumed: 10, MeanLatency: 2, P99Latency: 4, Completion: 20,
+			Latencies: []float64{1, 4}, Fractions: []float64{0.5, 1}},
+		{Name: "faster", Produced: 10, Consumed: 10, MeanLatency: 1.5, P99Latency: 2, Completion: 18,
+			Latencies: []float64{1, 2}, Fractions: []float64{0.5, 1}},
+	}
+	
+	var w strings.Builder
+	if err := WriteComparisonHTML(&w, "Policies <A/B>", results); err != nil {
+		t.Fatal(err)
+	}
+	page := w.String()
+	
+	for _, want := range []string{
+		"<title>Policies &lt;A/B&gt;</title>",
+		`<td class="better">1.50 s <span class="delta">(-0.50 s, -25.0%)</span></td>`,
+		`<td class="better">18.00 s <span class="delta">(-2.00 s, -10.0%)</span></td>`,
+		// The longest latency spans the chart
+		`points="48.0,312.0 190.0,312.0 190.0,168.0 616.0,168.0 616.0,24.0"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected %s in the report, got:\n%s", want, page)
+		}
+	}
+	if n := strings.Count(page, "<polyline"); n != 2 {
+		t.Errorf("Expected a CDF per run, got %d", n)
+	}
+}
@@ -0,0 +1,1427 @@
+package demo
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// Config holds all the parameters of a simulation run. It can be loaded from
+// a JSON file and every field can be overridden with a command-line flag.
+type Config struct {
+	Cycles          int     `json:"cycles"`
+	Seed            int64   `json:"seed"`
+	Engine          string  `json:"engine"`
+	Traffic         string  `json:"traffic"`
+	ArrivalRate     float64 `json:"arrival_rate"`
+	BurstLength     float64 `json:"burst_length"`
+	BurstRate       float64 `json:"burst_rate"`
+	IdlePeriod      float64 `json:"idle_period"`
+	ConsumeInterval float64 `json:"consume_interval"`
+	Consumers       int     `json:"consumers"`
+	RxQueues        int     `json:"rx_queues"`
+	Flows           int     `json:"flows"`
+	TraceFile       string  `json:"trace_file"`
+	TraceWindow     int     `json:"trace_window"`
+	CoalesceCount   int     `json:"coalesce_count"`
+	CoalesceTime    float64 `json:"coalesce_time"`
+	ConsumerMode    string  `json:"consumer_mode"`
+	BatchSize       int     `json:"batch_size"`
+	Scenario        string  `json:"scenario"`
+	CompareHTML     string  `json:"compare_html"`
+	MaxInFlight     int     `json:"max_in_flight"`
+	TTL             float64 `json:"ttl"`
+	WindowSize      int     `json:"window_size"`
+	WindowTargetRTT float64 `json:"window_target_rtt"`
+	LatencyCDFFile  string  `json:"latency_cdf_file"`
+	// DryRun builds the model, prints it, and checks its wiring instead of
+	// running it
+	DryRun bool `json:"dry_run"`
+	// MetricsOut receives the key metrics of the run as JSON, which the
+	// compare command checks against the metrics of another run
+	MetricsOut string `json:"metrics_out"`
+	// Warmup is the start-up phase left out of the latency and throughput
+	// statistics: messages created before it are only counted in the totals
+	Warmup float64 `json:"warmup"`
+	// Attribution breaks the latency percentiles down by the routing,
+	// priority, and middleware decisions taken for the messages
+	Attribution bool `json:"attribution"`
+	// AuditDelivery checks that every message sent is consumed exactly once
+	// and makes the run exit with an error otherwise
+	AuditDelivery bool `json:"audit_delivery"`
+	// FailOnDeadLetter makes the run exit with an error if any message could
+	// not be delivered
+	FailOnDeadLetter bool `json:"fail_on_dead_letter"`
+	// StrictErrors aborts the run on the first error of a kind other than
+	// the ExpectedErrors, such as ttl-expiry or unknown-destination
+	StrictErrors   bool     `json:"strict_errors"`
+	ExpectedErrors []string `json:"expected_errors"`
+	// RegistrationPeriod is the warm-up time during which consumers register
+	// with the distributor, before the producer discovers them
+	RegistrationPeriod float64 `json:"registration_period"`
+	// RetentionSize is the number of messages the distributor keeps for
+	// consumers that have not registered yet, 0 disables retention
+	RetentionSize   int     `json:"retention_size"`
+	RetentionWindow float64 `json:"retention_window"`
+	// DestWindow caps the unacknowledged messages the distributor has
+	// outstanding at every consumer, 0 for no limit; DestWindows overrides
+	// it for single consumers
+	DestWindow  int            `json:"dest_window"`
+	DestWindows map[string]int `json:"dest_windows"`
+	// The capacities of the ports on the data path, in messages: the output
+	// port of every producer, the input port of the distributor, its output
+	// port to every consumer, and every RX queue of a consumer. The capacity
+	// of an output port is the send buffer of its connection.
+	ProducerOutCapacity    int `json:"producer_out_capacity"`
+	DistributorInCapacity  int `json:"distributor_in_capacity"`
+	DistributorOutCapacity int `json:"distributor_out_capacity"`
+	ConsumerInCapacity     int `json:"consumer_in_capacity"`
+	// WorkPool replaces the RX queues of the consumers with one shared
+	// queue of PoolCapacity messages, 0 for as many as the RX queues hold
+	// together, which the consumers pull their messages from
+	WorkPool     bool `json:"work_pool"`
+	PoolCapacity int  `json:"pool_capacity"`
+	// Steal lets idle consumers take the newer half of the queue of a peer
+	// that holds at least StealThreshold messages
+	Steal          bool `json:"steal"`
+	StealThreshold int  `json:"steal_threshold"`
+	// SharedBuffer holds the RX queues of all consumers in one buffer pool
+	// with BufferReserve messages reserved for every consumer and a shared
+	// region of SharedBuffer messages, divided by BufferPolicy; 0 gives
+	// every RX queue its own capacity. BufferAdmission drops the messages
+	// without room (fifo) or lets them evict lower priorities (priority).
+	SharedBuffer    int    `json:"shared_buffer"`
+	BufferReserve   int    `json:"buffer_reserve"`
+	BufferPolicy    string `json:"buffer_policy"`
+	BufferAdmission string `json:"buffer_admission"`
+	// FaultSchedule is a file of faults to inject: connections losing
+	// messages, consumers down, and distributors stalled. FaultLoss loses
+	// that share of the messages on every data connection, and RandomFaults
+	// draws that many downs and stalls of FaultDuration seconds. The faults
+	// draw from FaultSeed, 0 uses the seed of the run.
+	FaultSchedule string  `json:"fault_schedule"`
+	FaultLoss     float64 `json:"fault_loss"`
+	RandomFaults  int     `json:"random_faults"`
+	FaultDuration float64 `json:"fault_duration"`
+	FaultSeed     int64   `json:"fault_seed"`
+	// DownMode is what a consumer that is down does with the messages it is
+	// sent: drop loses them, refuse leaves them queued until it is back
+	DownMode string `json:"down_mode"`
+	// HealthInterval makes every consumer send the distributor a heartbeat
+	// this often while it is up, 0 sends none. A consumer not heard from for
+	// HealthTimeout is considered failed, and its messages go to the healthy
+	// consumers until it is heard from again.
+	HealthInterval float64 `json:"health_interval"`
+	HealthTimeout  float64 `json:"health_timeout"`
+	// RetransmitTimeout makes the producer send a message again if its ACK
+	// has not arrived this long after it was sent, 0 never retransmits. The
+	// timeout doubles with every retransmission, and the producer gives up
+	// on the message after RetransmitLimit of them.
+	RetransmitTimeout float64 `json:"retransmit_timeout"`
+	RetransmitLimit   int     `json:"retransmit_limit"`
+	// Clock frequencies in Hz of the producers, the distributor, and the
+	// consumers; Frequencies overrides them for single components
+	ProducerFreq    float64            `json:"producer_freq"`
+	DistributorFreq float64            `json:"distributor_freq"`
+	ConsumerFreq    float64            `json:"consumer_freq"`
+	Frequencies     map[string]float64 `json:"frequencies"`
+	// Transport model of the connections: the latency in cycles from the
+	// end of a transmission to the delivery, and the bandwidth in messages
+	// or bytes (BandwidthUnit) per cycle, 0 for no limit. LinkLatencies and
+	// LinkBandwidths override them for single connections. MsgSize is the
+	// payload size of generated messages in bytes.
+	LinkLatency    int                `json:"link_latency"`
+	LinkBandwidth  float64            `json:"link_bandwidth"`
+	BandwidthUnit  string             `json:"bandwidth_unit"`
+	LinkLatencies  map[string]float64 `json:"link_latencies"`
+	LinkBandwidths map[string]float64 `json:"link_bandwidths"`
+	MsgSize        int                `json:"msg_size"`
+	// Arbiter grants every link to the ports waiting to transmit, by
+	// round-robin or weighted fair queueing with the ArbiterWeights of the
+	// ports. ArbitrationAudit checks the grants against the fairness
+	// contract of the arbiter, and GrantTraceFile receives every grant as
+	// CSV.
+	Arbiter          string             `json:"arbiter"`
+	ArbiterWeights   map[string]float64 `json:"arbiter_weights"`
+	ArbitrationAudit bool               `json:"arbitration_audit"`
+	GrantTraceFile   string             `json:"grant_trace_file"`
+	// SizeSpread draws message sizes around MsgSize and consumer service
+	// times around the consume interval from lognormal distributions with
+	// this spread, 0 keeps them fixed. SizeCorrelation is the correlation
+	// of the two, positive when large messages take longer to serve.
+	SizeSpread      float64 `json:"size_spread"`
+	SizeCorrelation float64 `json:"size_correlation"`
+	// Network is the fabric between the distributor and the consumers:
+	// direct connections, or a mesh or ring of switches with SwitchLatency
+	// cycles per switch and FlitSize-byte flits
+	Network       string `json:"network"`
+	SwitchLatency int    `json:"switch_latency"`
+	FlitSize      int    `json:"flit_size"`
+	// DistributorDepth is the number of levels of distributors between the
+	// producers and the consumers, 1 for a single distributor. Every
+	// distributor of a deeper tree has up to DistributorFanOut children.
+	DistributorDepth  int `json:"distributor_depth"`
+	DistributorFanOut int `json:"distributor_fanout"`
+	// RegisterDelays maps consumer names to the time they subscribe
+	RegisterDelays map[string]float64 `json:"register_delays"`
+	// ServiceDist is the distribution of the service times of the consumers
+	// around their consume interval: fixed, exponential, normal:cv, or
+	// pareto:shape; ServiceDists overrides it for single consumers
+	ServiceDist  string            `json:"service_dist"`
+	ServiceDists map[string]string `json:"service_dists"`
+	// QueueingModel compares the queues of the consumers with exponential
+	// service times with the M/M/1 queue of their arrival and service rates
+	QueueingModel bool `json:"queueing_model"`
+	// ConsumeIntervals overrides the consume interval of single consumers
+	ConsumeIntervals map[string]float64 `json:"consume_intervals"`
+	// PauseInterval is the time between two pauses of a consumer, in which
+	// it serves no message for PauseDuration; 0 disables pauses. PauseMode
+	// is periodic or random (exponential gaps with the interval as mean).
+	PauseInterval float64 `json:"pause_interval"`
+	PauseDuration float64 `json:"pause_duration"`
+	PauseMode     string  `json:"pause_mode"`
+	// RandomTopology draws the number of producers and consumers, their
+	// rates, and the RX queue capacities from the seed, with at most
+	// MaxProducers producers and MaxConsumers consumers
+	RandomTopology bool `json:"random_topology"`
+	MaxProducers   int  `json:"max_producers"`
+	MaxConsumers   int  `json:"max_consumers"`
+	// TrafficMatrixFile holds the rate from every producer to every
+	// consumer, the rows and columns of the matrix name the components
+	TrafficMatrixFile string `json:"traffic_matrix_file"`
+	// CongestionThreshold is the number of messages queued at a consumer from
+	// which it counts as congested, 0 disables backpressure measurement
+	CongestionThreshold int `json:"congestion_threshold"`
+	// AutoStart selects the components ticked at the beginning of the run:
+	// "self-starting" or "all"
+	AutoStart string `json:"auto_start"`
+	// Watchdog reports a stall when messages are buffered but no component
+	// ticked for this many seconds, 0 disables it
+	Watchdog float64 `json:"watchdog"`
+	// Bench measures the performance of runs with 10, 100, ... producers and
+	// as many consumers, up to this number; 0 runs the simulation normally
+	Bench int `json:"bench"`
+	// Runs is the number of independent replications of the run, whose
+	// latencies and throughputs are averaged with confidence intervals
+	Runs int `json:"runs"`
+	// SweepRuns is the number of runs of the seed-sweep and topology-fuzz
+	// scenarios
+	SweepRuns int `json:"sweep_runs"`
+	// Sweep is the ranges of the parameters the param-sweep scenario runs
+	// every combination of; it is only read from the config file.
+	// SweepCSV receives the results matrix.
+	Sweep    *ParamSweep `json:"sweep"`
+	SweepCSV string      `json:"sweep_csv"`
+	// DrainTimeout bounds the time the run keeps draining the queues after
+	// generation stops, 0 drains until the queues are empty
+	DrainTimeout float64 `json:"drain_timeout"`
+	// PriorityLevels spreads messages over this many priorities, with
+	// inversions in the shared FIFO buffers reported above InversionThreshold
+	PriorityLevels     int     `json:"priority_levels"`
+	InversionThreshold float64 `json:"inversion_threshold"`
+	// DestPolicy picks the consumer of a generated message: random or
+	// latency-p2c
+	DestPolicy string `json:"dest_policy"`
+	// RoutePolicy lets the distributor override the producer's choice:
+	// destination delivers as addressed, queue-p2c picks the shorter RX queue
+	// of two random consumers
+	RoutePolicy string `json:"route_policy"`
+	// OverflowConsumer takes the messages of consumers with at least
+	// OverflowThreshold messages queued, empty disables overflow routing
+	OverflowConsumer  string `json:"overflow_consumer"`
+	OverflowThreshold int    `json:"overflow_threshold"`
+	// RoutingRules route messages by their content, size, and priority or
+	// drop them; they are only read from the config file
+	RoutingRules []RoutingRule `json:"routing_rules"`
+	// Multicast is the probability that a generated message is addressed to
+	// all consumers or to one of the Groups of consumers instead of a single
+	// consumer. The distributor sends a copy to every member.
+	Multicast float64             `json:"multicast"`
+	Groups    map[string][]string `json:"groups"`
+	// Joins and Leaves change the members of the multicast groups during
+	// the run: consumers send a join for each of their Joins at JoinAt, or
+	// once registered, and a leave for each of their Leaves at LeaveAt, or
+	// for all of their groups if none are given
+	Joins   map[string][]string `json:"joins"`
+	JoinAt  map[string]float64  `json:"join_at"`
+	Leaves  map[string][]string `json:"leaves"`
+	LeaveAt map[string]float64  `json:"leave_at"`
+	// Topics replace addressing consumers: every generated message is
+	// published to one of the Topics and copied to the consumers whose
+	// Subscriptions match it, a topic or a prefix followed by "*". At
+	// UnsubscribeAt, consumers unsubscribe from their Unsubscriptions, or
+	// from all of their subscriptions if none are given.
+	Topics          []string            `json:"topics"`
+	Subscriptions   map[string][]string `json:"subscriptions"`
+	Unsubscriptions map[string][]string `json:"unsubscriptions"`
+	UnsubscribeAt   map[string]float64  `json:"unsubscribe_at"`
+	// ConsumerGroups share a destination name among their members. The
+	// distributor sends a message addressed to a group to one member: with
+	// hash Assignment, the owner of the partition its flow hashes to among
+	// Partitions, with round-robin Assignment, the next member in turn.
+	// Members join when they register and leave at LeaveGroupAt.
+	ConsumerGroups map[string][]string `json:"consumer_groups"`
+	Assignment     string              `json:"assignment"`
+	Partitions     int                 `json:"partitions"`
+	LeaveGroupAt   map[string]float64  `json:"leave_group_at"`
+	// InOrder delivers the messages of every (producer, consumer) pair in
+	// order through a reorder buffer, which gives up on a missing message
+	// after ReorderTimeout seconds, 0 waits forever
+	InOrder        bool    `json:"in_order"`
+	ReorderTimeout float64 `json:"reorder_timeout"`
+	// ClockSkews offsets the clocks of single producers, which stamp their
+	// messages with them; the distributor corrects the stamps at ingress.
+	// TimestampFile receives the raw and corrected stamps of every message.
+	ClockSkews    map[string]float64 `json:"clock_skews"`
+	TimestampFile string             `json:"timestamp_file"`
+	// TraceOutFile receives the journey of every message as a Chrome trace
+	TraceOutFile string `json:"trace_out_file"`
+	// Output selects where the human-readable output goes: console, file
+	// (OutputFile), or null to disable it
+	Output     string `json:"output"`
+	OutputFile string `json:"output_file"`
+	// Sink receives the output of the runs of the config once it is started,
+	// the standard output if it is nil
+	Sink EventSink `json:"-"`
+	// Monitor is the address Akita's monitoring web UI is served at during a
+	// single run, such as ":8080"
+	Monitor string `json:"monitor"`
+	// Metrics is the address the Prometheus metrics of a single run are
+	// served at under /metrics, such as ":9090"
+	Metrics string `json:"metrics"`
+	// Control is the address of the HTTP API that pauses, resumes, and steps
+	// the engine and dumps the state of a single run; ControlPaused holds
+	// the run before its first event until it is resumed
+	Control       string `json:"control"`
+	ControlPaused bool   `json:"control_paused"`
+	// Interactive runs the engine event by event under a REPL on the terminal
+	Interactive bool `json:"interactive"`
+	// RPC answers JSON-RPC requests on the standard input and output that
+	// configure and run simulations, instead of running once
+	RPC bool `json:"rpc"`
+	// Segments stops a single run at these virtual times and prints its
+	// state before it goes on
+	Segments []float64 `json:"segments"`
+	// Checkpoint receives the state of the run after its last event before
+	// CheckpointAt; Replay restores a run from such a checkpoint and resumes
+	// it
+	Checkpoint   string  `json:"checkpoint"`
+	CheckpointAt float64 `json:"checkpoint_at"`
+	Replay       string  `json:"replay"`
+	// DBFile receives every message event in a SQLite database
+	DBFile string `json:"db_file"`
+	// VisualTraceFile receives the generation, routing, transfer, and
+	// consumption tasks in the SQLite trace database Daisen reads
+	VisualTraceFile string `json:"visual_trace_file"`
+	// DotFile receives the components, ports, and connections as a Graphviz
+	// graph, written before the run starts
+	DotFile string `json:"dot_file"`
+	// MermaidFile receives the topology and the lifecycle of the message
+	// MermaidMsg (0 for the first message) as Mermaid diagrams
+	MermaidFile string `json:"mermaid_file"`
+	MermaidMsg  uint64 `json:"mermaid_msg"`
+	// QueueSampleFile receives the occupancy of every port buffer, sampled
+	// every SampleInterval seconds
+	QueueSampleFile string  `json:"queue_sample_file"`
+	SampleInterval  float64 `json:"sample_interval"`
+	// PortTimelineFile receives the busy, idle, and blocked intervals of the
+	// data-path ports
+	PortTimelineFile string `json:"port_timeline_file"`
+	// Bundle receives the configuration, the build, the inputs, and the
+	// outputs of the run as a gzipped tar archive that reproduces it
+	Bundle string `json:"bundle"`
+
+	Budgets Budgets `json:"budgets"`
+}
+
+// Default capacities of the ports on the data path, in messages. An Akita
+// port only buffers the messages it receives; the messages an output port
+// sent wait in the send buffer of its connection until they are delivered.
+const (
+	producerOutCapacity    = 1
+	distributorInCapacity  = 10
+	distributorOutCapacity = 1
+	consumerInCapacity     = 10 // Every RX queue of a consumer
+)
+
+// DefaultConfig returns the configuration used when no config file or flag
+// is given
+func DefaultConfig() *Config {
+	return &Config{
+		Cycles:             20,
+		Engine:             "serial",
+		Traffic:            "random",
+		BurstLength:        5,
+		BurstRate:          0.9,
+		ArrivalRate:        0.3,
+		IdlePeriod:         10,
+		ConsumeInterval:    1,
+		Consumers:          3,
+		ServiceDist:        "fixed",
+		RxQueues:           1,
+		Flows:              16,
+		TraceWindow:        10000,
+		ConsumerMode:       "event",
+		BatchSize:          5,
+		RegistrationPeriod: 2,
+		RetentionWindow:    10,
+		WindowTargetRTT:    5,
+		PriorityLevels:     1,
+		Runs:               1,
+		SweepRuns:          4,
+		SampleInterval:     1,
+		AutoStart:          "self-starting",
+		DestPolicy:         "random",
+		RoutePolicy:        "destination",
+		OverflowThreshold:  5,
+		Assignment:         AssignHash,
+		Partitions:         6,
+		ReorderTimeout:     10,
+		PauseDuration:      5,
+		PauseMode:          "periodic",
+		MaxProducers:       4,
+		MaxConsumers:       8,
+		Output:             "console",
+		InversionThreshold: 2,
+
+		ProducerOutCapacity:    producerOutCapacity,
+		DistributorInCapacity:  distributorInCapacity,
+		DistributorOutCapacity: distributorOutCapacity,
+		ConsumerInCapacity:     consumerInCapacity,
+		StealThreshold:         2,
+		BufferReserve:          2,
+		BufferPolicy:           "dynamic",
+		BufferAdmission:        "fifo",
+		FaultDuration:          10,
+		DownMode:               "drop",
+		HealthTimeout:          3,
+		RetransmitLimit:        3,
+
+		ProducerFreq:    1,
+		DistributorFreq: 1,
+		ConsumerFreq:    1,
+
+		BandwidthUnit: "messages",
+		Arbiter:       "round-robin",
+
+		Network:       "direct",
+		SwitchLatency: 1,
+		FlitSize:      64,
+
+		DistributorDepth:  1,
+		DistributorFanOut: 2,
+	}
+}
+
+// RegisterFlags binds all configuration fields to command-line flags
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	c.RegisterModelFlags(fs)
+	c.RegisterSweepFlags(fs)
+	c.RegisterRunFlags(fs)
+	c.RegisterOutputFlags(fs)
+}
+
+// RegisterModelFlags binds the fields that describe the simulated model and
+// what its runs measure to command-line flags
+func (c *Config) RegisterModelFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.Cycles, "cycles", c.Cycles, "Number of simulation cycles (seconds) to run")
+	fs.Float64Var(&c.Warmup, "warmup", c.Warmup, "Seconds at the start of the run left out of the latency and throughput statistics")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "Random seed of the producer (0 uses the current time)")
+	fs.StringVar(&c.Engine, "engine", c.Engine, "Simulation engine: serial or parallel (Akita's parallel engine with one event at a time, which only changes the order of the events of a time)")
+	fs.StringVar(&c.Traffic, "traffic", c.Traffic, "Traffic model: random or bursty")
+	fs.Float64Var(&c.ArrivalRate, "arrival-rate", c.ArrivalRate, "Random traffic: probability of generating a message per tick")
+	fs.Float64Var(&c.BurstLength, "burst-length", c.BurstLength, "Bursty traffic: length of each burst in seconds")
+	fs.Float64Var(&c.BurstRate, "burst-rate", c.BurstRate, "Bursty traffic: probability of generating a message per tick during a burst")
+	fs.Float64Var(&c.IdlePeriod, "idle-period", c.IdlePeriod, "Bursty traffic: idle time between bursts in seconds")
+	fs.Float64Var(&c.ConsumeInterval, "consume-interval", c.ConsumeInterval, "Time in seconds between two messages consumed by a consumer")
+	fs.IntVar(&c.Consumers, "consumers", c.Consumers, "Number of consumers (traffic matrices and random topologies bring their own)")
+	fs.IntVar(&c.RxQueues, "rx-queues", c.RxQueues, "Number of RX queues per consumer, steered by flow hash")
+	fs.IntVar(&c.ProducerOutCapacity, "producer-out-capacity", c.ProducerOutCapacity, "Messages the output port of every producer holds until they are delivered to the distributor")
+	fs.IntVar(&c.DistributorInCapacity, "distributor-in-capacity", c.DistributorInCapacity, "Messages the input port of the distributor holds")
+	fs.IntVar(&c.DistributorOutCapacity, "distributor-out-capacity", c.DistributorOutCapacity, "Messages the distributor's output port to every consumer holds until they are delivered to the consumer")
+	fs.IntVar(&c.ConsumerInCapacity, "consumer-in-capacity", c.ConsumerInCapacity, "Messages every RX queue of a consumer holds (random topologies draw their own)")
+	fs.BoolVar(&c.WorkPool, "work-pool", c.WorkPool, "Queue all messages in one shared queue the consumers pull from instead of a queue per consumer")
+	fs.IntVar(&c.PoolCapacity, "pool-capacity", c.PoolCapacity, "Messages the work pool holds, 0 for as many as the RX queues of the consumers together")
+	fs.BoolVar(&c.Steal, "steal", c.Steal, "Let idle consumers steal the newer half of the queue of a loaded peer")
+	fs.IntVar(&c.StealThreshold, "steal-threshold", c.StealThreshold, "Messages a consumer must have queued to give some away to an idle peer")
+	fs.IntVar(&c.SharedBuffer, "shared-buffer", c.SharedBuffer, "Messages of a buffer region the consumers share on top of their reservations, dropping messages that find no room (0 gives every RX queue its own capacity)")
+	fs.IntVar(&c.BufferReserve, "buffer-reserve", c.BufferReserve, "Messages of the shared buffer reserved for every consumer")
+	fs.StringVar(&c.BufferPolicy, "buffer-policy", c.BufferPolicy, "How the consumers divide the shared region: static, shared, or dynamic")
+	fs.StringVar(&c.BufferAdmission, "buffer-admission", c.BufferAdmission, "What the shared buffer does with a message without room: fifo drops it, priority lets it evict a lower-priority message")
+	fs.StringVar(&c.FaultSchedule, "faults", c.FaultSchedule, "Inject the faults of this file (start,end,kind,target[,probability] per line, kind loss, down, or stall)")
+	fs.Float64Var(&c.FaultLoss, "fault-loss", c.FaultLoss, "Probability that a message is lost on every data connection it crosses")
+	fs.IntVar(&c.RandomFaults, "random-faults", c.RandomFaults, "Number of consumer downtimes and distributor stalls to draw at random")
+	fs.Float64Var(&c.FaultDuration, "fault-duration", c.FaultDuration, "Seconds a random fault lasts")
+	fs.Int64Var(&c.FaultSeed, "fault-seed", c.FaultSeed, "Seed of the injected faults (0 uses -seed)")
+	fs.StringVar(&c.DownMode, "down-mode", c.DownMode, "What a consumer that is down does with the messages it is sent: drop or refuse (leave them queued)")
+	fs.Float64Var(&c.HealthInterval, "health-interval", c.HealthInterval, "Seconds between the heartbeats consumers send the distributor (0 disables health checks)")
+	fs.Float64Var(&c.HealthTimeout, "health-timeout", c.HealthTimeout, "Seconds without a heartbeat after which the distributor reroutes the messages of a consumer to healthy ones")
+	fs.Float64Var(&c.RetransmitTimeout, "retransmit-timeout", c.RetransmitTimeout, "Seconds the producer waits for an ACK before sending a message again, doubling with every retry (0 disables retransmission)")
+	fs.IntVar(&c.RetransmitLimit, "retransmit-limit", c.RetransmitLimit, "Retransmissions of a message after which the producer gives up on it")
+	fs.Float64Var(&c.ProducerFreq, "producer-freq", c.ProducerFreq, "Clock frequency of the producers in Hz")
+	fs.Float64Var(&c.DistributorFreq, "distributor-freq", c.DistributorFreq, "Clock frequency of the distributor in Hz")
+	fs.Float64Var(&c.ConsumerFreq, "consumer-freq", c.ConsumerFreq, "Clock frequency of the consumers in Hz")
+	fs.Var((*delayList)(&c.Frequencies), "freqs", "Override the clock frequency in Hz of components, e.g. Consumer3=0.5")
+	fs.IntVar(&c.LinkLatency, "link-latency", c.LinkLatency, "Cycles every connection takes to deliver a transmitted message")
+	fs.Float64Var(&c.LinkBandwidth, "link-bandwidth", c.LinkBandwidth, "Messages or bytes every connection transmits per cycle, 0 for no limit")
+	fs.StringVar(&c.BandwidthUnit, "bandwidth-unit", c.BandwidthUnit, "Unit of the link bandwidths: messages or bytes")
+	fs.Var((*delayList)(&c.LinkLatencies), "link-latencies", "Override the latency in cycles of connections, e.g. DistributorToConsumer3=4")
+	fs.Var((*delayList)(&c.LinkBandwidths), "link-bandwidths", "Override the bandwidth of connections, e.g. ProducerToDistributor=0.5")
+	fs.StringVar(&c.Arbiter, "arbiter", c.Arbiter, "Arbiter of the links: round-robin or wfq (weighted fair queueing)")
+	fs.Var((*delayList)(&c.ArbiterWeights), "arbiter-weights", "Weights of ports under the wfq arbiter, 1 if not given, e.g. Consumer1.Ctrl=2")
+	fs.BoolVar(&c.ArbitrationAudit, "arbitration-audit", c.ArbitrationAudit, "Check the grants of the link arbiters against their fairness contract")
+	fs.IntVar(&c.MsgSize, "msg-size", c.MsgSize, "Payload size in bytes of generated messages")
+	fs.Float64Var(&c.SizeSpread, "size-spread", c.SizeSpread, "Draw message sizes and service times from lognormal distributions with this spread (sigma of the log), 0 keeps them fixed")
+	fs.Float64Var(&c.SizeCorrelation, "size-correlation", c.SizeCorrelation, "Correlation between message sizes and consumer service times, from -1 to 1")
+	fs.StringVar(&c.Network, "network", c.Network, "Fabric between the distributor and the consumers: direct, mesh, or ring (Akita NoC switches)")
+	fs.IntVar(&c.SwitchLatency, "switch-latency", c.SwitchLatency, "Cycles a flit spends in every switch of a mesh or ring")
+	fs.IntVar(&c.FlitSize, "flit-size", c.FlitSize, "Bytes per flit in a mesh or ring")
+	fs.IntVar(&c.DistributorDepth, "distributor-depth", c.DistributorDepth, "Levels of distributors, a root above regional distributors (1 for a single distributor)")
+	fs.IntVar(&c.DistributorFanOut, "distributor-fanout", c.DistributorFanOut, "Children of every distributor in a tree")
+	fs.IntVar(&c.Flows, "flows", c.Flows, "Number of distinct flows the producer spreads messages over")
+	fs.StringVar(&c.TraceFile, "trace", c.TraceFile, "Replay the workload from a trace file (timestamp,destination,size per line) instead of generating traffic")
+	fs.IntVar(&c.TraceWindow, "trace-window", c.TraceWindow, "Records of the trace read ahead of the replay; records may be out of order by less than this")
+	fs.IntVar(&c.CoalesceCount, "coalesce-count", c.CoalesceCount, "Interrupt coalescing: notify a consumer after this many messages (0 uses only the timer)")
+	fs.Float64Var(&c.CoalesceTime, "coalesce-time", c.CoalesceTime, "Interrupt coalescing: notify a consumer at most this many seconds after a message arrives (0 disables coalescing)")
+	fs.StringVar(&c.ConsumerMode, "consumer-mode", c.ConsumerMode, "Consumer modeling style: event (woken up by arrivals), polling (ticks every cycle), or batch (waits for a full batch)")
+	fs.IntVar(&c.BatchSize, "batch-size", c.BatchSize, "Batch consumers: number of messages to collect before processing")
+	fs.StringVar(&c.AutoStart, "auto-start", c.AutoStart, "Components ticked at the beginning of the run: self-starting, all")
+	fs.Float64Var(&c.Watchdog, "watchdog", c.Watchdog, "Report a stall when messages are buffered but no component ticked for this many seconds (0 = disabled)")
+	fs.IntVar(&c.MaxInFlight, "max-in-flight", c.MaxInFlight, "Maximum number of unacknowledged messages of the producer (0 for no limit)")
+	fs.Float64Var(&c.TTL, "ttl", c.TTL, "Lifetime of messages in seconds, expired messages are dropped (0 never expires)")
+	fs.IntVar(&c.WindowSize, "window-size", c.WindowSize, "Maximum size of the producer's sliding flow-control window (0 disables the window)")
+	fs.IntVar(&c.DestWindow, "dest-window", c.DestWindow, "Maximum number of unacknowledged messages the distributor has outstanding at each consumer (0 for no limit)")
+	fs.Var((*countList)(&c.DestWindows), "dest-windows", "Override the distributor's window of consumers, e.g. Consumer3=2")
+	fs.Float64Var(&c.WindowTargetRTT, "window-target-rtt", c.WindowTargetRTT, "Round-trip time in seconds above which the sliding window shrinks")
+	fs.IntVar(&c.CongestionThreshold, "congestion-threshold", c.CongestionThreshold, "Measure the lag until the producer slows down once this many messages are queued at a consumer (0 disables)")
+	fs.Float64Var(&c.DrainTimeout, "drain-timeout", c.DrainTimeout, "Stop draining the queues this many seconds after generation stops (0 = drain until empty)")
+	fs.IntVar(&c.PriorityLevels, "priority-levels", c.PriorityLevels, "Number of message priorities; above 1, priority inversions in shared buffers are reported")
+	fs.Float64Var(&c.InversionThreshold, "inversion-threshold", c.InversionThreshold, "Report a priority inversion when a message waits behind lower-priority messages for longer than this many seconds")
+	fs.Float64Var(&c.RegistrationPeriod, "registration-period", c.RegistrationPeriod, "Warm-up time in seconds for consumers to register before the producer discovers them")
+	fs.IntVar(&c.RetentionSize, "retention-size", c.RetentionSize, "Number of messages the distributor retains for consumers that subscribe late (0 disables retention)")
+	fs.Float64Var(&c.RetentionWindow, "retention-window", c.RetentionWindow, "Time in seconds a retained message stays deliverable to a late consumer")
+	fs.Var((*delayList)(&c.RegisterDelays), "register-delay", "Delay the subscription of consumers, e.g. Consumer3=8,Consumer2=4")
+	fs.StringVar(&c.ServiceDist, "service-dist", c.ServiceDist, "Distribution of the service times of the consumers around their consume interval: fixed, exponential, normal[:cv], or pareto[:shape]")
+	fs.Var((*specList)(&c.ServiceDists), "service-dists", "Override the service-time distribution of consumers, e.g. Consumer1=exponential,Consumer3=pareto:1.5")
+	fs.BoolVar(&c.QueueingModel, "queueing-model", c.QueueingModel, "Compare the queues of the consumers with exponential service times with the M/M/1 model")
+	fs.Var((*delayList)(&c.ConsumeIntervals), "consume-intervals", "Override the consume interval of consumers, e.g. Consumer3=4")
+	fs.BoolVar(&c.RandomTopology, "random-topology", c.RandomTopology, "Draw the producers, consumers, rates, and RX queue capacities at random from the seed")
+	fs.IntVar(&c.MaxProducers, "max-producers", c.MaxProducers, "Random topologies: maximum number of producers")
+	fs.IntVar(&c.MaxConsumers, "max-consumers", c.MaxConsumers, "Random topologies: maximum number of consumers")
+	fs.StringVar(&c.TrafficMatrixFile, "traffic-matrix", c.TrafficMatrixFile, "Generate the traffic of a producer x consumer matrix of rates in msg/s from this CSV file")
+	fs.Float64Var(&c.PauseInterval, "pause-interval", c.PauseInterval, "Seconds between two pauses of every consumer, modeling GC or compaction (0 disables pauses)")
+	fs.Float64Var(&c.PauseDuration, "pause-duration", c.PauseDuration, "Seconds a consumer pause lasts")
+	fs.StringVar(&c.PauseMode, "pause-mode", c.PauseMode, "Timing of consumer pauses: periodic (staggered across consumers) or random")
+	fs.BoolVar(&c.AuditDelivery, "audit-delivery", c.AuditDelivery, "Check that every message sent is consumed exactly once, and fail the run otherwise")
+	fs.BoolVar(&c.StrictErrors, "strict", c.StrictErrors, "Abort the run on the first error of a kind not listed in -expected-errors")
+	fs.Var((*nameList)(&c.ExpectedErrors), "expected-errors", "Error kinds that do not abort a strict run, e.g. ttl-expiry,no-route")
+	fs.BoolVar(&c.Attribution, "attribution", c.Attribution, "Report the latency percentiles of the messages by the routing, priority, and middleware decisions taken for them")
+	fs.StringVar(&c.DestPolicy, "dest-policy", c.DestPolicy, "Destination policy of the producer: random or latency-p2c (power of two choices on recent ACK latency)")
+	fs.StringVar(&c.RoutePolicy, "route-policy", c.RoutePolicy, "Routing policy of the distributor: destination (as addressed by the producer) or queue-p2c (power of two choices on current queue lengths)")
+	fs.StringVar(&c.OverflowConsumer, "overflow-consumer", c.OverflowConsumer, "Consumer that takes the messages of overloaded consumers (empty disables overflow routing)")
+	fs.IntVar(&c.OverflowThreshold, "overflow-threshold", c.OverflowThreshold, "Messages queued at a consumer from which its messages overflow to the overflow consumer")
+	fs.Float64Var(&c.Multicast, "multicast", c.Multicast, "Probability that a generated message is multicast to all consumers or to one of the groups")
+	fs.Var((*groupList)(&c.Groups), "groups", "Consumer groups multicast messages are addressed to, e.g. Front=Consumer1+Consumer2,Back=Consumer3")
+	fs.Var((*groupList)(&c.Joins), "joins", "Multicast groups consumers join at their join-at time, e.g. Consumer1=Front+Back")
+	fs.Var((*delayList)(&c.JoinAt), "join-at", "Time consumers join their multicast groups, once registered unless given, e.g. Consumer1=20")
+	fs.Var((*groupList)(&c.Leaves), "leaves", "Multicast groups consumers leave at their leave-at time, e.g. Consumer1=Front")
+	fs.Var((*delayList)(&c.LeaveAt), "leave-at", "Time consumers leave their multicast groups, all of them unless given with -leaves, e.g. Consumer1=40")
+	fs.Var((*nameList)(&c.Topics), "topics", "Publish every message to one of these topics instead of a consumer, e.g. orders/eu,orders/us,payments")
+	fs.Var((*groupList)(&c.Subscriptions), "subscriptions", "Topics consumers subscribe to, a trailing * matches every topic with the prefix, e.g. Consumer1=orders/*+payments")
+	fs.Var((*groupList)(&c.Unsubscriptions), "unsubscribe", "Topics consumers unsubscribe from at their unsubscribe-at time, e.g. Consumer1=orders/*")
+	fs.Var((*delayList)(&c.UnsubscribeAt), "unsubscribe-at", "Time consumers unsubscribe, from all of their topics unless given with -unsubscribe, e.g. Consumer1=30")
+	fs.Var((*groupList)(&c.ConsumerGroups), "consumer-groups", "Groups of consumers sharing a destination name, each message goes to one member, e.g. Orders=Consumer1+Consumer2")
+	fs.StringVar(&c.Assignment, "assignment", c.Assignment, "How the messages of a consumer group are assigned to its members: hash (by flow over the partitions) or round-robin")
+	fs.IntVar(&c.Partitions, "partitions", c.Partitions, "Partitions of every consumer group, spread over its members with hash assignment")
+	fs.Var((*delayList)(&c.LeaveGroupAt), "leave-group-at", "Time consumers leave their consumer group, e.g. Consumer2=40")
+	fs.BoolVar(&c.InOrder, "in-order", c.InOrder, "Deliver the messages of every producer-consumer pair in order through a reorder buffer and report how long they wait in it")
+	fs.Var((*delayList)(&c.ClockSkews), "clock-skews", "Offset the clocks of producers, which stamp their messages, e.g. Producer=0.5; the stamps are corrected at the distributor")
+	fs.Float64Var(&c.ReorderTimeout, "reorder-timeout", c.ReorderTimeout, "In-order delivery: seconds a message waits for a missing one before the buffer gives up on it (0 waits forever)")
+}
+
+// RegisterSweepFlags binds the fields of the scenarios that sweep a
+// parameter to command-line flags
+func (c *Config) RegisterSweepFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.SweepRuns, "sweep-runs", c.SweepRuns, "Number of runs of the seed-sweep and topology-fuzz scenarios")
+	fs.StringVar(&c.SweepCSV, "sweep-csv", c.SweepCSV, "Write the results matrix of the param-sweep scenario to this CSV file")
+}
+
+// RegisterRunFlags binds the fields that only a run of the simulation uses,
+// its scenarios, modes, exported files, and budgets, to command-line flags
+func (c *Config) RegisterRunFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.GrantTraceFile, "grant-trace", c.GrantTraceFile, "Write every grant of the link arbiters to this CSV file")
+	fs.StringVar(&c.Scenario, "scenario", c.Scenario, "Run a built-in comparison scenario instead of a single run: batch-vs-streaming, dest-policy, gc-pauses, work-pool, buffer-sharing, buffer-admission, seed-sweep, topology-fuzz, engine-check, capacity-sweep, param-sweep")
+	fs.StringVar(&c.CompareHTML, "compare-html", c.CompareHTML, "Write the results of a batch-vs-streaming, dest-policy, gc-pauses, or work-pool scenario as an HTML report with latency CDFs to this file")
+	fs.IntVar(&c.Runs, "runs", c.Runs, "Run this many independent replications with consecutive seeds and report the means of their latencies and throughputs with 95% confidence intervals")
+	fs.IntVar(&c.Bench, "bench", c.Bench, "Measure events per wall-clock second, peak heap, and time per component type of runs with 10, 100, ... producers and as many consumers, up to this number")
+	fs.BoolVar(&c.FailOnDeadLetter, "fail-on-dead-letter", c.FailOnDeadLetter, "Fail the run if any message is dead-lettered by the distributor")
+	fs.StringVar(&c.LatencyCDFFile, "latency-cdf", c.LatencyCDFFile, "Write the latency CDF of every (producer, consumer) pair to this CSV file")
+	fs.BoolVar(&c.DryRun, "dry-run", c.DryRun, "Build the model, print its components, ports, and connections, and check its wiring without running it")
+	fs.StringVar(&c.MetricsOut, "metrics-out", c.MetricsOut, "Write the key metrics of the run to this JSON file, for the compare command")
+	fs.StringVar(&c.TimestampFile, "timestamp-file", c.TimestampFile, "Clock skews: write the raw and corrected stamps and latencies of every consumed message to this CSV file")
+	fs.StringVar(&c.TraceOutFile, "trace-out", c.TraceOutFile, "Write the journey of every message as a Chrome trace (chrome://tracing, Perfetto) to this JSON file")
+	fs.StringVar(&c.Monitor, "monitor", c.Monitor, "Serve Akita's monitoring web UI at this address while the simulation runs, e.g. :8080")
+	fs.StringVar(&c.Control, "control", c.Control, "Serve an HTTP API to pause, resume, and step the engine and dump the state of the run at this address, e.g. :8081")
+	fs.BoolVar(&c.ControlPaused, "control-paused", c.ControlPaused, "Hold the run before its first event until it is resumed through the control API")
+	fs.BoolVar(&c.Interactive, "interactive", c.Interactive, "Run the engine event by event under a REPL that steps, runs until a time, shows port contents, and breaks on routed messages")
+	fs.BoolVar(&c.RPC, "rpc", c.RPC, "Answer JSON-RPC requests read line by line from the standard input that configure and run simulations and return their metrics")
+	fs.Var((*timeList)(&c.Segments), "segments", "Stop the run at these virtual times, e.g. 50,100, and print its state before it goes on")
+	fs.StringVar(&c.Checkpoint, "checkpoint", c.Checkpoint, "Save the state of the run to this file after its last event before -checkpoint-at")
+	fs.Float64Var(&c.CheckpointAt, "checkpoint-at", c.CheckpointAt, "Virtual time at which the checkpoint is saved")
+	fs.StringVar(&c.Replay, "replay", c.Replay, "Restore the run saved in this checkpoint file and resume it, with the model of the checkpoint and the run and output options given here")
+	fs.StringVar(&c.Metrics, "metrics", c.Metrics, "Serve Prometheus metrics at /metrics on this address while the simulation runs, e.g. :9090")
+	fs.StringVar(&c.DBFile, "db", c.DBFile, "Write every send, arrival, and retrieval of a message and the decisions about it to this SQLite database")
+	fs.StringVar(&c.VisualTraceFile, "visual-trace", c.VisualTraceFile, "Write the generation, routing, transfer, and consumption tasks to this Daisen trace database, a .sqlite3 file")
+	fs.StringVar(&c.DotFile, "dot", c.DotFile, "Write the components, ports, and connections as a Graphviz graph to this file before running")
+	fs.StringVar(&c.MermaidFile, "mermaid", c.MermaidFile, "Write the topology and the lifecycle of a sampled message as Mermaid diagrams to this Markdown file")
+	fs.Uint64Var(&c.MermaidMsg, "mermaid-msg", c.MermaidMsg, "ID of the message drawn in the Mermaid sequence diagram (0 = the first message sent)")
+	fs.StringVar(&c.QueueSampleFile, "queue-samples", c.QueueSampleFile, "Write the occupancy of every port buffer over time to this CSV file")
+	fs.Float64Var(&c.SampleInterval, "sample-interval", c.SampleInterval, "Seconds between two queue-depth samples")
+	fs.StringVar(&c.PortTimelineFile, "port-timeline", c.PortTimelineFile, "Write the busy, idle, and blocked intervals of every data-path port to this CSV file")
+	fs.Float64Var(&c.Budgets.MaxP99Latency, "budget-max-p99", c.Budgets.MaxP99Latency, "Fail the run if the p99 latency (seconds) exceeds this value (0 disables)")
+	fs.Float64Var(&c.Budgets.MinThroughput, "budget-min-throughput", c.Budgets.MinThroughput, "Fail the run if the throughput (messages/second) is below this value (0 disables)")
+}
+
+// RegisterOutputFlags binds the fields that select where the output goes to
+// command-line flags
+func (c *Config) RegisterOutputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Output, "output", c.Output, "Where the log and the reports go: console, file (see -output-file), or null to disable all output")
+	fs.StringVar(&c.OutputFile, "output-file", c.OutputFile, "File the log and the reports are written to with -output file")
+	fs.StringVar(&c.Bundle, "bundle", c.Bundle, "Package the configuration, seed, build, inputs, and output files of the run into this .tar.gz archive to reproduce it")
+}
+
+// Load reads a JSON config file on top of the current values. Fields missing
+// from the file keep their current values.
+func (c *Config) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return fmt.Errorf("parsing config %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Validate checks that the configuration describes a runnable simulation
+func (c *Config) Validate() error {
+	if c.Cycles <= 0 {
+		return fmt.Errorf("cycles must be a positive number")
+	}
+
+	if c.Warmup < 0 || c.Warmup >= float64(c.Cycles) {
+		return fmt.Errorf("warmup must be between 0 and the number of cycles")
+	}
+
+	if c.ConsumeInterval <= 0 {
+		return fmt.Errorf("consume-interval must be a positive number")
+	}
+
+	if c.Consumers <= 0 {
+		return fmt.Errorf("consumers must be a positive number")
+	}
+
+	if c.RxQueues <= 0 || c.Flows <= 0 {
+		return fmt.Errorf("rx-queues and flows must be positive numbers")
+	}
+
+	if c.ProducerFreq <= 0 || c.DistributorFreq <= 0 || c.ConsumerFreq <= 0 {
+		return fmt.Errorf("clock frequencies must be positive")
+	}
+	for name, freq := range c.Frequencies {
+		if freq <= 0 {
+			return fmt.Errorf("clock frequency of %s must be positive", name)
+		}
+	}
+
+	if c.LinkLatency < 0 || c.LinkBandwidth < 0 || c.MsgSize < 0 {
+		return fmt.Errorf("link-latency, link-bandwidth, and msg-size must not be negative")
+	}
+	for name, latency := range c.LinkLatencies {
+		if latency < 0 || latency != math.Trunc(latency) {
+			return fmt.Errorf("latency of %s must be a whole number of cycles", name)
+		}
+	}
+	for name, bandwidth := range c.LinkBandwidths {
+		if bandwidth < 0 {
+			return fmt.Errorf("bandwidth of %s must not be negative", name)
+		}
+	}
+	if c.SizeSpread < 0 {
+		return fmt.Errorf("size-spread must not be negative")
+	}
+	if c.SizeCorrelation < -1 || c.SizeCorrelation > 1 {
+		return fmt.Errorf("size-correlation must be between -1 and 1")
+	}
+	if c.SizeSpread > 0 && (c.MsgSize == 0 || c.TraceFile != "") {
+		return fmt.Errorf("size-spread needs a msg-size and generated traffic")
+	}
+	if c.SizeCorrelation != 0 && c.SizeSpread == 0 {
+		return fmt.Errorf("size-correlation needs a size-spread")
+	}
+	switch c.BandwidthUnit {
+	case "messages":
+	case "bytes":
+		if c.MsgSize == 0 && c.TraceFile == "" && (c.LinkBandwidth > 0 || len(c.LinkBandwidths) > 0) {
+			return fmt.Errorf("a bandwidth in bytes needs a msg-size or a trace with message sizes")
+		}
+	default:
+		return fmt.Errorf("unknown bandwidth-unit %q, must be messages or bytes", c.BandwidthUnit)
+	}
+
+	if err := c.validateArbitration(); err != nil {
+		return err
+	}
+
+	switch c.Network {
+	case "direct":
+	case "mesh", "ring":
+		if c.SwitchLatency <= 0 || c.FlitSize <= 0 {
+			return fmt.Errorf("switch-latency and flit-size must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown network %q, must be one of %v", c.Network, networkKinds)
+	}
+
+	if c.DistributorDepth < 1 {
+		return fmt.Errorf("distributor-depth must be at least 1")
+	}
+	if c.DistributorDepth > 1 {
+		if c.DistributorFanOut < 2 {
+			return fmt.Errorf("distributor-fanout must be at least 2")
+		}
+		if c.Network != "direct" {
+			return fmt.Errorf("a distributor tree needs direct connections to the consumers")
+		}
+		if c.CoalesceTime > 0 || c.RetentionSize > 0 {
+			return fmt.Errorf("coalescing and retention need a single distributor")
+		}
+	}
+
+	if c.ProducerOutCapacity <= 0 || c.DistributorInCapacity <= 0 || c.DistributorOutCapacity <= 0 || c.ConsumerInCapacity <= 0 {
+		return fmt.Errorf("port capacities must be positive numbers")
+	}
+
+	if c.CoalesceCount < 0 || c.CoalesceTime < 0 {
+		return fmt.Errorf("coalesce-count and coalesce-time must not be negative")
+	}
+	if c.CoalesceCount > 0 && c.CoalesceTime == 0 {
+		return fmt.Errorf("coalesce-count requires a coalesce-time so that partial batches are delivered")
+	}
+
+	if c.RegistrationPeriod < 0 || c.RegistrationPeriod >= float64(c.Cycles) {
+		return fmt.Errorf("registration-period must not be negative and must be shorter than the run")
+	}
+
+	if c.RetentionSize < 0 || c.RetentionWindow < 0 {
+		return fmt.Errorf("retention-size and retention-window must not be negative")
+	}
+	for name, delay := range c.RegisterDelays {
+		if delay < 0 || delay >= float64(c.Cycles) {
+			return fmt.Errorf("register delay of %s must not be negative and must be shorter than the run", name)
+		}
+	}
+
+	for name, interval := range c.ConsumeIntervals {
+		if interval <= 0 {
+			return fmt.Errorf("consume interval of %s must be positive", name)
+		}
+	}
+
+	if c.MaxInFlight < 0 {
+		return fmt.Errorf("max-in-flight must not be negative")
+	}
+	if c.DestWindow < 0 {
+		return fmt.Errorf("dest-window must not be negative")
+	}
+	for name, window := range c.DestWindows {
+		if window < 0 {
+			return fmt.Errorf("dest window of %s must not be negative", name)
+		}
+	}
+	if c.DestPolicy != "random" && c.DestPolicy != "latency-p2c" {
+		return fmt.Errorf("unknown dest-policy %q", c.DestPolicy)
+	}
+	if c.RoutePolicy != "destination" && c.RoutePolicy != "queue-p2c" {
+		return fmt.Errorf("unknown route-policy %q", c.RoutePolicy)
+	}
+	if c.OverflowThreshold <= 0 {
+		return fmt.Errorf("overflow-threshold must be positive")
+	}
+	if c.Multicast < 0 || c.Multicast > 1 {
+		return fmt.Errorf("multicast must be between 0 and 1")
+	}
+	if c.Multicast > 0 && (c.TraceFile != "" || c.TrafficMatrixFile != "") {
+		return fmt.Errorf("multicast needs generated traffic, not a trace or a traffic matrix")
+	}
+	for name, members := range c.Groups {
+		if name == distributor.BroadcastGroup {
+			return fmt.Errorf("group %q is reserved for all consumers", name)
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("group %s has no members", name)
+		}
+	}
+	if err := c.validateMemberships(); err != nil {
+		return err
+	}
+	if err := c.validateTopics(); err != nil {
+		return err
+	}
+	if err := c.validateConsumerGroups(); err != nil {
+		return err
+	}
+	if c.TimestampFile != "" && len(c.ClockSkews) == 0 {
+		return fmt.Errorf("timestamp-file needs clock-skews")
+	}
+	if c.VisualTraceFile != "" && !strings.HasSuffix(c.VisualTraceFile, ".sqlite3") {
+		return fmt.Errorf("visual-trace must name a .sqlite3 file, the extension Akita's trace writer gives it")
+	}
+	if c.ReorderTimeout < 0 {
+		return fmt.Errorf("reorder-timeout must not be negative")
+	}
+	if c.PauseInterval < 0 {
+		return fmt.Errorf("pause-interval must not be negative")
+	}
+	if c.PauseInterval > 0 && (c.PauseDuration <= 0 || c.PauseDuration >= c.PauseInterval) {
+		return fmt.Errorf("pause-duration must be positive and shorter than pause-interval")
+	}
+	if c.PauseMode != "periodic" && c.PauseMode != "random" {
+		return fmt.Errorf("unknown pause-mode %q", c.PauseMode)
+	}
+	if c.RandomTopology {
+		if c.MaxProducers <= 0 || c.MaxConsumers <= 0 {
+			return fmt.Errorf("max-producers and max-consumers must be positive")
+		}
+		if c.TraceFile != "" {
+			return fmt.Errorf("random-topology needs random traffic, not a trace")
+		}
+	}
+	if c.CompareHTML != "" && c.Scenario != "batch-vs-streaming" && c.Scenario != "dest-policy" && c.Scenario != "gc-pauses" && c.Scenario != "work-pool" {
+		return fmt.Errorf("compare-html needs a batch-vs-streaming, dest-policy, gc-pauses, or work-pool scenario")
+	}
+	if c.TraceWindow <= 0 {
+		return fmt.Errorf("trace-window must be a positive number of records")
+	}
+	if c.Bench < 0 {
+		return fmt.Errorf("bench must not be negative")
+	}
+	if c.Bench > 0 {
+		if c.Scenario != "" || c.Engine != "serial" {
+			return fmt.Errorf("bench measures single runs on the serial engine")
+		}
+		if c.TraceFile != "" || c.TrafficMatrixFile != "" || c.RandomTopology {
+			return fmt.Errorf("bench builds its own topology, not a trace, a traffic matrix, or a random topology")
+		}
+	}
+	if c.Runs < 1 {
+		return fmt.Errorf("runs must be positive")
+	}
+	if c.Runs > 1 && (c.Scenario != "" || c.Bench > 0 || c.RPC || c.Interactive || c.Control != "" ||
+		len(c.Segments) > 0 || c.Checkpoint != "" || c.Replay != "") {
+		return fmt.Errorf("runs replicates plain runs, not a scenario, a benchmark, or a controlled or checkpointed run")
+	}
+	if c.TrafficMatrixFile != "" && (c.TraceFile != "" || c.RandomTopology) {
+		return fmt.Errorf("traffic-matrix cannot be combined with a trace or a random topology")
+	}
+	switch c.Output {
+	case "console", "null":
+	case "file":
+		if c.OutputFile == "" {
+			return fmt.Errorf("output file needs an output-file")
+		}
+	default:
+		return fmt.Errorf("unknown output %q", c.Output)
+	}
+	if c.Monitor != "" {
+		if _, err := monitorPort(c.Monitor); err != nil {
+			return fmt.Errorf("invalid monitor address %q: %v", c.Monitor, err)
+		}
+		if c.Scenario != "" {
+			return fmt.Errorf("monitor watches a single run, not a scenario")
+		}
+	}
+	if c.Metrics != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics); err != nil {
+			return fmt.Errorf("invalid metrics address %q: %v", c.Metrics, err)
+		}
+		if c.Scenario != "" {
+			return fmt.Errorf("metrics are served for a single run, not a scenario")
+		}
+	}
+	if c.Control != "" {
+		if _, _, err := net.SplitHostPort(c.Control); err != nil {
+			return fmt.Errorf("invalid control address %q: %v", c.Control, err)
+		}
+		if c.Scenario != "" {
+			return fmt.Errorf("control API drives a single run, not a scenario")
+		}
+	}
+	if c.ControlPaused && c.Control == "" {
+		return fmt.Errorf("control-paused needs a control address")
+	}
+	if c.Checkpoint != "" {
+		if c.Seed == 0 {
+			return fmt.Errorf("checkpoints need a fixed seed to build the model again")
+		}
+		if c.Scenario != "" {
+			return fmt.Errorf("checkpoints save a single run, not a scenario")
+		}
+		if c.CheckpointAt < 0 {
+			return fmt.Errorf("checkpoint-at must not be negative")
+		}
+	}
+	if c.Replay != "" && c.Scenario != "" {
+		return fmt.Errorf("a checkpoint restores a single run, not a scenario")
+	}
+	if c.TraceFile != "" && (c.Checkpoint != "" || c.Replay != "") {
+		// The position in the trace file is not saved
+		return fmt.Errorf("a trace file cannot be combined with checkpoint or replay")
+	}
+	switch c.Engine {
+	case "serial":
+	case "parallel":
+		// Pausing the run and replaying it rely on the order of the serial
+		// engine
+		if c.Control != "" || c.Interactive || len(c.Segments) > 0 || c.Checkpoint != "" || c.Replay != "" {
+			return fmt.Errorf("the parallel engine cannot be combined with control, interactive, segments, checkpoint, or replay")
+		}
+	default:
+		return fmt.Errorf("unknown engine %q", c.Engine)
+	}
+	if c.Interactive {
+		if c.Scenario != "" {
+			return fmt.Errorf("interactive mode drives a single run, not a scenario")
+		}
+		if c.Control != "" {
+			return fmt.Errorf("interactive mode and the control API cannot drive the same run")
+		}
+	}
+	if err := c.validateSegments(); err != nil {
+		return err
+	}
+	if c.RPC {
+		if err := c.validateRPCRun(); err != nil {
+			return err
+		}
+	}
+	if c.AutoStart != "self-starting" && c.AutoStart != "all" {
+		return fmt.Errorf("unknown auto-start %q", c.AutoStart)
+	}
+	if c.SampleInterval <= 0 {
+		return fmt.Errorf("sample-interval must be positive")
+	}
+	if c.Watchdog < 0 {
+		return fmt.Errorf("watchdog must not be negative")
+	}
+	if c.DrainTimeout < 0 {
+		return fmt.Errorf("drain-timeout must not be negative")
+	}
+	if c.PriorityLevels <= 0 || c.InversionThreshold < 0 {
+		return fmt.Errorf("priority-levels must be positive and inversion-threshold must not be negative")
+	}
+	if c.CongestionThreshold < 0 {
+		return fmt.Errorf("congestion-threshold must not be negative")
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	if c.WindowSize < 0 || c.WindowTargetRTT <= 0 {
+		return fmt.Errorf("window-size must not be negative and window-target-rtt must be positive")
+	}
+
+	switch c.ConsumerMode {
+	case "event", "polling", "batch":
+	default:
+		return fmt.Errorf("unknown consumer mode %q", c.ConsumerMode)
+	}
+	// A batch has to fit into an RX queue, but the capacity only bounds the
+	// batch of batch consumers
+	if c.BatchSize <= 0 || (c.ConsumerMode == "batch" && c.BatchSize > c.ConsumerInCapacity) {
+		return fmt.Errorf("batch-size must be between 1 and %d", c.ConsumerInCapacity)
+	}
+
+	if err := c.validateWorkPool(); err != nil {
+		return err
+	}
+	if err := c.validateStealing(); err != nil {
+		return err
+	}
+	if err := c.validateRoutingRules(); err != nil {
+		return err
+	}
+	if err := c.validateSharedBuffer(); err != nil {
+		return err
+	}
+	if err := c.validateFaults(); err != nil {
+		return err
+	}
+	if err := c.validateHealth(); err != nil {
+		return err
+	}
+	if err := c.validateRetransmission(); err != nil {
+		return err
+	}
+	if err := c.validateServiceDists(); err != nil {
+		return err
+	}
+	if err := c.validateQueueingModel(); err != nil {
+		return err
+	}
+	if err := c.validateBundle(); err != nil {
+		return err
+	}
+	if err := c.validateParamSweep(); err != nil {
+		return err
+	}
+	if err := c.validateDryRun(); err != nil {
+		return err
+	}
+
+	switch c.Scenario {
+	case "", "batch-vs-streaming", "dest-policy", "engine-check", "work-pool", "buffer-sharing", "buffer-admission":
+	case "seed-sweep":
+		if c.SweepRuns < 2 {
+			return fmt.Errorf("sweep-runs must be at least 2")
+		}
+		if c.TraceFile != "" || c.TrafficMatrixFile != "" {
+			return fmt.Errorf("seed-sweep needs random traffic, not a trace or a traffic matrix")
+		}
+	case "param-sweep":
+		if c.Sweep == nil {
+			return fmt.Errorf("param-sweep needs a sweep in the config file")
+		}
+	case "capacity-sweep":
+		if c.RandomTopology {
+			return fmt.Errorf("capacity-sweep needs fixed RX queue capacities, not a random topology")
+		}
+	case "gc-pauses":
+		if c.PauseInterval == 0 {
+			return fmt.Errorf("gc-pauses needs a pause-interval")
+		}
+	case "topology-fuzz":
+		if c.SweepRuns < 1 {
+			return fmt.Errorf("sweep-runs must be positive")
+		}
+		if c.TraceFile != "" || c.TrafficMatrixFile != "" {
+			return fmt.Errorf("topology-fuzz needs random traffic, not a trace or a traffic matrix")
+		}
+	default:
+		return fmt.Errorf("unknown scenario %q", c.Scenario)
+	}
+
+	switch c.Traffic {
+	case "random":
+		if c.ArrivalRate < 0 || c.ArrivalRate > 1 {
+			return fmt.Errorf("arrival-rate must be between 0 and 1")
+		}
+	case "bursty":
+		if c.BurstLength <= 0 || c.IdlePeriod < 0 {
+			return fmt.Errorf("burst-length must be positive and idle-period must not be negative")
+		}
+		if c.BurstRate < 0 || c.BurstRate > 1 {
+			return fmt.Errorf("burst-rate must be between 0 and 1")
+		}
+	default:
+		return fmt.Errorf("unknown traffic model %q", c.Traffic)
+	}
+
+	if _, err := parseErrorKinds(c.ExpectedErrors); err != nil {
+		return err
+	}
+	if c.Budgets.MaxP99Latency < 0 || c.Budgets.MinThroughput < 0 {
+		return fmt.Errorf("budgets must not be negative")
+	}
+
+	return nil
+}
+
+// TrafficModel builds the producer traffic model described by the config
+func (c *Config) TrafficModel() producer.Traffic {
+	if c.Traffic == "bursty" {
+		return &BurstyTraffic{
+			BurstLength: sim.VTimeInSec(c.BurstLength),
+			BurstRate:   c.BurstRate,
+			IdlePeriod:  sim.VTimeInSec(c.IdlePeriod),
+		}
+	}
+
+	return &producer.RandomTraffic{Probability: c.ArrivalRate}
+}
+
+// EventSink creates the sink of the human-readable output
+func (c *Config) EventSink() (EventSink, error) {
+	switch c.Output {
+	case "file":
+		return NewFileSink(c.OutputFile)
+	case "null":
+		return NullSink{}, nil
+	}
+	return ConsoleSink{}, nil
+}
+
+// Out returns the sink the runs of the config write to
+func (c *Config) Out() EventSink {
+	if c.Sink == nil {
+		return ConsoleSink{}
+	}
+	return c.Sink
+}
+
+// DestinationPolicy creates the destination policy described by the config
+func (c *Config) DestinationPolicy() producer.DestinationPolicy {
+	if c.DestPolicy == "latency-p2c" {
+		return NewLatencyAwareDestination(0.5)
+	}
+	return producer.RandomDestination{}
+}
+
+// Freq returns the clock of a component: its own frequency if it has one,
+// otherwise the frequency of its kind
+func (c *Config) Freq(name string, kind float64) sim.Freq {
+	if hz, ok := c.Frequencies[name]; ok {
+		return sim.Freq(hz)
+	}
+	return sim.Freq(kind)
+}
+
+// Link returns the transport model of a connection: its own latency and
+// bandwidth if it has them, otherwise the ones of all connections
+func (c *Config) Link(name string) LinkSpec {
+	spec := LinkSpec{
+		Latency:   c.LinkLatency,
+		Bandwidth: c.LinkBandwidth,
+		Bytes:     c.BandwidthUnit == "bytes",
+	}
+	if latency, ok := c.LinkLatencies[name]; ok {
+		spec.Latency = int(latency)
+	}
+	if bandwidth, ok := c.LinkBandwidths[name]; ok {
+		spec.Bandwidth = bandwidth
+	}
+	return spec
+}
+
+// nameList is a flag value of comma-separated names
+type nameList []string
+
+func (l *nameList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *nameList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+// timeList is a flag value of comma-separated virtual times
+type timeList []float64
+
+func (l *timeList) String() string {
+	if l == nil {
+		return ""
+	}
+
+	times := make([]string, len(*l))
+	for i, t := range *l {
+		times[i] = fmt.Sprintf("%g", t)
+	}
+	return strings.Join(times, ",")
+}
+
+func (l *timeList) Set(value string) error {
+	for _, field := range strings.Split(value, ",") {
+		t, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return fmt.Errorf("invalid time %q", field)
+		}
+		*l = append(*l, t)
+	}
+	return nil
+}
+
+// groupList is a flag value of comma-separated name=member+member pairs
+type groupList map[string][]string
+
+func (l *groupList) String() string {
+	if l == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*l))
+	for name, members := range *l {
+		pairs = append(pairs, name+"="+strings.Join(members, "+"))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l *groupList) Set(value string) error {
+	if *l == nil {
+		*l = make(groupList)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, members, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected name=member+member, got %q", pair)
+		}
+		var names []string
+		for _, member := range strings.Split(members, "+") {
+			if member = strings.TrimSpace(member); member != "" {
+				names = append(names, member)
+			}
+		}
+		(*l)[strings.TrimSpace(name)] = names
+	}
+	return nil
+}
+
+// specList is a flag value of comma-separated name=spec pairs
+type specList map[string]string
+
+func (l *specList) String() string {
+	if l == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*l))
+	for name, spec := range *l {
+		pairs = append(pairs, name+"="+spec)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l *specList) Set(value string) error {
+	if *l == nil {
+		*l = make(specList)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, spec, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected name=spec, got %q", pair)
+		}
+		(*l)[strings.TrimSpace(name)] = strings.TrimSpace(spec)
+	}
+	return nil
+}
+
+// delayList is a flag value of comma-separated name=seconds pairs
+type delayList map[string]float64
+
+func (l *delayList) String() string {
+	if l == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*l))
+	for name, delay := range *l {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", name, delay))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l *delayList) Set(value string) error {
+	if *l == nil {
+		*l = make(delayList)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, delay, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected name=seconds, got %q", pair)
+		}
+		d, err := strconv.ParseFloat(delay, 64)
+		if err != nil {
+			return fmt.Errorf("invalid delay %q", delay)
+		}
+		(*l)[strings.TrimSpace(name)] = d
+	}
+	return nil
+}
+
+// countList is a flag value of comma-separated name=count pairs
+type countList map[string]int
+
+func (l *countList) String() string {
+	if l == nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(*l))
+	for name, n := range *l {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", name, n))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (l *countList) Set(value string) error {
+	if *l == nil {
+		*l = make(countList)
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, count, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected name=count, got %q", pair)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("invalid count %q", count)
+		}
+		(*l)[strings.TrimSpace(name)] = n
+	}
+	return nil
+}
+
+// ConsumerPauses creates the pause windows of the index-th of n consumers.
+// Periodic pauses are staggered, so that the consumers do not all pause at
+// once; random pauses draw from a source per consumer.
+func (c *Config) ConsumerPauses(index, n int) *Pauses {
+	interval := sim.VTimeInSec(c.PauseInterval)
+	duration := sim.VTimeInSec(c.PauseDuration)
+	stopTime := sim.VTimeInSec(c.Cycles)
+	if c.PauseMode == "random" {
+		seed := time.Now().UnixNano()
+		if c.Seed != 0 {
+			seed = c.Seed
+		}
+		rng := rand.New(rand.NewSource(seed + int64(index) + 1))
+		return RandomPauses(interval, duration, stopTime, rng)
+	}
+
+	phase := interval * sim.VTimeInSec(index+1) / sim.VTimeInSec(n)
+	return PeriodicPauses(interval, duration, phase, stopTime)
+}
+
+// consumerModels are the models drawn for a consumer, kept for the reports
+type consumerModels struct {
+	service  *ServiceDist   // Nil serves every message in the interval
+	queueing *QueueObserver // Nil unless the M/M/1 model is checked
+	pauses   *Pauses        // Nil never pauses
+}
+
+// consumerModels draws the service times, the queueing observer, and the
+// pauses of the index-th of n consumers
+func (c *Config) consumerModels(name string, index, n int) *consumerModels {
+	m := &consumerModels{service: c.ServiceDistribution(name, index)}
+	m.queueing = c.QueueObserver(m.service)
+	if c.PauseInterval > 0 {
+		m.pauses = c.ConsumerPauses(index, n)
+	}
+	return m
+}
+
+// options passes the models that are drawn to the consumer
+func (m *consumerModels) options() []consumer.Option {
+	var opts []consumer.Option
+	if m.service != nil {
+		opts = append(opts, consumer.WithServiceTimes(m.service))
+	}
+	if m.queueing != nil {
+		opts = append(opts, consumer.WithQueueing(m.queueing))
+	}
+	if m.pauses != nil {
+		opts = append(opts, consumer.WithPauses(m.pauses))
+	}
+	return opts
+}
+
+// consumerMode returns the options of the consumer mode: polling, batches,
+// or interrupts when the distributor coalesces them
+func (c *Config) consumerMode() []consumer.Option {
+	var opts []consumer.Option
+	switch c.ConsumerMode {
+	case "polling":
+		opts = append(opts, consumer.WithPolling(sim.VTimeInSec(c.Cycles)))
+	case "batch":
+		opts = append(opts, consumer.WithBatches(c.BatchSize, sim.VTimeInSec(c.Cycles)))
+	}
+	if c.CoalesceTime > 0 {
+		opts = append(opts, consumer.WithInterrupts())
+	}
+	return opts
+}
+
+// TopologySpec returns the producers and consumers of the run: a producer
+// and Consumers consumers, the sources and destinations of a traffic matrix, or
+// a random topology drawn from the seed. The consume intervals given per
+// consumer override the drawn ones.
+func (c *Config) TopologySpec(matrix *TrafficMatrix) TopologySpec {
+	var spec TopologySpec
+	if matrix != nil {
+		for _, name := range matrix.Sources {
+			spec.Producers = append(spec.Producers, ProducerSpec{Name: name})
+		}
+		for _, name := range matrix.Destinations {
+			spec.Consumers = append(spec.Consumers, ConsumerSpec{
+				Name:          name,
+				Interval:      c.ConsumeInterval,
+				QueueCapacity: c.ConsumerInCapacity,
+			})
+		}
+	} else if c.Bench > 0 {
+		spec = BenchTopologySpec(c.Bench, c.ConsumeInterval, c.ConsumerInCapacity)
+	} else if c.RandomTopology {
+		seed := time.Now().UnixNano()
+		if c.Seed != 0 {
+			seed = c.Seed
+		}
+		minCapacity := 0
+		if c.ConsumerMode == "batch" {
+			minCapacity = c.BatchSize
+		}
+		// The topology draws from its own source, so that it does not shift
+		// the traffic of the producers
+		rng := rand.New(rand.NewSource(^seed))
+		spec = RandomTopologySpec(rng, c.MaxProducers, c.MaxConsumers, minCapacity)
+	} else {
+		spec.Producers = []ProducerSpec{{Name: "Producer"}}
+		for i := 1; i <= c.Consumers; i++ {
+			spec.Consumers = append(spec.Consumers, ConsumerSpec{
+				Name:          fmt.Sprintf("Consumer%d", i),
+				Interval:      c.ConsumeInterval,
+				QueueCapacity: c.ConsumerInCapacity,
+			})
+		}
+	}
+
+	for i, consumer := range spec.Consumers {
+		if interval, ok := c.ConsumeIntervals[consumer.Name]; ok {
+			spec.Consumers[i].Interval = interval
+		}
+		// The shared buffer decides the room of a consumer, its queues can
+		// hold whatever it may take
+		if c.SharedBuffer > 0 {
+			spec.Consumers[i].QueueCapacity = c.BufferReserve + c.SharedBuffer
+		}
+	}
+	return spec
+}
@@ -0,0 +1,62 @@
+package demo
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConfigFlagsOverrideFile verifies that values from a config file are
+// applied and that explicitly given flags take precedence over them
+func TestConfigFlagsOverrideFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"cycles": 50, "traffic": "bursty", "budgets": {"max_p99_latency": 3}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	args := []string{"-cycles", "30"}
+	
+	if err := cfg.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	
+	if cfg.Cycles != 30 {
+		t.Errorf("Expected cycles flag to override config file, got %d", cfg.Cycles)
+	}
+	if cfg.Traffic != "bursty" {
+		t.Errorf("Expected traffic from config file, got %q", cfg.Traffic)
+	}
+	if cfg.Budgets.MaxP99Latency != 3 {
+		t.Errorf("Expected p99 budget from config file, got %v", cfg.Budgets.MaxP99Latency)
+	}
+}
+
+// TestConfigValidateRejectsBadValues verifies that invalid configurations are
+// rejected
+func TestConfigValidateRejectsBadValues(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Cycles = 0
+	if cfg.Validate() == nil {
+		t.Error("Expected an error for zero cycles")
+	}
+	
+	cfg = DefaultConfig()
+	cfg.Traffic = "unknown"
+	if cfg.Validate() == nil {
+		t.Error("Expected an error for an unknown traffic model")
+	}
+	
+	cfg = DefaultConfig()
+	cfg.DistributorInCapacity = 0
+	if cfg.Validate() == nil {
+		t.Error("Expected an error for a port without capacity")
+	}
+}
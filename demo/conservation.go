@@ -0,0 +1,120 @@
+package demo
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Ledger counts the messages entering and leaving the ports of the data
+// path, so that messages sitting in port buffers or connections can be
+// accounted for at the end of a run
+type Ledger struct {
+	sent      int // Messages sent by the tracked output ports
+	retrieved int // Messages retrieved from the tracked input ports
+	lost      int // Messages lost on a connection
+}
+
+// Lose counts a message that left the network without a port retrieving
+// it: lost on a connection or evicted from a queue
+func (l *Ledger) Lose() {
+	l.lost++
+}
+
+// TrackOutput counts the messages sent through a port
+func (l *Ledger) TrackOutput(port sim.Port) {
+	port.AcceptHook(l)
+}
+
+// TrackInput counts the messages retrieved from a port
+func (l *Ledger) TrackInput(port sim.Port) {
+	port.AcceptHook(l)
+}
+
+// Func counts a send or retrieve on a tracked port
+func (l *Ledger) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosPortMsgSend:
+		l.sent++
+	case sim.HookPosPortMsgRetrieve:
+		l.retrieved++
+	}
+}
+
+// InNetwork returns the number of messages sent but not yet retrieved
+func (l *Ledger) InNetwork() int {
+	return l.sent - l.retrieved - l.lost
+}
+
+// Conservation is the end-of-run accounting of every produced message
+type Conservation struct {
+	Produced        int
+	Copies          int // Extra messages from fanning out multicast messages
+	Consumed        int
+	Filtered        int // Dropped by routing rules
+	NoRoom          int // Dropped for lack of room in the shared buffer
+	Evicted         int // Pushed out of the shared buffer by higher-priority messages
+	Duplicates      int // Extra copies sent for middlewares
+	Retransmitted   int // Messages sent again because their ACK was overdue
+	Deduplicated    int // Discarded by consumers that consumed them before
+	Intercepted     int // Dropped by middlewares
+	Lost            int // Lost to injected faults
+	Expired         int
+	DeadLetters     int
+	RetentionMisses int
+	InNetwork       int // Queued in ports or in transit on connections
+	Retained        int // Held by the distributor for late consumers
+}
+
+// Accounted returns the number of messages whose fate is known
+func (c Conservation) Accounted() int {
+	return c.Consumed + c.Filtered + c.NoRoom + c.Evicted + c.Intercepted + c.Deduplicated + c.Lost + c.Expired + c.DeadLetters +
+		c.RetentionMisses + c.InNetwork + c.Retained
+}
+
+// Holds reports whether no message vanished or appeared out of nowhere
+func (c Conservation) Holds() bool {
+	return c.Produced+c.Copies+c.Duplicates+c.Retransmitted == c.Accounted()
+}
+
+// Print writes the accounting and whether it balances
+func (c Conservation) Print(out EventSink) {
+	out.Println("=== Conservation ===")
+	out.Printf("Produced:          %d\n", c.Produced)
+	if c.Copies > 0 {
+		out.Printf("Multicast copies:  %d (beyond the first copy of every message)\n", c.Copies)
+	}
+	if c.Duplicates > 0 {
+		out.Printf("Duplicates:        %d (copies sent for middlewares)\n", c.Duplicates)
+	}
+	if c.Retransmitted > 0 {
+		out.Printf("Retransmitted:     %d (sent again, their ACK was overdue)\n", c.Retransmitted)
+	}
+	out.Printf("Consumed:          %d\n", c.Consumed)
+	if c.Filtered > 0 {
+		out.Printf("Filtered:          %d (dropped by routing rules)\n", c.Filtered)
+	}
+	if c.NoRoom > 0 {
+		out.Printf("No room:           %d (dropped, the shared buffer was full)\n", c.NoRoom)
+	}
+	if c.Evicted > 0 {
+		out.Printf("Evicted:           %d (pushed out of the shared buffer by higher priorities)\n", c.Evicted)
+	}
+	if c.Intercepted > 0 {
+		out.Printf("Intercepted:       %d (dropped by middlewares)\n", c.Intercepted)
+	}
+	if c.Deduplicated > 0 {
+		out.Printf("Deduplicated:      %d (discarded, consumed before)\n", c.Deduplicated)
+	}
+	if c.Lost > 0 {
+		out.Printf("Lost:              %d (to injected faults)\n", c.Lost)
+	}
+	out.Printf("Dropped:           %d (%d expired, %d dead letters, %d retention misses)\n",
+		c.Expired+c.DeadLetters+c.RetentionMisses, c.Expired, c.DeadLetters, c.RetentionMisses)
+	out.Printf("Still buffered:    %d (%d in ports and connections, %d retained)\n",
+		c.InNetwork+c.Retained, c.InNetwork, c.Retained)
+	if c.Holds() {
+		out.Println("Result:            OK")
+	} else {
+		out.Printf("Result:            VIOLATED (%d produced, %d accounted for)\n",
+			c.Produced+c.Copies+c.Duplicates+c.Retransmitted, c.Accounted())
+	}
+}
@@ -0,0 +1,320 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Strategies that assign the messages of a consumer group to its members
+const (
+	AssignHash       = "hash"
+	AssignRoundRobin = "round-robin"
+)
+
+// Rebalance is a change of the members of a consumer group, after which the
+// partitions of the group are assigned anew
+type Rebalance struct {
+	Time    sim.VTimeInSec
+	Group   string
+	Members []string
+	Moved   int // Partitions that changed their owner
+}
+
+// ConsumerGroups lets consumers share a destination name. A message
+// addressed to a group goes to one of its members: with hash assignment, to
+// the owner of the partition its flow hashes to, with round-robin
+// assignment, to the next member in turn. The members own contiguous ranges
+// of the partitions, which are reassigned whenever a member joins the group
+// by registering or leaves it. Its methods are safe to call on nil groups.
+type ConsumerGroups struct {
+	Strategy   string
+	Partitions int
+
+	members map[string][]string // Configured members of every group
+	groupOf map[string]string   // Group of every configured member
+	left    map[string]bool     // Members that left their group
+	active  map[string][]string // Members the partitions are assigned to
+	owners  map[string][]string // Owner of every partition of each group
+	turns   map[string]int      // Messages routed per group, which picks the next member in turn
+
+	Assigned   map[string]int // Messages routed to every member
+	Rebalances []Rebalance
+}
+
+// NewConsumerGroups creates consumer groups with no registered members
+func NewConsumerGroups(groups map[string][]string, strategy string, partitions int) *ConsumerGroups {
+	g := &ConsumerGroups{
+		Strategy:   strategy,
+		Partitions: partitions,
+		members:    groups,
+		groupOf:    make(map[string]string),
+		left:       make(map[string]bool),
+		active:     make(map[string][]string),
+		owners:     make(map[string][]string),
+		turns:      make(map[string]int),
+		Assigned:   make(map[string]int),
+	}
+	for group, members := range groups {
+		for _, member := range members {
+			g.groupOf[member] = group
+		}
+		g.owners[group] = make([]string, partitions)
+	}
+	return g
+}
+
+// Has reports whether a destination name is a consumer group
+func (g *ConsumerGroups) Has(name string) bool {
+	if g == nil {
+		return false
+	}
+	_, ok := g.members[name]
+	return ok
+}
+
+// Destination returns the name messages for a consumer are addressed to,
+// its group if it is a member of one
+func (g *ConsumerGroups) Destination(consumer string) string {
+	if g == nil {
+		return consumer
+	}
+	if group, ok := g.groupOf[consumer]; ok {
+		return group
+	}
+	return consumer
+}
+
+// Destinations returns the sorted names of a list of consumers, with the
+// members of a group replaced by the group
+func (g *ConsumerGroups) Destinations(consumers []string) []string {
+	if g == nil {
+		return consumers
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, consumer := range consumers {
+		name := g.Destination(consumer)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Leave removes a consumer from its group. It returns the group, or false
+// if the consumer is not a member of one.
+func (g *ConsumerGroups) Leave(consumer string) (string, bool) {
+	group, ok := g.groupOf[consumer]
+	if !ok || g.left[consumer] {
+		return "", false
+	}
+	g.left[consumer] = true
+	return group, true
+}
+
+// Rebalance assigns the partitions of every group whose members changed
+// over its members that are routable and did not leave. It returns the
+// rebalances in the order of the group names.
+func (g *ConsumerGroups) Rebalance(now sim.VTimeInSec, routable func(string) bool) []Rebalance {
+	groups := make([]string, 0, len(g.members))
+	for group := range g.members {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	var rebalances []Rebalance
+	for _, group := range groups {
+		var members []string
+		for _, member := range g.members[group] {
+			if routable(member) && !g.left[member] {
+				members = append(members, member)
+			}
+		}
+		sort.Strings(members)
+		if strings.Join(members, " ") == strings.Join(g.active[group], " ") {
+			continue
+		}
+
+		g.active[group] = members
+		moved := 0
+		for p := range g.owners[group] {
+			owner := ""
+			if len(members) > 0 {
+				// Every member owns a contiguous range of the partitions
+				owner = members[p*len(members)/g.Partitions]
+			}
+			if owner != g.owners[group][p] {
+				moved++
+				g.owners[group][p] = owner
+			}
+		}
+		rebalance := Rebalance{Time: now, Group: group, Members: members, Moved: moved}
+		g.Rebalances = append(g.Rebalances, rebalance)
+		rebalances = append(rebalances, rebalance)
+	}
+	return rebalances
+}
+
+// Assign picks the member of a group a message goes to and the partition of
+// the message, -1 with round-robin assignment. It returns false if the
+// group has no members.
+func (g *ConsumerGroups) Assign(group string, m *msg.DemoMessage) (string, int, bool) {
+	members := g.active[group]
+	if len(members) == 0 {
+		return "", 0, false
+	}
+	if g.Strategy == AssignRoundRobin {
+		return members[g.turns[group]%len(members)], -1, true
+	}
+	partition := int(distributor.RSSHash(m.FlowID) % uint32(g.Partitions))
+	return g.owners[group][partition], partition, true
+}
+
+// Routed counts a message of a group once it has been sent to a member
+func (g *ConsumerGroups) Routed(m *msg.DemoMessage) {
+	if g == nil || m.ConsumerGroup == "" {
+		return
+	}
+	g.turns[m.ConsumerGroup]++
+	g.Assigned[m.Destination]++
+}
+
+// partitionsOf returns the partitions a member owns
+func (g *ConsumerGroups) partitionsOf(group, member string) []int {
+	var partitions []int
+	for p, owner := range g.owners[group] {
+		if owner == member {
+			partitions = append(partitions, p)
+		}
+	}
+	return partitions
+}
+
+// Print writes the messages every member was sent, the partitions it owns
+// at the end of the run, and the rebalances
+func (g *ConsumerGroups) Print(out EventSink) {
+	out.Println("=== Consumer Groups ===")
+	out.Printf("%-16s %-16s %8s  %s\n", "Group", "Member", "Assigned", "Partitions")
+	groups := make([]string, 0, len(g.members))
+	for group := range g.members {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for _, member := range g.members[group] {
+			owned := "-"
+			if g.left[member] {
+				owned = "left"
+			} else if g.Strategy == AssignRoundRobin {
+				owned = "in turn"
+			} else if partitions := g.partitionsOf(group, member); len(partitions) > 0 {
+				owned = strings.Trim(fmt.Sprint(partitions), "[]")
+			}
+			out.Printf("%-16s %-16s %8d  %s\n", group, member, g.Assigned[member], owned)
+		}
+	}
+	out.Printf("Rebalances:        %d\n", len(g.Rebalances))
+	for _, r := range g.Rebalances {
+		out.Printf("  [%.2f] %s\n", float64(r.Time), g.describe(r))
+	}
+}
+
+// Reassign rebalances the groups whose routable members changed, and
+// describes every rebalance
+func (g *ConsumerGroups) Reassign(now sim.VTimeInSec, routable func(string) bool) []string {
+	var rebalances []string
+	for _, r := range g.Rebalance(now, routable) {
+		rebalances = append(rebalances, g.describe(r))
+	}
+	return rebalances
+}
+
+// Owners returns the owner of every partition of each group
+func (g *ConsumerGroups) Owners() map[string][]string {
+	return g.owners
+}
+
+// String lists the members of every group and how their messages are
+// assigned
+func (g *ConsumerGroups) String() string {
+	var groups []string
+	for _, name := range multicastTargets(g.members)[1:] {
+		groups = append(groups, name+"="+strings.Join(g.members[name], "+"))
+	}
+	assignment := "in turn"
+	if g.Strategy == AssignHash {
+		assignment = fmt.Sprintf("by flow over %d partitions", g.Partitions)
+	}
+	return strings.Join(groups, ", ") + ", " + assignment
+}
+
+// describe says which members a group was rebalanced over and, with hash
+// assignment, how many partitions moved
+func (g *ConsumerGroups) describe(r Rebalance) string {
+	members := "no members"
+	if len(r.Members) > 0 {
+		members = strings.Join(r.Members, ", ")
+	}
+	if g.Strategy == AssignRoundRobin {
+		return fmt.Sprintf("%s over %s", r.Group, members)
+	}
+	return fmt.Sprintf("%s over %s (%d of %d partitions moved)", r.Group, members, r.Moved, g.Partitions)
+}
+
+// validateConsumerGroups checks the assignment of the consumer groups, that
+// every consumer is a member of at most one group, and that the groups are
+// addressed by generated traffic through a single distributor
+func (c *Config) validateConsumerGroups() error {
+	if c.Assignment != AssignHash && c.Assignment != AssignRoundRobin {
+		return fmt.Errorf("unknown assignment %q", c.Assignment)
+	}
+	if c.Partitions <= 0 {
+		return fmt.Errorf("partitions must be positive")
+	}
+	if len(c.ConsumerGroups) == 0 {
+		if len(c.LeaveGroupAt) > 0 {
+			return fmt.Errorf("leave-group-at needs consumer groups")
+		}
+		return nil
+	}
+	groupOf := make(map[string]string)
+	for name, members := range c.ConsumerGroups {
+		if len(members) == 0 {
+			return fmt.Errorf("consumer group %s has no members", name)
+		}
+		for _, member := range members {
+			if other, ok := groupOf[member]; ok {
+				return fmt.Errorf("%s is a member of both %s and %s", member, other, name)
+			}
+			groupOf[member] = name
+		}
+	}
+	for name, at := range c.LeaveGroupAt {
+		if _, ok := groupOf[name]; !ok {
+			return fmt.Errorf("%s leaves no consumer group", name)
+		}
+		if at <= 0 {
+			return fmt.Errorf("leave-group-at of %s must be positive", name)
+		}
+	}
+	if c.Multicast > 0 || len(c.Topics) > 0 {
+		return fmt.Errorf("consumer groups cannot be combined with multicast or topics")
+	}
+	if c.TraceFile != "" || c.TrafficMatrixFile != "" {
+		return fmt.Errorf("consumer groups need generated traffic, not a trace or a traffic matrix")
+	}
+	if c.DistributorDepth > 1 {
+		return fmt.Errorf("consumer groups need a single distributor")
+	}
+	if c.RoutePolicy != "destination" || c.DestPolicy != "random" {
+		return fmt.Errorf("consumer groups need the destination route-policy and the random dest-policy")
+	}
+	return nil
+}
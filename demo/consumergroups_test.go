@@ -0,0 +1,102 @@
+package demo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestRebalanceMovesContiguousRanges verifies that the members own
+// contiguous ranges of the partitions and that a rebalance only moves the
+// partitions of the members that changed
+func TestRebalanceMovesContiguousRanges(t *testing.T) {
+	groups := NewConsumerGroups(map[string][]string{"Orders": {"A", "B", "C"}}, AssignHash, 6)
+	routable := map[string]bool{"A": true, "B": true, "C": true}
+	isRoutable := func(name string) bool { return routable[name] }
+	
+	rebalances := groups.Rebalance(1, isRoutable)
+	if len(rebalances) != 1 || rebalances[0].Moved != 6 {
+		t.Fatalf("Expected one rebalance moving every partition, got %+v", rebalances)
+	}
+	if want := []string{"A", "A", "B", "B", "C", "C"}; !reflect.DeepEqual(groups.owners["Orders"], want) {
+		t.Errorf("Expected owners %v, got %v", want, groups.owners["Orders"])
+	}
+	if rebalances := groups.Rebalance(2, isRoutable); len(rebalances) != 0 {
+		t.Errorf("Expected no rebalance without a change of the members, got %+v", rebalances)
+	}
+	
+	groups.Leave("B")
+	rebalances = groups.Rebalance(3, isRoutable)
+	if len(rebalances) != 1 || rebalances[0].Moved != 2 {
+		t.Fatalf("Expected one rebalance moving the two partitions of B, got %+v", rebalances)
+	}
+	if want := []string{"A", "A", "A", "C", "C", "C"}; !reflect.DeepEqual(groups.owners["Orders"], want) {
+		t.Errorf("Expected owners %v, got %v", want, groups.owners["Orders"])
+	}
+	
+	routable["A"], routable["C"] = false, false
+	groups.Rebalance(4, isRoutable)
+	if _, _, ok := groups.Assign("Orders", &msg.DemoMessage{}); ok {
+		t.Error("Expected a group without members to assign nothing")
+	}
+}
+
+// TestConsumerGroupsRebalanceOnJoinAndLeave verifies that a member joining
+// late and a member leaving rebalance the group, and that every message is
+// consumed once and in the order of its member
+func TestConsumerGroupsRebalanceOnJoinAndLeave(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 80
+	cfg.Flows = 12
+	cfg.ConsumerGroups = map[string][]string{"Orders": {"Consumer1", "Consumer2"}}
+	cfg.RegisterDelays = map[string]float64{"Consumer2": 20}
+	cfg.LeaveGroupAt = map[string]float64{"Consumer1": 50}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	groups := simulation.routing.consumerGroups
+	var members [][]string
+	for _, r := range groups.Rebalances {
+		members = append(members, r.Members)
+	}
+	want := [][]string{{"Consumer1"}, {"Consumer1", "Consumer2"}, {"Consumer2"}}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("Expected rebalances over %v, got %v", want, members)
+	}
+	if groups.Assigned["Consumer1"] == 0 || groups.Assigned["Consumer2"] == 0 {
+		t.Errorf("Expected both members to be assigned messages, got %v", groups.Assigned)
+	}
+	if v := simulation.verifier; v.Reordered > 0 || v.Duplicates > 0 {
+		t.Errorf("Expected in-order delivery, got %d reordered and %d duplicates", v.Reordered, v.Duplicates)
+	}
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected every message to be accounted for, got %+v", simulation.Conservation())
+	}
+}
+
+// TestConsumerGroupsMembership verifies that a consumer can only be a member
+// of one group and only members leave a group
+func TestConsumerGroupsMembership(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConsumerGroups = map[string][]string{"Orders": {"Consumer1", "Consumer2"}, "Billing": {"Consumer2"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a consumer in two groups to be rejected")
+	}
+	cfg.ConsumerGroups = map[string][]string{"Orders": {"Consumer1", "Consumer2"}}
+	cfg.LeaveGroupAt = map[string]float64{"Consumer3": 10}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a consumer outside the groups leaving to be rejected")
+	}
+	cfg.LeaveGroupAt = map[string]float64{"Consumer2": 10}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the groups to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,551 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// ControlStatus is the answer of every control request
+type ControlStatus struct {
+	Time       float64 `json:"time"`
+	Events     uint64  `json:"events"`   // Events handled so far
+	Paused     bool    `json:"paused"`   // The engine waits before its next event
+	Finished   bool    `json:"finished"` // The engine ran out of events
+	NextEvent  string  `json:"next_event,omitempty"`
+	Breakpoint string  `json:"breakpoint,omitempty"` // What paused the engine, if not a request
+}
+
+// MessageState describes a message at the head of a port
+type MessageState struct {
+	ID          uint64  `json:"id"`
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	CreateTime  float64 `json:"create_time"`
+}
+
+// BufferState is the occupancy of a port buffer
+type BufferState struct {
+	Name   string        `json:"name"`
+	Queued int           `json:"queued"`
+	Head   *MessageState `json:"head,omitempty"`
+}
+
+// ProducerState is the flow-control state of a producer
+type ProducerState struct {
+	Name       string   `json:"name"`
+	Discovered bool     `json:"discovered"`
+	Consumers  []string `json:"consumers"`
+	Sent       uint64   `json:"sent"`
+	Unacked    int      `json:"unacked"`
+	Window     int      `json:"window,omitempty"` // Current window size, 0 without a window
+}
+
+// DistributorState is the routing state of the distributor
+type DistributorState struct {
+	Name     string   `json:"name"`
+	Routes   []string `json:"routes"`   // Registered destinations
+	Retained int      `json:"retained"` // Messages kept for late consumers
+}
+
+// ConsumerState is the registration and the queues of a consumer
+type ConsumerState struct {
+	Name        string `json:"name"`
+	Registered  bool   `json:"registered"`
+	Queues      []int  `json:"queues"`   // Messages waiting in every RX queue
+	Consumed    []int  `json:"consumed"` // Messages consumed from every RX queue
+	PendingAcks int    `json:"pending_acks"`
+}
+
+// SimulationState is a snapshot of every component of a paused run
+type SimulationState struct {
+	ControlStatus
+	Produced    int              `json:"produced"`
+	Routed      int              `json:"routed"`
+	Consumed    int              `json:"consumed"`
+	Acked       int              `json:"acked"`
+	DeadLetters int              `json:"dead_letters"`
+	Producers   []ProducerState  `json:"producers"`
+	Distributor DistributorState `json:"distributor"`
+	Consumers   []ConsumerState  `json:"consumers"`
+	Ports       []BufferState    `json:"ports"`
+}
+
+// Controller pauses the engine between events on request. While paused, the
+// engine goroutine waits in the hook before its next event, so the state of
+// the run can be read safely from the HTTP handlers.
+type Controller struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	paused   bool // Pause before the next event
+	steps    int  // Events to handle before pausing again
+	waiting  bool // The engine waits before the next event
+	finished bool
+	next     sim.Event
+	now      sim.VTimeInSec
+	events   uint64
+	until    sim.VTimeInSec // Pause before the first event at or after this time, if set
+	hasUntil bool
+	breaks   map[string]bool // Consumers whose routed messages pause the engine
+	hit      string          // Breakpoint that paused the engine, if any
+
+	ports    []sim.Port
+	contents portContents
+
+	changes []*ParamChange // Parameter changes in the order they were requested
+	check   func(change ParamChange) error
+	tune    func(now sim.VTimeInSec, change ParamChange)
+}
+
+// NewController creates a controller of the engine, paused from the start if
+// requested
+func NewController(engine sim.Engine, paused bool) *Controller {
+	c := &Controller{
+		paused:   paused,
+		breaks:   make(map[string]bool),
+		contents: make(portContents),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	engine.AcceptHook(c)
+	return c
+}
+
+// Track includes the buffer of a port in the snapshots
+func (c *Controller) Track(port sim.Port) {
+	c.ports = append(c.ports, port)
+	port.AcceptHook(c.contents)
+}
+
+// WatchRoutes checks the messages the distributor sends through a port
+// against the route breakpoints
+func (c *Controller) WatchRoutes(port sim.Port) {
+	port.AcceptHook(routeHook{c})
+}
+
+type routeHook struct {
+	c *Controller
+}
+
+// Func pauses the engine after the event that routed a message to a consumer
+// with a breakpoint
+func (h routeHook) Func(ctx sim.HookCtx) {
+	m, ok := ctx.Item.(*msg.DemoMessage)
+	if !ok || ctx.Pos != sim.HookPosPortMsgSend {
+		return
+	}
+
+	c := h.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.breaks[m.Destination] {
+		c.paused = true
+		c.steps = 0
+		c.hit = fmt.Sprintf("message #%d routed to %s", m.ID, m.Destination)
+	}
+}
+
+// portContents follows the messages queued in the buffers of the ports it is
+// a hook of, as Akita keeps the buffers themselves unexported
+type portContents map[sim.Port][]sim.Msg
+
+// Func adds received messages to the contents of a port and removes retrieved
+// ones
+func (p portContents) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosPortMsgRecvd:
+		port := ctx.Domain.(sim.Port)
+		p[port] = append(p[port], ctx.Item.(sim.Msg))
+	case sim.HookPosPortMsgRetrieve:
+		port := ctx.Domain.(sim.Port)
+		for i, msg := range p[port] {
+			if msg == ctx.Item {
+				p[port] = append(p[port][:i], p[port][i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// describeMsg summarizes a message by its type and its identifying fields
+func describeMsg(m sim.Msg) string {
+	switch m := m.(type) {
+	case *msg.DemoMessage:
+		return fmt.Sprintf("#%d %s -> %s, created at %.2f",
+			m.ID, m.Source, m.Destination, float64(m.CreateTime))
+	case *msg.AckMsg:
+		return fmt.Sprintf("ACK of #%d from %s", m.MsgID, m.Consumer)
+	case *msg.RegisterMsg:
+		return fmt.Sprintf("registration of %s", m.Name)
+	case *msg.DiscoverRsp:
+		return fmt.Sprintf("discovery of %v", m.Names)
+	default:
+		return fmt.Sprintf("%T", m)
+	}
+}
+
+// Func waits before an event while the engine is paused
+func (c *Controller) Func(ctx sim.HookCtx) {
+	if ctx.Pos == sim.HookPosBeforeEvent {
+		c.beforeEvent(ctx.Item.(sim.Event))
+	}
+}
+
+func (c *Controller) beforeEvent(evt sim.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.next = evt
+	if c.hasUntil && evt.Time() >= c.until {
+		c.hasUntil = false
+		c.paused = true
+		c.steps = 0
+		c.hit = fmt.Sprintf("reached %.2f", float64(c.until))
+	}
+	for c.paused && c.steps == 0 {
+		c.waiting = true
+		c.cond.Broadcast()
+		c.cond.Wait()
+	}
+	c.waiting = false
+	if c.paused {
+		c.steps--
+	}
+	if c.events == 0 || evt.Time() > c.now {
+		c.applyChanges(evt.Time())
+	}
+	c.now = evt.Time()
+	c.events++
+	c.next = nil
+}
+
+// Finish releases the requests waiting for the engine at the end of the run
+func (c *Controller) Finish() {
+	c.mu.Lock()
+	c.finished = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+// Pause stops the engine before its next event and waits until it stopped
+func (c *Controller) Pause() ControlStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+	c.steps = 0
+	return c.waitForEngine()
+}
+
+// Resume lets the engine run freely
+func (c *Controller) Resume() ControlStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resume()
+	return c.status()
+}
+
+func (c *Controller) resume() {
+	c.paused = false
+	c.waiting = false
+	c.hit = ""
+	c.cond.Broadcast()
+}
+
+// Continue lets the engine run until a breakpoint or the end of the run and
+// waits until it stopped
+func (c *Controller) Continue() ControlStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resume()
+	return c.waitForEngine()
+}
+
+// RunUntil lets the engine run until the first event at or after the given
+// time, a breakpoint, or the end of the run, and waits until it stopped
+func (c *Controller) RunUntil(t sim.VTimeInSec) ControlStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.until = t
+	c.hasUntil = true
+	c.resume()
+	status := c.waitForEngine()
+	c.hasUntil = false
+	return status
+}
+
+// Break pauses the engine whenever the distributor routes a message to the
+// consumer
+func (c *Controller) Break(consumer string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breaks[consumer] = true
+}
+
+// ClearBreaks removes all route breakpoints
+func (c *Controller) ClearBreaks() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breaks = make(map[string]bool)
+}
+
+// Breakpoints returns the consumers with a route breakpoint
+func (c *Controller) Breakpoints() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.breaks))
+	for name := range c.breaks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Step lets a paused engine handle n more events and waits until it stopped
+// again
+func (c *Controller) Step(n int) ControlStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+	c.steps = n
+	c.waiting = false
+	c.hit = ""
+	c.cond.Broadcast()
+	return c.waitForEngine()
+}
+
+func (c *Controller) waitForEngine() ControlStatus {
+	for !(c.waiting && c.steps == 0) && !c.finished {
+		c.cond.Wait()
+	}
+	return c.status()
+}
+
+func (c *Controller) status() ControlStatus {
+	s := ControlStatus{
+		Time:     float64(c.now),
+		Events:   c.events,
+		Paused:   c.waiting,
+		Finished: c.finished,
+	}
+	if c.waiting && c.next != nil {
+		s.NextEvent = describeEvent(c.next)
+		s.Breakpoint = c.hit
+	}
+	return s
+}
+
+// describeEvent names an event by its type, its handler, and its time
+func describeEvent(evt sim.Event) string {
+	handler := fmt.Sprintf("%T", evt.Handler())
+	if named, ok := evt.Handler().(sim.Named); ok {
+		handler = named.Name()
+	}
+	return fmt.Sprintf("%T for %s at %.2f", evt, handler, float64(evt.Time()))
+}
+
+// State takes a snapshot of the run. It fails unless the engine waits
+// between events or has finished.
+func (c *Controller) State(s *Simulation) (SimulationState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.waiting && !c.finished {
+		return SimulationState{}, fmt.Errorf("the engine is running, pause it first")
+	}
+
+	state := SimulationState{
+		ControlStatus: c.status(),
+		Produced:      s.stats.Produced,
+		Routed:        s.stats.Routed,
+		Consumed:      s.stats.Consumed,
+		Acked:         s.stats.Acked,
+		DeadLetters:   s.deadLetters.Total,
+	}
+	for _, p := range s.producers {
+		ps := ProducerState{
+			Name:       p.Name(),
+			Discovered: p.Discovered(),
+			Consumers:  p.Consumers(),
+			Sent:       p.Sent(),
+			Unacked:    p.Unacked(),
+		}
+		if w := p.Window(); w != nil {
+			ps.Window = w.Size()
+		}
+		state.Producers = append(state.Producers, ps)
+	}
+	state.Distributor = DistributorState{
+		Name:     s.distributor.Name(),
+		Routes:   s.distributor.Routes().Names(),
+		Retained: s.distributor.Replaying(),
+	}
+	if s.routing.retention != nil {
+		state.Distributor.Retained += s.routing.retention.Len()
+	}
+	for _, consumer := range s.consumers {
+		cs := ConsumerState{
+			Name:        consumer.Name(),
+			Registered:  consumer.Registered(),
+			PendingAcks: consumer.PendingAcks(),
+			Queues:      consumer.Queued(),
+			Consumed:    consumer.Consumed(),
+		}
+		state.Consumers = append(state.Consumers, cs)
+	}
+	for _, port := range c.ports {
+		ps := BufferState{Name: port.Name(), Queued: len(c.contents[port])}
+		if m, ok := port.Peek().(*msg.DemoMessage); ok {
+			ps.Head = &MessageState{
+				ID:          m.ID,
+				Source:      m.Source,
+				Destination: m.Destination,
+				CreateTime:  float64(m.CreateTime),
+			}
+		}
+		state.Ports = append(state.Ports, ps)
+	}
+	sort.Slice(state.Ports, func(i, j int) bool { return state.Ports[i].Name < state.Ports[j].Name })
+	return state, nil
+}
+
+// newController creates a controller of the run that tracks every port of
+// the topology and the routes of the distributor
+func (s *Simulation) newController(paused bool) *Controller {
+	c := NewController(s.engine, paused)
+	_, ports := s.topology.portsByComponent()
+	for _, component := range ports {
+		for _, port := range component {
+			c.Track(port)
+		}
+	}
+	for _, name := range s.consumerNames {
+		c.WatchRoutes(s.tree.Leaf(name).OutputPort(name))
+	}
+	c.check = s.checkParamChange
+	c.tune = s.applyParamChange
+	s.control = c
+	return c
+}
+
+// StartControl serves the control API at the given address:
+//
+//	POST /pause          stop before the next event
+//	POST /resume         run freely
+//	POST /step?n=N       handle N events (default 1) and stop again
+//	GET  /status         time, handled events, and the next event
+//	GET  /state          JSON snapshot of every component, while paused
+//	POST /params?param=P&target=T&value=V
+//	                     change a parameter at the next time boundary
+//	GET  /params         requested parameter changes, applied or pending
+//	POST /consumers?name=N&interval=I
+//	                     add a consumer at the next time boundary
+//	DELETE /consumers?name=N
+//	                     stop routing to a consumer at the next time
+//	                     boundary and detach it once it has drained
+func (s *Simulation) StartControl(addr string, paused bool) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	c := s.newController(paused)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pause", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.Pause())
+	}))
+	mux.HandleFunc("/resume", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, c.Resume())
+	}))
+	mux.HandleFunc("/step", postOnly(func(w http.ResponseWriter, r *http.Request) {
+		n := 1
+		if arg := r.URL.Query().Get("n"); arg != "" {
+			var err error
+			n, err = strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				http.Error(w, "n must be a positive number of events", http.StatusBadRequest)
+				return
+			}
+		}
+		writeJSON(w, c.Step(n))
+	}))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		status := c.status()
+		c.mu.Unlock()
+		writeJSON(w, status)
+	})
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		state, err := c.State(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, state)
+	})
+	mux.HandleFunc("/params", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, c.Changes())
+		case http.MethodPost:
+			q := r.URL.Query()
+			change, err := parseParamChange(q.Get("param"), q.Get("target"), q.Get("value"))
+			if err == nil {
+				change, err = c.Tune(change)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, change)
+		default:
+			http.Error(w, "use GET or POST", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/consumers", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var change ParamChange
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			change, err = parseParamChange("add-consumer", q.Get("name"), q.Get("interval"))
+		case http.MethodDelete:
+			change = ParamChange{Param: "remove-consumer", Target: q.Get("name")}
+		default:
+			http.Error(w, "use POST or DELETE", http.StatusMethodNotAllowed)
+			return
+		}
+		if err == nil {
+			change, err = c.Tune(change)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, change)
+	})
+	go http.Serve(listener, mux)
+	return nil
+}
+
+func postOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
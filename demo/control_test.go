@@ -0,0 +1,246 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestControllerStepsPausedEngine verifies that a run started paused handles
+// exactly the stepped events, can be inspected in between, and finishes once
+// resumed
+func TestControllerStepsPausedEngine(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.StartControl("localhost:0", true); err != nil {
+		t.Fatal(err)
+	}
+	c := simulation.control
+	
+	done := make(chan error)
+	go func() { done <- simulation.Run() }()
+	
+	status := c.Pause()
+	if !status.Paused || status.Events != 0 || status.NextEvent == "" {
+		t.Fatalf("Expected the run to wait before its first event, got %+v", status)
+	}
+	status = c.Step(40)
+	if !status.Paused || status.Events != 40 {
+		t.Fatalf("Expected the run to stop after 40 events, got %+v", status)
+	}
+	
+	state, err := c.State(simulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Produced == 0 || len(state.Distributor.Routes) != 3 || len(state.Consumers) != 3 {
+		t.Errorf("Expected messages and three registered consumers after 40 events, got %+v", state)
+	}
+	if len(state.Ports) == 0 {
+		t.Error("Expected the ports of the topology in the state")
+	}
+	
+	c.Resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if status := c.Pause(); !status.Finished {
+		t.Errorf("Expected the run to be finished, got %+v", status)
+	}
+	if _, err := c.State(simulation); err != nil {
+		t.Errorf("Expected the state of a finished run, got %v", err)
+	}
+}
+
+// TestControllerTunesParametersAtTimeBoundary verifies that parameter changes
+// are checked when requested, take effect at the next time boundary, and are
+// recorded with the times they were requested and applied at
+func TestControllerTunesParametersAtTimeBoundary(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.StartControl("localhost:0", true); err != nil {
+		t.Fatal(err)
+	}
+	c := simulation.control
+	
+	done := make(chan error)
+	go func() { done <- simulation.Run() }()
+	
+	for _, bad := range []ParamChange{
+		{Param: "speed", Target: "Producer", Value: 1},
+		{Param: "probability", Target: "Producer", Value: 2},
+		{Param: "weight", Target: "Consumer9", Value: 1},
+	} {
+		if _, err := c.Tune(bad); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+	
+	c.RunUntil(30)
+	queued, err := c.Tune(ParamChange{Param: "weight", Target: "Consumer1", Value: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !queued.Pending || queued.Requested >= 30 {
+		t.Errorf("Expected a pending change requested before 30, got %+v", queued)
+	}
+	producer := simulation.producers[0]
+	addressed := producer.SeqNum("Consumer1")
+	
+	c.Resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	
+	changes := c.Changes()
+	if len(changes) != 1 || changes[0].Pending || changes[0].Applied != 30 {
+		t.Fatalf("Expected the change applied at 30, got %+v", changes)
+	}
+	if producer.SeqNum("Consumer1") != addressed || producer.SeqNum("Consumer2") == 0 {
+		t.Errorf("Expected no messages for Consumer1 after its weight dropped to 0, got %d more", producer.SeqNum("Consumer1")-addressed)
+	}
+}
+
+// TestControllerAddsConsumerMidRun verifies that a consumer added through the
+// control API registers, is announced to the producer, receives messages,
+// and shows up in the statistics, with every message accounted for
+func TestControllerAddsConsumerMidRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.StartControl("localhost:0", true); err != nil {
+		t.Fatal(err)
+	}
+	c := simulation.control
+	
+	done := make(chan error)
+	go func() { done <- simulation.Run() }()
+	
+	for _, bad := range []ParamChange{
+		{Param: "add-consumer", Target: "Consumer1", Value: 1},
+		{Param: "add-consumer", Target: "Consumer4", Value: 0},
+	} {
+		if _, err := c.Tune(bad); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+	
+	c.RunUntil(30)
+	if _, err := c.Tune(ParamChange{Param: "add-consumer", Target: "Consumer4", Value: 1}); err != nil {
+		t.Fatal(err)
+	}
+	c.RunUntil(40)
+	state, err := c.State(simulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Consumers) != 4 || !state.Consumers[3].Registered || len(state.Distributor.Routes) != 4 {
+		t.Errorf("Expected Consumer4 registered by 40, got %+v", state)
+	}
+	if producers := state.Producers[0].Consumers; len(producers) != 4 {
+		t.Errorf("Expected the producer to know four consumers, got %v", producers)
+	}
+	
+	c.Resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	
+	added := simulation.consumer("Consumer4")
+	if added.TotalConsumed() == 0 {
+		t.Error("Expected Consumer4 to consume messages")
+	}
+	if ticks := simulation.stats.Ticks("Consumer4"); ticks.Total() == 0 {
+		t.Error("Expected the ticks of Consumer4 in the statistics")
+	}
+	if conservation := simulation.Conservation(); !conservation.Holds() {
+		t.Errorf("Expected every message accounted for, got %+v", conservation)
+	}
+	if v := simulation.verifier; v.Reordered > 0 || v.Duplicates > 0 {
+		t.Errorf("Expected no reordered or duplicate messages, got %d and %d", v.Reordered, v.Duplicates)
+	}
+}
+
+// TestControllerRemovesConsumerMidRun verifies that a consumer removed
+// through the control API stops getting messages, drains the ones it was
+// sent, and is detached, while the messages still addressed to it go to the
+// other consumers
+func TestControllerRemovesConsumerMidRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.StartControl("localhost:0", true); err != nil {
+		t.Fatal(err)
+	}
+	c := simulation.control
+	
+	done := make(chan error)
+	go func() { done <- simulation.Run() }()
+	
+	if _, err := c.Tune(ParamChange{Param: "remove-consumer", Target: "Consumer9"}); err == nil {
+		t.Error("Expected an unknown consumer to be rejected")
+	}
+	
+	c.RunUntil(30)
+	if _, err := c.Tune(ParamChange{Param: "remove-consumer", Target: "Consumer2"}); err != nil {
+		t.Fatal(err)
+	}
+	c.RunUntil(31)
+	if _, err := c.Tune(ParamChange{Param: "remove-consumer", Target: "Consumer2"}); err == nil {
+		t.Error("Expected a second removal of Consumer2 to be rejected")
+	}
+	if _, err := c.Tune(ParamChange{Param: "remove-consumer", Target: "Consumer1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Tune(ParamChange{Param: "remove-consumer", Target: "Consumer3"}); err == nil {
+		t.Error("Expected the last consumer to be kept")
+	}
+	
+	c.Resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	
+	removed := simulation.consumer("Consumer2")
+	var removal *Removal
+	for _, r := range simulation.removals {
+		if r.Consumer == "Consumer2" {
+			removal = r
+		}
+	}
+	if removal == nil || !removal.Done || removal.Detached < removal.Started {
+		t.Fatalf("Expected Consumer2 to be detached, got %+v", removal)
+	}
+	if removed.Received() != removal.Routed {
+		t.Errorf("Expected Consumer2 to receive only the %d messages routed before its removal, got %d",
+			removal.Routed, removed.Received())
+	}
+	if len(simulation.removals) != 2 || simulation.removals[0].TotalRedistributed() == 0 {
+		t.Errorf("Expected messages for Consumer2 redistributed, got %+v", simulation.removals)
+	}
+	if conservation := simulation.Conservation(); !conservation.Holds() {
+		t.Errorf("Expected every message accounted for, got %+v", conservation)
+	}
+	if v := simulation.verifier; v.Reordered > 0 || v.Duplicates > 0 {
+		t.Errorf("Expected no reordered or duplicate messages, got %d and %d", v.Reordered, v.Duplicates)
+	}
+}
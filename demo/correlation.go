@@ -0,0 +1,94 @@
+package demo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// SizeService records the sizes, service times, and latencies of the
+// consumed messages, to measure how strongly sizes and service times are
+// correlated and how the large messages fare. Its methods are safe to call
+// on a nil recorder.
+type SizeService struct {
+	Spread      float64 // Spread of the log-values
+	Coefficient float64 // Configured correlation of the log-values
+
+	sizes     []float64
+	services  []float64
+	latencies []float64
+}
+
+// NewSizeService creates a recorder of sizes and service times drawn with
+// the given spread and correlation
+func NewSizeService(spread, coefficient float64) *SizeService {
+	return &SizeService{Spread: spread, Coefficient: coefficient}
+}
+
+// Consumed records a consumed message, the time it took to serve and its
+// end-to-end latency
+func (s *SizeService) Consumed(m *msg.DemoMessage, service, latency sim.VTimeInSec) {
+	if s == nil {
+		return
+	}
+	s.sizes = append(s.sizes, float64(m.Size))
+	s.services = append(s.services, float64(service))
+	s.latencies = append(s.latencies, float64(latency))
+}
+
+// Measured returns the Pearson correlation of the sizes and the service
+// times of the consumed messages
+func (s *SizeService) Measured() float64 {
+	return pearson(s.sizes, s.services)
+}
+
+// pearson returns the correlation coefficient of two samples of the same
+// length, 0 if either does not vary
+func pearson(xs, ys []float64) float64 {
+	mx, my := mean(xs), mean(ys)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// halves returns the mean latencies of the messages up to the median size
+// and of the larger ones
+func (s *SizeService) halves() (small, large float64) {
+	order := make([]int, len(s.sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return s.sizes[order[a]] < s.sizes[order[b]] })
+	var smalls, larges []float64
+	for rank, i := range order {
+		if rank < (len(order)+1)/2 {
+			smalls = append(smalls, s.latencies[i])
+		} else {
+			larges = append(larges, s.latencies[i])
+		}
+	}
+	return mean(smalls), mean(larges)
+}
+
+// Print writes the measured correlation and the latencies of the small and
+// the large messages
+func (s *SizeService) Print(out EventSink) {
+	out.Println("=== Size and Service Time ===")
+	out.Printf("Spread:            %.2f\n", s.Spread)
+	out.Printf("Correlation:       %.2f configured, %.2f measured over %d messages\n",
+		s.Coefficient, s.Measured(), len(s.sizes))
+	out.Printf("Mean size:         %.1f bytes\n", mean(s.sizes))
+	out.Printf("Mean service time: %.2f s\n", mean(s.services))
+	small, large := s.halves()
+	out.Printf("Mean latency:      %.2f s up to the median size, %.2f s above\n", small, large)
+}
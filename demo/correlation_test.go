@@ -0,0 +1,55 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestCorrelatedSizesSlowLargeMessages verifies that with correlated sizes
+// the large messages take longer to serve and wait longer than the small
+// ones
+func TestCorrelatedSizesSlowLargeMessages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 400
+	cfg.ConsumeInterval = 4
+	cfg.MsgSize = 100
+	cfg.SizeSpread = 0.8
+	cfg.SizeCorrelation = 0.9
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	s := simulation.sizeService
+	if r := s.Measured(); r < 0.8 {
+		t.Errorf("Expected a strong correlation of sizes and service times, got %.2f", r)
+	}
+	if small, large := s.halves(); large <= small {
+		t.Errorf("Expected the large messages to wait longer, got %.2f s and %.2f s", small, large)
+	}
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected every message to be accounted for, got %+v", simulation.Conservation())
+	}
+}
+
+// TestSizeCorrelationNeedsSpread verifies that a correlation without a
+// spread of sizes is rejected
+func TestSizeCorrelationNeedsSpread(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SizeCorrelation = 0.5
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a correlation without a spread to be rejected")
+	}
+	cfg.SizeSpread = 0.5
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a spread without a msg-size to be rejected")
+	}
+	cfg.MsgSize = 100
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the spread to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package demo
+
+import (
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// DeadLetterSink collects the messages the distributor could not deliver and
+// counts them by reason
+type DeadLetterSink struct {
+	*sim.TickingComponent
+	component.BaseLifecycle
+	inputPort sim.Port
+	counts    map[msg.DeadLetterReason]int
+	errors    *ErrorLog
+	Total     int
+}
+
+// NewDeadLetterSink creates a new dead-letter sink component
+func NewDeadLetterSink(name string, engine sim.Engine) *DeadLetterSink {
+	s := &DeadLetterSink{
+		counts: make(map[msg.DeadLetterReason]int),
+	}
+	s.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, s)
+	s.inputPort = sim.NewLimitNumMsgPort(s, 4, name+".In")
+	return s
+}
+
+// Tick records all the dead letters that arrived
+func (s *DeadLetterSink) Tick(now sim.VTimeInSec) bool {
+	madeProgress := false
+	for {
+		m := s.inputPort.Retrieve(now)
+		if m == nil {
+			return madeProgress
+		}
+		madeProgress = true
+
+		letter, ok := m.(*msg.DeadLetterMsg)
+		if !ok {
+			s.errors.Report(now, s.Name(), component.ErrTypeMismatch, "Discarded message of type %T", m)
+			continue
+		}
+		s.counts[letter.Reason]++
+		s.Total++
+
+		dest := "unknown"
+		if demoMsg, ok := letter.Msg.(*msg.DemoMessage); ok {
+			dest = demoMsg.Destination
+		}
+		s.errors.Report(now, s.Name(), component.DeadLetterKind(letter.Reason), "Received message for %s (%s)", dest, letter.Reason)
+	}
+}
+
+// Count returns the number of dead letters with the given reason
+func (s *DeadLetterSink) Count(reason msg.DeadLetterReason) int {
+	return s.counts[reason]
+}
+
+// Print writes the dead-letter counts by reason
+func (s *DeadLetterSink) Print(out EventSink) {
+	out.Println("=== Dead Letters ===")
+	out.Printf("Dead letters:      %d\n", s.Total)
+
+	reasons := make([]string, 0, len(s.counts))
+	for reason := range s.counts {
+		reasons = append(reasons, string(reason))
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		out.Printf("  %-24s %d\n", reason+":", s.counts[msg.DeadLetterReason(reason)])
+	}
+}
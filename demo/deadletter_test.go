@@ -0,0 +1,73 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestDistributorDeadLettersBadMessages verifies that messages for unknown
+// or unregistered destinations end up in the dead-letter sink with a reason
+func TestDistributorDeadLettersBadMessages(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	sink := NewDeadLetterSink("DeadLetterSink", engine)
+	d := distributor.New("Distributor", engine, []string{"Consumer1"},
+		distributor.WithDeadLetters(sink.inputPort))
+	
+	conn := sim.NewDirectConnection("DistributorToDeadLetterSink", engine, 1*sim.Hz)
+	conn.PlugIn(d.DeadLetterPort(), 1)
+	conn.PlugIn(sink.inputPort, 1)
+	
+	for _, dest := range []string{"Consumer9", "Consumer1"} {
+		m := &msg.DemoMessage{Destination: dest}
+		m.Meta().Dst = d.InputPort()
+		d.InputPort().Recv(m)
+	}
+	d.TickNow(0)
+	
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if sink.Total != 2 {
+		t.Fatalf("Expected 2 dead letters, got %d", sink.Total)
+	}
+	if sink.Count(msg.ReasonUnknownDestination) != 1 || sink.Count(msg.ReasonNoRoute) != 1 {
+		t.Errorf("Expected 1 unknown destination and 1 unrouted message, got %d and %d",
+			sink.Count(msg.ReasonUnknownDestination), sink.Count(msg.ReasonNoRoute))
+	}
+	if d.InputPort().Peek() != nil {
+		t.Error("Expected the distributor's input queue to be drained")
+	}
+}
+
+// TestDistributorWaitsForBusySink verifies that a dead letter stays queued
+// while the sink's port is busy
+func TestDistributorWaitsForBusySink(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	sink := NewDeadLetterSink("DeadLetterSink", engine)
+	d := distributor.New("Distributor", engine, []string{"Consumer1"},
+		distributor.WithDeadLetters(sink.inputPort))
+	
+	conn := sim.NewDirectConnection("DistributorToDeadLetterSink", engine, 1*sim.Hz)
+	conn.PlugIn(d.DeadLetterPort(), 1)
+	conn.PlugIn(sink.inputPort, 1)
+	
+	// Fill the distributor's dead-letter port
+	blocker := &msg.DeadLetterMsg{Reason: msg.ReasonInvalidType}
+	blocker.Meta().Src = d.DeadLetterPort()
+	blocker.Meta().Dst = sink.inputPort
+	d.DeadLetterPort().Send(blocker)
+	
+	m := &msg.DemoMessage{Destination: "Consumer9"}
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
+	
+	result := d.Tick(0)
+	
+	if result != false || d.InputPort().Peek() != m {
+		t.Error("Expected the message to stay queued while the sink is busy")
+	}
+}
@@ -0,0 +1,147 @@
+package demo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+)
+
+// Describable is implemented by the components that list their parameters
+// in the description of the model
+type Describable interface {
+	Describe() []component.Parameter
+}
+
+// DescribeModel builds the simulation of cfg without running it and prints
+// the model it instantiated
+func DescribeModel(cfg *Config) error {
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		return err
+	}
+	simulation.Describe()
+	return nil
+}
+
+// Describe writes the model as it was instantiated, read from the components
+// and connections rather than the configuration: every component with its
+// parameters and ports, and every connection with its frequency
+func (s *Simulation) Describe() {
+	components, ports := s.topology.portsByComponent()
+	connections := make(map[sim.Port]*topologyConn)
+	for i := range s.topology.connections {
+		conn := &s.topology.connections[i]
+		for _, port := range conn.ports {
+			connections[port] = conn
+		}
+	}
+
+	s.out.Println("=== Model ===")
+	s.out.Printf("Components:        %d\n", len(components))
+	s.out.Printf("Connections:       %d\n", len(s.topology.connections))
+	for _, name := range components {
+		component := ports[name][0].Component()
+		kind := fmt.Sprintf("%T", component)
+		s.out.Printf("\n%s (%s)\n", name, kind[strings.LastIndex(kind, ".")+1:])
+		if d, ok := component.(Describable); ok {
+			for _, param := range d.Describe() {
+				s.out.Printf("  %-16s %s\n", param.Name+":", param.Value)
+			}
+		}
+		s.out.Println("  Ports:")
+		for _, port := range ports[name] {
+			conn := connections[port]
+			if conn.conn == nil {
+				s.out.Printf("    %-28s holds %d, on %s\n", port.Name(), portCapacity(port), conn.name)
+				continue
+			}
+			s.out.Printf("    %-28s holds %d, sends %d, on %s\n",
+				port.Name(), portCapacity(port), s.topology.sendBuffer(port), conn.name)
+		}
+	}
+
+	s.out.Println()
+	s.out.Println("=== Connections ===")
+	for _, conn := range s.topology.connections {
+		names := make([]string, len(conn.ports))
+		for i, port := range conn.ports {
+			names[i] = port.Name()
+		}
+		kind := s.describeConnection(conn)
+		if conn.lossy != nil {
+			kind += ", loses messages by faults"
+		}
+		s.out.Printf("%s (%s)\n", conn.name, kind)
+		s.out.Printf("  %s\n", strings.Join(names, ", "))
+	}
+}
+
+// describeConnection names the kind of a connection and its frequency
+func (s *Simulation) describeConnection(conn topologyConn) string {
+	switch c := conn.conn.(type) {
+	case *sim.DirectConnection:
+		return fmt.Sprintf("direct, %s", component.FormatFreq(c.Freq))
+	case *Link:
+		bandwidth := "unlimited bandwidth"
+		if c.spec.Bandwidth > 0 {
+			unit := "messages"
+			if c.spec.Bytes {
+				unit = "bytes"
+			}
+			bandwidth = fmt.Sprintf("%g %s per cycle", c.spec.Bandwidth, unit)
+		}
+		if _, ok := c.arbiter.(*WFQArbiter); ok {
+			bandwidth += ", weighted fair queueing"
+		}
+		return fmt.Sprintf("link, %s, %d-cycle latency, %s", component.FormatFreq(c.Freq), c.spec.Latency, bandwidth)
+	case nil:
+		if s.network != nil {
+			return fmt.Sprintf("switch network, %s", s.network)
+		}
+	}
+	return fmt.Sprintf("%T", conn.conn)
+}
+
+// sendBuffer returns the number of messages a port can have sent that its
+// connection has not delivered yet
+func (t *Topology) sendBuffer(port sim.Port) int {
+	if size, ok := t.sendBuffers[port]; ok {
+		return size
+	}
+	return 1
+}
+
+// portCapacity returns the number of messages a port can hold, 0 if it is
+// not known. Akita does not expose the buffer of a port, so it is read from
+// the port's unexported field.
+func portCapacity(port sim.Port) int {
+	p, ok := port.(*sim.LimitNumMsgPort)
+	if !ok {
+		return 0
+	}
+	field := reflect.ValueOf(p).Elem().FieldByName("buf")
+	buf, ok := reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Interface().(sim.Buffer)
+	if !ok {
+		return 0
+	}
+	return buf.Capacity()
+}
+
+// Describe lists the capacity of the work pool and how it hands out its
+// messages
+func (p *WorkPool) Describe() []component.Parameter {
+	return []component.Parameter{
+		{Name: "Frequency", Value: component.FormatFreq(p.Freq)},
+		{Name: "Capacity", Value: fmt.Sprintf("%d messages", p.Capacity)},
+		{Name: "Hands out", Value: "the oldest message to the consumer that pulled first"},
+	}
+}
+
+// Describe lists the frequency of the sink
+func (s *DeadLetterSink) Describe() []component.Parameter {
+	return []component.Parameter{{Name: "Frequency", Value: component.FormatFreq(s.Freq)}}
+}
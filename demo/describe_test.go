@@ -0,0 +1,46 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDescribeReadsTheInstantiatedModel verifies that the description
+// reports the capacities, frequencies, and connections the components were
+// built with, including those overridden for single components
+func TestDescribeReadsTheInstantiatedModel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DistributorInCapacity = 3
+	cfg.ConsumerInCapacity = 2
+	cfg.Frequencies = map[string]float64{"Consumer2": 0.5}
+	cfg.LinkLatency = 2
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if got := portCapacity(simulation.distributor.InputPort()); got != 3 {
+		t.Errorf("Expected the distributor to hold 3 messages, got %d", got)
+	}
+	if got := portCapacity(simulation.consumers[0].InputPort()); got != 2 {
+		t.Errorf("Expected Consumer1 to hold 2 messages, got %d", got)
+	}
+	params := make(map[string]string)
+	for _, param := range simulation.consumers[1].Describe() {
+		params[param.Name] = param.Value
+	}
+	if params["Frequency"] != "0.5 Hz" {
+		t.Errorf("Expected Consumer2 to tick at 0.5 Hz, got %q", params["Frequency"])
+	}
+	for _, conn := range simulation.topology.connections {
+		if conn.name != "ProducerToDistributor" {
+			continue
+		}
+		if got := simulation.describeConnection(conn); !strings.HasPrefix(got, "link, 1 Hz, 2-cycle latency") {
+			t.Errorf("Expected a link with a latency of 2 cycles, got %q", got)
+		}
+	}
+	
+	simulation.Describe()
+}
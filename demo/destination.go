@@ -0,0 +1,163 @@
+package demo
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// WeightedDestination picks a consumer at random in proportion to its
+// weight. Consumers without a weight weigh 1; if no consumer weighs
+// anything, it picks uniformly.
+type WeightedDestination struct {
+	Weights map[string]float64
+}
+
+// NewWeightedDestination creates the policy with every consumer weighing 1
+func NewWeightedDestination() *WeightedDestination {
+	return &WeightedDestination{Weights: make(map[string]float64)}
+}
+
+func (d *WeightedDestination) weight(consumer string) float64 {
+	if w, ok := d.Weights[consumer]; ok {
+		return w
+	}
+	return 1
+}
+
+// Pick returns a consumer drawn by weight
+func (d *WeightedDestination) Pick(consumers []string, rng *rand.Rand) string {
+	total := 0.0
+	for _, consumer := range consumers {
+		total += d.weight(consumer)
+	}
+	if total <= 0 {
+		return consumers[rng.Intn(len(consumers))]
+	}
+
+	r := rng.Float64() * total
+	picked := ""
+	for _, consumer := range consumers {
+		w := d.weight(consumer)
+		if w <= 0 {
+			continue
+		}
+		picked = consumer
+		if r -= w; r < 0 {
+			break
+		}
+	}
+	return picked
+}
+
+// Observe ignores the round-trip times
+func (d *WeightedDestination) Observe(consumer string, rtt sim.VTimeInSec) {}
+
+// String names the policy and the weights of the consumers
+func (d *WeightedDestination) String() string {
+	names := make([]string, 0, len(d.Weights))
+	for name := range d.Weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	weights := make([]string, len(names))
+	for i, name := range names {
+		weights[i] = fmt.Sprintf("%s=%g", name, d.Weights[name])
+	}
+	return "weighted, " + strings.Join(weights, ", ")
+}
+
+// LatencyAwareDestination applies the power of two choices to the observed
+// latencies: it samples two distinct consumers at random and picks the one
+// with the lower recent round-trip time. The recent round-trip time is an
+// exponentially weighted moving average; a consumer without any ACK yet
+// counts as fast, so that every consumer gets probed.
+type LatencyAwareDestination struct {
+	Weight float64 // Weight of the newest round-trip time in the average
+	recent map[string]float64
+}
+
+// NewLatencyAwareDestination creates the policy with the given weight of new
+// round-trip times
+func NewLatencyAwareDestination(weight float64) *LatencyAwareDestination {
+	return &LatencyAwareDestination{
+		Weight: weight,
+		recent: make(map[string]float64),
+	}
+}
+
+// Pick returns the faster of two random consumers
+func (d *LatencyAwareDestination) Pick(consumers []string, rng *rand.Rand) string {
+	if len(consumers) == 1 {
+		return consumers[0]
+	}
+
+	i, j := randomPair(len(consumers), rng)
+	if d.recent[consumers[j]] < d.recent[consumers[i]] {
+		return consumers[j]
+	}
+	return consumers[i]
+}
+
+// Observe folds a round-trip time into the consumer's moving average
+func (d *LatencyAwareDestination) Observe(consumer string, rtt sim.VTimeInSec) {
+	prev, ok := d.recent[consumer]
+	if !ok {
+		d.recent[consumer] = float64(rtt)
+		return
+	}
+	d.recent[consumer] = prev + d.Weight*(float64(rtt)-prev)
+}
+
+// Recent returns the moving average of the consumer's round-trip times
+func (d *LatencyAwareDestination) Recent(consumer string) float64 {
+	return d.recent[consumer]
+}
+
+// String names the policy and the weight of new round-trip times
+func (d *LatencyAwareDestination) String() string {
+	return fmt.Sprintf("latency-aware, new round-trip times weigh %.2f", d.Weight)
+}
+
+// QueueAwareDestination applies the power of two choices to the current
+// queue lengths: it samples two distinct consumers at random and picks the
+// one with fewer messages waiting in its RX queues
+type QueueAwareDestination struct {
+	QueueLen func(consumer string) int // Instantaneous queue length of a consumer
+}
+
+// Pick returns the less loaded of two random consumers
+func (d QueueAwareDestination) Pick(consumers []string, rng *rand.Rand) string {
+	if len(consumers) == 1 {
+		return consumers[0]
+	}
+
+	i, j := randomPair(len(consumers), rng)
+	if d.QueueLen(consumers[j]) < d.QueueLen(consumers[i]) {
+		return consumers[j]
+	}
+	return consumers[i]
+}
+
+// Observe ignores the round-trip times
+func (QueueAwareDestination) Observe(consumer string, rtt sim.VTimeInSec) {}
+
+// String names the policy
+func (QueueAwareDestination) String() string {
+	return "power of two choices by queue length"
+}
+
+// randomPair returns two distinct indices below n. The pair is drawn at
+// once, so that the random stream stays aligned with random destinations
+// and all policies see the same traffic.
+func randomPair(n int, rng *rand.Rand) (int, int) {
+	pair := rng.Intn(n * (n - 1))
+	i, j := pair/(n-1), pair%(n-1)
+	if j >= i {
+		j++
+	}
+	return i, j
+}
@@ -0,0 +1,69 @@
+package demo
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/syifan/akita_demo/producer"
+)
+
+// TestLatencyAwareDestinationAvoidsSlowConsumer verifies that the slowest
+// consumer is never picked, since it loses every comparison
+func TestLatencyAwareDestinationAvoidsSlowConsumer(t *testing.T) {
+	policy := NewLatencyAwareDestination(0.5)
+	policy.Observe("Consumer1", 2)
+	policy.Observe("Consumer2", 3)
+	policy.Observe("Consumer3", 10)
+	
+	consumers := []string{"Consumer1", "Consumer2", "Consumer3"}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if dest := policy.Pick(consumers, rng); dest == "Consumer3" {
+			t.Fatalf("Expected the slow consumer never to be picked")
+		}
+	}
+}
+
+// TestLatencyAwareDestinationAveragesRTTs verifies the moving average of the
+// round-trip times
+func TestLatencyAwareDestinationAveragesRTTs(t *testing.T) {
+	policy := NewLatencyAwareDestination(0.5)
+	policy.Observe("Consumer1", 4)
+	policy.Observe("Consumer1", 2)
+	
+	if recent := policy.Recent("Consumer1"); recent != 3 {
+		t.Errorf("Expected a recent RTT of 3, got %.2f", recent)
+	}
+}
+
+// TestDestinationPoliciesSeeSameTraffic verifies that both policies draw the
+// same number of random numbers, so that they generate the same traffic
+func TestDestinationPoliciesSeeSameTraffic(t *testing.T) {
+	consumers := []string{"Consumer1", "Consumer2", "Consumer3"}
+	a := rand.New(rand.NewSource(5))
+	b := rand.New(rand.NewSource(5))
+	latency := NewLatencyAwareDestination(0.5)
+	for i := 0; i < 10; i++ {
+		producer.RandomDestination{}.Pick(consumers, a)
+		latency.Pick(consumers, b)
+	}
+	
+	if a.Int63() != b.Int63() {
+		t.Errorf("Expected the random streams to stay aligned")
+	}
+}
+
+// TestQueueAwareDestinationAvoidsLongestQueue verifies that the consumer with
+// the longest queue is never picked, since it loses every comparison
+func TestQueueAwareDestinationAvoidsLongestQueue(t *testing.T) {
+	depths := map[string]int{"Consumer1": 1, "Consumer2": 0, "Consumer3": 6}
+	policy := QueueAwareDestination{QueueLen: func(name string) int { return depths[name] }}
+	
+	consumers := []string{"Consumer1", "Consumer2", "Consumer3"}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if dest := policy.Pick(consumers, rng); dest == "Consumer3" {
+			t.Fatalf("Expected the consumer with the longest queue never to be picked")
+		}
+	}
+}
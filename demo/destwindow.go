@@ -0,0 +1,145 @@
+package demo
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// windowOccupancy is the time-weighted occupancy of the window of a consumer
+type windowOccupancy struct {
+	outstanding int
+	peak        int
+	since       sim.VTimeInSec // Time of the last change
+	area        float64        // Outstanding messages integrated over time
+	full        sim.VTimeInSec // Time spent with a full window
+	blocked     int            // Times a message waited for a full window
+}
+
+// DestinationWindows caps the number of unacknowledged messages the
+// distributor has outstanding at every consumer, independent of the capacity
+// of its ports. A consumer acknowledges a message to the distributor once it
+// consumed or dropped it. While the window of a consumer is full, its next
+// message waits at the head of the distributor's input queue, just as if its
+// output port were busy. Its methods are safe to call on nil windows.
+type DestinationWindows struct {
+	Limit  int            // Window of every consumer, 0 for no limit
+	limits map[string]int // Windows of single consumers
+	stats  map[string]*windowOccupancy
+}
+
+// NewDestinationWindows creates windows of limit messages, except for the
+// consumers given their own limit
+func NewDestinationWindows(limit int, limits map[string]int) *DestinationWindows {
+	return &DestinationWindows{
+		Limit:  limit,
+		limits: limits,
+		stats:  make(map[string]*windowOccupancy),
+	}
+}
+
+// LimitOf returns the window of a consumer, 0 for no limit
+func (w *DestinationWindows) LimitOf(dest string) int {
+	if limit, ok := w.limits[dest]; ok {
+		return limit
+	}
+	return w.Limit
+}
+
+// Outstanding returns the number of unacknowledged messages at a consumer
+func (w *DestinationWindows) Outstanding(dest string) int {
+	if w == nil || w.stats[dest] == nil {
+		return 0
+	}
+	return w.stats[dest].outstanding
+}
+
+// Full reports whether the window of a consumer admits no more messages
+func (w *DestinationWindows) Full(dest string) bool {
+	if w == nil {
+		return false
+	}
+	limit := w.LimitOf(dest)
+	return limit > 0 && w.Outstanding(dest) >= limit
+}
+
+// Block counts a message that has to wait for the full window of a consumer
+func (w *DestinationWindows) Block(dest string) {
+	if w == nil {
+		return
+	}
+	w.occupancy(dest).blocked++
+}
+
+// Sent counts a message forwarded to a consumer
+func (w *DestinationWindows) Sent(now sim.VTimeInSec, dest string) {
+	if w == nil {
+		return
+	}
+	w.change(now, dest, 1)
+}
+
+// Acked retires a message acknowledged by a consumer
+func (w *DestinationWindows) Acked(now sim.VTimeInSec, dest string) {
+	if w == nil || w.Outstanding(dest) == 0 {
+		return
+	}
+	w.change(now, dest, -1)
+}
+
+func (w *DestinationWindows) occupancy(dest string) *windowOccupancy {
+	o, ok := w.stats[dest]
+	if !ok {
+		o = &windowOccupancy{}
+		w.stats[dest] = o
+	}
+	return o
+}
+
+// change integrates the occupancy up to now before changing it by delta
+func (w *DestinationWindows) change(now sim.VTimeInSec, dest string, delta int) {
+	o := w.occupancy(dest)
+	w.integrate(now, dest, o)
+	o.outstanding += delta
+	if o.outstanding > o.peak {
+		o.peak = o.outstanding
+	}
+}
+
+func (w *DestinationWindows) integrate(now sim.VTimeInSec, dest string, o *windowOccupancy) {
+	o.area += float64(o.outstanding) * float64(now-o.since)
+	if limit := w.LimitOf(dest); limit > 0 && o.outstanding >= limit {
+		o.full += now - o.since
+	}
+	o.since = now
+}
+
+// Print writes the window, the peak and the mean occupancy, the share of
+// the run the window was full, and the messages blocked by it for every
+// consumer the distributor sent to
+func (w *DestinationWindows) Print(out EventSink, end sim.VTimeInSec) {
+	names := make([]string, 0, len(w.stats))
+	for name := range w.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out.Println("=== Destination Windows ===")
+	out.Printf("%-12s %6s %5s %6s %6s %8s %11s\n", "Consumer", "Window", "Peak", "Mean", "Full", "Blocked", "Outstanding")
+	for _, name := range names {
+		o := w.stats[name]
+		w.integrate(end, name, o)
+		mean, full := 0.0, 0.0
+		if end > 0 {
+			mean = o.area / float64(end)
+			full = float64(o.full) / float64(end) * 100
+		}
+		window := "-"
+		if limit := w.LimitOf(name); limit > 0 {
+			window = strconv.Itoa(limit)
+		}
+		out.Printf("%-12s %6s %5d %6.2f %5.1f%% %8d %11d\n",
+			name, window, o.peak, mean, full, o.blocked, o.outstanding)
+	}
+}
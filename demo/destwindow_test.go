@@ -0,0 +1,84 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestDestinationWindowsFillAndDrain verifies that a window admits messages
+// up to its limit, frees a slot per ACK, and integrates its occupancy
+func TestDestinationWindowsFillAndDrain(t *testing.T) {
+	w := NewDestinationWindows(2, map[string]int{"Consumer2": 0})
+	
+	w.Sent(0, "Consumer1")
+	if w.Full("Consumer1") {
+		t.Fatal("Expected room for a second message")
+	}
+	w.Sent(1, "Consumer1")
+	if !w.Full("Consumer1") {
+		t.Fatal("Expected the window to be full after two messages")
+	}
+	w.Acked(3, "Consumer1")
+	if w.Full("Consumer1") || w.Outstanding("Consumer1") != 1 {
+		t.Fatalf("Expected one outstanding message after the ACK, got %d", w.Outstanding("Consumer1"))
+	}
+	
+	for i := 0; i < 5; i++ {
+		w.Sent(0, "Consumer2")
+	}
+	if w.Full("Consumer2") {
+		t.Error("Expected no limit for Consumer2")
+	}
+	
+	o := w.stats["Consumer1"]
+	w.integrate(4, "Consumer1", o)
+	if o.peak != 2 || o.area != 6 || o.full != 2 {
+		t.Errorf("Expected peak 2, area 6, and 2 s full, got %d, %.1f, and %.1f", o.peak, o.area, float64(o.full))
+	}
+}
+
+// TestDestinationWindowLimitsOutstandingMessages verifies that the
+// distributor never has more messages outstanding at a consumer than its
+// window, and that every message is still consumed
+func TestDestinationWindowLimitsOutstandingMessages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 5
+	cfg.Cycles = 60
+	cfg.DestWindow = 1
+	cfg.ConsumeIntervals = map[string]float64{"Consumer3": 4}
+	silence(t, cfg)
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	blocked := 0
+	for name, o := range simulation.routing.windows.stats {
+		if o.peak > 1 {
+			t.Errorf("Expected at most 1 message outstanding at %s, got %d", name, o.peak)
+		}
+		if o.outstanding != 0 {
+			t.Errorf("Expected every message at %s to be acknowledged, got %d outstanding", name, o.outstanding)
+		}
+		blocked += o.blocked
+	}
+	if blocked == 0 {
+		t.Error("Expected the window to block messages of the slow consumer")
+	}
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected conservation to hold, got %+v", simulation.Conservation())
+	}
+}
+
+// TestDestWindowsRejectsUnknownConsumers verifies that a window override of
+// a consumer that does not exist is an error
+func TestDestWindowsRejectsUnknownConsumers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DestWindows = map[string]int{"Consumer9": 1}
+	if _, err := NewSimulation(cfg); err == nil {
+		t.Error("Expected an error for an unknown consumer")
+	}
+}
@@ -0,0 +1,78 @@
+package demo
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// DrainLimit wraps the engine of a run to bound the drain phase. After the
+// producer stops generating at stopTime, the engine keeps running until all
+// queues are empty, but events scheduled later than the drain timeout are
+// dropped so that a stuck run still ends.
+type DrainLimit struct {
+	sim.Engine
+
+	stopTime sim.VTimeInSec
+	deadline sim.VTimeInSec
+	ledger   *Ledger
+	stopped  bool
+
+	AtStop  int // Messages buffered when generation stopped
+	Dropped int // Events dropped after the deadline
+}
+
+// NewDrainLimit wraps engine so that it runs at most timeout seconds past
+// stopTime
+func NewDrainLimit(engine sim.Engine, stopTime, timeout sim.VTimeInSec) *DrainLimit {
+	d := &DrainLimit{
+		Engine:   engine,
+		stopTime: stopTime,
+		deadline: stopTime + timeout,
+	}
+	engine.AcceptHook(d)
+	return d
+}
+
+// Schedule forwards the event to the engine unless it is past the deadline
+func (d *DrainLimit) Schedule(evt sim.Event) {
+	if evt.Time() > d.deadline {
+		d.Dropped++
+		return
+	}
+	d.Engine.Schedule(evt)
+}
+
+// Func counts the buffered messages before the first event of the drain phase
+func (d *DrainLimit) Func(ctx sim.HookCtx) {
+	if ctx.Pos != sim.HookPosBeforeEvent || d.stopped || d.ledger == nil {
+		return
+	}
+	if evt, ok := ctx.Item.(sim.Event); ok && evt.Time() >= d.stopTime {
+		d.stopped = true
+		d.AtStop = d.ledger.InNetwork()
+	}
+}
+
+// TimedOut reports whether the drain phase was cut short by the deadline
+func (d *DrainLimit) TimedOut() bool {
+	return d.Dropped > 0
+}
+
+// Print writes how the drain phase went. left is the number of messages still
+// buffered at the end of the run.
+func (d *DrainLimit) Print(out EventSink, end sim.VTimeInSec, left int) {
+	drainTime := end - d.stopTime
+	if drainTime < 0 {
+		drainTime = 0
+	}
+
+	out.Println("=== Drain ===")
+	out.Printf("Stopped at:        %.2f s\n", float64(d.stopTime))
+	out.Printf("Buffered at stop:  %d\n", d.AtStop)
+	out.Printf("Drain time:        %.2f s (timeout %.2f s)\n",
+		float64(drainTime), float64(d.deadline-d.stopTime))
+	if d.TimedOut() {
+		out.Printf("Result:            timed out, %d messages abandoned\n", left)
+	} else {
+		out.Printf("Result:            drained, %d messages left\n", left)
+	}
+}
@@ -0,0 +1,64 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+type countingHandler struct {
+	handled []sim.VTimeInSec
+}
+
+func (h *countingHandler) Handle(e sim.Event) error {
+	h.handled = append(h.handled, e.Time())
+	return nil
+}
+
+// TestDrainLimitDropsEventsPastDeadline verifies that the drain phase ends at
+// the drain timeout even if events are still scheduled
+func TestDrainLimitDropsEventsPastDeadline(t *testing.T) {
+	handler := &countingHandler{}
+	drain := NewDrainLimit(sim.NewSerialEngine(), 10, 5)
+	
+	for _, at := range []sim.VTimeInSec{5, 12, 15, 16, 20} {
+		drain.Schedule(sim.NewEventBase(at, handler))
+	}
+	if err := drain.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(handler.handled) != 3 {
+		t.Errorf("Expected 3 events before the deadline, got %v", handler.handled)
+	}
+	if drain.Dropped != 2 || !drain.TimedOut() {
+		t.Errorf("Expected 2 dropped events, got %d", drain.Dropped)
+	}
+	if drain.CurrentTime() != 15 {
+		t.Errorf("Expected the run to end at 15, got %.2f", drain.CurrentTime())
+	}
+}
+
+// TestSimulationDrainsQueues verifies that the queues are drained after
+// generation stops when the timeout is long enough
+func TestSimulationDrainsQueues(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	cfg.Cycles = 40
+	cfg.ConsumeInterval = 4
+	cfg.DrainTimeout = 1000
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if simulation.drain.TimedOut() {
+		t.Errorf("Expected the queues to drain before the timeout")
+	}
+	if left := simulation.Conservation().InNetwork; left != 0 {
+		t.Errorf("Expected empty queues, %d messages left", left)
+	}
+}
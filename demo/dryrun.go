@@ -0,0 +1,138 @@
+package demo
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// DryRun prints the model of the simulation and checks its wiring without
+// running it. It returns whether the wiring is sound.
+func (s *Simulation) DryRun() bool {
+	s.Describe()
+	s.out.Println()
+	problems := s.CheckWiring()
+	s.out.Println("=== Wiring Check ===")
+	for _, problem := range problems {
+		s.out.Printf("- %s\n", problem)
+	}
+	if len(problems) > 0 {
+		s.out.Printf("Result:            %d problems\n", len(problems))
+		return false
+	}
+	s.out.Printf("Result:            OK (%d connections)\n", len(s.topology.connections))
+	return true
+}
+
+// CheckWiring returns the problems of the wiring: ports of the producers,
+// the distributors, the consumers, and the dead-letter sink that are not
+// plugged into a connection, connections that link fewer than two ports,
+// and destinations of a distributor that do not lead to the consumer or
+// the region serving them
+func (s *Simulation) CheckWiring() []string {
+	var problems []string
+	connections := make(map[sim.Port]*topologyConn)
+	for i := range s.topology.connections {
+		conn := &s.topology.connections[i]
+		if len(conn.ports) < 2 {
+			problems = append(problems, fmt.Sprintf("connection %s links fewer than two ports", conn.name))
+		}
+		for _, port := range conn.ports {
+			connections[port] = conn
+		}
+	}
+
+	for _, port := range s.ownPorts() {
+		if connections[port] == nil {
+			problems = append(problems, fmt.Sprintf("port %s is not connected", port.Name()))
+		}
+	}
+
+	consumers := make(map[string]*consumer.Consumer)
+	for _, c := range s.consumers {
+		consumers[c.Name()] = c
+	}
+	regions := make(map[string]*distributor.Distributor)
+	for _, d := range s.tree.Regions() {
+		regions[d.Name()] = d
+	}
+	for _, d := range s.tree.Distributors() {
+		for _, dest := range d.Destinations() {
+			c, ok := consumers[dest]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s routes %s, which is no consumer", d.Name(), dest))
+				continue
+			}
+			targets := c.RxPorts()
+			if region, ok := regions[d.NextHop(dest)]; ok {
+				targets = []sim.Port{region.InputPort()}
+			} else if s.workPool != nil {
+				targets = []sim.Port{s.workPool.inputPort}
+			}
+			if conn := connections[d.OutputPort(dest)]; conn == nil || !conn.links(targets) {
+				problems = append(problems, fmt.Sprintf("%s routes %s through %s, which does not lead to it",
+					d.Name(), dest, d.OutputPort(dest).Name()))
+			}
+		}
+	}
+	for _, c := range s.consumers {
+		if s.tree.Root.OutputPort(c.Name()) == nil {
+			problems = append(problems, fmt.Sprintf("%s is no destination of %s", c.Name(), s.tree.Root.Name()))
+		}
+	}
+	return problems
+}
+
+// links reports whether any of the ports is plugged into the connection
+func (c *topologyConn) links(ports []sim.Port) bool {
+	for _, p := range c.ports {
+		for _, port := range ports {
+			if p == port {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ownPorts returns the ports the components of the data and control paths
+// hold, connected or not
+func (s *Simulation) ownPorts() []sim.Port {
+	var ports []sim.Port
+	for _, p := range s.producers {
+		ports = append(ports, p.OutputPort(), p.CtrlPort())
+	}
+	for _, d := range s.tree.Distributors() {
+		ports = append(ports, d.InputPort(), d.CtrlPort())
+		seen := make(map[sim.Port]bool)
+		for _, dest := range d.Destinations() {
+			if port := d.OutputPort(dest); !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+		if d.DeadLetterSink() != nil {
+			ports = append(ports, d.DeadLetterPort())
+		}
+	}
+	for _, c := range s.consumers {
+		if s.workPool == nil {
+			ports = append(ports, c.RxPorts()...)
+		}
+		ports = append(ports, c.CtrlPort())
+	}
+	if s.deadLetters != nil {
+		ports = append(ports, s.deadLetters.inputPort)
+	}
+	return ports
+}
+
+// validateDryRun checks that a dry run builds a single simulation
+func (c *Config) validateDryRun() error {
+	if c.DryRun && (c.Scenario != "" || c.Bench > 0 || c.Runs > 1 || c.RPC) {
+		return fmt.Errorf("dry-run checks a single run, not a scenario, benchmark, replications, or RPC server")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCheckWiring verifies that the wiring of the models built from a
+// configuration is sound, and that unplugged ports and routes to unknown
+// consumers are reported
+func TestCheckWiring(t *testing.T) {
+	for _, change := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.DistributorDepth = 2; c.Consumers = 6 },
+		func(c *Config) { c.WorkPool = true },
+		func(c *Config) { c.Network = "mesh" },
+	} {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		change(cfg)
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems := simulation.CheckWiring(); len(problems) > 0 {
+			t.Errorf("Expected sound wiring, got %v", problems)
+		}
+	}
+	
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.distributor.AddDestination("Ghost")
+	simulation.topology.Unplug(simulation.consumers[1].RxPorts()[0])
+	problems := strings.Join(simulation.CheckWiring(), "\n")
+	for _, want := range []string{
+		"port Consumer2.In is not connected",
+		"Distributor routes Consumer2 through Distributor.Out.Consumer2, which does not lead to it",
+		"Distributor routes Ghost, which is no consumer",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected %q, got\n%s", want, problems)
+		}
+	}
+	if simulation.DryRun() {
+		t.Errorf("Expected the dry run to fail")
+	}
+	
+	cfg.DryRun = true
+	cfg.Runs = 2
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Expected a dry run of replications to be rejected")
+	}
+}
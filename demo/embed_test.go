@@ -0,0 +1,57 @@
+package demo_test
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/demo"
+)
+
+// TestEmbeddedSession verifies that a program importing the package can build
+// a simulation, run it in segments, tune it in between, and finish it
+func TestEmbeddedSession(t *testing.T) {
+	cfg := demo.DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	cfg.Sink = demo.NullSink{}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	simulation, err := demo.NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	session := simulation.Start()
+	status, err := session.RunTo(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Paused || status.Time >= 30 {
+		t.Fatalf("Expected the run to stop before 30, got %+v", status)
+	}
+	state, err := session.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Produced == 0 || len(state.Consumers) != cfg.Consumers {
+		t.Errorf("Expected messages from the first segment and every consumer, got %+v", state)
+	}
+	if _, err := session.Tune(demo.ParamChange{Param: "weight", Target: "Consumer1", Value: 0}); err != nil {
+		t.Fatal(err)
+	}
+	producer := simulation.Producers()[0]
+	addressed := producer.SeqNum("Consumer1")
+	if err := session.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if producer.SeqNum("Consumer1") != addressed {
+		t.Errorf("Expected no messages for Consumer1 after its weight dropped to 0, got %d more", producer.SeqNum("Consumer1")-addressed)
+	}
+	if c := simulation.Conservation(); c.Produced <= state.Produced || !c.Holds() {
+		t.Errorf("Expected the run to go on after the segment and account for every message, got %+v", c)
+	}
+	if simulation.ReportFailures() {
+		t.Error("Expected the run to pass its checks")
+	}
+}
@@ -0,0 +1,224 @@
+package demo
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// NewEngine creates the engine of a run: serial or parallel
+func NewEngine(kind string) sim.Engine {
+	if kind == "parallel" {
+		return NewParallelEngine()
+	}
+	return sim.NewSerialEngine()
+}
+
+// ParallelEngine runs a simulation on Akita's scheduling of the parallel
+// engine without its parallelism. The components of this demo share their
+// statistics, trackers, and output and are not safe for concurrent ticks, so
+// every handler and hook runs under one lock: the events of a time run one
+// at a time, but in the order the goroutines of the parallel engine pick
+// them rather than the serial engine's. It checks that the results do not
+// depend on the order of the events of a time, not that the components can
+// tick concurrently, and it is no faster than the serial engine.
+type ParallelEngine struct {
+	*sim.ParallelEngine
+	mu sync.Mutex
+}
+
+// NewParallelEngine creates a parallel engine with as many event queues as
+// the Go runtime has processors
+func NewParallelEngine() *ParallelEngine {
+	return &ParallelEngine{ParallelEngine: sim.NewParallelEngine()}
+}
+
+// Schedule hands the event to Akita's engine with a handler that holds the
+// lock while it runs
+func (e *ParallelEngine) Schedule(evt sim.Event) {
+	e.ParallelEngine.Schedule(&lockedEvent{Event: evt, engine: e})
+}
+
+// AcceptHook registers a hook that holds the lock while it runs and sees the
+// events as they were scheduled
+func (e *ParallelEngine) AcceptHook(hook sim.Hook) {
+	e.ParallelEngine.AcceptHook(&lockedHook{Hook: hook, engine: e})
+}
+
+// lockedEvent wraps an event so that it is handled under the engine's lock
+type lockedEvent struct {
+	sim.Event
+	engine *ParallelEngine
+}
+
+// Handler returns the event itself, which handles the wrapped event
+func (e *lockedEvent) Handler() sim.Handler {
+	return e
+}
+
+// Handle passes the wrapped event to its handler under the lock
+func (e *lockedEvent) Handle(sim.Event) error {
+	e.engine.mu.Lock()
+	defer e.engine.mu.Unlock()
+	return e.Event.Handler().Handle(e.Event)
+}
+
+type lockedHook struct {
+	sim.Hook
+	engine *ParallelEngine
+}
+
+// Func passes the hook context with the wrapped event to the hook under the
+// lock
+func (h *lockedHook) Func(ctx sim.HookCtx) {
+	if evt, ok := ctx.Item.(*lockedEvent); ok {
+		ctx.Item = evt.Event
+	}
+	h.engine.mu.Lock()
+	defer h.engine.mu.Unlock()
+	h.Hook.Func(ctx)
+}
+
+// EngineRun summarizes the run of one engine in the engine check
+type EngineRun struct {
+	Engine    string
+	Duration  sim.VTimeInSec
+	Events    int
+	WallClock time.Duration
+	Consumers map[string]ConsumerOutcome
+}
+
+// ConsumerOutcome is the consumption of one consumer in the engine check
+type ConsumerOutcome struct {
+	Consumed    int
+	MeanLatency float64
+}
+
+// RunEngineCheck runs the same configuration on the serial and the parallel
+// engine with the same seed and returns both runs
+func RunEngineCheck(cfg *Config) ([]EngineRun, error) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var runs []EngineRun
+	for _, engine := range []string{"serial", "parallel"} {
+		runCfg := *cfg
+		runCfg.Seed = seed
+		runCfg.Engine = engine
+		// Only the comparison is printed, the runs are silent
+		runCfg.Sink = NullSink{}
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		err = simulation.Run()
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, EngineRun{
+			Engine:    engine,
+			Duration:  simulation.Duration(),
+			Events:    simulation.stats.EngineEvents,
+			WallClock: time.Since(start),
+			Consumers: simulation.consumerOutcomes(),
+		})
+	}
+	return runs, nil
+}
+
+// consumerOutcomes returns the messages consumed by every consumer and their
+// mean end-to-end latency
+func (s *Simulation) consumerOutcomes() map[string]ConsumerOutcome {
+	outcomes := make(map[string]ConsumerOutcome)
+	sums := make(map[string]float64)
+	for _, c := range s.consumers {
+		outcomes[c.Name()] = ConsumerOutcome{}
+	}
+	for pair, latencies := range s.stats.pairLatencies {
+		o := outcomes[pair.Consumer]
+		o.Consumed += len(latencies)
+		for _, latency := range latencies {
+			sums[pair.Consumer] += latency
+		}
+		outcomes[pair.Consumer] = o
+	}
+	for name, o := range outcomes {
+		if o.Consumed > 0 {
+			o.MeanLatency = sums[name] / float64(o.Consumed)
+			outcomes[name] = o
+		}
+	}
+	return outcomes
+}
+
+// EngineDiffs returns the consumers whose consumption or latency differs
+// between the runs of the engine check
+func EngineDiffs(serial, parallel EngineRun) []string {
+	var names []string
+	for name := range serial.Consumers {
+		names = append(names, name)
+	}
+	for name := range parallel.Consumers {
+		if _, ok := serial.Consumers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diffs []string
+	for _, name := range names {
+		s, p := serial.Consumers[name], parallel.Consumers[name]
+		if s.Consumed != p.Consumed || math.Abs(s.MeanLatency-p.MeanLatency) > 1e-9 {
+			diffs = append(diffs, name)
+		}
+	}
+	return diffs
+}
+
+// PrintEngineCheck writes the consumption of both runs side by side and
+// returns whether the engines agree
+func PrintEngineCheck(out EventSink, runs []EngineRun) bool {
+	serial, parallel := runs[0], runs[1]
+	diffs := EngineDiffs(serial, parallel)
+	differs := make(map[string]bool)
+	for _, name := range diffs {
+		differs[name] = true
+	}
+
+	out.Println("=== Engine Check ===")
+	for _, r := range runs {
+		out.Printf("%-9s %d events, ended at %.2f s, %.2f ms wall clock\n",
+			r.Engine+":", r.Events, float64(r.Duration), float64(r.WallClock.Microseconds())/1000)
+	}
+	out.Printf("%-12s %16s %16s %s\n", "Consumer", "Serial", "Parallel", "Result")
+	var names []string
+	for name := range serial.Consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, p := serial.Consumers[name], parallel.Consumers[name]
+		result := "same"
+		if differs[name] {
+			result = "DIFFERS"
+		}
+		out.Printf("%-12s %5d / %6.2f s %5d / %6.2f s %s\n",
+			name, s.Consumed, s.MeanLatency, p.Consumed, p.MeanLatency, result)
+	}
+
+	if len(diffs) > 0 {
+		out.Printf("Result:            %d of %d consumers differ\n", len(diffs), len(names))
+		return false
+	}
+	out.Println("Result:            equivalent")
+	return true
+}
@@ -0,0 +1,46 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestParallelEngineMatchesSerial verifies that a congested run consumes the
+// same messages with the same latencies on both engines
+func TestParallelEngineMatchesSerial(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 5
+	cfg.Cycles = 100
+	cfg.ConsumeInterval = 3
+	cfg.RxQueues = 2
+	
+	runs, err := RunEngineCheck(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	serial, parallel := runs[0], runs[1]
+	if serial.Events == 0 || serial.Events != parallel.Events {
+		t.Errorf("Expected the same number of events, got %d and %d", serial.Events, parallel.Events)
+	}
+	if diffs := EngineDiffs(serial, parallel); len(diffs) > 0 {
+		t.Errorf("Expected equivalent runs, %v differ: %v and %v", diffs, serial.Consumers, parallel.Consumers)
+	}
+}
+
+// TestEngineDiffsReportsDifferentConsumers verifies that a consumer with a
+// different count or latency is reported
+func TestEngineDiffsReportsDifferentConsumers(t *testing.T) {
+	serial := EngineRun{Consumers: map[string]ConsumerOutcome{
+		"Consumer1": {Consumed: 3, MeanLatency: 2},
+		"Consumer2": {Consumed: 4, MeanLatency: 2},
+	}}
+	parallel := EngineRun{Consumers: map[string]ConsumerOutcome{
+		"Consumer1": {Consumed: 3, MeanLatency: 2},
+		"Consumer2": {Consumed: 4, MeanLatency: 2.5},
+	}}
+	
+	diffs := EngineDiffs(serial, parallel)
+	if len(diffs) != 1 || diffs[0] != "Consumer2" {
+		t.Errorf("Expected Consumer2 to differ, got %v", diffs)
+	}
+}
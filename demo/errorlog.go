@@ -0,0 +1,142 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+)
+
+var errorKinds = []component.ErrorKind{
+	component.ErrUnknownDestination, component.ErrNoRoute, component.ErrNilRemotePort,
+	component.ErrBufferOverflow, component.ErrTTLExpiry, component.ErrTypeMismatch,
+}
+
+// StrictError is the error of a strict run that ran into an error of a kind
+// it did not expect
+type StrictError struct {
+	Kind    component.ErrorKind
+	Time    sim.VTimeInSec
+	Source  string
+	Message string
+}
+
+func (e *StrictError) Error() string {
+	return fmt.Sprintf("strict mode: unexpected %s at %.2f, %s: %s", e.Kind, float64(e.Time), e.Source, e.Message)
+}
+
+// ErrorLog logs the errors of a run and counts them by kind. In strict mode,
+// the first error of a kind that is not expected aborts the run.
+type ErrorLog struct {
+	mu       sync.Mutex
+	out      EventSink
+	counts   map[component.ErrorKind]int
+	strict   bool
+	expected map[component.ErrorKind]bool
+	aborted  *StrictError
+	Total    int
+}
+
+// NewErrorLog creates an error log that counts every error
+func NewErrorLog(out EventSink) *ErrorLog {
+	return &ErrorLog{out: out, counts: make(map[component.ErrorKind]int)}
+}
+
+// Strict makes the errors of the kinds other than the expected ones abort
+// the run. It returns the engine to build the components on, which stops
+// scheduling events once the run is aborted, so that the run ends after the
+// events already queued.
+func (l *ErrorLog) Strict(engine sim.Engine, expected []component.ErrorKind) sim.Engine {
+	l.strict = true
+	l.expected = make(map[component.ErrorKind]bool)
+	for _, kind := range expected {
+		l.expected[kind] = true
+	}
+	return &abortingEngine{Engine: engine, log: l}
+}
+
+// Report logs an error of a component at now and counts it. A nil log
+// ignores the error.
+func (l *ErrorLog) Report(now sim.VTimeInSec, source string, kind component.ErrorKind, format string, args ...interface{}) {
+	if l == nil {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+	l.out.Printf("[%.2f] %s: %s\n", now, source, message)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[kind]++
+	l.Total++
+	if l.strict && !l.expected[kind] && l.aborted == nil {
+		l.aborted = &StrictError{Kind: kind, Time: now, Source: source, Message: message}
+		l.out.Printf("[%.2f] Strict mode: Aborting the run on %s\n", now, kind)
+	}
+}
+
+// Count returns the number of errors of a kind
+func (l *ErrorLog) Count(kind component.ErrorKind) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[kind]
+}
+
+// Err returns the error that aborted a strict run, or nil
+func (l *ErrorLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.aborted == nil {
+		return nil
+	}
+	return l.aborted
+}
+
+// Print writes the error counts by kind
+func (l *ErrorLog) Print() {
+	l.out.Println("=== Errors ===")
+	l.out.Printf("Errors:            %d\n", l.Total)
+	for _, kind := range errorKinds {
+		if n := l.counts[kind]; n > 0 {
+			l.out.Printf("  %-24s %d\n", string(kind)+":", n)
+		}
+	}
+}
+
+// parseErrorKinds reads a list of error kinds
+func parseErrorKinds(names []string) ([]component.ErrorKind, error) {
+	kinds := make([]component.ErrorKind, 0, len(names))
+	for _, name := range names {
+		kind := component.ErrorKind(name)
+		found := false
+		for _, k := range errorKinds {
+			found = found || k == kind
+		}
+		if !found {
+			known := make([]string, len(errorKinds))
+			for i, k := range errorKinds {
+				known[i] = string(k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown error kind %q, must be one of %s", name, strings.Join(known, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// abortingEngine drops the events scheduled after a strict run was aborted
+type abortingEngine struct {
+	sim.Engine
+	log *ErrorLog
+}
+
+// Schedule forwards the event to the engine unless the run was aborted
+func (e *abortingEngine) Schedule(evt sim.Event) {
+	if e.log.Err() != nil {
+		return
+	}
+	e.Engine.Schedule(evt)
+}
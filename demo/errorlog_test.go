@@ -0,0 +1,89 @@
+package demo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestStrictModeAbortsOnUnexpectedErrors verifies that a strict run ends
+// with the first error of an unexpected kind, and runs to the end when the
+// kind is expected
+func TestStrictModeAbortsOnUnexpectedErrors(t *testing.T) {
+	run := func(expected ...string) (*Simulation, error) {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 40
+		cfg.TTL = 2
+		cfg.ConsumeInterval = 3
+		cfg.StrictErrors = true
+		cfg.ExpectedErrors = expected
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return simulation, simulation.Run()
+	}
+	
+	aborted, err := run()
+	var strictErr *StrictError
+	if !errors.As(err, &strictErr) || strictErr.Kind != component.ErrTTLExpiry {
+		t.Fatalf("Expected the run to abort on a TTL expiry, got %v", err)
+	}
+	
+	finished, err := run("ttl-expiry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, f := aborted.Conservation().Produced, finished.Conservation().Produced; a >= f {
+		t.Errorf("Expected the aborted run to stop generating, produced %d messages against %d", a, f)
+	}
+	if n := finished.errors.Count(component.ErrTTLExpiry); n == 0 || n != finished.errors.Total {
+		t.Errorf("Expected only TTL expiries, got %d of %d errors", n, finished.errors.Total)
+	}
+}
+
+// TestDeadLettersAreCountedByKind verifies that the dead letters of the
+// distributor are counted under the kinds of their reasons
+func TestDeadLettersAreCountedByKind(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	errorLog := NewErrorLog(NullSink{})
+	sink := NewDeadLetterSink("DeadLetterSink", engine)
+	sink.errors = errorLog
+	d := distributor.New("Distributor", engine, []string{"Consumer1"},
+		distributor.WithDeadLetters(sink.inputPort))
+	
+	conn := sim.NewDirectConnection("DistributorToDeadLetterSink", engine, 1*sim.Hz)
+	conn.PlugIn(d.DeadLetterPort(), 1)
+	conn.PlugIn(sink.inputPort, 1)
+	
+	for _, dest := range []string{"Consumer9", "Consumer1"} {
+		m := &msg.DemoMessage{Destination: dest}
+		m.Meta().Dst = d.InputPort()
+		d.InputPort().Recv(m)
+	}
+	d.TickNow(0)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if errorLog.Count(component.ErrUnknownDestination) != 1 || errorLog.Count(component.ErrNoRoute) != 1 || errorLog.Total != 2 {
+		t.Errorf("Expected 1 unknown-destination and 1 no-route error, got %d and %d of %d",
+			errorLog.Count(component.ErrUnknownDestination), errorLog.Count(component.ErrNoRoute), errorLog.Total)
+	}
+}
+
+// TestExpectedErrorsMustBeKnownKinds verifies that the configuration rejects
+// unknown error kinds
+func TestExpectedErrorsMustBeKnownKinds(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ExpectedErrors = []string{"ttl-expiry", "gremlins"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an unknown error kind to be rejected")
+	}
+}
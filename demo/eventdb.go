@@ -0,0 +1,234 @@
+package demo
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/sarchlab/akita/v3/sim"
+
+	// Registers the SQLite driver the event database is written with
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// MsgEvent is a message passing through a port, or a decision a component
+// made about it
+type MsgEvent struct {
+	Time      sim.VTimeInSec
+	Event     string // send, recv, retrieve, decision, or outcome
+	Component string
+	Port      string
+	MsgID     uint64
+	Detail    string // Destination of a send, what was decided for decisions and outcomes
+}
+
+// EventDB records every send, arrival, and retrieval of a message at the
+// watched ports, and writes them to the events table of a SQLite database,
+// so that the run can be analyzed with SQL after the fact. A retrieval at a
+// consumer port is the consumption of the message. The components also record the decisions they make about a message, which
+// go to a separate decisions table. Its Decide and Conclude methods are safe
+// to call on a nil database.
+type EventDB struct {
+	timeTeller sim.TimeTeller
+	Events     []MsgEvent
+}
+
+// NewEventDB creates an empty event database
+func NewEventDB(timeTeller sim.TimeTeller) *EventDB {
+	return &EventDB{timeTeller: timeTeller}
+}
+
+// Watch records the messages passing through a port
+func (db *EventDB) Watch(port sim.Port) {
+	port.AcceptHook(db)
+}
+
+// Func records the send, arrival, or retrieval of a message
+func (db *EventDB) Func(ctx sim.HookCtx) {
+	port, ok := ctx.Domain.(sim.Port)
+	if !ok {
+		return
+	}
+	m, ok := ctx.Item.(*msg.DemoMessage)
+	if !ok {
+		return
+	}
+
+	var event string
+	switch ctx.Pos {
+	case sim.HookPosPortMsgSend:
+		event = "send"
+	case sim.HookPosPortMsgRecvd:
+		event = "recv"
+	case sim.HookPosPortMsgRetrieve:
+		event = "retrieve"
+	default:
+		return
+	}
+
+	db.Events = append(db.Events, MsgEvent{
+		Time:      db.timeTeller.CurrentTime(),
+		Event:     event,
+		Component: port.Component().Name(),
+		Port:      port.Name(),
+		MsgID:     m.ID,
+		Detail:    m.Destination,
+	})
+}
+
+// Decide records a decision a component made about a message that stays in
+// the network
+func (db *EventDB) Decide(now sim.VTimeInSec, component string, msgID uint64, format string, args ...interface{}) {
+	db.record(now, "decision", component, msgID, format, args)
+}
+
+// Conclude records the decision that took a message out of the network:
+// its consumption or why it was dropped
+func (db *EventDB) Conclude(now sim.VTimeInSec, component string, msgID uint64, format string, args ...interface{}) {
+	db.record(now, "outcome", component, msgID, format, args)
+}
+
+func (db *EventDB) record(now sim.VTimeInSec, event, component string, msgID uint64, format string, args []interface{}) {
+	if db == nil {
+		return
+	}
+	db.Events = append(db.Events, MsgEvent{
+		Time:      now,
+		Event:     event,
+		Component: component,
+		MsgID:     msgID,
+		Detail:    fmt.Sprintf(format, args...),
+	})
+}
+
+// eventDBSchema creates the tables of the event database. The seq column
+// orders the rows of both tables as they were recorded, and the run table
+// holds the time the run ended.
+var eventDBSchema = []string{
+	"CREATE TABLE events (time REAL, event TEXT, component TEXT, port TEXT, msg_id INTEGER, destination TEXT, seq INTEGER)",
+	"CREATE TABLE decisions (time REAL, component TEXT, msg_id INTEGER, detail TEXT, final INTEGER, seq INTEGER)",
+	"CREATE TABLE run (end_time REAL)",
+}
+
+// eventDBIndexes speed up following a message through the tables
+var eventDBIndexes = []string{
+	"CREATE INDEX events_msg_id ON events (msg_id)",
+	"CREATE INDEX decisions_msg_id ON decisions (msg_id)",
+}
+
+// Export writes the events to a SQLite database file, replacing the file if
+// it exists. The port events go to the events table, the decisions to the
+// decisions table.
+func (db *EventDB) Export(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	if err := db.insert(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return tx.Commit()
+}
+
+// insert creates the tables and fills them with the events
+func (db *EventDB) insert(tx *sql.Tx) error {
+	for _, stmt := range eventDBSchema {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	insertEvent, err := tx.Prepare("INSERT INTO events VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertEvent.Close()
+	insertDecision, err := tx.Prepare("INSERT INTO decisions VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insertDecision.Close()
+
+	for i, e := range db.Events {
+		switch e.Event {
+		case "decision", "outcome":
+			final := 0
+			if e.Event == "outcome" {
+				final = 1
+			}
+			_, err = insertDecision.Exec(float64(e.Time), e.Component, int64(e.MsgID), e.Detail, final, i)
+		default:
+			_, err = insertEvent.Exec(float64(e.Time), e.Event, e.Component, e.Port, int64(e.MsgID), e.Detail, i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("INSERT INTO run VALUES (?)", float64(db.timeTeller.CurrentTime())); err != nil {
+		return err
+	}
+
+	for _, stmt := range eventDBIndexes {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadEventDB reads the events of a message back from a database Export
+// wrote, in the order they were recorded, and the time the run ended
+func ReadEventDB(path string, msgID uint64) (*EventDB, sim.VTimeInSec, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, 0, err
+	}
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	var end float64
+	if err := conn.QueryRow("SELECT end_time FROM run").Scan(&end); err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	rows, err := conn.Query(`
+		SELECT time, event, component, port, destination, seq FROM events WHERE msg_id = ?1
+		UNION ALL
+		SELECT time, CASE final WHEN 1 THEN 'outcome' ELSE 'decision' END, component, '', detail, seq
+		FROM decisions WHERE msg_id = ?1
+		ORDER BY seq`, int64(msgID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	defer rows.Close()
+
+	db := &EventDB{}
+	for rows.Next() {
+		var (
+			e    = MsgEvent{MsgID: msgID}
+			time float64
+			seq  int64
+		)
+		if err := rows.Scan(&time, &e.Event, &e.Component, &e.Port, &e.Detail, &seq); err != nil {
+			return nil, 0, fmt.Errorf("reading %s: %w", path, err)
+		}
+		e.Time = sim.VTimeInSec(time)
+		db.Events = append(db.Events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return db, sim.VTimeInSec(end), nil
+}
@@ -0,0 +1,112 @@
+package demo
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/msg"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// TestEventDBRecordsMessageEvents verifies that the send, arrival, and
+// retrieval of a message are recorded and written to the database
+func TestEventDBRecordsMessageEvents(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	p := producer.New("Producer", engine, 100)
+	c := consumer.New("Consumer1", engine, 1.0)
+	db := NewEventDB(engine)
+	db.Watch(p.OutputPort())
+	db.Watch(c.InputPort())
+	
+	conn := sim.NewDirectConnection("ProducerToConsumer", engine, 1*sim.Hz)
+	conn.PlugIn(p.OutputPort(), 1)
+	conn.PlugIn(c.InputPort(), 1)
+	
+	m := &msg.DemoMessage{ID: 3, Destination: "Consumer1"}
+	m.Meta().Src = p.OutputPort()
+	m.Meta().Dst = c.InputPort()
+	p.OutputPort().Send(m)
+	c.InputPort().Recv(m)
+	c.InputPort().Retrieve(0)
+	
+	want := []string{"send", "recv", "retrieve"}
+	if len(db.Events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(db.Events))
+	}
+	for i, e := range db.Events {
+		if e.Event != want[i] || e.MsgID != 3 {
+			t.Errorf("Expected event %d to be %s of #3, got %s of #%d", i, want[i], e.Event, e.MsgID)
+		}
+	}
+	if db.Events[2].Component != "Consumer1" {
+		t.Errorf("Expected the retrieval at Consumer1, got %s", db.Events[2].Component)
+	}
+	
+	path := filepath.Join(t.TempDir(), "run.sqlite")
+	if err := db.Export(path); err != nil {
+		t.Fatal(err)
+	}
+	rows := queryEventDB(t, path, "SELECT event, component, port FROM events WHERE msg_id = 3 ORDER BY rowid")
+	if got := strings.Join(rows, "; "); got != "send Producer Producer.Out; recv Consumer1 Consumer1.In; retrieve Consumer1 Consumer1.In" {
+		t.Errorf("Expected the send, arrival, and retrieval of #3 in the database, got %s", got)
+	}
+}
+
+// TestEventDBExportReplacesFile verifies that exporting again replaces the
+// database instead of adding to it
+func TestEventDBExportReplacesFile(t *testing.T) {
+	db := NewEventDB(sim.NewSerialEngine())
+	db.Conclude(2, "Consumer1", 5, "consumed")
+	path := filepath.Join(t.TempDir(), "run.sqlite")
+	for i := 0; i < 2; i++ {
+		if err := db.Export(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	
+	rows := queryEventDB(t, path, "SELECT component, msg_id, detail, final FROM decisions")
+	if got := strings.Join(rows, "; "); got != "Consumer1 5 consumed 1" {
+		t.Errorf("Expected the single outcome of #5, got %s", got)
+	}
+}
+
+// queryEventDB opens an exported event database and returns the rows of the
+// query, their columns separated by spaces
+func queryEventDB(t *testing.T, path, query string) []string {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	
+	rows, err := conn.Query(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for rows.Next() {
+		values := make([]string, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, strings.Join(values, " "))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// EventSink receives the human-readable output of the simulation: the log of
+// what the components do and the reports at the end of a run
+type EventSink interface {
+	Printf(format string, args ...interface{})
+	Println(args ...interface{})
+	// Close flushes the output that is still buffered
+	Close() error
+}
+
+// ConsoleSink writes the output to the standard output
+type ConsoleSink struct{}
+
+// Printf formats and writes a line
+func (ConsoleSink) Printf(format string, args ...interface{}) {
+	fmt.Printf(format, args...)
+}
+
+// Println writes the arguments followed by a newline
+func (ConsoleSink) Println(args ...interface{}) {
+	fmt.Println(args...)
+}
+
+// Close does nothing
+func (ConsoleSink) Close() error { return nil }
+
+// FileSink writes the output to a file through a buffer
+type FileSink struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewFileSink creates the file the output is written to
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Printf formats and writes a line
+func (s *FileSink) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(s.w, format, args...)
+}
+
+// Println writes the arguments followed by a newline
+func (s *FileSink) Println(args ...interface{}) {
+	fmt.Fprintln(s.w, args...)
+}
+
+// Close flushes the buffer and closes the file
+func (s *FileSink) Close() error {
+	if err := s.w.Flush(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
+
+// NullSink discards the output without formatting it, for benchmark runs
+type NullSink struct{}
+
+// Printf does nothing
+func (NullSink) Printf(format string, args ...interface{}) {}
+
+// Println does nothing
+func (NullSink) Println(args ...interface{}) {}
+
+// Close does nothing
+func (NullSink) Close() error { return nil }
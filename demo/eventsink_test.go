@@ -0,0 +1,65 @@
+package demo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// silence discards the output of the runs of cfg until the test ends
+func silence(t testing.TB, cfg *Config) {
+	sink := cfg.Sink
+	cfg.Sink = NullSink{}
+	t.Cleanup(func() { cfg.Sink = sink })
+}
+
+// TestFileSinkWritesOutputOnClose verifies that the buffered output of a
+// file sink ends up in the file
+func TestFileSinkWritesOutputOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	sink.Printf("[%.2f] %s\n", 1.0, "Producer: Generated message")
+	sink.Println("=== Statistics ===")
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[1.00] Producer: Generated message\n=== Statistics ===\n"; string(content) != want {
+		t.Errorf("Expected %q, got %q", want, content)
+	}
+}
+
+// TestNullSinkLeavesResultsUnchanged verifies that disabling the output
+// does not change what the simulation measures
+func TestNullSinkLeavesResultsUnchanged(t *testing.T) {
+	run := func(sink EventSink) *Stats {
+		cfg := DefaultConfig()
+		cfg.Seed = 5
+		cfg.Cycles = 50
+		cfg.Sink = sink
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return simulation.stats
+	}
+	
+	console := run(ConsoleSink{})
+	null := run(NullSink{})
+	if console.Produced != null.Produced || console.Consumed != null.Consumed || console.MeanLatency() != null.MeanLatency() {
+		t.Errorf("Expected the same results, got %d/%d/%.2f and %d/%d/%.2f",
+			console.Produced, console.Consumed, console.MeanLatency(),
+			null.Produced, null.Consumed, null.MeanLatency())
+	}
+}
@@ -0,0 +1,107 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// ExplainMessage reads the events of a message from the event database at
+// path and prints the life of the message
+func ExplainMessage(out EventSink, path string, msgID uint64) error {
+	db, end, err := ReadEventDB(path, msgID)
+	if err != nil {
+		return err
+	}
+	if !db.Explain(out, msgID, end) {
+		return fmt.Errorf("message #%d is not in %s", msgID, path)
+	}
+	return nil
+}
+
+// leaveQueuesFirst moves every retrieval ahead of the other events its
+// component recorded at the same time. A component takes a message out of a
+// queue after it forwarded or judged it, but the message left the queue
+// first.
+func leaveQueuesFirst(events []MsgEvent) {
+	for i := range events {
+		if events[i].Event != "retrieve" {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			prev := events[j-1]
+			if prev.Time != events[j].Time || prev.Component != events[j].Component || prev.Event == "recv" {
+				break
+			}
+			events[j-1], events[j] = events[j], prev
+		}
+	}
+}
+
+// Explain writes the life of a message as a narrative: its generation, every
+// hop and every queue it waited in, the decisions the components made about
+// it, and its fate. It returns false if the message was never recorded.
+func (db *EventDB) Explain(out EventSink, msgID uint64, end sim.VTimeInSec) bool {
+	var events []MsgEvent
+	for _, e := range db.Events {
+		if e.MsgID == msgID {
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		return false
+	}
+	leaveQueuesFirst(events)
+
+	out.Printf("=== Message #%d ===\n", msgID)
+	var (
+		generated bool
+		sent      sim.VTimeInSec
+		transit   sim.VTimeInSec
+		waited    sim.VTimeInSec
+		queues    []string
+		outcome   string
+		arrivals  = make(map[string]sim.VTimeInSec)
+	)
+	for _, e := range events {
+		var line string
+		switch e.Event {
+		case "send":
+			// The producer's send is the first event of a message
+			if !generated {
+				generated = true
+				line = fmt.Sprintf("generated for %s, ", e.Detail)
+			}
+			sent = e.Time
+			line += "sent from " + e.Port
+		case "recv":
+			arrivals[e.Port] = e.Time
+			transit += e.Time - sent
+			line = fmt.Sprintf("arrived at %s after %.2f s in transit", e.Port, float64(e.Time-sent))
+		case "retrieve":
+			wait := e.Time - arrivals[e.Port]
+			waited += wait
+			queues = append(queues, fmt.Sprintf("%s %.2f s", e.Port, float64(wait)))
+			line = fmt.Sprintf("left %s after waiting %.2f s", e.Port, float64(wait))
+		case "outcome":
+			outcome = e.Detail
+			line = e.Detail
+		default:
+			line = e.Detail
+		}
+		out.Printf("[%.2f] %s: %s\n", e.Time, e.Component, line)
+	}
+
+	if outcome == "" {
+		outcome = fmt.Sprintf("still in the network at the end of the run at %.2f s", float64(end))
+	}
+	if len(queues) > 0 {
+		out.Printf("Queue waits:       %.2f s (%s)\n", float64(waited), strings.Join(queues, ", "))
+	} else {
+		out.Println("Queue waits:       none")
+	}
+	out.Printf("In transit:        %.2f s\n", float64(transit))
+	out.Printf("Outcome:           %s\n", outcome)
+	return true
+}
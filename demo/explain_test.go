@@ -0,0 +1,118 @@
+package demo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// TestExplainRecordsDecisionsAndOutcome verifies that a run written with
+// -db records the decisions about a message and its fate next to its hops,
+// and that explain reads them back from the database
+func TestExplainRecordsDecisionsAndOutcome(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	cfg.RxQueues = 2
+	cfg.Flows = 4
+	cfg.DBFile = filepath.Join(t.TempDir(), "run.sqlite")
+	silence(t, cfg)
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.eventDB.Export(cfg.DBFile); err != nil {
+		t.Fatal(err)
+	}
+	
+	var kinds []string
+	for _, e := range simulation.eventDB.Events {
+		if e.MsgID == 1 {
+			kinds = append(kinds, e.Event)
+		}
+	}
+	want := "send recv send decision retrieve recv retrieve outcome"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("Expected the hops, the steering, and the consumption of #1, got %s", got)
+	}
+	rows := queryEventDB(t, cfg.DBFile, "SELECT final FROM decisions WHERE msg_id = 1 ORDER BY rowid")
+	if got := strings.Join(rows, " "); got != "0 1" {
+		t.Errorf("Expected the steering and the consumption of #1 in the database, got %s", got)
+	}
+	
+	read, end, err := ReadEventDB(cfg.DBFile, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end != simulation.Duration() {
+		t.Errorf("Expected the run to end at %.2f, got %.2f", simulation.Duration(), end)
+	}
+	var recorded []MsgEvent
+	for _, e := range simulation.eventDB.Events {
+		if e.MsgID == 1 {
+			recorded = append(recorded, e)
+		}
+	}
+	if fmt.Sprint(read.Events) != fmt.Sprint(recorded) {
+		t.Errorf("Expected the events of #1 as recorded\n%v\ngot\n%v", recorded, read.Events)
+	}
+	if err := ExplainMessage(NullSink{}, cfg.DBFile, 1); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestExplainNeedsTheDatabaseAndTheMessage verifies that explain fails
+// instead of running the simulation when the database or the message is
+// missing
+func TestExplainNeedsTheDatabaseAndTheMessage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sqlite")
+	if err := ExplainMessage(NullSink{}, path, 1); err == nil {
+		t.Error("Expected a missing database to fail")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected a missing database not to be created")
+	}
+	
+	db := NewEventDB(sim.NewSerialEngine())
+	db.Conclude(2, "Consumer1", 5, "consumed")
+	if err := db.Export(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExplainMessage(NullSink{}, path, 5); err != nil {
+		t.Error(err)
+	}
+	if err := ExplainMessage(NullSink{}, path, 9999); err == nil {
+		t.Error("Expected #9999 not to be found")
+	}
+}
+
+// TestLeaveQueuesFirstOrdersRetrievals verifies that a message leaves a
+// queue before its component forwards it, but not before it arrived
+func TestLeaveQueuesFirstOrdersRetrievals(t *testing.T) {
+	events := []MsgEvent{
+		{Time: 1, Event: "recv", Component: "Distributor"},
+		{Time: 2, Event: "decision", Component: "Distributor"},
+		{Time: 2, Event: "send", Component: "Distributor"},
+		{Time: 2, Event: "retrieve", Component: "Distributor"},
+		{Time: 2, Event: "recv", Component: "Consumer1"},
+		{Time: 3, Event: "recv", Component: "Consumer1"},
+		{Time: 3, Event: "retrieve", Component: "Consumer1"},
+	}
+	leaveQueuesFirst(events)
+	
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Component+" "+e.Event)
+	}
+	want := "Distributor recv, Distributor retrieve, Distributor decision, Distributor send, Consumer1 recv, Consumer1 recv, Consumer1 retrieve"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ", "))
+	}
+}
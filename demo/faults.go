@@ -0,0 +1,468 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Kinds of the faults the injector causes
+const (
+	FaultLoss  = "loss"  // Messages on a connection are lost
+	FaultDown  = "down"  // A consumer loses the messages it holds and is sent
+	FaultStall = "stall" // A distributor routes no message
+)
+
+// Fault is a window of the fault schedule, from Start to End, and what it
+// did
+type Fault struct {
+	Kind        string
+	Target      string // Connection, consumer, or distributor, "*" for every data connection
+	Start, End  sim.VTimeInSec
+	Probability float64 // Share of the messages lost, for loss faults
+	Lost        int     // Messages lost to the fault
+	Delayed     int     // Consumed messages whose life overlapped the fault
+}
+
+// active reports whether the fault lasts at now
+func (f *Fault) active(now sim.VTimeInSec) bool {
+	return f.Start <= now && now < f.End
+}
+
+// controlConnections carry no messages of the data path, faults leave them
+// alone so that registrations and ACKs still arrive
+var controlConnections = map[string]bool{
+	"ControlPlane":                true,
+	"DistributorToDeadLetterSink": true,
+	"ConsumerPeers":               true,
+}
+
+// FaultInjector causes the faults of a schedule: it loses messages on
+// connections, takes consumers down, and stalls distributors, and measures
+// the latency of the messages delayed by a fault against the others. Random
+// losses draw from a source of their own, so that the faults of a seed are
+// the same whatever the traffic. Its methods are safe to call on a nil
+// injector.
+type FaultInjector struct {
+	Faults []*Fault
+	Seed   int64
+	File   string // Schedule file, empty if the faults were given otherwise
+	Refuse bool   // Consumers that are down leave their messages queued instead of losing them
+
+	mu         sync.Mutex // Connections send from the goroutines of the parallel engine
+	rand       *rand.Rand
+	out        EventSink
+	ledger     *Ledger
+	eventDB    *EventDB
+	affected   []float64 // Latencies of messages delayed by a fault
+	unaffected []float64
+}
+
+// NewFaultInjector creates an injector of the given faults whose random
+// losses draw from seed
+func NewFaultInjector(faults []*Fault, seed int64, out EventSink) *FaultInjector {
+	return &FaultInjector{
+		Faults: faults,
+		Seed:   seed,
+		rand:   rand.New(rand.NewSource(seed)),
+		out:    out,
+	}
+}
+
+// LoadFaultSchedule reads a fault schedule file. Every line that is not
+// empty and does not start with '#' has the form
+// "start,end,kind,target[,probability]", where kind is loss, down, or stall
+// and the probability is that of losing a message, for loss faults.
+func LoadFaultSchedule(path string) ([]*Fault, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var faults []*Fault
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fault, err := parseFault(strings.Split(line, ","))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		faults = append(faults, fault)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return faults, nil
+}
+
+// parseFault parses the fields of a line of a fault schedule
+func parseFault(fields []string) (*Fault, error) {
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) < 4 || len(fields) > 5 {
+		return nil, fmt.Errorf("expected start,end,kind,target[,probability]")
+	}
+	start, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start %q", fields[0])
+	}
+	end, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end %q", fields[1])
+	}
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("a fault must end after it starts, at 0 or later")
+	}
+	fault := &Fault{Kind: fields[2], Target: fields[3], Start: sim.VTimeInSec(start), End: sim.VTimeInSec(end)}
+	if fault.Target == "" {
+		return nil, fmt.Errorf("missing target")
+	}
+	switch fault.Kind {
+	case FaultLoss:
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("a loss fault needs a probability")
+		}
+		fault.Probability, err = strconv.ParseFloat(fields[4], 64)
+		if err != nil || fault.Probability <= 0 || fault.Probability > 1 {
+			return nil, fmt.Errorf("invalid probability %q, expected more than 0 and at most 1", fields[4])
+		}
+	case FaultDown, FaultStall:
+		if len(fields) > 4 {
+			return nil, fmt.Errorf("only loss faults have a probability")
+		}
+	default:
+		return nil, fmt.Errorf("unknown fault kind %q, expected loss, down, or stall", fault.Kind)
+	}
+	return fault, nil
+}
+
+// RandomFaults draws n faults of the given duration within the run, each a
+// consumer down or the distributor stalled
+func RandomFaults(n int, duration sim.VTimeInSec, stopTime sim.VTimeInSec, consumers []string, distributor string, rng *rand.Rand) []*Fault {
+	var faults []*Fault
+	latest := stopTime - duration
+	if latest < 0 {
+		latest = 0
+	}
+	for i := 0; i < n; i++ {
+		start := sim.VTimeInSec(math.Floor(rng.Float64() * float64(latest)))
+		fault := &Fault{Kind: FaultStall, Target: distributor, Start: start, End: start + duration}
+		if k := rng.Intn(len(consumers) + 1); k < len(consumers) {
+			fault.Kind, fault.Target = FaultDown, consumers[k]
+		}
+		faults = append(faults, fault)
+	}
+	return faults
+}
+
+// Faults creates the fault injector of the run from the schedule file, the
+// random faults, and the loss on every data connection, or returns nil if
+// the run has no faults. The injector logs the messages it loses to out.
+func (c *Config) Faults(consumers []string, distributor string, out EventSink) (*FaultInjector, error) {
+	if c.FaultSchedule == "" && c.RandomFaults == 0 && c.FaultLoss == 0 {
+		return nil, nil
+	}
+	seed := c.FaultSeed
+	if seed == 0 {
+		seed = c.Seed
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var faults []*Fault
+	if c.FaultSchedule != "" {
+		var err error
+		faults, err = LoadFaultSchedule(c.FaultSchedule)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.FaultLoss > 0 {
+		faults = append(faults, &Fault{Kind: FaultLoss, Target: "*", End: sim.VTimeInSec(c.Cycles), Probability: c.FaultLoss})
+	}
+	f := NewFaultInjector(faults, seed, out)
+	f.File = c.FaultSchedule
+	f.Refuse = c.DownMode == "refuse"
+	if c.RandomFaults > 0 {
+		// Drawn from a source of their own, so that the windows do not
+		// change with the losses
+		rng := rand.New(rand.NewSource(seed + 1))
+		f.Faults = append(f.Faults, RandomFaults(c.RandomFaults, sim.VTimeInSec(c.FaultDuration),
+			sim.VTimeInSec(c.Cycles), consumers, distributor, rng)...)
+	}
+	return f, nil
+}
+
+// Check reports a fault whose target does not exist, and losses under
+// per-consumer windows, which a lost message would hold for good
+func (f *FaultInjector) Check(
+	topology *Topology,
+	consumers []string,
+	distributors []*distributor.Distributor,
+	windows *DestinationWindows,
+) error {
+	if f == nil {
+		return nil
+	}
+	for _, fault := range f.Faults {
+		known := false
+		switch fault.Kind {
+		case FaultLoss:
+			if windows != nil {
+				return fmt.Errorf("loss faults cannot be combined with per-consumer windows")
+			}
+			known = fault.Target == "*" ||
+				(topology.HasConnection(fault.Target) && !controlConnections[fault.Target])
+		case FaultDown:
+			for _, name := range consumers {
+				known = known || name == fault.Target
+			}
+		case FaultStall:
+			for _, d := range distributors {
+				known = known || d.Name() == fault.Target
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown target %q of a %s fault", fault.Target, fault.Kind)
+		}
+	}
+	return nil
+}
+
+// Wrap returns a connection that loses messages sent over conn as the loss
+// faults of the connection say, or nil if none hits it
+func (f *FaultInjector) Wrap(name string, conn sim.Connection, engine sim.Engine) sim.Connection {
+	if f == nil || controlConnections[name] {
+		return nil
+	}
+	for _, fault := range f.Faults {
+		if fault.Kind == FaultLoss && (fault.Target == "*" || fault.Target == name) {
+			return &lossyConnection{Connection: conn, name: name, engine: engine, faults: f}
+		}
+	}
+	return nil
+}
+
+// lose decides whether a message sent over the connection at now is lost,
+// and counts it
+func (f *FaultInjector) lose(now sim.VTimeInSec, conn string, m *msg.DemoMessage) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fault := range f.Faults {
+		if fault.Kind != FaultLoss || !fault.active(now) || (fault.Target != "*" && fault.Target != conn) {
+			continue
+		}
+		if f.rand.Float64() >= fault.Probability {
+			return false
+		}
+		fault.Lost++
+		f.ledger.Lose()
+		f.out.Printf("[%.2f] Faults: Lost message for %s on %s\n", now, m.Destination, conn)
+		f.eventDB.Conclude(now, conn, m.ID, "lost on %s by an injected fault", conn)
+		return true
+	}
+	return false
+}
+
+// lossyConnection is a connection whose data messages may get lost on the
+// way. The sender sees a lost message as sent.
+type lossyConnection struct {
+	sim.Connection
+	name   string
+	engine sim.Engine
+	faults *FaultInjector
+}
+
+// Send passes the message on unless a loss fault takes it
+func (c *lossyConnection) Send(m sim.Msg) *sim.SendError {
+	demoMsg, ok := m.(*msg.DemoMessage)
+	if !ok || !c.Connection.CanSend(m.Meta().Src) {
+		return c.Connection.Send(m)
+	}
+	if c.faults.lose(c.engine.CurrentTime(), c.name, demoMsg) {
+		// Not released, the sender may still read it
+		return nil
+	}
+	return c.Connection.Send(m)
+}
+
+// active returns the fault of the kind that hits the target at now, or nil
+func (f *FaultInjector) active(kind, target string, now sim.VTimeInSec) *Fault {
+	if f == nil {
+		return nil
+	}
+	for _, fault := range f.Faults {
+		if fault.Kind == kind && fault.Target == target && fault.active(now) {
+			return fault
+		}
+	}
+	return nil
+}
+
+// count returns the faults of the kind that hit the target
+func (f *FaultInjector) count(kind, target string) int {
+	if f == nil {
+		return 0
+	}
+	n := 0
+	for _, fault := range f.Faults {
+		if fault.Kind == kind && fault.Target == target {
+			n++
+		}
+	}
+	return n
+}
+
+// Down returns the end of the downtime of the consumer at now, and false if
+// the consumer is up
+func (f *FaultInjector) Down(now sim.VTimeInSec, consumer string) (sim.VTimeInSec, bool) {
+	fault := f.active(FaultDown, consumer, now)
+	if fault == nil {
+		return 0, false
+	}
+	return fault.End, true
+}
+
+// Refuses reports whether consumers that are down leave their messages
+// queued instead of losing them
+func (f *FaultInjector) Refuses() bool {
+	return f != nil && f.Refuse
+}
+
+// Lost counts a message the consumer lost while down at now
+func (f *FaultInjector) Lost(now sim.VTimeInSec, consumer string) {
+	fault := f.active(FaultDown, consumer, now)
+	if fault == nil {
+		return
+	}
+	f.mu.Lock()
+	fault.Lost++
+	f.mu.Unlock()
+}
+
+// Downtimes returns the number of times the consumer is down
+func (f *FaultInjector) Downtimes(consumer string) int {
+	return f.count(FaultDown, consumer)
+}
+
+// Stalled returns the end of the stall of the distributor at now, and false
+// if it is not stalled
+func (f *FaultInjector) Stalled(now sim.VTimeInSec, distributor string) (sim.VTimeInSec, bool) {
+	fault := f.active(FaultStall, distributor, now)
+	if fault == nil {
+		return 0, false
+	}
+	return fault.End, true
+}
+
+// Stalls returns the number of times the distributor stalls
+func (f *FaultInjector) Stalls(distributor string) int {
+	return f.count(FaultStall, distributor)
+}
+
+// Consumed records the latency of a consumed message, as delayed by the
+// faults that lasted while it was on its way: a stall, or its consumer
+// being down
+func (f *FaultInjector) Consumed(now sim.VTimeInSec, consumer string, createTime sim.VTimeInSec) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	latency := float64(now - createTime)
+	delayed := false
+	for _, fault := range f.Faults {
+		hits := fault.Kind == FaultStall || (fault.Kind == FaultDown && fault.Target == consumer)
+		if hits && fault.Start <= now && createTime < fault.End {
+			fault.Delayed++
+			delayed = true
+		}
+	}
+	if delayed {
+		f.affected = append(f.affected, latency)
+	} else {
+		f.unaffected = append(f.unaffected, latency)
+	}
+}
+
+// TotalLost returns the messages lost to the faults
+func (f *FaultInjector) TotalLost() int {
+	if f == nil {
+		return 0
+	}
+	total := 0
+	for _, fault := range f.Faults {
+		total += fault.Lost
+	}
+	return total
+}
+
+// Print writes every fault with the messages it lost or delayed, and the
+// latency of the delayed messages compared with the others
+func (f *FaultInjector) Print(produced int) {
+	f.out.Println("=== Faults ===")
+	if f.File != "" {
+		f.out.Printf("Schedule:          %s\n", f.File)
+	}
+	f.out.Printf("Seed:              %d\n", f.Seed)
+	if f.Refuse {
+		f.out.Println("Down mode:         refuse (consumers that are down leave their messages queued)")
+	}
+	f.out.Printf("%-6s %-24s %8s %8s %6s %6s %8s\n", "Kind", "Target", "Start", "End", "Prob", "Lost", "Delayed")
+	for _, fault := range f.Faults {
+		prob, delayed := "-", "-"
+		if fault.Kind == FaultLoss {
+			prob = fmt.Sprintf("%.2f", fault.Probability)
+		} else {
+			delayed = strconv.Itoa(fault.Delayed)
+		}
+		f.out.Printf("%-6s %-24s %8.2f %8.2f %6s %6d %8s\n",
+			fault.Kind, fault.Target, float64(fault.Start), float64(fault.End), prob, fault.Lost, delayed)
+	}
+	lost := f.TotalLost()
+	f.out.Printf("Injected:          %d faults, %d messages lost (%.1f%% of produced)\n",
+		len(f.Faults), lost, percent(lost, produced))
+	f.out.Printf("Delayed by a fault: %d messages, mean %.2f s, p99 %.2f s\n",
+		len(f.affected), mean(f.affected), percentile(f.affected, 99))
+	f.out.Printf("Not delayed:        %d messages, mean %.2f s, p99 %.2f s\n",
+		len(f.unaffected), mean(f.unaffected), percentile(f.unaffected, 99))
+}
+
+// validateFaults checks the fault options and that the run can lose messages
+func (c *Config) validateFaults() error {
+	if c.FaultLoss < 0 || c.FaultLoss > 1 {
+		return fmt.Errorf("fault-loss must be between 0 and 1")
+	}
+	if c.RandomFaults < 0 {
+		return fmt.Errorf("random-faults must not be negative")
+	}
+	if c.RandomFaults > 0 && c.FaultDuration <= 0 {
+		return fmt.Errorf("fault-duration must be positive")
+	}
+	if c.FaultSchedule == "" && c.RandomFaults == 0 && c.FaultLoss == 0 {
+		return nil
+	}
+	if c.SharedBuffer > 0 {
+		// A message lost on its way would hold its room for good
+		return fmt.Errorf("faults cannot be combined with a shared buffer")
+	}
+	return nil
+}
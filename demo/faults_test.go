@@ -0,0 +1,115 @@
+package demo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFaults writes a fault schedule file and returns its path
+func writeFaults(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "faults.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestFaultScheduleParsing verifies that the schedule file is read and that
+// malformed lines are rejected with their line number
+func TestFaultScheduleParsing(t *testing.T) {
+	faults, err := LoadFaultSchedule(writeFaults(t, "# start,end,kind,target\n30,45,down,Consumer2\n\n0,100,loss,*,0.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faults) != 2 || faults[0].Kind != FaultDown || faults[0].End != 45 || faults[1].Probability != 0.5 {
+		t.Errorf("Unexpected faults %+v and %+v", faults[0], faults[1])
+	}
+	
+	for _, line := range []string{
+		"10,5,down,Consumer1",
+		"0,10,loss,*",
+		"0,10,loss,*,1.5",
+		"0,10,stall,Distributor,0.5",
+		"0,10,crash,Consumer1",
+		"0,10,down",
+	} {
+		_, err := LoadFaultSchedule(writeFaults(t, "# header\n"+line+"\n"))
+		if err == nil || !strings.Contains(err.Error(), ":2:") {
+			t.Errorf("Expected an error on line 2 for %q, got %v", line, err)
+		}
+	}
+}
+
+// TestFaultsLoseAndDelay verifies that a consumer down loses its messages, a
+// stall delays the others, losses follow the fault seed, and every message
+// is still accounted for
+func TestFaultsLoseAndDelay(t *testing.T) {
+	run := func(faultSeed int64) *Simulation {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 200
+		cfg.FaultSchedule = writeFaults(t, "30,60,down,Consumer2\n100,110,stall,Distributor\n")
+		cfg.FaultLoss = 0.1
+		cfg.FaultSeed = faultSeed
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return simulation
+	}
+	
+	simulation := run(7)
+	faults := simulation.faults.Faults
+	if faults[0].Lost == 0 || faults[1].Delayed == 0 || faults[2].Lost == 0 {
+		t.Errorf("Expected the downtime and the loss to lose messages and the stall to delay some, got %+v, %+v, and %+v",
+			*faults[0], *faults[1], *faults[2])
+	}
+	if len(simulation.faults.affected) == 0 ||
+		mean(simulation.faults.affected) <= mean(simulation.faults.unaffected) {
+		t.Errorf("Expected the delayed messages to take longer, got mean %.2f s against %.2f s",
+			mean(simulation.faults.affected), mean(simulation.faults.unaffected))
+	}
+	if c := simulation.Conservation(); !c.Holds() || c.Lost != simulation.faults.TotalLost() {
+		t.Errorf("Expected conservation to hold with the lost messages, got %+v", c)
+	}
+	
+	if again := run(7); again.faults.TotalLost() != simulation.faults.TotalLost() {
+		t.Errorf("Expected the same fault seed to lose %d messages again, got %d",
+			simulation.faults.TotalLost(), again.faults.TotalLost())
+	}
+}
+
+// TestFaultValidation verifies that unknown targets and runs that cannot
+// lose messages are rejected
+func TestFaultValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.FaultSchedule = writeFaults(t, "0,10,loss,ControlPlane,0.5\n")
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSimulation(cfg); err == nil {
+		t.Error("Expected losses on the control plane to be rejected")
+	}
+	
+	cfg = DefaultConfig()
+	cfg.FaultLoss = 0.1
+	cfg.SharedBuffer = 4
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected faults with a shared buffer to be rejected")
+	}
+	cfg.SharedBuffer = 0
+	cfg.FaultLoss = 2
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a loss probability above 1 to be rejected")
+	}
+}
@@ -0,0 +1,133 @@
+package demo
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+var updateGolden = flag.Bool("update", false, "Rewrite the golden event logs of TestGoldenEventLogs")
+
+// eventLog records every event handled by the engine and every message
+// event at the ports, in the order they happen
+type eventLog struct {
+	lines []string
+}
+
+func (l *eventLog) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosBeforeEvent:
+		l.lines = append(l.lines, describeEvent(ctx.Item.(sim.Event)))
+	case sim.HookPosPortMsgSend, sim.HookPosPortMsgRecvd, sim.HookPosPortMsgRetrieve:
+		l.lines = append(l.lines, fmt.Sprintf("  %s %s: %s",
+			ctx.Domain.(sim.Port).Name(), ctx.Pos.Name, describeMsg(ctx.Item.(sim.Msg))))
+	}
+}
+
+// recordEventLog runs the simulation and returns its event log
+func recordEventLog(t *testing.T, cfg *Config) []string {
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	log := &eventLog{}
+	simulation.engine.AcceptHook(log)
+	components, ports := simulation.topology.portsByComponent()
+	for _, component := range components {
+		for _, port := range ports[component] {
+			port.AcceptHook(log)
+		}
+	}
+	
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return log.lines
+}
+
+func readGolden(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// TestGoldenEventLogs compares the full event log of seeded runs against the
+// golden files in testdata/golden, so that any change of behavior, such as
+// one brought by a new Akita version, fails the test. After an intended
+// change, rewrite the files with go test -run TestGoldenEventLogs -update.
+func TestGoldenEventLogs(t *testing.T) {
+	cases := map[string]func(cfg *Config){
+		"default": func(cfg *Config) {
+			cfg.Seed = 1
+			cfg.Cycles = 60
+		},
+		"congested": func(cfg *Config) {
+			cfg.Seed = 3
+			cfg.Cycles = 60
+			cfg.ConsumeInterval = 4
+			cfg.RxQueues = 2
+		},
+		"polling-priorities-ttl": func(cfg *Config) {
+			cfg.Seed = 5
+			cfg.Cycles = 60
+			cfg.ConsumerMode = "polling"
+			cfg.PriorityLevels = 2
+			cfg.ConsumeInterval = 3
+			cfg.TTL = 8
+		},
+	}
+	
+	for name, configure := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			configure(cfg)
+			lines := recordEventLog(t, cfg)
+			path := filepath.Join("testdata", "golden", name+".log")
+			
+			if *updateGolden {
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				content := strings.Join(lines, "\n") + "\n"
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			
+			golden, err := readGolden(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create the golden file)", err)
+			}
+			for i := 0; i < len(lines) && i < len(golden); i++ {
+				if lines[i] != golden[i] {
+					t.Fatalf("Event log diverged from %s at line %d:\n  golden: %s\n  got:    %s",
+						path, i+1, golden[i], lines[i])
+				}
+			}
+			if len(lines) != len(golden) {
+				t.Fatalf("Expected %d lines as in %s, got %d", len(golden), path, len(lines))
+			}
+		})
+	}
+}
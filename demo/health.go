@@ -0,0 +1,233 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// downModes are what a consumer that is down does with the messages it is
+// sent: drop loses them, refuse leaves them queued until it is back
+var downModes = []string{"drop", "refuse"}
+
+// Failure is a consumer the distributor considered failed, from the time its
+// heartbeats were overdue until it was heard from again
+type Failure struct {
+	Consumer  string
+	LastSeen  sim.VTimeInSec // Last heartbeat before the failure
+	Detected  sim.VTimeInSec
+	Recovered sim.VTimeInSec // 0 while the consumer is considered failed
+	Down      sim.VTimeInSec // Start of the downtime that failed the consumer, -1 if it was up
+	Rerouted  int            // Messages for the consumer sent to healthy consumers
+}
+
+// DetectionTime returns how long the failure went unnoticed, or -1 if the
+// consumer was not down
+func (f *Failure) DetectionTime() sim.VTimeInSec {
+	if f.Down < 0 {
+		return -1
+	}
+	return f.Detected - f.Down
+}
+
+// HealthChecker lets the distributor tell failed consumers from healthy ones
+// by their heartbeats. Every consumer sends one every Interval while it is
+// up. A consumer whose last heartbeat is Timeout old is considered failed,
+// and its messages are rerouted to the healthy consumers in turn until it is
+// heard from again. Heartbeats and checks end at the end of the run. Its
+// methods are safe to call on a nil checker.
+type HealthChecker struct {
+	Interval   sim.VTimeInSec
+	Timeout    sim.VTimeInSec
+	Heartbeats int        // Heartbeats received
+	Failures   []*Failure // Failures in the order they were detected
+
+	stopTime sim.VTimeInSec
+	faults   *FaultInjector // Downtimes recorded as the causes of the failures, nil if none
+	lastSeen map[string]sim.VTimeInSec
+	failed   map[string]*Failure // Consumers considered failed
+	next     int                 // Healthy consumer to reroute the next message to
+}
+
+// NewHealthChecker creates a checker of heartbeats sent every interval until
+// stopTime that considers a consumer failed after timeout
+func NewHealthChecker(interval, timeout, stopTime sim.VTimeInSec) *HealthChecker {
+	return &HealthChecker{
+		Interval: interval,
+		Timeout:  timeout,
+		stopTime: stopTime,
+		lastSeen: make(map[string]sim.VTimeInSec),
+		failed:   make(map[string]*Failure),
+	}
+}
+
+// Heartbeat records a heartbeat of a consumer. It returns when the failure
+// the heartbeat ends was detected, and false if the consumer was healthy.
+func (h *HealthChecker) Heartbeat(now sim.VTimeInSec, consumer string) (sim.VTimeInSec, bool) {
+	h.Heartbeats++
+	h.lastSeen[consumer] = now
+	f := h.failed[consumer]
+	if f == nil {
+		return 0, false
+	}
+	f.Recovered = now
+	delete(h.failed, consumer)
+	return f.Detected, true
+}
+
+// Overdue returns when a heartbeat received at now is overdue, and false if
+// the checks end before
+func (h *HealthChecker) Overdue(now sim.VTimeInSec) (sim.VTimeInSec, bool) {
+	return now + h.Timeout, now+h.Timeout <= h.stopTime
+}
+
+// Check returns the consumers whose heartbeats became overdue with the time
+// of their last heartbeat, nil if there are none. A downtime of the
+// consumer, if one is injected, is recorded as the cause.
+func (h *HealthChecker) Check(now sim.VTimeInSec) map[string]sim.VTimeInSec {
+	if h == nil || now > h.stopTime {
+		return nil
+	}
+	names := make([]string, 0, len(h.lastSeen))
+	for name := range h.lastSeen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var detected map[string]sim.VTimeInSec
+	for _, name := range names {
+		lastSeen := h.lastSeen[name]
+		if h.failed[name] != nil || now < lastSeen+h.Timeout {
+			continue
+		}
+		f := &Failure{Consumer: name, LastSeen: lastSeen, Detected: now, Down: -1}
+		if fault := h.faults.active(FaultDown, name, now); fault != nil {
+			f.Down = fault.Start
+		}
+		h.failed[name] = f
+		h.Failures = append(h.Failures, f)
+		if detected == nil {
+			detected = make(map[string]sim.VTimeInSec)
+		}
+		detected[name] = lastSeen
+	}
+	return detected
+}
+
+// String describes the heartbeats and when a consumer is considered failed
+func (h *HealthChecker) String() string {
+	return fmt.Sprintf("heartbeats every %.2f s, failed after %.2f s", float64(h.Interval), float64(h.Timeout))
+}
+
+// Failed reports whether a consumer is considered failed
+func (h *HealthChecker) Failed(consumer string) bool {
+	return h != nil && h.failed[consumer] != nil
+}
+
+// Reroute returns a copy of a message for a failed consumer addressed to the
+// next healthy consumer in turn. The copy keeps its sequence number and
+// remembers the failed consumer. The message is returned unchanged if its
+// consumer is healthy or no consumer is.
+func (h *HealthChecker) Reroute(m *msg.DemoMessage, routes *distributor.RoutingTable) *msg.DemoMessage {
+	if !h.Failed(m.Destination) {
+		return m
+	}
+	var candidates []string
+	for _, name := range routes.Names() {
+		if !h.Failed(name) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return m
+	}
+
+	rerouted := m.Clone()
+	rerouted.Destination = candidates[h.next%len(candidates)]
+	rerouted.FailoverFrom = m.Destination
+	h.next++
+	return rerouted
+}
+
+// Routed counts a rerouted message once it has been sent
+func (h *HealthChecker) Routed(m *msg.DemoMessage) {
+	if h == nil || m.FailoverFrom == "" {
+		return
+	}
+	if f := h.failed[m.FailoverFrom]; f != nil {
+		f.Rerouted++
+	}
+}
+
+// TotalRerouted returns the number of messages sent to healthy consumers
+// instead of failed ones
+func (h *HealthChecker) TotalRerouted() int {
+	if h == nil {
+		return 0
+	}
+	total := 0
+	for _, f := range h.Failures {
+		total += f.Rerouted
+	}
+	return total
+}
+
+// Print writes every failure the distributor detected, how long it took to
+// notice, and the messages rerouted meanwhile
+func (h *HealthChecker) Print(out EventSink) {
+	out.Println("=== Health Checks ===")
+	out.Printf("Heartbeats:        every %.2f s, timeout %.2f s, %d received\n",
+		float64(h.Interval), float64(h.Timeout), h.Heartbeats)
+	out.Printf("Failures:          %d detected, %d messages rerouted\n", len(h.Failures), h.TotalRerouted())
+	if len(h.Failures) == 0 {
+		return
+	}
+	out.Printf("%-12s %10s %8s %9s %10s %10s %9s\n",
+		"Consumer", "Last beat", "Down at", "Detected", "Detection", "Recovered", "Rerouted")
+	for _, f := range h.Failures {
+		down, detection := "up", "-"
+		if f.Down >= 0 {
+			down = fmt.Sprintf("%.2f", float64(f.Down))
+			detection = fmt.Sprintf("%.2f s", float64(f.DetectionTime()))
+		}
+		recovered := "-"
+		if f.Recovered > 0 {
+			recovered = fmt.Sprintf("%.2f", float64(f.Recovered))
+		}
+		out.Printf("%-12s %10.2f %8s %9.2f %10s %10s %9d\n",
+			f.Consumer, float64(f.LastSeen), down, float64(f.Detected), detection, recovered, f.Rerouted)
+	}
+}
+
+// validateHealth checks the heartbeats and the down mode, and that failed
+// consumers can be told apart and replaced by the distributor that routes
+// to them
+func (c *Config) validateHealth() error {
+	known := false
+	for _, mode := range downModes {
+		known = known || mode == c.DownMode
+	}
+	if !known {
+		return fmt.Errorf("unknown down-mode %q", c.DownMode)
+	}
+	if c.HealthInterval < 0 {
+		return fmt.Errorf("health-interval must not be negative")
+	}
+	if c.HealthInterval == 0 {
+		return nil
+	}
+	if c.HealthTimeout <= c.HealthInterval {
+		// A consumer would be considered failed between two heartbeats
+		return fmt.Errorf("health-timeout must be longer than health-interval")
+	}
+	if c.DistributorDepth > 1 || c.WorkPool {
+		return fmt.Errorf("health checks need a single distributor and cannot be combined with a work pool")
+	}
+	if c.Multicast > 0 || len(c.Topics) > 0 || len(c.ConsumerGroups) > 0 {
+		return fmt.Errorf("health checks cannot be combined with multicast, topics, or consumer groups")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestHealthCheckerDetectsAndReroutes verifies that a consumer is failed once
+// its heartbeats are overdue, that its messages go to the healthy consumers
+// in turn meanwhile, and that a heartbeat ends the failure
+func TestHealthCheckerDetectsAndReroutes(t *testing.T) {
+	h := NewHealthChecker(2, 5, 100)
+	routes := distributor.NewRoutingTable()
+	for _, name := range []string{"Consumer1", "Consumer2", "Consumer3"} {
+		routes.Add(name)
+		h.Heartbeat(10, name)
+	}
+	h.Heartbeat(14, "Consumer1")
+	h.Heartbeat(14, "Consumer3")
+	
+	if failed := h.Check(14); len(failed) != 0 {
+		t.Errorf("Expected no failure before the timeout, got %d", len(failed))
+	}
+	failed := h.Check(15)
+	if len(failed) != 1 || failed["Consumer2"] != 10 || len(h.Failures) != 1 || h.Failures[0].Down != -1 {
+		t.Fatalf("Expected Consumer2 to fail at 15, got %v", failed)
+	}
+	
+	var destinations []string
+	for i := 0; i < 3; i++ {
+		m := h.Reroute(&msg.DemoMessage{Destination: "Consumer2", SeqNum: 7}, routes)
+		if m.FailoverFrom != "Consumer2" || m.SeqNum != 7 || m.Addressee() != "Consumer2" {
+			t.Errorf("Expected a copy that remembers Consumer2, got %+v", m)
+		}
+		h.Routed(m)
+		destinations = append(destinations, m.Destination)
+	}
+	if destinations[0] != "Consumer1" || destinations[1] != "Consumer3" || destinations[2] != "Consumer1" {
+		t.Errorf("Expected the healthy consumers in turn, got %v", destinations)
+	}
+	healthy := &msg.DemoMessage{Destination: "Consumer1"}
+	if h.Reroute(healthy, routes) != healthy {
+		t.Errorf("Expected the messages of healthy consumers to stay")
+	}
+	
+	if detected, ok := h.Heartbeat(20, "Consumer2"); !ok || detected != 15 {
+		t.Errorf("Expected the heartbeat to end the failure detected at 15, got %.2f", float64(detected))
+	}
+	if f := h.Failures[0]; f.Recovered != 20 || f.Rerouted != 3 {
+		t.Errorf("Expected the failure to end at 20 after 3 reroutes, got %+v", f)
+	}
+	if h.Failed("Consumer2") || h.Check(100) == nil || h.Check(101) != nil {
+		t.Errorf("Expected Consumer2 healthy again and checks to end at the end of the run")
+	}
+}
+
+// TestHealthChecksFailOver verifies that the distributor notices a consumer
+// that is down from its missing heartbeats and sends its messages elsewhere,
+// so that fewer are lost, and that a consumer refusing its messages loses
+// none
+func TestHealthChecksFailOver(t *testing.T) {
+	run := func(healthInterval float64, downMode string) *Simulation {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 200
+		cfg.FaultSchedule = writeFaults(t, "30,60,down,Consumer2\n")
+		cfg.HealthInterval = healthInterval
+		cfg.DownMode = downMode
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if c := simulation.Conservation(); !c.Holds() {
+			t.Errorf("Expected every message to be accounted for, got %+v", c)
+		}
+		return simulation
+	}
+	
+	unchecked := run(0, "drop")
+	checked := run(2, "drop")
+	health := checked.routing.health
+	if len(health.Failures) != 1 {
+		t.Fatalf("Expected one failure, got %d", len(health.Failures))
+	}
+	f := health.Failures[0]
+	if f.Consumer != "Consumer2" || f.Down != 30 || f.DetectionTime() > 3 || f.Recovered < 60 || f.Rerouted == 0 {
+		t.Errorf("Expected the downtime of Consumer2 to be detected within the timeout, got %+v", f)
+	}
+	if checked.faults.TotalLost() >= unchecked.faults.TotalLost() {
+		t.Errorf("Expected health checks to lose fewer messages, got %d and %d",
+			unchecked.faults.TotalLost(), checked.faults.TotalLost())
+	}
+	
+	refused := run(2, "refuse")
+	if refused.faults.TotalLost() != 0 || refused.stats.Consumed != refused.stats.Produced {
+		t.Errorf("Expected a consumer refusing its messages to lose none, got %d lost", refused.faults.TotalLost())
+	}
+}
+
+// TestHealthValidation verifies the heartbeats and down modes accepted
+func TestHealthValidation(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		apply func(cfg *Config)
+	}{
+		{"unknown down mode", func(cfg *Config) { cfg.DownMode = "crash" }},
+		{"negative interval", func(cfg *Config) { cfg.HealthInterval = -1 }},
+		{"timeout within the interval", func(cfg *Config) { cfg.HealthTimeout = 2 }},
+		{"distributor tree", func(cfg *Config) { cfg.DistributorDepth = 2 }},
+		{"multicast", func(cfg *Config) { cfg.Multicast = 0.5 }},
+	} {
+		cfg := DefaultConfig()
+		cfg.HealthInterval = 2
+		c.apply(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected %s to be rejected", c.name)
+		}
+	}
+}
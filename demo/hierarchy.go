@@ -0,0 +1,176 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// DistributorTree is the distributors of a run. The root distributor
+// receives the messages of the producers and routes them down a tree of
+// regional distributors to the consumers. The consumers are split into
+// contiguous groups, one per child, at every level. Every distributor
+// resolves the next hop of a message from its destination: a child
+// distributor, or the consumer itself at the leaves, where the consumers
+// register. A tree of depth 1 is the root alone.
+type DistributorTree struct {
+	Root     *distributor.Distributor
+	depth    int
+	fanOut   int
+	regions  []*distributor.Distributor // Distributors below the root, level by level
+	children map[*distributor.Distributor][]*distributor.Distributor
+	uplinks  map[*distributor.Distributor]sim.Port // Port of the parent that sends to a region
+	leaves   map[string]*distributor.Distributor   // Leaf distributor of every consumer
+}
+
+// rootDistributor is the name of the distributor the producers send to
+const rootDistributor = "Distributor"
+
+// NewDistributorTree creates the distributors of a tree of the given depth,
+// in which every distributor has up to fanOut children, for the consumers.
+// Every distributor is created with the options opts returns for its name,
+// nil for none, and the root with rootOpts as well.
+func NewDistributorTree(
+	engine sim.Engine,
+	consumers []string,
+	depth, fanOut int,
+	opts func(name string) []distributor.Option,
+	rootOpts ...distributor.Option,
+) *DistributorTree {
+	t := &DistributorTree{
+		depth:    depth,
+		fanOut:   fanOut,
+		children: make(map[*distributor.Distributor][]*distributor.Distributor),
+		uplinks:  make(map[*distributor.Distributor]sim.Port),
+		leaves:   make(map[string]*distributor.Distributor),
+	}
+
+	// Build the tree level by level, so that the regions are numbered in
+	// the order of their levels
+	type node struct {
+		d         *distributor.Distributor
+		consumers []string
+	}
+	t.Root = t.newDistributor(rootDistributor, engine, consumers, 1, opts, rootOpts...)
+	level := []node{{t.Root, consumers}}
+	for l := 2; l <= depth; l++ {
+		var next []node
+		for _, parent := range level {
+			for _, group := range splitConsumers(parent.consumers, fanOut) {
+				name := fmt.Sprintf("Region%d", len(t.regions)+1)
+				region := t.newDistributor(name, engine, group, l, opts)
+				t.addRegion(parent.d, region, group)
+				next = append(next, node{region, group})
+			}
+		}
+		level = next
+	}
+	return t
+}
+
+// newDistributor creates a distributor of a level of the tree. Leaves get
+// an output port per consumer, the others get one per child when the
+// children are added.
+func (t *DistributorTree) newDistributor(
+	name string,
+	engine sim.Engine,
+	consumers []string,
+	level int,
+	opts func(name string) []distributor.Option,
+	extra ...distributor.Option,
+) *distributor.Distributor {
+	var options []distributor.Option
+	if opts != nil {
+		options = opts(name)
+	}
+	options = append(options, extra...)
+	if level < t.depth {
+		return distributor.New(name, engine, nil, options...)
+	}
+	d := distributor.New(name, engine, consumers, options...)
+	for _, consumer := range consumers {
+		t.leaves[consumer] = d
+	}
+	return d
+}
+
+// addRegion makes a region the next hop of its parent for the consumers of
+// its group. The parent's output port to the region serves all of them, and
+// the region's input port is their route.
+func (t *DistributorTree) addRegion(parent, region *distributor.Distributor, consumers []string) {
+	port := parent.AddRegion(region, consumers)
+	t.regions = append(t.regions, region)
+	t.children[parent] = append(t.children[parent], region)
+	t.uplinks[region] = port
+}
+
+// splitConsumers splits the consumers into up to n contiguous groups whose
+// sizes differ by at most one
+func splitConsumers(consumers []string, n int) [][]string {
+	if n > len(consumers) {
+		n = len(consumers)
+	}
+	groups := make([][]string, 0, n)
+	start := 0
+	for i := 0; i < n; i++ {
+		end := start + (len(consumers)-start)/(n-i)
+		groups = append(groups, consumers[start:end])
+		start = end
+	}
+	return groups
+}
+
+// Distributors returns the root followed by the regions, level by level
+func (t *DistributorTree) Distributors() []*distributor.Distributor {
+	return append([]*distributor.Distributor{t.Root}, t.regions...)
+}
+
+// Regions returns the distributors below the root, level by level
+func (t *DistributorTree) Regions() []*distributor.Distributor {
+	return t.regions
+}
+
+// Uplink returns the output port a region receives its messages from
+func (t *DistributorTree) Uplink(region *distributor.Distributor) sim.Port {
+	return t.uplinks[region]
+}
+
+// Leaf returns the distributor that routes the messages of a consumer to
+// it, which the consumer registers with
+func (t *DistributorTree) Leaf(consumer string) *distributor.Distributor {
+	if d, ok := t.leaves[consumer]; ok {
+		return d
+	}
+	// Consumers added during the run are served by the root
+	return t.Root
+}
+
+// String describes the shape of the tree for the setup
+func (t *DistributorTree) String() string {
+	return fmt.Sprintf("tree of depth %d and fan-out %d (%d distributors)",
+		t.depth, t.fanOut, len(t.regions)+1)
+}
+
+// Print writes the tree with the messages every distributor passed on and
+// the consumers of the leaves
+func (t *DistributorTree) Print(out EventSink) {
+	out.Println("=== Distributor Tree ===")
+	out.Printf("%s\n", t)
+	t.print(out, t.Root, 0)
+}
+
+func (t *DistributorTree) print(out EventSink, d *distributor.Distributor, indent int) {
+	routed := d.TotalRouted()
+	name := strings.Repeat("  ", indent) + d.Name()
+	children := t.children[d]
+	if len(children) == 0 {
+		out.Printf("%-16s %6d routed  %s\n", name, routed, strings.Join(d.Destinations(), " "))
+		return
+	}
+	out.Printf("%-16s %6d routed\n", name, routed)
+	for _, child := range children {
+		t.print(out, child, indent+1)
+	}
+}
@@ -0,0 +1,74 @@
+package demo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// TestDistributorTreeRoutesOverRegions verifies that a run over a tree of
+// depth 3 delivers every message, and that every message passes the root,
+// one region of the middle level, and one leaf
+func TestDistributorTreeRoutesOverRegions(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 60
+	cfg.DistributorDepth = 3
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected every message accounted for, got %+v", simulation.Conservation())
+	}
+	tree := simulation.tree
+	if len(tree.Regions()) != 5 {
+		t.Fatalf("Expected 2 regions and 3 leaves, got %d regions", len(tree.Regions()))
+	}
+	total := func(distributors []*distributor.Distributor) int {
+		n := 0
+		for _, d := range distributors {
+			n += d.TotalRouted()
+		}
+		return n
+	}
+	regions, leaves := tree.Regions()[:2], tree.Regions()[2:]
+	consumed := simulation.stats.Consumed
+	if consumed == 0 || total([]*distributor.Distributor{tree.Root}) != consumed || total(regions) != consumed || total(leaves) != consumed {
+		t.Errorf("Expected %d messages passed on at every level, got %d, %d, and %d",
+			consumed, total([]*distributor.Distributor{tree.Root}), total(regions), total(leaves))
+	}
+	if simulation.stats.Routed != consumed {
+		t.Errorf("Expected %d messages routed to the consumers, got %d", consumed, simulation.stats.Routed)
+	}
+}
+
+// TestDistributorTreeSplitsConsumers verifies that the consumers are split
+// evenly over the leaves and register with them
+func TestDistributorTreeSplitsConsumers(t *testing.T) {
+	consumers := []string{"Consumer1", "Consumer2", "Consumer3", "Consumer4", "Consumer5"}
+	tree := NewDistributorTree(sim.NewSerialEngine(), consumers, 2, 2, nil)
+	
+	if got := tree.Root.Destinations(); !reflect.DeepEqual(got, consumers) {
+		t.Errorf("Expected the root to serve every consumer, got %v", got)
+	}
+	want := map[string]string{
+		"Consumer1": "Region1", "Consumer2": "Region1",
+		"Consumer3": "Region2", "Consumer4": "Region2", "Consumer5": "Region2",
+	}
+	for consumer, region := range want {
+		if leaf := tree.Leaf(consumer).Name(); leaf != region {
+			t.Errorf("Expected %s served by %s, got %s", consumer, region, leaf)
+		}
+		if hop := tree.Root.NextHop(consumer); hop != region {
+			t.Errorf("Expected the root to pass on %s to %s, got %s", consumer, region, hop)
+		}
+	}
+}
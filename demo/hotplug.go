@@ -0,0 +1,154 @@
+package demo
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// AddConsumer creates a consumer while the run goes on and wires it like the
+// consumers of the configuration: the distributor gets an output port for
+// it, and the run-wide statistics, checks, and trackers follow it. The
+// consumer registers with the distributor on its next tick, which then
+// announces it to the producers that discovered the destinations.
+func (s *Simulation) AddConsumer(now sim.VTimeInSec, name string, interval sim.VTimeInSec) (*consumer.Consumer, error) {
+	cfg := s.cfg
+	if s.consumer(name) != nil {
+		return nil, fmt.Errorf("consumer %q already exists", name)
+	}
+	if s.workPool != nil {
+		return nil, fmt.Errorf("consumers cannot join a work pool during the run")
+	}
+	if s.stealing != nil {
+		return nil, fmt.Errorf("consumers cannot join the work stealing during the run")
+	}
+	if s.routing.sharedBuffer != nil {
+		return nil, fmt.Errorf("consumers cannot join the shared buffer during the run")
+	}
+
+	ackPorts := make(map[string]sim.Port)
+	for _, p := range s.producers {
+		ackPorts[p.Name()] = p.CtrlPort()
+	}
+	opts := []consumer.Option{
+		consumer.WithFreq(cfg.Freq(name, cfg.ConsumerFreq)),
+		consumer.WithQueues(cfg.RxQueues, cfg.ConsumerInCapacity),
+		consumer.WithRegistry(s.distributor.CtrlPort(), 0),
+		consumer.WithAckPorts(ackPorts),
+		consumer.WithStats(s.stats),
+		consumer.WithBackpressure(s.backpressure),
+		consumer.WithVerifier(s.verifier),
+		consumer.WithErrors(s.errors),
+		consumer.WithMiddleware(s.middleware),
+		consumer.WithLogger(s.out),
+	}
+	opts = append(opts, cfg.consumerMode()...)
+	if s.sizeService != nil {
+		opts = append(opts, consumer.WithSizeService(s.sizeService))
+	}
+	if s.reorder != nil {
+		opts = append(opts, consumer.WithReorder(s.reorder))
+	}
+	if s.timestamps != nil {
+		opts = append(opts, consumer.WithTimestamps(s.timestamps))
+	}
+	if s.routing.overflow != nil {
+		opts = append(opts, consumer.WithOverflow(s.routing.overflow))
+	}
+	if h := s.routing.health; h != nil {
+		opts = append(opts, consumer.WithHeartbeats(h.Interval, h.stopTime))
+	}
+	if s.attribution != nil {
+		opts = append(opts, consumer.WithAttribution(s.attribution))
+	}
+	if s.retransmitter != nil {
+		opts = append(opts, consumer.WithRetransmitter(s.retransmitter))
+	}
+	if s.auditor != nil {
+		opts = append(opts, consumer.WithAuditor(s.auditor))
+	}
+	if s.eventDB != nil {
+		opts = append(opts, consumer.WithEvents(s.eventDB))
+	}
+	if s.faults != nil {
+		opts = append(opts, consumer.WithFaults(s.faults))
+	}
+	if s.routing.windows != nil {
+		opts = append(opts, consumer.WithWindowAcks(s.distributor.CtrlPort()))
+	}
+	models := cfg.consumerModels(name, len(s.consumers), len(s.consumers)+1)
+	c := consumer.New(name, s.engine, interval, append(opts, models.options()...)...)
+	if s.routing.coalescer != nil {
+		s.routing.coalescer.AddTarget(name, c)
+	}
+
+	// Connect the consumer to the distributor and to the control plane
+	outputPort := s.distributor.AddDestination(name)
+	s.topology.SetSendBuffer(outputPort, cfg.DistributorOutCapacity)
+	ports := append([]sim.Port{outputPort}, c.RxPorts()...)
+	s.topology.Connect(fmt.Sprintf("DistributorTo%s", name), s.engine, ports...)
+	if err := s.topology.PlugIn("ControlPlane", c.CtrlPort()); err != nil {
+		return nil, err
+	}
+
+	s.track(outputPort, c)
+	s.consumers = append(s.consumers, c)
+	s.consumerNames = append(s.consumerNames, name)
+	s.spec.Consumers = append(s.spec.Consumers, ConsumerSpec{
+		Name:          name,
+		Interval:      float64(interval),
+		QueueCapacity: cfg.ConsumerInCapacity,
+	})
+	s.models[name] = models
+	s.queueDepths[name] = c.QueueDepth
+	s.components = append(s.components, c)
+	if stopTime := sim.VTimeInSec(cfg.Cycles); now < stopTime {
+		scheduleDrain(s.engine, []component.Lifecycle{c}, stopTime)
+	}
+
+	c.TickLater(now)
+	return c, nil
+}
+
+// track adds the ports of a consumer added during the run, and the output
+// port of the distributor to it, to the trackers of the run
+func (s *Simulation) track(outputPort sim.Port, c *consumer.Consumer) {
+	s.ledger.TrackOutput(outputPort)
+	for _, port := range c.RxPorts() {
+		s.ledger.TrackInput(port)
+	}
+	if s.control != nil {
+		s.control.Track(outputPort)
+		s.control.WatchRoutes(outputPort)
+		for _, port := range c.RxPorts() {
+			s.control.Track(port)
+		}
+		s.control.Track(c.CtrlPort())
+	}
+	if s.watchdog != nil {
+		for _, port := range c.RxPorts() {
+			s.watchdog.WatchPort(port)
+		}
+	}
+	if s.inversions != nil {
+		for _, port := range c.RxPorts() {
+			s.inversions.Watch(port)
+		}
+	}
+	if s.chromeTrace != nil {
+		for _, port := range c.RxPorts() {
+			s.chromeTrace.Track(port, c.Name())
+		}
+	}
+	if s.eventDB != nil {
+		s.eventDB.Watch(outputPort)
+		for _, port := range c.RxPorts() {
+			s.eventDB.Watch(port)
+		}
+	}
+	if s.visualTracer != nil {
+		s.visualTracer.Attach(c)
+	}
+}
@@ -0,0 +1,53 @@
+package demo
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+)
+
+// drainEvent starts the drain phase of all components
+type drainEvent struct {
+	*sim.EventBase
+}
+
+// IsSecondary makes the drain phase start after the last ticks at the stop
+// time, without reordering the events scheduled for the same time
+func (e *drainEvent) IsSecondary() bool {
+	return true
+}
+
+type drainHandler struct {
+	components []component.Lifecycle
+}
+
+// Handle drains every component
+func (h *drainHandler) Handle(e sim.Event) error {
+	for _, c := range h.components {
+		c.Drain(e.Time())
+	}
+	return nil
+}
+
+// scheduleDrain makes the components drain at stopTime
+func scheduleDrain(engine sim.Engine, components []component.Lifecycle, stopTime sim.VTimeInSec) {
+	engine.Schedule(&drainEvent{
+		EventBase: sim.NewEventBase(stopTime, &drainHandler{components: components}),
+	})
+}
+
+// ticker is a component that can be scheduled to tick
+type ticker interface {
+	TickNow(now sim.VTimeInSec)
+}
+
+// startComponents schedules the first tick of the self-starting components,
+// or of all ticking components if all is set, and starts every component
+func startComponents(components []component.Lifecycle, now sim.VTimeInSec, all bool) {
+	for _, c := range components {
+		_, selfStarting := c.(component.SelfStarting)
+		if t, ok := c.(ticker); ok && (all || selfStarting) {
+			t.TickNow(now)
+		}
+		c.Start(now)
+	}
+}
@@ -0,0 +1,69 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+)
+
+type recordingComponent struct {
+	component.BaseLifecycle
+	drainedAt sim.VTimeInSec
+}
+
+func (c *recordingComponent) Drain(now sim.VTimeInSec) {
+	c.drainedAt = now
+}
+
+// TestDrainRunsAtStopTime verifies that all components drain at the stop time
+func TestDrainRunsAtStopTime(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	a := &recordingComponent{}
+	b := &recordingComponent{}
+	scheduleDrain(engine, []component.Lifecycle{a, b}, 7)
+	
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if a.drainedAt != 7 || b.drainedAt != 7 {
+		t.Errorf("Expected both components to drain at 7, got %.2f and %.2f", a.drainedAt, b.drainedAt)
+	}
+}
+
+type tickCounter struct {
+	ticks map[string]int
+}
+
+func (c *tickCounter) Func(ctx sim.HookCtx) {
+	if evt, ok := ctx.Item.(sim.TickEvent); ok && ctx.Pos == sim.HookPosBeforeEvent {
+		c.ticks[evt.Handler().(sim.Named).Name()]++
+	}
+}
+
+// TestStartComponentsTicksSelfStarting verifies that only self-starting
+// components are kicked off, unless all components are started
+func TestStartComponentsTicksSelfStarting(t *testing.T) {
+	for _, all := range []bool{false, true} {
+		engine := sim.NewSerialEngine()
+		counter := &tickCounter{ticks: make(map[string]int)}
+		engine.AcceptHook(counter)
+		d := distributor.New("Distributor", engine, []string{"Consumer1"})
+		c := consumer.New("Consumer1", engine, 1.0)
+		
+		startComponents([]component.Lifecycle{d, c}, 0, all)
+		if err := engine.Run(); err != nil {
+			t.Fatal(err)
+		}
+		
+		if counter.ticks["Consumer1"] == 0 {
+			t.Errorf("all=%v: expected the self-starting consumer to tick", all)
+		}
+		if ticked := counter.ticks["Distributor"] > 0; ticked != all {
+			t.Errorf("all=%v: expected the distributor to tick only when all components start, ticked %v", all, ticked)
+		}
+	}
+}
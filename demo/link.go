@@ -0,0 +1,307 @@
+package demo
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/sarchlab/akita/v3/tracing"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// LinkSpec is the transport model of a connection
+type LinkSpec struct {
+	Latency   int     // Cycles from the end of a transmission to the delivery
+	Bandwidth float64 // Messages or bytes transmitted per cycle, 0 for no limit
+	Bytes     bool    // The bandwidth is in bytes per cycle rather than messages
+}
+
+// Ideal reports whether the link delivers every message as soon as it is
+// sent, like an Akita DirectConnection
+func (s LinkSpec) Ideal() bool {
+	return s.Latency == 0 && s.Bandwidth == 0
+}
+
+// LinkStats are the delays of the messages a link delivered, split into the
+// parts of the transport
+type LinkStats struct {
+	Delivered     int
+	Rejected      int            // Sends refused because the send buffer was full
+	Waiting       sim.VTimeInSec // Time messages waited for the link to be free
+	Serialization sim.VTimeInSec // Time the link spent transmitting
+	Stalled       sim.VTimeInSec // Time arrived messages waited for a full receiver
+}
+
+// Link is a connection with a latency and a bandwidth. The ports plugged
+// into it share it: the messages they send wait in their send buffers until
+// the link is free, taking turns between the ports, are transmitted one
+// after the other in their serialization time, and are delivered the latency
+// later.
+type Link struct {
+	*sim.TickingComponent
+	spec    LinkSpec
+	period  sim.VTimeInSec
+	ports   []sim.Port
+	ends    map[sim.Port]*linkEnd
+	arbiter Arbiter           // Picks the port to transmit from next
+	audit   *ArbitrationAudit // Checks the grants of the arbiter, nil audits nothing
+	freeAt  sim.VTimeInSec    // End of the last transmission
+	flights []linkFlight      // Transmitted messages in the order they arrive
+	Stats   LinkStats
+}
+
+type linkEnd struct {
+	port    sim.Port
+	buf     []sim.Msg
+	bufSize int
+	busy    bool // The port failed to send and waits to be notified
+}
+
+type linkFlight struct {
+	msg    sim.Msg
+	arrive sim.VTimeInSec
+}
+
+// NewLink creates a link that ticks at freq, the unit of its latency and
+// bandwidth
+func NewLink(name string, engine sim.Engine, freq sim.Freq, spec LinkSpec) *Link {
+	l := &Link{
+		spec:    spec,
+		period:  freq.Period(),
+		ends:    make(map[sim.Port]*linkEnd),
+		arbiter: &RoundRobinArbiter{},
+	}
+	l.TickingComponent = sim.NewSecondaryTickingComponent(name, engine, freq, l)
+	return l
+}
+
+// PlugIn connects a port to the link with a send buffer of sourceSideBufSize
+// messages
+func (l *Link) PlugIn(port sim.Port, sourceSideBufSize int) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.ports = append(l.ports, port)
+	l.ends[port] = &linkEnd{port: port, bufSize: sourceSideBufSize}
+	port.SetConnection(l)
+}
+
+// Unplug disconnects a port from the link. The messages left in its send
+// buffer are dropped.
+func (l *Link) Unplug(port sim.Port) {
+	l.Lock()
+	defer l.Unlock()
+
+	for i, p := range l.ports {
+		if p != port {
+			continue
+		}
+		l.ports = append(l.ports[:i:i], l.ports[i+1:]...)
+		l.arbiter.Unplugged(i)
+		break
+	}
+	delete(l.ends, port)
+}
+
+// NotifyAvailable wakes up the link when a receiver has room again
+func (l *Link) NotifyAvailable(now sim.VTimeInSec, _ sim.Port) {
+	l.TickNow(now)
+}
+
+// CanSend reports whether the send buffer of the port has room
+func (l *Link) CanSend(src sim.Port) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	end := l.ends[src]
+	if len(end.buf) >= end.bufSize {
+		end.busy = true
+		return false
+	}
+	return true
+}
+
+// Send queues the message in the send buffer of its source port
+func (l *Link) Send(msg sim.Msg) *sim.SendError {
+	l.Lock()
+	defer l.Unlock()
+
+	src, ok := l.ends[msg.Meta().Src]
+	if !ok {
+		panic("src is not connected")
+	}
+	if _, ok := l.ends[msg.Meta().Dst]; !ok {
+		panic("dst is not connected")
+	}
+	if len(src.buf) >= src.bufSize {
+		src.busy = true
+		l.Stats.Rejected++
+		return sim.NewSendError()
+	}
+
+	src.buf = append(src.buf, msg)
+	l.trace(msg, component.StartTask)
+	l.TickNow(msg.Meta().SendTime)
+	return nil
+}
+
+// Tick transmits the messages the link has time for in this cycle and
+// delivers the ones that have arrived
+func (l *Link) Tick(now sim.VTimeInSec) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	madeProgress := l.transmit(now)
+	madeProgress = l.deliver(now) || madeProgress
+
+	// Keep ticking while messages are on their way or wait for the link
+	for _, f := range l.flights {
+		if f.arrive > now+component.ClockTolerance {
+			return true
+		}
+	}
+	for _, end := range l.ends {
+		if len(end.buf) > 0 {
+			return true
+		}
+	}
+	return madeProgress
+}
+
+// transmit starts the transmissions that fit into the cycle beginning now
+func (l *Link) transmit(now sim.VTimeInSec) bool {
+	madeProgress := false
+	for {
+		start := now
+		if l.freeAt > start {
+			start = l.freeAt
+		}
+		if start >= now+l.period-component.ClockTolerance {
+			// Busy until the next cycle, the ports are arbitrated then
+			return madeProgress
+		}
+		index := l.arbiter.Pick(l.ports, l.head, l.units)
+		if index < 0 {
+			return madeProgress
+		}
+		end := l.ends[l.ports[index]]
+		l.audit.Granted(now, l, index, l.units(end.buf[0]))
+
+		msg := end.buf[0]
+		end.buf = end.buf[1:]
+		serialization := l.serialization(msg)
+		l.freeAt = start + serialization
+		// The message arrives on the first tick after its latency is over
+		latency := sim.VTimeInSec(l.spec.Latency) * l.period
+		arrive := l.Freq.NoEarlierThan(l.freeAt + latency - component.ClockTolerance)
+		l.flights = append(l.flights, linkFlight{msg: msg, arrive: arrive})
+		l.Stats.Waiting += start - msg.Meta().SendTime
+		l.Stats.Serialization += serialization
+		madeProgress = true
+
+		if end.busy {
+			end.busy = false
+			end.port.NotifyAvailable(now)
+		}
+	}
+}
+
+// head returns the message waiting at a port to be transmitted, or nil if
+// none is
+func (l *Link) head(port sim.Port) sim.Msg {
+	if end := l.ends[port]; len(end.buf) > 0 {
+		return end.buf[0]
+	}
+	return nil
+}
+
+// units returns the units of the bandwidth a message takes, one message or
+// its size in bytes
+func (l *Link) units(msg sim.Msg) float64 {
+	if l.spec.Bytes {
+		return float64(msg.Meta().TrafficBytes)
+	}
+	return 1
+}
+
+// serialization returns the time the link takes to transmit a message
+func (l *Link) serialization(msg sim.Msg) sim.VTimeInSec {
+	if l.spec.Bandwidth == 0 {
+		return 0
+	}
+	return sim.VTimeInSec(l.units(msg)/l.spec.Bandwidth) * l.period
+}
+
+// deliver hands the arrived messages to their receivers. The messages for a
+// full receiver wait, and so do the later ones for it, to keep their order.
+func (l *Link) deliver(now sim.VTimeInSec) bool {
+	madeProgress := false
+	full := make(map[sim.Port]bool)
+	waiting := l.flights[:0]
+	for _, f := range l.flights {
+		dst := f.msg.Meta().Dst
+		if f.arrive > now+component.ClockTolerance || full[dst] {
+			waiting = append(waiting, f)
+			continue
+		}
+
+		f.msg.Meta().RecvTime = now
+		if err := dst.Recv(f.msg); err != nil {
+			full[dst] = true
+			waiting = append(waiting, f)
+			continue
+		}
+		l.Stats.Delivered++
+		l.trace(f.msg, component.EndTask)
+		if now > f.arrive+component.ClockTolerance {
+			l.Stats.Stalled += now - f.arrive
+		}
+		madeProgress = true
+	}
+	l.flights = waiting
+	return madeProgress
+}
+
+// Utilization returns the share of the duration the link spent transmitting
+func (l *Link) Utilization(duration sim.VTimeInSec) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(l.Stats.Serialization/duration) * 100
+}
+
+// PrintLinks writes the mean delays of the messages every link delivered
+// and the share of the run the link spent transmitting
+func PrintLinks(out EventSink, links []*Link, duration sim.VTimeInSec) {
+	out.Println("=== Links ===")
+	out.Printf("%-28s %7s %10s %9s %9s %13s %9s %7s %8s\n",
+		"Link", "Latency", "Bandwidth", "Delivered", "Wait", "Serialization", "Stall", "Busy", "Rejected")
+	for _, l := range links {
+		bandwidth := "-"
+		if l.spec.Bandwidth > 0 {
+			unit := "msg"
+			if l.spec.Bytes {
+				unit = "B"
+			}
+			bandwidth = fmt.Sprintf("%g %s", l.spec.Bandwidth, unit)
+		}
+		s := l.Stats
+		mean := func(t sim.VTimeInSec) float64 {
+			if s.Delivered == 0 {
+				return 0
+			}
+			return float64(t) / float64(s.Delivered)
+		}
+		out.Printf("%-28s %7d %10s %9d %7.2f s %11.2f s %7.2f s %6.1f%% %8d\n",
+			l.Name(), l.spec.Latency, bandwidth, s.Delivered, mean(s.Waiting),
+			mean(s.Serialization), mean(s.Stalled), l.Utilization(duration), s.Rejected)
+	}
+}
+
+// trace reports the start or the end of the transfer of a demo message over
+// the link
+func (l *Link) trace(m sim.Msg, report func(tracing.NamedHookable, string, uint64)) {
+	if m, ok := m.(*msg.DemoMessage); ok {
+		report(l, "transfer", m.ID)
+	}
+}
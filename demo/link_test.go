@@ -0,0 +1,127 @@
+package demo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// linkEndpoint owns a port of a link test and records the arrival times of
+// the messages it receives
+type linkEndpoint struct {
+	*sim.ComponentBase
+	port     sim.Port
+	arrivals []sim.VTimeInSec
+}
+
+func newLinkEndpoint(name string) *linkEndpoint {
+	e := &linkEndpoint{ComponentBase: sim.NewComponentBase(name)}
+	e.port = sim.NewLimitNumMsgPort(e, 4, name+".Port")
+	return e
+}
+
+func (e *linkEndpoint) Handle(sim.Event) error { return nil }
+
+func (e *linkEndpoint) NotifyRecv(now sim.VTimeInSec, port sim.Port) {
+	e.arrivals = append(e.arrivals, now)
+	port.Retrieve(now)
+}
+
+func (e *linkEndpoint) NotifyPortFree(sim.VTimeInSec, sim.Port) {}
+
+// sendOver sends a message of size bytes from one endpoint to another at
+// time 0
+func sendOver(t *testing.T, from, to *linkEndpoint, size int) {
+	m := &msg.DemoMessage{Size: size}
+	m.Meta().Src = from.port
+	m.Meta().Dst = to.port
+	m.Meta().TrafficBytes = size
+	if err := from.port.Send(m); err != nil {
+		t.Fatalf("%s could not send", from.Name())
+	}
+}
+
+// TestLinkSerializesContendingSenders verifies that two senders share the
+// bandwidth of a link: the second message waits for the first one to be
+// transmitted, and both arrive their serialization time and the latency
+// after they start
+func TestLinkSerializesContendingSenders(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	a, b, dst := newLinkEndpoint("A"), newLinkEndpoint("B"), newLinkEndpoint("Dst")
+	link := NewLink("Link", engine, 1*sim.Hz, LinkSpec{Latency: 3, Bandwidth: 0.5})
+	for _, e := range []*linkEndpoint{a, b, dst} {
+		link.PlugIn(e.port, 1)
+	}
+	
+	sendOver(t, a, dst, 0)
+	sendOver(t, b, dst, 0)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	// Transmissions take 2 cycles each, in [0, 2) and [2, 4)
+	if len(dst.arrivals) != 2 || dst.arrivals[0] != 5 || dst.arrivals[1] != 7 {
+		t.Errorf("Expected the messages to arrive at 5 and 7, got %v", dst.arrivals)
+	}
+	if link.Stats.Waiting != 2 || link.Stats.Serialization != 4 {
+		t.Errorf("Expected 2 s of waiting and 4 s of serialization, got %+v", link.Stats)
+	}
+}
+
+// TestLinkBandwidthInBytes verifies that a bandwidth in bytes makes the
+// serialization time grow with the message size, and that a message arrives
+// on the first tick after its transmission and latency
+func TestLinkBandwidthInBytes(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	src, dst := newLinkEndpoint("Src"), newLinkEndpoint("Dst")
+	link := NewLink("Link", engine, 1*sim.Hz, LinkSpec{Latency: 1, Bandwidth: 4, Bytes: true})
+	link.PlugIn(src.port, 1)
+	link.PlugIn(dst.port, 1)
+	
+	sendOver(t, src, dst, 10)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(dst.arrivals) != 1 || dst.arrivals[0] != 4 {
+		t.Errorf("Expected the message to arrive at 4, got %v", dst.arrivals)
+	}
+	if math.Abs(float64(link.Stats.Serialization)-2.5) > 1e-9 || link.Stats.Stalled != 0 {
+		t.Errorf("Expected 2.5 s of serialization and no stalls, got %+v", link.Stats)
+	}
+}
+
+// TestLinksDelayTheRun verifies that a run over links with a latency
+// delivers every message later than over ideal connections, and reports the
+// links
+func TestLinksDelayTheRun(t *testing.T) {
+	run := func(latency int) *Simulation {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 60
+		cfg.LinkLatency = latency
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return simulation
+	}
+	
+	ideal, delayed := run(0), run(2)
+	if len(ideal.topology.Links()) != 0 || len(delayed.topology.Links()) == 0 {
+		t.Fatal("Expected links only with a latency")
+	}
+	if !delayed.Conservation().Holds() {
+		t.Errorf("Expected every message accounted for, got %+v", delayed.Conservation())
+	}
+	// Two hops of 2 cycles each
+	if got, want := delayed.stats.MeanLatency(), ideal.stats.MeanLatency()+4; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Expected a mean latency of %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,136 @@
+package demo
+
+import (
+	"math"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// littleTolerance is the relative difference between L and lambda*W up to
+// which Little's law counts as holding
+const littleTolerance = 0.01
+
+// stay is the time a message entered the system and the number of its
+// copies still in it
+type stay struct {
+	since   sim.VTimeInSec
+	holders int
+}
+
+// occupancy follows the number of messages in the system, from their
+// creation until they are consumed, by the time they enter and leave it.
+// Little's law checks it against the latencies, which are measured from the
+// stamps the messages carry instead.
+type occupancy struct {
+	n         int            // Messages in the system
+	last      sim.VTimeInSec // Time n last changed
+	area      float64        // Integral of n over time up to last
+	stays     map[uint64]*stay
+	unmatched int // Messages that left without having entered
+}
+
+// advance integrates the number of messages in the system up to now
+func (o *occupancy) advance(now sim.VTimeInSec) {
+	if now > o.last {
+		o.area += float64(o.n) * float64(now-o.last)
+		o.last = now
+	}
+}
+
+// enter counts a message, or a copy of it, that is in the system since the
+// given time, which is before now for messages held before they are counted
+func (o *occupancy) enter(now sim.VTimeInSec, id uint64, since sim.VTimeInSec) {
+	if o.stays == nil {
+		o.stays = make(map[uint64]*stay)
+	}
+	o.advance(now)
+	if since > now {
+		since = now
+	}
+	o.area += float64(now - since)
+	o.n++
+	if s, ok := o.stays[id]; ok {
+		s.holders++
+		return
+	}
+	o.stays[id] = &stay{since: since, holders: 1}
+}
+
+// leave counts a message, or a copy of it, that leaves the system. A
+// retracted message never counted: its copies replace it.
+func (o *occupancy) leave(now sim.VTimeInSec, id uint64, retract bool) {
+	s, ok := o.stays[id]
+	if !ok {
+		o.unmatched++
+		return
+	}
+	o.advance(now)
+	o.n--
+	if retract {
+		o.area -= float64(now - s.since)
+	}
+	s.holders--
+	if s.holders == 0 {
+		delete(o.stays, id)
+	}
+}
+
+// LittlesLaw compares the time-averaged number of messages in the system, L,
+// with the product of the rate at which they pass through it, lambda, and
+// their mean latency, W
+type LittlesLaw struct {
+	L, Lambda, W float64
+	Remaining    int // Messages never consumed, left out of L
+	Unmatched    int // Messages consumed that never entered the system
+}
+
+// Holds reports whether L and lambda*W agree within the tolerance
+func (l LittlesLaw) Holds() bool {
+	product := l.Lambda * l.W
+	return l.Unmatched == 0 && math.Abs(l.L-product) <= littleTolerance*product
+}
+
+// LittlesLaw checks Little's law over a run of the given duration, including
+// the warm-up. The messages never consumed, lost or still queued at the end,
+// are left out: their time in the system up to the end of the run is taken
+// out of L.
+func (s *Stats) LittlesLaw(duration sim.VTimeInSec) LittlesLaw {
+	o := s.occupancy
+	if duration <= 0 {
+		return LittlesLaw{}
+	}
+	o.advance(duration)
+	remaining := 0
+	area := o.area
+	for _, stay := range o.stays {
+		remaining += stay.holders
+		area -= float64(stay.holders) * float64(duration-stay.since)
+	}
+	return LittlesLaw{
+		L:         area / float64(duration),
+		Lambda:    s.TotalThroughput(duration),
+		W:         mean(s.totalLatencies()),
+		Remaining: remaining,
+		Unmatched: o.unmatched,
+	}
+}
+
+// Print writes both sides of Little's law and whether they agree
+func (l LittlesLaw) Print(out EventSink) {
+	out.Println("=== Little's Law ===")
+	out.Printf("In system (L):     %.2f messages on average\n", l.L)
+	out.Printf("Throughput:        %.3f msg/s\n", l.Lambda)
+	out.Printf("Mean latency:      %.2f s\n", l.W)
+	out.Printf("Product:           %.2f messages (throughput x latency)\n", l.Lambda*l.W)
+	if l.Remaining > 0 {
+		out.Printf("Not consumed:      %d messages, left out\n", l.Remaining)
+	}
+	if l.Unmatched > 0 {
+		out.Printf("Unmatched:         %d messages consumed, never produced\n", l.Unmatched)
+	}
+	if l.Holds() {
+		out.Printf("Result:            OK (within %.0f%%)\n", littleTolerance*100)
+	} else {
+		out.Printf("Result:            VIOLATED (L off by %s)\n", percentError(l.L, l.Lambda*l.W))
+	}
+}
@@ -0,0 +1,80 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestLittlesLawOfMessages verifies that the time-averaged number of messages
+// in the system matches their throughput times their latency, also for the
+// copies of multicast messages, and that it fails once the stamps of the
+// messages disagree with their time in the system
+func TestLittlesLawOfMessages(t *testing.T) {
+	stats := NewStats()
+	a := &msg.DemoMessage{ID: 1, CreateTime: 0}
+	b := &msg.DemoMessage{ID: 2, CreateTime: 1, Group: "Front"}
+	lost := &msg.DemoMessage{ID: 3, CreateTime: 2}
+	stats.RecordProduced(0, a)
+	stats.RecordProduced(2, b)
+	stats.RecordProduced(2, lost)
+	stats.RecordCopy(3, b)
+	stats.RecordCopy(3, b)
+	stats.RecordFannedOut(3, b)
+	stats.RecordConsumed(4, a)
+	stats.RecordConsumed(5, b)
+	stats.RecordConsumed(7, b)
+	
+	// 4 + 4 + 6 message-seconds of the consumed messages over 10 s
+	law := stats.LittlesLaw(10)
+	if law.L != 1.4 || law.Lambda != 0.3 || !law.Holds() {
+		t.Errorf("Expected 1.4 messages in the system at 0.3 msg/s, got %+v", law)
+	}
+	if law.Remaining != 1 {
+		t.Errorf("Expected the lost message left out, got %d", law.Remaining)
+	}
+	
+	late := &msg.DemoMessage{ID: 4, CreateTime: 8}
+	stats.RecordProduced(8, late)
+	late.CreateTime = 6
+	stats.RecordConsumed(10, late)
+	if law := stats.LittlesLaw(10); law.Holds() {
+		t.Errorf("Expected a restamped message to violate Little's law, got %+v", law)
+	}
+	
+	stats.RecordConsumed(10, &msg.DemoMessage{ID: 9})
+	if law := stats.LittlesLaw(10); law.Unmatched != 1 || law.Holds() {
+		t.Errorf("Expected a message never produced to be unmatched, got %+v", law)
+	}
+}
+
+// TestLittlesLawOfRuns verifies that Little's law holds over runs with
+// multicast messages and messages lost to faults
+func TestLittlesLawOfRuns(t *testing.T) {
+	for _, change := range []func(*Config){
+		func(c *Config) {},
+		func(c *Config) { c.Multicast = 0.3 },
+		func(c *Config) { c.FaultLoss = 0.1 },
+	} {
+		cfg := DefaultConfig()
+		cfg.Seed = 2
+		cfg.Cycles = 200
+		change(cfg)
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+	
+		law := simulation.stats.LittlesLaw(simulation.Duration())
+		if law.L == 0 || !law.Holds() {
+			t.Errorf("Expected Little's law to hold, got %+v", law)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// TrafficMatrix holds the rate in messages per second from every source to
+// every destination
+type TrafficMatrix struct {
+	Sources      []string
+	Destinations []string
+	Rates        [][]float64 // Rates[source][destination]
+}
+
+// LoadTrafficMatrix reads a traffic matrix file. The first line that is not
+// empty and does not start with '#' is the header "source,dest1,dest2,...";
+// every following line has the form "source,rate1,rate2,...". A source sends
+// at most one message per second, so the rates of a row must not add up to
+// more than 1.
+func LoadTrafficMatrix(path string) (*TrafficMatrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &TrafficMatrix{}
+	names := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		if m.Destinations == nil {
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: expected a header with at least one destination", path, lineNum)
+			}
+			for _, name := range fields[1:] {
+				if name == "" || names[name] {
+					return nil, fmt.Errorf("%s:%d: invalid or repeated name %q", path, lineNum, name)
+				}
+				names[name] = true
+			}
+			m.Destinations = fields[1:]
+			continue
+		}
+
+		rates, err := parseMatrixRow(fields, len(m.Destinations))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+		if names[fields[0]] {
+			return nil, fmt.Errorf("%s:%d: invalid or repeated name %q", path, lineNum, fields[0])
+		}
+		names[fields[0]] = true
+		m.Sources = append(m.Sources, fields[0])
+		m.Rates = append(m.Rates, rates)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(m.Sources) == 0 {
+		return nil, fmt.Errorf("%s: traffic matrix has no sources", path)
+	}
+	return m, nil
+}
+
+func parseMatrixRow(fields []string, numDestinations int) ([]float64, error) {
+	if len(fields) != numDestinations+1 {
+		return nil, fmt.Errorf("expected %d fields, got %d", numDestinations+1, len(fields))
+	}
+	if fields[0] == "" {
+		return nil, fmt.Errorf("missing source name")
+	}
+
+	rates := make([]float64, numDestinations)
+	total := 0.0
+	for i, field := range fields[1:] {
+		rate, err := strconv.ParseFloat(field, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate %q", field)
+		}
+		rates[i] = rate
+		total += rate
+	}
+	if total > 1 {
+		return nil, fmt.Errorf("%s sends %.3g messages per second, at most 1 is possible", fields[0], total)
+	}
+	return rates, nil
+}
+
+// Traffic returns the traffic model of the source with the given index
+func (m *TrafficMatrix) Traffic(source int) *MatrixTraffic {
+	return &MatrixTraffic{
+		destinations: m.Destinations,
+		rates:        m.Rates[source],
+		sent:         make([]int, len(m.Destinations)),
+		next:         -1,
+	}
+}
+
+// MatrixTraffic generates the messages of a row of a traffic matrix
+// deterministically: on every tick, it sends to the destination that is the
+// furthest behind its schedule, among those owed at least one message.
+// Messages that could not be sent in time are caught up later, and when the
+// producer is held back, every destination falls behind by the same time
+// rather than the slower ones starving. It is both the traffic model and the
+// destination policy of its producer.
+type MatrixTraffic struct {
+	destinations []string
+	rates        []float64
+	sent         []int // Messages sent per destination
+	start        sim.VTimeInSec
+	started      bool
+	next         int // Destination picked by the last ShouldGenerate
+}
+
+// ShouldGenerate returns true if a destination is owed a message. Rates
+// count from the first tick, after the consumers are discovered.
+func (t *MatrixTraffic) ShouldGenerate(now sim.VTimeInSec, rng *rand.Rand) bool {
+	if !t.started {
+		t.start = now
+		t.started = true
+	}
+
+	t.next = -1
+	lag := 0.0
+	for i, rate := range t.rates {
+		deficit := rate*float64(now-t.start) - float64(t.sent[i])
+		if deficit >= 1 && (t.next < 0 || deficit/rate > lag) {
+			t.next = i
+			lag = deficit / rate
+		}
+	}
+	return t.next >= 0
+}
+
+// Pick returns the destination chosen by ShouldGenerate and counts the
+// message as sent
+func (t *MatrixTraffic) Pick(consumers []string, rng *rand.Rand) string {
+	t.sent[t.next]++
+	return t.destinations[t.next]
+}
+
+// Observe ignores the round-trip times
+func (t *MatrixTraffic) Observe(consumer string, rtt sim.VTimeInSec) {}
+
+// Unsend takes back a message to a destination that could not be sent
+func (t *MatrixTraffic) Unsend(dest string) {
+	for i, d := range t.destinations {
+		if d == dest {
+			t.sent[i]--
+			return
+		}
+	}
+}
+
+// String names the traffic model and the number of its destinations
+func (t *MatrixTraffic) String() string {
+	return fmt.Sprintf("traffic matrix to %d destinations", len(t.destinations))
+}
+
+// PrintMatrixReport writes the target and the achieved rate of every
+// (source, destination) pair of the matrix, from the first tick of the
+// producers to stopTime
+func PrintMatrixReport(out EventSink, m *TrafficMatrix, producers []*producer.Producer, stopTime sim.VTimeInSec) {
+	out.Println("=== Traffic Matrix ===")
+	out.Printf("%-24s %9s %14s %14s\n", "Pair", "Messages", "Target", "Achieved")
+	for i, p := range producers {
+		t, ok := p.Traffic().(*MatrixTraffic)
+		if !ok {
+			continue
+		}
+		period := float64(stopTime - t.start)
+		for j, dest := range m.Destinations {
+			achieved := 0.0
+			if period > 0 {
+				achieved = float64(t.sent[j]) / period
+			}
+			out.Printf("%-24s %9d %8.3f msg/s %8.3f msg/s\n",
+				Pair{Producer: m.Sources[i], Consumer: dest}, t.sent[j], m.Rates[i][j], achieved)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package demo
+
+import (
+	"math"
+	"testing"
+)
+
+// TestLoadTrafficMatrixReadsRowsAndColumns verifies that the header names
+// the destinations and every row a source with its rates
+func TestLoadTrafficMatrixReadsRowsAndColumns(t *testing.T) {
+	path := writeTraceFile(t, "# rates\nsource,Web,Db\nFrontend,0.2,0.3\nBatch,0,0.5\n")
+	
+	m, err := LoadTrafficMatrix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(m.Sources) != 2 || m.Sources[1] != "Batch" || len(m.Destinations) != 2 || m.Destinations[0] != "Web" {
+		t.Fatalf("Unexpected sources %v and destinations %v", m.Sources, m.Destinations)
+	}
+	if m.Rates[0][1] != 0.3 || m.Rates[1][0] != 0 {
+		t.Errorf("Unexpected rates %v", m.Rates)
+	}
+}
+
+// TestLoadTrafficMatrixRejectsImpossibleRows verifies that rows that do not
+// match the header or exceed one message per second are rejected
+func TestLoadTrafficMatrixRejectsImpossibleRows(t *testing.T) {
+	for _, content := range []string{
+		"source,Web,Db\nFrontend,0.2\n",
+		"source,Web,Db\nFrontend,0.6,0.6\n",
+		"source,Web,Db\nFrontend,-0.1,0.2\n",
+		"source,Web,Web\nFrontend,0.1,0.2\n",
+		"source,Web\n",
+	} {
+		if _, err := LoadTrafficMatrix(writeTraceFile(t, content)); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+// TestProducersFollowTrafficMatrix verifies that every pair receives the
+// number of messages its rate asks for over the run
+func TestProducersFollowTrafficMatrix(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 200
+	cfg.TrafficMatrixFile = writeTraceFile(t, "source,Web,Db,Cache\nFrontend,0.2,0.05,0.25\nBatch,0,0.3,0.1\n")
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	received := make(map[Pair]int)
+	for pair, latencies := range simulation.stats.pairLatencies {
+		received[pair] = len(latencies)
+	}
+	for i, p := range simulation.producers {
+		traffic := p.Traffic().(*MatrixTraffic)
+		period := float64(200 - traffic.start)
+		for j, dest := range simulation.matrix.Destinations {
+			pair := Pair{Producer: p.Name(), Consumer: dest}
+			want := simulation.matrix.Rates[i][j] * period
+			if math.Abs(float64(received[pair])-want) > 1 {
+				t.Errorf("Expected about %.1f messages for %s, got %d", want, pair, received[pair])
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// GroupMembership measures the joins and leaves of the multicast groups and
+// how they race the messages of their groups. A membership change takes
+// effect when the distributor applies it, not when the consumer sends it:
+// a joining consumer misses the messages fanned out in between, and a
+// leaving consumer still receives copies. Its methods are safe to call on a
+// nil tracker.
+type GroupMembership struct {
+	fanOuts map[string][]sim.VTimeInSec // Times the messages of every group were fanned out
+	copies  map[string][]sim.VTimeInSec // Times copies were sent to every group@member
+
+	Joins     int
+	Leaves    int
+	Races     int       // Changes that raced at least one message of their group
+	Missed    int       // Messages fanned out while a join to their group was on its way
+	Stale     int       // Copies sent to a member while its leave was on its way
+	Abandoned int       // Copies not sent to members that left during the fan-out of a message
+	Delays    []float64 // Time from sending every change to applying it
+}
+
+// NewGroupMembership creates a tracker that has seen no membership changes
+func NewGroupMembership() *GroupMembership {
+	return &GroupMembership{
+		fanOuts: make(map[string][]sim.VTimeInSec),
+		copies:  make(map[string][]sim.VTimeInSec),
+	}
+}
+
+// FannedOut records that a message of a group was fanned out
+func (m *GroupMembership) FannedOut(now sim.VTimeInSec, group string) {
+	if m == nil {
+		return
+	}
+	m.fanOuts[group] = append(m.fanOuts[group], now)
+}
+
+// Copied records a copy of a message of a group sent to a member
+func (m *GroupMembership) Copied(now sim.VTimeInSec, group, member string) {
+	if m == nil {
+		return
+	}
+	m.copies[group+"@"+member] = append(m.copies[group+"@"+member], now)
+}
+
+// Joined records a join sent at sent and applied now, and returns the
+// messages of the group the consumer missed in between
+func (m *GroupMembership) Joined(now, sent sim.VTimeInSec, group string) int {
+	m.Joins++
+	return m.applied(now, sent, m.fanOuts[group], &m.Missed)
+}
+
+// Left records a leave sent at sent and applied now, and returns the copies
+// the consumer was sent in between
+func (m *GroupMembership) Left(now, sent sim.VTimeInSec, group, member string) int {
+	m.Leaves++
+	return m.applied(now, sent, m.copies[group+"@"+member], &m.Stale)
+}
+
+// Abandon counts a copy not sent to a member that left during the fan-out
+// of a message
+func (m *GroupMembership) Abandon() {
+	m.Abandoned++
+}
+
+func (m *GroupMembership) applied(now, sent sim.VTimeInSec, times []sim.VTimeInSec, count *int) int {
+	m.Delays = append(m.Delays, float64(now-sent))
+	raced := 0
+	for _, t := range times {
+		if t >= sent && t < now {
+			raced++
+		}
+	}
+	*count += raced
+	if raced > 0 {
+		m.Races++
+	}
+	return raced
+}
+
+// Print writes the membership changes, how they raced the messages of
+// their groups, and the members of the groups at the end of the run
+func (m *GroupMembership) Print(out EventSink, groups map[string][]string) {
+	out.Println("=== Group Membership ===")
+	out.Printf("Joins:             %d\n", m.Joins)
+	out.Printf("Leaves:            %d\n", m.Leaves)
+	if len(m.Delays) > 0 {
+		longest := 0.0
+		for _, delay := range m.Delays {
+			longest = math.Max(longest, delay)
+		}
+		out.Printf("Change delay:      mean %.2f s, max %.2f s\n", mean(m.Delays), longest)
+	}
+	out.Printf("Races:             %d of %d changes\n", m.Races, m.Joins+m.Leaves)
+	out.Printf("Missed:            %d messages fanned out while a join was on its way\n", m.Missed)
+	out.Printf("Stale copies:      %d sent while a leave was on its way\n", m.Stale)
+	out.Printf("Abandoned copies:  %d not sent to members that left during a fan-out\n", m.Abandoned)
+	for _, name := range multicastTargets(groups)[1:] {
+		out.Printf("%-18s %s\n", name+":", strings.Join(groups[name], " "))
+	}
+}
+
+// checkMemberships checks that the consumers that join and leave multicast
+// groups are consumers
+func checkMemberships(cfg *Config, consumers []string) error {
+	known := make(map[string]bool)
+	for _, name := range consumers {
+		known[name] = true
+	}
+	for _, lists := range []map[string][]string{cfg.Joins, cfg.Leaves} {
+		for name := range lists {
+			if !known[name] {
+				return fmt.Errorf("unknown consumer %q in joins or leaves", name)
+			}
+		}
+	}
+	for _, times := range []map[string]float64{cfg.JoinAt, cfg.LeaveAt} {
+		for name := range times {
+			if !known[name] {
+				return fmt.Errorf("unknown consumer %q in join-at or leave-at", name)
+			}
+		}
+	}
+	return nil
+}
+
+// GroupsLeft returns the groups the consumer leaves at its LeaveAt, the ones
+// it joined unless its Leaves say otherwise
+func (c *Config) GroupsLeft(name string) []string {
+	if leaves, ok := c.Leaves[name]; ok {
+		return leaves
+	}
+	return c.Joins[name]
+}
+
+// MulticastGroups returns the multicast groups with their initial members,
+// including the groups consumers only join during the run
+func (c *Config) MulticastGroups() map[string][]string {
+	groups := make(map[string][]string)
+	for name, members := range c.Groups {
+		groups[name] = append([]string(nil), members...)
+	}
+	for _, joins := range c.Joins {
+		for _, group := range joins {
+			if _, ok := groups[group]; !ok {
+				groups[group] = nil
+			}
+		}
+	}
+	return groups
+}
+
+// validateMemberships checks that the consumers join groups other than the
+// broadcast group while multicasting, leave groups they are members of, and
+// leave after they join
+func (c *Config) validateMemberships() error {
+	if len(c.Joins) == 0 && len(c.Leaves) == 0 && len(c.JoinAt) == 0 && len(c.LeaveAt) == 0 {
+		return nil
+	}
+	if c.Multicast == 0 {
+		return fmt.Errorf("joins and leaves need multicast")
+	}
+	groups := c.MulticastGroups()
+	for name, joins := range c.Joins {
+		for _, group := range joins {
+			if group == "" || group == distributor.BroadcastGroup {
+				return fmt.Errorf("%s cannot join group %q", name, group)
+			}
+		}
+	}
+	for name, leaves := range c.Leaves {
+		for _, group := range leaves {
+			if _, ok := groups[group]; !ok {
+				return fmt.Errorf("%s leaves the unknown group %q", name, group)
+			}
+		}
+		if _, ok := c.LeaveAt[name]; !ok {
+			return fmt.Errorf("leaves need a leave-at time for %s", name)
+		}
+	}
+	for name, at := range c.JoinAt {
+		if at < 0 {
+			return fmt.Errorf("join-at of %s must not be negative", name)
+		}
+		if _, ok := c.Joins[name]; !ok {
+			return fmt.Errorf("join-at of %s needs joins", name)
+		}
+	}
+	for name, at := range c.LeaveAt {
+		if at <= c.JoinAt[name] {
+			return fmt.Errorf("leave-at of %s must be after its join-at", name)
+		}
+		if len(c.Joins[name]) == 0 && len(c.Leaves[name]) == 0 {
+			return fmt.Errorf("leave-at of %s needs joins or leaves", name)
+		}
+	}
+	return nil
+}
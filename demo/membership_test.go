@@ -0,0 +1,74 @@
+package demo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// TestMembershipChangesRaceGroupMessages verifies that joins and leaves sent
+// over a slow control plane change the members of the groups once applied,
+// and that the messages fanned out in between are counted as races
+func TestMembershipChangesRaceGroupMessages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 80
+	cfg.Multicast = 0.6
+	cfg.Groups = map[string][]string{"Front": {"Consumer1"}}
+	cfg.Joins = map[string][]string{"Consumer2": {"Front"}, "Consumer3": {"Front", "Back"}}
+	cfg.JoinAt = map[string]float64{"Consumer2": 20}
+	cfg.LeaveAt = map[string]float64{"Consumer1": 40}
+	cfg.Leaves = map[string][]string{"Consumer1": {"Front"}}
+	cfg.LinkLatencies = map[string]float64{"ControlPlane": 3}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	want := map[string][]string{"Front": {"Consumer2", "Consumer3"}, "Back": {"Consumer3"}}
+	if !reflect.DeepEqual(simulation.distributor.Groups(), want) {
+		t.Errorf("Expected the members %v, got %v", want, simulation.distributor.Groups())
+	}
+	m := simulation.routing.membership
+	if m.Joins != 3 || m.Leaves != 1 {
+		t.Errorf("Expected 3 joins and 1 leave, got %d and %d", m.Joins, m.Leaves)
+	}
+	if m.Missed == 0 || m.Stale == 0 {
+		t.Errorf("Expected missed messages and stale copies, got %d and %d", m.Missed, m.Stale)
+	}
+	if v := simulation.verifier; v.Reordered > 0 || v.Duplicates > 0 {
+		t.Errorf("Expected every member to get one copy in order, got %d reordered and %d duplicates",
+			v.Reordered, v.Duplicates)
+	}
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected every message to be accounted for, got %+v", simulation.Conservation())
+	}
+}
+
+// TestMembershipChangesNeedMulticast verifies that joins need multicast and
+// leaves need a time
+func TestMembershipChangesNeedMulticast(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Joins = map[string][]string{"Consumer1": {"Front"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected joins without multicast to be rejected")
+	}
+	cfg.Multicast = 0.5
+	cfg.Leaves = map[string][]string{"Consumer1": {"Front"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected leaves without a leave-at time to be rejected")
+	}
+	cfg.LeaveAt = map[string]float64{"Consumer1": 30}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the membership changes to be valid, got %v", err)
+	}
+	cfg.Joins = map[string][]string{"Consumer1": {distributor.BroadcastGroup}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a join to the broadcast group to be rejected")
+	}
+}
@@ -0,0 +1,187 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// flowStep is a hop of the sampled message between two components, or a
+// note about what a component did with it
+type flowStep struct {
+	from, to string // to is empty for a note over from
+	text     string
+	sent     sim.VTimeInSec
+	arrival  sim.VTimeInSec
+	arrived  bool
+	ack      bool
+}
+
+// MessageFlow records the lifecycle of a single message, from its
+// generation through the distributor to its consumption and its ACK, and
+// writes it as a Mermaid sequence diagram
+type MessageFlow struct {
+	timeTeller sim.TimeTeller
+	MsgID      uint64 // Sampled message, 0 samples the first message sent
+	steps      []flowStep
+}
+
+// NewMessageFlow creates a recorder for the message with the given ID
+func NewMessageFlow(timeTeller sim.TimeTeller, msgID uint64) *MessageFlow {
+	return &MessageFlow{timeTeller: timeTeller, MsgID: msgID}
+}
+
+// Watch records the sampled message and its ACK passing through a port
+func (f *MessageFlow) Watch(port sim.Port) {
+	port.AcceptHook(f)
+}
+
+// Func records the hops of the sampled message and of its ACK
+func (f *MessageFlow) Func(ctx sim.HookCtx) {
+	port, ok := ctx.Domain.(sim.Port)
+	if !ok {
+		return
+	}
+	now := f.timeTeller.CurrentTime()
+
+	switch m := ctx.Item.(type) {
+	case *msg.DemoMessage:
+		if f.MsgID == 0 && ctx.Pos == sim.HookPosPortMsgSend {
+			f.MsgID = m.ID
+		}
+		if m.ID != f.MsgID {
+			return
+		}
+		switch ctx.Pos {
+		case sim.HookPosPortMsgSend:
+			f.send(port, m.Meta().Dst, fmt.Sprintf("#%d for %s", m.ID, m.Destination), now, false)
+		case sim.HookPosPortMsgRecvd:
+			f.arrive(port, now)
+		case sim.HookPosPortMsgRetrieve:
+			f.note(port, now)
+		}
+	case *msg.AckMsg:
+		if m.MsgID != f.MsgID {
+			return
+		}
+		switch ctx.Pos {
+		case sim.HookPosPortMsgSend:
+			f.send(port, m.Meta().Dst, fmt.Sprintf("ACK #%d", m.MsgID), now, true)
+		case sim.HookPosPortMsgRecvd:
+			f.arrive(port, now)
+		}
+	}
+}
+
+func (f *MessageFlow) send(src, dst sim.Port, text string, now sim.VTimeInSec, ack bool) {
+	f.steps = append(f.steps, flowStep{
+		from: src.Component().Name(),
+		to:   dst.Component().Name(),
+		text: text,
+		sent: now,
+		ack:  ack,
+	})
+}
+
+// arrive completes the latest hop to the component of the port
+func (f *MessageFlow) arrive(port sim.Port, now sim.VTimeInSec) {
+	name := port.Component().Name()
+	for i := len(f.steps) - 1; i >= 0; i-- {
+		if f.steps[i].to == name && !f.steps[i].arrived {
+			f.steps[i].arrival = now
+			f.steps[i].arrived = true
+			return
+		}
+	}
+}
+
+// note records the component taking the message out of its input buffer:
+// the distributor routes it and a consumer consumes it. The distributor
+// forwards the message before it retrieves it, so the note goes before the
+// hops the component sent at the same time.
+func (f *MessageFlow) note(port sim.Port, now sim.VTimeInSec) {
+	name := port.Component().Name()
+	action := "consumed"
+	if _, ok := port.Component().(*distributor.Distributor); ok {
+		action = "routed"
+	}
+
+	i := len(f.steps)
+	for i > 0 && f.steps[i-1].from == name && f.steps[i-1].sent == now {
+		i--
+	}
+	step := flowStep{from: name, text: fmt.Sprintf("%s at %.2f", action, now), sent: now}
+	f.steps = append(f.steps[:i], append([]flowStep{step}, f.steps[i:]...)...)
+}
+
+// WriteMermaid writes the recorded lifecycle as a Mermaid sequence diagram
+func (f *MessageFlow) WriteMermaid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "sequenceDiagram")
+
+	// Participants appear in the order they are involved
+	seen := make(map[string]bool)
+	for _, step := range f.steps {
+		for _, name := range []string{step.from, step.to} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				fmt.Fprintf(bw, "    participant %s\n", name)
+			}
+		}
+	}
+
+	for _, step := range f.steps {
+		if step.to == "" {
+			fmt.Fprintf(bw, "    Note over %s: %s\n", step.from, step.text)
+			continue
+		}
+		arrow := "->>"
+		if step.ack {
+			arrow = "-->>"
+		}
+		arrival := "never arrived"
+		if step.arrived {
+			arrival = fmt.Sprintf("arrived at %.2f", step.arrival)
+		}
+		fmt.Fprintf(bw, "    %s%s%s: %s, sent at %.2f, %s\n",
+			step.from, arrow, step.to, step.text, step.sent, arrival)
+	}
+	if len(f.steps) == 0 {
+		fmt.Fprintf(bw, "    Note over Producer: message #%d was never sent\n", f.MsgID)
+	}
+	return bw.Flush()
+}
+
+// ExportMermaid writes a Markdown file with the topology as a Mermaid
+// flowchart and the lifecycle of the sampled message as a sequence diagram,
+// ready to be pasted into documentation or an issue
+func ExportMermaid(path string, topology *Topology, flow *MessageFlow) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	fmt.Fprintln(bw, "## Topology")
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "```mermaid")
+	if err := topology.WriteMermaid(bw); err != nil {
+		return err
+	}
+	fmt.Fprintln(bw, "```")
+	fmt.Fprintln(bw)
+	fmt.Fprintf(bw, "## Message #%d\n", flow.MsgID)
+	fmt.Fprintln(bw)
+	fmt.Fprintln(bw, "```mermaid")
+	if err := flow.WriteMermaid(bw); err != nil {
+		return err
+	}
+	fmt.Fprintln(bw, "```")
+	return bw.Flush()
+}
@@ -0,0 +1,74 @@
+package demo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestMermaidFollowsSampledMessage verifies that the sequence diagram shows
+// the hops of the sampled message in order, from the producer to its ACK
+func TestMermaidFollowsSampledMessage(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	cfg.Cycles = 40
+	cfg.MermaidFile = "unused.md"
+	cfg.MermaidMsg = 6
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	var buf bytes.Buffer
+	if err := simulation.messageFlow.WriteMermaid(&buf); err != nil {
+		t.Fatal(err)
+	}
+	diagram := buf.String()
+	
+	last := -1
+	for _, line := range []string{
+		"Producer->>Distributor: #6 for ",
+		"Note over Distributor: routed at ",
+		"Distributor->>Consumer",
+		"Note over Consumer",
+		"-->>Producer: ACK #6, ",
+	} {
+		i := strings.Index(diagram, line)
+		if i < 0 {
+			t.Fatalf("Expected the diagram to contain %q:\n%s", line, diagram)
+		}
+		if i < last {
+			t.Errorf("Expected %q to come later:\n%s", line, diagram)
+		}
+		last = i
+	}
+}
+
+// TestMermaidFlowchartLinksPortsToConnections verifies that the flowchart
+// groups the ports by component and links them to their connections
+func TestMermaidFlowchartLinksPortsToConnections(t *testing.T) {
+	simulation, err := NewSimulation(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	var buf bytes.Buffer
+	if err := simulation.topology.WriteMermaid(&buf); err != nil {
+		t.Fatal(err)
+	}
+	chart := buf.String()
+	
+	if n := strings.Count(chart, "subgraph"); n != 6 {
+		t.Errorf("Expected 6 components, got %d", n)
+	}
+	if !strings.Contains(chart, `conn0(["ProducerToDistributor"])`) {
+		t.Errorf("Expected the producer connection as first connection:\n%s", chart)
+	}
+	if n := strings.Count(chart, " --- "); n != 15 {
+		t.Errorf("Expected 15 links between ports and connections, got %d", n)
+	}
+}
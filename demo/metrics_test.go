@@ -0,0 +1,53 @@
+package demo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestDerivedMetricsFollowUtilizationLaw verifies the offered/carried load
+// and that the utilization of each stage is throughput times service demand
+// divided by the number of servers
+func TestDerivedMetricsFollowUtilizationLaw(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"})
+	c := consumer.New("Consumer1", engine, 2.0, consumer.WithQueues(2, consumerInCapacity))
+	for i, n := range []int{3, 2} {
+		port := c.RxPorts()[i]
+		for j := 0; j < n; j++ {
+			m := &msg.DemoMessage{ID: uint64(10*i + j), Destination: "Consumer1"}
+			m.Meta().Dst = port
+			port.Recv(m)
+		}
+	}
+	c.TickLater(0)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	stats := NewStats()
+	stats.Produced = 6
+	stats.Routed = 5
+	stats.Consumed = 5
+	
+	m := ComputeDerivedMetrics(stats, 10, d, []*consumer.Consumer{c})
+	
+	if m.OfferedLoad != 0.6 || m.CarriedLoad != 0.5 {
+		t.Errorf("Expected offered/carried load 0.6/0.5 msg/s, got %v/%v", m.OfferedLoad, m.CarriedLoad)
+	}
+	if len(m.Stages) != 2 {
+		t.Fatalf("Expected 2 stages, got %d", len(m.Stages))
+	}
+	if math.Abs(m.Stages[0].Utilization-0.5) > 1e-9 {
+		t.Errorf("Expected distributor utilization 0.5, got %v", m.Stages[0].Utilization)
+	}
+	// 0.5 msg/s * 2 s/msg over 2 RX queues
+	if math.Abs(m.Stages[1].Utilization-0.5) > 1e-9 {
+		t.Errorf("Expected consumer utilization 0.5, got %v", m.Stages[1].Utilization)
+	}
+}
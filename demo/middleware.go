@@ -0,0 +1,23 @@
+package demo
+
+import (
+	"github.com/syifan/akita_demo/middleware"
+)
+
+// printMiddleware writes the decisions of the middlewares at every stage
+func printMiddleware(out EventSink, c *middleware.Chain) {
+	out.Println("=== Middleware ===")
+	out.Printf("Middlewares:       %d\n", c.Len())
+	out.Printf("%-8s %8s %8s %8s %11s\n", "Stage", "Seen", "Dropped", "Delayed", "Duplicated")
+	for stage := middleware.StageProduce; stage < middleware.NumStages; stage++ {
+		out.Printf("%-8s %8d %8d %8d %11d\n",
+			stage, c.Seen[stage], c.Dropped[stage], c.Delayed[stage], c.Duplicated[stage])
+	}
+}
+
+// Use appends a middleware to the chain that intercepts every message when
+// it is produced, routed by the root distributor, and consumed. Middlewares
+// must be added before the run starts.
+func (s *Simulation) Use(m middleware.Middleware) {
+	s.middleware.Use(m)
+}
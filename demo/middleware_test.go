@@ -0,0 +1,111 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/syifan/akita_demo/middleware"
+)
+
+// runWithMiddleware runs the default workload with the given middleware
+func runWithMiddleware(t *testing.T, m middleware.Middleware) *Simulation {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 200
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.Use(m)
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return simulation
+}
+
+// TestMiddlewareDropsAndDuplicates verifies that messages dropped or
+// duplicated by middlewares at every stage are accounted for, and that the
+// copies reach the consumers as duplicates
+func TestMiddlewareDropsAndDuplicates(t *testing.T) {
+	produced := 0
+	simulation := runWithMiddleware(t, middleware.Funcs{
+		Produce: func(i *middleware.Interception) {
+			produced++
+			if produced%5 == 0 {
+				i.Copies = 1
+			}
+		},
+		Route: func(i *middleware.Interception) {
+			i.Drop = i.Msg.Destination == "Consumer2"
+		},
+		Consume: func(i *middleware.Interception) {
+			i.Drop = i.Msg.ID%7 == 0
+		},
+	})
+	
+	chain := simulation.middleware
+	if chain.Dropped[middleware.StageRoute] == 0 || chain.Dropped[middleware.StageConsume] == 0 {
+		t.Errorf("Expected drops when routing and consuming, got %v", chain.Dropped)
+	}
+	if chain.Duplicated[middleware.StageProduce] != produced/5 {
+		t.Errorf("Expected %d copies, got %d", produced/5, chain.Duplicated[middleware.StageProduce])
+	}
+	if chain.Seen[middleware.StageProduce] != simulation.stats.Produced {
+		t.Errorf("Expected every produced message to be intercepted, got %d of %d",
+			chain.Seen[middleware.StageProduce], simulation.stats.Produced)
+	}
+	for _, c := range simulation.consumers {
+		if c.Name() == "Consumer2" && c.TotalConsumed() > 0 {
+			t.Errorf("Expected no message to reach Consumer2, got %d", c.TotalConsumed())
+		}
+	}
+	if simulation.verifier.Duplicates == 0 {
+		t.Error("Expected the copies to show as duplicates")
+	}
+	if c := simulation.Conservation(); !c.Holds() {
+		t.Errorf("Expected conservation to hold, got %+v", c)
+	}
+}
+
+// TestMiddlewareDelaysAndChanges verifies that held messages arrive later
+// but all of them, and that changes to a message reach the consumer
+func TestMiddlewareDelaysAndChanges(t *testing.T) {
+	base := runWithMiddleware(t, middleware.Funcs{})
+	
+	var contents []string
+	simulation := runWithMiddleware(t, middleware.Funcs{
+		Produce: func(i *middleware.Interception) {
+			i.Msg.Content = "tagged " + i.Msg.Content
+		},
+		Route: func(i *middleware.Interception) {
+			i.Delay = 2
+		},
+		Consume: func(i *middleware.Interception) {
+			contents = append(contents, i.Msg.Content)
+		},
+	})
+	
+	if simulation.stats.Consumed != base.stats.Consumed || simulation.stats.Consumed == 0 {
+		t.Errorf("Expected %d messages consumed, got %d", base.stats.Consumed, simulation.stats.Consumed)
+	}
+	if simulation.stats.MeanLatency() <= base.stats.MeanLatency()+1 {
+		t.Errorf("Expected the delay to raise the mean latency of %.2f s, got %.2f s",
+			base.stats.MeanLatency(), simulation.stats.MeanLatency())
+	}
+	if simulation.middleware.Delayed[middleware.StageRoute] != simulation.middleware.Seen[middleware.StageRoute] {
+		t.Errorf("Expected every routed message to be held, got %d of %d",
+			simulation.middleware.Delayed[middleware.StageRoute], simulation.middleware.Seen[middleware.StageRoute])
+	}
+	for _, content := range contents {
+		if !strings.HasPrefix(content, "tagged ") {
+			t.Fatalf("Expected the changed content to reach the consumer, got %q", content)
+		}
+	}
+	if c := simulation.Conservation(); !c.Holds() {
+		t.Errorf("Expected conservation to hold, got %+v", c)
+	}
+}
@@ -0,0 +1,95 @@
+package demo
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/sarchlab/akita/v3/monitoring"
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// monitorPort returns the port number of a monitoring address such as
+// ":8080". Akita's monitor listens on all interfaces, so the host is only
+// checked for syntax.
+func monitorPort(addr string) (int, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return n, nil
+}
+
+// progressHook advances a progress bar of the monitor with the virtual time
+type progressHook struct {
+	bar     *monitoring.ProgressBar
+	reached uint64 // Seconds of virtual time reported so far
+}
+
+// Func reports the seconds passed before every event. Draining may run past
+// the end of the bar, which stays full.
+func (h *progressHook) Func(ctx sim.HookCtx) {
+	if ctx.Pos != sim.HookPosBeforeEvent {
+		return
+	}
+	evt, ok := ctx.Item.(sim.Event)
+	if !ok {
+		return
+	}
+
+	now := uint64(evt.Time())
+	if now > h.bar.Total {
+		now = h.bar.Total
+	}
+	if now > h.reached {
+		h.bar.IncrementFinished(now - h.reached)
+		h.reached = now
+	}
+}
+
+// StartMonitor serves Akita's monitoring web UI at the given address while
+// the simulation runs. The UI shows the fields of every component, the
+// messages buffered in their ports, and the progress of the virtual time, and
+// it can pause and continue the engine.
+func (s *Simulation) StartMonitor(addr string) error {
+	port, err := monitorPort(addr)
+	if err != nil {
+		return err
+	}
+
+	m := monitoring.NewMonitor().WithPortNumber(port)
+	m.RegisterEngine(s.engine)
+	for _, c := range s.monitored() {
+		m.RegisterComponent(c)
+	}
+
+	bar := m.CreateProgressBar("Virtual time (s)", uint64(s.cfg.Cycles))
+	s.engine.AcceptHook(&progressHook{bar: bar})
+	m.StartServer()
+	return nil
+}
+
+// monitored returns the components shown in the monitoring UI: the
+// producers, every distributor of the tree, the consumers, the dead-letter
+// sink, and the watchdog if there is one
+func (s *Simulation) monitored() []sim.Component {
+	var components []sim.Component
+	for _, p := range s.producers {
+		components = append(components, p)
+	}
+	for _, d := range s.tree.Distributors() {
+		components = append(components, d)
+	}
+	for _, c := range s.consumers {
+		components = append(components, c)
+	}
+	components = append(components, s.deadLetters)
+	if s.watchdog != nil {
+		components = append(components, s.watchdog)
+	}
+	return components
+}
@@ -0,0 +1,46 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestMonitorPortParsesAddresses verifies that monitoring addresses are
+// reduced to their port number and malformed ones are rejected
+func TestMonitorPortParsesAddresses(t *testing.T) {
+	for addr, want := range map[string]int{":8080": 8080, "localhost:9000": 9000, ":0": 0} {
+		port, err := monitorPort(addr)
+		if err != nil || port != want {
+			t.Errorf("Expected port %d for %q, got %d (%v)", want, addr, port, err)
+		}
+	}
+	for _, addr := range []string{"8080", ":http", ":70000"} {
+		if _, err := monitorPort(addr); err == nil {
+			t.Errorf("Expected an error for %q", addr)
+		}
+	}
+}
+
+// TestMonitorShowsEveryDistributor verifies that the regional distributors
+// of a tree are shown in the monitoring UI with the root
+func TestMonitorShowsEveryDistributor(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.DistributorDepth = 3
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	shown := make(map[string]bool)
+	for _, c := range simulation.monitored() {
+		shown[c.Name()] = true
+	}
+	for _, d := range simulation.tree.Distributors() {
+		if !shown[d.Name()] {
+			t.Errorf("Expected %s in the monitoring UI", d.Name())
+		}
+	}
+	if len(simulation.tree.Regions()) == 0 {
+		t.Fatal("Expected regional distributors")
+	}
+}
@@ -0,0 +1,40 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// checkGroups checks that the members of the consumer groups are consumers,
+// and that no group is named like a consumer
+func checkGroups(groups map[string][]string, consumers []string) error {
+	known := make(map[string]bool)
+	for _, name := range consumers {
+		known[name] = true
+	}
+	for group, members := range groups {
+		if known[group] {
+			return fmt.Errorf("group %q is named like a consumer", group)
+		}
+		for _, member := range members {
+			if !known[member] {
+				return fmt.Errorf("unknown consumer %q in group %s", member, group)
+			}
+		}
+	}
+	return nil
+}
+
+// multicastTargets returns the broadcast group followed by the consumer
+// groups in sorted order
+func multicastTargets(groups map[string][]string) []string {
+	targets := []string{distributor.BroadcastGroup}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(targets, names...)
+}
@@ -0,0 +1,57 @@
+package demo
+
+import (
+	"testing"
+	"github.com/syifan/akita_demo/distributor"
+)
+
+// TestMulticastRetriesOnlyBlockedBranches verifies that the copies for a
+// slow consumer are retried without sending the other members a second copy,
+// and that the copies are accounted for
+func TestMulticastRetriesOnlyBlockedBranches(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 60
+	cfg.Multicast = 1
+	cfg.ConsumeIntervals = map[string]float64{"Consumer3": 8}
+	cfg.ConsumerInCapacity = 2
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	stats := simulation.stats
+	if stats.FannedOut == 0 || stats.BranchRetries == 0 {
+		t.Fatalf("Expected fanned out messages with retried branches, got %d and %d",
+			stats.FannedOut, stats.BranchRetries)
+	}
+	if simulation.verifier.Duplicates != 0 || simulation.verifier.Reordered != 0 {
+		t.Errorf("Expected every member to get one copy in order, got %d duplicates and %d reordered",
+			simulation.verifier.Duplicates, simulation.verifier.Reordered)
+	}
+	c := simulation.Conservation()
+	if !c.Holds() || c.Copies != stats.Copies-stats.FannedOut {
+		t.Errorf("Expected the copies to be accounted for, got %+v", c)
+	}
+}
+
+// TestMulticastGroupsMustHaveMembers verifies that the configuration rejects
+// empty groups and groups named like the broadcast group
+func TestMulticastGroupsMustHaveMembers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Groups = map[string][]string{"Front": nil}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an empty group to be rejected")
+	}
+	cfg.Groups = map[string][]string{distributor.BroadcastGroup: {"Consumer1"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected the group all to be rejected")
+	}
+	if err := checkGroups(map[string][]string{"Front": {"Consumer9"}}, []string{"Consumer1"}); err == nil {
+		t.Error("Expected an unknown member to be rejected")
+	}
+}
@@ -0,0 +1,211 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sarchlab/akita/v3/noc/networking/networkconnector"
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// networkKinds are the fabrics between the distributor and the consumers:
+//
+//	direct  a connection of its own to every consumer
+//	mesh    a grid of switches, as square as possible
+//	ring    switches in a ring
+var networkKinds = []string{"direct", "mesh", "ring"}
+
+// Network is a fabric of Akita NoC switches between the distributor and
+// the consumers. Every device, the distributor first, has an endpoint on a
+// switch of its own. The switches are wired into a mesh or a ring, and their
+// routing tables follow the shortest paths, so that messages cross several
+// switches and contend for the links they share. Endpoints break messages
+// into flits and reassemble them at the destination.
+type Network struct {
+	kind      string
+	width     int // Switches per row of a mesh
+	latency   int // Cycles a flit spends in every switch
+	flitSize  int
+	connector networkconnector.Connector
+	devices   []string // Devices in the order of their switches
+	transits  map[string]*NetworkTransit
+}
+
+// NetworkTransit is the time the messages to a consumer spent in the
+// network, from the distributor's send to the delivery at the consumer
+type NetworkTransit struct {
+	Messages int
+	Total    sim.VTimeInSec
+	Min      sim.VTimeInSec
+	Max      sim.VTimeInSec
+}
+
+// NewNetwork creates an empty fabric of a kind for the devices, which are
+// connected in the order they are given
+func NewNetwork(kind string, engine sim.Engine, devices int, latency, flitSize int) *Network {
+	n := &Network{
+		kind:     kind,
+		latency:  latency,
+		flitSize: flitSize,
+		transits: make(map[string]*NetworkTransit),
+	}
+	n.width = int(math.Ceil(math.Sqrt(float64(devices))))
+	n.connector = networkconnector.MakeConnector().
+		WithEngine(engine).
+		WithDefaultFreq(1 * sim.Hz).
+		WithFlitSize(flitSize)
+	n.connector.NewNetwork("Network")
+	return n
+}
+
+// ConnectDevice gives a device a switch and plugs its ports into an endpoint
+// on it
+func (n *Network) ConnectDevice(name string, ports ...sim.Port) {
+	switchID := n.connector.AddSwitch()
+	n.connector.ConnectDeviceWithEPName(name+"EndPoint", switchID, ports, n.deviceLink())
+	for _, port := range ports {
+		port.Component().AddPort(port.Name(), port)
+	}
+	n.devices = append(n.devices, name)
+}
+
+// Build wires the switches into the fabric and fills their routing tables.
+// It is called once every device is connected.
+func (n *Network) Build() {
+	count := len(n.devices)
+	switch n.kind {
+	case "ring":
+		for i := 0; i < count; i++ {
+			// Two switches need a single link
+			if next := (i + 1) % count; next != i && (count > 2 || next > i) {
+				n.connector.ConnectSwitches(i, next, n.switchLink())
+			}
+		}
+	case "mesh":
+		for i := 0; i < count; i++ {
+			if (i+1)%n.width != 0 && i+1 < count {
+				n.connector.ConnectSwitches(i, i+1, n.switchLink())
+			}
+			if i+n.width < count {
+				n.connector.ConnectSwitches(i, i+n.width, n.switchLink())
+			}
+		}
+	}
+	n.connector.EstablishRoute()
+}
+
+func (n *Network) deviceLink() networkconnector.DeviceToSwitchLinkParameter {
+	return networkconnector.DeviceToSwitchLinkParameter{
+		DeviceEndParam: networkconnector.LinkEndDeviceParameter{
+			IncomingBufSize:  4,
+			OutgoingBufSize:  4,
+			NumInputChannel:  1,
+			NumOutputChannel: 1,
+		},
+		SwitchEndParam: n.switchEnd(),
+		LinkParam:      networkconnector.LinkParameter{IsIdeal: true},
+	}
+}
+
+func (n *Network) switchLink() networkconnector.SwitchToSwitchLinkParameter {
+	return networkconnector.SwitchToSwitchLinkParameter{
+		LeftEndParam:  n.switchEnd(),
+		RightEndParam: n.switchEnd(),
+		LinkParam:     networkconnector.LinkParameter{IsIdeal: true},
+	}
+}
+
+func (n *Network) switchEnd() networkconnector.LinkEndSwitchParameter {
+	return networkconnector.LinkEndSwitchParameter{
+		IncomingBufSize:  4,
+		OutgoingBufSize:  4,
+		NumInputChannel:  1,
+		NumOutputChannel: 1,
+		Latency:          n.latency,
+	}
+}
+
+// Hops returns the number of links between the switches of two devices on
+// the shortest path
+func (n *Network) Hops(from, to int) int {
+	switch n.kind {
+	case "ring":
+		d := from - to
+		if d < 0 {
+			d = -d
+		}
+		if len(n.devices)-d < d {
+			d = len(n.devices) - d
+		}
+		return d
+	case "mesh":
+		rows := from/n.width - to/n.width
+		cols := from%n.width - to%n.width
+		if rows < 0 {
+			rows = -rows
+		}
+		if cols < 0 {
+			cols = -cols
+		}
+		return rows + cols
+	}
+	return 1
+}
+
+// Track records the transit times of the messages delivered to the ports of
+// a consumer
+func (n *Network) Track(consumer string, ports ...sim.Port) {
+	transit := &NetworkTransit{}
+	n.transits[consumer] = transit
+	for _, port := range ports {
+		port.AcceptHook(transit)
+	}
+}
+
+// Func records the transit of a message delivered to a tracked port
+func (t *NetworkTransit) Func(ctx sim.HookCtx) {
+	if ctx.Pos != sim.HookPosPortMsgRecvd {
+		return
+	}
+	meta := ctx.Item.(sim.Msg).Meta()
+	transit := meta.RecvTime - meta.SendTime
+	if t.Messages == 0 || transit < t.Min {
+		t.Min = transit
+	}
+	if transit > t.Max {
+		t.Max = transit
+	}
+	t.Messages++
+	t.Total += transit
+}
+
+// String describes the fabric for the setup
+func (n *Network) String() string {
+	shape := fmt.Sprintf("ring of %d switches", len(n.devices))
+	if n.kind == "mesh" {
+		rows := (len(n.devices) + n.width - 1) / n.width
+		shape = fmt.Sprintf("%dx%d mesh of %d switches", n.width, rows, len(n.devices))
+	}
+	return fmt.Sprintf("%s, %d-cycle switch latency, %d-byte flits", shape, n.latency, n.flitSize)
+}
+
+// Print writes the hops to every consumer and the transit times of the
+// messages sent to it. Transits above the minimum over the same hops were
+// spent waiting for links and switches taken by other flits.
+func (n *Network) Print(out EventSink) {
+	out.Println("=== Network ===")
+	out.Printf("%s\n", n)
+	out.Printf("%-12s %6s %4s %8s %9s %9s %9s\n", "Consumer", "Switch", "Hops", "Messages", "Min", "Mean", "Max")
+	for i, name := range n.devices[1:] {
+		t := n.transits[name]
+		if t == nil {
+			continue
+		}
+		mean := 0.0
+		if t.Messages > 0 {
+			mean = float64(t.Total) / float64(t.Messages)
+		}
+		out.Printf("%-12s %6d %4d %8d %7.2f s %7.2f s %7.2f s\n",
+			name, i+1, n.Hops(0, i+1), t.Messages, float64(t.Min), mean, float64(t.Max))
+	}
+}
@@ -0,0 +1,46 @@
+package demo
+
+import "testing"
+
+// TestRingNetworkRoutesOverSeveralHops verifies that a run over a ring of
+// switches delivers every message, and that the consumer two hops away from
+// the distributor gets its messages later than the ones next to it
+func TestRingNetworkRoutesOverSeveralHops(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 60
+	cfg.Network = "ring"
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected every message accounted for, got %+v", simulation.Conservation())
+	}
+	network := simulation.network
+	if hops := []int{network.Hops(0, 1), network.Hops(0, 2), network.Hops(0, 3)}; hops[0] != 1 || hops[1] != 2 || hops[2] != 1 {
+		t.Fatalf("Expected the consumers 1, 2, and 1 hops away, got %v", hops)
+	}
+	near, far := network.transits["Consumer1"], network.transits["Consumer2"]
+	if near.Messages == 0 || far.Messages == 0 {
+		t.Fatalf("Expected messages to Consumer1 and Consumer2, got %+v and %+v", near, far)
+	}
+	if far.Min <= near.Min {
+		t.Errorf("Expected a longer transit over two hops than over one, got %.2f and %.2f s", float64(far.Min), float64(near.Min))
+	}
+}
+
+// TestMeshHops verifies the hop counts of a mesh that is not full
+func TestMeshHops(t *testing.T) {
+	n := &Network{kind: "mesh", width: 3, devices: make([]string, 8)}
+	for to, want := range []int{0, 1, 2, 1, 2, 3, 2, 3} {
+		if got := n.Hops(0, to); got != want {
+			t.Errorf("Expected %d hops to switch %d, got %d", want, to, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+type latencySum struct {
+	count int
+	total sim.VTimeInSec
+}
+
+func (s latencySum) mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return float64(s.total) / float64(s.count)
+}
+
+// OverflowRouting lets the distributor send the messages of an overloaded
+// consumer to a designated overflow consumer instead. A consumer is
+// overloaded while Threshold or more messages wait in its RX queues. A
+// message only overflows if the overflow consumer is registered and not
+// overloaded itself. Its methods are safe to call on a nil policy.
+type OverflowRouting struct {
+	Consumer  string                    // Consumer that takes the overflow
+	Threshold int                       // Queued messages at which a consumer is overloaded
+	QueueLen  func(consumer string) int // Instantaneous queue length of a consumer
+
+	Decisions map[string]int // Messages rerouted, by the consumer they were addressed to
+	direct    map[string]latencySum
+	overflow  map[string]latencySum
+}
+
+// NewOverflowRouting creates the policy with the given overflow consumer and
+// threshold
+func NewOverflowRouting(consumer string, threshold int, queueLen func(string) int) *OverflowRouting {
+	return &OverflowRouting{
+		Consumer:  consumer,
+		Threshold: threshold,
+		QueueLen:  queueLen,
+		Decisions: make(map[string]int),
+		direct:    make(map[string]latencySum),
+		overflow:  make(map[string]latencySum),
+	}
+}
+
+// Reroute returns a copy of the message addressed to the overflow consumer
+// if its destination is overloaded, and the message unchanged otherwise. The
+// copy keeps its sequence number and remembers its original destination.
+func (o *OverflowRouting) Reroute(m *msg.DemoMessage, routes *distributor.RoutingTable) *msg.DemoMessage {
+	if o == nil || m.Destination == o.Consumer {
+		return m
+	}
+	if _, ok := routes.Lookup(m.Destination); !ok {
+		return m
+	}
+	if _, ok := routes.Lookup(o.Consumer); !ok {
+		return m
+	}
+	if o.QueueLen(m.Destination) < o.Threshold || o.QueueLen(o.Consumer) >= o.Threshold {
+		return m
+	}
+
+	rerouted := m.Clone()
+	rerouted.Destination = o.Consumer
+	rerouted.OverflowFrom = m.Destination
+	return rerouted
+}
+
+// String describes the overflow consumer and when it takes over
+func (o *OverflowRouting) String() string {
+	return fmt.Sprintf("to %s from %d queued messages", o.Consumer, o.Threshold)
+}
+
+// Routed counts the decision for a rerouted message once it has been sent
+func (o *OverflowRouting) Routed(m *msg.DemoMessage) {
+	if o == nil || m.OverflowFrom == "" {
+		return
+	}
+	o.Decisions[m.OverflowFrom]++
+}
+
+// Consumed records the end-to-end latency of a message under the consumer it
+// was addressed to, separately for rerouted messages
+func (o *OverflowRouting) Consumed(m *msg.DemoMessage, latency sim.VTimeInSec) {
+	if o == nil {
+		return
+	}
+	sums, dest := o.direct, m.Destination
+	if m.OverflowFrom != "" {
+		sums, dest = o.overflow, m.OverflowFrom
+	}
+	s := sums[dest]
+	s.count++
+	s.total += latency
+	sums[dest] = s
+}
+
+// Total returns the number of rerouted messages
+func (o *OverflowRouting) Total() int {
+	total := 0
+	for _, n := range o.Decisions {
+		total += n
+	}
+	return total
+}
+
+// Print writes the overflow decisions per consumer and the mean latency of
+// the messages that stayed with their consumer and of those that overflowed
+func (o *OverflowRouting) Print(out EventSink) {
+	out.Println("=== Overflow Routing ===")
+	out.Printf("Rerouted to %s:  %d messages (threshold %d)\n", o.Consumer, o.Total(), o.Threshold)
+
+	names := make([]string, 0, len(o.direct))
+	for name := range o.direct {
+		if name != o.Consumer {
+			names = append(names, name)
+		}
+	}
+	for name := range o.overflow {
+		if _, ok := o.direct[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out.Printf("%-12s %9s %14s %14s\n", "Consumer", "Rerouted", "Direct mean", "Rerouted mean")
+	for _, name := range names {
+		out.Printf("%-12s %9d %13.2fs %13.2fs\n",
+			name, o.Decisions[name], o.direct[name].mean(), o.overflow[name].mean())
+	}
+}
@@ -0,0 +1,83 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestOverflowReroutesOverloadedConsumer verifies that only the messages of
+// an overloaded consumer are rerouted, and only while the overflow consumer
+// is registered and has room
+func TestOverflowReroutesOverloadedConsumer(t *testing.T) {
+	queueLens := map[string]int{"Consumer1": 3, "Consumer2": 1, "Consumer3": 0}
+	o := NewOverflowRouting("Consumer3", 3, func(name string) int { return queueLens[name] })
+	routes := distributor.NewRoutingTable()
+	routes.Add("Consumer1", nil)
+	routes.Add("Consumer2", nil)
+	
+	m := &msg.DemoMessage{ID: 1, Destination: "Consumer1", SeqNum: 4}
+	if got := o.Reroute(m, routes); got != m {
+		t.Errorf("Expected no rerouting before the overflow consumer registers, got %s", got.Destination)
+	}
+	
+	routes.Add("Consumer3", nil)
+	got := o.Reroute(m, routes)
+	if got.Destination != "Consumer3" || got.OverflowFrom != "Consumer1" || got.SeqNum != 4 {
+		t.Errorf("Expected message #4 to overflow from Consumer1 to Consumer3, got %+v", got)
+	}
+	if got.Addressee() != "Consumer1" || m.Destination != "Consumer1" {
+		t.Errorf("Expected the original message to stay addressed to Consumer1")
+	}
+	if o.Reroute(&msg.DemoMessage{Destination: "Consumer2"}, routes).OverflowFrom != "" {
+		t.Error("Expected Consumer2 below the threshold to keep its message")
+	}
+	
+	queueLens["Consumer3"] = 3
+	if o.Reroute(m, routes) != m {
+		t.Error("Expected no rerouting while the overflow consumer is overloaded")
+	}
+}
+
+// TestOverflowRelievesSlowConsumer verifies that a slow consumer's messages
+// overflow, reach the overflow consumer faster, and are all accounted for
+func TestOverflowRelievesSlowConsumer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 300
+	cfg.ConsumeIntervals = map[string]float64{"Consumer1": 15}
+	cfg.OverflowConsumer = "Consumer3"
+	cfg.OverflowThreshold = 3
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	o := simulation.routing.overflow
+	if o.Decisions["Consumer1"] == 0 || o.Total() != o.Decisions["Consumer1"] {
+		t.Fatalf("Expected only Consumer1 to overflow, got %v", o.Decisions)
+	}
+	if o.overflow["Consumer1"].count != o.Total() {
+		t.Errorf("Expected all %d rerouted messages to be consumed, got %d", o.Total(), o.overflow["Consumer1"].count)
+	}
+	if o.overflow["Consumer1"].mean() >= o.direct["Consumer1"].mean() {
+		t.Errorf("Expected rerouted messages to be faster than those queued at Consumer1")
+	}
+	if !simulation.Conservation().Holds() || simulation.verifier.Duplicates > 0 {
+		t.Errorf("Expected every message to be consumed once")
+	}
+}
+
+// TestOverflowRejectsUnknownConsumer verifies the name of the overflow
+// consumer is checked against the topology
+func TestOverflowRejectsUnknownConsumer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OverflowConsumer = "Consumer9"
+	if _, err := NewSimulation(cfg); err == nil {
+		t.Error("Expected an error for an unknown overflow consumer")
+	}
+}
@@ -0,0 +1,219 @@
+package demo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SweepValues are the values a parameter of the param-sweep scenario takes.
+// In the config file they are either a list, [1, 2, 4], or a range,
+// {"from": 0.1, "to": 0.5, "step": 0.1}, which includes both ends.
+type SweepValues []float64
+
+// sweepRange is the form of a range of SweepValues in the config file
+type sweepRange struct {
+	From *float64 `json:"from"`
+	To   *float64 `json:"to"`
+	Step float64  `json:"step"`
+}
+
+// UnmarshalJSON reads a list or a range of values
+func (v *SweepValues) UnmarshalJSON(data []byte) error {
+	var list []float64
+	if err := json.Unmarshal(data, &list); err == nil {
+		*v = list
+		return nil
+	}
+
+	var r sweepRange
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("a sweep takes a list of values or a range with from, to, and step")
+	}
+	if r.From == nil || r.To == nil || r.Step <= 0 || *r.To < *r.From {
+		return fmt.Errorf("a sweep range needs from <= to and a positive step")
+	}
+	values := SweepValues{}
+	// The slack keeps the last step from falling short of to
+	n := int(math.Floor((*r.To-*r.From)/r.Step + 1e-9))
+	for i := 0; i <= n; i++ {
+		// Rounding drops the error the steps add up, 0.6 instead of
+		// 0.6000000000000001
+		values = append(values, math.Round((*r.From+float64(i)*r.Step)*1e9)/1e9)
+	}
+	*v = values
+	return nil
+}
+
+// ParamSweep is the parameters the param-sweep scenario varies. A parameter
+// without values keeps its configured value.
+type ParamSweep struct {
+	ArrivalRate        SweepValues `json:"arrival_rate"`
+	Consumers          SweepValues `json:"consumers"`
+	ConsumerInCapacity SweepValues `json:"consumer_in_capacity"`
+}
+
+// ParamSweepResult is the outcome of the run of one combination of the
+// swept parameters
+type ParamSweepResult struct {
+	ArrivalRate        float64
+	Consumers          int
+	ConsumerInCapacity int
+	Produced           int
+	Consumed           int
+	Dropped            int // Messages whose TTL ran out
+	MeanLatency        float64
+	P99Latency         float64
+	Throughput         float64
+}
+
+// orConfigured returns the values of a swept parameter, or its configured
+// value if it is not swept
+func (v SweepValues) orConfigured(configured float64) []float64 {
+	if len(v) == 0 {
+		return []float64{configured}
+	}
+	return v
+}
+
+// RunParamSweep runs the same workload once per combination of the swept
+// arrival rates, consumer counts, and RX queue capacities. The runs are
+// silent.
+func RunParamSweep(cfg *Config) ([]ParamSweepResult, error) {
+	// All runs must see the same random numbers
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var results []ParamSweepResult
+	for _, rate := range cfg.Sweep.ArrivalRate.orConfigured(cfg.ArrivalRate) {
+		for _, consumers := range cfg.Sweep.Consumers.orConfigured(float64(cfg.Consumers)) {
+			for _, capacity := range cfg.Sweep.ConsumerInCapacity.orConfigured(float64(cfg.ConsumerInCapacity)) {
+				runCfg := *cfg
+				runCfg.Seed = seed
+				runCfg.ArrivalRate = rate
+				runCfg.Consumers = int(consumers)
+				runCfg.ConsumerInCapacity = int(capacity)
+				runCfg.Sink = NullSink{}
+
+				simulation, err := NewSimulation(&runCfg)
+				if err != nil {
+					return nil, err
+				}
+
+				err = simulation.Run()
+				if err != nil {
+					return nil, fmt.Errorf("arrival rate %g, %d consumers, capacity %d: %w",
+						rate, runCfg.Consumers, runCfg.ConsumerInCapacity, err)
+				}
+
+				stats := simulation.stats
+				results = append(results, ParamSweepResult{
+					ArrivalRate:        rate,
+					Consumers:          runCfg.Consumers,
+					ConsumerInCapacity: runCfg.ConsumerInCapacity,
+					Produced:           stats.Produced,
+					Consumed:           stats.Consumed,
+					Dropped:            stats.Expired,
+					MeanLatency:        stats.MeanLatency(),
+					P99Latency:         stats.LatencyPercentile(99),
+					Throughput:         stats.Throughput(simulation.Duration()),
+				})
+			}
+		}
+	}
+	return results, nil
+}
+
+// PrintParamSweep writes the latencies and throughput of every combination
+func PrintParamSweep(out EventSink, results []ParamSweepResult) {
+	out.Println("=== Parameter Sweep ===")
+	out.Printf("%12s %9s %9s %9s %9s %8s %13s %12s %12s\n",
+		"Arrival rate", "Consumers", "Capacity", "Produced", "Consumed", "Dropped", "Mean latency", "p99 latency", "Throughput")
+	for _, r := range results {
+		out.Printf("%12.3f %9d %9d %9d %9d %8d %11.2f s %10.2f s %6.3f msg/s\n",
+			r.ArrivalRate, r.Consumers, r.ConsumerInCapacity, r.Produced, r.Consumed, r.Dropped,
+			r.MeanLatency, r.P99Latency, r.Throughput)
+	}
+}
+
+// WriteParamSweep writes the results matrix as CSV with a row per
+// combination, the swept parameters first
+func WriteParamSweep(w io.Writer, results []ParamSweepResult) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"arrival_rate", "consumers", "consumer_in_capacity",
+		"produced", "consumed", "dropped", "mean_latency", "p99_latency", "throughput",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		err := cw.Write([]string{
+			strconv.FormatFloat(r.ArrivalRate, 'f', -1, 64),
+			strconv.Itoa(r.Consumers),
+			strconv.Itoa(r.ConsumerInCapacity),
+			strconv.Itoa(r.Produced),
+			strconv.Itoa(r.Consumed),
+			strconv.Itoa(r.Dropped),
+			strconv.FormatFloat(r.MeanLatency, 'f', 4, 64),
+			strconv.FormatFloat(r.P99Latency, 'f', 4, 64),
+			strconv.FormatFloat(r.Throughput, 'f', 4, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportParamSweep writes the results matrix to a CSV file
+func ExportParamSweep(path string, results []ParamSweepResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return WriteParamSweep(f, results)
+}
+
+// validateParamSweep checks the values of the swept parameters, which the
+// producer and the consumers of the default topology take. Other runs
+// ignore the sweep, so that they can share its config file.
+func (c *Config) validateParamSweep() error {
+	if c.Sweep == nil || c.Scenario != "param-sweep" {
+		return nil
+	}
+	if c.Traffic != "random" || c.TraceFile != "" || c.TrafficMatrixFile != "" || c.RandomTopology || c.Bench > 0 {
+		return fmt.Errorf("param-sweep needs random traffic and the default topology")
+	}
+	for _, rate := range c.Sweep.ArrivalRate {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("swept arrival rates must be between 0 and 1")
+		}
+	}
+	for _, n := range c.Sweep.Consumers {
+		if n < 1 || n != math.Trunc(n) {
+			return fmt.Errorf("swept consumer counts must be positive whole numbers")
+		}
+	}
+	for _, capacity := range c.Sweep.ConsumerInCapacity {
+		if capacity < 1 || capacity != math.Trunc(capacity) {
+			return fmt.Errorf("swept consumer-in capacities must be positive whole numbers")
+		}
+		if c.ConsumerMode == "batch" && int(capacity) < c.BatchSize {
+			return fmt.Errorf("swept consumer-in capacities must hold a batch of %d", c.BatchSize)
+		}
+	}
+	return nil
+}
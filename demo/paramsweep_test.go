@@ -0,0 +1,91 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+)
+
+// TestSweepValues verifies that a sweep is read from a list or a range that
+// includes both ends
+func TestSweepValues(t *testing.T) {
+	var sweep ParamSweep
+	err := json.Unmarshal([]byte(`{"arrival_rate": {"from": 0.2, "to": 0.6, "step": 0.2}, "consumers": [1, 3]}`), &sweep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sweep.ArrivalRate) != 3 || sweep.ArrivalRate[2] != 0.6 {
+		t.Errorf("Expected the arrival rates 0.2, 0.4, and 0.6, got %v", sweep.ArrivalRate)
+	}
+	if len(sweep.Consumers) != 2 || sweep.ConsumerInCapacity != nil {
+		t.Errorf("Expected 2 consumer counts and no capacities, got %v and %v", sweep.Consumers, sweep.ConsumerInCapacity)
+	}
+	
+	for _, bad := range []string{`{"from": 1, "to": 0, "step": 1}`, `{"from": 0, "to": 1}`, `"1"`} {
+		var v SweepValues
+		if err := json.Unmarshal([]byte(bad), &v); err == nil {
+			t.Errorf("Expected %s to be rejected", bad)
+		}
+	}
+}
+
+// TestParamSweepRunsEveryCombination verifies that the sweep runs every
+// combination with the same traffic, that more consumers carry a load a
+// single one cannot, and that the matrix has a row per combination
+func TestParamSweepRunsEveryCombination(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 300
+	cfg.ConsumeInterval = 4
+	cfg.Scenario = "param-sweep"
+	cfg.Sweep = &ParamSweep{ArrivalRate: SweepValues{0.1, 0.5}, Consumers: SweepValues{1, 3}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	
+	results, err := RunParamSweep(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 runs, got %d", len(results))
+	}
+	if results[0].Produced != results[1].Produced {
+		t.Errorf("Expected the same traffic for 1 and 3 consumers, got %d and %d messages", results[0].Produced, results[1].Produced)
+	}
+	one, three := results[2], results[3]
+	if one.Consumers != 1 || three.Consumers != 3 || one.ConsumerInCapacity != consumerInCapacity {
+		t.Fatalf("Expected the runs in order of the swept values, got %+v and %+v", one, three)
+	}
+	if one.Throughput > 0.26 || three.Throughput <= one.Throughput || three.MeanLatency >= one.MeanLatency {
+		t.Errorf("Expected 3 consumers to carry more with less latency than 1, got %+v and %+v", three, one)
+	}
+	
+	var buf bytes.Buffer
+	if err := WriteParamSweep(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[0][0] != "arrival_rate" || rows[4][0] != "0.5" || rows[4][1] != "3" {
+		t.Errorf("Expected a header and a row per run, got %v", rows)
+	}
+	
+	for _, change := range []func(*Config){
+		func(c *Config) { c.Sweep = nil },
+		func(c *Config) { c.Traffic = "bursty" },
+		func(c *Config) { c.Sweep.Consumers = SweepValues{1.5} },
+		func(c *Config) { c.Sweep.ArrivalRate = SweepValues{2} },
+	} {
+		bad := DefaultConfig()
+		bad.Scenario = "param-sweep"
+		bad.Sweep = &ParamSweep{}
+		change(bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected the sweep to be rejected")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package demo
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// PauseWindow is a period during which a consumer serves no message, such as
+// a garbage collection or a compaction
+type PauseWindow struct {
+	Start, End sim.VTimeInSec
+	Arrivals   int // Messages that arrived during the pause
+	PeakDepth  int // Deepest queue during the pause
+}
+
+// Pauses holds the pause windows of a consumer and measures their effect:
+// how much the queue grows during a pause and the latency of the messages
+// whose life overlaps a pause, compared with the others. Its methods are
+// safe to call on nil pauses.
+type Pauses struct {
+	Windows []PauseWindow
+
+	affected   []float64 // Latencies of messages delayed by a pause
+	unaffected []float64
+}
+
+// PeriodicPauses creates pauses of the given duration every interval
+// seconds until stopTime. The first pause starts after phase seconds.
+func PeriodicPauses(interval, duration, phase, stopTime sim.VTimeInSec) *Pauses {
+	p := &Pauses{}
+	for start := phase; start < stopTime; start += interval {
+		p.Windows = append(p.Windows, PauseWindow{Start: start, End: start + duration})
+	}
+	return p
+}
+
+// RandomPauses creates pauses of the given duration until stopTime, with
+// exponentially distributed gaps between them that average interval
+// seconds from start to start
+func RandomPauses(interval, duration, stopTime sim.VTimeInSec, rng *rand.Rand) *Pauses {
+	p := &Pauses{}
+	start := sim.VTimeInSec(0)
+	for {
+		gap := sim.VTimeInSec(rng.ExpFloat64()) * interval
+		if gap < duration {
+			gap = duration
+		}
+		start += gap
+		if start >= stopTime {
+			return p
+		}
+		p.Windows = append(p.Windows, PauseWindow{Start: start, End: start + duration})
+	}
+}
+
+// window returns the index of the pause window containing now, or -1
+func (p *Pauses) window(now sim.VTimeInSec) int {
+	if p == nil {
+		return -1
+	}
+	for i, w := range p.Windows {
+		if w.Start <= now && now < w.End {
+			return i
+		}
+	}
+	return -1
+}
+
+// Until returns the end of the pause at now, and false if the consumer is not
+// paused
+func (p *Pauses) Until(now sim.VTimeInSec) (sim.VTimeInSec, bool) {
+	i := p.window(now)
+	if i < 0 {
+		return 0, false
+	}
+	return p.Windows[i].End, true
+}
+
+// String summarizes the pauses
+func (p *Pauses) String() string {
+	return fmt.Sprintf("%d windows", len(p.Windows))
+}
+
+// Arrived records a message arrival at the given queue depth
+func (p *Pauses) Arrived(now sim.VTimeInSec, depth int) {
+	i := p.window(now)
+	if i < 0 {
+		return
+	}
+	w := &p.Windows[i]
+	w.Arrivals++
+	if depth > w.PeakDepth {
+		w.PeakDepth = depth
+	}
+}
+
+// Consumed records the latency of a consumed message, as delayed by a pause
+// if the message was created before the end of a pause that started before
+// its consumption
+func (p *Pauses) Consumed(now, createTime sim.VTimeInSec) {
+	if p == nil {
+		return
+	}
+	latency := float64(now - createTime)
+	for _, w := range p.Windows {
+		if w.Start <= now && createTime < w.End {
+			p.affected = append(p.affected, latency)
+			return
+		}
+	}
+	p.unaffected = append(p.unaffected, latency)
+}
+
+// PrintPauseReport writes the pauses of every consumer, the growth of their
+// queues during the pauses, and the latency of the messages delayed by a
+// pause compared with the others
+func PrintPauseReport(out EventSink, consumers []*consumer.Consumer, models map[string]*consumerModels, duration sim.VTimeInSec) {
+	out.Println("=== Consumer Pauses ===")
+	out.Printf("%-10s %7s %18s %13s %11s\n", "Consumer", "Pauses", "Paused time", "Queue growth", "Peak depth")
+
+	var affected, unaffected []float64
+	for _, c := range consumers {
+		pauses := models[c.Name()].pauses
+		if pauses == nil {
+			continue
+		}
+
+		paused := sim.VTimeInSec(0)
+		arrivals, peak := 0, 0
+		for _, w := range pauses.Windows {
+			end := w.End
+			if end > duration {
+				end = duration
+			}
+			if end > w.Start {
+				paused += end - w.Start
+			}
+			arrivals += w.Arrivals
+			if w.PeakDepth > peak {
+				peak = w.PeakDepth
+			}
+		}
+		share := 0.0
+		if duration > 0 {
+			share = float64(paused/duration) * 100
+		}
+		growth := 0.0
+		if n := len(pauses.Windows); n > 0 {
+			growth = float64(arrivals) / float64(n)
+		}
+		out.Printf("%-10s %7d %8.2f s (%4.1f%%) %8.2f msgs %11d\n",
+			c.Name(), len(pauses.Windows), float64(paused), share, growth, peak)
+
+		affected = append(affected, pauses.affected...)
+		unaffected = append(unaffected, pauses.unaffected...)
+	}
+
+	out.Printf("Delayed by a pause: %d messages, mean %.2f s, p99 %.2f s\n",
+		len(affected), mean(affected), percentile(affected, 99))
+	out.Printf("Not delayed:        %d messages, mean %.2f s, p99 %.2f s\n",
+		len(unaffected), mean(unaffected), percentile(unaffected, 99))
+}
@@ -0,0 +1,66 @@
+package demo
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// TestPausedConsumerServesNothingUntilPauseEnds verifies that a consumer
+// keeps its queue during a pause and consumes once the pause is over
+func TestPausedConsumerServesNothingUntilPauseEnds(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	c := consumer.New("Consumer1", engine, 1.0,
+		consumer.WithStats(stats), consumer.WithPauses(PeriodicPauses(100, 5, 0, 10)))
+	
+	queueMessages(c, 2)
+	c.TickLater(0)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if stats.Consumed != 2 {
+		t.Fatalf("Expected both messages to be consumed after the pause, got %d", stats.Consumed)
+	}
+	if engine.CurrentTime() < 5 {
+		t.Errorf("Expected consumption to wait for the end of the pause at 5, finished at %.2f", engine.CurrentTime())
+	}
+}
+
+// TestPausesMeasureQueueGrowthAndDelayedMessages verifies the arrivals
+// counted during a pause and the split of latencies
+func TestPausesMeasureQueueGrowthAndDelayedMessages(t *testing.T) {
+	pauses := PeriodicPauses(20, 5, 10, 30)
+	
+	pauses.Arrived(4, 1)
+	pauses.Arrived(11, 1)
+	pauses.Arrived(12, 2)
+	pauses.Consumed(16, 9)
+	pauses.Consumed(8, 6)
+	
+	w := pauses.Windows[0]
+	if w.Arrivals != 2 || w.PeakDepth != 2 {
+		t.Errorf("Expected 2 arrivals and a peak depth of 2, got %d and %d", w.Arrivals, w.PeakDepth)
+	}
+	if len(pauses.affected) != 1 || len(pauses.unaffected) != 1 {
+		t.Errorf("Expected 1 delayed and 1 undelayed message, got %v and %v", pauses.affected, pauses.unaffected)
+	}
+}
+
+// TestRandomPausesDoNotOverlap verifies that random pauses are at least one
+// pause duration apart
+func TestRandomPausesDoNotOverlap(t *testing.T) {
+	pauses := RandomPauses(10, 3, 1000, rand.New(rand.NewSource(1)))
+	
+	if len(pauses.Windows) == 0 {
+		t.Fatal("Expected random pauses to be generated")
+	}
+	for i := 1; i < len(pauses.Windows); i++ {
+		if pauses.Windows[i].Start < pauses.Windows[i-1].End {
+			t.Errorf("Expected pause %d to start after the previous one ends", i)
+		}
+	}
+}
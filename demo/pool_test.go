@@ -0,0 +1,92 @@
+package demo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestPoolingKeepsResults verifies that recycling messages does not change
+// what the consumers see
+func TestPoolingKeepsResults(t *testing.T) {
+	run := func(pooling bool) map[string]ConsumerOutcome {
+		msg.Pooling = pooling
+		defer func() { msg.Pooling = true }()
+		
+		cfg := DefaultConfig()
+		cfg.Seed = 3
+		cfg.Bench = 20
+		silence(t, cfg)
+		
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return simulation.consumerOutcomes()
+	}
+	
+	pooled, allocated := run(true), run(false)
+	diffs := EngineDiffs(EngineRun{Consumers: allocated}, EngineRun{Consumers: pooled})
+	if len(diffs) > 0 {
+		t.Errorf("Expected the same consumption with pooling, got differences at %v", diffs)
+	}
+}
+
+// BenchmarkMessagePool compares the allocations of runs with and without
+// recycling messages
+func BenchmarkMessagePool(b *testing.B) {
+	for _, scale := range []int{10, 100} {
+		for _, pooling := range []bool{false, true} {
+			b.Run(fmt.Sprintf("scale=%d/pool=%t", scale, pooling), func(b *testing.B) {
+				msg.Pooling = pooling
+				defer func() { msg.Pooling = true }()
+				
+				cfg := DefaultConfig()
+				cfg.Seed = 1
+				cfg.Cycles = 2000
+				cfg.Bench = scale
+				silence(b, cfg)
+				
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					simulation, err := NewSimulation(cfg)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if err := simulation.Run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMessageAlloc measures a message allocated and dropped against one
+// taken from and given back to the pool
+func BenchmarkMessageAlloc(b *testing.B) {
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := new(msg.DemoMessage)
+			m.ID = uint64(i)
+			sinkMsg = m
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m := msg.New()
+			m.ID = uint64(i)
+			sinkMsg = m
+			m.Release()
+		}
+	})
+}
+
+// sinkMsg keeps the benchmarked messages from being optimized away
+var sinkMsg *msg.DemoMessage
@@ -0,0 +1,140 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Blocker is a lower-priority message queued ahead of a higher-priority one
+type Blocker struct {
+	ID       uint64
+	Priority int
+}
+
+// Inversion is a high-priority message that waited in a shared buffer behind
+// lower-priority messages for longer than the threshold
+type Inversion struct {
+	Port     string
+	ID       uint64
+	Priority int
+	Wait     sim.VTimeInSec
+	Chain    []Blocker // Lower-priority messages ahead of it when it arrived
+}
+
+// String formats the inversion with its blocking chain
+func (inv Inversion) String() string {
+	chain := make([]string, len(inv.Chain))
+	for i, b := range inv.Chain {
+		chain[i] = fmt.Sprintf("#%d (priority %d)", b.ID, b.Priority)
+	}
+	return fmt.Sprintf("%s: #%d (priority %d) waited %.2f s behind %s",
+		inv.Port, inv.ID, inv.Priority, float64(inv.Wait), strings.Join(chain, ", "))
+}
+
+type queuedMsg struct {
+	msg      *msg.DemoMessage
+	arrival  sim.VTimeInSec
+	blockers []Blocker
+}
+
+// InversionDetector watches FIFO ports shared by messages of different
+// priorities. It mirrors the queue of every watched port, so that when a
+// message leaves the queue it knows which lower-priority messages were ahead
+// of it and how long it waited.
+type InversionDetector struct {
+	timeTeller sim.TimeTeller
+	threshold  sim.VTimeInSec
+	out        EventSink
+	queues     map[sim.Port][]queuedMsg
+
+	Inversions []Inversion
+}
+
+// NewInversionDetector creates a detector that reports high-priority
+// messages blocked for longer than threshold
+func NewInversionDetector(timeTeller sim.TimeTeller, threshold sim.VTimeInSec, out EventSink) *InversionDetector {
+	return &InversionDetector{
+		timeTeller: timeTeller,
+		threshold:  threshold,
+		out:        out,
+		queues:     make(map[sim.Port][]queuedMsg),
+	}
+}
+
+// Watch starts mirroring the queue of a port
+func (d *InversionDetector) Watch(port sim.Port) {
+	d.queues[port] = nil
+	port.AcceptHook(d)
+}
+
+// Func mirrors the arrival and departure of messages at a watched port
+func (d *InversionDetector) Func(ctx sim.HookCtx) {
+	port, ok := ctx.Domain.(sim.Port)
+	if !ok {
+		return
+	}
+	m, ok := ctx.Item.(*msg.DemoMessage)
+	if !ok {
+		return
+	}
+	now := d.timeTeller.CurrentTime()
+
+	switch ctx.Pos {
+	case sim.HookPosPortMsgRecvd:
+		entry := queuedMsg{msg: m, arrival: now}
+		for _, ahead := range d.queues[port] {
+			if ahead.msg.Priority < m.Priority {
+				entry.blockers = append(entry.blockers, Blocker{ID: ahead.msg.ID, Priority: ahead.msg.Priority})
+			}
+		}
+		d.queues[port] = append(d.queues[port], entry)
+	case sim.HookPosPortMsgRetrieve:
+		queue := d.queues[port]
+		if len(queue) == 0 {
+			return
+		}
+		entry := queue[0]
+		d.queues[port] = queue[1:]
+
+		wait := now - entry.arrival
+		if len(entry.blockers) > 0 && wait > d.threshold {
+			inv := Inversion{
+				Port:     port.Name(),
+				ID:       entry.msg.ID,
+				Priority: entry.msg.Priority,
+				Wait:     wait,
+				Chain:    entry.blockers,
+			}
+			d.Inversions = append(d.Inversions, inv)
+			d.out.Printf("[%.2f] Priority inversion at %s\n", now, inv)
+		}
+	}
+}
+
+// Forget stops mirroring a message taken out of the middle of the queue of
+// a port. It is safe to call on a nil detector.
+func (d *InversionDetector) Forget(port sim.Port, m *msg.DemoMessage) {
+	if d == nil {
+		return
+	}
+	queue := d.queues[port]
+	for i, entry := range queue {
+		if entry.msg == m {
+			d.queues[port] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// Print writes the detected priority inversions
+func (d *InversionDetector) Print() {
+	d.out.Println("=== Priority Inversions ===")
+	d.out.Printf("Threshold:         %.2f s\n", float64(d.threshold))
+	d.out.Printf("Inversions:        %d\n", len(d.Inversions))
+	for _, inv := range d.Inversions {
+		d.out.Println("  " + inv.String())
+	}
+}
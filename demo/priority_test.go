@@ -0,0 +1,76 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestInversionDetectorReportsBlockingChain verifies that a high-priority
+// message queued behind lower-priority messages for longer than the threshold
+// is reported with the messages that blocked it
+func TestInversionDetectorReportsBlockingChain(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	clock := &fixedTime{}
+	c := consumer.New("Consumer1", engine, 1.0)
+	detector := NewInversionDetector(clock, 2, NullSink{})
+	detector.Watch(c.InputPort())
+	
+	recv := func(now sim.VTimeInSec, id uint64, priority int) {
+		clock.now = now
+		m := &msg.DemoMessage{ID: id, Destination: "Consumer1", Priority: priority}
+		m.Meta().Dst = c.InputPort()
+		c.InputPort().Recv(m)
+	}
+	retrieve := func(now sim.VTimeInSec) {
+		clock.now = now
+		c.InputPort().Retrieve(now)
+	}
+	
+	recv(1, 1, 0)
+	recv(2, 2, 2)
+	recv(3, 3, 1)
+	recv(4, 4, 2)
+	retrieve(5)
+	retrieve(6)
+	retrieve(7)
+	retrieve(8)
+	
+	if len(detector.Inversions) != 3 {
+		t.Fatalf("Expected 3 inversions, got %v", detector.Inversions)
+	}
+	first := detector.Inversions[0]
+	if first.ID != 2 || first.Wait != 4 || len(first.Chain) != 1 || first.Chain[0].ID != 1 {
+		t.Errorf("Unexpected first inversion: %v", first)
+	}
+	last := detector.Inversions[2]
+	if last.ID != 4 || last.Wait != 4 || len(last.Chain) != 2 {
+		t.Errorf("Unexpected last inversion: %v", last)
+	}
+}
+
+// TestInversionDetectorIgnoresShortWaits verifies that waits within the
+// threshold are not reported
+func TestInversionDetectorIgnoresShortWaits(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	clock := &fixedTime{}
+	c := consumer.New("Consumer1", engine, 1.0)
+	detector := NewInversionDetector(clock, 2, NullSink{})
+	detector.Watch(c.InputPort())
+	
+	for i, priority := range []int{0, 1} {
+		m := &msg.DemoMessage{ID: uint64(i + 1), Destination: "Consumer1", Priority: priority}
+		m.Meta().Dst = c.InputPort()
+		c.InputPort().Recv(m)
+	}
+	clock.now = 1
+	c.InputPort().Retrieve(1)
+	clock.now = 2
+	c.InputPort().Retrieve(2)
+	
+	if len(detector.Inversions) != 0 {
+		t.Errorf("Expected no inversions, got %v", detector.Inversions)
+	}
+}
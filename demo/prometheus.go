@@ -0,0 +1,150 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// metricsSnapshot is the state of the run published to the scrapers
+type metricsSnapshot struct {
+	time         sim.VTimeInSec
+	routed       int
+	conservation Conservation
+	depths       []int
+}
+
+// PrometheusExporter serves the counters and the queue depths of a run in
+// the Prometheus text format. The engine runs on its own goroutine, so the
+// state is copied into a snapshot whenever the virtual time moves on, and a
+// scrape reads the latest snapshot, which shows the state after all the
+// events of its time.
+type PrometheusExporter struct {
+	stats        *Stats
+	conservation func() Conservation
+	ports        []sim.Port
+	index        map[sim.Port]int
+	depths       []int
+	now          sim.VTimeInSec
+
+	mu       sync.Mutex
+	snapshot metricsSnapshot
+}
+
+// NewPrometheusExporter creates an exporter that publishes the counters of
+// stats and the accounting returned by conservation
+func NewPrometheusExporter(engine sim.Engine, stats *Stats, conservation func() Conservation) *PrometheusExporter {
+	e := &PrometheusExporter{
+		stats:        stats,
+		conservation: conservation,
+		index:        make(map[sim.Port]int),
+	}
+	engine.AcceptHook(e)
+	return e
+}
+
+// Track includes the buffer of a port in the queue-depth gauges
+func (e *PrometheusExporter) Track(port sim.Port) {
+	e.index[port] = len(e.ports)
+	e.ports = append(e.ports, port)
+	e.depths = append(e.depths, 0)
+	port.AcceptHook(e)
+}
+
+// Func publishes a snapshot before the first event of a new time and follows
+// the occupancy of the tracked ports
+func (e *PrometheusExporter) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosBeforeEvent:
+		if t := ctx.Item.(sim.Event).Time(); t > e.now {
+			e.publish()
+			e.now = t
+		}
+	case sim.HookPosPortMsgRecvd:
+		e.depths[e.index[ctx.Domain.(sim.Port)]]++
+	case sim.HookPosPortMsgRetrieve:
+		e.depths[e.index[ctx.Domain.(sim.Port)]]--
+	}
+}
+
+func (e *PrometheusExporter) publish() {
+	depths := make([]int, len(e.depths))
+	copy(depths, e.depths)
+	snapshot := metricsSnapshot{
+		time:         e.now,
+		routed:       e.stats.Routed,
+		conservation: e.conservation(),
+		depths:       depths,
+	}
+
+	e.mu.Lock()
+	e.snapshot = snapshot
+	e.mu.Unlock()
+}
+
+// Finish publishes the state at the end of the run
+func (e *PrometheusExporter) Finish(end sim.VTimeInSec) {
+	e.now = end
+	e.publish()
+}
+
+// ServeHTTP writes the latest snapshot in the Prometheus text format
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	s := e.snapshot
+	e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	c := s.conservation
+	writeMetric(bw, "akita_demo_virtual_time_seconds", "gauge", "Virtual time of the simulation.")
+	fmt.Fprintf(bw, "akita_demo_virtual_time_seconds %g\n", float64(s.time))
+	writeMetric(bw, "akita_demo_messages_produced_total", "counter", "Messages generated by the producers.")
+	fmt.Fprintf(bw, "akita_demo_messages_produced_total %d\n", c.Produced)
+	writeMetric(bw, "akita_demo_messages_routed_total", "counter", "Messages forwarded by the distributor.")
+	fmt.Fprintf(bw, "akita_demo_messages_routed_total %d\n", s.routed)
+	writeMetric(bw, "akita_demo_messages_consumed_total", "counter", "Messages consumed by the consumers.")
+	fmt.Fprintf(bw, "akita_demo_messages_consumed_total %d\n", c.Consumed)
+	writeMetric(bw, "akita_demo_messages_dropped_total", "counter", "Messages dropped, by reason.")
+	fmt.Fprintf(bw, "akita_demo_messages_dropped_total{reason=\"expired\"} %d\n", c.Expired)
+	fmt.Fprintf(bw, "akita_demo_messages_dropped_total{reason=\"dead_letter\"} %d\n", c.DeadLetters)
+	fmt.Fprintf(bw, "akita_demo_messages_dropped_total{reason=\"retention_miss\"} %d\n", c.RetentionMisses)
+	writeMetric(bw, "akita_demo_queue_depth", "gauge", "Messages waiting in the buffer of a port.")
+	for i, depth := range s.depths {
+		fmt.Fprintf(bw, "akita_demo_queue_depth{port=%q} %d\n", e.ports[i].Name(), depth)
+	}
+	bw.Flush()
+}
+
+func writeMetric(w *bufio.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// ServeMetrics serves the metrics at /metrics on the given address until the
+// program ends. Listening fails right away if the address is taken.
+func (s *Simulation) ServeMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metrics)
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// ServerURL returns the URL of a server listening at addr, on the local
+// host if addr has no host
+func ServerURL(addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	if host == "" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
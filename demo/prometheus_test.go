@@ -0,0 +1,51 @@
+package demo
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestPrometheusExporterPublishesFinalState verifies that after a run the
+// metrics show the counters of the run and empty queues
+func TestPrometheusExporterPublishesFinalState(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	cfg.Metrics = ":0"
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	rec := httptest.NewRecorder()
+	simulation.metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	
+	stats := simulation.stats
+	for _, line := range []string{
+		fmt.Sprintf("akita_demo_virtual_time_seconds %g", float64(simulation.Duration())),
+		fmt.Sprintf("akita_demo_messages_produced_total %d", stats.Produced),
+		fmt.Sprintf("akita_demo_messages_routed_total %d", stats.Routed),
+		fmt.Sprintf("akita_demo_messages_consumed_total %d", stats.Consumed),
+		`akita_demo_messages_dropped_total{reason="dead_letter"} 0`,
+		`akita_demo_queue_depth{port="Distributor.In"} 0`,
+		"# TYPE akita_demo_queue_depth gauge",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in the metrics, got:\n%s", line, body)
+		}
+	}
+}
+
+// TestServerURLDefaultsToLocalhost verifies the URL printed for an address
+// without a host
+func TestServerURLDefaultsToLocalhost(t *testing.T) {
+	if url := ServerURL(":9090"); url != "http://localhost:9090" {
+		t.Errorf("Expected http://localhost:9090, got %s", url)
+	}
+}
@@ -0,0 +1,162 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// QueueObserver measures the queue of a consumer the way a queueing model
+// describes it: the messages that arrive and the time every message waits in
+// the RX queue until the consumer serves it. A consumer notices an arrival
+// on its next tick, so a message waits from that tick on; the cycle before
+// is the pickup, not queueing. Its methods are safe to call on a nil
+// observer.
+type QueueObserver struct {
+	Arrivals int
+
+	waits []float64
+}
+
+// Arrived records a message arrival
+func (o *QueueObserver) Arrived() {
+	if o == nil {
+		return
+	}
+	o.Arrivals++
+}
+
+// Served records the wait of a message the consumer starts to serve, which
+// it could have served from its tick ready on
+func (o *QueueObserver) Served(now, ready sim.VTimeInSec) {
+	if o == nil {
+		return
+	}
+	wait := now - ready
+	if wait < 0 {
+		wait = 0
+	}
+	o.waits = append(o.waits, float64(wait))
+}
+
+// MeanLength returns the time-averaged number of messages waiting over the
+// run, the time they waited in total divided by its duration
+func (o *QueueObserver) MeanLength(duration sim.VTimeInSec) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	var total float64
+	for _, w := range o.waits {
+		total += w
+	}
+	return total / float64(duration)
+}
+
+// QueueObserver returns the observer of a consumer with the given service
+// times if the queueing model is compared, which describes consumers with
+// exponential service times only, and nil otherwise
+func (c *Config) QueueObserver(service *ServiceDist) *QueueObserver {
+	if !c.QueueingModel || service == nil || service.Kind != "exponential" {
+		return nil
+	}
+	return &QueueObserver{}
+}
+
+// MM1 is the M/M/1 queue with Poisson arrivals at rate Lambda and
+// exponential service times at rate Mu, in messages per second
+type MM1 struct {
+	Lambda, Mu float64
+}
+
+// Load returns the utilization of the server
+func (m MM1) Load() float64 {
+	return m.Lambda / m.Mu
+}
+
+// Stable reports whether the queue stays finite, which takes a load below 1
+func (m MM1) Stable() bool {
+	return m.Load() < 1
+}
+
+// QueueLength returns the expected number of messages waiting, not counting
+// the one in service
+func (m MM1) QueueLength() float64 {
+	rho := m.Load()
+	return rho * rho / (1 - rho)
+}
+
+// Wait returns the expected time a message waits until it is served
+func (m MM1) Wait() float64 {
+	return m.Load() / (m.Mu - m.Lambda)
+}
+
+// percentError returns the error of measured relative to expected in
+// percent, formatted for the report
+func percentError(measured, expected float64) string {
+	if expected == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%+.1f%%", (measured-expected)/expected*100)
+}
+
+// PrintQueueingReport compares the queue of every consumer with exponential
+// service times with the M/M/1 queue of its measured arrival rate and its
+// service rate. The expected and measured values differ by the sampling
+// error of the run, and because producers generate at most one message per
+// tick and consumers serve at their ticks.
+func PrintQueueingReport(out EventSink, consumers []*consumer.Consumer, models map[string]*consumerModels, duration sim.VTimeInSec) {
+	out.Println("=== M/M/1 Queueing Model ===")
+	out.Printf("%-12s %9s %9s %6s %24s %26s\n", "", "Arrivals", "Service", "", "Queue length (msgs)", "Wait (s)")
+	out.Printf("%-12s %9s %9s %6s %8s %8s %7s %8s %8s %8s\n",
+		"Consumer", "(msg/s)", "(msg/s)", "Load", "M/M/1", "Measured", "Error", "M/M/1", "Measured", "Error")
+	sorted := append([]*consumer.Consumer(nil), consumers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+	for _, c := range sorted {
+		o := models[c.Name()].queueing
+		if o == nil {
+			continue
+		}
+		model := MM1{Lambda: float64(o.Arrivals) / float64(duration), Mu: 1 / float64(c.Interval())}
+		length, wait := o.MeanLength(duration), mean(o.waits)
+		if !model.Stable() {
+			out.Printf("%-12s %9.3f %9.3f %6.2f %8s %8.2f %7s %8s %8.2f %8s\n",
+				c.Name(), model.Lambda, model.Mu, model.Load(), "unstable", length, "-", "unstable", wait, "-")
+			continue
+		}
+		out.Printf("%-12s %9.3f %9.3f %6.2f %8.2f %8.2f %7s %8.2f %8.2f %8s\n",
+			c.Name(), model.Lambda, model.Mu, model.Load(),
+			model.QueueLength(), length, percentError(length, model.QueueLength()),
+			model.Wait(), wait, percentError(wait, model.Wait()))
+	}
+}
+
+// validateQueueingModel checks that the M/M/1 model describes the consumers:
+// producers that generate messages at random, which arrive about as a
+// Poisson process, and consumers with one RX queue and exponential service
+// times that serve every message on its own
+func (c *Config) validateQueueingModel() error {
+	if !c.QueueingModel {
+		return nil
+	}
+	if c.Traffic != "random" || c.TraceFile != "" || c.TrafficMatrixFile != "" {
+		return fmt.Errorf("the queueing model needs random traffic")
+	}
+	if c.RxQueues != 1 || c.ConsumerMode == "batch" || c.WorkPool || c.Steal {
+		return fmt.Errorf("the queueing model needs consumers that serve one RX queue message by message")
+	}
+	specs := []string{c.ServiceDist}
+	for _, spec := range c.ServiceDists {
+		specs = append(specs, spec)
+	}
+	exponential := false
+	for _, spec := range specs {
+		kind, _, _ := ParseServiceDist(spec)
+		exponential = exponential || kind == "exponential"
+	}
+	if !exponential {
+		return fmt.Errorf("the queueing model needs consumers with exponential service times, see -service-dist")
+	}
+	return nil
+}
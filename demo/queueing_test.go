@@ -0,0 +1,79 @@
+package demo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// TestMM1 verifies the expected queue length and wait of an M/M/1 queue
+func TestMM1(t *testing.T) {
+	m := MM1{Lambda: 0.5, Mu: 1}
+	if m.Load() != 0.5 || !m.Stable() {
+		t.Errorf("Expected a stable load of 0.5, got %.2f", m.Load())
+	}
+	if m.QueueLength() != 0.5 || m.Wait() != 1 {
+		t.Errorf("Expected 0.5 messages waiting 1 s, got %.2f waiting %.2f s", m.QueueLength(), m.Wait())
+	}
+	if (MM1{Lambda: 1, Mu: 1}).Stable() {
+		t.Errorf("Expected a load of 1 to be unstable")
+	}
+	
+	o := &QueueObserver{}
+	o.Arrived()
+	o.Arrived()
+	o.Served(4, 2)
+	o.Served(5, 6)
+	if o.Arrivals != 2 || mean(o.waits) != 1 || o.MeanLength(sim.VTimeInSec(10)) != 0.2 {
+		t.Errorf("Expected 2 arrivals waiting 1 s on average, got %d waiting %v", o.Arrivals, o.waits)
+	}
+}
+
+// TestQueueingModelOfRun verifies that the measured waits of consumers with
+// exponential service times come close to the M/M/1 model
+func TestQueueingModelOfRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 5000
+	cfg.ConsumeInterval = 5
+	cfg.ServiceDist = "exponential"
+	cfg.QueueingModel = true
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	duration := simulation.Duration()
+	for _, c := range simulation.consumers {
+		o := simulation.models[c.Name()].queueing
+		if o == nil || o.Arrivals == 0 {
+			t.Fatalf("Expected %s to measure its queue", c.Name())
+		}
+		model := MM1{Lambda: float64(o.Arrivals) / float64(duration), Mu: 1 / float64(c.Interval())}
+		if wait := mean(o.waits); math.Abs(wait-model.Wait()) > 0.3*model.Wait() {
+			t.Errorf("Expected %s to wait about %.2f s, got %.2f s", c.Name(), model.Wait(), wait)
+		}
+	}
+	
+	for _, change := range []func(*Config){
+		func(c *Config) { c.ServiceDist = "fixed" },
+		func(c *Config) { c.Traffic = "bursty" },
+		func(c *Config) { c.RxQueues = 2 },
+	} {
+		bad := DefaultConfig()
+		bad.ServiceDist = "exponential"
+		bad.QueueingModel = true
+		change(bad)
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Expected the queueing model to be rejected")
+		}
+	}
+}
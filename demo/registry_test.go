@@ -0,0 +1,62 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// TestRegistrationAndDiscovery verifies that consumers register with the
+// distributor and that the producer discovers them afterwards
+func TestRegistrationAndDiscovery(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	names := []string{"Consumer1", "Consumer2"}
+	d := distributor.New("Distributor", engine, names)
+	p := producer.New("Producer", engine, 10,
+		producer.WithTraffic(&producer.RandomTraffic{Probability: 0}),
+		producer.WithDiscovery(d.CtrlPort(), 2))
+	
+	ctrlConn := sim.NewDirectConnection("ControlPlane", engine, 1*sim.Hz)
+	ctrlConn.PlugIn(d.CtrlPort(), 1)
+	ctrlConn.PlugIn(p.CtrlPort(), 1)
+	
+	for _, name := range names {
+		c := consumer.New(name, engine, 1.0, consumer.WithRegistry(d.CtrlPort(), 0))
+		ctrlConn.PlugIn(c.CtrlPort(), 1)
+		c.TickNow(0)
+	}
+	p.TickNow(0)
+	
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if _, ok := d.Routes().Lookup("Consumer2"); !ok {
+		t.Error("Expected Consumer2 to be registered with the distributor")
+	}
+	if !p.Discovered() || len(p.Consumers()) != 2 {
+		t.Errorf("Expected the producer to discover 2 consumers, got %v", p.Consumers())
+	}
+}
+
+// TestDistributorRejectsUnknownRegistration verifies that a registration for
+// a name without an output port is not added to the routing table
+func TestDistributorRejectsUnknownRegistration(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"})
+	c := consumer.New("Consumer9", engine, 1.0)
+	
+	m := &msg.RegisterMsg{Name: "Consumer9", Ports: c.RxPorts()}
+	m.Meta().Dst = d.CtrlPort()
+	d.CtrlPort().Recv(m)
+	
+	d.Tick(0)
+	
+	if _, ok := d.Routes().Lookup("Consumer9"); ok {
+		t.Error("Expected the registration of Consumer9 to be rejected")
+	}
+}
@@ -0,0 +1,129 @@
+package demo
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ExportRunMetrics writes the metrics of a run to a JSON file, in the form
+// the RPC server returns them, so that runs of different code versions or
+// configs can be compared
+func ExportRunMetrics(path string, m RunMetrics) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ReadRunMetrics reads the metrics a run exported
+func ReadRunMetrics(path string) (RunMetrics, error) {
+	var m RunMetrics
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parsing metrics %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// gatedMetric is a metric the comparison checks for regressions. A metric
+// regresses if it gets worse by more than the threshold, in percent.
+type gatedMetric struct {
+	name          string
+	unit          string
+	lowerIsBetter bool
+	gated         bool // Produced only describes the workload
+	value         func(m RunMetrics) float64
+}
+
+var gatedMetrics = []gatedMetric{
+	{"Produced", "", false, false, func(m RunMetrics) float64 { return float64(m.Produced) }},
+	{"Consumed", "", false, true, func(m RunMetrics) float64 { return float64(m.Consumed) }},
+	{"Expired", "", true, true, func(m RunMetrics) float64 { return float64(m.Expired) }},
+	{"Dead letters", "", true, true, func(m RunMetrics) float64 { return float64(m.DeadLetters) }},
+	{"Mean latency", "s", true, true, func(m RunMetrics) float64 { return m.MeanLatency }},
+	{"p50 latency", "s", true, true, func(m RunMetrics) float64 { return m.P50Latency }},
+	{"p99 latency", "s", true, true, func(m RunMetrics) float64 { return m.P99Latency }},
+	{"Throughput", "msg/s", false, true, func(m RunMetrics) float64 { return m.Throughput }},
+	{"Completion", "s", true, true, func(m RunMetrics) float64 { return m.Completion }},
+}
+
+// MetricDelta is the change of a metric from the baseline to the candidate
+type MetricDelta struct {
+	Metric              string
+	Unit                string
+	Baseline, Candidate float64
+	Regressed           bool
+}
+
+// Change returns the change relative to the baseline in percent, or 0 if
+// the baseline is 0
+func (d MetricDelta) Change() float64 {
+	if d.Baseline == 0 {
+		return 0
+	}
+	return (d.Candidate - d.Baseline) / d.Baseline * 100
+}
+
+// CompareRunMetrics returns the change of every metric and whether it got
+// worse by more than threshold percent. A metric that grows from 0 counts
+// as regressed if that is worse.
+func CompareRunMetrics(baseline, candidate RunMetrics, threshold float64) []MetricDelta {
+	var deltas []MetricDelta
+	for _, m := range gatedMetrics {
+		d := MetricDelta{
+			Metric:    m.name,
+			Unit:      m.unit,
+			Baseline:  m.value(baseline),
+			Candidate: m.value(candidate),
+		}
+		worse := d.Candidate - d.Baseline
+		if !m.lowerIsBetter {
+			worse = -worse
+		}
+		if m.gated && worse > 0 {
+			d.Regressed = d.Baseline == 0 || worse/d.Baseline*100 > threshold
+		}
+		deltas = append(deltas, d)
+	}
+	return deltas
+}
+
+// PrintMetricDeltas writes the change of every metric and the regressions
+func PrintMetricDeltas(out EventSink, baselinePath, candidatePath string, deltas []MetricDelta, threshold float64) {
+	out.Println("=== Metrics Comparison ===")
+	out.Printf("Baseline:          %s\n", baselinePath)
+	out.Printf("Candidate:         %s\n", candidatePath)
+	out.Printf("Threshold:         %.1f%%\n", threshold)
+	out.Println()
+	out.Printf("%-13s %14s %14s %9s  %s\n", "Metric", "Baseline", "Candidate", "Change", "Status")
+	regressions := 0
+	for _, d := range deltas {
+		value := func(v float64) string {
+			if d.Unit == "" {
+				return fmt.Sprintf("%.0f", v)
+			}
+			return fmt.Sprintf("%.3f %s", v, d.Unit)
+		}
+		change := "-"
+		if d.Baseline != 0 {
+			change = fmt.Sprintf("%+.1f%%", d.Change())
+		}
+		status := "ok"
+		if d.Regressed {
+			status = "REGRESSED"
+			regressions++
+		}
+		out.Printf("%-13s %14s %14s %9s  %s\n", d.Metric, value(d.Baseline), value(d.Candidate), change, status)
+	}
+	out.Println()
+	if regressions > 0 {
+		out.Printf("Result:            REGRESSED (%d of %d metrics)\n", regressions, len(deltas))
+	} else {
+		out.Println("Result:            OK")
+	}
+}
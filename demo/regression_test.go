@@ -0,0 +1,34 @@
+package demo
+
+import "testing"
+
+// TestCompareRunMetrics verifies that only the metrics that get worse by
+// more than the threshold regress, in the direction that is worse for each
+func TestCompareRunMetrics(t *testing.T) {
+	baseline := RunMetrics{Produced: 100, Consumed: 100, MeanLatency: 2, P99Latency: 4, Throughput: 1, Completion: 100}
+	candidate := RunMetrics{Produced: 50, Consumed: 96, MeanLatency: 1, P99Latency: 4.4, Throughput: 0.9, Completion: 100, Expired: 1}
+	
+	regressed := make(map[string]bool)
+	for _, d := range CompareRunMetrics(baseline, candidate, 5) {
+		regressed[d.Metric] = d.Regressed
+	}
+	for metric, want := range map[string]bool{
+		"Produced":     false, // Never gated
+		"Consumed":     false, // 4% fewer
+		"Expired":      true,  // From 0
+		"Mean latency": false, // Better
+		"p99 latency":  true,  // 10% higher
+		"Throughput":   true,  // 10% lower
+		"Completion":   false,
+	} {
+		if regressed[metric] != want {
+			t.Errorf("Expected %s to regress: %v, got %v", metric, want, regressed[metric])
+		}
+	}
+	
+	for _, d := range CompareRunMetrics(baseline, candidate, 20) {
+		if d.Regressed && d.Metric != "Expired" {
+			t.Errorf("Expected %s to be within a threshold of 20%%", d.Metric)
+		}
+	}
+}
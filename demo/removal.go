@@ -0,0 +1,106 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Removal is the graceful removal of a consumer. The distributor stops
+// routing to the consumer when the removal starts and redirects the messages
+// still addressed to it to the remaining consumers. The consumer serves the
+// messages it was sent, and its ports are detached once it has drained.
+type Removal struct {
+	Consumer string
+	Started  sim.VTimeInSec
+	Detached sim.VTimeInSec
+	Done     bool // The consumer has drained and its ports are detached
+	Routed   int  // Messages routed to the consumer before the removal
+	Backlog  int  // Messages queued at or on their way to the consumer at the start
+	// Messages addressed to the consumer since the start, by the consumer
+	// they were redirected to
+	Redistributed map[string]int
+}
+
+// DrainTime returns how long the consumer took to drain
+func (r *Removal) DrainTime() sim.VTimeInSec {
+	return r.Detached - r.Started
+}
+
+// TotalRedistributed returns the number of redirected messages
+func (r *Removal) TotalRedistributed() int {
+	total := 0
+	for _, n := range r.Redistributed {
+		total += n
+	}
+	return total
+}
+
+// RemoveConsumer starts the graceful removal of a consumer. Partial batches
+// of the consumer are served without waiting for the rest of the batch.
+func (s *Simulation) RemoveConsumer(now sim.VTimeInSec, name string) (*Removal, error) {
+	c := s.consumer(name)
+	if c == nil {
+		return nil, fmt.Errorf("unknown consumer %q", name)
+	}
+	if c.Removing() {
+		return nil, fmt.Errorf("%s is already being removed", name)
+	}
+	if s.workPool != nil {
+		return nil, fmt.Errorf("consumers cannot leave a work pool during the run")
+	}
+	if s.stealing != nil {
+		return nil, fmt.Errorf("consumers cannot leave the work stealing during the run")
+	}
+
+	routed, redistributed := s.distributor.RemoveDestination(now, name)
+	removal := &Removal{
+		Consumer:      name,
+		Started:       now,
+		Routed:        routed,
+		Redistributed: redistributed,
+	}
+	removal.Backlog = removal.Routed - c.Received() + c.QueueDepth()
+	s.removals = append(s.removals, removal)
+	c.Remove(now, removal.Routed, func(now sim.VTimeInSec) {
+		removal.Detached = now
+		removal.Done = true
+		for _, port := range append(c.RxPorts(), c.CtrlPort()) {
+			s.topology.Unplug(port)
+		}
+	})
+	return removal, nil
+}
+
+// PrintRemovals writes how long every removed consumer took to drain and
+// where the messages still addressed to it went
+func PrintRemovals(out EventSink, removals []*Removal) {
+	out.Println("=== Consumer Removals ===")
+	out.Printf("%-12s %8s %9s %11s %8s %14s\n",
+		"Consumer", "Started", "Detached", "Drain time", "Backlog", "Redistributed")
+	for _, r := range removals {
+		detached, drain := "draining", "-"
+		if r.Done {
+			detached = fmt.Sprintf("%.2f", float64(r.Detached))
+			drain = fmt.Sprintf("%.2f s", float64(r.DrainTime()))
+		}
+		out.Printf("%-12s %8.2f %9s %11s %8d %14d",
+			r.Consumer, float64(r.Started), detached, drain, r.Backlog, r.TotalRedistributed())
+
+		targets := make([]string, 0, len(r.Redistributed))
+		for name := range r.Redistributed {
+			targets = append(targets, name)
+		}
+		sort.Strings(targets)
+		shares := make([]string, len(targets))
+		for i, name := range targets {
+			shares[i] = fmt.Sprintf("%s %d", name, r.Redistributed[name])
+		}
+		if len(shares) > 0 {
+			out.Printf(" (%s)", strings.Join(shares, ", "))
+		}
+		out.Println()
+	}
+}
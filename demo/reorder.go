@@ -0,0 +1,194 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// heldMsg is a message in the buffer. The message itself is released once
+// it is consumed, so only its creation time is kept.
+type heldMsg struct {
+	created sim.VTimeInSec
+	served  sim.VTimeInSec
+}
+
+// ReorderBuffer delivers the messages of every (producer, consumer) pair to
+// the application in the order of their sequence numbers. A message served
+// ahead of a missing one waits in the buffer until the missing message is
+// served, so its reorder wait adds to the latency at which it was served.
+// The buffer gives up on a missing message once the message after it waited
+// Timeout, 0 waits forever. Its methods are safe to call on a nil buffer.
+type ReorderBuffer struct {
+	Timeout sim.VTimeInSec
+	out     EventSink
+
+	next map[Pair]uint64             // Next sequence number to deliver
+	held map[Pair]map[uint64]heldMsg // Messages waiting for a missing one
+
+	Delivered int
+	Waited    int // Delivered messages that waited for a missing one
+	Skipped   int // Missing messages given up on
+	Late      int // Messages served after they were given up on
+	wait      latencySum
+	maxWait   sim.VTimeInSec
+	served    latencySum // End-to-end latency when served
+	delivered latencySum // End-to-end latency when delivered
+}
+
+// NewReorderBuffer creates an empty buffer that gives up on a missing
+// message after timeout
+func NewReorderBuffer(timeout sim.VTimeInSec, out EventSink) *ReorderBuffer {
+	return &ReorderBuffer{
+		Timeout: timeout,
+		out:     out,
+		next:    make(map[Pair]uint64),
+		held:    make(map[Pair]map[uint64]heldMsg),
+	}
+}
+
+// Serve passes a message served by a consumer through the buffer. It is
+// delivered right away unless an earlier message of its pair is missing.
+func (b *ReorderBuffer) Serve(now sim.VTimeInSec, m *msg.DemoMessage) {
+	if b == nil {
+		return
+	}
+	b.Expire(now)
+
+	pair := Pair{Producer: m.Source, Consumer: m.Addressee()}
+	b.served.count++
+	b.served.total += now - m.CreateTime
+	next := b.nextSeq(pair)
+	switch {
+	case m.SeqNum < next:
+		b.Late++
+		b.deliver(heldMsg{created: m.CreateTime, served: now}, now)
+	case m.SeqNum > next:
+		if b.held[pair] == nil {
+			b.held[pair] = make(map[uint64]heldMsg)
+		}
+		b.held[pair][m.SeqNum] = heldMsg{created: m.CreateTime, served: now}
+		b.out.Printf("[%.2f] Reorder: %s #%d waits for #%d\n", now, pair, m.SeqNum, next)
+	default:
+		b.deliver(heldMsg{created: m.CreateTime, served: now}, now)
+		b.next[pair] = next + 1
+		b.release(pair, now)
+	}
+}
+
+// Expire gives up on the missing messages whose successor waited Timeout by
+// now, and delivers the messages held behind them at the time the wait ran
+// out
+func (b *ReorderBuffer) Expire(now sim.VTimeInSec) {
+	if b == nil || b.Timeout <= 0 {
+		return
+	}
+
+	for _, pair := range b.heldPairs() {
+		var at sim.VTimeInSec
+		for len(b.held[pair]) > 0 {
+			first := b.firstHeld(pair)
+			deadline := b.held[pair][first].served + b.Timeout
+			if deadline > now {
+				break
+			}
+			if deadline < at {
+				// Waited behind an earlier missing message that long already
+				deadline = at
+			}
+			next := b.nextSeq(pair)
+			missing := fmt.Sprintf("#%d", next)
+			if first-1 > next {
+				missing += fmt.Sprintf("-#%d", first-1)
+			}
+			b.out.Printf("[%.2f] Reorder: %s gave up on %s at %.2f\n", now, pair, missing, float64(deadline))
+			b.Skipped += int(first - next)
+			b.next[pair] = first
+			b.release(pair, deadline)
+			at = deadline
+		}
+	}
+}
+
+// Held returns the number of messages still waiting for a missing one
+func (b *ReorderBuffer) Held() int {
+	n := 0
+	for _, held := range b.held {
+		n += len(held)
+	}
+	return n
+}
+
+func (b *ReorderBuffer) nextSeq(pair Pair) uint64 {
+	if next, ok := b.next[pair]; ok {
+		return next
+	}
+	return 1
+}
+
+// release delivers the held messages of a pair that are next in order
+func (b *ReorderBuffer) release(pair Pair, now sim.VTimeInSec) {
+	for {
+		next := b.nextSeq(pair)
+		h, ok := b.held[pair][next]
+		if !ok {
+			return
+		}
+		delete(b.held[pair], next)
+		b.deliver(h, now)
+		b.next[pair] = next + 1
+	}
+}
+
+func (b *ReorderBuffer) deliver(h heldMsg, now sim.VTimeInSec) {
+	b.Delivered++
+	b.delivered.count++
+	b.delivered.total += now - h.created
+	if wait := now - h.served; wait > 0 {
+		b.Waited++
+		b.wait.count++
+		b.wait.total += wait
+		if wait > b.maxWait {
+			b.maxWait = wait
+		}
+	}
+}
+
+func (b *ReorderBuffer) firstHeld(pair Pair) uint64 {
+	first := uint64(0)
+	for seq := range b.held[pair] {
+		if first == 0 || seq < first {
+			first = seq
+		}
+	}
+	return first
+}
+
+func (b *ReorderBuffer) heldPairs() []Pair {
+	pairs := make([]Pair, 0, len(b.held))
+	for pair, held := range b.held {
+		if len(held) > 0 {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].String() < pairs[j].String() })
+	return pairs
+}
+
+// Print writes how long messages waited in the buffer, separately from the
+// latency at which they were served. Missing messages whose wait ran out
+// by the end of the run are given up on first.
+func (b *ReorderBuffer) Print(end sim.VTimeInSec) {
+	b.Expire(end)
+	b.out.Println("=== Reorder Buffer ===")
+	b.out.Printf("Delivered:         %d\n", b.Delivered)
+	b.out.Printf("Waited:            %d\n", b.Waited)
+	b.out.Printf("Mean reorder wait: %.2f s\n", b.wait.mean())
+	b.out.Printf("Max reorder wait:  %.2f s\n", float64(b.maxWait))
+	b.out.Printf("Mean latency:      %.2f s served, %.2f s delivered\n", b.served.mean(), b.delivered.mean())
+	b.out.Printf("Given up on:       %d\n", b.Skipped)
+	b.out.Printf("Late:              %d\n", b.Late)
+	b.out.Printf("Still held:        %d\n", b.Held())
+}
@@ -0,0 +1,48 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestReorderBufferHoldsUntilGapFills verifies that a message served ahead
+// of a missing one waits until the missing message is served
+func TestReorderBufferHoldsUntilGapFills(t *testing.T) {
+	b := NewReorderBuffer(0, NullSink{})
+	b.Serve(1, seqMsg(1))
+	b.Serve(2, seqMsg(3))
+	if b.Delivered != 1 || b.Held() != 1 {
+		t.Fatalf("Expected #3 to wait for #2, got %d delivered and %d held", b.Delivered, b.Held())
+	}
+	
+	b.Serve(5, seqMsg(2))
+	if b.Delivered != 3 || b.Held() != 0 {
+		t.Errorf("Expected all messages delivered, got %d delivered and %d held", b.Delivered, b.Held())
+	}
+	if b.Waited != 1 || b.wait.mean() != 3 {
+		t.Errorf("Expected #3 to wait 3 s, got %d messages waiting %.2f s", b.Waited, b.wait.mean())
+	}
+}
+
+// TestReorderBufferGivesUpOnMissingMessages verifies that the messages held
+// behind a missing one are delivered when the timeout runs out, and that the
+// missing message is still delivered if it is served later
+func TestReorderBufferGivesUpOnMissingMessages(t *testing.T) {
+	b := NewReorderBuffer(2, NullSink{})
+	b.Serve(1, seqMsg(2))
+	b.Serve(2, seqMsg(3))
+	
+	b.Expire(10)
+	if b.Skipped != 1 || b.Delivered != 2 {
+		t.Fatalf("Expected to give up on #1 and deliver 2 messages, got %d and %d", b.Skipped, b.Delivered)
+	}
+	// Both are delivered when #2 waited 2 s, at 3
+	if b.maxWait != 2 || b.wait.mean() != 1.5 {
+		t.Errorf("Expected waits of 2 s and 1 s, got a max of %.2f s and a mean of %.2f s",
+			float64(b.maxWait), b.wait.mean())
+	}
+	
+	b.Serve(11, seqMsg(1))
+	if b.Late != 1 || b.Delivered != 3 {
+		t.Errorf("Expected #1 to be delivered late, got %d late and %d delivered", b.Late, b.Delivered)
+	}
+}
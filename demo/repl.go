@@ -0,0 +1,166 @@
+package demo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// ErrQuit ends an interactive run before the engine finished
+var ErrQuit = errors.New("quit")
+
+const replHelp = `Commands:
+  step [n]          handle the next n events (default 1)
+  until <time>      run until the first event at or after the virtual time
+  continue          run until a breakpoint or the end of the run
+  break <consumer>  stop after a message is routed to the consumer
+  breaks            list the breakpoints
+  clear             remove all breakpoints
+  ports <component> show the messages queued in the ports of a component
+  status            show the time, the handled events, and the next event
+  quit              end the program without finishing the run
+At the end of the input, the run continues to its end without breakpoints.`
+
+// RunInteractive runs the simulation event by event under a REPL that reads
+// commands from in and answers on w. The engine starts paused before its
+// first event and runs on its own goroutine; the REPL returns once the run
+// finished, or ErrQuit if the user quit.
+func (s *Simulation) RunInteractive(in io.Reader, w io.Writer) error {
+	c := s.newController(true)
+	done := make(chan error, 1)
+	go func() { done <- s.Run() }()
+
+	printStatus(w, c.Pause())
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(w, "(akita) ")
+		if !scanner.Scan() {
+			fmt.Fprintln(w)
+			c.ClearBreaks()
+			printStatus(w, c.Continue())
+			break
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "q" {
+			return ErrQuit
+		}
+		status, err := s.replCommand(c, fields, w)
+		if err != nil {
+			fmt.Fprintf(w, "Error: %v\n", err)
+			continue
+		}
+		if status != nil {
+			printStatus(w, *status)
+			if status.Finished {
+				break
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return <-done
+}
+
+// replCommand executes a command and returns the status of the engine if the
+// command moved it
+func (s *Simulation) replCommand(c *Controller, fields []string, w io.Writer) (*ControlStatus, error) {
+	var status ControlStatus
+	switch fields[0] {
+	case "step", "s":
+		n := 1
+		if len(fields) > 1 {
+			var err error
+			n, err = strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid number of events %q", fields[1])
+			}
+		}
+		status = c.Step(n)
+	case "until", "u":
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("usage: until <time>")
+		}
+		t, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q", fields[1])
+		}
+		status = c.RunUntil(sim.VTimeInSec(t))
+	case "continue", "c":
+		status = c.Continue()
+	case "status":
+		status = c.Pause()
+	case "break", "b":
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("usage: break <consumer>")
+		}
+		if s.distributor.OutputPort(fields[1]) == nil {
+			return nil, fmt.Errorf("unknown consumer %q", fields[1])
+		}
+		c.Break(fields[1])
+		fmt.Fprintf(w, "Breaks when a message is routed to %s\n", fields[1])
+		return nil, nil
+	case "breaks":
+		for _, name := range c.Breakpoints() {
+			fmt.Fprintf(w, "  routed to %s\n", name)
+		}
+		return nil, nil
+	case "clear":
+		c.ClearBreaks()
+		return nil, nil
+	case "ports", "p":
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("usage: ports <component>")
+		}
+		return nil, s.printPorts(c, fields[1], w)
+	case "help", "h":
+		fmt.Fprintln(w, replHelp)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown command %q, try help", fields[0])
+	}
+	return &status, nil
+}
+
+// printPorts writes the messages queued in every port of a component,
+// oldest first. The engine is paused, so the ports can be read safely.
+func (s *Simulation) printPorts(c *Controller, component string, w io.Writer) error {
+	_, ports := s.topology.portsByComponent()
+	if len(ports[component]) == 0 {
+		return fmt.Errorf("unknown component %q", component)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, port := range ports[component] {
+		msgs := c.contents[port]
+		fmt.Fprintf(w, "%s: %d queued\n", port.Name(), len(msgs))
+		for _, msg := range msgs {
+			fmt.Fprintf(w, "  %s\n", describeMsg(msg))
+		}
+	}
+	return nil
+}
+
+func printStatus(w io.Writer, status ControlStatus) {
+	switch {
+	case status.Finished:
+		fmt.Fprintf(w, "Run finished at %.2f after %d events\n", status.Time, status.Events)
+	case status.Breakpoint != "":
+		fmt.Fprintf(w, "Stopped at %.2f after %d events: %s\n  next: %s\n",
+			status.Time, status.Events, status.Breakpoint, status.NextEvent)
+	default:
+		fmt.Fprintf(w, "Stopped at %.2f after %d events\n  next: %s\n",
+			status.Time, status.Events, status.NextEvent)
+	}
+}
@@ -0,0 +1,58 @@
+package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRunInteractiveStopsAtBreakpoint verifies that the REPL stops the run
+// when a message is routed to a watched consumer, and that the run finishes
+// once the commands run out
+func TestRunInteractiveStopsAtBreakpoint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	script := "break Consumer2\ncontinue\nports Distributor\nstep 2\nclear\nuntil 50\n"
+	var w strings.Builder
+	if err := simulation.RunInteractive(strings.NewReader(script), &w); err != nil {
+		t.Fatal(err)
+	}
+	
+	output := w.String()
+	for _, want := range []string{
+		"routed to Consumer2\n",
+		"Distributor.In: ",
+		"reached 50.00\n",
+		"Run finished at ",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in the output, got:\n%s", want, output)
+		}
+	}
+	if !simulation.Conservation().Holds() {
+		t.Error("Expected every message to be accounted for after the run")
+	}
+}
+	
+// TestRunInteractiveQuits verifies that quit ends the REPL before the run
+// finished
+func TestRunInteractiveQuits(t *testing.T) {
+	simulation, err := NewSimulation(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	var w strings.Builder
+	err = simulation.RunInteractive(strings.NewReader("step\nquit\n"), &w)
+	if err != ErrQuit {
+		t.Fatalf("Expected ErrQuit, got %v", err)
+	}
+	if !strings.Contains(w.String(), "Stopped at 0.00 after 1 events") {
+		t.Errorf("Expected the run to stop after one event, got:\n%s", w.String())
+	}
+}
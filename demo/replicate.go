@@ -0,0 +1,121 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Replication is the outcome of one independent run of a Monte Carlo
+// experiment
+type Replication struct {
+	Seed        int64
+	Consumed    int
+	MeanLatency float64
+	P99Latency  float64
+	Throughput  float64
+}
+
+// Estimate is the mean of a metric over the replications, the standard
+// deviation of the runs, and the half-width of the 95% confidence interval
+// of the mean
+type Estimate struct {
+	Mean, StdDev, HalfWidth float64
+}
+
+// tQuantiles are the 97.5% quantiles of Student's t-distribution with 1 to
+// 30 degrees of freedom
+var tQuantiles = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tQuantile returns the 97.5% quantile of the t-distribution with df degrees
+// of freedom, approximated by the normal distribution beyond 30
+func tQuantile(df int) float64 {
+	if df <= len(tQuantiles) {
+		return tQuantiles[df-1]
+	}
+	return 1.96
+}
+
+// estimate returns the mean of values with its 95% confidence interval,
+// which takes at least two values
+func estimate(values []float64) Estimate {
+	e := Estimate{Mean: mean(values)}
+	n := len(values)
+	if n < 2 {
+		return e
+	}
+	var sum float64
+	for _, v := range values {
+		sum += (v - e.Mean) * (v - e.Mean)
+	}
+	e.StdDev = math.Sqrt(sum / float64(n-1))
+	e.HalfWidth = tQuantile(n-1) * e.StdDev / math.Sqrt(float64(n))
+	return e
+}
+
+// RunReplications runs the simulation Runs times, each on an engine of its
+// own. With a fixed seed, the runs use consecutive seeds; without one,
+// every run seeds itself from the current time.
+func RunReplications(cfg *Config) ([]Replication, error) {
+	var replications []Replication
+	for i := 0; i < cfg.Runs; i++ {
+		runCfg := *cfg
+		if cfg.Seed != 0 {
+			runCfg.Seed = cfg.Seed + int64(i)
+		}
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		simulation.out.Printf("=== Scenario Run: replication %d ===\n", i+1)
+		if err := simulation.Run(); err != nil {
+			return nil, err
+		}
+		simulation.out.Println()
+
+		stats := simulation.stats
+		replications = append(replications, Replication{
+			Seed:        simulation.producers[0].Seed(),
+			Consumed:    stats.Consumed,
+			MeanLatency: stats.MeanLatency(),
+			P99Latency:  stats.LatencyPercentile(99),
+			Throughput:  stats.Throughput(simulation.Duration()),
+		})
+	}
+	return replications, nil
+}
+
+// PrintReplications writes the metrics of every run and their means over
+// the runs with 95% confidence intervals
+func PrintReplications(out EventSink, replications []Replication) {
+	out.Println("=== Monte Carlo Runs ===")
+	out.Printf("%-4s %20s %9s %13s %12s %12s\n", "Run", "Seed", "Consumed", "Mean latency", "p99 latency", "Throughput")
+	var means, p99s, throughputs []float64
+	for i, r := range replications {
+		out.Printf("%-4d %20d %9d %11.2f s %10.2f s %6.3f msg/s\n",
+			i+1, r.Seed, r.Consumed, r.MeanLatency, r.P99Latency, r.Throughput)
+		means = append(means, r.MeanLatency)
+		p99s = append(p99s, r.P99Latency)
+		throughputs = append(throughputs, r.Throughput)
+	}
+	out.Println()
+	out.Printf("%-13s %12s %12s  %s\n", "Metric", "Mean", "Std. dev.", "95% confidence interval")
+	printEstimate(out, "Mean latency", "s", "%.2f", estimate(means))
+	printEstimate(out, "p99 latency", "s", "%.2f", estimate(p99s))
+	printEstimate(out, "Throughput", "msg/s", "%.3f", estimate(throughputs))
+}
+
+// printEstimate writes a line of the estimates of the runs
+func printEstimate(out EventSink, metric, unit, format string, e Estimate) {
+	value := func(v float64) string { return fmt.Sprintf(format+" %s", v, unit) }
+	interval := fmt.Sprintf("%s to %s", value(e.Mean-e.HalfWidth), value(e.Mean+e.HalfWidth))
+	if e.Mean != 0 {
+		interval += fmt.Sprintf(" (±%.1f%%)", e.HalfWidth/math.Abs(e.Mean)*100)
+	}
+	out.Printf("%-13s %12s %12s  %s\n", metric, value(e.Mean), value(e.StdDev), interval)
+}
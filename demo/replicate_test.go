@@ -0,0 +1,70 @@
+package demo
+
+import (
+	"math"
+	"testing"
+)
+
+// TestEstimate verifies the mean and the 95% confidence interval of a few
+// replications
+func TestEstimate(t *testing.T) {
+	e := estimate([]float64{1, 2, 3})
+	if e.Mean != 2 || e.StdDev != 1 || math.Abs(e.HalfWidth-4.303/math.Sqrt(3)) > 1e-9 {
+		t.Errorf("Expected 2 ± %.3f, got %+v", 4.303/math.Sqrt(3), e)
+	}
+	if e := estimate([]float64{5}); e.Mean != 5 || e.HalfWidth != 0 {
+		t.Errorf("Expected a single run without an interval, got %+v", e)
+	}
+	if tQuantile(100) != 1.96 {
+		t.Errorf("Expected the normal quantile beyond 30 degrees of freedom")
+	}
+}
+
+// TestRunReplications verifies that every replication runs on its own seed
+// and engine
+func TestRunReplications(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 7
+	cfg.Cycles = 200
+	cfg.Runs = 3
+	cfg.ServiceDist = "exponential"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	replications, err := RunReplications(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(replications) != 3 {
+		t.Fatalf("Expected 3 replications, got %d", len(replications))
+	}
+	for i, r := range replications {
+		if r.Seed != 7+int64(i) || r.Consumed == 0 || r.Throughput == 0 {
+			t.Errorf("Expected run %d with seed %d to consume messages, got %+v", i+1, 7+i, r)
+		}
+	}
+	if replications[0] == replications[1] && replications[1] == replications[2] {
+		t.Errorf("Expected independent replications, got %+v", replications)
+	}
+	
+	single := *cfg
+	single.Runs = 1
+	simulation, err := NewSimulation(&single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if simulation.stats.MeanLatency() != replications[0].MeanLatency {
+		t.Errorf("Expected the first replication to match a single run, got %.2f s and %.2f s",
+			replications[0].MeanLatency, simulation.stats.MeanLatency())
+	}
+	
+	cfg.Scenario = "seed-sweep"
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Expected replications of a scenario to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package demo
+
+import (
+	"fmt"
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+type retainedMsg struct {
+	msg  *msg.DemoMessage
+	time sim.VTimeInSec
+}
+
+// Retention is a bounded buffer at the distributor that keeps messages for
+// destinations without a registered consumer. When a consumer registers
+// slightly late, the messages retained for it within the retention window
+// are delivered instead of being lost.
+type Retention struct {
+	capacity int
+	window   sim.VTimeInSec
+	entries  []retainedMsg
+
+	Hits    int // Retained messages delivered to a late consumer
+	Misses  int // Retained messages evicted or expired before their consumer registered
+	Evicted int // Misses caused by the buffer being full
+}
+
+// NewRetention creates a retention buffer holding at most capacity messages
+// for at most window seconds
+func NewRetention(capacity int, window sim.VTimeInSec) *Retention {
+	return &Retention{
+		capacity: capacity,
+		window:   window,
+	}
+}
+
+// Retain keeps a message, evicting the oldest message if the buffer is full.
+// It returns the evicted message, or nil.
+func (r *Retention) Retain(now sim.VTimeInSec, m *msg.DemoMessage) *msg.DemoMessage {
+	r.expire(now)
+
+	var evicted *msg.DemoMessage
+	if len(r.entries) >= r.capacity {
+		evicted = r.entries[0].msg
+		r.entries = r.entries[1:]
+		r.Misses++
+		r.Evicted++
+	}
+
+	r.entries = append(r.entries, retainedMsg{msg: m, time: now})
+	return evicted
+}
+
+// Claim removes and returns the retained messages of a destination that are
+// still within the retention window, oldest first
+func (r *Retention) Claim(now sim.VTimeInSec, dest string) []*msg.DemoMessage {
+	r.expire(now)
+
+	var claimed []*msg.DemoMessage
+	kept := r.entries[:0]
+	for _, e := range r.entries {
+		if e.msg.Destination == dest {
+			claimed = append(claimed, e.msg)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	r.entries = kept
+	r.Hits += len(claimed)
+
+	return claimed
+}
+
+// Len returns the number of messages currently retained
+func (r *Retention) Len() int {
+	return len(r.entries)
+}
+
+// String describes how many messages are retained for how long
+func (r *Retention) String() string {
+	return fmt.Sprintf("%d messages for %.2f s", r.capacity, float64(r.window))
+}
+
+func (r *Retention) expire(now sim.VTimeInSec) {
+	for len(r.entries) > 0 && now-r.entries[0].time > r.window {
+		r.entries = r.entries[1:]
+		r.Misses++
+	}
+}
+
+// Print writes the retention hit and miss statistics
+func (r *Retention) Print(out EventSink) {
+	out.Println("=== Retention ===")
+	out.Printf("Retention hits:    %d\n", r.Hits)
+	out.Printf("Retention misses:  %d (%d evicted, %d expired)\n",
+		r.Misses, r.Evicted, r.Misses-r.Evicted)
+	out.Printf("Still retained:    %d\n", r.Len())
+}
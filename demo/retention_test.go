@@ -0,0 +1,81 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestRetentionClaimWithinWindow verifies that retained messages are handed
+// to a late consumer and counted as hits, while expired ones are misses
+func TestRetentionClaimWithinWindow(t *testing.T) {
+	r := NewRetention(10, 5)
+	r.Retain(0, &msg.DemoMessage{ID: 1, Destination: "Consumer1"})
+	r.Retain(4, &msg.DemoMessage{ID: 2, Destination: "Consumer1"})
+	r.Retain(4, &msg.DemoMessage{ID: 3, Destination: "Consumer2"})
+	
+	claimed := r.Claim(7, "Consumer1")
+	
+	if len(claimed) != 1 || claimed[0].ID != 2 {
+		t.Fatalf("Expected to claim message 2, got %v", claimed)
+	}
+	if r.Hits != 1 || r.Misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %d hits and %d misses", r.Hits, r.Misses)
+	}
+	if r.Len() != 1 {
+		t.Errorf("Expected the message of Consumer2 to stay retained, got %d", r.Len())
+	}
+}
+
+// TestRetentionEvictsOldestWhenFull verifies that the buffer stays bounded
+func TestRetentionEvictsOldestWhenFull(t *testing.T) {
+	r := NewRetention(2, 100)
+	for i := uint64(1); i <= 3; i++ {
+		r.Retain(sim.VTimeInSec(i), &msg.DemoMessage{ID: i, Destination: "Consumer1"})
+	}
+	
+	claimed := r.Claim(4, "Consumer1")
+	
+	if len(claimed) != 2 || claimed[0].ID != 2 {
+		t.Fatalf("Expected messages 2 and 3 to be retained, got %v", claimed)
+	}
+	if r.Evicted != 1 || r.Misses != 1 {
+		t.Errorf("Expected 1 eviction, got %d evictions and %d misses", r.Evicted, r.Misses)
+	}
+}
+
+// TestLateConsumerReceivesRetainedMessages verifies that a message sent
+// before its consumer registered is delivered once the consumer registers
+func TestLateConsumerReceivesRetainedMessages(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	retention := NewRetention(10, 10)
+	d := distributor.New("Distributor", engine, []string{"Consumer1"}, distributor.WithRetention(retention))
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithStats(stats), consumer.WithRegistry(d.CtrlPort(), 3))
+	
+	dataConn := sim.NewDirectConnection("DistributorToConsumer1", engine, 1*sim.Hz)
+	dataConn.PlugIn(d.OutputPort("Consumer1"), 1)
+	dataConn.PlugIn(c.InputPort(), 1)
+	ctrlConn := sim.NewDirectConnection("ControlPlane", engine, 1*sim.Hz)
+	ctrlConn.PlugIn(d.CtrlPort(), 1)
+	ctrlConn.PlugIn(c.CtrlPort(), 1)
+	
+	m := &msg.DemoMessage{ID: 1, Destination: "Consumer1", CreateTime: 0}
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
+	c.TickNow(0)
+	
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if retention.Hits != 1 {
+		t.Errorf("Expected 1 retention hit, got %d", retention.Hits)
+	}
+	if stats.Consumed != 1 {
+		t.Errorf("Expected the late consumer to consume 1 message, got %d", stats.Consumed)
+	}
+}
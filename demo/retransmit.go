@@ -0,0 +1,212 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// unacked is a message the producer waits for the ACK of, kept to send it
+// again. The Retransmits of the copy count the retransmissions so far.
+type unacked struct {
+	msg      *msg.DemoMessage   // Copy of the message as first sent
+	deadline sim.VTimeInSec // Time the ACK is overdue
+}
+
+// Retransmitter makes the producers send a message again when its ACK is
+// Timeout overdue, waiting twice as long after every retransmission, until
+// they give up after Limit of them. Consumers discard the messages whose ID
+// they consumed already, and ACK them again. Producers and consumers share
+// it, and its methods are safe to call on a nil retransmitter.
+type Retransmitter struct {
+	Timeout       sim.VTimeInSec
+	Limit         int
+	Retransmitted int // Messages sent again
+	Recovered     int // Messages acknowledged after a retransmission
+	GaveUp        int // Messages the producers stopped retransmitting
+
+	mu         sync.Mutex // Producers and consumers run on the goroutines of the parallel engine
+	pending    map[uint64]*unacked
+	consumed   map[string]map[uint64]bool // IDs of the messages every consumer consumed
+	duplicates map[string]int             // Duplicates every consumer discarded
+}
+
+// NewRetransmitter creates a retransmitter that first waits timeout for an
+// ACK and sends a message at most limit times again
+func NewRetransmitter(timeout sim.VTimeInSec, limit int) *Retransmitter {
+	return &Retransmitter{
+		Timeout:    timeout,
+		Limit:      limit,
+		pending:    make(map[uint64]*unacked),
+		consumed:   make(map[string]map[uint64]bool),
+		duplicates: make(map[string]int),
+	}
+}
+
+// String describes when the producers send a message again
+func (r *Retransmitter) String() string {
+	return fmt.Sprintf("after %.2f s without an ACK, doubling, up to %d times", float64(r.Timeout), r.Limit)
+}
+
+// Sent starts waiting for the ACK of a message sent for the first time, and
+// returns the time it is overdue
+func (r *Retransmitter) Sent(now sim.VTimeInSec, m *msg.DemoMessage) sim.VTimeInSec {
+	if r == nil {
+		return now
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := &unacked{msg: m.Clone(), deadline: now + r.Timeout}
+	r.pending[m.ID] = u
+	return u.deadline
+}
+
+// Acked stops waiting for the ACK of a message
+func (r *Retransmitter) Acked(id uint64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.pending[id]; ok {
+		if u.msg.Retransmits > 0 {
+			r.Recovered++
+		}
+		delete(r.pending, id)
+	}
+}
+
+// Overdue returns the copies of the messages of a producer whose ACK is
+// overdue at now, by ID
+func (r *Retransmitter) Overdue(now sim.VTimeInSec, producer string) []*msg.DemoMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []*msg.DemoMessage
+	for _, u := range r.pending {
+		if u.msg.Source == producer && now >= u.deadline-component.ClockTolerance {
+			due = append(due, u.msg)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+// Exhausted reports whether an overdue message was sent Limit times again
+func (r *Retransmitter) Exhausted(m *msg.DemoMessage) bool {
+	return m.Retransmits == r.Limit
+}
+
+// Resent waits for the ACK of a message sent again, twice as long as before,
+// and returns the time it is overdue
+func (r *Retransmitter) Resent(now sim.VTimeInSec, id uint64) sim.VTimeInSec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := r.pending[id]
+	u.msg.Retransmits++
+	u.deadline = now + r.Timeout*sim.VTimeInSec(int(1)<<u.msg.Retransmits)
+	r.Retransmitted++
+	return u.deadline
+}
+
+// Forget stops waiting for a message, counting it as given up on if the
+// producer still waited for its ACK
+func (r *Retransmitter) Forget(id uint64, gaveUp bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, id)
+	if gaveUp {
+		r.GaveUp++
+	}
+}
+
+// Consumed records the ID of a message a consumer consumed
+func (r *Retransmitter) Consumed(consumer string, id uint64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.consumed[consumer] == nil {
+		r.consumed[consumer] = make(map[uint64]bool)
+	}
+	r.consumed[consumer][id] = true
+}
+
+// Duplicate reports whether a consumer consumed a message of the ID before
+func (r *Retransmitter) Duplicate(consumer string, id uint64) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.consumed[consumer][id]
+}
+
+// Discarded counts a duplicate a consumer discarded
+func (r *Retransmitter) Discarded(consumer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.duplicates[consumer]++
+}
+
+// TotalRetransmitted returns the messages the producers sent again
+func (r *Retransmitter) TotalRetransmitted() int {
+	if r == nil {
+		return 0
+	}
+	return r.Retransmitted
+}
+
+// TotalDuplicates returns the duplicates the consumers discarded
+func (r *Retransmitter) TotalDuplicates() int {
+	if r == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range r.duplicates {
+		total += n
+	}
+	return total
+}
+
+// Print writes the retransmissions of the producers and the duplicates the
+// consumers discarded
+func (r *Retransmitter) Print(out EventSink) {
+	out.Println("=== Retransmission ===")
+	out.Printf("ACK timeout:       %.2f s, doubling up to %d retransmissions\n", float64(r.Timeout), r.Limit)
+	out.Printf("Retransmitted:     %d messages\n", r.Retransmitted)
+	out.Printf("Recovered:         %d (acknowledged after a retransmission)\n", r.Recovered)
+	out.Printf("Gave up:           %d (no ACK after %d retransmissions)\n", r.GaveUp, r.Limit)
+	out.Printf("Duplicates:        %d (discarded by consumers)\n", r.TotalDuplicates())
+	consumers := make([]string, 0, len(r.duplicates))
+	for name := range r.duplicates {
+		consumers = append(consumers, name)
+	}
+	sort.Strings(consumers)
+	for _, name := range consumers {
+		out.Printf("  %-16s %d\n", name, r.duplicates[name])
+	}
+}
+
+// validateRetransmission checks the retransmission options, and that every
+// message is acknowledged once and by its consumer
+func (c *Config) validateRetransmission() error {
+	if c.RetransmitTimeout < 0 {
+		return fmt.Errorf("retransmit-timeout must not be negative")
+	}
+	if c.RetransmitLimit < 0 {
+		return fmt.Errorf("retransmit-limit must not be negative")
+	}
+	if c.RetransmitTimeout == 0 {
+		return nil
+	}
+	if c.Multicast > 0 || len(c.Topics) > 0 {
+		// The first of several ACKs would stop the retransmissions
+		return fmt.Errorf("retransmission cannot be combined with multicast or topics")
+	}
+	return nil
+}
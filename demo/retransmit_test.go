@@ -0,0 +1,113 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestRetransmitterBacksOff verifies that the wait for an ACK doubles with
+// every retransmission and that an ACK ends it
+func TestRetransmitterBacksOff(t *testing.T) {
+	r := NewRetransmitter(2, 3)
+	r.Sent(10, &msg.DemoMessage{ID: 1, Source: "Producer"})
+	r.Sent(11, &msg.DemoMessage{ID: 2, Source: "Producer"})
+	
+	if due := r.Overdue(11, "Producer"); len(due) != 0 {
+		t.Errorf("Expected no ACK overdue before the timeout, got %d", len(due))
+	}
+	due := r.Overdue(12, "Producer")
+	if len(due) != 1 || due[0].ID != 1 {
+		t.Fatalf("Expected message 1 overdue at 12, got %d messages", len(due))
+	}
+	deadline := sim.VTimeInSec(12)
+	for i, want := range []float64{16, 24, 40} {
+		deadline = r.Resent(deadline, 1)
+		if float64(deadline) != want || due[0].Retransmits != i+1 {
+			t.Errorf("Expected retry %d to wait until %.0f, got %.0f", i+1, want, float64(deadline))
+		}
+	}
+	if !r.Exhausted(due[0]) {
+		t.Errorf("Expected message 1 to be sent again as often as allowed")
+	}
+	if r.Overdue(20, "Other") != nil {
+		t.Errorf("Expected no overdue ACK of another producer")
+	}
+	
+	r.Acked(1)
+	r.Acked(2)
+	if r.Retransmitted != 3 || r.Recovered != 1 || len(r.pending) != 0 {
+		t.Errorf("Expected 3 retransmissions of a recovered message, got %+v", r)
+	}
+}
+
+// TestRetransmissionRecoversLosses verifies that the messages lost on the
+// connections are sent again until they are consumed, and that consumers
+// discard the copies of messages they consumed before
+func TestRetransmissionRecoversLosses(t *testing.T) {
+	run := func(timeout float64) *Simulation {
+		cfg := DefaultConfig()
+		cfg.Seed = 1
+		cfg.Cycles = 200
+		cfg.FaultLoss = 0.1
+		cfg.RetransmitTimeout = timeout
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		silence(t, cfg)
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if c := simulation.Conservation(); !c.Holds() {
+			t.Errorf("Expected every message to be accounted for, got %+v", c)
+		}
+		return simulation
+	}
+	
+	lossy := run(0)
+	if lossy.stats.Consumed >= lossy.stats.Produced {
+		t.Fatalf("Expected losses without retransmission, got %d of %d consumed", lossy.stats.Consumed, lossy.stats.Produced)
+	}
+	
+	recovered := run(4)
+	r := recovered.retransmitter
+	if r.Retransmitted == 0 || r.GaveUp != 0 || recovered.stats.Consumed != recovered.stats.Produced {
+		t.Errorf("Expected every lost message to be sent again and consumed, got %d of %d consumed after %d retransmissions",
+			recovered.stats.Consumed, recovered.stats.Produced, r.Retransmitted)
+	}
+	
+	// ACKs take 2 s, so a shorter timeout sends copies of messages that
+	// arrive, which their consumers discard
+	eager := run(1)
+	if eager.retransmitter.TotalDuplicates() == 0 || eager.verifier.Duplicates != 0 {
+		t.Errorf("Expected the consumers to discard the duplicates, got %d discarded and %d consumed",
+			eager.retransmitter.TotalDuplicates(), eager.verifier.Duplicates)
+	}
+	if eager.stats.Consumed > eager.stats.Produced {
+		t.Errorf("Expected no message consumed twice, got %d of %d", eager.stats.Consumed, eager.stats.Produced)
+	}
+}
+
+// TestRetransmissionValidation verifies the retransmission options accepted
+func TestRetransmissionValidation(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		apply func(cfg *Config)
+	}{
+		{"negative timeout", func(cfg *Config) { cfg.RetransmitTimeout = -1 }},
+		{"negative limit", func(cfg *Config) { cfg.RetransmitLimit = -1 }},
+		{"multicast", func(cfg *Config) { cfg.Multicast = 0.5 }},
+	} {
+		cfg := DefaultConfig()
+		cfg.RetransmitTimeout = 2
+		c.apply(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected %s to be rejected", c.name)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestDistributorRoutesByName verifies that the distributor forwards a
+// message to the port registered for its destination name
+func TestDistributorRoutesByName(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"})
+	stats := NewStats()
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithStats(stats))
+	
+	conn := sim.NewDirectConnection("TestConnection", engine, 1*sim.Hz)
+	conn.PlugIn(d.OutputPort("Consumer1"), 1)
+	conn.PlugIn(c.InputPort(), 1)
+	d.Routes().Add("Consumer1", c.InputPort())
+	
+	m := &msg.DemoMessage{Content: "Test message", Destination: "Consumer1"}
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
+	
+	d.Tick(0)
+	engine.Run()
+	
+	if stats.Consumed != 1 {
+		t.Errorf("Expected Consumer1 to consume 1 message, got %d", stats.Consumed)
+	}
+}
+
+// TestDistributorDropsMessageWithoutRoute verifies that a message to a
+// destination without a route is consumed and discarded
+func TestDistributorDropsMessageWithoutRoute(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"})
+	
+	m := &msg.DemoMessage{Content: "Test message", Destination: "Consumer1"}
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
+	
+	result := d.Tick(0)
+	
+	if result != false || d.InputPort().Peek() != nil {
+		t.Errorf("Expected the unroutable message to be discarded, got tick result %v", result)
+	}
+}
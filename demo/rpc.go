@@ -0,0 +1,314 @@
+package demo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcRunError       = -32000 // The request is valid but cannot be carried out
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// RunMetrics are the metrics of a finished run, as returned to RPC clients
+type RunMetrics struct {
+	Duration    float64   `json:"duration"`
+	Produced    int       `json:"produced"`
+	Routed      int       `json:"routed"`
+	Consumed    int       `json:"consumed"`
+	Acked       int       `json:"acked"`
+	Expired     int       `json:"expired"`
+	DeadLetters int       `json:"dead_letters"`
+	Throughput  float64   `json:"throughput"` // Messages consumed per second
+	MeanLatency float64   `json:"mean_latency"`
+	P50Latency  float64   `json:"p50_latency"`
+	P99Latency  float64   `json:"p99_latency"`
+	Completion  float64   `json:"completion"` // Time the last message was consumed
+	Conserved   bool      `json:"conserved"`  // Every produced message is accounted for
+	Latencies   []float64 `json:"latencies"`  // End-to-end latency of every consumed message
+	// Messages consumed by every consumer
+	PerConsumer map[string]int `json:"per_consumer"`
+}
+
+// Metrics collects the metrics of the run once it has ended
+func (s *Simulation) Metrics() RunMetrics {
+	duration := s.Duration()
+	m := RunMetrics{
+		Duration:    float64(duration),
+		Produced:    s.stats.Produced,
+		Routed:      s.stats.Routed,
+		Consumed:    s.stats.Consumed,
+		Acked:       s.stats.Acked,
+		Expired:     s.stats.Expired,
+		DeadLetters: s.deadLetters.Total,
+		Throughput:  s.stats.Throughput(duration),
+		MeanLatency: s.stats.MeanLatency(),
+		P50Latency:  s.stats.LatencyPercentile(50),
+		P99Latency:  s.stats.LatencyPercentile(99),
+		Completion:  float64(s.Completion()),
+		Conserved:   s.Conservation().Holds(),
+		Latencies:   append([]float64{}, s.stats.latencies...),
+		PerConsumer: make(map[string]int),
+	}
+	for _, c := range s.consumers {
+		m.PerConsumer[c.Name()] += c.TotalConsumed()
+	}
+	return m
+}
+
+// RPCServer answers JSON-RPC 2.0 requests, one per line, so that scripts
+// and notebooks can configure and run simulations and fetch their metrics
+// without writing Go:
+//
+//	configure {fields}        change the config like a config file, returns it
+//	run [{fields}]            run to the end, with the fields for this run only
+//	start [{fields}]          start a run paused before its first event
+//	run_to {"time": t}        run the started run up to a virtual time
+//	state                     snapshot of the started run between segments
+//	tune {param,target,value} change a parameter of the started run
+//	finish                    run the started run to its end
+//	metrics                   metrics of the last finished run
+//
+// Runs are silent unless the output goes to a file, as the answers take the
+// standard output.
+type RPCServer struct {
+	cfg     *Config
+	sim     *Simulation
+	session *Session // Started run, nil once it finished
+	ended   bool     // The last run reached its end
+}
+
+// NewRPCServer creates a server whose runs start from the given config
+func NewRPCServer(cfg *Config) *RPCServer {
+	return &RPCServer{cfg: cfg}
+}
+
+// Serve answers the requests read from in on w until the input ends. A run
+// still started then is finished.
+func (r *RPCServer) Serve(in io.Reader, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rsp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+		var req rpcRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			rsp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		} else {
+			if req.ID != nil {
+				rsp.ID = req.ID
+			}
+			rsp.Result, rsp.Error = r.call(req.Method, req.Params)
+			if req.ID == nil {
+				// Notifications are not answered
+				continue
+			}
+		}
+		if err := enc.Encode(rsp); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if r.session != nil {
+		return r.session.Finish()
+	}
+	return nil
+}
+
+// call carries out a method and returns its result or the error to answer
+func (r *RPCServer) call(method string, params json.RawMessage) (interface{}, *rpcError) {
+	var result interface{}
+	var err error
+	switch method {
+	case "configure":
+		result, err = r.configure(params)
+	case "run":
+		result, err = r.run(params)
+	case "start":
+		result, err = r.start(params)
+	case "run_to":
+		var args struct {
+			Time *float64 `json:"time"`
+		}
+		if e := json.Unmarshal(params, &args); e != nil || args.Time == nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "run_to needs a time"}
+		}
+		result, err = r.runTo(sim.VTimeInSec(*args.Time))
+	case "state":
+		if r.session == nil {
+			return nil, &rpcError{Code: rpcRunError, Message: "no run is started"}
+		}
+		result, err = r.session.State()
+	case "tune":
+		var change ParamChange
+		if e := json.Unmarshal(params, &change); e != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: e.Error()}
+		}
+		if r.session == nil {
+			return nil, &rpcError{Code: rpcRunError, Message: "no run is started"}
+		}
+		result, err = r.session.Tune(change)
+	case "finish":
+		result, err = r.finish()
+	case "metrics":
+		if r.sim == nil || !r.ended {
+			return nil, &rpcError{Code: rpcRunError, Message: "no run has finished"}
+		}
+		result = r.sim.Metrics()
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+	}
+	if err != nil {
+		return nil, &rpcError{Code: rpcRunError, Message: err.Error()}
+	}
+	return result, nil
+}
+
+// configure applies the fields to the config of the next runs
+func (r *RPCServer) configure(params json.RawMessage) (*Config, error) {
+	cfg, err := r.runConfig(params)
+	if err != nil {
+		return nil, err
+	}
+	r.cfg = cfg
+	return cfg, nil
+}
+
+// runConfig returns a copy of the config with the fields applied, checked
+// for a single run. The run writes to the sink of the server.
+func (r *RPCServer) runConfig(params json.RawMessage) (*Config, error) {
+	data, err := json.Marshal(r.cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{Sink: r.cfg.Sink}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config: %v", err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validateRPCRun(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// run builds a simulation and runs it to its end
+func (r *RPCServer) run(params json.RawMessage) (RunMetrics, error) {
+	if err := r.build(params, false); err != nil {
+		return RunMetrics{}, err
+	}
+	if err := r.sim.Run(); err != nil {
+		return RunMetrics{}, err
+	}
+	r.ended = true
+	return r.sim.Metrics(), nil
+}
+
+// start builds a simulation and starts it paused before its first event
+func (r *RPCServer) start(params json.RawMessage) (ControlStatus, error) {
+	if err := r.build(params, true); err != nil {
+		return ControlStatus{}, err
+	}
+	r.session = r.sim.Start()
+	return r.session.control.Pause(), nil
+}
+
+// build creates the simulation of the next run. A run that stops between
+// segments needs the serial engine.
+func (r *RPCServer) build(params json.RawMessage, segmented bool) error {
+	if r.session != nil {
+		return fmt.Errorf("a started run must be finished first")
+	}
+	cfg, err := r.runConfig(params)
+	if err != nil {
+		return err
+	}
+	if segmented && cfg.Engine != "serial" {
+		return fmt.Errorf("a started run needs the serial engine")
+	}
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		return err
+	}
+	r.sim, r.ended = simulation, false
+	return nil
+}
+
+// runTo runs the started run up to t, and ends it if it ran out of events
+func (r *RPCServer) runTo(t sim.VTimeInSec) (ControlStatus, error) {
+	if r.session == nil {
+		return ControlStatus{}, fmt.Errorf("no run is started")
+	}
+	status, err := r.session.RunTo(t)
+	if status.Finished {
+		r.session, r.ended = nil, err == nil
+	}
+	return status, err
+}
+
+// finish runs the started run to its end
+func (r *RPCServer) finish() (RunMetrics, error) {
+	if r.session == nil {
+		return RunMetrics{}, fmt.Errorf("no run is started")
+	}
+	err := r.session.Finish()
+	r.session = nil
+	if err != nil {
+		return RunMetrics{}, err
+	}
+	r.ended = true
+	return r.sim.Metrics(), nil
+}
+
+// validateRPCRun checks that the config describes a single run the server
+// can drive
+func (c *Config) validateRPCRun() error {
+	if c.Scenario != "" || c.Bench > 0 {
+		return fmt.Errorf("the RPC server drives single runs, not scenarios or benchmarks")
+	}
+	if c.Interactive || c.Control != "" || len(c.Segments) > 0 {
+		return fmt.Errorf("the RPC server cannot be combined with interactive mode, the control API, or segments")
+	}
+	if c.Checkpoint != "" || c.Replay != "" {
+		return fmt.Errorf("the RPC server does not save or replay checkpoints")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package demo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// serveRPC answers the requests without output and returns the responses
+// by ID
+func serveRPC(t *testing.T, cfg *Config, requests ...string) map[int]rpcResponse {
+	silence(t, cfg)
+	var w bytes.Buffer
+	if err := NewRPCServer(cfg).Serve(strings.NewReader(strings.Join(requests, "\n")), &w); err != nil {
+		t.Fatal(err)
+	}
+	responses := make(map[int]rpcResponse)
+	dec := json.NewDecoder(&w)
+	for dec.More() {
+		var rsp rpcResponse
+		if err := dec.Decode(&rsp); err != nil {
+			t.Fatal(err)
+		}
+		var id int
+		json.Unmarshal(rsp.ID, &id)
+		responses[id] = rsp
+	}
+	return responses
+}
+
+// resultOf decodes the result of a response
+func resultOf(t *testing.T, rsp rpcResponse, v interface{}) {
+	if rsp.Error != nil {
+		t.Fatalf("Expected a result, got error %+v", *rsp.Error)
+	}
+	data, _ := json.Marshal(rsp.Result)
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRPCRunsConfiguredSimulation verifies that a run requested over RPC
+// takes the configured fields and returns the metrics of the same run made
+// directly
+func TestRPCRunsConfiguredSimulation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 60
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := simulation.Metrics()
+	
+	responses := serveRPC(t, DefaultConfig(),
+		`{"jsonrpc": "2.0", "id": 1, "method": "configure", "params": {"cycles": 60}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "run", "params": {"seed": 1}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "metrics"}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "configure"}`,
+	)
+	var got RunMetrics
+	resultOf(t, responses[2], &got)
+	if got.Produced != want.Produced || got.Consumed != want.Consumed || got.P99Latency != want.P99Latency ||
+		len(got.Latencies) != want.Consumed || !got.Conserved {
+		t.Errorf("Expected the metrics of the direct run %+v, got %+v", want, got)
+	}
+	var again RunMetrics
+	resultOf(t, responses[3], &again)
+	if again.Produced != got.Produced {
+		t.Errorf("Expected the metrics of the last run, got %+v", again)
+	}
+	var kept Config
+	resultOf(t, responses[4], &kept)
+	if kept.Cycles != 60 || kept.Seed != 0 {
+		t.Errorf("Expected the configured cycles without the seed of a single run, got %d and %d", kept.Cycles, kept.Seed)
+	}
+}
+
+// TestRPCRunsInSegments verifies that a started run stops at the requested
+// time, can be inspected and tuned, and finishes with its metrics
+func TestRPCRunsInSegments(t *testing.T) {
+	responses := serveRPC(t, DefaultConfig(),
+		`{"jsonrpc": "2.0", "id": 1, "method": "start", "params": {"seed": 1, "cycles": 60}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "run_to", "params": {"time": 20}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "state"}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "tune", "params": {"param": "consume-interval", "target": "Consumer2", "value": 4}}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "run", "params": {"seed": 1}}`,
+		`{"jsonrpc": "2.0", "id": 6, "method": "finish"}`,
+	)
+	var status ControlStatus
+	resultOf(t, responses[2], &status)
+	if !status.Paused || status.Time >= 20 {
+		t.Errorf("Expected the run to stop before 20, got %+v", status)
+	}
+	var state SimulationState
+	resultOf(t, responses[3], &state)
+	if state.Produced == 0 {
+		t.Errorf("Expected messages produced by 20, got %+v", state)
+	}
+	var change ParamChange
+	resultOf(t, responses[4], &change)
+	if !change.Pending {
+		t.Errorf("Expected the change to wait for the next time boundary, got %+v", change)
+	}
+	if responses[5].Error == nil {
+		t.Error("Expected a new run to be rejected while one is started")
+	}
+	var metrics RunMetrics
+	resultOf(t, responses[6], &metrics)
+	if metrics.Consumed == 0 || !metrics.Conserved {
+		t.Errorf("Expected the finished run to consume its messages, got %+v", metrics)
+	}
+}
+
+// TestRPCErrors verifies the error codes of malformed requests, unknown
+// methods, and requests that do not apply
+func TestRPCErrors(t *testing.T) {
+	responses := serveRPC(t, DefaultConfig(),
+		`{"jsonrpc": "2.0", "id": 1, "method": "fly"}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "run_to"}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "run", "params": {"scenario": "work-pool"}}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "configure", "params": {"cycles": -1}}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "metrics"}`,
+		`{"jsonrpc": "2.0", "method": "fly"}`,
+		`not json`,
+	)
+	for id, code := range map[int]int{1: rpcMethodNotFound, 2: rpcInvalidParams, 3: rpcRunError, 4: rpcRunError, 5: rpcRunError, 0: rpcParseError} {
+		if rsp := responses[id]; rsp.Error == nil || rsp.Error.Code != code {
+			t.Errorf("Expected request %d to fail with %d, got %+v", id, code, rsp)
+		}
+	}
+	if len(responses) != 6 {
+		t.Errorf("Expected no answer to the notification, got %d answers", len(responses))
+	}
+}
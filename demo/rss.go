@@ -0,0 +1,31 @@
+package demo
+
+import (
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// PrintRxQueueReport prints how many messages each RX queue served, and the
+// imbalance between the busiest queue and the average queue
+func PrintRxQueueReport(out EventSink, consumers []*consumer.Consumer) {
+	out.Println("=== RX Queues ===")
+	for _, c := range consumers {
+		total := 0
+		busiest := 0
+		consumed := c.Consumed()
+		out.Printf("%s:", c.Name())
+		for i, n := range consumed {
+			out.Printf(" rx%d=%d", i, n)
+			total += n
+			if n > busiest {
+				busiest = n
+			}
+		}
+
+		imbalance := 0.0
+		if total > 0 {
+			mean := float64(total) / float64(len(consumed))
+			imbalance = float64(busiest) / mean
+		}
+		out.Printf(" (imbalance: %.2fx)\n", imbalance)
+	}
+}
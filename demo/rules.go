@@ -0,0 +1,195 @@
+package demo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// RoutingRule matches messages by their fields and sends the matching ones
+// to a consumer or drops them. A field left at its zero value matches every
+// message.
+type RoutingRule struct {
+	Content  string `json:"content"`  // Regular expression the content must match
+	MinSize  int    `json:"min_size"` // Smallest payload size in bytes
+	MaxSize  int    `json:"max_size"` // Largest payload size in bytes, 0 for no limit
+	Priority *int   `json:"priority"` // Priority the message must have, nil for any
+	Consumer string `json:"consumer"` // Consumer to send the matching messages to
+	Drop     bool   `json:"drop"`     // Drop the matching messages instead
+}
+
+// String describes the match and the action of the rule
+func (r RoutingRule) String() string {
+	var conds []string
+	if r.Content != "" {
+		conds = append(conds, fmt.Sprintf("content ~ /%s/", r.Content))
+	}
+	if r.MinSize > 0 || r.MaxSize > 0 {
+		if r.MaxSize > 0 {
+			conds = append(conds, fmt.Sprintf("size %d-%d", r.MinSize, r.MaxSize))
+		} else {
+			conds = append(conds, fmt.Sprintf("size >= %d", r.MinSize))
+		}
+	}
+	if r.Priority != nil {
+		conds = append(conds, fmt.Sprintf("priority %d", *r.Priority))
+	}
+	if len(conds) == 0 {
+		conds = append(conds, "any")
+	}
+	action := r.Consumer
+	if r.Drop {
+		action = "drop"
+	}
+	return strings.Join(conds, ", ") + " -> " + action
+}
+
+// RoutingRules lets the distributor route messages by their content instead
+// of the destination the producer picked. The first rule a message matches
+// decides: the message goes to the rule's consumer, numbered in the sequence
+// of its addressee, or is dropped. Multicast messages are not matched. Its
+// methods are safe to call on a nil rule set.
+type RoutingRules struct {
+	Rules   []RoutingRule
+	content []*regexp.Regexp
+
+	Routed  []int // Messages every rule sent to its consumer
+	Dropped []int // Messages every rule dropped
+}
+
+// NewRoutingRules compiles the content patterns of the rules
+func NewRoutingRules(rules []RoutingRule) (*RoutingRules, error) {
+	r := &RoutingRules{
+		Rules:   rules,
+		Routed:  make([]int, len(rules)),
+		Dropped: make([]int, len(rules)),
+	}
+	for i, rule := range rules {
+		var re *regexp.Regexp
+		if rule.Content != "" {
+			var err error
+			re, err = regexp.Compile(rule.Content)
+			if err != nil {
+				return nil, fmt.Errorf("routing rule %d: %v", i+1, err)
+			}
+		}
+		r.content = append(r.content, re)
+	}
+	return r, nil
+}
+
+// Match returns the index of the first rule the message matches, -1 if it
+// matches none
+func (r *RoutingRules) Match(m *msg.DemoMessage) int {
+	if r == nil || m.Group != "" {
+		return -1
+	}
+	for i, rule := range r.Rules {
+		if r.content[i] != nil && !r.content[i].MatchString(m.Content) {
+			continue
+		}
+		if m.Size < rule.MinSize || (rule.MaxSize > 0 && m.Size > rule.MaxSize) {
+			continue
+		}
+		if rule.Priority != nil && m.Priority != *rule.Priority {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// Apply returns the message addressed to the consumer of the rule it
+// matches, a copy if the rule changes its destination, and the index of the
+// rule. Messages that match no rule or a drop rule are returned unchanged.
+func (r *RoutingRules) Apply(m *msg.DemoMessage) (*msg.DemoMessage, int) {
+	i := r.Match(m)
+	if i < 0 || r.Rules[i].Drop {
+		return m, i
+	}
+	if r.Rules[i].Consumer == m.Destination {
+		m.Rule = i + 1
+		return m, i
+	}
+	routed := m.Clone()
+	routed.Destination = r.Rules[i].Consumer
+	routed.RuleFrom = m.Destination
+	routed.Rule = i + 1
+	return routed, i
+}
+
+// Sent counts the message for the rule that routed it once it has been sent
+func (r *RoutingRules) Sent(m *msg.DemoMessage) {
+	if r == nil || m.Rule == 0 {
+		return
+	}
+	r.Routed[m.Rule-1]++
+}
+
+// Drops reports whether a rule drops the messages it matches
+func (r *RoutingRules) Drops(rule int) bool {
+	return r.Rules[rule].Drop
+}
+
+// Drop counts a message a rule dropped
+func (r *RoutingRules) Drop(rule int) {
+	r.Dropped[rule]++
+}
+
+// TotalDropped returns the number of messages the rules dropped
+func (r *RoutingRules) TotalDropped() int {
+	if r == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range r.Dropped {
+		total += n
+	}
+	return total
+}
+
+// Print writes the rules and the messages every rule routed or dropped
+func (r *RoutingRules) Print(out EventSink) {
+	out.Println("=== Routing Rules ===")
+	out.Printf("%-4s %-48s %8s %8s\n", "Rule", "Match", "Routed", "Dropped")
+	for i, rule := range r.Rules {
+		out.Printf("%-4d %-48s %8d %8d\n", i+1, rule, r.Routed[i], r.Dropped[i])
+	}
+}
+
+// checkRoutingRules checks that the rules send messages to consumers of the
+// run
+func checkRoutingRules(rules []RoutingRule, consumers []string) error {
+	known := make(map[string]bool)
+	for _, name := range consumers {
+		known[name] = true
+	}
+	for i, rule := range rules {
+		if !rule.Drop && !known[rule.Consumer] {
+			return fmt.Errorf("routing rule %d: unknown consumer %q", i+1, rule.Consumer)
+		}
+	}
+	return nil
+}
+
+// validateRoutingRules checks that every rule has one action and valid
+// bounds, and that the rules apply at a single distributor
+func (c *Config) validateRoutingRules() error {
+	for i, rule := range c.RoutingRules {
+		if rule.Drop == (rule.Consumer != "") {
+			return fmt.Errorf("routing rule %d needs either a consumer or drop", i+1)
+		}
+		if rule.MinSize < 0 || rule.MaxSize < 0 || (rule.MaxSize > 0 && rule.MaxSize < rule.MinSize) {
+			return fmt.Errorf("routing rule %d has an invalid size range", i+1)
+		}
+		if _, err := regexp.Compile(rule.Content); err != nil {
+			return fmt.Errorf("routing rule %d: %v", i+1, err)
+		}
+	}
+	if len(c.RoutingRules) > 0 && c.DistributorDepth > 1 {
+		return fmt.Errorf("routing rules need a single distributor")
+	}
+	return nil
+}
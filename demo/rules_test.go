@@ -0,0 +1,115 @@
+package demo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestRoutingRulesFirstMatchDecides verifies that every field of a rule
+// narrows its match and that the first matching rule decides
+func TestRoutingRulesFirstMatchDecides(t *testing.T) {
+	urgent := 2
+	rules, err := NewRoutingRules([]RoutingRule{
+		{Priority: &urgent, Consumer: "Consumer1"},
+		{Content: "^Bulk", MinSize: 100, MaxSize: 200, Drop: true},
+		{Content: "^Bulk", Consumer: "Consumer2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	for _, c := range []struct {
+		msg  msg.DemoMessage
+		want int
+	}{
+		{msg.DemoMessage{Content: "Bulk load", Size: 150, Priority: 2}, 0},
+		{msg.DemoMessage{Content: "Bulk load", Size: 150}, 1},
+		{msg.DemoMessage{Content: "Bulk load", Size: 250}, 2},
+		{msg.DemoMessage{Content: "Message at time 3.00", Size: 150}, -1},
+		{msg.DemoMessage{Content: "Bulk load", Priority: 2, Group: distributor.BroadcastGroup}, -1},
+	} {
+		if got := rules.Match(&c.msg); got != c.want {
+			t.Errorf("Expected %+v to match rule %d, got %d", c.msg, c.want, got)
+		}
+	}
+	
+	m := &msg.DemoMessage{Content: "Bulk load", Size: 250, Destination: "Consumer3", SeqNum: 7}
+	routed, rule := rules.Apply(m)
+	if rule != 2 || routed == m || routed.Destination != "Consumer2" {
+		t.Fatalf("Expected a copy for Consumer2 by rule 3, got %+v by rule %d", routed, rule+1)
+	}
+	if routed.Addressee() != "Consumer3" || routed.SeqNum != 7 {
+		t.Errorf("Expected the copy numbered in the sequence of Consumer3, got %s #%d", routed.Addressee(), routed.SeqNum)
+	}
+}
+
+// TestRoutingRulesRouteAndDrop verifies that a run with rules sends the
+// matching messages to the rule's consumer, drops the ones a rule drops, and
+// accounts for all of them
+func TestRoutingRulesRouteAndDrop(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 60
+	cfg.PriorityLevels = 3
+	urgent := 2
+	cfg.RoutingRules = []RoutingRule{
+		{Priority: &urgent, Consumer: "Consumer1"},
+		{Content: `time 2[0-9]\.`, Drop: true},
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	rules := simulation.routing.rules
+	if rules.Routed[0] == 0 || rules.Dropped[1] == 0 {
+		t.Errorf("Expected both rules to apply, got %v routed and %v dropped", rules.Routed, rules.Dropped)
+	}
+	c := simulation.Conservation()
+	if !c.Holds() || c.Filtered != rules.TotalDropped() {
+		t.Errorf("Expected the dropped messages to be accounted for, got %+v", c)
+	}
+	if c.Consumed+c.Filtered != c.Produced {
+		t.Errorf("Expected every message consumed or dropped, got %+v", c)
+	}
+}
+
+// TestRoutingRulesValidation verifies that rules are read from JSON and that
+// a rule needs exactly one action, a valid pattern, and a known consumer
+func TestRoutingRulesValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	data := `{"routing_rules": [{"content": "time 1", "max_size": 64, "consumer": "Consumer2"}]}`
+	if err := json.Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected the rules to be valid, got %v", err)
+	}
+	if rule := cfg.RoutingRules[0]; rule.Content != "time 1" || rule.MaxSize != 64 || rule.Priority != nil {
+		t.Errorf("Expected the rule as written, got %+v", rule)
+	}
+	
+	for _, bad := range []RoutingRule{
+		{Content: "time", Consumer: "Consumer2", Drop: true},
+		{Content: "time"},
+		{Content: "(", Drop: true},
+		{MinSize: 10, MaxSize: 5, Drop: true},
+	} {
+		cfg.RoutingRules = []RoutingRule{bad}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+	
+	cfg.RoutingRules = []RoutingRule{{Consumer: "Consumer9"}}
+	if _, err := NewSimulation(cfg); err == nil {
+		t.Error("Expected a rule for an unknown consumer to be rejected")
+	}
+}
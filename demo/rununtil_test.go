@@ -0,0 +1,187 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// engineDriver runs an engine in the background and holds it before every
+// event, so that a test can stop the run as soon as a condition holds and
+// inspect the components without racing the engine
+type engineDriver struct {
+	events  chan sim.VTimeInSec // Time of the event the engine waits to handle
+	next    chan struct{}       // Lets the engine handle the event
+	release chan struct{}       // Closed to let the engine run to its end
+	done    chan error
+	pending bool
+	at      sim.VTimeInSec
+	ended   bool
+}
+
+// Func blocks the engine before every event until the driver lets it go on,
+// or released it
+func (d *engineDriver) Func(ctx sim.HookCtx) {
+	if ctx.Pos != sim.HookPosBeforeEvent {
+		return
+	}
+	select {
+	case d.events <- ctx.Item.(sim.Event).Time():
+	case <-d.release:
+		return
+	}
+	select {
+	case <-d.next:
+	case <-d.release:
+	}
+}
+
+// finish releases the engine and waits until it handled its remaining
+// events, so that its goroutine ends
+func (d *engineDriver) finish() {
+	close(d.release)
+	if !d.ended {
+		<-d.done
+		d.ended = true
+	}
+}
+
+// wait blocks until the engine waits for the next event or ran out of events
+func (d *engineDriver) wait() {
+	if d.pending || d.ended {
+		return
+	}
+	select {
+	case d.at = <-d.events:
+		d.pending = true
+	case <-d.done:
+		d.ended = true
+	}
+}
+
+// engineDrivers are the drivers of the engines the running test stopped
+var engineDrivers = make(map[sim.Engine]*engineDriver)
+
+// RunUntil runs the engine until cond holds or the next event is later than
+// deadline, and reports whether cond holds. The engine stops between events,
+// so the state seen by cond and by the test afterwards is that of a single
+// point in virtual time. Later calls continue the same run. While stopped,
+// the engine's CurrentTime already is the time of the next event. When the
+// test ends, the engine runs to its end and its driver is dropped.
+func RunUntil(t *testing.T, engine sim.Engine, cond func() bool, deadline sim.VTimeInSec) bool {
+	t.Helper()
+	d, ok := engineDrivers[engine]
+	if !ok {
+		d = &engineDriver{
+			events:  make(chan sim.VTimeInSec),
+			next:    make(chan struct{}),
+			release: make(chan struct{}),
+			done:    make(chan error, 1),
+		}
+		engineDrivers[engine] = d
+		engine.AcceptHook(d)
+		go func() { d.done <- engine.Run() }()
+		t.Cleanup(func() {
+			d.finish()
+			delete(engineDrivers, engine)
+		})
+	}
+	
+	for {
+		d.wait()
+		if cond() {
+			return true
+		}
+		if d.ended || d.at > deadline {
+			return false
+		}
+		d.pending = false
+		d.next <- struct{}{}
+	}
+}
+
+// TestRunUntilStopsWhenConditionHolds verifies that the run stops right after
+// the event that makes the condition true
+func TestRunUntilStopsWhenConditionHolds(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	cfg.Cycles = 40
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startComponents(simulation.components, 0, false)
+	
+	consumed := func() bool { return simulation.stats.Consumed >= 3 }
+	if !RunUntil(t, simulation.engine, consumed, 1000) {
+		t.Fatalf("Expected 3 messages to be consumed")
+	}
+	if simulation.stats.Consumed != 3 {
+		t.Errorf("Expected the run to stop at the 3rd message, got %d", simulation.stats.Consumed)
+	}
+	if now := simulation.engine.CurrentTime(); now >= 40 {
+		t.Errorf("Expected the run to stop before the end, stopped at %.2f", now)
+	}
+	
+	// The run continues where it stopped
+	more := func() bool { return simulation.stats.Consumed >= 5 }
+	if !RunUntil(t, simulation.engine, more, 1000) {
+		t.Errorf("Expected 5 messages to be consumed")
+	}
+}
+
+// TestRunUntilStopsAtDeadline verifies that the run stops before the first
+// event past the deadline when the condition never holds
+func TestRunUntilStopsAtDeadline(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	handler := &countingHandler{}
+	for at := sim.VTimeInSec(1); at <= 20; at++ {
+		engine.Schedule(sim.NewEventBase(at, handler))
+	}
+	
+	never := func() bool { return false }
+	if RunUntil(t, engine, never, 10) {
+		t.Fatalf("Expected the condition not to hold")
+	}
+	if len(handler.handled) != 10 {
+		t.Errorf("Expected the events up to 10 to be handled, got %v", handler.handled)
+	}
+}
+
+// TestRunUntilReportsEndOfEvents verifies that RunUntil returns when the
+// engine runs out of events
+func TestRunUntilReportsEndOfEvents(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	handler := &countingHandler{}
+	engine.Schedule(sim.NewEventBase(1, handler))
+	
+	handled := func() bool { return len(handler.handled) == 2 }
+	if RunUntil(t, engine, handled, 1000) {
+		t.Errorf("Expected the run to end before the condition holds")
+	}
+	if len(handler.handled) != 1 {
+		t.Errorf("Expected 1 handled event, got %d", len(handler.handled))
+	}
+}
+
+// TestRunUntilFinishesEngineAtCleanup verifies that a stopped engine runs to
+// its end when the test ends and leaves no driver behind
+func TestRunUntilFinishesEngineAtCleanup(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	handler := &countingHandler{}
+	for at := sim.VTimeInSec(1); at <= 20; at++ {
+		engine.Schedule(sim.NewEventBase(at, handler))
+	}
+	
+	t.Run("stopped", func(t *testing.T) {
+		if !RunUntil(t, engine, func() bool { return len(handler.handled) == 5 }, 1000) {
+			t.Fatalf("Expected 5 handled events")
+		}
+	})
+	if len(handler.handled) != 20 {
+		t.Errorf("Expected the engine to handle all 20 events at cleanup, got %d", len(handler.handled))
+	}
+	if len(engineDrivers) != 0 {
+		t.Errorf("Expected no driver left, got %d", len(engineDrivers))
+	}
+}
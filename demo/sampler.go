@@ -0,0 +1,146 @@
+package demo
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// QueueSample is the occupancy of every tracked port at one point in time
+type QueueSample struct {
+	Time   sim.VTimeInSec
+	Depths []int // In the order the ports were tracked
+}
+
+// QueueSampler records the occupancy of port buffers at a fixed virtual-time
+// interval. Samples are taken when the engine moves past a sample time, so
+// sampling does not add events to the run. A sample shows the state after
+// all the events at its time.
+type QueueSampler struct {
+	interval sim.VTimeInSec
+	next     sim.VTimeInSec
+	ports    []sim.Port
+	index    map[sim.Port]int
+	depths   []int
+
+	Samples []QueueSample
+}
+
+// NewQueueSampler creates a sampler that samples every interval seconds,
+// starting at time 0
+func NewQueueSampler(engine sim.Engine, interval sim.VTimeInSec) *QueueSampler {
+	s := &QueueSampler{
+		interval: interval,
+		index:    make(map[sim.Port]int),
+	}
+	engine.AcceptHook(s)
+	return s
+}
+
+// Track includes the buffer of a port in the samples
+func (s *QueueSampler) Track(port sim.Port) {
+	s.index[port] = len(s.ports)
+	s.ports = append(s.ports, port)
+	s.depths = append(s.depths, 0)
+	port.AcceptHook(s)
+}
+
+// Func takes the samples due before an event and follows the occupancy of
+// the tracked ports
+func (s *QueueSampler) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosBeforeEvent:
+		s.sampleBefore(ctx.Item.(sim.Event).Time())
+	case sim.HookPosPortMsgRecvd:
+		s.depths[s.index[ctx.Domain.(sim.Port)]]++
+	case sim.HookPosPortMsgRetrieve:
+		s.depths[s.index[ctx.Domain.(sim.Port)]]--
+	}
+}
+
+func (s *QueueSampler) sampleBefore(t sim.VTimeInSec) {
+	for s.next < t {
+		s.sample()
+	}
+}
+
+func (s *QueueSampler) sample() {
+	depths := make([]int, len(s.depths))
+	copy(depths, s.depths)
+	s.Samples = append(s.Samples, QueueSample{Time: s.next, Depths: depths})
+	s.next += s.interval
+}
+
+// Finish takes the remaining samples up to the end of the run
+func (s *QueueSampler) Finish(end sim.VTimeInSec) {
+	for s.next <= end {
+		s.sample()
+	}
+}
+
+// WriteSamples writes one row per sample with one column per port
+func (s *QueueSampler) WriteSamples(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"time"}
+	for _, port := range s.ports {
+		header = append(header, port.Name())
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, sample := range s.Samples {
+		row := []string{strconv.FormatFloat(float64(sample.Time), 'f', -1, 64)}
+		for _, depth := range sample.Depths {
+			row = append(row, strconv.Itoa(depth))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportSamples writes the queue-depth time series to a CSV file
+func (s *QueueSampler) ExportSamples(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.WriteSamples(f)
+}
+
+// Print writes the mean and peak occupancy of every port and names the port
+// with the highest mean, the likely bottleneck
+func (s *QueueSampler) Print(out EventSink) {
+	out.Println("=== Queue Depth ===")
+	if len(s.Samples) == 0 {
+		return
+	}
+	out.Printf("%-28s %6s %6s\n", "Port", "Mean", "Peak")
+	bottleneck, bottleneckMean := -1, 0.0
+	for i, port := range s.ports {
+		sum, peak := 0, 0
+		for _, sample := range s.Samples {
+			sum += sample.Depths[i]
+			if sample.Depths[i] > peak {
+				peak = sample.Depths[i]
+			}
+		}
+		mean := float64(sum) / float64(len(s.Samples))
+		if mean > bottleneckMean {
+			bottleneck, bottleneckMean = i, mean
+		}
+		out.Printf("%-28s %6.2f %6d\n", port.Name(), mean, peak)
+	}
+	if bottleneck >= 0 {
+		out.Printf("Deepest queue:     %s\n", s.ports[bottleneck].Name())
+	}
+}
@@ -0,0 +1,63 @@
+package demo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+type funcHandler func(now sim.VTimeInSec)
+
+func (f funcHandler) Handle(e sim.Event) error {
+	f(e.Time())
+	return nil
+}
+
+// TestQueueSamplerRecordsDepths verifies that every sample shows the queue
+// depth after the events at its time
+func TestQueueSamplerRecordsDepths(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	sleepy := &sleepyComponent{ComponentBase: sim.NewComponentBase("Sleepy")}
+	port := sim.NewLimitNumMsgPort(sleepy, 4, "Sleepy.In")
+	sampler := NewQueueSampler(engine, 2)
+	sampler.Track(port)
+	
+	recv := funcHandler(func(now sim.VTimeInSec) {
+		m := &msg.DemoMessage{Destination: "Sleepy"}
+		m.Meta().Dst = port
+		port.Recv(m)
+	})
+	retrieve := funcHandler(func(now sim.VTimeInSec) {
+		port.Retrieve(now)
+	})
+	for _, at := range []sim.VTimeInSec{1, 2, 3} {
+		engine.Schedule(sim.NewEventBase(at, recv))
+	}
+	engine.Schedule(sim.NewEventBase(5, retrieve))
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	sampler.Finish(engine.CurrentTime())
+	
+	expected := []int{0, 2, 3}
+	if len(sampler.Samples) != len(expected) {
+		t.Fatalf("Expected %d samples, got %v", len(expected), sampler.Samples)
+	}
+	for i, depth := range expected {
+		if sampler.Samples[i].Time != sim.VTimeInSec(2*i) || sampler.Samples[i].Depths[0] != depth {
+			t.Errorf("Sample %d: expected depth %d at %d, got %v", i, depth, 2*i, sampler.Samples[i])
+		}
+	}
+	
+	var buf bytes.Buffer
+	if err := sampler.WriteSamples(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "time,Sleepy.In" || lines[2] != "2,2" {
+		t.Errorf("Unexpected CSV output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,138 @@
+package demo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// ScenarioResult summarizes one run of a comparison scenario
+type ScenarioResult struct {
+	Name        string
+	Produced    int
+	Consumed    int
+	MeanLatency float64
+	P99Latency  float64
+	Completion  sim.VTimeInSec // Time the last message was consumed
+	// CDF of the end-to-end latencies, as returned by Stats.LatencyCDF
+	Latencies []float64
+	Fractions []float64
+}
+
+// scenarioRun is one run of a comparison scenario, configure adapts the
+// config of the run
+type scenarioRun struct {
+	name      string
+	configure func(cfg *Config)
+}
+
+// runScenario runs the same workload once per run and returns the results
+func runScenario(cfg *Config, runs []scenarioRun) ([]ScenarioResult, error) {
+	// All runs must see the same traffic
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var results []ScenarioResult
+	for _, run := range runs {
+		runCfg := *cfg
+		runCfg.Seed = seed
+		run.configure(&runCfg)
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		simulation.out.Printf("=== Scenario Run: %s ===\n", run.name)
+		if err := simulation.Run(); err != nil {
+			return nil, err
+		}
+		simulation.out.Println()
+
+		stats := simulation.stats
+		latencies, fractions := stats.LatencyCDF()
+		results = append(results, ScenarioResult{
+			Name:        run.name,
+			Produced:    stats.Produced,
+			Consumed:    stats.Consumed,
+			MeanLatency: stats.MeanLatency(),
+			P99Latency:  stats.LatencyPercentile(99),
+			Completion:  simulation.Completion(),
+			Latencies:   latencies,
+			Fractions:   fractions,
+		})
+	}
+
+	return results, nil
+}
+
+// RunBatchVsStreaming runs the same workload twice, once with consumers that
+// process messages as they arrive and once with consumers that wait for a
+// full batch, and returns the results of both runs
+func RunBatchVsStreaming(cfg *Config) ([]ScenarioResult, error) {
+	return runScenario(cfg, []scenarioRun{
+		{"streaming", func(c *Config) { c.ConsumerMode = "event" }},
+		{fmt.Sprintf("batch(%d)", cfg.BatchSize), func(c *Config) { c.ConsumerMode = "batch" }},
+	})
+}
+
+// RunDestinationPolicies runs the same workload with random destinations,
+// with destinations picked by their recent ACK latency, and with the
+// distributor balancing on queue lengths, and returns the results of all runs
+func RunDestinationPolicies(cfg *Config) ([]ScenarioResult, error) {
+	route := func(dest, route string) func(*Config) {
+		return func(c *Config) {
+			c.DestPolicy = dest
+			c.RoutePolicy = route
+		}
+	}
+	return runScenario(cfg, []scenarioRun{
+		{"random", route("random", "destination")},
+		{"latency-p2c", route("latency-p2c", "destination")},
+		{"queue-p2c", route("random", "queue-p2c")},
+	})
+}
+
+// RunPauseImpact runs the same workload with consumers that never pause and
+// with the configured consumer pauses, and returns the results of both runs
+func RunPauseImpact(cfg *Config) ([]ScenarioResult, error) {
+	interval := cfg.PauseInterval
+	return runScenario(cfg, []scenarioRun{
+		{"no pauses", func(c *Config) { c.PauseInterval = 0 }},
+		{"pauses", func(c *Config) { c.PauseInterval = interval }},
+	})
+}
+
+// RunWorkPool runs the same workload with a queue per consumer and with
+// one work pool the consumers pull from, and returns the results of both runs
+func RunWorkPool(cfg *Config) ([]ScenarioResult, error) {
+	return runScenario(cfg, []scenarioRun{
+		{"dedicated", func(c *Config) { c.WorkPool = false }},
+		{"work-pool", func(c *Config) { c.WorkPool = true }},
+	})
+}
+
+// PrintScenarioComparison writes the results of a scenario side by side,
+// with the completion time of every run relative to the first one
+func PrintScenarioComparison(out EventSink, title string, results []ScenarioResult) {
+	out.Printf("=== %s ===\n", title)
+	out.Printf("%-12s %9s %9s %14s %13s %12s\n",
+		"Mode", "Produced", "Consumed", "Mean latency", "p99 latency", "Completion")
+	for _, r := range results {
+		out.Printf("%-12s %9d %9d %12.2f s %11.2f s %10.2f s\n",
+			r.Name, r.Produced, r.Consumed, r.MeanLatency, r.P99Latency, float64(r.Completion))
+	}
+
+	if len(results) < 2 || results[0].Completion <= 0 {
+		return
+	}
+	base := results[0]
+	for _, r := range results[1:] {
+		diff := r.Completion - base.Completion
+		out.Printf("%s completes %+.2f s (%+.1f%%) relative to %s\n",
+			r.Name, float64(diff), float64(diff/base.Completion)*100, base.Name)
+	}
+}
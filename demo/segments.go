@@ -0,0 +1,134 @@
+package demo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Session runs a simulation in segments. Every RunTo runs the engine to a
+// virtual time and returns control to the caller, who can inspect the run
+// and change its parameters before the next segment; Finish runs the rest.
+// The engine runs on its own goroutine and waits between segments, so the
+// components must only be read between the calls.
+type Session struct {
+	sim     *Simulation
+	control *Controller
+	done    chan error
+	at      sim.VTimeInSec // Time of the last segment
+	ended   bool
+	err     error
+}
+
+// Start starts the run of the simulation paused before its first event
+func (s *Simulation) Start() *Session {
+	c := s.newController(true)
+	session := &Session{sim: s, control: c, done: make(chan error, 1)}
+	go func() {
+		err := s.Run()
+		// A failed run does not finish the controller on its own
+		c.Finish()
+		session.done <- err
+	}()
+	c.Pause()
+	return session
+}
+
+// RunTo handles every event before t and stops before the first event at or
+// after it. It returns early if the run ends before t, with the error of the
+// run if it failed.
+func (ss *Session) RunTo(t sim.VTimeInSec) (ControlStatus, error) {
+	if t <= ss.at {
+		return ControlStatus{}, fmt.Errorf("cannot run to %.2f, the run already reached %.2f", float64(t), float64(ss.at))
+	}
+	ss.at = t
+	status := ss.control.RunUntil(t)
+	if status.Finished {
+		return status, ss.wait()
+	}
+	return status, nil
+}
+
+// State takes a snapshot of the run between two segments
+func (ss *Session) State() (SimulationState, error) {
+	return ss.control.State(ss.sim)
+}
+
+// Tune changes a parameter of the run before the next segment
+func (ss *Session) Tune(change ParamChange) (ParamChange, error) {
+	return ss.control.Tune(change)
+}
+
+// Finish runs the simulation to its end and returns the error of the run
+func (ss *Session) Finish() error {
+	ss.control.Continue()
+	return ss.wait()
+}
+
+func (ss *Session) wait() error {
+	if !ss.ended {
+		ss.ended = true
+		ss.err = <-ss.done
+	}
+	return ss.err
+}
+
+// RunSegments runs the simulation to every time in turn and prints the state
+// of the run at every stop before it runs to its end
+func (s *Simulation) RunSegments(times []float64) error {
+	session := s.Start()
+	for _, t := range times {
+		status, err := session.RunTo(sim.VTimeInSec(t))
+		if err != nil || status.Finished {
+			return err
+		}
+		state, err := session.State()
+		if err != nil {
+			return err
+		}
+		printSegment(s.out, t, state)
+	}
+	return session.Finish()
+}
+
+// printSegment writes the progress of the run and the queues of the
+// consumers at a stop
+func printSegment(out EventSink, t float64, state SimulationState) {
+	out.Printf("\n=== Stopped at %.2f ===\n", t)
+	out.Printf("Events:            %d, last at %.2f\n", state.Events, state.Time)
+	out.Printf("Produced:          %d\n", state.Produced)
+	out.Printf("Routed:            %d\n", state.Routed)
+	out.Printf("Consumed:          %d\n", state.Consumed)
+	var queues []string
+	for _, c := range state.Consumers {
+		depth := 0
+		for _, n := range c.Queues {
+			depth += n
+		}
+		queues = append(queues, fmt.Sprintf("%s=%d", c.Name, depth))
+	}
+	out.Printf("Queued:            %s\n\n", strings.Join(queues, " "))
+}
+
+// validateSegments checks that a single run on the serial engine stops at
+// increasing positive times, and that nothing else drives it
+func (c *Config) validateSegments() error {
+	if len(c.Segments) == 0 {
+		return nil
+	}
+	if c.Scenario != "" || c.Bench > 0 {
+		return fmt.Errorf("segments stop a single run, not a scenario or a benchmark")
+	}
+	if c.Interactive || c.Control != "" {
+		return fmt.Errorf("segments cannot be combined with interactive mode or the control API")
+	}
+	last := 0.0
+	for _, t := range c.Segments {
+		if t <= last {
+			return fmt.Errorf("segments must be positive and increasing, got %g after %g", t, last)
+		}
+		last = t
+	}
+	return nil
+}
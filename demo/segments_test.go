@@ -0,0 +1,121 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// TestSessionRunsInSegments verifies that a run stopped at several times
+// stops before the events at those times, can be tuned in between, and ends
+// as an uninterrupted run would without the change
+func TestSessionRunsInSegments(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	silence(t, cfg)
+	
+	whole, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := whole.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := simulation.Start()
+	var events uint64
+	for _, at := range []sim.VTimeInSec{25, 50} {
+		status, err := session.RunTo(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Paused || status.Time >= float64(at) || status.Events <= events {
+			t.Fatalf("Expected the run to stop before %.2f after more events, got %+v", float64(at), status)
+		}
+		events = status.Events
+		
+		state, err := session.State()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Produced == 0 || state.Produced > whole.stats.Produced {
+			t.Errorf("Expected part of the messages produced at %.2f, got %d", float64(at), state.Produced)
+		}
+	}
+	if _, err := session.RunTo(40); err == nil {
+		t.Error("Expected running back to an earlier time to be rejected")
+	}
+	if err := session.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if simulation.stats.Produced != whole.stats.Produced || simulation.stats.Consumed != whole.stats.Consumed {
+		t.Errorf("Expected the segmented run to match the whole run, got %d/%d and %d/%d produced/consumed",
+			simulation.stats.Produced, simulation.stats.Consumed, whole.stats.Produced, whole.stats.Consumed)
+	}
+	if simulation.Duration() != whole.Duration() {
+		t.Errorf("Expected the runs to end at the same time, got %.2f and %.2f",
+			float64(simulation.Duration()), float64(whole.Duration()))
+	}
+}
+
+// TestSessionTunesBetweenSegments verifies that a change made between two
+// segments applies before the first event of the next one
+func TestSessionTunesBetweenSegments(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	session := simulation.Start()
+	if _, err := session.RunTo(30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Tune(ParamChange{Param: "weight", Target: "Consumer1", Value: 0}); err != nil {
+		t.Fatal(err)
+	}
+	producer := simulation.producers[0]
+	addressed := producer.SeqNum("Consumer1")
+	if err := session.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	
+	changes := simulation.control.Changes()
+	if len(changes) != 1 || changes[0].Applied != 30 {
+		t.Fatalf("Expected the change applied at 30, got %+v", changes)
+	}
+	if producer.SeqNum("Consumer1") != addressed {
+		t.Errorf("Expected no messages for Consumer1 after its weight dropped to 0, got %d more", producer.SeqNum("Consumer1")-addressed)
+	}
+	if _, err := session.RunTo(200); err != nil {
+		t.Errorf("Expected a finished session to report no error, got %v", err)
+	}
+}
+
+// TestSegmentsValidation verifies that segments need increasing positive
+// times and a run nothing else drives
+func TestSegmentsValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Segments = []float64{50, 20}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected decreasing segments to be rejected")
+	}
+	cfg.Segments = []float64{20, 50}
+	cfg.Interactive = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected segments in interactive mode to be rejected")
+	}
+	cfg.Interactive = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the segments to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// serviceDists are the distributions of the service times of consumers, and
+// the default parameter of each: the coefficient of variation of normal
+// service times, and the shape of Pareto ones
+var serviceDists = map[string]float64{
+	"fixed":       0,
+	"exponential": 0,
+	"normal":      0.25,
+	"pareto":      2.5,
+}
+
+// ServiceDist draws the service times of a consumer around its interval.
+// Exponential service times make the consumer an M/M/1 server under Poisson
+// arrivals. Normal ones vary by Param times the interval and are cut off at
+// 0. Pareto ones have the shape Param, the lower the heavier their tail.
+type ServiceDist struct {
+	Kind  string
+	Param float64
+
+	rand    *rand.Rand
+	samples []float64 // Drawn service times in multiples of the interval
+}
+
+// ParseServiceDist parses a distribution given as kind or kind:param
+func ParseServiceDist(spec string) (kind string, param float64, err error) {
+	kind, value, hasParam := strings.Cut(strings.TrimSpace(spec), ":")
+	param, known := serviceDists[kind]
+	if !known {
+		return "", 0, fmt.Errorf("unknown service distribution %q, expected fixed, exponential, normal, or pareto", kind)
+	}
+	if !hasParam {
+		return kind, param, nil
+	}
+	if kind == "fixed" || kind == "exponential" {
+		return "", 0, fmt.Errorf("%s service times take no parameter", kind)
+	}
+	param, err = strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid parameter %q of %s service times", value, kind)
+	}
+	switch {
+	case kind == "normal" && param < 0:
+		return "", 0, fmt.Errorf("the coefficient of variation of normal service times must not be negative")
+	case kind == "pareto" && param <= 1:
+		// The mean of a Pareto distribution is infinite up to a shape of 1
+		return "", 0, fmt.Errorf("the shape of Pareto service times must be greater than 1")
+	}
+	return kind, param, nil
+}
+
+// NewServiceDist creates a distribution of the kind drawing from rng
+func NewServiceDist(kind string, param float64, rng *rand.Rand) *ServiceDist {
+	return &ServiceDist{Kind: kind, Param: param, rand: rng}
+}
+
+// Draw returns the service time of the next message in multiples of the
+// consumer's interval, 1 on average
+func (d *ServiceDist) Draw() float64 {
+	var scale float64
+	switch d.Kind {
+	case "exponential":
+		scale = d.rand.ExpFloat64()
+	case "normal":
+		scale = math.Max(0, 1+d.Param*d.rand.NormFloat64())
+	case "pareto":
+		// Inverse transform with the scale that makes the mean 1
+		xm := (d.Param - 1) / d.Param
+		scale = xm / math.Pow(1-d.rand.Float64(), 1/d.Param)
+	default:
+		scale = 1
+	}
+	d.samples = append(d.samples, scale)
+	return scale
+}
+
+// String formats the distribution as it is given
+func (d *ServiceDist) String() string {
+	if d.Kind == "normal" || d.Kind == "pareto" {
+		return fmt.Sprintf("%s:%g", d.Kind, d.Param)
+	}
+	return d.Kind
+}
+
+// ServiceDistribution returns the distribution of the service times of the
+// index-th consumer, or nil if it serves every message in one interval.
+// Every consumer draws from a source of its own.
+func (c *Config) ServiceDistribution(name string, index int) *ServiceDist {
+	spec := c.ServiceDist
+	if s, ok := c.ServiceDists[name]; ok {
+		spec = s
+	}
+	kind, param, err := ParseServiceDist(spec)
+	if err != nil || kind == "fixed" {
+		return nil
+	}
+	seed := time.Now().UnixNano()
+	if c.Seed != 0 {
+		seed = c.Seed
+	}
+	rng := rand.New(rand.NewSource(seed + int64(index+1)<<20))
+	return NewServiceDist(kind, param, rng)
+}
+
+// PrintServiceReport writes the measured service times of the consumers
+// with drawn service times, in multiples of their intervals, to compare the
+// mean and the spread with the distribution
+func PrintServiceReport(out EventSink, consumers []*consumer.Consumer, models map[string]*consumerModels) {
+	out.Println("=== Service Times ===")
+	out.Printf("%-12s %-14s %9s %8s %6s %8s %8s\n", "Consumer", "Distribution", "Interval", "Drawn", "Mean", "CV", "p99")
+	sorted := append([]*consumer.Consumer(nil), consumers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name() < sorted[j].Name() })
+	for _, c := range sorted {
+		service := models[c.Name()].service
+		if service == nil {
+			continue
+		}
+		samples := service.samples
+		out.Printf("%-12s %-14s %7.2f s %8d %6.2f %8.2f %8.2f\n",
+			c.Name(), service, float64(c.Interval()), len(samples), mean(samples), variation(samples), percentile(samples, 99))
+	}
+}
+
+// variation returns the coefficient of variation of samples, 0 if their
+// mean is
+func variation(samples []float64) float64 {
+	m := mean(samples)
+	if m == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range samples {
+		sum += (x - m) * (x - m)
+	}
+	return math.Sqrt(sum/float64(len(samples))) / m
+}
+
+// validateServiceDists checks the service distributions of the consumers
+func (c *Config) validateServiceDists() error {
+	specs := []string{c.ServiceDist}
+	for _, spec := range c.ServiceDists {
+		specs = append(specs, spec)
+	}
+	for _, spec := range specs {
+		if _, _, err := ParseServiceDist(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package demo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// TestServiceDistsAverageOneInterval verifies that every distribution draws
+// service times of one interval on average, with the configured spread
+func TestServiceDistsAverageOneInterval(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		cv   float64
+	}{
+		{"exponential", 1},
+		{"normal", 0.25},
+		{"normal:0.1", 0.1},
+		{"pareto:10", 1 / math.Sqrt(80)},
+	} {
+		kind, param, err := ParseServiceDist(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewServiceDist(kind, param, rand.New(rand.NewSource(1)))
+		for i := 0; i < 20000; i++ {
+			d.Draw()
+		}
+		if m := mean(d.samples); math.Abs(m-1) > 0.05 {
+			t.Errorf("Expected %s service times of 1 interval on average, got %.3f", c.spec, m)
+		}
+		if cv := variation(d.samples); math.Abs(cv-c.cv) > 0.1*c.cv {
+			t.Errorf("Expected %s service times to vary by %.2f, got %.2f", c.spec, c.cv, cv)
+		}
+	}
+}
+
+// TestParseServiceDist verifies the distributions and parameters accepted
+func TestParseServiceDist(t *testing.T) {
+	if kind, param, err := ParseServiceDist("pareto"); err != nil || kind != "pareto" || param != 2.5 {
+		t.Errorf("Expected pareto with the default shape, got %s:%g (%v)", kind, param, err)
+	}
+	for _, spec := range []string{"uniform", "fixed:1", "exponential:2", "normal:-1", "normal:x", "pareto:1"} {
+		if _, _, err := ParseServiceDist(spec); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+}
+
+// TestServiceDistsPerConsumer verifies that only the consumers given a
+// distribution draw their service times, once per served message
+func TestServiceDistsPerConsumer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 200
+	cfg.ServiceDists = map[string]string{"Consumer1": "exponential", "Consumer3": "pareto:1.5"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	for _, c := range simulation.consumers {
+		service := simulation.models[c.Name()].service
+		if c.Name() == "Consumer2" {
+			if service != nil {
+				t.Errorf("Expected Consumer2 to keep fixed service times")
+			}
+			continue
+		}
+		if service == nil || len(service.samples) != c.TotalConsumed() {
+			t.Errorf("Expected %s to draw the service time of every consumed message", c.Name())
+		}
+	}
+	
+	cfg.ServiceDists = map[string]string{"Consumer9": "exponential"}
+	if _, err := NewSimulation(cfg); err == nil {
+		t.Errorf("Expected an unknown consumer to be rejected")
+	}
+}
@@ -0,0 +1,478 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// bufferPolicies are the ways the shared region of a shared buffer is
+// divided among the consumers
+var bufferPolicies = []string{"static", "shared", "dynamic"}
+
+// bufferAdmissions are the ways a shared buffer treats a message for a
+// consumer without room: fifo drops it, priority lets it evict a queued
+// message of a lower priority
+var bufferAdmissions = []string{"fifo", "priority"}
+
+// SharedBuffer is one buffer pool that holds the RX queues of all the
+// consumers, like the packet memory of a switch. Every consumer has Reserve
+// messages of its own and takes more from a shared region of Shared messages
+// as the policy allows:
+//
+//	static   the shared region is split evenly, as if every consumer had a
+//	         buffer of its own
+//	shared   a consumer takes whatever is free
+//	dynamic  a consumer holds at most as much of the region as is still
+//	         free, so that a loaded consumer leaves room to the others
+//
+// A message holds its room from the time the distributor sends it to the
+// consumer until the consumer takes it out of its queue. The distributor
+// drops a message for a consumer without room instead of waiting for it,
+// unless priority admission lets the message push out the latest queued
+// message of the lowest priority below its own.
+type SharedBuffer struct {
+	Policy    string
+	Admission string
+	Reserve   int // Messages reserved for every consumer
+	Shared    int // Messages of the shared region
+
+	owners     map[sim.Port]string  // Consumer of every tracked port
+	holders    map[string]*consumer.Consumer // Consumers whose queues hold the messages
+	used       map[string]int       // Messages held for every consumer
+	consumers  int
+	ledger     *Ledger            // Counts the evicted messages out of the network
+	inversions *InversionDetector // Forgets the evicted messages, nil if not watching
+	Admitted   map[string]int     // Messages sent to every consumer
+	Dropped    map[string]int     // Messages dropped for every consumer
+	Evicted    map[string]int     // Queued messages of every consumer pushed out
+	Priorities map[int]*PriorityLoss
+	Peak       map[string]int // Most messages held for every consumer at once
+	PeakShared int            // Most messages held in the shared region at once
+}
+
+// PriorityLoss counts the messages of a priority a shared buffer admitted,
+// dropped on arrival, and evicted after admitting them
+type PriorityLoss struct {
+	Admitted, Dropped, Evicted int
+}
+
+// LossRate returns the share of the offered messages that were lost
+func (l *PriorityLoss) LossRate() float64 {
+	return percent(l.Dropped+l.Evicted, l.Admitted+l.Dropped)
+}
+
+// NewSharedBuffer creates the buffer pool of the given consumers
+func NewSharedBuffer(policy string, reserve, shared int, consumers []string) *SharedBuffer {
+	return &SharedBuffer{
+		Policy:     policy,
+		Admission:  "fifo",
+		Reserve:    reserve,
+		Shared:     shared,
+		owners:     make(map[sim.Port]string),
+		holders:    make(map[string]*consumer.Consumer),
+		used:       make(map[string]int),
+		consumers:  len(consumers),
+		Admitted:   make(map[string]int),
+		Dropped:    make(map[string]int),
+		Evicted:    make(map[string]int),
+		Priorities: make(map[int]*PriorityLoss),
+		Peak:       make(map[string]int),
+	}
+}
+
+// priority returns the counts of a priority
+func (b *SharedBuffer) priority(p int) *PriorityLoss {
+	if b.Priorities[p] == nil {
+		b.Priorities[p] = &PriorityLoss{}
+	}
+	return b.Priorities[p]
+}
+
+// Track counts the messages sent through or retrieved from a port against
+// the room of a consumer
+func (b *SharedBuffer) Track(port sim.Port, consumer string) {
+	b.owners[port] = consumer
+	port.AcceptHook(b)
+}
+
+// Hold counts the messages retrieved from the RX queues of a consumer
+// against its room, and lets arrivals evict the messages queued there
+func (b *SharedBuffer) Hold(c *consumer.Consumer) {
+	b.holders[c.Name()] = c
+	for _, port := range c.RxPorts() {
+		b.Track(port, c.Name())
+	}
+}
+
+// Func takes room for a message sent to a consumer and frees it once the
+// consumer retrieved the message
+func (b *SharedBuffer) Func(ctx sim.HookCtx) {
+	consumer, ok := b.owners[ctx.Domain.(sim.Port)]
+	if !ok {
+		return
+	}
+	switch ctx.Pos {
+	case sim.HookPosPortMsgSend:
+		b.used[consumer]++
+		b.Admitted[consumer]++
+		if m, ok := ctx.Item.(*msg.DemoMessage); ok {
+			b.priority(m.Priority).Admitted++
+		}
+		if b.used[consumer] > b.Peak[consumer] {
+			b.Peak[consumer] = b.used[consumer]
+		}
+		if shared := b.sharedUsed(); shared > b.PeakShared {
+			b.PeakShared = shared
+		}
+	case sim.HookPosPortMsgRetrieve:
+		b.used[consumer]--
+	}
+}
+
+// sharedUsed returns the messages held beyond the reservations
+func (b *SharedBuffer) sharedUsed() int {
+	total := 0
+	for _, n := range b.used {
+		if n > b.Reserve {
+			total += n - b.Reserve
+		}
+	}
+	return total
+}
+
+// Admits reports whether the policy leaves room for another message for the
+// consumer
+func (b *SharedBuffer) Admits(consumer string) bool {
+	held := b.used[consumer] - b.Reserve
+	if held < 0 {
+		return true
+	}
+	free := b.Shared - b.sharedUsed()
+	switch b.Policy {
+	case "static":
+		return held < b.Shared/b.consumers
+	case "dynamic":
+		return held < free
+	default:
+		return free > 0
+	}
+}
+
+// Evict pushes out the latest queued message of the lowest priority below
+// that of msg from the queues of its consumer, which leaves room for msg. It
+// returns false under fifo admission or if no queued message has a lower
+// priority.
+func (b *SharedBuffer) Evict(now sim.VTimeInSec, m *msg.DemoMessage) bool {
+	if b.Admission != "priority" {
+		return false
+	}
+	c := b.holders[m.Destination]
+	if c == nil {
+		return false
+	}
+	victim, port := c.EvictBelow(now, m.Priority)
+	if victim == nil {
+		return false
+	}
+	b.used[c.Name()]--
+	b.Evicted[c.Name()]++
+	b.priority(victim.Priority).Evicted++
+	b.ledger.Lose()
+	b.inversions.Forget(port, victim)
+	victim.Release()
+	return true
+}
+
+// Drop counts a message dropped on arrival for lack of room
+func (b *SharedBuffer) Drop(m *msg.DemoMessage) {
+	b.Dropped[m.Destination]++
+	b.priority(m.Priority).Dropped++
+}
+
+// String describes the room of the consumers and how it is shared
+func (b *SharedBuffer) String() string {
+	return fmt.Sprintf("%d reserved per consumer, %d shared (%s), drops without room", b.Reserve, b.Shared, b.Policy)
+}
+
+// TotalDropped returns the number of messages dropped for lack of room. It
+// is safe to call on a nil buffer.
+func (b *SharedBuffer) TotalDropped() int {
+	if b == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range b.Dropped {
+		total += n
+	}
+	return total
+}
+
+// TotalEvicted returns the number of queued messages pushed out by arrivals
+// of a higher priority. It is safe to call on a nil buffer.
+func (b *SharedBuffer) TotalEvicted() int {
+	if b == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range b.Evicted {
+		total += n
+	}
+	return total
+}
+
+// Print writes the messages every consumer was sent and lost for lack of
+// room, and how much of the buffer it held at most
+func (b *SharedBuffer) Print(out EventSink, consumers []string) {
+	out.Println("=== Shared Buffer ===")
+	out.Printf("Policy:            %s\n", b.Policy)
+	if b.Admission == "priority" {
+		out.Printf("Admission:         priority, %d messages evicted\n", b.TotalEvicted())
+	}
+	out.Printf("Size:              %d messages (%d reserved per consumer, %d shared)\n",
+		b.Reserve*b.consumers+b.Shared, b.Reserve, b.Shared)
+	out.Printf("Peak shared use:   %d messages\n", b.PeakShared)
+	out.Printf("%-16s %8s %8s %7s %6s\n", "Consumer", "Admitted", "Dropped", "Drop%", "Peak")
+	for _, name := range consumers {
+		out.Printf("%-16s %8d %8d %6.1f%% %6d\n", name, b.Admitted[name], b.Dropped[name],
+			percent(b.Dropped[name], b.Admitted[name]+b.Dropped[name]), b.Peak[name])
+	}
+	if len(b.Priorities) > 1 {
+		out.Printf("%-16s %8s %8s %8s %7s\n", "Priority", "Admitted", "Dropped", "Evicted", "Lost%")
+		for _, p := range b.priorityLevels() {
+			l := b.Priorities[p]
+			out.Printf("%-16d %8d %8d %8d %6.1f%%\n", p, l.Admitted, l.Dropped, l.Evicted, l.LossRate())
+		}
+	}
+}
+
+// priorityLevels returns the priorities of the messages offered to the
+// buffer, the most urgent first
+func (b *SharedBuffer) priorityLevels() []int {
+	var levels []int
+	for p := range b.Priorities {
+		levels = append(levels, p)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(levels)))
+	return levels
+}
+
+// BufferSharingResult is the outcome of a run with one sharing policy
+type BufferSharingResult struct {
+	Policy     string
+	Produced   int
+	Consumed   int
+	Dropped    map[string]int // Messages dropped for every consumer
+	Admitted   map[string]int // Messages sent to every consumer
+	P99Latency float64
+	Completion sim.VTimeInSec // Time the last message was consumed
+	Consumers  []string
+	TotalDrops int
+	PeakShared int
+}
+
+// DropRate returns the share of the messages for a consumer, or for all of
+// them if consumer is empty, that were dropped
+func (r BufferSharingResult) DropRate(consumer string) float64 {
+	if consumer == "" {
+		return percent(r.TotalDrops, r.Produced)
+	}
+	return percent(r.Dropped[consumer], r.Admitted[consumer]+r.Dropped[consumer])
+}
+
+// RunBufferSharing runs the same workload once per sharing policy of the
+// shared buffer and returns the results of all runs. The runs are silent.
+func RunBufferSharing(cfg *Config) ([]BufferSharingResult, error) {
+	// All runs must see the same traffic
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var results []BufferSharingResult
+	for _, policy := range bufferPolicies {
+		runCfg := *cfg
+		runCfg.Seed = seed
+		runCfg.BufferPolicy = policy
+		runCfg.Sink = NullSink{}
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		err = simulation.Run()
+		if err != nil {
+			return nil, fmt.Errorf("%s policy: %w", policy, err)
+		}
+
+		buffer := simulation.routing.sharedBuffer
+		results = append(results, BufferSharingResult{
+			Policy:     policy,
+			Produced:   simulation.stats.Produced,
+			Consumed:   simulation.stats.Consumed,
+			Dropped:    buffer.Dropped,
+			Admitted:   buffer.Admitted,
+			P99Latency: simulation.stats.LatencyPercentile(99),
+			Completion: simulation.Completion(),
+			Consumers:  simulation.consumerNames,
+			TotalDrops: buffer.TotalDropped(),
+			PeakShared: buffer.PeakShared,
+		})
+	}
+	return results, nil
+}
+
+// PrintBufferSharing writes the drop rate of every policy, overall and for
+// every consumer
+func PrintBufferSharing(out EventSink, results []BufferSharingResult) {
+	out.Println("=== Buffer Sharing ===")
+	if len(results) == 0 {
+		return
+	}
+	out.Printf("%-8s %9s %9s %8s %7s", "Policy", "Produced", "Consumed", "Dropped", "Drop%")
+	for _, name := range results[0].Consumers {
+		out.Printf(" %10s", name)
+	}
+	out.Printf(" %12s %12s\n", "p99 latency", "Peak shared")
+	for _, r := range results {
+		out.Printf("%-8s %9d %9d %8d %6.1f%%", r.Policy, r.Produced, r.Consumed, r.TotalDrops, r.DropRate(""))
+		for _, name := range r.Consumers {
+			out.Printf(" %9.1f%%", r.DropRate(name))
+		}
+		out.Printf(" %10.2f s %12d\n", r.P99Latency, r.PeakShared)
+	}
+}
+
+// BufferAdmissionResult is the outcome of a run with one admission of the
+// shared buffer
+type BufferAdmissionResult struct {
+	Admission  string
+	Produced   int
+	Consumed   int
+	Dropped    int
+	Evicted    int
+	Priorities map[int]*PriorityLoss
+	Levels     []int // Priorities offered to the buffer, the most urgent first
+	P99Latency float64
+}
+
+// LossRate returns the share of the messages of a priority that were lost
+func (r BufferAdmissionResult) LossRate(priority int) float64 {
+	if l := r.Priorities[priority]; l != nil {
+		return l.LossRate()
+	}
+	return 0
+}
+
+// RunBufferAdmission runs the same workload once per admission of the shared
+// buffer and returns the results of all runs. The runs are silent.
+func RunBufferAdmission(cfg *Config) ([]BufferAdmissionResult, error) {
+	// All runs must see the same traffic
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var results []BufferAdmissionResult
+	for _, admission := range bufferAdmissions {
+		runCfg := *cfg
+		runCfg.Seed = seed
+		runCfg.BufferAdmission = admission
+		runCfg.Sink = NullSink{}
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		err = simulation.Run()
+		if err != nil {
+			return nil, fmt.Errorf("%s admission: %w", admission, err)
+		}
+
+		buffer := simulation.routing.sharedBuffer
+		results = append(results, BufferAdmissionResult{
+			Admission:  admission,
+			Produced:   simulation.stats.Produced,
+			Consumed:   simulation.stats.Consumed,
+			Dropped:    buffer.TotalDropped(),
+			Evicted:    buffer.TotalEvicted(),
+			Priorities: buffer.Priorities,
+			Levels:     buffer.priorityLevels(),
+			P99Latency: simulation.stats.LatencyPercentile(99),
+		})
+	}
+	return results, nil
+}
+
+// PrintBufferAdmission writes the messages every admission lost, overall and
+// for every priority
+func PrintBufferAdmission(out EventSink, results []BufferAdmissionResult) {
+	out.Println("=== Buffer Admission ===")
+	if len(results) == 0 {
+		return
+	}
+	out.Printf("%-9s %9s %9s %8s %8s", "Admission", "Produced", "Consumed", "Dropped", "Evicted")
+	for _, p := range results[0].Levels {
+		out.Printf(" %9s", fmt.Sprintf("P%d lost", p))
+	}
+	out.Printf(" %12s\n", "p99 latency")
+	for _, r := range results {
+		out.Printf("%-9s %9d %9d %8d %8d", r.Admission, r.Produced, r.Consumed, r.Dropped, r.Evicted)
+		for _, p := range results[0].Levels {
+			out.Printf(" %8.1f%%", r.LossRate(p))
+		}
+		out.Printf(" %10.2f s\n", r.P99Latency)
+	}
+}
+
+// validateSharedBuffer checks the sizes, the policy, and the admission of the
+// shared buffer, and that only the distributor sends messages into it
+func (c *Config) validateSharedBuffer() error {
+	if c.SharedBuffer < 0 || c.BufferReserve < 0 {
+		return fmt.Errorf("shared-buffer and buffer-reserve must not be negative")
+	}
+	known := false
+	for _, policy := range bufferPolicies {
+		known = known || policy == c.BufferPolicy
+	}
+	if !known {
+		return fmt.Errorf("unknown buffer-policy %q", c.BufferPolicy)
+	}
+	known = false
+	for _, admission := range bufferAdmissions {
+		known = known || admission == c.BufferAdmission
+	}
+	if !known {
+		return fmt.Errorf("unknown buffer-admission %q", c.BufferAdmission)
+	}
+	if c.SharedBuffer == 0 {
+		if c.Scenario == "buffer-sharing" || c.Scenario == "buffer-admission" {
+			return fmt.Errorf("the %s scenario needs a shared-buffer", c.Scenario)
+		}
+		if c.BufferAdmission != "fifo" {
+			return fmt.Errorf("buffer-admission %s needs a shared-buffer", c.BufferAdmission)
+		}
+		return nil
+	}
+	if (c.BufferAdmission == "priority" || c.Scenario == "buffer-admission") && c.PriorityLevels < 2 {
+		// With a single priority no message may evict another
+		return fmt.Errorf("priority admission needs at least two priority-levels")
+	}
+	if c.DistributorDepth > 1 || c.WorkPool || c.Steal {
+		return fmt.Errorf("a shared buffer needs a single distributor and cannot be combined with a work pool or work stealing")
+	}
+	if c.Multicast > 0 || len(c.Topics) > 0 || c.RetentionSize > 0 {
+		return fmt.Errorf("a shared buffer cannot be combined with multicast, topics, or retention")
+	}
+	if c.ConsumerMode == "batch" {
+		// A batch that does not fit in the room of its consumer never fills
+		return fmt.Errorf("a shared buffer cannot be combined with batch consumers")
+	}
+	return nil
+}
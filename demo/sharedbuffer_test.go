@@ -0,0 +1,172 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestSharedBufferPolicies verifies how much of the shared region every
+// policy lets a consumer take beyond its reservation
+func TestSharedBufferPolicies(t *testing.T) {
+	consumers := []string{"Consumer1", "Consumer2", "Consumer3"}
+	for _, c := range []struct {
+		policy string
+		want   int // Messages Consumer1 may hold while the others hold their reservations
+	}{
+		{"static", 2 + 2},
+		{"shared", 2 + 6},
+		{"dynamic", 2 + 3},
+	} {
+		b := NewSharedBuffer(c.policy, 2, 6, consumers)
+		b.used["Consumer2"], b.used["Consumer3"] = 2, 2
+		held := 0
+		for b.Admits("Consumer1") {
+			b.used["Consumer1"]++
+			held++
+		}
+		if held != c.want {
+			t.Errorf("Expected the %s policy to let Consumer1 hold %d messages, got %d", c.policy, c.want, held)
+		}
+		if !b.Admits("Consumer2") && c.policy != "shared" {
+			t.Errorf("Expected the %s policy to leave Consumer2 room", c.policy)
+		}
+	}
+}
+
+// TestSharedBufferDropsUnderSkew verifies that a consumer too slow for its
+// traffic loses messages at the distributor, and that the fuller the sharing
+// the fewer it loses, at the expense of its peers under the shared policy
+func TestSharedBufferDropsUnderSkew(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 300
+	cfg.Traffic = "bursty"
+	cfg.ConsumeIntervals = map[string]float64{"Consumer1": 20, "Consumer2": 3, "Consumer3": 2}
+	cfg.SharedBuffer = 6
+	cfg.Scenario = "buffer-sharing"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := RunBufferSharing(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	rates := make(map[string]BufferSharingResult)
+	for _, r := range results {
+		rates[r.Policy] = r
+		if r.TotalDrops == 0 || r.Consumed+r.TotalDrops != r.Produced {
+			t.Errorf("Expected the %s policy to drop messages and consume the rest, got %+v", r.Policy, r)
+		}
+	}
+	if !(rates["static"].DropRate("Consumer1") >= rates["dynamic"].DropRate("Consumer1") &&
+		rates["dynamic"].DropRate("Consumer1") >= rates["shared"].DropRate("Consumer1")) {
+		t.Errorf("Expected Consumer1 to lose fewer messages the more it may share, got %.1f%%, %.1f%%, and %.1f%%",
+			rates["static"].DropRate("Consumer1"), rates["dynamic"].DropRate("Consumer1"), rates["shared"].DropRate("Consumer1"))
+	}
+	if rates["static"].Dropped["Consumer2"] > 0 || rates["shared"].Dropped["Consumer2"] == 0 {
+		t.Errorf("Expected Consumer2 to lose messages only when Consumer1 may take the whole region, got %v and %v",
+			rates["static"].Dropped, rates["shared"].Dropped)
+	}
+	
+	cfg.Scenario = ""
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if c := simulation.Conservation(); !c.Holds() || c.NoRoom != rates["dynamic"].TotalDrops {
+		t.Errorf("Expected the dropped messages to be accounted for, got %+v", c)
+	}
+}
+
+// TestSharedBufferValidation verifies the sizes and policies accepted, and
+// that the buffer is only combined with features sending through the
+// distributor's checks
+func TestSharedBufferValidation(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		apply func(cfg *Config)
+	}{
+		{"negative region", func(cfg *Config) { cfg.SharedBuffer = -1 }},
+		{"unknown policy", func(cfg *Config) { cfg.BufferPolicy = "greedy" }},
+		{"unknown admission", func(cfg *Config) { cfg.BufferAdmission = "random" }},
+		{"priority admission with one priority", func(cfg *Config) { cfg.BufferAdmission = "priority" }},
+		{"priority admission without region", func(cfg *Config) {
+			cfg.SharedBuffer, cfg.BufferAdmission, cfg.PriorityLevels = 0, "priority", 3
+		}},
+		{"scenario without region", func(cfg *Config) { cfg.SharedBuffer, cfg.Scenario = 0, "buffer-sharing" }},
+		{"distributor tree", func(cfg *Config) { cfg.DistributorDepth = 2 }},
+		{"multicast", func(cfg *Config) { cfg.Multicast = 0.5 }},
+		{"batch consumers", func(cfg *Config) { cfg.ConsumerMode = "batch" }},
+	} {
+		cfg := DefaultConfig()
+		cfg.SharedBuffer = 6
+		c.apply(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected %s to be rejected", c.name)
+		}
+	}
+	
+	cfg := DefaultConfig()
+	cfg.SharedBuffer = 6
+	cfg.BufferReserve = 1
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, consumer := range cfg.TopologySpec(nil).Consumers {
+		if consumer.QueueCapacity != 7 {
+			t.Errorf("Expected the RX queues to hold what a consumer may take, got %d", consumer.QueueCapacity)
+		}
+	}
+}
+
+// TestSharedBufferPriorityAdmission verifies that priority admission trades
+// the losses of the lower priorities for those of the highest one, and that
+// the evicted messages are accounted for
+func TestSharedBufferPriorityAdmission(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 300
+	cfg.Traffic = "bursty"
+	cfg.ConsumeIntervals = map[string]float64{"Consumer1": 20, "Consumer2": 3, "Consumer3": 2}
+	cfg.SharedBuffer = 6
+	cfg.PriorityLevels = 3
+	cfg.Scenario = "buffer-admission"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := RunBufferAdmission(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	fifo, priority := results[0], results[1]
+	if fifo.Evicted != 0 || priority.Evicted == 0 {
+		t.Errorf("Expected only priority admission to evict messages, got %d and %d", fifo.Evicted, priority.Evicted)
+	}
+	if fifo.LossRate(2) == 0 || priority.LossRate(2) != 0 {
+		t.Errorf("Expected priority admission to protect the highest priority, got %.1f%% and %.1f%%",
+			fifo.LossRate(2), priority.LossRate(2))
+	}
+	if priority.LossRate(0) < fifo.LossRate(0) {
+		t.Errorf("Expected the lowest priority to lose more under priority admission, got %.1f%% and %.1f%%",
+			fifo.LossRate(0), priority.LossRate(0))
+	}
+	
+	cfg.Scenario = ""
+	cfg.BufferAdmission = "priority"
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if c := simulation.Conservation(); !c.Holds() || c.Evicted != priority.Evicted || c.NoRoom != priority.Dropped {
+		t.Errorf("Expected the evicted messages to be accounted for, got %+v", c)
+	}
+}
@@ -0,0 +1,1636 @@
+// Package demo builds the simulation of the demo from a configuration and
+// runs it, whole, in segments, or as the scenarios that compare several runs.
+package demo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// Simulation is the producer, distributor, and consumers of one run, wired
+// together on their own engine as described by a Config
+type Simulation struct {
+	cfg           *Config
+	out           EventSink
+	engine        sim.Engine
+	stats         *Stats
+	trafficModel  producer.Traffic
+	matrix        *TrafficMatrix // Nil unless the traffic follows a matrix
+	producers     []*producer.Producer
+	spec          TopologySpec
+	distributor   *distributor.Distributor
+	tree          *DistributorTree // Root and regional distributors, the root alone at depth 1
+	deadLetters   *DeadLetterSink
+	timeline      *PortTimeline // Nil unless a port timeline is exported
+	backpressure  *BackpressureTracker
+	verifier      *Verifier
+	sizeService   *SizeService        // Nil unless sizes and service times are drawn
+	reorder       *ReorderBuffer      // Nil unless messages are delivered in order
+	timestamps    *TimestampCorrector // Nil unless producer clocks are skewed
+	ledger        *Ledger
+	fingerprint   *TrafficFingerprint
+	watchdog      *Watchdog           // Nil unless stall detection is enabled
+	sampler       *QueueSampler       // Nil unless queue depths are sampled
+	metrics       *PrometheusExporter // Nil unless metrics are served
+	control       *Controller         // Nil unless the run is controlled
+	chromeTrace   *ChromeTrace        // Nil unless a Chrome trace is written
+	eventDB       *EventDB            // Nil unless the message events are recorded
+	visualTracer  *VisualTracer       // Nil unless tasks are traced for Daisen
+	messageFlow   *MessageFlow        // Nil unless Mermaid diagrams are written
+	topology      *Topology           // Connections as they were made
+	network       *Network            // Nil unless the consumers sit on a switch network
+	workPool      *WorkPool           // Nil unless the consumers pull from a shared queue
+	stealing      *WorkStealing       // Nil unless idle consumers steal work
+	arbitration   *ArbitrationAudit   // Nil unless the grants of the link arbiters are recorded
+	drain         *DrainLimit         // Nil unless the drain phase has a timeout
+	inversions    *InversionDetector  // Nil unless messages have several priorities
+	consumerNames []string
+	consumers     []*consumer.Consumer
+	models        map[string]*consumerModels // Service times, queueing, and pauses of every consumer
+	middleware    *middleware.Chain           // Middlewares that intercept the messages, none unless added
+	faults        *FaultInjector             // Nil unless faults are injected
+	attribution   *LatencyAttribution        // Nil unless latencies are broken down by decision
+	retransmitter *Retransmitter             // Nil unless producers send unacknowledged messages again
+	auditor       *DeliveryAuditor           // Nil unless deliveries are audited
+	queueDepths   map[string]func() int
+	removals      []*Removal
+	errors        *ErrorLog
+	components    []component.Lifecycle       // Components driven through the phases of the run
+	checkpoints   []*checkpointHook // Checkpoints to save
+	restored      bool              // Resumed from a checkpoint
+	traceStreams  []*TraceStream    // Trace files read by the producers
+	routing       *routingModels    // Policies and trackers of the root distributor
+}
+
+// routingModels are the policies and trackers the root distributor is
+// created with, each nil unless its feature is enabled
+type routingModels struct {
+	timestamps     *TimestampCorrector
+	coalescer      *Coalescer
+	retention      *Retention
+	balancer       producer.DestinationPolicy // Overrides the producer's destination choice
+	seed           int64             // Seeds the balancer, zero seeds it from the clock
+	groups         map[string][]string
+	membership     *GroupMembership
+	subscriptions  *Subscriptions
+	consumerGroups *ConsumerGroups
+	overflow       *OverflowRouting
+	health         *HealthChecker
+	windows        *DestinationWindows
+	rules          *RoutingRules
+	sharedBuffer   *SharedBuffer
+	workPool       *WorkPool
+	middleware     *middleware.Chain
+}
+
+// options returns the options that hand the enabled models to the root
+// distributor
+func (r *routingModels) options() []distributor.Option {
+	opts := []distributor.Option{
+		distributor.WithGroups(r.groups),
+		distributor.WithMiddleware(r.middleware),
+	}
+	if r.timestamps != nil {
+		opts = append(opts, distributor.WithTimestamps(r.timestamps))
+	}
+	if r.coalescer != nil {
+		opts = append(opts, distributor.WithCoalescer(r.coalescer))
+	}
+	if r.retention != nil {
+		opts = append(opts, distributor.WithRetention(r.retention))
+	}
+	if r.balancer != nil {
+		opts = append(opts, distributor.WithBalancer(r.balancer))
+		if r.seed != 0 {
+			opts = append(opts, distributor.WithSeed(r.seed))
+		}
+	}
+	if r.membership != nil {
+		opts = append(opts, distributor.WithMembership(r.membership))
+	}
+	if r.subscriptions != nil {
+		opts = append(opts, distributor.WithTopics(r.subscriptions))
+	}
+	if r.consumerGroups != nil {
+		opts = append(opts, distributor.WithConsumerGroups(r.consumerGroups))
+	}
+	if r.overflow != nil {
+		opts = append(opts, distributor.WithOverflow(r.overflow))
+	}
+	if r.health != nil {
+		opts = append(opts, distributor.WithHealth(r.health))
+	}
+	if r.windows != nil {
+		opts = append(opts, distributor.WithWindows(r.windows))
+	}
+	if r.rules != nil {
+		opts = append(opts, distributor.WithRules(r.rules))
+	}
+	if r.sharedBuffer != nil {
+		opts = append(opts, distributor.WithSharedBuffer(r.sharedBuffer))
+	}
+	if r.workPool != nil {
+		opts = append(opts, distributor.WithWorkPool(r.workPool.inputPort))
+	}
+	return opts
+}
+
+// NewSimulation builds the components of a run and connects them
+func NewSimulation(cfg *Config) (*Simulation, error) {
+	trafficModel := cfg.TrafficModel()
+	out := cfg.Out()
+
+	// Create the serial or parallel simulation engine. With a drain timeout,
+	// the engine is wrapped to drop the events past the timeout.
+	engine := NewEngine(cfg.Engine)
+	stats := NewStats()
+	stats.warmup = sim.VTimeInSec(cfg.Warmup)
+	engine.AcceptHook(stats) // Count the events handled by the engine
+	var drain *DrainLimit
+	if cfg.DrainTimeout > 0 {
+		drain = NewDrainLimit(engine, sim.VTimeInSec(cfg.Cycles), sim.VTimeInSec(cfg.DrainTimeout))
+		engine = drain
+	}
+	// Count the errors of the run. A strict run is aborted on unexpected
+	// ones by an engine that stops scheduling events.
+	errorLog := NewErrorLog(out)
+	if cfg.StrictErrors {
+		expected, err := parseErrorKinds(cfg.ExpectedErrors)
+		if err != nil {
+			return nil, err
+		}
+		engine = errorLog.Strict(engine, expected)
+	}
+
+	// Define the producers and consumers
+	var matrix *TrafficMatrix
+	if cfg.TrafficMatrixFile != "" {
+		var err error
+		matrix, err = LoadTrafficMatrix(cfg.TrafficMatrixFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	spec := cfg.TopologySpec(matrix)
+	consumerNames := spec.ConsumerNames()
+
+	// The policies and trackers of the root distributor, added by the
+	// features below
+	routing := &routingModels{groups: cfg.MulticastGroups()}
+
+	// Skewed producers stamp their messages with their own clocks
+	var timestamps *TimestampCorrector
+	if len(cfg.ClockSkews) > 0 {
+		skews := make(map[string]sim.VTimeInSec)
+		for _, ps := range spec.Producers {
+			skews[ps.Name] = sim.VTimeInSec(cfg.ClockSkews[ps.Name])
+		}
+		for name := range cfg.ClockSkews {
+			if _, ok := skews[name]; !ok {
+				return nil, fmt.Errorf("unknown producer %q in clock-skews", name)
+			}
+		}
+		timestamps = NewTimestampCorrector(skews)
+		routing.timestamps = timestamps
+	}
+
+	var backpressure *BackpressureTracker
+	if cfg.CongestionThreshold > 0 {
+		backpressure = NewBackpressureTracker(cfg.CongestionThreshold, out)
+	}
+
+	// Send messages again whose ACK is overdue, and discard the duplicates
+	var retransmitter *Retransmitter
+	if cfg.RetransmitTimeout > 0 {
+		retransmitter = NewRetransmitter(sim.VTimeInSec(cfg.RetransmitTimeout), cfg.RetransmitLimit)
+	}
+
+	// Follow every message from its producer to its consumers
+	var auditor *DeliveryAuditor
+	if cfg.AuditDelivery {
+		auditor = NewDeliveryAuditor()
+	}
+
+	// Middlewares are added to the chain before the run starts
+	chain := &middleware.Chain{}
+	routing.middleware = chain
+
+	deadLetters := NewDeadLetterSink("DeadLetterSink", engine)
+	deadLetters.errors = errorLog
+	if cfg.CoalesceTime > 0 {
+		routing.coalescer = NewCoalescer(engine, cfg.CoalesceCount, sim.VTimeInSec(cfg.CoalesceTime))
+	}
+	if cfg.RetentionSize > 0 {
+		routing.retention = NewRetention(cfg.RetentionSize, sim.VTimeInSec(cfg.RetentionWindow))
+	}
+
+	verifier := NewVerifier(out)
+	var sizeService *SizeService
+	if cfg.SizeSpread > 0 {
+		sizeService = NewSizeService(cfg.SizeSpread, cfg.SizeCorrelation)
+	}
+	var reorder *ReorderBuffer
+	if cfg.InOrder {
+		reorder = NewReorderBuffer(sim.VTimeInSec(cfg.ReorderTimeout), out)
+	}
+
+	// Options of every consumer, added by the features below
+	consumerOpts := make(map[string][]consumer.Option)
+
+	// Consumers added during the run join the queue depths
+	queueDepths := make(map[string]func() int)
+	if cfg.RoutePolicy == "queue-p2c" {
+		routing.balancer = QueueAwareDestination{
+			QueueLen: func(name string) int { return queueDepths[name]() },
+		}
+		routing.seed = cfg.Seed
+	}
+
+	// The root distributor fans multicast messages out to the members of
+	// their groups
+	if err := checkGroups(cfg.MulticastGroups(), consumerNames); err != nil {
+		return nil, err
+	}
+
+	// Consumers that join and leave the groups change their members
+	if len(cfg.Joins) > 0 || len(cfg.Leaves) > 0 {
+		if err := checkMemberships(cfg, consumerNames); err != nil {
+			return nil, err
+		}
+		routing.membership = NewGroupMembership()
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithMemberships(
+				cfg.Joins[name], sim.VTimeInSec(cfg.JoinAt[name]), cfg.GroupsLeft(name), sim.VTimeInSec(cfg.LeaveAt[name])))
+		}
+	}
+
+	// With topics, the root distributor copies every message to the
+	// subscribers of its topic instead
+	if len(cfg.Topics) > 0 {
+		if err := checkSubscriptions(cfg, consumerNames); err != nil {
+			return nil, err
+		}
+		routing.subscriptions = NewSubscriptions(cfg.Topics)
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithSubscriptions(
+				cfg.Subscriptions[name], cfg.TopicsLeft(name), sim.VTimeInSec(cfg.UnsubscribeAt[name])))
+		}
+	}
+
+	// Consumer groups share a destination name, the root distributor assigns
+	// their messages to the members
+	if len(cfg.ConsumerGroups) > 0 {
+		if err := checkGroups(cfg.ConsumerGroups, consumerNames); err != nil {
+			return nil, err
+		}
+		routing.consumerGroups = NewConsumerGroups(cfg.ConsumerGroups, cfg.Assignment, cfg.Partitions)
+		for _, name := range consumerNames {
+			if at, ok := cfg.LeaveGroupAt[name]; ok {
+				consumerOpts[name] = append(consumerOpts[name], consumer.WithGroupLeave(sim.VTimeInSec(at)))
+			}
+		}
+	}
+
+	// Reroute the messages of overloaded consumers to the overflow consumer
+	if cfg.OverflowConsumer != "" {
+		known := false
+		for _, name := range consumerNames {
+			known = known || name == cfg.OverflowConsumer
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown overflow consumer %q", cfg.OverflowConsumer)
+		}
+		routing.overflow = NewOverflowRouting(cfg.OverflowConsumer, cfg.OverflowThreshold,
+			func(name string) int { return queueDepths[name]() })
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithOverflow(routing.overflow))
+		}
+	}
+
+	// Reroute the messages of consumers that stopped sending heartbeats
+	if cfg.HealthInterval > 0 {
+		routing.health = NewHealthChecker(sim.VTimeInSec(cfg.HealthInterval),
+			sim.VTimeInSec(cfg.HealthTimeout), sim.VTimeInSec(cfg.Cycles))
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name],
+				consumer.WithHeartbeats(routing.health.Interval, routing.health.stopTime))
+		}
+	}
+
+	// Consumers discard the duplicates of retransmitted messages
+	if retransmitter != nil {
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithRetransmitter(retransmitter))
+		}
+	}
+
+	if auditor != nil {
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithAuditor(auditor))
+		}
+	}
+
+	// Break the latencies down by the decisions taken for the messages
+	var attribution *LatencyAttribution
+	if cfg.Attribution {
+		attribution = NewLatencyAttribution()
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithAttribution(attribution))
+		}
+	}
+
+	// Route messages by their content before anything else picks a consumer
+	if len(cfg.RoutingRules) > 0 {
+		if err := checkRoutingRules(cfg.RoutingRules, consumerNames); err != nil {
+			return nil, err
+		}
+		rules, err := NewRoutingRules(cfg.RoutingRules)
+		if err != nil {
+			return nil, err
+		}
+		routing.rules = rules
+	}
+
+	// Hold the RX queues of the consumers in one shared buffer pool
+	if cfg.SharedBuffer > 0 {
+		buffer := NewSharedBuffer(cfg.BufferPolicy, cfg.BufferReserve, cfg.SharedBuffer, consumerNames)
+		buffer.Admission = cfg.BufferAdmission
+		routing.sharedBuffer = buffer
+	}
+
+	isConsumer := make(map[string]bool)
+	for _, name := range consumerNames {
+		isConsumer[name] = true
+	}
+	for name := range cfg.ServiceDists {
+		if !isConsumer[name] {
+			return nil, fmt.Errorf("unknown consumer %q in service-dists", name)
+		}
+	}
+
+	// Limit the unacknowledged messages at every consumer, which acknowledge
+	// their messages to the distributor as well
+	if cfg.DestWindow > 0 || len(cfg.DestWindows) > 0 {
+		for name := range cfg.DestWindows {
+			if !isConsumer[name] {
+				return nil, fmt.Errorf("unknown consumer %q in dest-windows", name)
+			}
+		}
+		routing.windows = NewDestinationWindows(cfg.DestWindow, cfg.DestWindows)
+	}
+
+	// Take consumers down, stall distributors, and lose messages on the
+	// connections as the faults say
+	faults, err := cfg.Faults(consumerNames, rootDistributor, out)
+	if err != nil {
+		return nil, err
+	}
+	if routing.health != nil {
+		routing.health.faults = faults
+	}
+	if faults != nil {
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithFaults(faults))
+		}
+	}
+
+	// Record every message event and decision for SQL analysis or to
+	// explain a message
+	var eventDB *EventDB
+	if cfg.DBFile != "" {
+		eventDB = NewEventDB(engine)
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithEvents(eventDB))
+		}
+	}
+
+	// The distributor queues every message in the work pool, which hands
+	// them out to the consumers that pull
+	var workPool *WorkPool
+	if cfg.WorkPool {
+		capacity := cfg.PoolCapacity
+		if capacity == 0 {
+			for _, cs := range spec.Consumers {
+				capacity += cs.QueueCapacity
+			}
+		}
+		workPool = NewWorkPool("WorkPool", engine, capacity, len(consumerNames), out)
+		routing.workPool = workPool
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithWorkPool(workPool.workerPort))
+		}
+	}
+
+	// The producers send to the root distributor, which routes the messages
+	// down the tree of regional distributors if there is one
+	tree := NewDistributorTree(engine, consumerNames, cfg.DistributorDepth, cfg.DistributorFanOut,
+		func(name string) []distributor.Option {
+			opts := []distributor.Option{
+				distributor.WithFreq(cfg.Freq(name, cfg.DistributorFreq)),
+				distributor.WithCapacity(cfg.DistributorInCapacity),
+				distributor.WithStats(stats),
+				distributor.WithErrors(errorLog),
+				distributor.WithDeadLetters(deadLetters.inputPort),
+				distributor.WithLogger(out),
+			}
+			if faults != nil {
+				opts = append(opts, distributor.WithFaults(faults))
+			}
+			if eventDB != nil {
+				opts = append(opts, distributor.WithEvents(eventDB))
+			}
+			return opts
+		}, routing.options()...)
+	root := tree.Root
+	if routing.windows != nil {
+		for _, name := range consumerNames {
+			consumerOpts[name] = append(consumerOpts[name], consumer.WithWindowAcks(root.CtrlPort()))
+		}
+	}
+
+	// Create components with configurable stop time. With a trace file, the
+	// producer streams the trace instead of generating random traffic.
+	producers := make([]*producer.Producer, len(spec.Producers))
+	var traceStreams []*TraceStream
+	for i, ps := range spec.Producers {
+		opts := []producer.Option{
+			producer.WithFreq(cfg.Freq(ps.Name, cfg.ProducerFreq)),
+			producer.WithTraffic(trafficModel),
+			producer.WithDestinationPolicy(cfg.DestinationPolicy()),
+			producer.WithMulticast(cfg.Multicast, multicastTargets(cfg.MulticastGroups())),
+			producer.WithFlows(cfg.Flows),
+			producer.WithSize(cfg.MsgSize, cfg.SizeSpread, cfg.SizeCorrelation),
+			producer.WithMaxInFlight(cfg.MaxInFlight),
+			producer.WithTTL(sim.VTimeInSec(cfg.TTL)),
+			producer.WithPriorities(cfg.PriorityLevels),
+			producer.WithIDs(uint64(len(spec.Producers)), uint64(i)),
+			producer.WithClockSkew(sim.VTimeInSec(cfg.ClockSkews[ps.Name])),
+			producer.WithDestination(root.InputPort()),
+			producer.WithStats(stats),
+			producer.WithMiddleware(chain),
+			producer.WithLogger(out),
+		}
+		if ps.Probability > 0 {
+			opts = append(opts, producer.WithTraffic(&producer.RandomTraffic{Probability: ps.Probability}))
+		}
+		if matrix != nil {
+			// The matrix decides both when and where to send
+			traffic := matrix.Traffic(i)
+			opts = append(opts, producer.WithTraffic(traffic), producer.WithDestinationPolicy(traffic))
+		}
+		if len(cfg.Topics) > 0 {
+			opts = append(opts, producer.WithTopics(cfg.Topics))
+		}
+		if cfg.WindowSize > 0 {
+			opts = append(opts, producer.WithWindow(producer.NewWindow(cfg.WindowSize, sim.VTimeInSec(cfg.WindowTargetRTT))))
+		}
+		if cfg.Seed != 0 {
+			opts = append(opts, producer.WithSeed(cfg.Seed+int64(i)))
+		}
+		if backpressure != nil {
+			opts = append(opts, producer.WithBackpressure(backpressure))
+		}
+		if retransmitter != nil {
+			opts = append(opts, producer.WithRetransmitter(retransmitter))
+		}
+		if auditor != nil {
+			opts = append(opts, producer.WithAuditor(auditor))
+		}
+
+		if cfg.TraceFile != "" {
+			stream, err := OpenTrace(cfg.TraceFile, cfg.TraceWindow)
+			if err != nil {
+				return nil, err
+			}
+			traceStreams = append(traceStreams, stream)
+			producers[i] = producer.NewStreamingTraceProducer(ps.Name, engine, stream, cfg.TraceWindow,
+				sim.VTimeInSec(cfg.Cycles), opts...).Producer
+		} else {
+			// The producer discovers the consumers from the distributor
+			opts = append(opts, producer.WithDiscovery(root.CtrlPort(), sim.VTimeInSec(cfg.RegistrationPeriod)))
+			producers[i] = producer.New(ps.Name, engine, sim.VTimeInSec(cfg.Cycles), opts...)
+		}
+	}
+	for name := range cfg.Frequencies {
+		known := false
+		for _, d := range tree.Distributors() {
+			known = known || d.Name() == name
+		}
+		for _, c := range consumerNames {
+			known = known || c == name
+		}
+		for _, p := range producers {
+			known = known || p.Name() == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown component %q in freqs", name)
+		}
+	}
+
+	ackPorts := make(map[string]sim.Port)
+	for _, p := range producers {
+		ackPorts[p.Name()] = p.CtrlPort()
+	}
+	if routing.sharedBuffer != nil {
+		for _, name := range consumerNames {
+			routing.sharedBuffer.Track(root.OutputPort(name), name)
+		}
+	}
+
+	// Create consumers with fixed consumption rate
+	consumers := make([]*consumer.Consumer, len(consumerNames))
+	models := make(map[string]*consumerModels)
+	for i, cs := range spec.Consumers {
+		name := cs.Name
+		models[name] = cfg.consumerModels(name, i, len(consumerNames))
+		opts := []consumer.Option{
+			consumer.WithFreq(cfg.Freq(name, cfg.ConsumerFreq)),
+			consumer.WithQueues(cfg.RxQueues, cs.QueueCapacity),
+			consumer.WithRegistry(tree.Leaf(name).CtrlPort(), sim.VTimeInSec(cfg.RegisterDelays[name])),
+			consumer.WithAckPorts(ackPorts),
+			consumer.WithStats(stats),
+			consumer.WithBackpressure(backpressure),
+			consumer.WithVerifier(verifier),
+			consumer.WithErrors(errorLog),
+			consumer.WithMiddleware(chain),
+			consumer.WithLogger(out),
+		}
+		opts = append(opts, cfg.consumerMode()...)
+		if sizeService != nil {
+			opts = append(opts, consumer.WithSizeService(sizeService))
+		}
+		if reorder != nil {
+			opts = append(opts, consumer.WithReorder(reorder))
+		}
+		if timestamps != nil {
+			opts = append(opts, consumer.WithTimestamps(timestamps))
+		}
+		opts = append(opts, models[name].options()...)
+		consumers[i] = consumer.New(name, engine, sim.VTimeInSec(cs.Interval), append(opts, consumerOpts[name]...)...)
+		queueDepths[name] = consumers[i].QueueDepth
+		if routing.coalescer != nil {
+			routing.coalescer.AddTarget(name, consumers[i])
+		}
+		if routing.sharedBuffer != nil {
+			routing.sharedBuffer.Hold(consumers[i])
+		}
+	}
+
+	// Connect the producers to the distributor, their destination is the
+	// distributor's input port (immediate hop). The topology records every
+	// connection for the Graphviz export.
+	topology := &Topology{}
+	topology.SetLinks(cfg.Link)
+	var arbitration *ArbitrationAudit
+	if cfg.ArbitrationAudit || cfg.GrantTraceFile != "" {
+		arbitration = NewArbitrationAudit()
+	}
+	topology.SetArbitration(func() Arbiter { return NewArbiter(cfg.Arbiter, cfg.ArbiterWeights) }, arbitration)
+	topology.SetFaults(faults)
+	inPorts := []sim.Port{root.InputPort()}
+	for _, p := range producers {
+		inPorts = append(inPorts, p.OutputPort())
+		topology.SetSendBuffer(p.OutputPort(), cfg.ProducerOutCapacity)
+	}
+	topology.Connect("ProducerToDistributor", engine, inPorts...)
+
+	// Connect every distributor of the tree to its regions
+	for _, region := range tree.Regions() {
+		uplink := tree.Uplink(region)
+		topology.SetSendBuffer(uplink, cfg.DistributorOutCapacity)
+		parent := uplink.Component().Name()
+		topology.Connect(fmt.Sprintf("%sTo%s", parent, region.Name()), engine, uplink, region.InputPort())
+	}
+
+	// Connect distributor to consumers, directly or over a switch network
+	var network *Network
+	if workPool != nil {
+		ports := []sim.Port{workPool.inputPort}
+		for _, name := range consumerNames {
+			topology.SetSendBuffer(root.OutputPort(name), cfg.DistributorOutCapacity)
+			ports = append(ports, root.OutputPort(name))
+		}
+		topology.Connect("DistributorToWorkPool", engine, ports...)
+		topology.SetSendBuffer(workPool.workerPort, len(consumers))
+		ports = []sim.Port{workPool.workerPort}
+		for _, consumer := range consumers {
+			ports = append(ports, consumer.RxPorts()...)
+		}
+		topology.Connect("WorkPoolToConsumers", engine, ports...)
+	} else if cfg.Network == "direct" {
+		for i, consumer := range consumers {
+			leaf := tree.Leaf(consumerNames[i])
+			topology.SetSendBuffer(leaf.OutputPort(consumerNames[i]), cfg.DistributorOutCapacity)
+			ports := append([]sim.Port{leaf.OutputPort(consumerNames[i])}, consumer.RxPorts()...)
+			topology.Connect(fmt.Sprintf("%sTo%s", leaf.Name(), consumerNames[i]), engine, ports...)
+		}
+	} else {
+		network = NewNetwork(cfg.Network, engine, len(consumers)+1, cfg.SwitchLatency, cfg.FlitSize)
+		outputPorts := make([]sim.Port, len(consumers))
+		for i, name := range consumerNames {
+			outputPorts[i] = root.OutputPort(name)
+		}
+		network.ConnectDevice(root.Name(), outputPorts...)
+		ports := append([]sim.Port{}, outputPorts...)
+		for i, consumer := range consumers {
+			network.ConnectDevice(consumerNames[i], consumer.RxPorts()...)
+			network.Track(consumerNames[i], consumer.RxPorts()...)
+			ports = append(ports, consumer.RxPorts()...)
+		}
+		network.Build()
+		topology.Record("Network", ports...)
+	}
+
+	// Connect the distributors to the dead-letter sink
+	var deadLetterPorts []sim.Port
+	for _, d := range tree.Distributors() {
+		deadLetterPorts = append(deadLetterPorts, d.DeadLetterPort())
+	}
+	topology.Connect("DistributorToDeadLetterSink", engine, append(deadLetterPorts, deadLetters.inputPort)...)
+
+	// Connect the control plane used for registration and discovery
+	var ctrlPorts []sim.Port
+	for _, d := range tree.Distributors() {
+		ctrlPorts = append(ctrlPorts, d.CtrlPort())
+	}
+	for _, p := range producers {
+		ctrlPorts = append(ctrlPorts, p.CtrlPort())
+	}
+	for _, consumer := range consumers {
+		ctrlPorts = append(ctrlPorts, consumer.CtrlPort())
+	}
+	topology.Connect("ControlPlane", engine, ctrlPorts...)
+
+	// Connect the consumers to each other to steal work
+	var stealing *WorkStealing
+	if cfg.Steal {
+		stealing = NewWorkStealing(cfg.StealThreshold)
+		connectPeers(topology, engine, consumers, stealing)
+	}
+	for _, overrides := range []map[string]float64{cfg.LinkLatencies, cfg.LinkBandwidths} {
+		for name := range overrides {
+			if !topology.HasConnection(name) {
+				return nil, fmt.Errorf("unknown connection %q in link-latencies or link-bandwidths", name)
+			}
+		}
+	}
+	if err := faults.Check(topology, consumerNames, tree.Distributors(), routing.windows); err != nil {
+		return nil, err
+	}
+	linked := make(map[string]bool)
+	for _, l := range topology.Links() {
+		for _, port := range l.ports {
+			linked[port.Name()] = true
+		}
+	}
+	for name := range cfg.ArbiterWeights {
+		if !linked[name] {
+			return nil, fmt.Errorf("unknown port %q in arbiter-weights, it must be plugged into a link", name)
+		}
+	}
+
+	// Count the messages held by the ports and connections of the data path
+	ledger := &Ledger{}
+	for _, p := range producers {
+		ledger.TrackOutput(p.OutputPort())
+	}
+	for _, d := range tree.Distributors() {
+		ledger.TrackInput(d.InputPort())
+		ledger.TrackOutput(d.DeadLetterPort())
+	}
+	for _, region := range tree.Regions() {
+		ledger.TrackOutput(tree.Uplink(region))
+	}
+	ledger.TrackInput(deadLetters.inputPort)
+	if workPool != nil {
+		ledger.TrackInput(workPool.inputPort)
+		ledger.TrackOutput(workPool.workerPort)
+	}
+	for i, consumer := range consumers {
+		ledger.TrackOutput(tree.Leaf(consumerNames[i]).OutputPort(consumerNames[i]))
+		for _, port := range consumer.RxPorts() {
+			ledger.TrackInput(port)
+		}
+	}
+
+	if drain != nil {
+		drain.ledger = ledger
+	}
+	if faults != nil {
+		faults.ledger = ledger
+	}
+	if routing.sharedBuffer != nil {
+		routing.sharedBuffer.ledger = ledger
+	}
+
+	// Fingerprint the generated traffic to compare runs
+	fingerprint := NewTrafficFingerprint()
+	for _, p := range producers {
+		p.OutputPort().AcceptHook(fingerprint)
+	}
+
+	// Detect stalls caused by missed wake-ups
+	var watchdog *Watchdog
+	if cfg.Watchdog > 0 {
+		watchdog = NewWatchdog("Watchdog", engine, sim.VTimeInSec(cfg.Watchdog), sim.VTimeInSec(cfg.Cycles), ledger, out)
+		for _, d := range tree.Distributors() {
+			watchdog.WatchPort(d.InputPort())
+		}
+		watchdog.WatchPort(deadLetters.inputPort)
+		if workPool != nil {
+			watchdog.WatchPort(workPool.inputPort)
+		}
+		for _, consumer := range consumers {
+			for _, port := range consumer.RxPorts() {
+				watchdog.WatchPort(port)
+			}
+		}
+		watchdog.WatchBuffer("Unacked", func() int {
+			unacked := 0
+			for _, p := range producers {
+				unacked += p.Unacked()
+			}
+			return unacked
+		})
+		watchdog.WatchBuffer("Retained", func() int {
+			if routing.retention == nil {
+				return root.Replaying()
+			}
+			return root.Replaying() + routing.retention.Len()
+		})
+	}
+
+	// Watch the shared FIFO buffers for priority inversions
+	var inversions *InversionDetector
+	if cfg.PriorityLevels > 1 {
+		inversions = NewInversionDetector(engine, sim.VTimeInSec(cfg.InversionThreshold), out)
+		for _, d := range tree.Distributors() {
+			inversions.Watch(d.InputPort())
+		}
+		for _, consumer := range consumers {
+			for _, port := range consumer.RxPorts() {
+				inversions.Watch(port)
+			}
+		}
+		if routing.sharedBuffer != nil {
+			routing.sharedBuffer.inversions = inversions
+		}
+	}
+
+	// Record the journey of every message for chrome://tracing
+	var chromeTrace *ChromeTrace
+	if cfg.TraceOutFile != "" {
+		chromeTrace = NewChromeTrace(engine)
+		for _, p := range producers {
+			chromeTrace.Track(p.OutputPort(), p.Name())
+		}
+		chromeTrace.Track(root.InputPort(), root.Name())
+		for i, consumer := range consumers {
+			for _, port := range consumer.RxPorts() {
+				chromeTrace.Track(port, consumerNames[i])
+			}
+		}
+	}
+
+	// Watch the message events and decisions
+	if eventDB != nil {
+		for _, p := range producers {
+			eventDB.Watch(p.OutputPort())
+		}
+		for _, d := range tree.Distributors() {
+			eventDB.Watch(d.InputPort())
+		}
+		for i, consumer := range consumers {
+			eventDB.Watch(tree.Leaf(consumerNames[i]).OutputPort(consumerNames[i]))
+			for _, port := range consumer.RxPorts() {
+				eventDB.Watch(port)
+			}
+		}
+		if workPool != nil {
+			eventDB.Watch(workPool.inputPort)
+			eventDB.Watch(workPool.workerPort)
+			workPool.eventDB = eventDB
+		}
+		if faults != nil {
+			faults.eventDB = eventDB
+		}
+	}
+
+	// Trace the tasks of the components and the links for Daisen
+	var visualTracer *VisualTracer
+	if cfg.VisualTraceFile != "" {
+		writer, err := NewDaisenTraceWriter(cfg.VisualTraceFile)
+		if err != nil {
+			return nil, err
+		}
+		visualTracer = NewVisualTracer(engine, writer)
+		for _, p := range producers {
+			visualTracer.Attach(p)
+		}
+		for _, d := range tree.Distributors() {
+			visualTracer.Attach(d)
+		}
+		for _, consumer := range consumers {
+			visualTracer.Attach(consumer)
+		}
+		for _, link := range topology.Links() {
+			visualTracer.Attach(link)
+		}
+	}
+
+	// Follow a sampled message for a Mermaid sequence diagram
+	var messageFlow *MessageFlow
+	if cfg.MermaidFile != "" {
+		messageFlow = NewMessageFlow(engine, cfg.MermaidMsg)
+		for _, p := range producers {
+			messageFlow.Watch(p.OutputPort())
+			messageFlow.Watch(p.CtrlPort())
+		}
+		for _, d := range tree.Distributors() {
+			messageFlow.Watch(d.InputPort())
+		}
+		for i, consumer := range consumers {
+			messageFlow.Watch(tree.Leaf(consumerNames[i]).OutputPort(consumerNames[i]))
+			for _, port := range consumer.RxPorts() {
+				messageFlow.Watch(port)
+			}
+			messageFlow.Watch(consumer.CtrlPort())
+		}
+	}
+
+	// Sample the occupancy of every port buffer
+	var sampler *QueueSampler
+	if cfg.QueueSampleFile != "" {
+		sampler = NewQueueSampler(engine, sim.VTimeInSec(cfg.SampleInterval))
+		for _, p := range producers {
+			sampler.Track(p.CtrlPort())
+		}
+		sampler.Track(root.InputPort())
+		sampler.Track(root.CtrlPort())
+		sampler.Track(deadLetters.inputPort)
+		if workPool != nil {
+			sampler.Track(workPool.inputPort)
+		}
+		for _, consumer := range consumers {
+			for _, port := range consumer.RxPorts() {
+				sampler.Track(port)
+			}
+			sampler.Track(consumer.CtrlPort())
+		}
+	}
+
+	// Publish the counters and queue depths for Prometheus
+	var metrics *PrometheusExporter
+	if cfg.Metrics != "" {
+		metrics = NewPrometheusExporter(engine, stats, nil)
+		for _, p := range producers {
+			metrics.Track(p.CtrlPort())
+		}
+		metrics.Track(root.InputPort())
+		metrics.Track(root.CtrlPort())
+		metrics.Track(deadLetters.inputPort)
+		for _, consumer := range consumers {
+			for _, port := range consumer.RxPorts() {
+				metrics.Track(port)
+			}
+			metrics.Track(consumer.CtrlPort())
+		}
+	}
+
+	// Record the port states along the data path
+	var timeline *PortTimeline
+	if cfg.PortTimelineFile != "" {
+		timeline = NewPortTimeline(engine)
+		for _, p := range producers {
+			timeline.Track(p.OutputPort(), 0)
+		}
+		timeline.Track(root.InputPort(), 10)
+		for i, consumer := range consumers {
+			timeline.Track(tree.Leaf(consumerNames[i]).OutputPort(consumerNames[i]), 0)
+			for _, port := range consumer.RxPorts() {
+				timeline.Track(port, spec.Consumers[i].QueueCapacity)
+			}
+		}
+	}
+
+	var components []component.Lifecycle
+	for _, p := range producers {
+		components = append(components, p)
+	}
+	for _, d := range tree.Distributors() {
+		components = append(components, d)
+	}
+	components = append(components, deadLetters)
+	for _, consumer := range consumers {
+		components = append(components, consumer)
+	}
+	if watchdog != nil {
+		components = append(components, watchdog)
+	}
+
+	s := &Simulation{
+		cfg:           cfg,
+		out:           out,
+		engine:        engine,
+		stats:         stats,
+		trafficModel:  trafficModel,
+		matrix:        matrix,
+		producers:     producers,
+		spec:          spec,
+		distributor:   root,
+		tree:          tree,
+		deadLetters:   deadLetters,
+		timeline:      timeline,
+		backpressure:  backpressure,
+		verifier:      verifier,
+		sizeService:   sizeService,
+		reorder:       reorder,
+		timestamps:    timestamps,
+		ledger:        ledger,
+		fingerprint:   fingerprint,
+		watchdog:      watchdog,
+		sampler:       sampler,
+		metrics:       metrics,
+		chromeTrace:   chromeTrace,
+		eventDB:       eventDB,
+		visualTracer:  visualTracer,
+		messageFlow:   messageFlow,
+		topology:      topology,
+		network:       network,
+		workPool:      workPool,
+		stealing:      stealing,
+		arbitration:   arbitration,
+		drain:         drain,
+		faults:        faults,
+		attribution:   attribution,
+		retransmitter: retransmitter,
+		auditor:       auditor,
+		middleware:    chain,
+		inversions:    inversions,
+		consumerNames: consumerNames,
+		consumers:     consumers,
+		models:        models,
+		queueDepths:   queueDepths,
+		errors:        errorLog,
+		components:    components,
+		traceStreams:  traceStreams,
+		routing:       routing,
+	}
+	if metrics != nil {
+		metrics.conservation = s.Conservation
+	}
+	return s, nil
+}
+
+// Run kicks off the components and runs the engine until no events are left.
+// After the producer stops, the run drains the queues, bounded by the drain
+// timeout if one is set.
+func (s *Simulation) Run() error {
+	for _, c := range s.components {
+		if err := c.Init(); err != nil {
+			return err
+		}
+	}
+
+	// Kick off the ticking components
+	// The producer and the consumers start ticking at time 0; consumers
+	// register with the distributor during the registration period.
+	// Afterwards, the distributor and consumers are woken up by message
+	// arrivals (polling consumers keep ticking).
+	// A restored run goes on with the events of the saved one
+	if !s.restored {
+		startComponents(s.components, 0, s.cfg.AutoStart == "all")
+		scheduleDrain(s.engine, s.components, sim.VTimeInSec(s.cfg.Cycles))
+	}
+	for _, h := range s.checkpoints {
+		h.check()
+	}
+
+	err := s.engine.Run()
+	for _, stream := range s.traceStreams {
+		stream.Close()
+		if err == nil {
+			err = stream.Err()
+		}
+	}
+	for _, h := range s.checkpoints {
+		if hookErr := h.finish(); err == nil {
+			err = hookErr
+		}
+	}
+	if err == nil {
+		err = s.errors.Err()
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, c := range s.components {
+		c.Finalize(s.Duration())
+	}
+	if s.timeline != nil {
+		s.timeline.Finish(s.Duration())
+	}
+	if s.sampler != nil {
+		s.sampler.Finish(s.Duration())
+	}
+	if s.metrics != nil {
+		s.metrics.Finish(s.Duration())
+	}
+	if s.control != nil {
+		s.control.Finish()
+	}
+	if s.visualTracer != nil {
+		return s.visualTracer.Finish()
+	}
+	return nil
+}
+
+// Duration returns the virtual time the run took
+func (s *Simulation) Duration() sim.VTimeInSec {
+	return s.engine.CurrentTime()
+}
+
+// Completion returns the time the last message was consumed
+func (s *Simulation) Completion() sim.VTimeInSec {
+	var completion sim.VTimeInSec
+	for _, c := range s.consumers {
+		if last := c.LastConsumed(); last > completion {
+			completion = last
+		}
+	}
+	return completion
+}
+
+// Conservation accounts for every message produced during the run
+func (s *Simulation) Conservation() Conservation {
+	c := Conservation{
+		Produced:      s.stats.Produced,
+		Copies:        s.stats.Copies - s.stats.FannedOut,
+		Filtered:      s.routing.rules.TotalDropped(),
+		NoRoom:        s.routing.sharedBuffer.TotalDropped(),
+		Evicted:       s.routing.sharedBuffer.TotalEvicted(),
+		Duplicates:    s.middleware.TotalCopies(),
+		Retransmitted: s.retransmitter.TotalRetransmitted(),
+		Deduplicated:  s.retransmitter.TotalDuplicates(),
+		Intercepted:   s.middleware.TotalDropped(),
+		Lost:          s.faults.TotalLost(),
+		Consumed:      s.stats.Consumed,
+		Expired:       s.stats.Expired,
+		DeadLetters:   s.deadLetters.Total,
+		InNetwork:     s.ledger.InNetwork(),
+		Retained:      s.distributor.Replaying(),
+	}
+	if s.routing.retention != nil {
+		c.RetentionMisses = s.routing.retention.Misses
+		c.Retained += s.routing.retention.Len()
+	}
+	return c
+}
+
+// Topology returns the components and connections of the model as they were
+// built
+func (s *Simulation) Topology() *Topology {
+	return s.topology
+}
+
+// Producers returns the producers of the model
+func (s *Simulation) Producers() []*producer.Producer {
+	return s.producers
+}
+
+// Consumers returns the consumers of the model
+func (s *Simulation) Consumers() []*consumer.Consumer {
+	return s.consumers
+}
+
+// PrintSetup describes the configuration of the run
+func (s *Simulation) PrintSetup() {
+	cfg := s.cfg
+	s.out.Println("=== Starting Akita Demo Simulation ===")
+	s.out.Printf("Simulation Duration: %d cycles (seconds)\n", cfg.Cycles)
+	if cfg.RandomTopology {
+		s.out.Printf("Random topology: %s\n", s.spec)
+		for _, p := range s.spec.Producers {
+			s.out.Printf("%s: Randomly generates messages (%.0f%% chance per tick)\n", p.Name, p.Probability*100)
+		}
+	} else if s.matrix != nil {
+		s.out.Printf("Traffic matrix: %s (%s)\n", cfg.TrafficMatrixFile, s.spec)
+		for i, name := range s.matrix.Sources {
+			total := 0.0
+			for _, rate := range s.matrix.Rates[i] {
+				total += rate
+			}
+			s.out.Printf("%s: Follows its row of the matrix (%.3f msg/s in total)\n", name, total)
+		}
+	} else if cfg.TraceFile != "" {
+		s.out.Printf("Producer: Replays trace %s\n", cfg.TraceFile)
+	} else if t, ok := s.trafficModel.(*BurstyTraffic); ok {
+		s.out.Printf("Producer: Bursty traffic (%.0fs bursts at %.0f%% per tick, %.0fs idle)\n",
+			float64(t.BurstLength), t.BurstRate*100, float64(t.IdlePeriod))
+	} else {
+		s.out.Printf("Producer: Randomly generates messages (%.0f%% chance per tick)\n", cfg.ArrivalRate*100)
+	}
+	s.out.Printf("Registration: Consumers register during the first %.2f seconds\n", cfg.RegistrationPeriod)
+	s.out.Println("Distributor: Routes messages to correct consumer")
+	if len(s.tree.Regions()) > 0 {
+		s.out.Printf("Distributors: A %s, consumers register with the leaves\n", s.tree)
+	}
+	if s.routing.coalescer != nil {
+		s.out.Printf("Distributor: Coalesces notifications (after %d messages or %.2f seconds)\n",
+			cfg.CoalesceCount, cfg.CoalesceTime)
+	}
+	if s.routing.retention != nil {
+		s.out.Printf("Distributor: Retains up to %d messages for %.2f seconds for late consumers\n",
+			cfg.RetentionSize, cfg.RetentionWindow)
+	}
+	for _, name := range s.consumerNames {
+		if delay, ok := cfg.RegisterDelays[name]; ok {
+			s.out.Printf("%s: Subscribes late, at %.2f seconds\n", name, delay)
+		}
+	}
+	if cfg.RxQueues > 1 {
+		s.out.Printf("Consumers: %d RX queues each, steered by hash of %d flows\n", cfg.RxQueues, cfg.Flows)
+	}
+	if cfg.RandomTopology {
+		for _, c := range s.spec.Consumers {
+			s.out.Printf("%s: Processes 1 message every %.2f seconds, RX queues hold %d messages\n",
+				c.Name, c.Interval, c.QueueCapacity)
+		}
+	} else {
+		s.out.Printf("Consumers: Process messages at fixed rate (1 every %.2f seconds)\n", cfg.ConsumeInterval)
+		for _, name := range s.consumerNames {
+			if interval, ok := cfg.ConsumeIntervals[name]; ok {
+				s.out.Printf("%s: Processes 1 message every %.2f seconds\n", name, interval)
+			}
+		}
+	}
+	for _, c := range s.consumers {
+		if service := s.models[c.Name()].service; service != nil {
+			s.out.Printf("%s: Draws %s service times around its interval\n", c.Name(), service)
+		}
+	}
+	if cfg.ProducerFreq != 1 || cfg.DistributorFreq != 1 || cfg.ConsumerFreq != 1 || len(cfg.Frequencies) > 0 {
+		s.out.Printf("Clocks: Producers at %g Hz, distributor at %g Hz, consumers at %g Hz\n",
+			cfg.ProducerFreq, cfg.DistributorFreq, cfg.ConsumerFreq)
+		var names []string
+		for _, d := range s.tree.Distributors() {
+			names = append(names, d.Name())
+		}
+		for _, p := range s.producers {
+			names = append(names, p.Name())
+		}
+		for _, name := range append(names, s.consumerNames...) {
+			if freq, ok := cfg.Frequencies[name]; ok {
+				s.out.Printf("%s: Ticks at %g Hz\n", name, freq)
+			}
+		}
+	}
+	if s.network != nil {
+		s.out.Printf("Network: Consumers reached over a %s\n", s.network)
+	}
+	if s.workPool != nil {
+		s.out.Printf("Work pool: Consumers pull from one shared queue of %d messages\n", s.workPool.Capacity)
+	}
+	if s.stealing != nil {
+		s.out.Printf("Work stealing: Idle consumers take half the queue of a peer with %d or more messages\n", s.stealing.Threshold)
+	}
+	if links := s.topology.Links(); len(links) > 0 {
+		bandwidth := "unlimited"
+		if cfg.LinkBandwidth > 0 {
+			bandwidth = fmt.Sprintf("%g %s per cycle", cfg.LinkBandwidth, cfg.BandwidthUnit)
+		}
+		s.out.Printf("Links: Latency of %d cycles, %s bandwidth\n", cfg.LinkLatency, bandwidth)
+		if cfg.Arbiter == "wfq" {
+			weights := "equal weights"
+			if len(cfg.ArbiterWeights) > 0 {
+				weights = "weights " + (*delayList)(&cfg.ArbiterWeights).String()
+			}
+			s.out.Printf("Links: Arbitrated by weighted fair queueing, %s\n", weights)
+		}
+		if cfg.ArbitrationAudit {
+			s.out.Println("Links: Grants audited against the fairness contract of the arbiter")
+		}
+		for _, l := range links {
+			spec := cfg.Link(l.Name())
+			_, latency := cfg.LinkLatencies[l.Name()]
+			_, bw := cfg.LinkBandwidths[l.Name()]
+			if latency || bw {
+				s.out.Printf("%s: Latency of %d cycles, %g %s per cycle\n", l.Name(), spec.Latency, spec.Bandwidth, cfg.BandwidthUnit)
+			}
+		}
+	}
+	if cfg.PauseInterval > 0 {
+		s.out.Printf("Consumers: Pause for %.2f seconds every %.2f seconds (%s)\n",
+			cfg.PauseDuration, cfg.PauseInterval, cfg.PauseMode)
+	}
+	if cfg.DestPolicy == "latency-p2c" && cfg.TraceFile == "" {
+		s.out.Println("Producer: Picks the faster of two random consumers by recent ACK latency")
+	}
+	if cfg.RoutePolicy == "queue-p2c" {
+		s.out.Println("Distributor: Routes each message to the shorter queue of two random consumers")
+	}
+	if s.routing.overflow != nil {
+		s.out.Printf("Distributor: Reroutes to %s when %d messages are queued at a consumer\n",
+			cfg.OverflowConsumer, cfg.OverflowThreshold)
+	}
+	if s.routing.rules != nil {
+		s.out.Printf("Distributor: Routes by %d content rules before the destination\n", len(s.routing.rules.Rules))
+	}
+	if f := s.faults; f != nil {
+		s.out.Printf("Faults: %d injected (seed %d), losses on the data connections, consumers down, and distributors stalled\n",
+			len(f.Faults), f.Seed)
+	}
+	if h := s.routing.health; h != nil {
+		s.out.Printf("Health checks: Heartbeats every %.2f s, consumers silent for %.2f s are failed over\n",
+			float64(h.Interval), float64(h.Timeout))
+	}
+	if r := s.retransmitter; r != nil {
+		s.out.Printf("Producer: Retransmits messages unacknowledged after %.2f s, doubling the wait up to %d times\n",
+			float64(r.Timeout), r.Limit)
+	}
+	if b := s.routing.sharedBuffer; b != nil {
+		s.out.Printf("Consumers: Share a buffer of %d messages, %d reserved each, %d shared (%s), and drop what finds no room\n",
+			b.Reserve*len(s.consumers)+b.Shared, b.Reserve, b.Shared, b.Policy)
+	}
+	if cfg.Multicast > 0 {
+		s.out.Printf("Producer: Multicasts %.0f%% of messages to one of %s, the distributor copies them to every member\n",
+			cfg.Multicast*100, strings.Join(multicastTargets(cfg.MulticastGroups()), ", "))
+		for _, name := range multicastTargets(cfg.Groups)[1:] {
+			s.out.Printf("%s: %s\n", name, strings.Join(cfg.Groups[name], ", "))
+		}
+		for _, name := range s.consumerNames {
+			if joins := cfg.Joins[name]; len(joins) > 0 {
+				when := "once registered"
+				if at := cfg.JoinAt[name]; at > 0 {
+					when = fmt.Sprintf("at %.2f", at)
+				}
+				s.out.Printf("%s: Joins %s %s\n", name, strings.Join(joins, ", "), when)
+			}
+			if at := cfg.LeaveAt[name]; at > 0 {
+				s.out.Printf("%s: Leaves %s at %.2f\n", name, strings.Join(cfg.GroupsLeft(name), ", "), at)
+			}
+		}
+	}
+	if groups := s.routing.consumerGroups; groups != nil {
+		if groups.Strategy == AssignRoundRobin {
+			s.out.Println("Distributor: Assigns the messages of consumer groups to their members in turn")
+		} else {
+			s.out.Printf("Distributor: Assigns the messages of consumer groups to their members by flow over %d partitions\n",
+				groups.Partitions)
+		}
+		for _, name := range multicastTargets(cfg.ConsumerGroups)[1:] {
+			s.out.Printf("%s: %s\n", name, strings.Join(cfg.ConsumerGroups[name], ", "))
+		}
+		for _, name := range s.consumerNames {
+			if at := cfg.LeaveGroupAt[name]; at > 0 {
+				s.out.Printf("%s: Leaves %s at %.2f\n", name, groups.Destination(name), at)
+			}
+		}
+	}
+	if cfg.SizeSpread > 0 {
+		s.out.Printf("Producer: Draws sizes around %d bytes and service times around the consume interval (spread %.2f, correlation %.2f)\n",
+			cfg.MsgSize, cfg.SizeSpread, cfg.SizeCorrelation)
+	}
+	if len(cfg.Topics) > 0 {
+		s.out.Printf("Producer: Publishes every message to one of %s, the distributor copies it to the subscribers\n",
+			strings.Join(cfg.Topics, ", "))
+		for _, name := range s.consumerNames {
+			if subscriptions := cfg.Subscriptions[name]; len(subscriptions) > 0 {
+				s.out.Printf("%s: Subscribes to %s\n", name, strings.Join(subscriptions, ", "))
+			}
+			if at := cfg.UnsubscribeAt[name]; at > 0 {
+				s.out.Printf("%s: Unsubscribes from %s at %.2f\n", name, strings.Join(cfg.TopicsLeft(name), ", "), at)
+			}
+		}
+	}
+	if len(cfg.ClockSkews) > 0 {
+		s.out.Println("Distributor: Corrects the creation stamps of producers with skewed clocks")
+	}
+	if cfg.InOrder && cfg.ReorderTimeout > 0 {
+		s.out.Printf("Consumers: Deliver messages in order, give up on a missing message after %.2f seconds\n",
+			cfg.ReorderTimeout)
+	} else if cfg.InOrder {
+		s.out.Println("Consumers: Deliver messages in order")
+	}
+	if cfg.MaxInFlight > 0 {
+		s.out.Printf("Producer: At most %d unacknowledged messages in flight\n", cfg.MaxInFlight)
+	}
+	if cfg.DestWindow > 0 && len(cfg.DestWindows) > 0 {
+		s.out.Printf("Distributor: At most %d unacknowledged messages outstanding per consumer (%s)\n",
+			cfg.DestWindow, (*countList)(&cfg.DestWindows))
+	} else if cfg.DestWindow > 0 {
+		s.out.Printf("Distributor: At most %d unacknowledged messages outstanding per consumer\n", cfg.DestWindow)
+	} else if len(cfg.DestWindows) > 0 {
+		s.out.Printf("Distributor: Limits the unacknowledged messages outstanding at %s\n", (*countList)(&cfg.DestWindows))
+	}
+	if cfg.Watchdog > 0 {
+		s.out.Printf("Watchdog: Reports a stall after %.2f seconds without ticks\n", cfg.Watchdog)
+	}
+	if cfg.DrainTimeout > 0 {
+		s.out.Printf("Drain: Queues drain for at most %.2f seconds after generation stops\n", cfg.DrainTimeout)
+	}
+	if cfg.PriorityLevels > 1 {
+		s.out.Printf("Producer: Messages have %d priority levels\n", cfg.PriorityLevels)
+	}
+	if cfg.TTL > 0 {
+		s.out.Printf("Producer: Messages expire %.2f seconds after they are generated\n", cfg.TTL)
+	}
+	if cfg.WindowSize > 0 {
+		s.out.Printf("Producer: Sliding window of up to %d messages, shrinks when RTT exceeds %.2f seconds\n",
+			cfg.WindowSize, cfg.WindowTargetRTT)
+	}
+	if cfg.ConsumerMode == "polling" {
+		s.out.Println("Consumers: Poll their queues every cycle")
+	}
+	if cfg.FailOnDeadLetter {
+		s.out.Println("Distributor: The run fails if any message is dead-lettered")
+	}
+	if cfg.StrictErrors {
+		expected := "none"
+		if len(cfg.ExpectedErrors) > 0 {
+			expected = strings.Join(cfg.ExpectedErrors, ", ")
+		}
+		s.out.Printf("Errors: Strict, the run aborts on the first unexpected error (expected: %s)\n", expected)
+	}
+	if cfg.ConsumerMode == "batch" {
+		s.out.Printf("Consumers: Wait for batches of %d messages before processing\n", cfg.BatchSize)
+	}
+	s.out.Println()
+}
+
+// PrintReport writes the statistics of a finished run
+func (s *Simulation) PrintReport() error {
+	cfg := s.cfg
+	duration := s.Duration()
+	s.out.Println()
+	s.stats.Print(s.out, duration)
+	s.out.Println()
+	ComputeDerivedMetrics(s.stats, duration, s.distributor, s.consumers).Print(s.out)
+	s.out.Println()
+	s.stats.PrintUtilization(s.out)
+	s.out.Println()
+	s.stats.PrintPairLatencies(s.out)
+	s.out.Println()
+	s.verifier.Print()
+	s.out.Println()
+	s.Conservation().Print(s.out)
+	if s.auditor != nil {
+		s.out.Println()
+		s.auditor.Audit().Print(s.out)
+	}
+	if links := s.topology.Links(); len(links) > 0 {
+		s.out.Println()
+		PrintLinks(s.out, links, duration)
+	}
+	if cfg.ArbitrationAudit {
+		s.out.Println()
+		s.arbitration.Print(s.out, s.topology.Links())
+	}
+	if s.arbitration != nil && cfg.GrantTraceFile != "" {
+		if err := s.arbitration.WriteCSV(cfg.GrantTraceFile); err != nil {
+			return err
+		}
+		s.out.Printf("%d grants written to %s\n", len(s.arbitration.Grants), cfg.GrantTraceFile)
+	}
+	if s.network != nil {
+		s.out.Println()
+		s.network.Print(s.out)
+	}
+	if s.workPool != nil {
+		s.out.Println()
+		s.workPool.Print(s.consumerNames)
+	}
+	if s.stealing != nil {
+		s.out.Println()
+		s.stealing.Print(s.out, s.consumerNames)
+	}
+	if len(s.tree.Regions()) > 0 {
+		s.out.Println()
+		s.tree.Print(s.out)
+	}
+	if len(s.removals) > 0 {
+		s.out.Println()
+		PrintRemovals(s.out, s.removals)
+	}
+	if s.attribution != nil {
+		s.out.Println()
+		s.attribution.Print(s.out)
+	}
+	if cfg.LatencyCDFFile != "" {
+		if err := s.stats.ExportLatencyCDF(cfg.LatencyCDFFile); err != nil {
+			return err
+		}
+		s.out.Printf("Latency CDFs written to %s\n", cfg.LatencyCDFFile)
+	}
+	if cfg.MetricsOut != "" {
+		if err := ExportRunMetrics(cfg.MetricsOut, s.Metrics()); err != nil {
+			return err
+		}
+		s.out.Printf("Metrics written to %s\n", cfg.MetricsOut)
+	}
+	if s.timeline != nil {
+		s.out.Println()
+		s.timeline.Print(s.out, s.Duration())
+		if err := s.timeline.ExportTimeline(cfg.PortTimelineFile); err != nil {
+			return err
+		}
+		s.out.Printf("Port timeline written to %s\n", cfg.PortTimelineFile)
+	}
+	if s.chromeTrace != nil {
+		if err := s.chromeTrace.ExportTrace(cfg.TraceOutFile); err != nil {
+			return err
+		}
+		s.out.Printf("Chrome trace written to %s\n", cfg.TraceOutFile)
+	}
+	if s.eventDB != nil && cfg.DBFile != "" {
+		if err := s.eventDB.Export(cfg.DBFile); err != nil {
+			return err
+		}
+		s.out.Printf("%d message events written to %s\n", len(s.eventDB.Events), cfg.DBFile)
+	}
+	if s.visualTracer != nil {
+		s.out.Printf("%d tasks written to %s\n", s.visualTracer.Tasks, cfg.VisualTraceFile)
+	}
+	if s.messageFlow != nil {
+		if err := ExportMermaid(cfg.MermaidFile, s.topology, s.messageFlow); err != nil {
+			return err
+		}
+		s.out.Printf("Mermaid diagrams of the topology and message #%d written to %s\n",
+			s.messageFlow.MsgID, cfg.MermaidFile)
+	}
+	if s.sampler != nil {
+		s.out.Println()
+		s.sampler.Print(s.out)
+		if err := s.sampler.ExportSamples(cfg.QueueSampleFile); err != nil {
+			return err
+		}
+		s.out.Printf("Queue samples written to %s\n", cfg.QueueSampleFile)
+	}
+	if cfg.RxQueues > 1 {
+		s.out.Println()
+		PrintRxQueueReport(s.out, s.consumers)
+	}
+	if cfg.PauseInterval > 0 {
+		s.out.Println()
+		PrintPauseReport(s.out, s.consumers, s.models, s.Duration())
+	}
+	if cfg.ServiceDist != "fixed" || len(cfg.ServiceDists) > 0 {
+		s.out.Println()
+		PrintServiceReport(s.out, s.consumers, s.models)
+	}
+	if cfg.QueueingModel {
+		s.out.Println()
+		PrintQueueingReport(s.out, s.consumers, s.models, s.Duration())
+	}
+	if s.routing.overflow != nil {
+		s.out.Println()
+		s.routing.overflow.Print(s.out)
+	}
+	if s.routing.rules != nil {
+		s.out.Println()
+		s.routing.rules.Print(s.out)
+	}
+	if s.routing.sharedBuffer != nil {
+		s.out.Println()
+		s.routing.sharedBuffer.Print(s.out, s.consumerNames)
+	}
+	if s.middleware.Active() {
+		s.out.Println()
+		printMiddleware(s.out, s.middleware)
+	}
+	if s.faults != nil {
+		s.out.Println()
+		s.faults.Print(s.stats.Produced)
+	}
+	if s.routing.health != nil {
+		s.out.Println()
+		s.routing.health.Print(s.out)
+	}
+	if s.retransmitter != nil {
+		s.out.Println()
+		s.retransmitter.Print(s.out)
+	}
+	if s.routing.membership != nil {
+		s.out.Println()
+		s.routing.membership.Print(s.out, s.routing.groups)
+	}
+	if s.routing.subscriptions != nil {
+		s.out.Println()
+		s.routing.subscriptions.Print(s.out)
+	}
+	if s.routing.consumerGroups != nil {
+		s.out.Println()
+		s.routing.consumerGroups.Print(s.out)
+	}
+	if s.sizeService != nil {
+		s.out.Println()
+		s.sizeService.Print(s.out)
+	}
+	if s.routing.windows != nil {
+		s.out.Println()
+		s.routing.windows.Print(s.out, duration)
+	}
+	if s.reorder != nil {
+		s.out.Println()
+		s.reorder.Print(duration)
+	}
+	if s.timestamps != nil {
+		s.out.Println()
+		s.timestamps.Print(s.out)
+		if cfg.TimestampFile != "" {
+			if err := s.timestamps.ExportTimestamps(cfg.TimestampFile); err != nil {
+				return err
+			}
+			s.out.Printf("Timestamps written to %s\n", cfg.TimestampFile)
+		}
+	}
+	if s.matrix != nil {
+		s.out.Println()
+		PrintMatrixReport(s.out, s.matrix, s.producers, sim.VTimeInSec(cfg.Cycles))
+	}
+	if s.deadLetters.Total > 0 {
+		s.out.Println()
+		s.deadLetters.Print(s.out)
+	}
+	if s.errors.Total > 0 {
+		s.out.Println()
+		s.errors.Print()
+	}
+	for _, p := range s.producers {
+		if p.Window() == nil {
+			continue
+		}
+		s.out.Println()
+		if len(s.producers) > 1 {
+			s.out.Printf("%s:\n", p.Name())
+		}
+		printWindow(s.out, p.Window())
+	}
+	if s.backpressure != nil {
+		s.out.Println()
+		s.backpressure.Print()
+	}
+	if s.inversions != nil {
+		s.out.Println()
+		s.inversions.Print()
+	}
+	if s.control != nil && len(s.control.Changes()) > 0 {
+		s.out.Println()
+		s.control.PrintChanges(s.out)
+	}
+	if s.routing.retention != nil {
+		s.out.Println()
+		s.routing.retention.Print(s.out)
+	}
+	if s.drain != nil {
+		s.out.Println()
+		c := s.Conservation()
+		s.drain.Print(s.out, duration, c.InNetwork+c.Retained)
+	}
+	if s.watchdog != nil {
+		s.out.Println()
+		s.watchdog.Print()
+	}
+
+	return nil
+}
+
+// ReportFailures writes why a finished run failed: it missed a budget, lost
+// or duplicated messages, delivered them more than once, stalled, or
+// dead-lettered messages it must not. It returns whether the run failed.
+func (s *Simulation) ReportFailures() bool {
+	failed := false
+	violations := s.cfg.Budgets.Check(s.stats, s.Duration())
+	if len(violations) > 0 {
+		s.out.Println("\n=== Budget Violations ===")
+		for _, v := range violations {
+			s.out.Println(v)
+		}
+		failed = true
+	}
+	if !s.Conservation().Holds() {
+		s.out.Println("\nError: messages were lost or duplicated")
+		failed = true
+	}
+	if s.auditor != nil && !s.auditor.Audit().Holds() {
+		s.out.Println("\nError: messages were not delivered exactly once")
+		failed = true
+	}
+	if s.watchdog != nil && len(s.watchdog.Stalls) > 0 {
+		s.out.Println("\nError: the run stalled")
+		failed = true
+	}
+	if s.cfg.FailOnDeadLetter && s.deadLetters.Total > 0 {
+		s.out.Printf("\nError: %d messages were dead-lettered\n", s.deadLetters.Total)
+		failed = true
+	}
+	return failed
+}
@@ -0,0 +1,652 @@
+package demo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// snapshot is the state of a simulation as a tree of values that gob can
+// encode: every value the simulation reaches, the unexported fields of
+// Akita's engine, ports, and connections and of the components included,
+// with the pointers between them as references into Objects. The pending
+// events, the buffered messages, and the random number generators are saved
+// along with the rest.
+//
+// A snapshot is restored into a simulation built again from the same
+// configuration. The objects it shares with the saved run, found by name or
+// by the path that leads to them, are overwritten in place, so that the
+// handlers and hooks the fresh run registered keep pointing at them, and the
+// objects created during the saved run, such as messages and events, are
+// allocated again.
+type snapshot struct {
+	Root    stateValue
+	Objects []stateObject
+}
+
+// stateObject is a value a pointer refers to
+type stateObject struct {
+	Type  string
+	Name  string // Name of a component or a port, empty for other objects
+	Value stateValue
+}
+
+// stateValue is one value of a snapshot. Which fields are set depends on its
+// kind: a number or a string, a reference to an object (1-based, 0 is nil),
+// the dynamic type of an interface, or the elements of a struct, an array,
+// a slice, or a map along with its keys. A value that is kept is left as the
+// restoring run built it.
+type stateValue struct {
+	Bool   bool
+	Int    int64
+	Uint   uint64
+	Float  float64
+	String string
+	Nil    bool
+	Keep   bool
+	Ref    int
+	Type   string
+	Elems  []stateValue
+	Keys   []stateValue
+}
+
+// snapshotRoot is what a snapshot starts from: the simulation, and Akita's
+// ID generator, which numbers the messages of every simulation in the process
+type snapshotRoot struct {
+	Simulation *Simulation
+	IDs        sim.IDGenerator
+}
+
+// keptPackages are packages whose values are not saved: locks, files,
+// servers, clocks, and Akita's tracers and monitor, which belong to the run
+// in the process rather than to the model
+var keptPackages = []string{
+	"sync", "sync/atomic", "os", "io", "bufio", "log", "net", "net/http",
+	"time", "context", "database/sql", "strings", "bytes", "encoding/",
+	"github.com/sarchlab/akita/v3/tracing", "github.com/sarchlab/akita/v3/monitoring",
+	"github.com/prometheus/", "github.com/mattn/", "modernc.org/",
+}
+
+// keptTypes are the types whose values the restoring run keeps: the
+// configuration, the hooks, and the observers created by the run and output
+// options, which the restoring run may set differently from the saved one
+var keptTypes = []reflect.Type{
+	reflect.TypeOf(Config{}),
+	reflect.TypeOf(sim.HookableBase{}),
+	reflect.TypeOf(EventDB{}),
+	reflect.TypeOf(ChromeTrace{}),
+	reflect.TypeOf(VisualTracer{}),
+	reflect.TypeOf(MessageFlow{}),
+	reflect.TypeOf(PortTimeline{}),
+	reflect.TypeOf(QueueSampler{}),
+	reflect.TypeOf(PrometheusExporter{}),
+	reflect.TypeOf(Controller{}),
+	reflect.TypeOf(checkpointHook{}),
+	reflect.TypeOf(portContents{}),
+}
+
+// optionalTypes are the types of the models that a run option can also
+// create: the saved one is restored if both runs have it, otherwise the
+// restoring run keeps what it has
+var optionalTypes = []reflect.Type{
+	reflect.TypeOf(ArbitrationAudit{}),
+}
+
+// restoredTypes are the types that can appear in a snapshot behind an
+// interface, for example as pending events and buffered messages, without
+// being reachable from a simulation that has not run yet
+func restoredTypes() []interface{} {
+	types := []interface{}{
+		&sim.TickEvent{},
+		&drainEvent{},
+		&drainHandler{},
+		&coalesceTimerEvent{},
+		&msg.DemoMessage{},
+		&msg.RegisterMsg{},
+		&msg.DiscoverReq{},
+		&msg.DiscoverRsp{},
+		&msg.AckMsg{},
+		&msg.HeartbeatMsg{},
+		&msg.LeaveGroupMsg{},
+		&msg.MembershipMsg{},
+		&msg.SubscribeMsg{},
+		&msg.PullMsg{},
+		&msg.DeadLetterMsg{},
+		&msg.StealReq{},
+		&msg.StealRsp{},
+	}
+	types = append(types, component.CheckpointTypes()...)
+	return types
+}
+
+var loggerType = reflect.TypeOf((*component.Logger)(nil)).Elem()
+
+// kept reports whether the values of a type are kept by the restoring run
+func kept(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return kept(t.Elem())
+	case reflect.Map:
+		return kept(t.Key()) || kept(t.Elem())
+	case reflect.Chan, reflect.UnsafePointer:
+		return true
+	}
+	if t.Implements(loggerType) || reflect.PtrTo(t).Implements(loggerType) {
+		// The output of the run
+		return true
+	}
+	for _, k := range keptTypes {
+		if t == k {
+			return true
+		}
+	}
+	if pkg := t.PkgPath(); pkg != "" {
+		for _, p := range keptPackages {
+			if pkg == p || strings.HasSuffix(p, "/") && strings.HasPrefix(pkg, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// optional reports whether a model can also be created by a run option
+func optional(t reflect.Type) bool {
+	for _, o := range optionalTypes {
+		if t == o {
+			return true
+		}
+	}
+	return false
+}
+
+// typeName names a type by its package path, unlike reflect.Type.String
+func typeName(t reflect.Type) string {
+	if t.Name() != "" {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		return t.PkgPath() + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + typeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), typeName(t.Elem()))
+	case reflect.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	}
+	return t.String()
+}
+
+// writable returns a value that can be set and read as an interface, also
+// if it was reached through unexported fields
+func writable(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// objectName returns the name of a component or a port
+func objectName(p reflect.Value) (name string) {
+	defer func() {
+		// The name of an object that is not set up yet
+		if recover() != nil {
+			name = ""
+		}
+	}()
+	p = reflect.NewAt(p.Type().Elem(), unsafe.Pointer(p.Pointer()))
+	if named, ok := p.Interface().(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return ""
+}
+
+// objectKey identifies an object by its type and address
+type objectKey struct {
+	t    reflect.Type
+	addr uintptr
+}
+
+// snapshotWriter encodes the values reachable from a simulation
+type snapshotWriter struct {
+	snapshot *snapshot
+	refs     map[objectKey]int
+}
+
+// takeSnapshot saves the state of a simulation
+func (s *Simulation) takeSnapshot() *snapshot {
+	w := &snapshotWriter{snapshot: &snapshot{}, refs: make(map[objectKey]int)}
+	root := &snapshotRoot{Simulation: s, IDs: sim.GetIDGenerator()}
+	w.snapshot.Root = w.value(reflect.ValueOf(root).Elem())
+	return w.snapshot
+}
+
+// value encodes a value and saves the objects it points to
+func (w *snapshotWriter) value(v reflect.Value) stateValue {
+	if kept(v.Type()) {
+		return stateValue{Keep: true}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return stateValue{Bool: v.Bool()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return stateValue{Int: v.Int()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return stateValue{Uint: v.Uint()}
+	case reflect.Float32, reflect.Float64:
+		return stateValue{Float: v.Float()}
+	case reflect.String:
+		return stateValue{String: v.String()}
+	case reflect.Func:
+		return stateValue{Nil: v.IsNil()}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return stateValue{}
+		}
+		return stateValue{Ref: w.object(v)}
+	case reflect.Interface:
+		if v.IsNil() {
+			return stateValue{Nil: true}
+		}
+		elem := v.Elem()
+		if kept(elem.Type()) {
+			return stateValue{Keep: true}
+		}
+		return stateValue{Type: typeName(elem.Type()), Elems: []stateValue{w.value(elem)}}
+	case reflect.Struct:
+		state := stateValue{Elems: make([]stateValue, v.NumField())}
+		for i := range state.Elems {
+			state.Elems[i] = w.value(v.Field(i))
+		}
+		return state
+	case reflect.Array:
+		state := stateValue{Elems: make([]stateValue, v.Len())}
+		for i := range state.Elems {
+			state.Elems[i] = w.value(v.Index(i))
+		}
+		return state
+	case reflect.Slice:
+		if v.IsNil() {
+			return stateValue{Nil: true}
+		}
+		state := stateValue{Elems: make([]stateValue, v.Len())}
+		for i := range state.Elems {
+			state.Elems[i] = w.value(v.Index(i))
+		}
+		return state
+	case reflect.Map:
+		if v.IsNil() {
+			return stateValue{Nil: true}
+		}
+		state := stateValue{}
+		iter := v.MapRange()
+		for iter.Next() {
+			state.Keys = append(state.Keys, w.value(iter.Key()))
+			state.Elems = append(state.Elems, w.value(iter.Value()))
+		}
+		return state
+	}
+	return stateValue{Keep: true}
+}
+
+// object returns the reference to the object a pointer points to, saving
+// the object the first time it is reached
+func (w *snapshotWriter) object(p reflect.Value) int {
+	key := objectKey{p.Type(), p.Pointer()}
+	if ref, ok := w.refs[key]; ok {
+		return ref
+	}
+	w.snapshot.Objects = append(w.snapshot.Objects, stateObject{
+		Type: typeName(p.Type().Elem()),
+		Name: objectName(p),
+	})
+	ref := len(w.snapshot.Objects)
+	w.refs[key] = ref
+	value := w.value(p.Elem())
+	w.snapshot.Objects[ref-1].Value = value
+	return ref
+}
+
+// snapshotReader restores a snapshot into a simulation built from the same
+// configuration
+type snapshotReader struct {
+	snapshot *snapshot
+	types    map[string]reflect.Type
+	named    map[string]reflect.Value // Components and ports of the run by type and name
+	targets  []reflect.Value          // Object each reference is restored into
+	reused   []bool                   // Whether the object belongs to the restoring run
+	claimed  map[objectKey]bool
+}
+
+// restoreSnapshot overwrites the state of a simulation that has not run yet
+// with a saved one
+func (s *Simulation) restoreSnapshot(saved *snapshot) error {
+	r := &snapshotReader{
+		snapshot: saved,
+		types:    make(map[string]reflect.Type),
+		named:    make(map[string]reflect.Value),
+		targets:  make([]reflect.Value, len(saved.Objects)),
+		reused:   make([]bool, len(saved.Objects)),
+		claimed:  make(map[objectKey]bool),
+	}
+	root := reflect.ValueOf(&snapshotRoot{Simulation: s, IDs: sim.GetIDGenerator()}).Elem()
+	r.collect(root, make(map[objectKey]bool))
+	for _, v := range restoredTypes() {
+		r.addType(reflect.TypeOf(v))
+	}
+
+	// Find the objects of the restoring run that the saved objects were:
+	// components and ports by name, the others by the path that leads to them
+	for i, o := range saved.Objects {
+		if o.Name == "" {
+			continue
+		}
+		if p, ok := r.named[o.Type+" "+o.Name]; ok {
+			r.claim(i, p)
+		}
+	}
+	visited := make([]bool, len(saved.Objects))
+	r.match(saved.Root, root, visited)
+	for i := range saved.Objects {
+		r.matchObject(i, visited)
+	}
+
+	for i, o := range saved.Objects {
+		if r.targets[i].IsValid() {
+			continue
+		}
+		t, ok := r.types[o.Type]
+		if !ok {
+			return fmt.Errorf("the checkpoint holds a %s, which cannot be restored", o.Type)
+		}
+		r.targets[i] = reflect.New(t)
+	}
+	for i, o := range saved.Objects {
+		if err := r.restore(r.targets[i].Elem(), o.Value, r.reused[i]); err != nil {
+			return fmt.Errorf("restoring %s: %w", o.Type, err)
+		}
+	}
+	return nil
+}
+
+// addType registers a type and the types it is made of
+func (r *snapshotReader) addType(t reflect.Type) {
+	name := typeName(t)
+	if _, ok := r.types[name]; ok {
+		return
+	}
+	r.types[name] = t
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		r.addType(t.Elem())
+	case reflect.Map:
+		r.addType(t.Key())
+		r.addType(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			r.addType(t.Field(i).Type)
+		}
+	}
+}
+
+// collect registers the types and the named objects of the restoring run
+func (r *snapshotReader) collect(v reflect.Value, seen map[objectKey]bool) {
+	t := v.Type()
+	r.addType(t)
+	if kept(t) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		key := objectKey{t, v.Pointer()}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if name := objectName(v); name != "" {
+			id := typeName(t.Elem()) + " " + name
+			if _, ok := r.named[id]; ok {
+				// Two objects with the same name are told apart by their paths
+				r.named[id] = reflect.Value{}
+			} else {
+				r.named[id] = v
+			}
+		}
+		r.collect(v.Elem(), seen)
+	case reflect.Interface:
+		if !v.IsNil() {
+			r.collect(v.Elem(), seen)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			r.collect(v.Field(i), seen)
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			r.collect(v.Index(i), seen)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			r.collect(iter.Key(), seen)
+			r.collect(iter.Value(), seen)
+		}
+	}
+}
+
+// claim restores a saved object into an object of the restoring run, unless
+// either is matched already
+func (r *snapshotReader) claim(ref int, p reflect.Value) bool {
+	if !p.IsValid() || r.targets[ref].IsValid() {
+		return false
+	}
+	if typeName(p.Type().Elem()) != r.snapshot.Objects[ref].Type {
+		return false
+	}
+	key := objectKey{p.Type(), p.Pointer()}
+	if r.claimed[key] {
+		return false
+	}
+	r.claimed[key] = true
+	r.targets[ref] = reflect.NewAt(p.Type().Elem(), unsafe.Pointer(p.Pointer()))
+	r.reused[ref] = true
+	return true
+}
+
+// match walks a saved value along with the value of the restoring run at
+// the same path and matches the objects they point to
+func (r *snapshotReader) match(state stateValue, v reflect.Value, visited []bool) {
+	if state.Keep || kept(v.Type()) {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if state.Ref == 0 || v.IsNil() {
+			return
+		}
+		ref := state.Ref - 1
+		r.claim(ref, v)
+		r.matchObject(ref, visited)
+	case reflect.Interface:
+		if state.Nil || v.IsNil() || len(state.Elems) == 0 || typeName(v.Elem().Type()) != state.Type {
+			return
+		}
+		r.match(state.Elems[0], v.Elem(), visited)
+	case reflect.Struct:
+		if len(state.Elems) != v.NumField() {
+			return
+		}
+		for i := range state.Elems {
+			r.match(state.Elems[i], v.Field(i), visited)
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < len(state.Elems) && i < v.Len(); i++ {
+			r.match(state.Elems[i], v.Index(i), visited)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		for i, key := range state.Keys {
+			k := reflect.New(v.Type().Key()).Elem()
+			if r.restore(k, key, false) != nil {
+				continue
+			}
+			if elem := v.MapIndex(k); elem.IsValid() {
+				r.match(state.Elems[i], elem, visited)
+			}
+		}
+	}
+}
+
+// matchObject matches the objects a matched object points to
+func (r *snapshotReader) matchObject(ref int, visited []bool) {
+	target := r.targets[ref]
+	if visited[ref] || !target.IsValid() {
+		return
+	}
+	visited[ref] = true
+	r.match(r.snapshot.Objects[ref].Value, target.Elem(), visited)
+}
+
+// restore sets a value to its saved state. A value of the restoring run
+// keeps its functions, which cannot be saved; a value allocated again must
+// not have any.
+func (r *snapshotReader) restore(v reflect.Value, state stateValue, reused bool) error {
+	if state.Keep || kept(v.Type()) {
+		return nil
+	}
+	v = writable(v)
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(state.Bool)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(state.Int)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(state.Uint)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(state.Float)
+	case reflect.String:
+		v.SetString(state.String)
+	case reflect.Func:
+		if state.Nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else if !reused || v.IsNil() {
+			return fmt.Errorf("a %s cannot be restored", v.Type())
+		}
+	case reflect.Ptr:
+		if (state.Ref == 0 || v.IsNil()) && optional(v.Type().Elem()) {
+			return nil
+		}
+		if state.Ref == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		target := r.targets[state.Ref-1]
+		if !target.IsValid() {
+			return fmt.Errorf("a %s is not restored yet", v.Type())
+		}
+		if target.Type() != v.Type() {
+			return fmt.Errorf("a %s is not a %s", target.Type(), v.Type())
+		}
+		v.Set(target)
+	case reflect.Interface:
+		if state.Nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		t, ok := r.types[state.Type]
+		if !ok {
+			return fmt.Errorf("a %s cannot be restored", state.Type)
+		}
+		elem := reflect.New(t).Elem()
+		fresh := reused && !v.IsNil() && v.Elem().Type() == t
+		if fresh {
+			elem.Set(v.Elem())
+		}
+		if err := r.restore(elem, state.Elems[0], fresh); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Struct:
+		if len(state.Elems) != v.NumField() {
+			return fmt.Errorf("%s has %d fields, the checkpoint %d: it was saved by another build",
+				v.Type(), v.NumField(), len(state.Elems))
+		}
+		for i := range state.Elems {
+			if err := r.restore(v.Field(i), state.Elems[i], reused); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := range state.Elems {
+			if err := r.restore(v.Index(i), state.Elems[i], reused); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if state.Nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		// The elements a value of the restoring run already has keep their
+		// functions
+		slice := reflect.MakeSlice(v.Type(), len(state.Elems), len(state.Elems))
+		fresh := 0
+		if reused {
+			fresh = reflect.Copy(slice, v)
+		}
+		for i := range state.Elems {
+			if err := r.restore(slice.Index(i), state.Elems[i], i < fresh); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if state.Nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		// A map of the restoring run is refilled rather than replaced, the
+		// functions of the run may refer to it
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		old := reflect.MakeMap(v.Type())
+		for _, k := range v.MapKeys() {
+			if reused {
+				old.SetMapIndex(k, v.MapIndex(k))
+			}
+			v.SetMapIndex(k, reflect.Value{})
+		}
+		for i := range state.Keys {
+			k := reflect.New(v.Type().Key()).Elem()
+			if err := r.restore(k, state.Keys[i], false); err != nil {
+				return err
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			fresh := old.MapIndex(k)
+			if fresh.IsValid() {
+				elem.Set(fresh)
+			}
+			if err := r.restore(elem, state.Elems[i], fresh.IsValid()); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Stats collects message counters and end-to-end latencies during a run.
+// The Record methods are safe to call on a nil *Stats so that components can
+// be used without statistics collection (e.g., in unit tests).
+type Stats struct {
+	Produced      int
+	Routed        int
+	Consumed      int
+	Notifications int // Consumer wake-ups by message arrival or coalesced interrupt
+	ConsumerTicks int // Tick invocations of all consumers
+	EngineEvents  int // Events handled by the engine, when registered as an engine hook
+	Acked         int // Messages acknowledged back to the producer
+	Stalls        int // Producer ticks stalled by the in-flight limit
+	Expired       int // Messages dropped because their TTL ran out
+	Lost          int // Messages the producer gave up waiting for an ACK for
+	Copies        int // Copies of multicast messages sent to the members of their groups
+	FannedOut     int // Multicast messages every member of their group was sent a copy of
+	BranchRetries int // Copies retried because the port or window of their member was full
+	expiredAt     map[string]int
+	warmup        sim.VTimeInSec // Messages created before are left out of the latencies and the throughput
+	latencies     []float64
+	early         []float64 // Latencies of the messages created during the warm-up
+	pairLatencies map[Pair][]float64
+	rtts          []float64
+	ticks         map[string]TickCounts
+	occupancy     occupancy
+}
+
+// NewStats creates an empty statistics collector
+func NewStats() *Stats {
+	return &Stats{
+		pairLatencies: make(map[Pair][]float64),
+		expiredAt:     make(map[string]int),
+		ticks:         make(map[string]TickCounts),
+	}
+}
+
+// RecordProduced counts a message generated by a producer
+func (s *Stats) RecordProduced(now sim.VTimeInSec, m *msg.DemoMessage) {
+	if s == nil {
+		return
+	}
+	s.Produced++
+	s.occupancy.enter(now, m.ID, m.CreateTime)
+}
+
+// RecordRouted counts a message forwarded by a distributor
+func (s *Stats) RecordRouted() {
+	if s == nil {
+		return
+	}
+	s.Routed++
+}
+
+// RecordConsumed counts a consumed message and its end-to-end latency
+func (s *Stats) RecordConsumed(now sim.VTimeInSec, m *msg.DemoMessage) {
+	if s == nil {
+		return
+	}
+	s.Consumed++
+	if s.Measures(m.CreateTime) {
+		s.latencies = append(s.latencies, float64(now-m.CreateTime))
+	} else {
+		s.early = append(s.early, float64(now-m.CreateTime))
+	}
+	s.occupancy.leave(now, m.ID, false)
+}
+
+// RecordExpired counts a message dropped by a component because its TTL ran
+// out
+func (s *Stats) RecordExpired(component string) {
+	if s == nil {
+		return
+	}
+	s.Expired++
+	s.expiredAt[component]++
+}
+
+// RecordLost counts an outstanding message the producer stopped waiting for
+func (s *Stats) RecordLost() {
+	if s == nil {
+		return
+	}
+	s.Lost++
+}
+
+// RecordCopy counts a copy of a multicast message sent to a member of its
+// group
+func (s *Stats) RecordCopy(now sim.VTimeInSec, branch *msg.DemoMessage) {
+	if s == nil {
+		return
+	}
+	s.Copies++
+	s.occupancy.enter(now, branch.ID, branch.CreateTime)
+}
+
+// RecordFannedOut counts a multicast message replaced by its copies
+func (s *Stats) RecordFannedOut(now sim.VTimeInSec, m *msg.DemoMessage) {
+	if s == nil {
+		return
+	}
+	s.FannedOut++
+	s.occupancy.leave(now, m.ID, true)
+}
+
+// RecordBranchRetry counts the copies of a multicast message that are
+// retried
+func (s *Stats) RecordBranchRetry(n int) {
+	if s == nil {
+		return
+	}
+	s.BranchRetries += n
+}
+
+// ExpiredAt returns the number of expired messages dropped by a component
+func (s *Stats) ExpiredAt(component string) int {
+	return s.expiredAt[component]
+}
+
+// RecordNotification counts a consumer wake-up notification
+func (s *Stats) RecordNotification() {
+	if s == nil {
+		return
+	}
+	s.Notifications++
+}
+
+// RecordPairLatency records the end-to-end latency of a message between a
+// producer and a consumer
+func (s *Stats) RecordPairLatency(producer, consumer string, latency sim.VTimeInSec) {
+	if s == nil {
+		return
+	}
+	pair := Pair{Producer: producer, Consumer: consumer}
+	s.pairLatencies[pair] = append(s.pairLatencies[pair], float64(latency))
+}
+
+// RecordAcked counts an acknowledged message sent at sendTime and its
+// round-trip time
+func (s *Stats) RecordAcked(sendTime, rtt sim.VTimeInSec) {
+	if s == nil {
+		return
+	}
+	s.Acked++
+	if s.Measures(sendTime) {
+		s.rtts = append(s.rtts, float64(rtt))
+	}
+}
+
+// Measures reports whether a message created or sent at the given time
+// counts in the latencies and the throughput, which leave the warm-up out
+func (s *Stats) Measures(t sim.VTimeInSec) bool {
+	return s == nil || t >= s.warmup
+}
+
+// RecordInFlightStall counts a producer tick stalled by the in-flight limit
+func (s *Stats) RecordInFlightStall() {
+	if s == nil {
+		return
+	}
+	s.Stalls++
+}
+
+// RecordConsumerTick counts a consumer tick
+func (s *Stats) RecordConsumerTick() {
+	if s == nil {
+		return
+	}
+	s.ConsumerTicks++
+}
+
+// Func counts the engine events when Stats is registered as an engine hook
+func (s *Stats) Func(ctx sim.HookCtx) {
+	if ctx.Pos == sim.HookPosBeforeEvent {
+		s.EngineEvents++
+	}
+}
+
+// MeanLatency returns the average end-to-end latency in seconds of the
+// messages created after the warm-up
+func (s *Stats) MeanLatency() float64 {
+	return mean(s.latencies)
+}
+
+// totalLatencies returns the latencies of all consumed messages, also of
+// those created during the warm-up
+func (s *Stats) totalLatencies() []float64 {
+	if len(s.early) == 0 {
+		return s.latencies
+	}
+	return append(append([]float64(nil), s.early...), s.latencies...)
+}
+
+// LatencyPercentile returns the p-th percentile (0-100) of the end-to-end
+// latency in seconds
+func (s *Stats) LatencyPercentile(p float64) float64 {
+	return percentile(s.latencies, p)
+}
+
+// MeanRTT returns the average round-trip time from sending a message to
+// receiving its ACK, in seconds
+func (s *Stats) MeanRTT() float64 {
+	return mean(s.rtts)
+}
+
+// RTTPercentile returns the p-th percentile (0-100) of the round-trip time in
+// seconds
+func (s *Stats) RTTPercentile(p float64) float64 {
+	return percentile(s.rtts, p)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the p-th percentile (0-100) of the values using the
+// nearest-rank method
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// Throughput returns the consumed messages per second over the given
+// duration, after the warm-up
+func (s *Stats) Throughput(duration sim.VTimeInSec) float64 {
+	if s.warmup > 0 {
+		if duration <= s.warmup {
+			return 0
+		}
+		return float64(len(s.latencies)) / float64(duration-s.warmup)
+	}
+	return s.TotalThroughput(duration)
+}
+
+// TotalThroughput returns the consumed messages per second over the given
+// duration, including the warm-up
+func (s *Stats) TotalThroughput(duration sim.VTimeInSec) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(s.Consumed) / float64(duration)
+}
+
+// Print writes a human-readable summary of the statistics
+func (s *Stats) Print(out EventSink, duration sim.VTimeInSec) {
+	out.Println("=== Statistics ===")
+	out.Printf("Messages produced: %d\n", s.Produced)
+	out.Printf("Messages routed:   %d\n", s.Routed)
+	out.Printf("Messages consumed: %d\n", s.Consumed)
+	if s.Expired > 0 {
+		out.Printf("Messages expired:  %d (%s)\n", s.Expired, s.expiredBreakdown())
+	}
+	if s.Copies > 0 {
+		out.Printf("Multicast:         %d messages fanned out to %d copies (%d branch retries)\n",
+			s.FannedOut, s.Copies, s.BranchRetries)
+	}
+	out.Printf("Notifications:     %d\n", s.Notifications)
+	out.Printf("Consumer ticks:    %d\n", s.ConsumerTicks)
+	out.Printf("Engine events:     %d\n", s.EngineEvents)
+	if s.warmup > 0 {
+		total := s.totalLatencies()
+		out.Printf("Measured window:   %.2f s to %.2f s, %d of %d consumed messages created in it\n",
+			float64(s.warmup), float64(duration), len(s.latencies), s.Consumed)
+		out.Printf("Mean latency:      %.2f s measured window, %.2f s total\n", s.MeanLatency(), mean(total))
+		out.Printf("p99 latency:       %.2f s measured window, %.2f s total\n",
+			s.LatencyPercentile(99), percentile(total, 99))
+		out.Printf("Throughput:        %.3f msg/s measured window, %.3f msg/s total\n",
+			s.Throughput(duration), s.TotalThroughput(duration))
+	} else {
+		out.Printf("Mean latency:      %.2f s\n", s.MeanLatency())
+		out.Printf("p99 latency:       %.2f s\n", s.LatencyPercentile(99))
+		out.Printf("Throughput:        %.3f msg/s\n", s.Throughput(duration))
+	}
+	out.Println()
+	out.Println("=== Round-Trip Times ===")
+	out.Printf("Messages acked:    %d\n", s.Acked)
+	if s.warmup > 0 {
+		out.Printf("Measured window:   %d sent after the warm-up\n", len(s.rtts))
+	}
+	out.Printf("Unacked at end:    %d\n", s.Produced-s.Acked-s.Lost)
+	if s.Lost > 0 {
+		out.Printf("Lost (expired):    %d\n", s.Lost)
+	}
+	out.Printf("In-flight stalls:  %d\n", s.Stalls)
+	out.Printf("Mean RTT:          %.2f s\n", s.MeanRTT())
+	out.Printf("p50 RTT:           %.2f s\n", s.RTTPercentile(50))
+	out.Printf("p99 RTT:           %.2f s\n", s.RTTPercentile(99))
+	out.Printf("Max RTT:           %.2f s\n", s.RTTPercentile(100))
+	out.Println()
+	s.LittlesLaw(duration).Print(out)
+}
+
+func (s *Stats) expiredBreakdown() string {
+	components := make([]string, 0, len(s.expiredAt))
+	for component := range s.expiredAt {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	parts := make([]string, len(components))
+	for i, component := range components {
+		parts[i] = fmt.Sprintf("%s: %d", component, s.expiredAt[component])
+	}
+	return strings.Join(parts, ", ")
+}
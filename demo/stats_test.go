@@ -0,0 +1,71 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestWarmupLeavesOutStartUp verifies that the messages created during the
+// warm-up only count in the totals
+func TestWarmupLeavesOutStartUp(t *testing.T) {
+	stats := NewStats()
+	stats.warmup = 10
+	stats.RecordConsumed(4, &msg.DemoMessage{ID: 1, CreateTime: 0})
+	stats.RecordConsumed(12, &msg.DemoMessage{ID: 2, CreateTime: 8})
+	stats.RecordConsumed(13, &msg.DemoMessage{ID: 3, CreateTime: 10})
+	stats.RecordConsumed(16, &msg.DemoMessage{ID: 4, CreateTime: 13})
+	stats.RecordAcked(8, 5)
+	stats.RecordAcked(13, 4)
+	
+	if stats.Consumed != 4 || stats.MeanLatency() != 3 || mean(stats.totalLatencies()) != 3.5 {
+		t.Errorf("Expected a mean latency of 3 s measured, 3.5 s total, got %.2f s and %.2f s",
+			stats.MeanLatency(), mean(stats.totalLatencies()))
+	}
+	if stats.Throughput(20) != 0.2 || stats.TotalThroughput(20) != 0.2 {
+		t.Errorf("Expected 0.2 msg/s measured and total, got %.2f and %.2f",
+			stats.Throughput(20), stats.TotalThroughput(20))
+	}
+	if stats.Acked != 2 || stats.MeanRTT() != 4 {
+		t.Errorf("Expected the RTT of the ACK after the warm-up only, got %v", stats.rtts)
+	}
+}
+
+// TestWarmupOfRun verifies that a run with a warm-up measures fewer messages
+// than it consumes, and that Little's law still holds over the whole run
+func TestWarmupOfRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 3
+	cfg.Cycles = 200
+	cfg.Warmup = 50
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	stats := simulation.stats
+	if len(stats.latencies) == 0 || len(stats.early) == 0 || len(stats.latencies)+len(stats.early) != stats.Consumed {
+		t.Errorf("Expected the consumed messages split at the warm-up, got %d measured and %d early of %d",
+			len(stats.latencies), len(stats.early), stats.Consumed)
+	}
+	for pair, latencies := range stats.pairLatencies {
+		if len(latencies) > len(stats.latencies) {
+			t.Errorf("Expected %v to measure after the warm-up only", pair)
+		}
+	}
+	if law := stats.LittlesLaw(simulation.Duration()); !law.Holds() {
+		t.Errorf("Expected Little's law to hold over the whole run, got %+v", law)
+	}
+	
+	cfg.Warmup = 200
+	if err := cfg.Validate(); err == nil {
+		t.Errorf("Expected a warm-up as long as the run to be rejected")
+	}
+}
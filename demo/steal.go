@@ -0,0 +1,102 @@
+package demo
+
+import (
+	"fmt"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// WorkStealing lets idle consumers take messages from the backlogs of their
+// peers. A consumer whose queue runs empty asks its peers in turn, one
+// request at a time, until one gives it work or all of them refused; it asks
+// again once a message arrives. A peer with at least Threshold messages
+// queued gives away the newer half, so that the messages that would wait the
+// longest move. Stolen messages keep their addressee and sequence number, so
+// the verifier reports the ones served ahead of older messages of their
+// sequence.
+type WorkStealing struct {
+	Threshold int
+	Attempts  map[string]int // Steal requests sent by every consumer
+	Successes map[string]int // Requests of every consumer answered with messages
+	Stolen    map[string]int // Messages every consumer took from its peers
+	Given     map[string]int // Messages every consumer gave away
+}
+
+// NewWorkStealing creates the stealing protocol for peers that give work
+// away from a backlog of threshold messages
+func NewWorkStealing(threshold int) *WorkStealing {
+	return &WorkStealing{
+		Threshold: threshold,
+		Attempts:  make(map[string]int),
+		Successes: make(map[string]int),
+		Stolen:    make(map[string]int),
+		Given:     make(map[string]int),
+	}
+}
+
+// connectPeers gives every consumer a steal port and connects the ports, so
+// that every consumer can ask each of the others, starting with the next one
+func connectPeers(topology *Topology, engine sim.Engine, consumers []*consumer.Consumer, stealing *WorkStealing) {
+	ports := consumer.ConnectPeers(consumers, stealing.Threshold, stealing)
+	topology.Connect("ConsumerPeers", engine, ports...)
+}
+
+// Asked counts a steal request of the thief
+func (s *WorkStealing) Asked(thief string) {
+	s.Attempts[thief]++
+}
+
+// Stole counts the n messages a request of the thief was answered with
+func (s *WorkStealing) Stole(thief string, n int) {
+	s.Successes[thief]++
+	s.Stolen[thief] += n
+}
+
+// Gave counts the n messages the victim gave away
+func (s *WorkStealing) Gave(victim string, n int) {
+	s.Given[victim] += n
+}
+
+// Print writes the steal requests of every consumer, how many succeeded,
+// and the messages the consumers took and gave away
+func (s *WorkStealing) Print(out EventSink, consumers []string) {
+	attempts, successes, moved := 0, 0, 0
+	for _, name := range consumers {
+		attempts += s.Attempts[name]
+		successes += s.Successes[name]
+		moved += s.Stolen[name]
+	}
+	out.Println("=== Work Stealing ===")
+	out.Printf("Threshold:         %d messages\n", s.Threshold)
+	out.Printf("Attempted:         %d\n", attempts)
+	if attempts > 0 {
+		out.Printf("Succeeded:         %d (%.1f%%)\n", successes, 100*float64(successes)/float64(attempts))
+	} else {
+		out.Printf("Succeeded:         0\n")
+	}
+	out.Printf("Moved:             %d messages\n", moved)
+	out.Printf("%-16s %8s %10s %8s %8s\n", "Consumer", "Asked", "Succeeded", "Stole", "Gave")
+	for _, name := range consumers {
+		out.Printf("%-16s %8d %10d %8d %8d\n",
+			name, s.Attempts[name], s.Successes[name], s.Stolen[name], s.Given[name])
+	}
+}
+
+// validateStealing checks the steal threshold and that every consumer serves
+// one plain RX queue that nothing else drains or accounts for by consumer
+func (c *Config) validateStealing() error {
+	if !c.Steal {
+		return nil
+	}
+	if c.StealThreshold < 2 {
+		return fmt.Errorf("steal-threshold must be at least 2, so that a victim keeps a message")
+	}
+	if c.RxQueues != 1 || c.ConsumerMode == "batch" || c.CoalesceTime > 0 {
+		return fmt.Errorf("work stealing needs consumers with one RX queue that are not batched or coalesced")
+	}
+	if c.WorkPool || c.DestWindow > 0 || len(c.DestWindows) > 0 {
+		return fmt.Errorf("work stealing cannot be combined with a work pool or destination windows")
+	}
+	return nil
+}
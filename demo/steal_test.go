@@ -0,0 +1,66 @@
+package demo
+
+import (
+	"testing"
+)
+
+// TestWorkStealingRelievesSlowConsumer verifies that idle consumers steal
+// from a slow peer, that every stolen message is consumed once, and that
+// the tail latency drops compared with the same run without stealing
+func TestWorkStealingRelievesSlowConsumer(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 200
+	cfg.ConsumeInterval = 2
+	cfg.ConsumeIntervals = map[string]float64{"Consumer3": 8}
+	silence(t, cfg)
+	
+	var p99 [2]float64
+	for i, steal := range []bool{false, true} {
+		cfg.Steal = steal
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if !simulation.Conservation().Holds() {
+			t.Errorf("Expected every message to be accounted for, got %+v", simulation.Conservation())
+		}
+		if v := simulation.verifier; v.Duplicates > 0 {
+			t.Errorf("Expected no duplicates, got %d", v.Duplicates)
+		}
+		p99[i] = simulation.stats.LatencyPercentile(99)
+		
+		if steal {
+			s := simulation.stealing
+			if s.Given["Consumer3"] == 0 || s.Stolen["Consumer1"]+s.Stolen["Consumer2"] != s.Given["Consumer3"] {
+				t.Errorf("Expected the fast consumers to steal what the slow one gave, got %v and %v", s.Stolen, s.Given)
+			}
+		}
+	}
+	if p99[1] >= p99[0] {
+		t.Errorf("Expected stealing to shorten the p99 latency, got %.2f and %.2f", p99[0], p99[1])
+	}
+}
+
+// TestWorkStealingValidation verifies that a victim always keeps a message
+// and that stealing needs consumers with one RX queue
+func TestWorkStealingValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Steal = true
+	cfg.StealThreshold = 1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a threshold of 1 to be rejected")
+	}
+	cfg.StealThreshold = 2
+	cfg.RxQueues = 2
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected stealing with several RX queues to be rejected")
+	}
+	cfg.RxQueues = 1
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected stealing to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package demo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TrafficFingerprint hashes the messages a producer sends, so that the
+// traffic of two runs can be compared byte for byte
+type TrafficFingerprint struct {
+	hash     hash.Hash
+	Messages int
+}
+
+// NewTrafficFingerprint creates an empty fingerprint
+func NewTrafficFingerprint() *TrafficFingerprint {
+	return &TrafficFingerprint{hash: sha256.New()}
+}
+
+// Func adds every message sent through the hooked port to the fingerprint
+func (f *TrafficFingerprint) Func(ctx sim.HookCtx) {
+	if ctx.Pos != sim.HookPosPortMsgSend {
+		return
+	}
+	m, ok := ctx.Item.(*msg.DemoMessage)
+	if !ok {
+		return
+	}
+	// Message IDs are left out, only what the traffic looks like matters
+	fmt.Fprintf(f.hash, "%.6f|%s|%d|%d|%s\n",
+		float64(m.CreateTime), m.Destination, m.FlowID, m.Priority, m.Content)
+	f.Messages++
+}
+
+// Sum returns the fingerprint as a short hex string
+func (f *TrafficFingerprint) Sum() string {
+	return hex.EncodeToString(f.hash.Sum(nil))[:16]
+}
+
+// SweepResult describes the traffic of one run of a seed sweep
+type SweepResult struct {
+	Seed        int64
+	Messages    int
+	Fingerprint string
+}
+
+// SeedCollision is a pair of sweep runs whose traffic is identical although
+// their seeds differ, or whose seeds are the same although they should not be
+type SeedCollision struct {
+	A, B     int
+	SameSeed bool
+}
+
+// String describes the collision
+func (c SeedCollision) String() string {
+	if c.SameSeed {
+		return fmt.Sprintf("runs %d and %d used the same seed", c.A+1, c.B+1)
+	}
+	return fmt.Sprintf("runs %d and %d produced identical traffic with different seeds", c.A+1, c.B+1)
+}
+
+// RunSeedSweep runs the simulation once per seed and fingerprints the traffic
+// of every run. With a fixed seed, the runs use consecutive seeds; without
+// one, every run seeds itself from the current time.
+func RunSeedSweep(cfg *Config) ([]SweepResult, error) {
+	var results []SweepResult
+	for i := 0; i < cfg.SweepRuns; i++ {
+		runCfg := *cfg
+		if cfg.Seed != 0 {
+			runCfg.Seed = cfg.Seed + int64(i)
+		}
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		simulation.out.Printf("=== Scenario Run: seed sweep %d ===\n", i+1)
+		if err := simulation.Run(); err != nil {
+			return nil, err
+		}
+		simulation.out.Println()
+
+		results = append(results, SweepResult{
+			Seed:        simulation.producers[0].Seed(),
+			Messages:    simulation.fingerprint.Messages,
+			Fingerprint: simulation.fingerprint.Sum(),
+		})
+	}
+	return results, nil
+}
+
+// FindSeedCollisions returns the pairs of runs that share a seed or whose
+// traffic is identical. Runs without traffic are identical by definition
+// and are not compared.
+func FindSeedCollisions(results []SweepResult) []SeedCollision {
+	var collisions []SeedCollision
+	for a := range results {
+		for b := a + 1; b < len(results); b++ {
+			switch {
+			case results[a].Seed == results[b].Seed:
+				collisions = append(collisions, SeedCollision{A: a, B: b, SameSeed: true})
+			case results[a].Messages > 0 && results[a].Fingerprint == results[b].Fingerprint:
+				collisions = append(collisions, SeedCollision{A: a, B: b})
+			}
+		}
+	}
+	return collisions
+}
+
+// PrintSeedSweep writes the fingerprint of every run and the collisions
+// between them
+func PrintSeedSweep(out EventSink, results []SweepResult, collisions []SeedCollision) {
+	out.Println("=== Seed Sweep ===")
+	out.Printf("%-4s %20s %9s  %s\n", "Run", "Seed", "Messages", "Fingerprint")
+	for i, r := range results {
+		out.Printf("%-4d %20d %9d  %s\n", i+1, r.Seed, r.Messages, r.Fingerprint)
+	}
+	if len(collisions) == 0 {
+		out.Println("All runs produced distinct traffic")
+		return
+	}
+	for _, c := range collisions {
+		out.Printf("Error: %s\n", c)
+	}
+}
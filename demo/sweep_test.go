@@ -0,0 +1,67 @@
+package demo
+
+import (
+	"testing"
+)
+
+func fingerprintRun(t *testing.T, seed int64) SweepResult {
+	cfg := DefaultConfig()
+	cfg.Seed = seed
+	cfg.Cycles = 30
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return SweepResult{
+		Seed:        simulation.producers[0].Seed(),
+		Messages:    simulation.fingerprint.Messages,
+		Fingerprint: simulation.fingerprint.Sum(),
+	}
+}
+
+// TestTrafficFingerprintFollowsSeed verifies that runs with the same seed
+// have the same fingerprint and runs with different seeds do not
+func TestTrafficFingerprintFollowsSeed(t *testing.T) {
+	a := fingerprintRun(t, 7)
+	b := fingerprintRun(t, 7)
+	c := fingerprintRun(t, 8)
+	
+	if a.Messages == 0 {
+		t.Fatalf("Expected traffic with seed 7")
+	}
+	if a.Fingerprint != b.Fingerprint {
+		t.Errorf("Expected equal fingerprints with the same seed, got %s and %s", a.Fingerprint, b.Fingerprint)
+	}
+	if a.Fingerprint == c.Fingerprint {
+		t.Errorf("Expected different fingerprints with different seeds")
+	}
+}
+
+// TestFindSeedCollisions verifies that shared seeds and identical traffic
+// with different seeds are flagged, while runs without traffic are not
+func TestFindSeedCollisions(t *testing.T) {
+	results := []SweepResult{
+		{Seed: 1, Messages: 4, Fingerprint: "aaaa"},
+		{Seed: 2, Messages: 4, Fingerprint: "aaaa"},
+		{Seed: 2, Messages: 3, Fingerprint: "bbbb"},
+		{Seed: 3, Messages: 0, Fingerprint: "cccc"},
+		{Seed: 4, Messages: 0, Fingerprint: "cccc"},
+	}
+	
+	collisions := FindSeedCollisions(results)
+	expected := []SeedCollision{
+		{A: 0, B: 1},
+		{A: 1, B: 2, SameSeed: true},
+	}
+	if len(collisions) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, collisions)
+	}
+	for i := range expected {
+		if collisions[i] != expected[i] {
+			t.Errorf("Collision %d: expected %v, got %v", i, expected[i], collisions[i])
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package demo
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// PortState is the state of a port at some point of the run
+type PortState string
+
+// Port states recorded in a port timeline
+const (
+	PortIdle    PortState = "idle"    // Nothing queued and nothing in flight
+	PortBusy    PortState = "busy"    // Messages queued, waiting for the owner
+	PortBlocked PortState = "blocked" // Queue full, or a sent message waits for a full receiver
+)
+
+// PortInterval is a period of time a port spent in one state
+type PortInterval struct {
+	Port  string
+	State PortState
+	Start sim.VTimeInSec
+	End   sim.VTimeInSec
+}
+
+type trackedPort struct {
+	port      sim.Port
+	capacity  int // Incoming buffer size, 0 if the port only sends
+	queued    int // Received messages not yet retrieved
+	inFlight  int // Sent messages not yet received
+	state     PortState
+	since     sim.VTimeInSec
+	intervals []PortInterval
+}
+
+func (p *trackedPort) currentState() PortState {
+	switch {
+	case p.inFlight > 0, p.capacity > 0 && p.queued >= p.capacity:
+		return PortBlocked
+	case p.queued > 0:
+		return PortBusy
+	default:
+		return PortIdle
+	}
+}
+
+// enter closes the current interval if the state changed at time now
+func (p *trackedPort) enter(now sim.VTimeInSec) {
+	state := p.currentState()
+	if state == p.state {
+		return
+	}
+
+	p.close(now)
+	p.state = state
+	p.since = now
+}
+
+func (p *trackedPort) close(now sim.VTimeInSec) {
+	if now <= p.since {
+		// Zero-length states within a single time step are not recorded
+		return
+	}
+
+	n := len(p.intervals)
+	if n > 0 && p.intervals[n-1].State == p.state && p.intervals[n-1].End == p.since {
+		p.intervals[n-1].End = now
+		return
+	}
+	p.intervals = append(p.intervals, PortInterval{
+		Port:  p.port.Name(),
+		State: p.state,
+		Start: p.since,
+		End:   now,
+	})
+}
+
+// PortTimeline records when ports are idle, busy, and blocked. It is a hook
+// registered on every tracked port.
+type PortTimeline struct {
+	timeTeller sim.TimeTeller
+	ports      []*trackedPort
+	byPort     map[sim.Port]*trackedPort
+}
+
+// NewPortTimeline creates an empty timeline that takes the time from the
+// given engine
+func NewPortTimeline(timeTeller sim.TimeTeller) *PortTimeline {
+	return &PortTimeline{
+		timeTeller: timeTeller,
+		byPort:     make(map[sim.Port]*trackedPort),
+	}
+}
+
+// Track starts recording the states of a port. The capacity is the size of
+// the port's incoming buffer, or 0 for a port that only sends.
+func (t *PortTimeline) Track(port sim.Port, capacity int) {
+	p := &trackedPort{
+		port:     port,
+		capacity: capacity,
+		state:    PortIdle,
+	}
+	t.ports = append(t.ports, p)
+	t.byPort[port] = p
+	port.AcceptHook(t)
+}
+
+// Func updates the state of the ports involved in a send, receive, or
+// retrieve
+func (t *PortTimeline) Func(ctx sim.HookCtx) {
+	msg, ok := ctx.Item.(sim.Msg)
+	if !ok {
+		return
+	}
+	now := t.timeTeller.CurrentTime()
+
+	switch ctx.Pos {
+	case sim.HookPosPortMsgSend:
+		if p, ok := t.byPort[msg.Meta().Src]; ok {
+			p.inFlight++
+			p.enter(now)
+		}
+	case sim.HookPosPortMsgRecvd:
+		if p, ok := t.byPort[msg.Meta().Dst]; ok {
+			p.queued++
+			p.enter(now)
+		}
+		if p, ok := t.byPort[msg.Meta().Src]; ok && p.inFlight > 0 {
+			p.inFlight--
+			p.enter(now)
+		}
+	case sim.HookPosPortMsgRetrieve:
+		if p, ok := t.byPort[msg.Meta().Dst]; ok && p.queued > 0 {
+			p.queued--
+			p.enter(now)
+		}
+	}
+}
+
+// Finish closes the open intervals at the end of the run
+func (t *PortTimeline) Finish(now sim.VTimeInSec) {
+	for _, p := range t.ports {
+		p.close(now)
+		p.since = now
+	}
+}
+
+// Intervals returns the recorded intervals of all ports, port by port in the
+// order the ports were tracked
+func (t *PortTimeline) Intervals() []PortInterval {
+	var intervals []PortInterval
+	for _, p := range t.ports {
+		intervals = append(intervals, p.intervals...)
+	}
+	return intervals
+}
+
+// WriteTimeline writes the intervals as CSV with the columns port, state,
+// start (s), and end (s), ready for a Gantt chart
+func (t *PortTimeline) WriteTimeline(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"port", "state", "start", "end"}); err != nil {
+		return err
+	}
+
+	for _, interval := range t.Intervals() {
+		err := cw.Write([]string{
+			interval.Port,
+			string(interval.State),
+			strconv.FormatFloat(float64(interval.Start), 'f', -1, 64),
+			strconv.FormatFloat(float64(interval.End), 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportTimeline writes the port timeline to a CSV file
+func (t *PortTimeline) ExportTimeline(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.WriteTimeline(f)
+}
+
+// Print writes the share of the run every port spent busy and blocked
+func (t *PortTimeline) Print(out EventSink, duration sim.VTimeInSec) {
+	out.Println("=== Port Contention ===")
+	if duration <= 0 {
+		return
+	}
+	out.Printf("%-28s %8s %8s\n", "Port", "Busy", "Blocked")
+	for _, p := range t.ports {
+		var busy, blocked sim.VTimeInSec
+		for _, interval := range p.intervals {
+			switch interval.State {
+			case PortBusy:
+				busy += interval.End - interval.Start
+			case PortBlocked:
+				blocked += interval.End - interval.Start
+			}
+		}
+		out.Printf("%-28s %6.1f %% %6.1f %%\n", p.port.Name(),
+			float64(busy/duration)*100, float64(blocked/duration)*100)
+	}
+}
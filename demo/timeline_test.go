@@ -0,0 +1,92 @@
+package demo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/msg"
+)
+
+type fixedTime struct {
+	now sim.VTimeInSec
+}
+
+func (f *fixedTime) CurrentTime() sim.VTimeInSec {
+	return f.now
+}
+
+// TestPortTimelineRecordsStates verifies that a port is recorded as busy
+// while messages are queued and as blocked while its queue is full
+func TestPortTimelineRecordsStates(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	clock := &fixedTime{}
+	c := consumer.New("Consumer1", engine, 1.0)
+	timeline := NewPortTimeline(clock)
+	timeline.Track(c.InputPort(), 2)
+	
+	recv := func(now sim.VTimeInSec) {
+		clock.now = now
+		m := &msg.DemoMessage{Destination: "Consumer1"}
+		m.Meta().Dst = c.InputPort()
+		c.InputPort().Recv(m)
+	}
+	retrieve := func(now sim.VTimeInSec) {
+		clock.now = now
+		c.InputPort().Retrieve(now)
+	}
+	
+	recv(1)
+	recv(3)
+	retrieve(4)
+	retrieve(6)
+	timeline.Finish(8)
+	
+	expected := []PortInterval{
+		{"Consumer1.In", PortIdle, 0, 1},
+		{"Consumer1.In", PortBusy, 1, 3},
+		{"Consumer1.In", PortBlocked, 3, 4},
+		{"Consumer1.In", PortBusy, 4, 6},
+		{"Consumer1.In", PortIdle, 6, 8},
+	}
+	intervals := timeline.Intervals()
+	if len(intervals) != len(expected) {
+		t.Fatalf("Expected %d intervals, got %v", len(expected), intervals)
+	}
+	for i := range expected {
+		if intervals[i] != expected[i] {
+			t.Errorf("Interval %d: expected %v, got %v", i, expected[i], intervals[i])
+		}
+	}
+}
+
+// TestPortTimelineSkipsZeroLengthStates verifies that a message received and
+// retrieved at the same time does not create an interval
+func TestPortTimelineSkipsZeroLengthStates(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	clock := &fixedTime{now: 2}
+	c := consumer.New("Consumer1", engine, 1.0)
+	timeline := NewPortTimeline(clock)
+	timeline.Track(c.InputPort(), 2)
+	
+	m := &msg.DemoMessage{Destination: "Consumer1"}
+	m.Meta().Dst = c.InputPort()
+	c.InputPort().Recv(m)
+	c.InputPort().Retrieve(2)
+	timeline.Finish(5)
+	
+	intervals := timeline.Intervals()
+	if len(intervals) != 1 || intervals[0].State != PortIdle || intervals[0].End != 5 {
+		t.Errorf("Expected a single idle interval, got %v", intervals)
+	}
+	
+	var buf bytes.Buffer
+	if err := timeline.WriteTimeline(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Consumer1.In,idle,0,5") {
+		t.Errorf("Expected the idle interval in the CSV, got %q", buf.String())
+	}
+}
@@ -0,0 +1,162 @@
+package demo
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// ingressDelay is the shortest time from the generation of a message to its
+// routing: the producer sends at a tick, the distributor routes at its next
+const ingressDelay sim.VTimeInSec = 1
+
+// timestampRecord is a consumed message with both of its creation stamps
+type timestampRecord struct {
+	ID        uint64
+	Producer  string
+	Consumer  string
+	Raw       sim.VTimeInSec // Stamped by the producer's clock
+	Corrected sim.VTimeInSec // Normalized at the ingress
+	Consumed  sim.VTimeInSec
+}
+
+// TimestampCorrector normalizes the creation stamps of messages at the
+// ingress of the distributor. A producer stamps its messages with its own
+// clock, so its skew shifts every latency measured from the stamp. The
+// corrector estimates the offset of a producer as the largest difference
+// between a stamp and the time the message reached the distributor, plus the
+// ingress delay, which the first message routed without waiting makes exact.
+// The statistics of the run then see the corrected stamps. Its methods are
+// safe to call on a nil corrector.
+type TimestampCorrector struct {
+	skews     map[string]sim.VTimeInSec // Configured skew of every producer
+	offsets   map[string]sim.VTimeInSec // Estimated skew of every producer
+	raw       map[string]latencySum
+	corrected map[string]latencySum
+	records   []timestampRecord
+}
+
+// NewTimestampCorrector creates a corrector for producers with the given
+// clock skews
+func NewTimestampCorrector(skews map[string]sim.VTimeInSec) *TimestampCorrector {
+	return &TimestampCorrector{
+		skews:     skews,
+		offsets:   make(map[string]sim.VTimeInSec),
+		raw:       make(map[string]latencySum),
+		corrected: make(map[string]latencySum),
+	}
+}
+
+// Correct updates the offset estimate of the message's producer and moves its
+// creation stamp to the distributor's clock. A message is corrected once,
+// however often the distributor looks at it.
+func (t *TimestampCorrector) Correct(now sim.VTimeInSec, m *msg.DemoMessage) {
+	if t == nil || m.Ingressed() {
+		return
+	}
+	m.MarkIngressed()
+	m.RawCreateTime = m.CreateTime
+
+	offset := m.CreateTime - now + ingressDelay
+	if estimate, ok := t.offsets[m.Source]; !ok || offset > estimate {
+		t.offsets[m.Source] = offset
+	}
+	m.CreateTime -= t.offsets[m.Source]
+}
+
+// Consumed records the latency of a consumed message from both stamps
+func (t *TimestampCorrector) Consumed(now sim.VTimeInSec, consumer string, m *msg.DemoMessage) {
+	if t == nil {
+		return
+	}
+	t.records = append(t.records, timestampRecord{
+		ID:        m.ID,
+		Producer:  m.Source,
+		Consumer:  consumer,
+		Raw:       m.RawCreateTime,
+		Corrected: m.CreateTime,
+		Consumed:  now,
+	})
+
+	raw := t.raw[m.Source]
+	raw.count++
+	raw.total += now - m.RawCreateTime
+	t.raw[m.Source] = raw
+	corrected := t.corrected[m.Source]
+	corrected.count++
+	corrected.total += now - m.CreateTime
+	t.corrected[m.Source] = corrected
+}
+
+// Offset returns the estimated clock offset of a producer
+func (t *TimestampCorrector) Offset(producer string) sim.VTimeInSec {
+	return t.offsets[producer]
+}
+
+// Print writes the configured and estimated skew of every producer and the
+// mean latency of its messages from the raw and the corrected stamps
+func (t *TimestampCorrector) Print(out EventSink) {
+	names := make([]string, 0, len(t.offsets))
+	for name := range t.offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out.Println("=== Clock Skew ===")
+	out.Printf("%-12s %8s %10s %10s %15s\n", "Producer", "Skew", "Estimated", "Raw mean", "Corrected mean")
+	for _, name := range names {
+		out.Printf("%-12s %6.2f s %8.2f s %8.2f s %13.2f s\n",
+			name, float64(t.skews[name]), float64(t.offsets[name]),
+			t.raw[name].mean(), t.corrected[name].mean())
+	}
+}
+
+// WriteTimestamps writes every consumed message as CSV with the columns id,
+// producer, consumer, raw and corrected creation time, consumption time,
+// and raw and corrected latency
+func (t *TimestampCorrector) WriteTimestamps(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"id", "producer", "consumer", "raw_create", "corrected_create",
+		"consumed", "raw_latency", "corrected_latency"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	format := func(v sim.VTimeInSec) string {
+		return strconv.FormatFloat(float64(v), 'f', 4, 64)
+	}
+	for _, r := range t.records {
+		err := cw.Write([]string{
+			strconv.FormatUint(r.ID, 10),
+			r.Producer,
+			r.Consumer,
+			format(r.Raw),
+			format(r.Corrected),
+			format(r.Consumed),
+			format(r.Consumed - r.Raw),
+			format(r.Consumed - r.Corrected),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportTimestamps writes the raw and corrected stamps to a CSV file
+func (t *TimestampCorrector) ExportTimestamps(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.WriteTimestamps(f)
+}
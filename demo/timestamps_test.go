@@ -0,0 +1,76 @@
+package demo
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestTimestampCorrectorEstimatesSkew verifies that the offset estimate
+// becomes exact with the first message routed without waiting, and that
+// later stamps are moved by it
+func TestTimestampCorrectorEstimatesSkew(t *testing.T) {
+	c := NewTimestampCorrector(nil)
+	
+	// Created at 10 by a clock 3 s ahead, routed at 13 after waiting 2 s
+	waited := &msg.DemoMessage{Source: "Producer", CreateTime: 13}
+	c.Correct(13, waited)
+	if c.Offset("Producer") != 1 || waited.CreateTime != 12 {
+		t.Errorf("Expected an offset of 1 and a stamp of 12, got %.2f and %.2f",
+			float64(c.Offset("Producer")), float64(waited.CreateTime))
+	}
+	
+	// Created at 20, routed at 21 without waiting
+	direct := &msg.DemoMessage{Source: "Producer", CreateTime: 23}
+	c.Correct(21, direct)
+	c.Correct(25, direct)
+	if c.Offset("Producer") != 3 || direct.CreateTime != 20 || direct.RawCreateTime != 23 {
+		t.Errorf("Expected an offset of 3 and stamps of 20 and 23, got %.2f, %.2f, and %.2f",
+			float64(c.Offset("Producer")), float64(direct.CreateTime), float64(direct.RawCreateTime))
+	}
+}
+
+// TestClockSkewKeepsCorrectedLatencies verifies that the statistics of a run
+// with a skewed producer match the run without skew, while the raw stamps
+// are shifted by the skew
+func TestClockSkewKeepsCorrectedLatencies(t *testing.T) {
+	run := func(skew float64) *Simulation {
+		cfg := DefaultConfig()
+		cfg.Seed = 3
+		cfg.Cycles = 100
+		cfg.ConsumeInterval = 2
+		if skew != 0 {
+			cfg.ClockSkews = map[string]float64{"Producer": skew}
+		}
+		simulation, err := NewSimulation(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := simulation.Run(); err != nil {
+			t.Fatal(err)
+		}
+		return simulation
+	}
+	
+	base := run(0)
+	skewed := run(4)
+	
+	if base.stats.Consumed == 0 || skewed.stats.MeanLatency() != base.stats.MeanLatency() {
+		t.Errorf("Expected a corrected mean latency of %.2f s, got %.2f s",
+			base.stats.MeanLatency(), skewed.stats.MeanLatency())
+	}
+	raw := skewed.timestamps.raw["Producer"].mean()
+	if math.Abs(raw-(base.stats.MeanLatency()-4)) > 1e-9 {
+		t.Errorf("Expected a raw mean latency of %.2f s, got %.2f s", base.stats.MeanLatency()-4, raw)
+	}
+	
+	var csv strings.Builder
+	if err := skewed.timestamps.WriteTimestamps(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(csv.String(), "\n"); lines != skewed.stats.Consumed+1 {
+		t.Errorf("Expected a row per consumed message, got %d lines for %d messages", lines, skewed.stats.Consumed)
+	}
+}
@@ -0,0 +1,243 @@
+package demo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// matchTopic reports whether a subscription pattern matches a topic. A
+// pattern is a topic, or a prefix followed by "*", which matches every topic
+// that starts with the prefix.
+func matchTopic(pattern, topic string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return pattern == topic
+}
+
+// Subscriptions is the subscription table of the distributor: the patterns
+// every consumer subscribed to, and what was published to every topic. A
+// consumer whose patterns overlap, such as orders/* and orders/eu, receives
+// a single copy of every message and keeps the topics of its other patterns
+// when it unsubscribes from one.
+type Subscriptions struct {
+	topics   []string
+	patterns map[string]map[string]bool // Patterns of every consumer
+
+	Published    map[string]int // Messages fanned out per topic
+	Copies       map[string]int // Copies sent per topic
+	Overlaps     int            // Copies not sent twice to consumers with overlapping patterns
+	Unsubscribed int            // Patterns given up
+}
+
+// NewSubscriptions creates an empty subscription table of the topics
+func NewSubscriptions(topics []string) *Subscriptions {
+	return &Subscriptions{
+		topics:    topics,
+		patterns:  make(map[string]map[string]bool),
+		Published: make(map[string]int),
+		Copies:    make(map[string]int),
+	}
+}
+
+// Topics returns the topics a pattern matches
+func (s *Subscriptions) Topics(pattern string) []string {
+	var topics []string
+	for _, topic := range s.topics {
+		if matchTopic(pattern, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// Subscribe adds a pattern of a consumer. It returns false if the consumer
+// already subscribed to it.
+func (s *Subscriptions) Subscribe(consumer, pattern string) bool {
+	if s.patterns[consumer] == nil {
+		s.patterns[consumer] = make(map[string]bool)
+	}
+	if s.patterns[consumer][pattern] {
+		return false
+	}
+	s.patterns[consumer][pattern] = true
+	return true
+}
+
+// Unsubscribe removes a pattern of a consumer. It returns false if the
+// consumer did not subscribe to it.
+func (s *Subscriptions) Unsubscribe(consumer, pattern string) bool {
+	if !s.patterns[consumer][pattern] {
+		return false
+	}
+	delete(s.patterns[consumer], pattern)
+	s.Unsubscribed++
+	return true
+}
+
+// Subscribers returns the sorted consumers with a pattern that matches a
+// topic, every consumer once, and whether the topic exists
+func (s *Subscriptions) Subscribers(topic string) ([]string, bool) {
+	known := false
+	for _, t := range s.topics {
+		known = known || t == topic
+	}
+	if !known {
+		return nil, false
+	}
+	var subscribers []string
+	for consumer, patterns := range s.patterns {
+		for pattern := range patterns {
+			if matchTopic(pattern, topic) {
+				subscribers = append(subscribers, consumer)
+				break
+			}
+		}
+	}
+	sort.Strings(subscribers)
+	return subscribers, true
+}
+
+// overlaps returns the number of copies of a message on a topic the members
+// would have received if every matching pattern got its own copy, beyond
+// the one they do receive
+func (s *Subscriptions) overlaps(topic string, members []string) int {
+	n := 0
+	for _, consumer := range members {
+		matches := 0
+		for pattern := range s.patterns[consumer] {
+			if matchTopic(pattern, topic) {
+				matches++
+			}
+		}
+		if matches > 1 {
+			n += matches - 1
+		}
+	}
+	return n
+}
+
+// Patterns returns the sorted patterns of a consumer
+func (s *Subscriptions) Patterns(consumer string) []string {
+	patterns := make([]string, 0, len(s.patterns[consumer]))
+	for pattern := range s.patterns[consumer] {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// Subscriptions returns the sorted patterns of every consumer that
+// subscribed
+func (s *Subscriptions) Subscriptions() map[string][]string {
+	subscriptions := make(map[string][]string)
+	for consumer := range s.patterns {
+		subscriptions[consumer] = s.Patterns(consumer)
+	}
+	return subscriptions
+}
+
+// String lists the topics
+func (s *Subscriptions) String() string {
+	return strings.Join(s.topics, ", ")
+}
+
+// Publish records a message on a topic fanned out to its subscribers
+func (s *Subscriptions) Publish(topic string, members []string, copies int) {
+	s.Published[topic]++
+	s.Copies[topic] += copies
+	s.Overlaps += s.overlaps(topic, members)
+}
+
+// Print writes the messages and copies of every topic and the subscribers
+// at the end of the run
+func (s *Subscriptions) Print(out EventSink) {
+	out.Println("=== Topics ===")
+	out.Printf("%-16s %9s %7s  %s\n", "Topic", "Published", "Copies", "Subscribers")
+	for _, topic := range s.topics {
+		subscribers, _ := s.Subscribers(topic)
+		out.Printf("%-16s %9d %7d  %s\n", topic, s.Published[topic], s.Copies[topic], strings.Join(subscribers, " "))
+	}
+	out.Printf("Overlapping:       %d copies not sent twice\n", s.Overlaps)
+	out.Printf("Unsubscriptions:   %d\n", s.Unsubscribed)
+}
+
+// checkSubscriptions checks that the consumers that subscribe and
+// unsubscribe are consumers
+func checkSubscriptions(cfg *Config, consumers []string) error {
+	known := make(map[string]bool)
+	for _, name := range consumers {
+		known[name] = true
+	}
+	for _, lists := range []map[string][]string{cfg.Subscriptions, cfg.Unsubscriptions} {
+		for name := range lists {
+			if !known[name] {
+				return fmt.Errorf("unknown consumer %q in subscriptions", name)
+			}
+		}
+	}
+	for name := range cfg.UnsubscribeAt {
+		if !known[name] {
+			return fmt.Errorf("unknown consumer %q in unsubscribe-at", name)
+		}
+	}
+	return nil
+}
+
+// TopicsLeft returns the topic patterns the consumer unsubscribes from at
+// its UnsubscribeAt, the ones it subscribed to unless its Unsubscriptions
+// say otherwise
+func (c *Config) TopicsLeft(name string) []string {
+	if unsubscriptions, ok := c.Unsubscriptions[name]; ok {
+		return unsubscriptions
+	}
+	return c.Subscriptions[name]
+}
+
+// validateTopics checks that the topics are named, that only generated
+// traffic on a single distributor is published to them, and that every
+// subscription matches a topic
+func (c *Config) validateTopics() error {
+	if len(c.Topics) == 0 {
+		if len(c.Subscriptions) > 0 || len(c.Unsubscriptions) > 0 || len(c.UnsubscribeAt) > 0 {
+			return fmt.Errorf("subscriptions need topics")
+		}
+		return nil
+	}
+	for _, topic := range c.Topics {
+		if topic == "" || strings.Contains(topic, "*") {
+			return fmt.Errorf("invalid topic %q", topic)
+		}
+	}
+	if c.Multicast > 0 {
+		return fmt.Errorf("topics and multicast cannot be combined")
+	}
+	if c.TraceFile != "" || c.TrafficMatrixFile != "" {
+		return fmt.Errorf("topics need generated traffic, not a trace or a traffic matrix")
+	}
+	if c.DistributorDepth > 1 {
+		return fmt.Errorf("topics need a single distributor")
+	}
+	subscriptions := NewSubscriptions(c.Topics)
+	for _, lists := range []map[string][]string{c.Subscriptions, c.Unsubscriptions} {
+		for name, patterns := range lists {
+			for _, pattern := range patterns {
+				if len(subscriptions.Topics(pattern)) == 0 {
+					return fmt.Errorf("subscription %s of %s matches no topic", pattern, name)
+				}
+			}
+		}
+	}
+	for name, at := range c.UnsubscribeAt {
+		if at <= 0 {
+			return fmt.Errorf("unsubscribe-at of %s must be positive", name)
+		}
+	}
+	for name := range c.Unsubscriptions {
+		if _, ok := c.UnsubscribeAt[name]; !ok {
+			return fmt.Errorf("unsubscribe needs an unsubscribe-at time for %s", name)
+		}
+	}
+	return nil
+}
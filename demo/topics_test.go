@@ -0,0 +1,106 @@
+package demo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestSubscriptionsOverlap verifies that a consumer with overlapping
+// patterns is a subscriber once, and keeps the topics of its other patterns
+// when it unsubscribes from one
+func TestSubscriptionsOverlap(t *testing.T) {
+	s := NewSubscriptions([]string{"orders/eu", "orders/us", "payments"})
+	s.Subscribe("Consumer1", "orders/*")
+	s.Subscribe("Consumer1", "orders/eu")
+	s.Subscribe("Consumer2", "orders/eu")
+	if s.Subscribe("Consumer2", "orders/eu") {
+		t.Error("Expected a second subscription to the same pattern to be ignored")
+	}
+	
+	if got, _ := s.Subscribers("orders/eu"); !reflect.DeepEqual(got, []string{"Consumer1", "Consumer2"}) {
+		t.Errorf("Expected Consumer1 and Consumer2 to subscribe to orders/eu once each, got %v", got)
+	}
+	if n := s.overlaps("orders/eu", []string{"Consumer1", "Consumer2"}); n != 1 {
+		t.Errorf("Expected 1 copy saved for Consumer1, got %d", n)
+	}
+	if _, ok := s.Subscribers("orders/asia"); ok {
+		t.Error("Expected orders/asia not to be a topic")
+	}
+	
+	if !s.Unsubscribe("Consumer1", "orders/*") || s.Unsubscribe("Consumer1", "orders/*") {
+		t.Error("Expected orders/* to be unsubscribed once")
+	}
+	if got, _ := s.Subscribers("orders/eu"); !reflect.DeepEqual(got, []string{"Consumer1", "Consumer2"}) {
+		t.Errorf("Expected Consumer1 to keep orders/eu, got %v", got)
+	}
+	if got, _ := s.Subscribers("orders/us"); len(got) != 0 {
+		t.Errorf("Expected no subscriber of orders/us, got %v", got)
+	}
+}
+
+// TestTopicsReachSubscribersOnly verifies that published messages are copied
+// to the subscribers of their topic, once to every subscriber, and are
+// dead-lettered once their topic has no subscriber left
+func TestTopicsReachSubscribersOnly(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 60
+	cfg.Topics = []string{"orders/eu", "orders/us", "payments"}
+	cfg.Subscriptions = map[string][]string{
+		"Consumer1": {"orders/*", "orders/eu"},
+		"Consumer2": {"orders/eu", "payments"},
+		"Consumer3": {"payments"},
+	}
+	cfg.Unsubscriptions = map[string][]string{"Consumer1": {"orders/*"}}
+	cfg.UnsubscribeAt = map[string]float64{"Consumer1": 30}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	s := simulation.routing.subscriptions
+	// Consumer1 matched orders/eu twice until it unsubscribed from orders/*
+	if s.Published["payments"] == 0 || s.Overlaps == 0 || s.Overlaps > s.Published["orders/eu"] {
+		t.Fatalf("Expected payments and orders/eu to be published, and Consumer1 to get one copy of orders/eu, got %v and %d overlaps",
+			s.Published, s.Overlaps)
+	}
+	if got := simulation.distributor.Routed("Consumer3"); got != s.Published["payments"] {
+		t.Errorf("Expected Consumer3 to get the %d payments only, got %d", s.Published["payments"], got)
+	}
+	if simulation.deadLetters.Count(msg.ReasonNoSubscriber) == 0 {
+		t.Error("Expected orders/us to be dead-lettered after Consumer1 unsubscribed")
+	}
+	if simulation.verifier.Duplicates != 0 || !simulation.Conservation().Holds() {
+		t.Errorf("Expected no duplicates and every copy accounted for, got %d duplicates and %+v",
+			simulation.verifier.Duplicates, simulation.Conservation())
+	}
+}
+
+// TestSubscriptionsMustMatchTopics verifies that the configuration rejects
+// subscriptions without topics and patterns that match no topic
+func TestSubscriptionsMustMatchTopics(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Subscriptions = map[string][]string{"Consumer1": {"payments"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected subscriptions without topics to be rejected")
+	}
+	cfg.Topics = []string{"orders/eu"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a subscription to a missing topic to be rejected")
+	}
+	cfg.Subscriptions = map[string][]string{"Consumer1": {"orders/*"}}
+	cfg.Unsubscriptions = map[string][]string{"Consumer1": {"orders/*"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an unsubscription without a time to be rejected")
+	}
+	cfg.UnsubscribeAt = map[string]float64{"Consumer1": 30}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a wildcard subscription to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package demo
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ProducerSpec describes a producer of a run
+type ProducerSpec struct {
+	Name        string
+	Probability float64 // Chance to generate a message per cycle, 0 follows the traffic model of the config
+}
+
+// ConsumerSpec describes a consumer of a run
+type ConsumerSpec struct {
+	Name          string
+	Interval      float64 // Seconds between two consumed messages per RX queue
+	QueueCapacity int     // Messages an RX queue holds
+}
+
+// TopologySpec lists the producers and consumers of a run
+type TopologySpec struct {
+	Producers []ProducerSpec
+	Consumers []ConsumerSpec
+}
+
+// Ranges of the values drawn for random topologies
+const (
+	minProduceProbability = 0.05
+	maxProduceProbability = 0.6
+	minConsumeInterval    = 0.5
+	maxConsumeInterval    = 6.0
+	minQueueCapacity      = 2
+	maxQueueCapacity      = 16
+)
+
+// RandomTopologySpec draws a topology with 1 to maxProducers producers and
+// 1 to maxConsumers consumers, each with a random rate, and RX queues of a
+// random capacity of at least minCapacity messages
+func RandomTopologySpec(rng *rand.Rand, maxProducers, maxConsumers, minCapacity int) TopologySpec {
+	var spec TopologySpec
+
+	numProducers := 1 + rng.Intn(maxProducers)
+	for i := 0; i < numProducers; i++ {
+		spec.Producers = append(spec.Producers, ProducerSpec{
+			Name:        producerName(i, numProducers),
+			Probability: minProduceProbability + rng.Float64()*(maxProduceProbability-minProduceProbability),
+		})
+	}
+
+	if minCapacity < minQueueCapacity {
+		minCapacity = minQueueCapacity
+	}
+	numConsumers := 1 + rng.Intn(maxConsumers)
+	for i := 0; i < numConsumers; i++ {
+		spec.Consumers = append(spec.Consumers, ConsumerSpec{
+			Name:          fmt.Sprintf("Consumer%d", i+1),
+			Interval:      minConsumeInterval + rng.Float64()*(maxConsumeInterval-minConsumeInterval),
+			QueueCapacity: minCapacity + rng.Intn(maxQueueCapacity-minCapacity+1),
+		})
+	}
+
+	return spec
+}
+
+// producerName names the i-th of n producers. A single producer is just
+// "Producer".
+func producerName(i, n int) string {
+	if n == 1 {
+		return "Producer"
+	}
+	return fmt.Sprintf("Producer%d", i+1)
+}
+
+// ConsumerNames returns the names of the consumers
+func (s TopologySpec) ConsumerNames() []string {
+	names := make([]string, len(s.Consumers))
+	for i, c := range s.Consumers {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// String summarizes the topology in one line
+func (s TopologySpec) String() string {
+	return fmt.Sprintf("%s, %s", count(len(s.Producers), "producer"), count(len(s.Consumers), "consumer"))
+}
+
+// count formats n things of a kind
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// fuzzWatchdog is the stall timeout of fuzz runs that do not set one
+const fuzzWatchdog = 20
+
+// FuzzResult is the outcome of one run of the topology fuzzer
+type FuzzResult struct {
+	Seed         int64
+	Topology     TopologySpec
+	Conservation Conservation
+	Ordered      bool // Whether the configuration promises in-order delivery
+	Reordered    int
+	Duplicates   int
+	Stalls       int
+}
+
+// Failed tells whether the run lost, duplicated, or stuck messages, or
+// reordered them although it promises in-order delivery
+func (r FuzzResult) Failed() bool {
+	if r.Ordered && r.Reordered > 0 {
+		return true
+	}
+	return !r.Conservation.Holds() || r.Duplicates > 0 || r.Stalls > 0
+}
+
+// RunTopologyFuzz runs the simulation on a random topology per seed and
+// checks that every run accounts for all messages, delivers none twice, and
+// does not stall. Messages must also arrive in order, unless several RX
+// queues per consumer let them overtake each other. With a fixed seed, the
+// runs use consecutive seeds.
+func RunTopologyFuzz(cfg *Config) ([]FuzzResult, error) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	var results []FuzzResult
+	for i := 0; i < cfg.SweepRuns; i++ {
+		runCfg := *cfg
+		runCfg.Seed = seed + int64(i)
+		runCfg.RandomTopology = true
+		if runCfg.Watchdog == 0 {
+			runCfg.Watchdog = fuzzWatchdog
+		}
+
+		simulation, err := NewSimulation(&runCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		simulation.out.Printf("=== Scenario Run: topology fuzz %d (%s) ===\n", i+1, simulation.spec)
+		if err := simulation.Run(); err != nil {
+			return nil, err
+		}
+		simulation.out.Println()
+
+		results = append(results, FuzzResult{
+			Seed:         runCfg.Seed,
+			Topology:     simulation.spec,
+			Conservation: simulation.Conservation(),
+			Ordered:      runCfg.RxQueues == 1,
+			Reordered:    simulation.verifier.Reordered,
+			Duplicates:   simulation.verifier.Duplicates,
+			Stalls:       len(simulation.watchdog.Stalls),
+		})
+	}
+	return results, nil
+}
+
+// PrintTopologyFuzz writes the topology and the checks of every run. It
+// returns false if a run failed.
+func PrintTopologyFuzz(out EventSink, results []FuzzResult) bool {
+	out.Println("=== Topology Fuzz ===")
+	out.Printf("%-4s %20s %10s %10s %9s %9s %10s %11s %7s  %s\n",
+		"Run", "Seed", "Producers", "Consumers", "Produced", "Consumed", "Reordered", "Duplicates", "Stalls", "Result")
+	passed := true
+	for i, r := range results {
+		result := "OK"
+		if r.Failed() {
+			result = "FAILED"
+			passed = false
+		}
+		out.Printf("%-4d %20d %10d %10d %9d %9d %10d %11d %7d  %s\n",
+			i+1, r.Seed, len(r.Topology.Producers), len(r.Topology.Consumers),
+			r.Conservation.Produced, r.Conservation.Consumed, r.Reordered, r.Duplicates, r.Stalls, result)
+	}
+	if passed {
+		out.Println("All topologies conserved their messages without duplicates or stalls")
+	}
+	return passed
+}
@@ -0,0 +1,86 @@
+package demo
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestRandomTopologyFollowsSeed verifies that the same seed draws the same
+// topology and that all values stay within their ranges
+func TestRandomTopologyFollowsSeed(t *testing.T) {
+	a := RandomTopologySpec(rand.New(rand.NewSource(3)), 4, 8, 0)
+	b := RandomTopologySpec(rand.New(rand.NewSource(3)), 4, 8, 0)
+	
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("Expected the same topology for the same seed, got %v and %v", a, b)
+	}
+	for seed := int64(1); seed <= 50; seed++ {
+		spec := RandomTopologySpec(rand.New(rand.NewSource(seed)), 4, 8, 5)
+		if len(spec.Producers) < 1 || len(spec.Producers) > 4 || len(spec.Consumers) < 1 || len(spec.Consumers) > 8 {
+			t.Fatalf("Seed %d: topology out of range: %v", seed, spec)
+		}
+		for _, p := range spec.Producers {
+			if p.Probability < minProduceProbability || p.Probability > maxProduceProbability {
+				t.Errorf("Seed %d: %s generates with probability %.2f", seed, p.Name, p.Probability)
+			}
+		}
+		for _, c := range spec.Consumers {
+			if c.Interval < minConsumeInterval || c.Interval > maxConsumeInterval {
+				t.Errorf("Seed %d: %s consumes every %.2f seconds", seed, c.Name, c.Interval)
+			}
+			if c.QueueCapacity < 5 || c.QueueCapacity > maxQueueCapacity {
+				t.Errorf("Seed %d: %s holds %d messages", seed, c.Name, c.QueueCapacity)
+			}
+		}
+	}
+}
+
+// TestRandomTopologiesConserveMessages verifies that runs on random
+// topologies, some with several producers, pass the checks of the fuzzer
+func TestRandomTopologiesConserveMessages(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 8
+	cfg.Cycles = 100
+	cfg.SweepRuns = 3
+	
+	results, err := RunTopologyFuzz(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	producers := 0
+	for _, r := range results {
+		producers += len(r.Topology.Producers)
+		if r.Failed() {
+			t.Errorf("Seed %d: run failed: %+v", r.Seed, r)
+		}
+	}
+	if producers == len(results) {
+		t.Error("Expected at least one topology with several producers")
+	}
+}
+
+// TestMultipleProducersUseDistinctIDs verifies that the messages of several
+// producers are all acknowledged to the producer that sent them
+func TestMultipleProducersUseDistinctIDs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 8
+	cfg.Cycles = 100
+	cfg.RandomTopology = true
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(simulation.producers) < 2 {
+		t.Fatalf("Expected several producers for seed 8, got %d", len(simulation.producers))
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	stats := simulation.stats
+	if stats.Produced == 0 || stats.Acked != stats.Produced {
+		t.Errorf("Expected all %d messages to be acknowledged, got %d", stats.Produced, stats.Acked)
+	}
+}
@@ -0,0 +1,245 @@
+package demo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// topologyConn is a connection and the ports plugged into it
+type topologyConn struct {
+	name  string
+	conn  sim.Connection
+	lossy sim.Connection // Loses messages sent over conn, nil if no fault does
+	ports []sim.Port
+}
+
+// Topology records the connections of a simulation as they are made, so
+// that the wiring can be drawn with Graphviz and checked before running
+type Topology struct {
+	connections []topologyConn
+	sendBuffers map[sim.Port]int           // Sizes of the send buffers other than 1
+	links       func(name string) LinkSpec // Transport model of the connections, nil for ideal ones
+	arbiter     func() Arbiter             // Creates the arbiter of every link, nil for round-robin
+	audit       *ArbitrationAudit          // Audits the arbiters of the links, nil for none
+	faults      *FaultInjector             // Loses messages on the connections, nil loses none
+}
+
+// SetSendBuffer sets the number of messages a port can have sent that its
+// connection has not delivered yet. Akita buffers these messages in the
+// connection, so it must be set before the port is connected.
+func (t *Topology) SetSendBuffer(port sim.Port, size int) {
+	if t.sendBuffers == nil {
+		t.sendBuffers = make(map[sim.Port]int)
+	}
+	t.sendBuffers[port] = size
+}
+
+// SetLinks models the connections made from now on as links with the
+// latency and bandwidth spec returns for their names
+func (t *Topology) SetLinks(spec func(name string) LinkSpec) {
+	t.links = spec
+}
+
+// SetArbitration gives the links made from now on an arbiter created by
+// newArbiter and audits their grants if audit is not nil
+func (t *Topology) SetArbitration(newArbiter func() Arbiter, audit *ArbitrationAudit) {
+	t.arbiter = newArbiter
+	t.audit = audit
+}
+
+// SetFaults lets the loss faults of the injector take messages sent over
+// the connections made from now on
+func (t *Topology) SetFaults(faults *FaultInjector) {
+	t.faults = faults
+}
+
+// Connect creates a connection and plugs the ports into it, with a send
+// buffer of one message unless set otherwise. The connection is a direct
+// one unless its link has a latency or a bandwidth. The ports are also
+// registered with the components that own them, so that tools such as
+// Akita's monitor find them.
+func (t *Topology) Connect(name string, engine sim.Engine, ports ...sim.Port) sim.Connection {
+	var conn sim.Connection = sim.NewDirectConnection(name, engine, 1*sim.Hz)
+	if t.links != nil {
+		if spec := t.links(name); !spec.Ideal() {
+			link := NewLink(name, engine, 1*sim.Hz, spec)
+			if t.arbiter != nil {
+				link.arbiter = t.arbiter()
+			}
+			link.audit = t.audit
+			conn = link
+		}
+	}
+	for _, port := range ports {
+		size := 1
+		if s, ok := t.sendBuffers[port]; ok {
+			size = s
+		}
+		conn.PlugIn(port, size)
+		port.Component().AddPort(port.Name(), port)
+	}
+	lossy := t.faults.Wrap(name, conn, engine)
+	if lossy != nil {
+		// The ports send through the lossy connection, which passes the
+		// messages it does not lose on to conn
+		for _, port := range ports {
+			port.SetConnection(lossy)
+		}
+	}
+	t.connections = append(t.connections, topologyConn{name: name, conn: conn, lossy: lossy, ports: ports})
+	return conn
+}
+
+// Record records a fabric built outside the topology, such as a switch
+// network, as a connection of the ports plugged into it
+func (t *Topology) Record(name string, ports ...sim.Port) {
+	t.connections = append(t.connections, topologyConn{name: name, ports: ports})
+}
+
+// PlugIn plugs a port into the connection made under the name, with a send
+// buffer of one message, for components added while the run goes on
+func (t *Topology) PlugIn(name string, port sim.Port) error {
+	for i := range t.connections {
+		conn := &t.connections[i]
+		if conn.name != name {
+			continue
+		}
+		conn.conn.PlugIn(port, 1)
+		if conn.lossy != nil {
+			port.SetConnection(conn.lossy)
+		}
+		port.Component().AddPort(port.Name(), port)
+		conn.ports = append(conn.ports, port)
+		return nil
+	}
+	return fmt.Errorf("no connection %q", name)
+}
+
+// Unplug removes a port from its connection, for components removed while
+// the run goes on. Akita's direct connections cannot unplug ports, so ports
+// stay plugged into them but are no longer drawn.
+func (t *Topology) Unplug(port sim.Port) {
+	for i := range t.connections {
+		conn := &t.connections[i]
+		for j, p := range conn.ports {
+			if p != port {
+				continue
+			}
+			conn.ports = append(conn.ports[:j:j], conn.ports[j+1:]...)
+			if link, ok := conn.conn.(*Link); ok {
+				link.Unplug(port)
+			}
+			return
+		}
+	}
+}
+
+// HasConnection reports whether a connection was made under the name
+func (t *Topology) HasConnection(name string) bool {
+	for _, conn := range t.connections {
+		if conn.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Links returns the connections modeled as links
+func (t *Topology) Links() []*Link {
+	var links []*Link
+	for _, conn := range t.connections {
+		if link, ok := conn.conn.(*Link); ok {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// WriteDOT writes the topology as a Graphviz graph: every component is a
+// cluster of its ports, and every connection is a node linked to the ports
+// plugged into it
+func (t *Topology) WriteDOT(w io.Writer) error {
+	components, ports := t.portsByComponent()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "graph topology {")
+	fmt.Fprintln(bw, "\trankdir=LR;")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for i, component := range components {
+		fmt.Fprintf(bw, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "\t\tlabel=%q;\n", component)
+		for _, port := range ports[component] {
+			label := strings.TrimPrefix(port.Name(), component+".")
+			fmt.Fprintf(bw, "\t\t%q [label=%q];\n", port.Name(), label)
+		}
+		fmt.Fprintln(bw, "\t}")
+	}
+	for _, conn := range t.connections {
+		fmt.Fprintf(bw, "\t%q [shape=ellipse];\n", conn.name)
+		for _, port := range conn.ports {
+			fmt.Fprintf(bw, "\t%q -- %q;\n", port.Name(), conn.name)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteMermaid writes the topology as a Mermaid flowchart, laid out like the
+// Graphviz graph. Mermaid IDs cannot contain dots, so nodes are numbered and
+// labeled with their names.
+func (t *Topology) WriteMermaid(w io.Writer) error {
+	components, ports := t.portsByComponent()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "flowchart LR")
+	ids := make(map[sim.Port]string)
+	for i, component := range components {
+		fmt.Fprintf(bw, "    subgraph component%d [%q]\n", i, component)
+		for _, port := range ports[component] {
+			ids[port] = fmt.Sprintf("port%d", len(ids))
+			label := strings.TrimPrefix(port.Name(), component+".")
+			fmt.Fprintf(bw, "        %s[%q]\n", ids[port], label)
+		}
+		fmt.Fprintln(bw, "    end")
+	}
+	for i, conn := range t.connections {
+		fmt.Fprintf(bw, "    conn%d([%q])\n", i, conn.name)
+		for _, port := range conn.ports {
+			fmt.Fprintf(bw, "    %s --- conn%d\n", ids[port], i)
+		}
+	}
+	return bw.Flush()
+}
+
+// portsByComponent groups the connected ports by component, in the order
+// they were connected
+func (t *Topology) portsByComponent() ([]string, map[string][]sim.Port) {
+	var components []string
+	ports := make(map[string][]sim.Port)
+	for _, conn := range t.connections {
+		for _, port := range conn.ports {
+			name := port.Component().Name()
+			if _, ok := ports[name]; !ok {
+				components = append(components, name)
+			}
+			ports[name] = append(ports[name], port)
+		}
+	}
+	return components, ports
+}
+
+// ExportDOT writes the topology to a Graphviz file
+func (t *Topology) ExportDOT(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return t.WriteDOT(f)
+}
@@ -0,0 +1,59 @@
+package demo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestTopologyDrawsEveryConnection verifies that the Graphviz graph of a
+// simulation links every port to its connection and groups the ports by
+// component
+func TestTopologyDrawsEveryConnection(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RxQueues = 2
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	var buf bytes.Buffer
+	if err := simulation.topology.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+	
+	for _, line := range []string{
+		`"Producer.Out" -- "ProducerToDistributor";`,
+		`"Distributor.In" -- "ProducerToDistributor";`,
+		`"Consumer2.In1" -- "DistributorToConsumer2";`,
+		`"DeadLetterSink.In" -- "DistributorToDeadLetterSink";`,
+		`"Consumer3.Ctrl" -- "ControlPlane";`,
+		`label="Distributor";`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Expected the graph to contain %s", line)
+		}
+	}
+	if n := strings.Count(dot, "subgraph"); n != 6 {
+		t.Errorf("Expected 6 components, got %d", n)
+	}
+}
+
+// TestConnectRegistersPortsWithComponents verifies that every connected port
+// can be found through the component that owns it
+func TestConnectRegistersPortsWithComponents(t *testing.T) {
+	simulation, err := NewSimulation(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if n := len(simulation.distributor.Ports()); n != 6 {
+		t.Errorf("Expected the distributor to own 6 ports, got %d", n)
+	}
+	port := simulation.consumers[0].GetPortByName("Consumer1.In")
+	if port != simulation.consumers[0].InputPort() {
+		t.Errorf("Expected Consumer1.In to be the input port of Consumer1")
+	}
+}
@@ -0,0 +1,172 @@
+package demo
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// LoadTrace reads a trace file. Every non-empty line that does not start with
+// '#' has the form "timestamp,destination,size". A header line starting with
+// "timestamp" is skipped. Records are returned sorted by time.
+func LoadTrace(path string) ([]producer.TraceRecord, error) {
+	stream, err := OpenTrace(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	return stream.Next(stream.Buffered()), nil
+}
+
+// traceEntry is a record read ahead with the line it was read from, which
+// keeps records of the same time in the order of the file
+type traceEntry struct {
+	producer.TraceRecord
+	line int
+}
+
+// traceHeap orders the records read ahead by time and line
+type traceHeap []traceEntry
+
+func (h traceHeap) Len() int { return len(h) }
+func (h traceHeap) Less(i, j int) bool {
+	if h[i].Time != h[j].Time {
+		return h[i].Time < h[j].Time
+	}
+	return h[i].line < h[j].line
+}
+func (h traceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *traceHeap) Push(x interface{}) { *h = append(*h, x.(traceEntry)) }
+func (h *traceHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// TraceStream reads the records of a trace file incrementally, so that a
+// trace larger than the memory can be replayed. A window of records is read
+// ahead and sorted, so records may be out of order by less than the window;
+// a record that is further out of order is an error.
+type TraceStream struct {
+	path    string
+	f       *os.File
+	scanner *bufio.Scanner
+	lineNum int
+	window  int            // Records read ahead, 0 reads the whole file
+	ahead   traceHeap      // Records read ahead, earliest first
+	last    sim.VTimeInSec // Time of the last record returned
+	eof     bool
+	err     error
+}
+
+// OpenTrace opens a trace file and reads the first window of records ahead,
+// so that errors in the head of the file are reported right away. A window
+// of 0 reads the whole file.
+func OpenTrace(path string, window int) (*TraceStream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &TraceStream{
+		path:    path,
+		f:       f,
+		scanner: bufio.NewScanner(f),
+		window:  window,
+	}
+	s.fill()
+	if s.err != nil {
+		f.Close()
+		return nil, s.err
+	}
+	return s, nil
+}
+
+// fill reads records until the window is full or the file ends
+func (s *TraceStream) fill() {
+	for !s.eof && s.err == nil && (s.window == 0 || len(s.ahead) < s.window) {
+		if !s.scanner.Scan() {
+			s.eof = true
+			s.err = s.scanner.Err()
+			return
+		}
+		s.lineNum++
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "timestamp") {
+			continue
+		}
+
+		record, err := parseTraceRecord(line)
+		if err != nil {
+			s.err = fmt.Errorf("%s:%d: %w", s.path, s.lineNum, err)
+			return
+		}
+		if record.Time < s.last {
+			s.err = fmt.Errorf("%s:%d: record at %.2f is out of order by more than the read-ahead window of %d records",
+				s.path, s.lineNum, float64(record.Time), s.window)
+			return
+		}
+		heap.Push(&s.ahead, traceEntry{TraceRecord: record, line: s.lineNum})
+	}
+}
+
+// Next returns up to n more records sorted by time. It returns fewer records
+// at the end of the file or after an error, which Err reports.
+func (s *TraceStream) Next(n int) []producer.TraceRecord {
+	records := make([]producer.TraceRecord, 0, n)
+	for len(records) < n && len(s.ahead) > 0 {
+		e := heap.Pop(&s.ahead).(traceEntry)
+		s.last = e.Time
+		records = append(records, e.TraceRecord)
+		s.fill()
+	}
+	return records
+}
+
+// Buffered returns the number of records read ahead
+func (s *TraceStream) Buffered() int {
+	return len(s.ahead)
+}
+
+// Err returns the error that ended the stream early, if any
+func (s *TraceStream) Err() error {
+	return s.err
+}
+
+// Close closes the trace file
+func (s *TraceStream) Close() error {
+	return s.f.Close()
+}
+
+func parseTraceRecord(line string) (producer.TraceRecord, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != 3 {
+		return producer.TraceRecord{}, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	t, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	if err != nil || t < 0 {
+		return producer.TraceRecord{}, fmt.Errorf("invalid timestamp %q", fields[0])
+	}
+
+	size, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+	if err != nil || size < 0 {
+		return producer.TraceRecord{}, fmt.Errorf("invalid payload size %q", fields[2])
+	}
+
+	return producer.TraceRecord{
+		Time:        sim.VTimeInSec(t),
+		Destination: strings.TrimSpace(fields[1]),
+		Size:        size,
+	}, nil
+}
+
@@ -0,0 +1,68 @@
+package demo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTraceFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "trace.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadTraceSortsRecords verifies that comments and the header are skipped
+// and that records are sorted by time
+func TestLoadTraceSortsRecords(t *testing.T) {
+	path := writeTraceFile(t, "timestamp,destination,size\n# comment\n5,Consumer2,100\n1.5,Consumer1,64\n")
+	
+	records, err := LoadTrace(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Time != 1.5 || records[0].Destination != "Consumer1" || records[0].Size != 64 {
+		t.Errorf("Unexpected first record %+v", records[0])
+	}
+	if records[1].Time != 5 || records[1].Destination != "Consumer2" || records[1].Size != 100 {
+		t.Errorf("Unexpected second record %+v", records[1])
+	}
+}
+
+// TestLoadTraceRejectsMalformedLines verifies that a malformed line is
+// reported as an error
+func TestLoadTraceRejectsMalformedLines(t *testing.T) {
+	path := writeTraceFile(t, "1,Consumer1\n")
+	
+	if _, err := LoadTrace(path); err == nil {
+		t.Error("Expected an error for a line with missing fields")
+	}
+}
+
+// TestTraceStreamSortsWithinWindow verifies that records out of order by
+// less than the read-ahead window are sorted and that records further out of
+// order are reported
+func TestTraceStreamSortsWithinWindow(t *testing.T) {
+	path := writeTraceFile(t, "2,Consumer1,1\n1,Consumer1,2\n3,Consumer1,3\n4,Consumer1,4\n0.5,Consumer1,5\n")
+	
+	stream, err := OpenTrace(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	
+	records := stream.Next(10)
+	if len(records) != 4 || records[0].Size != 2 || records[1].Size != 1 || records[3].Size != 4 {
+		t.Errorf("Expected the records read before the error in order, got %+v", records)
+	}
+	if stream.Err() == nil {
+		t.Error("Expected an error for the record out of order by more than the window")
+	}
+}
+
@@ -0,0 +1,44 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// BurstyTraffic is an on/off traffic model. During a burst of BurstLength
+// seconds messages are generated with probability BurstRate per tick, then the
+// producer stays silent for IdlePeriod seconds before the next burst starts.
+type BurstyTraffic struct {
+	BurstLength sim.VTimeInSec
+	BurstRate   float64
+	IdlePeriod  sim.VTimeInSec
+}
+
+// ShouldGenerate returns true with probability BurstRate while in a burst and
+// false while idle
+func (t *BurstyTraffic) ShouldGenerate(now sim.VTimeInSec, rng *rand.Rand) bool {
+	if !t.InBurst(now) {
+		return false
+	}
+	return rng.Float64() < t.BurstRate
+}
+
+// InBurst reports whether the given time falls inside an on period
+func (t *BurstyTraffic) InBurst(now sim.VTimeInSec) bool {
+	period := t.BurstLength + t.IdlePeriod
+	if period <= 0 {
+		return false
+	}
+
+	phase := math.Mod(float64(now), float64(period))
+	return phase < float64(t.BurstLength)
+}
+
+// String names the traffic model and its bursts
+func (t *BurstyTraffic) String() string {
+	return fmt.Sprintf("bursty, %.0f%% chance per tick in bursts of %.2f s, idle for %.2f s",
+		t.BurstRate*100, float64(t.BurstLength), float64(t.IdlePeriod))
+}
@@ -0,0 +1,36 @@
+package demo
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// TestBurstyTrafficOnOffPhases verifies that the bursty traffic model only
+// generates messages during bursts
+func TestBurstyTrafficOnOffPhases(t *testing.T) {
+	traffic := &BurstyTraffic{BurstLength: 5, BurstRate: 1.0, IdlePeriod: 10}
+	rng := rand.New(rand.NewSource(1))
+
+	for now := 0; now < 45; now++ {
+		expected := now%15 < 5
+		result := traffic.ShouldGenerate(sim.VTimeInSec(now), rng)
+		if result != expected {
+			t.Errorf("At time %d expected ShouldGenerate() to return %v, got %v", now, expected, result)
+		}
+	}
+}
+
+// TestBurstyTrafficZeroRate verifies that a burst rate of zero never
+// generates messages
+func TestBurstyTrafficZeroRate(t *testing.T) {
+	traffic := &BurstyTraffic{BurstLength: 5, BurstRate: 0, IdlePeriod: 0}
+	rng := rand.New(rand.NewSource(1))
+
+	for now := 0; now < 20; now++ {
+		if traffic.ShouldGenerate(sim.VTimeInSec(now), rng) {
+			t.Errorf("Expected no message at time %d with zero burst rate", now)
+		}
+	}
+}
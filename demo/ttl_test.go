@@ -0,0 +1,70 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestMessageExpiry verifies that only messages with a TTL expire, and only
+// once the TTL has passed
+func TestMessageExpiry(t *testing.T) {
+	m := &msg.DemoMessage{CreateTime: 2, TTL: 3}
+	
+	if m.Expired(5) {
+		t.Error("Expected the message to be alive at the end of its TTL")
+	}
+	if !m.Expired(6) {
+		t.Error("Expected the message to expire after its TTL")
+	}
+	if (&msg.DemoMessage{CreateTime: 2}).Expired(100) {
+		t.Error("Expected a message without a TTL to never expire")
+	}
+}
+
+// TestDistributorDropsExpiredMessage verifies that an expired message is
+// dropped at the distributor and counted
+func TestDistributorDropsExpiredMessage(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"}, distributor.WithStats(stats))
+	
+	m := &msg.DemoMessage{Destination: "Consumer1", CreateTime: 0, TTL: 1}
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
+	
+	d.Tick(5)
+	
+	if d.InputPort().Peek() != nil {
+		t.Error("Expected the expired message to be removed from the input queue")
+	}
+	if stats.ExpiredAt("Distributor") != 1 || stats.Routed != 0 {
+		t.Errorf("Expected 1 expired and no routed message, got %d and %d",
+			stats.ExpiredAt("Distributor"), stats.Routed)
+	}
+}
+
+// TestConsumerSkipsExpiredMessages verifies that a consumer drops expired
+// messages and consumes the next live message in the same tick
+func TestConsumerSkipsExpiredMessages(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithStats(stats))
+	
+	for _, createTime := range []sim.VTimeInSec{0, 8} {
+		m := &msg.DemoMessage{Destination: "Consumer1", CreateTime: createTime, TTL: 5}
+		m.Meta().Dst = c.InputPort()
+		c.InputPort().Recv(m)
+	}
+	
+	c.Tick(10)
+	
+	if stats.Expired != 1 || stats.Consumed != 1 {
+		t.Errorf("Expected 1 expired and 1 consumed message, got %d and %d",
+			stats.Expired, stats.Consumed)
+	}
+}
+
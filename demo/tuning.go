@@ -0,0 +1,247 @@
+package demo
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// ParamChange is a change of a parameter requested over the control API. It
+// takes effect at the next virtual-time boundary, before the first event at
+// a later time than the last handled one, so that all the events of a time
+// see the same parameters.
+type ParamChange struct {
+	Param     string  `json:"param"`
+	Target    string  `json:"target"` // Producer or consumer the change applies to
+	Value     float64 `json:"value"`
+	Requested float64 `json:"requested"` // Time of the run at the request
+	Applied   float64 `json:"applied"`
+	Pending   bool    `json:"pending"`
+}
+
+// tunableParams are the parameters that can be changed while the run goes on:
+//
+//	probability       chance of a producer with random traffic to generate a
+//	                  message per tick
+//	consume-interval  time between two messages consumed by a consumer
+//	weight            share of the traffic the producers address to a
+//	                  consumer, relative to the others' (1 by default)
+//
+// The add-consumer change plugs in a new consumer named after the target,
+// with the value as its consume interval, and the remove-consumer change
+// removes the target gracefully.
+var tunableParams = []string{"probability", "consume-interval", "weight"}
+
+// Tune queues a parameter change after checking it against the run. It
+// returns the change as queued.
+func (c *Controller) Tune(change ParamChange) (ParamChange, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished {
+		return ParamChange{}, fmt.Errorf("the run has finished")
+	}
+	if c.check != nil {
+		if err := c.check(change); err != nil {
+			return ParamChange{}, err
+		}
+	}
+
+	change.Requested = float64(c.now)
+	change.Pending = true
+	c.changes = append(c.changes, &change)
+	return change, nil
+}
+
+// Changes returns the parameter changes in the order they were requested
+func (c *Controller) Changes() []ParamChange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changes := make([]ParamChange, len(c.changes))
+	for i, change := range c.changes {
+		changes[i] = *change
+	}
+	return changes
+}
+
+// applyChanges applies the pending parameter changes at now. The engine
+// calls it with the lock held.
+func (c *Controller) applyChanges(now sim.VTimeInSec) {
+	for _, change := range c.changes {
+		if !change.Pending {
+			continue
+		}
+		change.Pending = false
+		change.Applied = float64(now)
+		if c.tune != nil {
+			c.tune(now, *change)
+		}
+	}
+}
+
+// PrintChanges writes the parameter changes of the run, the pending ones
+// included
+func (c *Controller) PrintChanges(out EventSink) {
+	out.Println("=== Parameter Changes ===")
+	out.Printf("%9s %9s  %-16s %-12s %8s\n", "Requested", "Applied", "Parameter", "Target", "Value")
+	for _, change := range c.Changes() {
+		applied := "pending"
+		if !change.Pending {
+			applied = fmt.Sprintf("%.2f", change.Applied)
+		}
+		out.Printf("%9.2f %9s  %-16s %-12s %8g\n",
+			change.Requested, applied, change.Param, change.Target, change.Value)
+	}
+}
+
+// parseParamChange reads a change from the param, target, and value query
+// arguments of a request
+func parseParamChange(param, target, value string) (ParamChange, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return ParamChange{}, fmt.Errorf("invalid value %q", value)
+	}
+	return ParamChange{Param: param, Target: target, Value: v}, nil
+}
+
+// checkParamChange returns an error if the change does not apply to the run
+func (s *Simulation) checkParamChange(change ParamChange) error {
+	switch change.Param {
+	case "probability":
+		p := s.producer(change.Target)
+		if p == nil {
+			return fmt.Errorf("unknown producer %q", change.Target)
+		}
+		if _, ok := p.Traffic().(*producer.RandomTraffic); !ok || s.cfg.TraceFile != "" {
+			return fmt.Errorf("%s does not generate random traffic", change.Target)
+		}
+		if change.Value < 0 || change.Value > 1 {
+			return fmt.Errorf("probability must be between 0 and 1")
+		}
+	case "consume-interval":
+		if s.consumer(change.Target) == nil {
+			return fmt.Errorf("unknown consumer %q", change.Target)
+		}
+		if change.Value <= 0 {
+			return fmt.Errorf("consume-interval must be positive")
+		}
+	case "weight":
+		if s.consumer(change.Target) == nil {
+			return fmt.Errorf("unknown consumer %q", change.Target)
+		}
+		for _, p := range s.producers {
+			switch p.DestinationPolicy().(type) {
+			case producer.RandomDestination, *WeightedDestination:
+			default:
+				return fmt.Errorf("weights need the random destination policy, %s follows another one", p.Name())
+			}
+		}
+		if change.Value < 0 {
+			return fmt.Errorf("weight must not be negative")
+		}
+	case "add-consumer":
+		if change.Target == "" {
+			return fmt.Errorf("the new consumer needs a name")
+		}
+		if s.consumer(change.Target) != nil {
+			return fmt.Errorf("consumer %q already exists", change.Target)
+		}
+		if s.cfg.TraceFile != "" || s.matrix != nil {
+			return fmt.Errorf("the producers follow a fixed workload and would send nothing to a new consumer")
+		}
+		if s.network != nil {
+			return fmt.Errorf("the %s network is built before the run", s.cfg.Network)
+		}
+		if len(s.tree.Regions()) > 0 {
+			return fmt.Errorf("consumers are assigned to the regional distributors before the run")
+		}
+		if change.Value <= 0 {
+			return fmt.Errorf("consume-interval must be positive")
+		}
+	case "remove-consumer":
+		// Removals still pending count as started. The controller holds its
+		// lock while checking.
+		removing := make(map[string]bool)
+		for _, c := range s.consumers {
+			if c.Removing() {
+				removing[c.Name()] = true
+			}
+		}
+		for _, pending := range s.control.changes {
+			if pending.Pending && pending.Param == "remove-consumer" {
+				removing[pending.Target] = true
+			}
+		}
+		if s.consumer(change.Target) == nil {
+			return fmt.Errorf("unknown consumer %q", change.Target)
+		}
+		if len(s.tree.Regions()) > 0 {
+			return fmt.Errorf("consumers are assigned to the regional distributors before the run")
+		}
+		if removing[change.Target] {
+			return fmt.Errorf("%s is already being removed", change.Target)
+		}
+		if len(s.consumers)-len(removing) < 2 {
+			return fmt.Errorf("%s is the last consumer", change.Target)
+		}
+	default:
+		return fmt.Errorf("unknown parameter %q, tunable are %v", change.Param, tunableParams)
+	}
+	return nil
+}
+
+// applyParamChange changes the parameter of the run and logs the change
+func (s *Simulation) applyParamChange(now sim.VTimeInSec, change ParamChange) {
+	switch change.Param {
+	case "probability":
+		// Producers share the traffic model, so the producer gets its own
+		s.producer(change.Target).SetTraffic(&producer.RandomTraffic{Probability: change.Value})
+	case "consume-interval":
+		s.consumer(change.Target).SetInterval(sim.VTimeInSec(change.Value))
+	case "weight":
+		for _, p := range s.producers {
+			weighted, ok := p.DestinationPolicy().(*WeightedDestination)
+			if !ok {
+				weighted = NewWeightedDestination()
+				p.SetDestinationPolicy(weighted)
+			}
+			weighted.Weights[change.Target] = change.Value
+		}
+	case "add-consumer":
+		if _, err := s.AddConsumer(now, change.Target, sim.VTimeInSec(change.Value)); err != nil {
+			s.out.Printf("[%.2f] Control: Could not add %s: %v\n", now, change.Target, err)
+			return
+		}
+		s.out.Printf("[%.2f] Control: Added %s, consuming a message every %g seconds\n", now, change.Target, change.Value)
+		return
+	case "remove-consumer":
+		removal, err := s.RemoveConsumer(now, change.Target)
+		if err != nil {
+			s.out.Printf("[%.2f] Control: Could not remove %s: %v\n", now, change.Target, err)
+			return
+		}
+		s.out.Printf("[%.2f] Control: Removing %s, %d messages to drain\n", now, change.Target, removal.Backlog)
+		return
+	}
+	s.out.Printf("[%.2f] Control: Set %s of %s to %g\n", now, change.Param, change.Target, change.Value)
+}
+
+func (s *Simulation) producer(name string) *producer.Producer {
+	for _, p := range s.producers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (s *Simulation) consumer(name string) *consumer.Consumer {
+	for _, c := range s.consumers {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package demo
+
+import (
+	"sort"
+
+	"github.com/syifan/akita_demo/component"
+)
+
+// TickCounts counts the ticks of a component by outcome
+type TickCounts struct {
+	Busy    int
+	Idle    int
+	Blocked int
+}
+
+// Total returns the number of ticks
+func (c TickCounts) Total() int {
+	return c.Busy + c.Idle + c.Blocked
+}
+
+// Utilization returns the share of ticks with useful work
+func (c TickCounts) Utilization() float64 {
+	if c.Total() == 0 {
+		return 0
+	}
+	return float64(c.Busy) / float64(c.Total())
+}
+
+// RecordTick counts a tick of the named component
+func (s *Stats) RecordTick(name string, outcome component.TickOutcome) {
+	if s == nil {
+		return
+	}
+	counts := s.ticks[name]
+	switch outcome {
+	case component.TickBusy:
+		counts.Busy++
+	case component.TickBlocked:
+		counts.Blocked++
+	default:
+		counts.Idle++
+	}
+	s.ticks[name] = counts
+}
+
+// Ticks returns the tick counts of the named component
+func (s *Stats) Ticks(name string) TickCounts {
+	return s.ticks[name]
+}
+
+// PrintUtilization writes the busy, idle, and blocked ticks of every
+// component
+func (s *Stats) PrintUtilization(out EventSink) {
+	out.Println("=== Component Utilization ===")
+	names := make([]string, 0, len(s.ticks))
+	for name := range s.ticks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out.Printf("%-12s %6s %6s %6s %8s %12s\n", "Component", "Ticks", "Busy", "Idle", "Blocked", "Utilization")
+	for _, name := range names {
+		c := s.ticks[name]
+		out.Printf("%-12s %6d %6d %6d %8d %10.1f %%\n",
+			name, c.Total(), c.Busy, c.Idle, c.Blocked, c.Utilization()*100)
+	}
+}
@@ -0,0 +1,61 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// TestDistributorTickOutcomes verifies that distributor ticks are idle
+// without messages, blocked while the output port is busy, and busy when a
+// message is forwarded
+func TestDistributorTickOutcomes(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"}, distributor.WithStats(stats))
+	c := consumer.New("Consumer1", engine, 1.0)
+	d.Routes().Add("Consumer1", c.InputPort())
+	conn := sim.NewDirectConnection("Conn", engine, 1*sim.Hz)
+	conn.PlugIn(d.OutputPort("Consumer1"), 1)
+	conn.PlugIn(c.InputPort(), 1)
+	
+	d.Tick(0)
+	
+	for i := 0; i < 2; i++ {
+		m := &msg.DemoMessage{ID: uint64(i + 1), Destination: "Consumer1"}
+		m.Meta().Dst = d.InputPort()
+		d.InputPort().Recv(m)
+	}
+	d.Tick(1)
+	d.Tick(1) // The output port still holds the first message
+	
+	expected := TickCounts{Busy: 1, Idle: 1, Blocked: 1}
+	if got := stats.Ticks("Distributor"); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+	if u := expected.Utilization(); u < 0.33 || u > 0.34 {
+		t.Errorf("Expected a utilization of 1/3, got %.2f", u)
+	}
+}
+
+// TestConsumerTickOutcomes verifies that a rate-limited consumer with queued
+// messages counts as blocked
+func TestConsumerTickOutcomes(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := NewStats()
+	c := consumer.New("Consumer1", engine, 2.0, consumer.WithStats(stats))
+	queueMessages(c, 2)
+	
+	c.Tick(2)
+	c.Tick(3)
+	c.Tick(4)
+	c.Tick(5)
+	
+	expected := TickCounts{Busy: 2, Idle: 1, Blocked: 1}
+	if got := stats.Ticks("Consumer1"); got != expected {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
@@ -0,0 +1,97 @@
+package demo
+
+import (
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Verifier checks that the messages of every (producer, consumer) pair are
+// consumed in the order of their sequence numbers. It reports messages that
+// arrive after a later message (reordering), sequence numbers that never
+// arrive (gaps), and duplicates. Its methods are safe to call on a nil
+// verifier.
+type Verifier struct {
+	out     EventSink
+	next    map[Pair]uint64          // Next expected sequence number
+	missing map[Pair]map[uint64]bool // Skipped sequence numbers
+
+	InOrder    int
+	Reordered  int
+	Duplicates int
+}
+
+// NewVerifier creates a verifier that has seen no messages
+func NewVerifier(out EventSink) *Verifier {
+	return &Verifier{
+		out:     out,
+		next:    make(map[Pair]uint64),
+		missing: make(map[Pair]map[uint64]bool),
+	}
+}
+
+// Check verifies the sequence number of a message consumed by a consumer
+func (v *Verifier) Check(now sim.VTimeInSec, consumer string, m *msg.DemoMessage) {
+	if v == nil {
+		return
+	}
+
+	pair := Pair{Producer: m.Source, Consumer: consumer}
+	expected, ok := v.next[pair]
+	if !ok {
+		expected = 1
+	}
+
+	switch {
+	case m.SeqNum == expected:
+		v.InOrder++
+		v.next[pair] = expected + 1
+	case m.SeqNum > expected:
+		v.InOrder++
+		if v.missing[pair] == nil {
+			v.missing[pair] = make(map[uint64]bool)
+		}
+		for seq := expected; seq < m.SeqNum; seq++ {
+			v.missing[pair][seq] = true
+		}
+		v.next[pair] = m.SeqNum + 1
+		v.out.Printf("[%.2f] Verifier: %s expected #%d, got #%d\n", now, pair, expected, m.SeqNum)
+	case v.missing[pair][m.SeqNum]:
+		delete(v.missing[pair], m.SeqNum)
+		v.Reordered++
+		v.out.Printf("[%.2f] Verifier: %s #%d arrived out of order\n", now, pair, m.SeqNum)
+	default:
+		v.Duplicates++
+		v.out.Printf("[%.2f] Verifier: %s #%d is a duplicate\n", now, pair, m.SeqNum)
+	}
+}
+
+// Missing returns the sequence numbers of a pair that never arrived
+func (v *Verifier) Missing(pair Pair) []uint64 {
+	seqs := make([]uint64, 0, len(v.missing[pair]))
+	for seq := range v.missing[pair] {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+// Print writes the ordering report
+func (v *Verifier) Print() {
+	v.out.Println("=== Ordering ===")
+	v.out.Printf("In order:          %d\n", v.InOrder)
+	v.out.Printf("Reordered:         %d\n", v.Reordered)
+	v.out.Printf("Duplicates:        %d\n", v.Duplicates)
+
+	pairs := make([]Pair, 0, len(v.missing))
+	for pair := range v.missing {
+		if len(v.missing[pair]) > 0 {
+			pairs = append(pairs, pair)
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].String() < pairs[j].String() })
+	for _, pair := range pairs {
+		v.out.Printf("Gaps %-24s %v\n", pair.String()+":", v.Missing(pair))
+	}
+}
@@ -0,0 +1,80 @@
+package demo
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/sarchlab/akita/v3/tracing"
+)
+
+// VisualTracer passes the tasks reported by the components and the links it
+// is attached to on to an Akita DBTracer, which writes them to the trace
+// table that Daisen, Akita's visualization tool, reads. A task that is
+// already open keeps its first start time.
+type VisualTracer struct {
+	*tracing.DBTracer
+	backend tracing.TracerBackend
+	open    map[string]bool
+	Tasks   int // Tasks written
+}
+
+// NewVisualTracer creates a tracer that writes the tasks to the backend
+func NewVisualTracer(timeTeller sim.TimeTeller, backend tracing.TracerBackend) *VisualTracer {
+	return &VisualTracer{
+		DBTracer: tracing.NewDBTracer(timeTeller, backend),
+		backend:  backend,
+		open:     make(map[string]bool),
+	}
+}
+
+// Attach collects the tasks of a component or a link
+func (t *VisualTracer) Attach(domain tracing.NamedHookable) {
+	tracing.CollectTrace(domain, t)
+}
+
+// StartTask opens a task unless it is open already
+func (t *VisualTracer) StartTask(task tracing.Task) {
+	if t.open[task.ID] {
+		return
+	}
+	t.open[task.ID] = true
+	t.DBTracer.StartTask(task)
+}
+
+// EndTask closes an open task and writes it
+func (t *VisualTracer) EndTask(task tracing.Task) {
+	if !t.open[task.ID] {
+		return
+	}
+	delete(t.open, task.ID)
+	t.Tasks++
+	t.DBTracer.EndTask(task)
+}
+
+// Finish ends the tasks still open at the end of the run, such as the
+// generation of messages that were never acknowledged, flushes the tasks to
+// the backend, and closes it if it is a database
+func (t *VisualTracer) Finish() error {
+	t.Tasks += len(t.open)
+	t.open = make(map[string]bool)
+	t.DBTracer.Terminate()
+	if closer, ok := t.backend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewDaisenTraceWriter creates the SQLite database of a Daisen trace at
+// path, replacing the file if it exists. Akita's writer adds the .sqlite3
+// extension to the name it is given, which the configuration requires path
+// to have.
+func NewDaisenTraceWriter(path string) (*tracing.SQLiteTraceWriter, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	writer := tracing.NewSQLiteTraceWriter(strings.TrimSuffix(path, ".sqlite3"))
+	writer.Init()
+	return writer, nil
+}
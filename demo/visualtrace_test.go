@@ -0,0 +1,104 @@
+package demo
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	
+	"github.com/sarchlab/akita/v3/tracing"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/consumer"
+)
+
+// recordedTasks is a tracer backend that keeps the tasks written to it
+type recordedTasks struct {
+	tasks []tracing.Task
+}
+
+func (r *recordedTasks) Write(task tracing.Task) {
+	r.tasks = append(r.tasks, task)
+}
+
+func (r *recordedTasks) Flush() {}
+
+// TestVisualTracerNestsTasksUnderGeneration verifies that every message of a
+// run has a generation task, that its routing, transfer, and consumption
+// tasks are children of it, and that the tasks end up in Daisen's trace
+// table
+func TestVisualTracerNestsTasksUnderGeneration(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 3
+	cfg.Cycles = 40
+	cfg.LinkLatency = 1
+	cfg.VisualTraceFile = filepath.Join(t.TempDir(), "trace.sqlite3")
+	silence(t, cfg)
+	
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	count := func(query string) int {
+		t.Helper()
+		rows := queryEventDB(t, cfg.VisualTraceFile, query)
+		n, _ := strconv.Atoi(rows[0])
+		return n
+	}
+	if n := count("SELECT COUNT(*) FROM trace"); n != simulation.visualTracer.Tasks {
+		t.Errorf("Expected %d tasks in the trace table, got %d", simulation.visualTracer.Tasks, n)
+	}
+	generated := count("SELECT COUNT(*) FROM trace WHERE kind = 'generate'")
+	if generated != simulation.stats.Produced {
+		t.Errorf("Expected %d generation tasks, got %d", simulation.stats.Produced, generated)
+	}
+	if n := count("SELECT COUNT(*) FROM trace WHERE kind != 'generate' AND parent_id NOT IN " +
+		"(SELECT task_id FROM trace WHERE kind = 'generate')"); n != 0 {
+		t.Errorf("Expected every task to be a child of a generation task, got %d orphans", n)
+	}
+	if n := count("SELECT COUNT(*) FROM trace WHERE end_time < start_time"); n != 0 {
+		t.Errorf("Expected every task to end after it starts, got %d that do not", n)
+	}
+	
+	kinds := queryEventDB(t, cfg.VisualTraceFile, "SELECT kind, COUNT(*) FROM trace GROUP BY kind ORDER BY kind")
+	want := []string{
+		fmt.Sprintf("consume %d", simulation.stats.Consumed),
+		fmt.Sprintf("generate %d", generated),
+		fmt.Sprintf("route %d", generated),
+	}
+	if len(kinds) != 4 || strings.Join(kinds[:3], ", ") != strings.Join(want, ", ") || !strings.HasPrefix(kinds[3], "transfer ") {
+		t.Errorf("Expected %s and the transfers over the links, got %v", strings.Join(want, ", "), kinds)
+	}
+}
+
+// TestVisualTracerKeepsFirstStart verifies that starting an open task again
+// does not move its start time
+func TestVisualTracerKeepsFirstStart(t *testing.T) {
+	clock := &fixedTime{}
+	backend := &recordedTasks{}
+	tracer := NewVisualTracer(clock, backend)
+	c := consumer.New("Consumer1", nil, 1.0)
+	tracer.Attach(c)
+	
+	clock.now = 2
+	component.StartTask(c, "consume", 1)
+	clock.now = 3
+	component.StartTask(c, "consume", 1)
+	clock.now = 5
+	component.EndTask(c, "consume", 1)
+	if err := tracer.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(backend.tasks) != 1 || tracer.Tasks != 1 {
+		t.Fatalf("Expected 1 task, got %d", len(backend.tasks))
+	}
+	task := backend.tasks[0]
+	if task.StartTime != 2 || task.EndTime != 5 || task.ParentID != "generate-1" {
+		t.Errorf("Expected consume-1 from 2 to 5 under generate-1, got %+v", task)
+	}
+}
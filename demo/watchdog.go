@@ -0,0 +1,140 @@
+package demo
+
+import (
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+type watchedBuffer struct {
+	name string
+	size func() int
+}
+
+// Watchdog checks every cycle whether messages are buffered while no
+// component has ticked for timeout seconds. Such a stall means a component
+// missed a wake-up, and the watchdog dumps the state of every watched port
+// and buffer when it detects one.
+type Watchdog struct {
+	*sim.TickingComponent
+	component.BaseLifecycle
+	timeout  sim.VTimeInSec
+	stopTime sim.VTimeInSec
+	ledger   *Ledger
+	out      EventSink
+
+	ports        []sim.Port
+	queued       map[sim.Port]int
+	buffers      []watchedBuffer
+	lastTick     map[string]sim.VTimeInSec
+	lastActivity sim.VTimeInSec
+
+	Stalls []sim.VTimeInSec // Times stalls were detected
+}
+
+// NewWatchdog creates a watchdog that keeps checking until stopTime and
+// afterwards as long as the ledger counts buffered messages
+func NewWatchdog(name string, engine sim.Engine, timeout, stopTime sim.VTimeInSec, ledger *Ledger, out EventSink) *Watchdog {
+	w := &Watchdog{
+		timeout:  timeout,
+		stopTime: stopTime,
+		ledger:   ledger,
+		out:      out,
+		queued:   make(map[sim.Port]int),
+		lastTick: make(map[string]sim.VTimeInSec),
+	}
+	w.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, w)
+	engine.AcceptHook(w)
+	return w
+}
+
+// SelfStarting makes the watchdog check from the beginning of the run
+func (w *Watchdog) SelfStarting() {}
+
+// WatchPort includes the queue of an input port in the state dump
+func (w *Watchdog) WatchPort(port sim.Port) {
+	w.ports = append(w.ports, port)
+	port.AcceptHook(w)
+}
+
+// WatchBuffer includes a buffer of a component in the state dump
+func (w *Watchdog) WatchBuffer(name string, size func() int) {
+	w.buffers = append(w.buffers, watchedBuffer{name: name, size: size})
+}
+
+// Func records the ticks of the other components and the queue lengths of
+// the watched ports
+func (w *Watchdog) Func(ctx sim.HookCtx) {
+	switch ctx.Pos {
+	case sim.HookPosBeforeEvent:
+		evt, ok := ctx.Item.(sim.TickEvent)
+		if !ok || evt.Handler() == w.TickingComponent {
+			return
+		}
+		w.lastActivity = evt.Time()
+		if named, ok := evt.Handler().(sim.Named); ok {
+			w.lastTick[named.Name()] = evt.Time()
+		}
+	case sim.HookPosPortMsgRecvd:
+		w.queued[ctx.Domain.(sim.Port)]++
+	case sim.HookPosPortMsgRetrieve:
+		w.queued[ctx.Domain.(sim.Port)]--
+	}
+}
+
+// Tick checks for a stall. The watchdog stops once it detected a stall, or
+// once generation stopped and no message is buffered anymore.
+func (w *Watchdog) Tick(now sim.VTimeInSec) bool {
+	buffered := w.ledger.InNetwork()
+	if buffered > 0 && now-w.lastActivity >= w.timeout {
+		w.Stalls = append(w.Stalls, now)
+		w.out.Printf("[%.2f] %s: Stall detected, %d messages buffered and no component ticked for %.2f s\n",
+			now, w.Name(), buffered, float64(now-w.lastActivity))
+		w.Dump()
+		return false
+	}
+	return now < w.stopTime || buffered > 0
+}
+
+// Dump writes when every component last ticked and what every watched port
+// and buffer holds
+func (w *Watchdog) Dump() {
+	w.out.Println("=== Watchdog State Dump ===")
+
+	names := make([]string, 0, len(w.lastTick))
+	for name := range w.lastTick {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.out.Printf("  %-24s last ticked at %.2f\n", name, float64(w.lastTick[name]))
+	}
+
+	queued := 0
+	for _, port := range w.ports {
+		n := w.queued[port]
+		queued += n
+		if m, ok := port.Peek().(*msg.DemoMessage); ok {
+			w.out.Printf("  %-24s %d queued, head #%d for %s created at %.2f\n",
+				port.Name(), n, m.ID, m.Destination, float64(m.CreateTime))
+		} else {
+			w.out.Printf("  %-24s %d queued\n", port.Name(), n)
+		}
+	}
+	w.out.Printf("  %-24s %d\n", "In transit", w.ledger.InNetwork()-queued)
+	for _, b := range w.buffers {
+		w.out.Printf("  %-24s %d\n", b.name, b.size())
+	}
+}
+
+// Print writes the stalls detected during the run
+func (w *Watchdog) Print() {
+	w.out.Println("=== Watchdog ===")
+	w.out.Printf("Timeout:           %.2f s\n", float64(w.timeout))
+	w.out.Printf("Stalls:            %d\n", len(w.Stalls))
+	for _, t := range w.Stalls {
+		w.out.Printf("  stalled at %.2f\n", float64(t))
+	}
+}
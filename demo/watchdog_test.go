@@ -0,0 +1,78 @@
+package demo
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+	"github.com/syifan/akita_demo/producer"
+)
+
+// sleepyComponent never wakes up when a message arrives
+type sleepyComponent struct {
+	*sim.ComponentBase
+}
+
+func (c *sleepyComponent) Handle(e sim.Event) error                      { return nil }
+func (c *sleepyComponent) NotifyRecv(now sim.VTimeInSec, p sim.Port)     {}
+func (c *sleepyComponent) NotifyPortFree(now sim.VTimeInSec, p sim.Port) {}
+
+// TestWatchdogDetectsMissedWakeup verifies that a message left in the queue
+// of a component that missed its wake-up is reported as a stall
+func TestWatchdogDetectsMissedWakeup(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	ledger := &Ledger{}
+	sleepy := &sleepyComponent{ComponentBase: sim.NewComponentBase("Sleepy")}
+	port := sim.NewLimitNumMsgPort(sleepy, 4, "Sleepy.In")
+	sender := producer.New("Sender", engine, 0)
+	conn := sim.NewDirectConnection("Conn", engine, 1*sim.Hz)
+	conn.PlugIn(sender.OutputPort(), 1)
+	conn.PlugIn(port, 1)
+	ledger.TrackOutput(sender.OutputPort())
+	ledger.TrackInput(port)
+	
+	watchdog := NewWatchdog("Watchdog", engine, 3, 2, ledger, NullSink{})
+	watchdog.WatchPort(port)
+	watchdog.TickNow(0)
+	
+	m := &msg.DemoMessage{ID: 1, Destination: "Sleepy"}
+	m.Meta().Src = sender.OutputPort()
+	m.Meta().Dst = port
+	if err := sender.OutputPort().Send(m); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(watchdog.Stalls) != 1 {
+		t.Fatalf("Expected 1 stall, got %v", watchdog.Stalls)
+	}
+	if watchdog.queued[port] != 1 {
+		t.Errorf("Expected 1 message queued at the stalled port, got %d", watchdog.queued[port])
+	}
+}
+
+// TestWatchdogStopsAfterDrain verifies that the watchdog reports nothing and
+// stops checking once generation stopped and the queues are empty
+func TestWatchdogStopsAfterDrain(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 2
+	cfg.Cycles = 40
+	cfg.Watchdog = 3
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(simulation.watchdog.Stalls) != 0 {
+		t.Errorf("Expected no stalls, got %v", simulation.watchdog.Stalls)
+	}
+	if simulation.Completion() > 0 && simulation.Duration() > simulation.Completion()+1 {
+		t.Errorf("Expected the run to end after the queues drained, ended at %.2f", simulation.Duration())
+	}
+}
@@ -0,0 +1,14 @@
+package demo
+
+import (
+	"github.com/syifan/akita_demo/producer"
+)
+
+// printWindow writes the window statistics
+func printWindow(out EventSink, w *producer.Window) {
+	out.Println("=== Sliding Window ===")
+	out.Printf("Final window:      %d (max %d)\n", w.Size(), w.MaxSize())
+	out.Printf("Peak window:       %d\n", w.Peak)
+	out.Printf("Window grows:      %d\n", w.Grows)
+	out.Printf("Window shrinks:    %d\n", w.Shrinks)
+}
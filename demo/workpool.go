@@ -0,0 +1,159 @@
+package demo
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// WorkPool is one queue shared by all consumers instead of a queue each.
+// The distributor queues every message it routes in the pool, whichever
+// consumer it is addressed to, and the pool hands the message at its head
+// to the consumer that has waited the longest for work. A consumer pulls its
+// next message as soon as its own queue is empty, so it holds at most one
+// message while serving another.
+type WorkPool struct {
+	*sim.TickingComponent
+	inputPort  sim.Port   // The shared queue
+	buf        sim.Buffer // Backing buffer of inputPort, used to report the depth
+	workerPort sim.Port   // Receives the pulls of the consumers and sends them their messages
+	idle       []*msg.PullMsg // Pulls waiting for a message, oldest first
+	seqNums    map[Pair]uint64
+	eventDB    *EventDB
+	out        EventSink
+
+	Capacity   int
+	Dispatched map[string]int            // Messages pulled by every consumer
+	Idle       map[string]sim.VTimeInSec // Time the pulls of every consumer waited for a message
+	Waits      []float64                 // Time every dispatched message waited in the pool
+	PeakDepth  int
+}
+
+// NewWorkPool creates a work pool that holds capacity messages
+func NewWorkPool(name string, engine sim.Engine, capacity, consumers int, out EventSink) *WorkPool {
+	p := &WorkPool{
+		Capacity:   capacity,
+		out:        out,
+		seqNums:    make(map[Pair]uint64),
+		Dispatched: make(map[string]int),
+		Idle:       make(map[string]sim.VTimeInSec),
+	}
+	p.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, p)
+	p.buf = sim.NewBuffer(name+".InBuf", capacity)
+	p.inputPort = sim.NewLimitNumMsgPortWithExternalBuffer(p, p.buf, name+".In")
+	// Every consumer has at most one pull outstanding
+	p.workerPort = sim.NewLimitNumMsgPort(p, consumers, name+".Workers")
+	return p
+}
+
+// Depth returns the number of messages waiting in the pool
+func (p *WorkPool) Depth() int {
+	return p.buf.Size()
+}
+
+// Tick collects the pulls of the consumers and hands out the messages
+func (p *WorkPool) Tick(now sim.VTimeInSec) bool {
+	madeProgress := false
+	for m := p.workerPort.Retrieve(now); m != nil; m = p.workerPort.Retrieve(now) {
+		madeProgress = true
+		if pull, ok := m.(*msg.PullMsg); ok {
+			p.idle = append(p.idle, pull)
+		}
+	}
+	if depth := p.Depth(); depth > p.PeakDepth {
+		p.PeakDepth = depth
+	}
+
+	for len(p.idle) > 0 && p.dispatch(now, p.idle[0]) {
+		p.idle = p.idle[1:]
+		madeProgress = true
+	}
+	return madeProgress
+}
+
+// dispatch sends the message at the head of the pool to the consumer of a
+// pull, numbered in the sequence of that consumer. It reports whether a
+// message was sent.
+func (p *WorkPool) dispatch(now sim.VTimeInSec, pull *msg.PullMsg) bool {
+	head := p.inputPort.Peek()
+	if head == nil {
+		return false
+	}
+	m := head.(*msg.DemoMessage)
+	received := *m.Meta()
+	addressed, seqNum := m.Destination, m.SeqNum
+	m.Meta().Src = p.workerPort
+	m.Meta().Dst = pull.Meta().Src
+	m.Meta().SendTime = now
+	m.Destination = pull.Consumer
+	m.SeqNum = p.seqNums[Pair{Producer: m.Source, Consumer: pull.Consumer}] + 1
+	if err := p.workerPort.Send(m); err != nil {
+		// Connection busy, will be woken up when it becomes free
+		*m.Meta() = received
+		m.Destination, m.SeqNum = addressed, seqNum
+		return false
+	}
+
+	p.inputPort.Retrieve(now)
+	p.seqNums[Pair{Producer: m.Source, Consumer: pull.Consumer}] = m.SeqNum
+	p.Dispatched[pull.Consumer]++
+	p.Idle[pull.Consumer] += now - pull.Meta().SendTime
+	p.Waits = append(p.Waits, float64(now-received.RecvTime))
+	p.eventDB.Decide(now, p.Name(), m.ID, "pulled by %s, addressed to %s", pull.Consumer, addressed)
+	p.out.Printf("[%.2f] %s: Dispatched message for %s to %s (pool: %d)\n",
+		now, p.Name(), addressed, pull.Consumer, p.Depth())
+	return true
+}
+
+// Print writes the messages every consumer pulled, how long the messages
+// waited in the pool, and how long the consumers waited for them
+func (p *WorkPool) Print(consumers []string) {
+	p.out.Println("=== Work Pool ===")
+	p.out.Printf("Capacity:          %d messages\n", p.Capacity)
+	p.out.Printf("Peak depth:        %d messages\n", p.PeakDepth)
+	dispatched := 0
+	for _, n := range p.Dispatched {
+		dispatched += n
+	}
+	p.out.Printf("Dispatched:        %d\n", dispatched)
+	if len(p.Waits) > 0 {
+		longest := 0.0
+		for _, wait := range p.Waits {
+			longest = math.Max(longest, wait)
+		}
+		p.out.Printf("Wait in pool:      mean %.2f s, max %.2f s\n", mean(p.Waits), longest)
+	}
+	p.out.Printf("%-16s %8s %12s\n", "Consumer", "Pulled", "Idle")
+	for _, name := range consumers {
+		p.out.Printf("%-16s %8d %10.2f s\n", name, p.Dispatched[name], float64(p.Idle[name]))
+	}
+}
+
+// validateWorkPool checks the capacity of the work pool and that nothing
+// else steers the messages to the consumers or depends on their own queues
+func (c *Config) validateWorkPool() error {
+	if c.PoolCapacity < 0 {
+		return fmt.Errorf("pool-capacity must not be negative")
+	}
+	if !c.WorkPool && c.Scenario != "work-pool" {
+		if c.PoolCapacity > 0 {
+			return fmt.Errorf("pool-capacity needs a work pool")
+		}
+		return nil
+	}
+	if c.RxQueues != 1 || c.ConsumerMode == "batch" || c.CoalesceTime > 0 {
+		return fmt.Errorf("a work pool needs consumers with one RX queue that are not batched or coalesced")
+	}
+	if c.DistributorDepth > 1 || c.Network != "direct" {
+		return fmt.Errorf("a work pool needs a single distributor and direct connections")
+	}
+	if c.Multicast > 0 || len(c.Topics) > 0 || len(c.ConsumerGroups) > 0 {
+		return fmt.Errorf("a work pool cannot be combined with multicast, topics, or consumer groups")
+	}
+	if c.RoutePolicy != "destination" || c.OverflowConsumer != "" || c.DestWindow > 0 || len(c.DestWindows) > 0 {
+		return fmt.Errorf("a work pool cannot be combined with load balancing, overflow routing, or destination windows")
+	}
+	return nil
+}
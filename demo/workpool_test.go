@@ -0,0 +1,78 @@
+package demo
+
+import "testing"
+
+// TestWorkPoolBalancesConsumers verifies that a slow consumer pulls fewer
+// messages from the work pool than the fast ones, and that every message is
+// still consumed once and in order
+func TestWorkPoolBalancesConsumers(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 100
+	cfg.WorkPool = true
+	cfg.ConsumeInterval = 2
+	cfg.ConsumeIntervals = map[string]float64{"Consumer3": 6}
+	silence(t, cfg)
+	simulation, err := NewSimulation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if err := simulation.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	pulled := simulation.workPool.Dispatched
+	if pulled["Consumer3"] >= pulled["Consumer1"] || pulled["Consumer3"] >= pulled["Consumer2"] {
+		t.Errorf("Expected the slow consumer to pull the fewest messages, got %v", pulled)
+	}
+	if v := simulation.verifier; v.Reordered > 0 || v.Duplicates > 0 {
+		t.Errorf("Expected in-order delivery, got %d reordered and %d duplicates", v.Reordered, v.Duplicates)
+	}
+	if !simulation.Conservation().Holds() {
+		t.Errorf("Expected every message to be accounted for, got %+v", simulation.Conservation())
+	}
+}
+
+// TestWorkPoolScenario verifies that the work pool shortens the tail latency
+// a slow consumer causes with dedicated queues
+func TestWorkPoolScenario(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Seed = 1
+	cfg.Cycles = 200
+	cfg.ConsumeInterval = 2
+	cfg.ConsumeIntervals = map[string]float64{"Consumer3": 6}
+	
+	silence(t, cfg)
+	results, err := RunWorkPool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[1].P99Latency >= results[0].P99Latency {
+		t.Errorf("Expected the work pool to shorten the p99 latency, got %.2f and %.2f",
+			results[0].P99Latency, results[1].P99Latency)
+	}
+}
+
+// TestWorkPoolValidation verifies that a work pool rejects consumers with
+// several queues and that a pool capacity needs a work pool
+func TestWorkPoolValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PoolCapacity = 8
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a pool capacity without a work pool to be rejected")
+	}
+	cfg.WorkPool = true
+	cfg.RxQueues = 2
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected a work pool with several RX queues to be rejected")
+	}
+	cfg.RxQueues = 1
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the work pool to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package distributor
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// InputPort returns the port the producers send their messages to
+func (d *Distributor) InputPort() sim.Port {
+	return d.inputPort
+}
+
+// CtrlPort returns the port the consumers register on and the producers
+// discover the destinations on
+func (d *Distributor) CtrlPort() sim.Port {
+	return d.ctrlPort
+}
+
+// DeadLetterPort returns the port undeliverable messages leave on
+func (d *Distributor) DeadLetterPort() sim.Port {
+	return d.deadLetterPort
+}
+
+// DeadLetterSink returns the input port of the dead-letter sink, nil if
+// undeliverable messages are dropped
+func (d *Distributor) DeadLetterSink() sim.Port {
+	return d.deadLetterDst
+}
+
+// OutputPort returns the output port of a destination, nil if there is none
+func (d *Distributor) OutputPort(dest string) sim.Port {
+	return d.outputPorts[dest]
+}
+
+// Routes returns the routing table the consumers register in
+func (d *Distributor) Routes() *RoutingTable {
+	return d.routes
+}
+
+// NextHop returns the child distributor messages for a destination are
+// passed on to, "" at the leaves of a tree
+func (d *Distributor) NextHop(dest string) string {
+	return d.nextHops[dest]
+}
+
+// Routed returns the number of messages sent to a destination
+func (d *Distributor) Routed(dest string) int {
+	return d.routed[dest]
+}
+
+// TotalRouted returns the number of messages sent to every destination
+func (d *Distributor) TotalRouted() int {
+	total := 0
+	for _, n := range d.routed {
+		total += n
+	}
+	return total
+}
+
+// Replaying returns the number of retained messages waiting to be delivered
+// to newly registered consumers
+func (d *Distributor) Replaying() int {
+	return len(d.replay)
+}
+
+// Groups returns the members of the multicast groups
+func (d *Distributor) Groups() map[string][]string {
+	return d.groups
+}
+
+// AddDestination creates the output port of a destination added while the
+// run goes on. Messages for it are routed once a consumer registers for it.
+func (d *Distributor) AddDestination(name string) sim.Port {
+	port := sim.NewLimitNumMsgPort(d, 1, d.Name()+".Out."+name)
+	d.outputPorts[name] = port
+	return port
+}
+
+// AddRegion makes a child distributor the next hop for its consumers. The
+// output port to the region, which it returns, serves all of them, and the
+// region's input port is their route.
+func (d *Distributor) AddRegion(region *Distributor, consumers []string) sim.Port {
+	if d.nextHops == nil {
+		d.nextHops = make(map[string]string)
+	}
+	port := sim.NewLimitNumMsgPort(d, 1, d.Name()+".Out."+region.Name())
+	for _, consumer := range consumers {
+		d.outputPorts[consumer] = port
+		d.nextHops[consumer] = region.Name()
+		d.routes.Add(consumer, region.inputPort)
+	}
+	return port
+}
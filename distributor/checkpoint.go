@@ -0,0 +1,60 @@
+package distributor
+
+import (
+	"github.com/syifan/akita_demo/component"
+)
+
+// Checkpoint is the state of a distributor saved in checkpoints
+type Checkpoint struct {
+	Routes        []string
+	Replay        []uint64
+	Retained      int
+	SeqNums       map[string]uint64
+	Random        *component.CountingSource
+	Windows       map[string]int      `json:",omitempty"` // Unacknowledged messages per consumer
+	FanOut        []string            `json:",omitempty"` // Members still waiting for a copy of the message at the head
+	Subscriptions map[string][]string `json:",omitempty"` // Topic patterns of every consumer
+	Partitions    map[string][]string `json:",omitempty"` // Owner of every partition of each consumer group
+	Groups        map[string][]string `json:",omitempty"` // Members of the multicast groups consumers join and leave
+}
+
+// CheckpointState returns the routes, the retained messages, the sequence
+// numbers, and the window occupancy of the distributor
+func (d *Distributor) CheckpointState() interface{} {
+	state := Checkpoint{
+		Routes:  d.routes.Names(),
+		SeqNums: make(map[string]uint64),
+	}
+	if d.balancer != nil {
+		// The generator is only seeded when the balancer draws from it
+		state.Random = d.source
+	}
+	for _, m := range d.replay {
+		state.Replay = append(state.Replay, m.ID)
+	}
+	if d.retention != nil {
+		state.Retained = d.retention.Len()
+	}
+	if d.fanout != nil {
+		state.FanOut = d.fanout.Pending
+	}
+	if d.subscriptions != nil {
+		state.Subscriptions = d.subscriptions.Subscriptions()
+	}
+	if d.consumerGroups != nil {
+		state.Partitions = d.consumerGroups.Owners()
+	}
+	if d.membership != nil {
+		state.Groups = d.groups
+	}
+	for p, seq := range d.seqNums {
+		state.SeqNums[p.producer+"->"+p.consumer] = seq
+	}
+	if d.windows != nil {
+		state.Windows = make(map[string]int)
+		for name := range d.outputPorts {
+			state.Windows[name] = d.windows.Outstanding(name)
+		}
+	}
+	return state
+}
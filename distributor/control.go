@@ -0,0 +1,182 @@
+package distributor
+
+import (
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// handleControl processes the control messages queued at the distributor's
+// control port
+func (d *Distributor) handleControl(now sim.VTimeInSec) {
+	if !d.announce(now) {
+		// Port busy, will be woken up when it becomes free
+		return
+	}
+
+	for {
+		m := d.ctrlPort.Peek()
+		if m == nil {
+			return
+		}
+
+		switch m := m.(type) {
+		case *msg.RegisterMsg:
+			if _, ok := d.outputPorts[m.Name]; !ok {
+				d.report(now, component.ErrNilRemotePort, "Rejected registration of %s (no output port)", m.Name)
+			} else if len(m.Ports) == 0 {
+				d.report(now, component.ErrNilRemotePort, "Rejected registration of %s (no RX ports)", m.Name)
+			} else {
+				d.routes.Add(m.Name, m.Ports...)
+				d.log.Printf("[%.2f] %s: Registered %s\n", now, d.Name(), m.Name)
+				if d.retention != nil {
+					d.replay = append(d.replay, d.retention.Claim(now, m.Name)...)
+				}
+				d.rebalanceGroups(now)
+				if !d.announced[d.destination(m.Name)] && len(d.subscribers) > 0 {
+					d.queueAnnouncements()
+				}
+			}
+		case *msg.AckMsg:
+			if d.windows != nil {
+				d.windows.Acked(now, m.Consumer)
+			}
+		case *msg.HeartbeatMsg:
+			d.heartbeat(now, m.Consumer)
+		case *msg.SubscribeMsg:
+			d.handleSubscription(now, m)
+		case *msg.MembershipMsg:
+			d.handleMembership(now, m)
+		case *msg.LeaveGroupMsg:
+			if d.consumerGroups == nil {
+				break
+			}
+			if group, ok := d.consumerGroups.Leave(m.Consumer); ok {
+				d.log.Printf("[%.2f] %s: %s left %s\n", now, d.Name(), m.Consumer, group)
+				d.rebalanceGroups(now)
+			}
+		case *msg.DiscoverReq:
+			rsp := &msg.DiscoverRsp{Names: d.discoverable()}
+			rsp.Meta().Src = d.ctrlPort
+			rsp.Meta().Dst = m.Meta().Src
+			rsp.Meta().SendTime = now
+			if err := d.ctrlPort.Send(rsp); err != nil {
+				// Reply later, will be woken up when the port becomes free
+				return
+			}
+			d.subscribe(m.Meta().Src, rsp.Names)
+		default:
+			d.report(now, component.ErrTypeMismatch, "Discarded control message of type %T", m)
+		}
+
+		d.ctrlPort.Retrieve(now)
+	}
+}
+
+// destination returns the name a consumer is discovered under: its
+// consumer group, or its own name
+func (d *Distributor) destination(consumer string) string {
+	if d.consumerGroups == nil {
+		return consumer
+	}
+	return d.consumerGroups.Destination(consumer)
+}
+
+// discoverable returns the names the producers discover, in which the
+// members of a consumer group are replaced by the group
+func (d *Distributor) discoverable() []string {
+	if d.consumerGroups == nil {
+		return d.Destinations()
+	}
+	return d.consumerGroups.Destinations(d.Destinations())
+}
+
+// subscribe remembers a producer that discovered the destinations, to tell
+// it about the destinations added later
+func (d *Distributor) subscribe(producer sim.Port, names []string) {
+	if d.announced == nil {
+		d.announced = make(map[string]bool)
+	}
+	for _, name := range names {
+		d.announced[name] = true
+	}
+	for _, p := range d.subscribers {
+		if p == producer {
+			return
+		}
+	}
+	d.subscribers = append(d.subscribers, producer)
+}
+
+// queueAnnouncements prepares an answer to a discovery for every producer
+// that discovered the destinations, once a destination they were not told
+// about has registered
+func (d *Distributor) queueAnnouncements() {
+	names := d.discoverable()
+	for _, name := range names {
+		d.announced[name] = true
+	}
+	for _, p := range d.subscribers {
+		rsp := &msg.DiscoverRsp{Names: names}
+		rsp.Meta().Src = d.ctrlPort
+		rsp.Meta().Dst = p
+		d.announcements = append(d.announcements, rsp)
+	}
+}
+
+// announce sends the queued announcements. It returns false if the control
+// port is busy.
+func (d *Distributor) announce(now sim.VTimeInSec) bool {
+	for len(d.announcements) > 0 {
+		rsp := d.announcements[0]
+		rsp.Meta().SendTime = now
+		if err := d.ctrlPort.Send(rsp); err != nil {
+			return false
+		}
+		d.announcements = d.announcements[1:]
+	}
+	return true
+}
+
+// Destinations returns the sorted names of the destinations the distributor
+// has output ports for, whether or not a consumer has registered for them yet
+func (d *Distributor) Destinations() []string {
+	names := make([]string, 0, len(d.outputPorts))
+	for name := range d.outputPorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// heartbeat records the heartbeat of a consumer and wakes the distributor up
+// when the next one is overdue
+func (d *Distributor) heartbeat(now sim.VTimeInSec, consumer string) {
+	if d.health == nil {
+		return
+	}
+	if detected, ok := d.health.Heartbeat(now, consumer); ok {
+		d.log.Printf("[%.2f] %s: %s recovered after %.2f s\n", now, d.Name(), consumer, float64(now-detected))
+	}
+	if overdue, ok := d.health.Overdue(now); ok {
+		component.ScheduleWakeup(d.TickingComponent, overdue)
+	}
+}
+
+// checkHealth considers the consumers whose heartbeats are overdue failed
+func (d *Distributor) checkHealth(now sim.VTimeInSec) {
+	if d.health == nil {
+		return
+	}
+	failed := d.health.Check(now)
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.log.Printf("[%.2f] %s: %s failed (no heartbeat since %.2f)\n", now, d.Name(), name, float64(failed[name]))
+	}
+}
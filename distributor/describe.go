@@ -0,0 +1,78 @@
+package distributor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/syifan/akita_demo/component"
+)
+
+// Describe lists the routes of the distributor and the policies that
+// change them
+func (d *Distributor) Describe() []component.Parameter {
+	params := []component.Parameter{{Name: "Frequency", Value: component.FormatFreq(d.Freq)}}
+	if d.nextHops != nil {
+		var hops []string
+		for _, dest := range d.Destinations() {
+			hops = append(hops, fmt.Sprintf("%s via %s", dest, d.nextHops[dest]))
+		}
+		params = append(params, component.Parameter{Name: "Routes", Value: strings.Join(hops, ", ")})
+	} else {
+		params = append(params, component.Parameter{Name: "Routes", Value: strings.Join(d.Destinations(), ", ")})
+	}
+	if d.balancer != nil {
+		params = append(params, component.Parameter{Name: "Balancer", Value: component.Summary(d.balancer)})
+	}
+	if d.overflow != nil {
+		params = append(params, component.Parameter{Name: "Overflow", Value: component.Summary(d.overflow)})
+	}
+	if d.health != nil {
+		params = append(params, component.Parameter{Name: "Health checks", Value: component.Summary(d.health)})
+	}
+	if d.windows != nil {
+		var windows []string
+		for _, dest := range d.Destinations() {
+			windows = append(windows, fmt.Sprintf("%s=%d", dest, d.windows.LimitOf(dest)))
+		}
+		params = append(params, component.Parameter{Name: "Windows", Value: strings.Join(windows, ", ")})
+	}
+	if d.sharedBuffer != nil {
+		params = append(params, component.Parameter{Name: "Shared buffer", Value: component.Summary(d.sharedBuffer)})
+	}
+	if d.faults != nil {
+		if n := d.faults.Stalls(d.Name()); n > 0 {
+			params = append(params, component.Parameter{Name: "Stalls", Value: fmt.Sprintf("%d windows", n)})
+		}
+	}
+	if d.retention != nil {
+		params = append(params, component.Parameter{Name: "Retention", Value: component.Summary(d.retention)})
+	}
+	if d.coalescer != nil {
+		params = append(params, component.Parameter{Name: "Coalescing", Value: component.Summary(d.coalescer)})
+	}
+	if d.subscriptions != nil {
+		params = append(params, component.Parameter{Name: "Topics", Value: component.Summary(d.subscriptions)})
+	}
+	if len(d.groups) > 0 {
+		names := make([]string, 0, len(d.groups))
+		for name := range d.groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var groups []string
+		for _, name := range names {
+			groups = append(groups, name+"="+strings.Join(d.groups[name], "+"))
+		}
+		params = append(params, component.Parameter{Name: "Groups", Value: strings.Join(groups, ", ")})
+	}
+	if d.consumerGroups != nil {
+		params = append(params, component.Parameter{Name: "Consumer groups", Value: component.Summary(d.consumerGroups)})
+	}
+	if d.deadLetterDst != nil {
+		params = append(params, component.Parameter{Name: "Dead letters", Value: "to " + d.deadLetterDst.Component().Name()})
+	} else {
+		params = append(params, component.Parameter{Name: "Dead letters", Value: "dropped"})
+	}
+	return params
+}
@@ -0,0 +1,509 @@
+// Package distributor holds the component of the demo that routes the
+// messages of the producers to the consumers by their destination names.
+// How it picks and reroutes the consumers, and what it reports to, is given
+// as options.
+package distributor
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// pair identifies a (producer, consumer) pair of messages
+type pair struct {
+	producer, consumer string
+}
+
+// Distributor routes messages to the correct consumer by destination name
+type Distributor struct {
+	*sim.TickingComponent
+	component.BaseLifecycle
+	inputPort      sim.Port
+	capacity       int      // Messages the input port holds
+	ctrlPort       sim.Port // Control-plane port for registration and discovery
+	outputPorts    map[string]sim.Port
+	routes         *RoutingTable             // Destination name to consumer ports
+	nextHops       map[string]string         // Child distributor of every destination in a tree, nil at the leaves
+	coalescer      Coalescer                 // Interrupt coalescing, nil notifies per message
+	retention      Retention                 // Keeps messages for late consumers, nil drops them
+	replay         []*msg.DemoMessage        // Retained messages to deliver to newly registered consumers
+	subscribers    []sim.Port                // Control ports of the producers that discovered the destinations
+	announced      map[string]bool           // Destinations the producers have been told about
+	announcements  []*msg.DiscoverRsp        // Destinations added since the discovery, waiting for the control port
+	deadLetterPort sim.Port                  // Output port for undeliverable messages
+	deadLetterDst  sim.Port                  // Dead-letter sink's input port, nil drops undeliverable messages
+	balancer       Balancer                  // Overrides the producer's destination choice, nil delivers as addressed
+	overflow       Rerouting                 // Reroutes messages of overloaded consumers, nil never reroutes
+	health         Health                    // Reroutes messages of failed consumers, nil never checks
+	windows        Windows                   // Limits the unacknowledged messages per consumer, nil never limits
+	groups         map[string][]string       // Members of the groups multicast messages are addressed to
+	membership     Membership                // Measures the joins and leaves of the groups, nil if their members are fixed
+	subscriptions  Topics                    // Subscribers of the topics messages are published to, nil without topics
+	consumerGroups ConsumerGroups            // Groups of consumers sharing a destination name, nil without groups
+	workPool       sim.Port                  // Input port of the shared queue all messages go to, nil if every consumer has its own
+	rules          Rules                     // Route messages by their content, nil routes them as addressed
+	sharedBuffer   SharedBuffer              // Room of the consumers in a shared buffer pool, nil if their queues have their own
+	middleware     *middleware.Chain         // Intercepts messages before routing them, nil routes them as they come
+	faults         Faults                    // Stalls the distributor, nil never stalls it
+	stalledUntil   sim.VTimeInSec            // End of the stall whose wake-up is scheduled
+	intercepted    *middleware.Interception  // Decision of the middlewares on the message at the head of the input port
+	copiesLeft     int                       // Copies of that message still to be sent
+	fanout         *FanOut                   // Multicast message being fanned out, nil if none
+	timestamps     Timestamps                // Corrects the creation stamps of skewed producers, nil keeps them
+	rand           *rand.Rand                // Random source of the balancer
+	source         *component.CountingSource // Source of rand
+	seqNums        map[pair]uint64           // Sequence numbers of rebalanced messages per (producer, consumer) pair
+	routed         map[string]int            // Messages sent to every destination
+	removals       map[string]map[string]int // Messages of every removed destination, by the destination they were redirected to
+	redirects      int                       // Messages redirected so far, which picks the next target
+	events         Events                    // Records the routing decisions, nil records nothing
+	errors         Errors                    // Reports the errors, nil logs them
+	stats          Stats                     // Counts the messages, nil counts none
+	log            component.Logger
+}
+
+// New creates a distributor with an output port for every consumer.
+// Without options, it ticks at 1 Hz, its input port holds 10 messages, and
+// it drops the messages it cannot deliver.
+func New(name string, engine sim.Engine, consumers []string, opts ...Option) *Distributor {
+	source := component.NewCountingSource(time.Now().UnixNano())
+	d := &Distributor{
+		capacity:    10,
+		outputPorts: make(map[string]sim.Port),
+		routes:      NewRoutingTable(),
+		rand:        rand.New(source),
+		source:      source,
+		seqNums:     make(map[pair]uint64),
+		routed:      make(map[string]int),
+		log:         component.Discard{},
+	}
+	d.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, d)
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	d.inputPort = sim.NewLimitNumMsgPort(d, d.capacity, name+".In")
+	d.ctrlPort = sim.NewLimitNumMsgPort(d, 10, name+".Ctrl")
+	d.deadLetterPort = sim.NewLimitNumMsgPort(d, 1, name+".DeadLetter")
+	for _, consumer := range consumers {
+		d.outputPorts[consumer] = sim.NewLimitNumMsgPort(d, 1, name+".Out."+consumer)
+	}
+	return d
+}
+
+// Tick processes messages from input and routes to output
+func (d *Distributor) Tick(now sim.VTimeInSec) bool {
+	outcome := component.TickIdle
+	defer func() {
+		if d.stats != nil {
+			d.stats.RecordTick(d.Name(), outcome)
+		}
+	}()
+
+	// Registrations are applied before routing any message
+	d.handleControl(now)
+	d.checkHealth(now)
+
+	// A stalled distributor routes nothing until the stall ends
+	if d.stallFault(now) {
+		return false
+	}
+
+	// Retained messages of newly registered consumers go first
+	if n := len(d.replay); n > 0 {
+		more := d.replayRetained(now)
+		outcome = component.ProgressOutcome(len(d.replay) < n)
+		return more
+	}
+
+	m := d.inputPort.Peek()
+	if m == nil {
+		// No messages available, return false to stop ticking
+		return false
+	}
+
+	// The tick did useful work if the message left the input port, whether
+	// it was forwarded, retained, rejected, or dropped
+	defer func() { outcome = component.ProgressOutcome(d.inputPort.Peek() != m) }()
+
+	demoMsg, ok := m.(*msg.DemoMessage)
+	if !ok {
+		return d.reject(now, m, msg.ReasonInvalidType)
+	}
+
+	// The routing task lasts until the message leaves the input port. The
+	// message may be released by then.
+	id := demoMsg.ID
+	component.StartTask(d, "route", id)
+	defer func() {
+		if d.inputPort.Peek() != m {
+			component.EndTask(d, "route", id)
+		}
+	}()
+
+	// The creation stamp is moved to the distributor's clock before anything
+	// measures time from it
+	if d.timestamps != nil {
+		d.timestamps.Correct(now, demoMsg)
+	}
+
+	// Expired messages are not worth forwarding
+	if demoMsg.Expired(now) {
+		d.inputPort.Retrieve(now)
+		if d.stats != nil {
+			d.stats.RecordExpired(d.Name())
+		}
+		d.fanout = nil
+		d.report(now, component.ErrTTLExpiry, "Dropped expired message for %s", demoMsg.Destination)
+		d.conclude(now, id, "dropped, its TTL of %.2f s ran out", float64(demoMsg.TTL))
+		demoMsg.Release()
+		return d.inputPort.Peek() != nil
+	}
+
+	// Middlewares may drop, hold, or duplicate the message before it is
+	// routed
+	if d.middleware != nil && d.middleware.Active() {
+		if more, done := d.intercept(now, demoMsg); done {
+			return more
+		}
+	}
+
+	// Multicast messages are copied to every member of their group
+	if demoMsg.Group != "" && demoMsg.Destination == demoMsg.Group {
+		return d.fanOut(now, demoMsg)
+	}
+
+	// Messages for a consumer group go to the member they are assigned to
+	if d.consumerGroups != nil && d.consumerGroups.Has(demoMsg.Destination) {
+		assigned, ok := d.assign(now, demoMsg)
+		if !ok {
+			return d.reject(now, m, msg.ReasonNoRoute)
+		}
+		demoMsg = assigned
+	}
+
+	// Load balancing: the balancer picks the consumer instead of the producer
+	if d.balancer != nil {
+		addressed := demoMsg.Destination
+		demoMsg = d.rebalance(demoMsg)
+		if demoMsg.Destination != addressed {
+			d.decide(now, id, "balancer picked %s instead of %s", demoMsg.Destination, addressed)
+		}
+	}
+
+	// Routing rules send messages by their content or drop them
+	if d.rules != nil {
+		if routed, rule := d.rules.Apply(demoMsg); rule >= 0 {
+			if d.rules.Drops(rule) {
+				return d.dropByRule(now, m, demoMsg, rule)
+			}
+			if routed != demoMsg {
+				d.decide(now, id, "routing rule %d sent it to %s instead of %s", rule+1, routed.Destination, demoMsg.Destination)
+				if demoMsg != m {
+					// Rebalanced copy, replaced by the routed one
+					demoMsg.Release()
+				}
+				demoMsg = routed
+			}
+		}
+	}
+
+	// Messages for a removed consumer go to the remaining ones in turn
+	if _, ok := d.removals[demoMsg.Destination]; ok {
+		redirected := d.redirect(demoMsg)
+		if redirected != demoMsg {
+			d.decide(now, id, "redirected from removed %s to %s", demoMsg.Destination, redirected.Destination)
+			if demoMsg != m {
+				// Rebalanced copy, replaced by the redirected one
+				demoMsg.Release()
+			}
+			demoMsg = redirected
+		}
+	}
+
+	// Messages for a failed consumer go to the healthy ones in turn
+	if d.health != nil {
+		if rerouted := d.health.Reroute(demoMsg, d.routes); rerouted != demoMsg {
+			d.decide(now, id, "failed over from %s to %s", demoMsg.Destination, rerouted.Destination)
+			if demoMsg != m {
+				// Rebalanced copy, replaced by the rerouted one
+				demoMsg.Release()
+			}
+			demoMsg = rerouted
+		}
+	}
+
+	// Overflow routing: messages of an overloaded consumer go to the overflow
+	// consumer
+	if d.overflow != nil {
+		if rerouted := d.overflow.Reroute(demoMsg, d.routes); rerouted != demoMsg {
+			d.decide(now, id, "rerouted from overloaded %s to %s", demoMsg.Destination, rerouted.Destination)
+			if demoMsg != m {
+				// Rebalanced copy, replaced by the rerouted one
+				demoMsg.Release()
+			}
+			demoMsg = rerouted
+		}
+	}
+
+	outputPort, ok := d.outputPorts[demoMsg.Destination]
+	if !ok {
+		return d.reject(now, m, msg.ReasonUnknownDestination)
+	}
+
+	// Look up the consumer ports of the destination in the routing table
+	dstPorts, ok := d.routes.Lookup(demoMsg.Destination)
+	if ok && d.workPool != nil {
+		// Whichever consumer pulls the message serves it
+		dstPorts = []sim.Port{d.workPool}
+	}
+	if ok && d.windows != nil && d.windows.Full(demoMsg.Destination) {
+		// Wait for an ACK of the consumer, which wakes the distributor up
+		d.windows.Block(demoMsg.Destination)
+		d.log.Printf("[%.2f] %s: Window of %s full (%d outstanding)\n",
+			now, d.Name(), demoMsg.Destination, d.windows.Outstanding(demoMsg.Destination))
+		d.decide(now, id, "held, the window of %s is full", demoMsg.Destination)
+		return false
+	}
+	if ok && d.sharedBuffer != nil && !d.sharedBuffer.Admits(demoMsg.Destination) &&
+		!d.sharedBuffer.Evict(now, demoMsg) {
+		// Tail drop, the consumer has no room left
+		return d.dropNoRoom(now, m, demoMsg)
+	}
+	if !ok {
+		if d.retention == nil {
+			return d.reject(now, m, msg.ReasonNoRoute)
+		}
+		// Keep the message for a consumer that registers late
+		d.inputPort.Retrieve(now)
+		evicted := d.retention.Retain(now, demoMsg)
+		d.log.Printf("[%.2f] %s: Retained message for %s\n", now, d.Name(), demoMsg.Destination)
+		if evicted != nil {
+			d.report(now, component.ErrBufferOverflow, "Evicted retained message for %s (retention buffer full)", evicted.Destination)
+		}
+		d.decide(now, id, "retained, %s has not registered yet", demoMsg.Destination)
+		return d.inputPort.Peek() != nil
+	}
+
+	if d.copiesLeft > 0 {
+		// Copies the middlewares asked for go ahead of the message
+		return d.sendCopy(now, m, demoMsg, outputPort, dstPorts)
+	}
+
+	if d.forward(now, demoMsg, outputPort, dstPorts) {
+		d.inputPort.Retrieve(now)
+		if d.balancer != nil || demoMsg.ConsumerGroup != "" {
+			d.seqNums[pair{demoMsg.Source, demoMsg.Addressee()}] = demoMsg.SeqNum
+		}
+		if d.consumerGroups != nil {
+			d.consumerGroups.Routed(demoMsg)
+		}
+		if d.overflow != nil {
+			d.overflow.Routed(demoMsg)
+		}
+		if d.rules != nil {
+			d.rules.Sent(demoMsg)
+		}
+		d.redirected(demoMsg)
+		if d.health != nil {
+			d.health.Routed(demoMsg)
+		}
+		// The consumer releases the forwarded message. A copy made by the
+		// group assignment, the balancer, or overflow routing went in place
+		// of the original.
+		if demoMsg != m {
+			m.(*msg.DemoMessage).Release()
+		}
+		// Successfully sent message, continue ticking if more messages available
+		return d.inputPort.Peek() != nil
+	}
+
+	// Failed to send message (output port full), return false to stop ticking
+	// Will be woken up when the port becomes free
+	return false
+}
+
+// reject removes an undeliverable message from the input port and hands it
+// to the dead-letter sink
+func (d *Distributor) reject(now sim.VTimeInSec, m sim.Msg, reason msg.DeadLetterReason) bool {
+	if !d.deadLetter(now, m, reason) {
+		// Sink busy, will be woken up when the port becomes free
+		return false
+	}
+	if demoMsg, ok := m.(*msg.DemoMessage); ok {
+		fate := "dead-lettered"
+		if d.deadLetterDst == nil {
+			fate = "dropped"
+		}
+		d.conclude(now, demoMsg.ID, "%s, %s", fate, reason)
+	}
+	d.inputPort.Retrieve(now)
+	// Continue ticking if more messages available
+	return d.inputPort.Peek() != nil
+}
+
+// deadLetter sends an undeliverable message to the dead-letter sink, or
+// drops it if there is none. It returns false if the sink's port is busy,
+// and the message stays at the head of the input port.
+func (d *Distributor) deadLetter(now sim.VTimeInSec, m sim.Msg, reason msg.DeadLetterReason) bool {
+	if d.deadLetterDst == nil {
+		d.report(now, component.DeadLetterKind(reason), "Dropped message (%s)", reason)
+		return true
+	}
+
+	letter := &msg.DeadLetterMsg{Msg: m, Reason: reason}
+	letter.Meta().Src = d.deadLetterPort
+	letter.Meta().Dst = d.deadLetterDst
+	letter.Meta().SendTime = now
+	return d.deadLetterPort.Send(letter) == nil
+}
+
+// forward sends the message itself on to the destination's port, with its
+// metadata updated for the hop. It returns false if the output port is busy,
+// and the message keeps its metadata.
+func (d *Distributor) forward(
+	now sim.VTimeInSec,
+	demoMsg *msg.DemoMessage,
+	outputPort sim.Port,
+	dstPorts []sim.Port,
+) bool {
+	received := *demoMsg.Meta()
+	demoMsg.Meta().Src = outputPort
+	demoMsg.Meta().Dst = dstPorts[0]
+	demoMsg.Meta().SendTime = now
+	demoMsg.Meta().TrafficBytes = demoMsg.Size
+	// Switch networks reassemble the flits of a message by its ID
+	demoMsg.Meta().ID = sim.GetIDGenerator().Generate()
+
+	// Multi-queue consumers: steer the message to an RX queue by flow hash
+	if len(dstPorts) > 1 {
+		demoMsg.Meta().Dst = dstPorts[SteerToQueue(demoMsg.FlowID, len(dstPorts))]
+	}
+
+	err := outputPort.Send(demoMsg)
+	if err != nil {
+		*demoMsg.Meta() = received
+		return false
+	}
+
+	// Messages count as routed once, when they are sent to the consumer
+	if d.nextHops == nil && d.stats != nil {
+		d.stats.RecordRouted()
+	}
+	d.routed[demoMsg.Destination]++
+	if d.windows != nil {
+		d.windows.Sent(now, demoMsg.Destination)
+	}
+	if len(dstPorts) > 1 {
+		d.decide(now, demoMsg.ID, "steered to %s by flow %d", demoMsg.Meta().Dst.Name(), demoMsg.FlowID)
+	}
+	if d.coalescer != nil {
+		d.coalescer.MessageRouted(now, demoMsg.Destination)
+	}
+	if hop, ok := d.nextHops[demoMsg.Destination]; ok {
+		d.decide(now, demoMsg.ID, "passed on to %s, the next hop to %s", hop, demoMsg.Destination)
+		d.log.Printf("[%.2f] %s: Routed message for %s to %s\n", now, d.Name(), demoMsg.Destination, hop)
+	} else if demoMsg.Group != "" && d.subscriptions != nil {
+		d.log.Printf("[%.2f] %s: Routed message to %s (published to %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.Group)
+	} else if demoMsg.Group != "" {
+		d.log.Printf("[%.2f] %s: Routed message to %s (multicast to %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.Group)
+	} else if demoMsg.ConsumerGroup != "" {
+		d.log.Printf("[%.2f] %s: Routed message to %s (member of %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.ConsumerGroup)
+	} else if demoMsg.RuleFrom != "" {
+		d.log.Printf("[%.2f] %s: Routed message to %s (rule %d, addressed to %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.Rule, demoMsg.RuleFrom)
+	} else if demoMsg.OverflowFrom != "" {
+		d.log.Printf("[%.2f] %s: Routed message to %s (overflow from %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.OverflowFrom)
+	} else if d.workPool != nil {
+		d.log.Printf("[%.2f] %s: Queued message for %s in %s\n", now, d.Name(), demoMsg.Destination, d.workPool.Component().Name())
+	} else if demoMsg.RedirectedFrom != "" {
+		d.log.Printf("[%.2f] %s: Routed message to %s (redirected from %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.RedirectedFrom)
+	} else if demoMsg.FailoverFrom != "" {
+		d.log.Printf("[%.2f] %s: Routed message to %s (failed over from %s)\n",
+			now, d.Name(), demoMsg.Destination, demoMsg.FailoverFrom)
+	} else {
+		d.log.Printf("[%.2f] %s: Routed message to %s\n", now, d.Name(), demoMsg.Destination)
+	}
+	return true
+}
+
+// dropByRule removes a message a rule drops from the input port. A copy made
+// by the group assignment or the balancer is released with the original.
+func (d *Distributor) dropByRule(now sim.VTimeInSec, m sim.Msg, demoMsg *msg.DemoMessage, rule int) bool {
+	d.inputPort.Retrieve(now)
+	d.rules.Drop(rule)
+	d.log.Printf("[%.2f] %s: Dropped message for %s (rule %d)\n", now, d.Name(), demoMsg.Destination, rule+1)
+	d.conclude(now, demoMsg.ID, "dropped by routing rule %d", rule+1)
+	if demoMsg != m {
+		demoMsg.Release()
+	}
+	m.(*msg.DemoMessage).Release()
+	return d.inputPort.Peek() != nil
+}
+
+// dropNoRoom removes a message whose consumer has no room left in the
+// shared buffer from the input port. A copy made by the group assignment or
+// the balancer is released with the original.
+func (d *Distributor) dropNoRoom(now sim.VTimeInSec, m sim.Msg, demoMsg *msg.DemoMessage) bool {
+	d.inputPort.Retrieve(now)
+	d.sharedBuffer.Drop(demoMsg)
+	d.report(now, component.ErrBufferOverflow, "Dropped message for %s (no room in the shared buffer)", demoMsg.Destination)
+	d.conclude(now, demoMsg.ID, "dropped, %s had no room left in the shared buffer", demoMsg.Destination)
+	if demoMsg != m {
+		demoMsg.Release()
+	}
+	m.(*msg.DemoMessage).Release()
+	return d.inputPort.Peek() != nil
+}
+
+// stallFault holds the messages of a stalled distributor until the stall
+// ends. It returns false if the distributor is not stalled.
+func (d *Distributor) stallFault(now sim.VTimeInSec) bool {
+	if d.faults == nil {
+		return false
+	}
+	end, stalled := d.faults.Stalled(now, d.Name())
+	if !stalled {
+		return false
+	}
+	if end != d.stalledUntil {
+		d.stalledUntil = end
+		d.log.Printf("[%.2f] %s: Stalled until %.2f\n", now, d.Name(), end)
+		component.ScheduleWakeup(d.TickingComponent, end)
+	}
+	return true
+}
+
+// decide records a decision taken for a message
+func (d *Distributor) decide(now sim.VTimeInSec, id uint64, format string, args ...interface{}) {
+	if d.events != nil {
+		d.events.Decide(now, d.Name(), id, format, args...)
+	}
+}
+
+// conclude records the fate of a message
+func (d *Distributor) conclude(now sim.VTimeInSec, id uint64, format string, args ...interface{}) {
+	if d.events != nil {
+		d.events.Conclude(now, d.Name(), id, format, args...)
+	}
+}
+
+// report passes an error to the error log, or logs it if there is none
+func (d *Distributor) report(now sim.VTimeInSec, kind component.ErrorKind, format string, args ...interface{}) {
+	if d.errors != nil {
+		d.errors.Report(now, d.Name(), kind, format, args...)
+		return
+	}
+	d.log.Printf("[%.2f] %s: %s\n", now, d.Name(), fmt.Sprintf(format, args...))
+}
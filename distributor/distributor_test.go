@@ -0,0 +1,89 @@
+package distributor
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// firstBalancer picks the first candidate
+type firstBalancer struct{}
+
+func (firstBalancer) Pick(consumers []string, rng *rand.Rand) string { return consumers[0] }
+
+// TestRoutingTableLookup verifies adding, looking up, and removing routes
+func TestRoutingTableLookup(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := New("Distributor", engine, []string{"Consumer1"})
+	table := NewRoutingTable()
+	
+	if _, ok := table.Lookup("Consumer1"); ok {
+		t.Error("Expected no route in an empty table")
+	}
+	
+	table.Add("Consumer1", d.InputPort())
+	ports, ok := table.Lookup("Consumer1")
+	if !ok || len(ports) != 1 || ports[0] != d.InputPort() {
+		t.Errorf("Expected route to the input port, got %v", ports)
+	}
+	
+	table.Remove("Consumer1")
+	if _, ok := table.Lookup("Consumer1"); ok {
+		t.Error("Expected the route to be removed")
+	}
+}
+
+// TestSteerToQueueIsStablePerFlow verifies that all messages of a flow are
+// steered to the same RX queue
+func TestSteerToQueueIsStablePerFlow(t *testing.T) {
+	for flow := 0; flow < 32; flow++ {
+		first := SteerToQueue(flow, 4)
+		if first < 0 || first >= 4 {
+			t.Fatalf("Flow %d steered to out-of-range queue %d", flow, first)
+		}
+		if again := SteerToQueue(flow, 4); again != first {
+			t.Errorf("Flow %d steered to queue %d and then %d", flow, first, again)
+		}
+	}
+}
+
+// TestMulticastMembersAreRegisteredConsumers verifies that a group reaches
+// only its members that registered, and the broadcast group all of them
+func TestMulticastMembersAreRegisteredConsumers(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := New("Distributor", engine, []string{"Consumer1", "Consumer2", "Consumer3"},
+		WithGroups(map[string][]string{"Front": {"Consumer1", "Consumer3"}}))
+	d.routes.Add("Consumer1", nil)
+	d.routes.Add("Consumer2", nil)
+	
+	if members, ok := d.members("Front"); !ok || !reflect.DeepEqual(members, []string{"Consumer1"}) {
+		t.Errorf("Expected Front to reach Consumer1 only, got %v", members)
+	}
+	if members, _ := d.members(BroadcastGroup); !reflect.DeepEqual(members, []string{"Consumer1", "Consumer2"}) {
+		t.Errorf("Expected a broadcast to reach Consumer1 and Consumer2, got %v", members)
+	}
+	if _, ok := d.members("Back"); ok {
+		t.Error("Expected the unknown group Back not to exist")
+	}
+}
+
+// TestDistributorRebalancesToRegisteredConsumer verifies that a rebalanced
+// message goes to a registered consumer and continues its sequence
+func TestDistributorRebalancesToRegisteredConsumer(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	d := New("Distributor", engine, []string{"Consumer1", "Consumer2"}, WithBalancer(firstBalancer{}))
+	d.routes.Add("Consumer2", d.InputPort())
+	d.seqNums[pair{"Producer", "Consumer2"}] = 4
+	
+	m := d.rebalance(&msg.DemoMessage{ID: 7, Source: "Producer", Destination: "Consumer1", SeqNum: 1})
+	
+	if m.Destination != "Consumer2" || m.SeqNum != 5 {
+		t.Errorf("Expected message #5 for Consumer2, got #%d for %s", m.SeqNum, m.Destination)
+	}
+	if m.ID != 7 {
+		t.Errorf("Expected the message ID to be kept, got %d", m.ID)
+	}
+}
@@ -0,0 +1,64 @@
+package distributor
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// rebalanceGroups reassigns the partitions of the consumer groups whose
+// registered members changed
+func (d *Distributor) rebalanceGroups(now sim.VTimeInSec) {
+	if d.consumerGroups == nil {
+		return
+	}
+	routable := func(name string) bool {
+		_, routed := d.routes.Lookup(name)
+		_, ok := d.outputPorts[name]
+		return routed && ok
+	}
+	for _, rebalance := range d.consumerGroups.Reassign(now, routable) {
+		d.log.Printf("[%.2f] %s: Rebalanced %s\n", now, d.Name(), rebalance)
+	}
+}
+
+// assign returns a copy of a message for a consumer group addressed to the
+// member it is assigned to and numbered in the sequence of that member
+func (d *Distributor) assign(now sim.VTimeInSec, m *msg.DemoMessage) (*msg.DemoMessage, bool) {
+	member, partition, ok := d.consumerGroups.Assign(m.Destination, m)
+	if !ok {
+		return m, false
+	}
+	if partition >= 0 {
+		d.decide(now, m.ID, "assigned to %s, the owner of partition %d of %s", member, partition, m.Destination)
+	} else {
+		d.decide(now, m.ID, "assigned to %s, the next member of %s in turn", member, m.Destination)
+	}
+	assigned := m.Clone()
+	assigned.Destination = member
+	assigned.ConsumerGroup = m.Destination
+	assigned.SeqNum = d.seqNums[pair{m.Source, member}] + 1
+	return assigned, true
+}
+
+// rebalance returns a copy of the message addressed to the consumer picked by
+// the distributor's balancer among the registered consumers. The copy is
+// numbered in the sequence of its new consumer, so that the verifier checks
+// the order in which the distributor delivers. The message is returned
+// unchanged while no consumer is registered.
+func (d *Distributor) rebalance(m *msg.DemoMessage) *msg.DemoMessage {
+	var candidates []string
+	for _, name := range d.routes.Names() {
+		if _, ok := d.outputPorts[name]; ok {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return m
+	}
+
+	dest := d.balancer.Pick(candidates, d.rand)
+	rebalanced := m.Clone()
+	rebalanced.Destination = dest
+	rebalanced.SeqNum = d.seqNums[pair{m.Source, dest}] + 1
+	return rebalanced
+}
@@ -0,0 +1,63 @@
+package distributor
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// intercept passes the message at the head of the input port through the
+// middlewares, once. It reports whether the middlewares dropped or hold the
+// message, and then whether the tick should continue.
+func (d *Distributor) intercept(now sim.VTimeInSec, demoMsg *msg.DemoMessage) (more, done bool) {
+	if !d.intercepted.Holds(demoMsg) {
+		d.intercepted = d.middleware.Intercept(now, middleware.StageRoute, d.Name(), demoMsg)
+		d.copiesLeft = d.intercepted.Copies
+		if demoMsg.Group != "" && demoMsg.Destination == demoMsg.Group {
+			// Multicast messages are copied to the members of their group
+			// instead
+			d.copiesLeft = 0
+		}
+		if d.intercepted.Delay > 0 {
+			d.log.Printf("[%.2f] %s: Middleware holds message for %s for %.2f s\n",
+				now, d.Name(), demoMsg.Destination, float64(d.intercepted.Delay))
+			demoMsg.Held += d.intercepted.Delay
+		}
+	}
+	i := d.intercepted
+	if i.Drop {
+		d.inputPort.Retrieve(now)
+		d.intercepted = nil
+		d.log.Printf("[%.2f] %s: Middleware dropped message for %s\n", now, d.Name(), demoMsg.Destination)
+		d.conclude(now, demoMsg.ID, "dropped by a middleware")
+		demoMsg.Release()
+		return d.inputPort.Peek() != nil, true
+	}
+	if now < i.ReadyAt() {
+		component.ScheduleWakeup(d.TickingComponent, i.ReadyAt())
+		return false, true
+	}
+	return false, false
+}
+
+// sendCopy sends a copy of the message a middleware duplicated ahead of the
+// message itself, which stays at the head of the input port
+func (d *Distributor) sendCopy(now sim.VTimeInSec, m sim.Msg, demoMsg *msg.DemoMessage, outputPort sim.Port, dstPorts []sim.Port) bool {
+	dup := demoMsg.Clone()
+	dup.Duplicate = true
+	sent := d.forward(now, dup, outputPort, dstPorts)
+	if sent {
+		d.copiesLeft--
+		d.middleware.Copied(middleware.StageRoute)
+	} else {
+		dup.Release()
+	}
+	if demoMsg != m {
+		// Copy made by the group assignment, the balancer, or a rerouting,
+		// made again for the next copy
+		demoMsg.Release()
+	}
+	// The port is free again on the next tick
+	return sent
+}
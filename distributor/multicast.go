@@ -0,0 +1,200 @@
+package distributor
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// BroadcastGroup is the group of every consumer registered with the
+// distributor
+const BroadcastGroup = "all"
+
+// FanOut is the multicast message at the head of the distributor's input
+// port and the members of its group that have not been sent a copy yet
+type FanOut struct {
+	Msg     *msg.DemoMessage
+	Members []string
+	Pending []string
+	Sent    int
+}
+
+// members returns the members of a group that a consumer registered for,
+// and whether the group exists. With topics, the groups are the topics and
+// their members the subscribers.
+func (d *Distributor) members(group string) ([]string, bool) {
+	var names []string
+	var ok bool
+	switch {
+	case d.subscriptions != nil:
+		names, ok = d.subscriptions.Subscribers(group)
+	case group == BroadcastGroup:
+		return d.routes.Names(), true
+	default:
+		names, ok = d.groups[group]
+	}
+	if !ok {
+		return nil, false
+	}
+	var members []string
+	for _, name := range names {
+		if _, ok := d.routes.Lookup(name); ok {
+			members = append(members, name)
+		}
+	}
+	return members, true
+}
+
+// fanOut sends a copy of the multicast message at the head of the input
+// port to every member of its group. The message stays at the head until
+// every member was sent a copy; members whose port or window was full are
+// retried on the next tick, the others are not sent a second copy. It
+// returns whether the tick should continue.
+func (d *Distributor) fanOut(now sim.VTimeInSec, m *msg.DemoMessage) bool {
+	if d.fanout == nil || d.fanout.Msg != m {
+		members, ok := d.members(m.Group)
+		if !ok {
+			return d.reject(now, m, msg.ReasonUnknownDestination)
+		}
+		if len(members) == 0 && d.subscriptions != nil {
+			return d.reject(now, m, msg.ReasonNoSubscriber)
+		}
+		if len(members) == 0 {
+			return d.reject(now, m, msg.ReasonNoRoute)
+		}
+		d.fanout = &FanOut{Msg: m, Members: members, Pending: members}
+		if d.membership != nil {
+			d.membership.FannedOut(now, m.Group)
+		}
+		d.decide(now, m.ID, "fanned out to %s", strings.Join(members, ", "))
+	} else if d.stats != nil {
+		d.stats.RecordBranchRetry(len(d.fanout.Pending))
+	}
+
+	var pending []string
+	for _, member := range d.fanout.Pending {
+		outputPort, ok := d.outputPorts[member]
+		dstPorts, routed := d.routes.Lookup(member)
+		if !ok || !routed {
+			// Removed since the fan-out started
+			continue
+		}
+		if d.membership != nil && m.Group != BroadcastGroup && !d.isMember(m.Group, member) {
+			// Left the group since the fan-out started
+			d.membership.Abandon()
+			continue
+		}
+		if d.windows != nil && d.windows.Full(member) {
+			d.windows.Block(member)
+			pending = append(pending, member)
+			continue
+		}
+		branch := m.Clone()
+		branch.Destination = member
+		if d.membership != nil {
+			// Members that joined late are numbered from their first copy
+			branch.SeqNum = d.seqNums[pair{m.Source, branch.Addressee()}] + 1
+		}
+		if !d.forward(now, branch, outputPort, dstPorts) {
+			branch.Release()
+			pending = append(pending, member)
+			continue
+		}
+		if d.membership != nil {
+			d.seqNums[pair{m.Source, branch.Addressee()}] = branch.SeqNum
+			d.membership.Copied(now, m.Group, member)
+		}
+		d.fanout.Sent++
+		if d.stats != nil {
+			d.stats.RecordCopy(now, branch)
+		}
+	}
+	d.fanout.Pending = pending
+	if len(pending) > 0 {
+		// Wait for the busy ports, which wake the distributor up
+		return false
+	}
+
+	// Every member got its copy, the copies replace the message
+	d.inputPort.Retrieve(now)
+	if d.stats != nil {
+		d.stats.RecordFannedOut(now, m)
+	}
+	if d.subscriptions != nil {
+		d.subscriptions.Publish(m.Group, d.fanout.Members, d.fanout.Sent)
+	}
+	m.Release()
+	d.fanout = nil
+	return d.inputPort.Peek() != nil
+}
+
+// isMember reports whether a consumer is a member of a multicast group
+func (d *Distributor) isMember(group, consumer string) bool {
+	for _, member := range d.groups[group] {
+		if member == consumer {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMembership applies a join or leave of a consumer to the members of
+// its multicast group
+func (d *Distributor) handleMembership(now sim.VTimeInSec, m *msg.MembershipMsg) {
+	sent := m.Meta().SendTime
+	members, ok := d.groups[m.Group]
+	switch {
+	case d.membership == nil || !ok:
+		d.report(now, component.ErrUnknownDestination, "Rejected membership change of %s in %s (no such group)",
+			m.Consumer, m.Group)
+	case m.Leave && !d.isMember(m.Group, m.Consumer):
+		d.log.Printf("[%.2f] %s: Ignored leave of %s from %s (not a member)\n", now, d.Name(), m.Consumer, m.Group)
+	case m.Leave:
+		var remaining []string
+		for _, member := range members {
+			if member != m.Consumer {
+				remaining = append(remaining, member)
+			}
+		}
+		d.groups[m.Group] = remaining
+		stale := d.membership.Left(now, sent, m.Group, m.Consumer)
+		d.log.Printf("[%.2f] %s: %s left %s (sent at %.2f, %d stale copies)\n",
+			now, d.Name(), m.Consumer, m.Group, sent, stale)
+	case d.isMember(m.Group, m.Consumer):
+		d.log.Printf("[%.2f] %s: Ignored join of %s to %s (already a member)\n", now, d.Name(), m.Consumer, m.Group)
+	default:
+		members = append(append([]string(nil), members...), m.Consumer)
+		sort.Strings(members)
+		d.groups[m.Group] = members
+		missed := d.membership.Joined(now, sent, m.Group)
+		d.log.Printf("[%.2f] %s: %s joined %s (sent at %.2f, %d messages missed)\n",
+			now, d.Name(), m.Consumer, m.Group, sent, missed)
+	}
+}
+
+// handleSubscription applies a subscription or unsubscription of a consumer
+// to the subscription table
+func (d *Distributor) handleSubscription(now sim.VTimeInSec, m *msg.SubscribeMsg) {
+	switch {
+	case d.subscriptions == nil || len(d.subscriptions.Topics(m.Pattern)) == 0:
+		d.report(now, component.ErrUnknownDestination, "Rejected subscription of %s to %s (no such topic)",
+			m.Consumer, m.Pattern)
+	case m.Unsubscribe:
+		if d.subscriptions.Unsubscribe(m.Consumer, m.Pattern) {
+			d.log.Printf("[%.2f] %s: Unsubscribed %s from %s\n", now, d.Name(), m.Consumer, m.Pattern)
+		} else {
+			d.log.Printf("[%.2f] %s: Ignored unsubscription of %s from %s (not subscribed)\n",
+				now, d.Name(), m.Consumer, m.Pattern)
+		}
+	default:
+		if d.subscriptions.Subscribe(m.Consumer, m.Pattern) {
+			d.log.Printf("[%.2f] %s: Subscribed %s to %s\n", now, d.Name(), m.Consumer, m.Pattern)
+		} else {
+			d.log.Printf("[%.2f] %s: Ignored subscription of %s to %s (already subscribed)\n",
+				now, d.Name(), m.Consumer, m.Pattern)
+		}
+	}
+}
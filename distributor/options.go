@@ -0,0 +1,313 @@
+package distributor
+
+import (
+	"math/rand"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Stats counts the messages of the distributor
+type Stats interface {
+	RecordTick(name string, outcome component.TickOutcome)
+	RecordExpired(component string)
+	RecordRouted()
+	RecordCopy(now sim.VTimeInSec, branch *msg.DemoMessage)
+	RecordFannedOut(now sim.VTimeInSec, m *msg.DemoMessage)
+	// RecordBranchRetry counts the members of a fan-out tried again
+	RecordBranchRetry(n int)
+}
+
+// Events records the decisions taken for the messages and their fate
+type Events interface {
+	Decide(now sim.VTimeInSec, component string, msgID uint64, format string, args ...interface{})
+	Conclude(now sim.VTimeInSec, component string, msgID uint64, format string, args ...interface{})
+}
+
+// Errors collects the errors the distributor runs into
+type Errors interface {
+	Report(now sim.VTimeInSec, source string, kind component.ErrorKind, format string, args ...interface{})
+}
+
+// Timestamps moves the creation stamps of skewed producers to the clock of
+// the distributor
+type Timestamps interface {
+	Correct(now sim.VTimeInSec, m *msg.DemoMessage)
+}
+
+// Coalescer notifies the consumers of the routed messages in batches
+type Coalescer interface {
+	MessageRouted(now sim.VTimeInSec, dest string)
+}
+
+// Retention keeps the messages of destinations without a registered
+// consumer
+type Retention interface {
+	// Retain keeps a message, and returns the message it evicted, or nil
+	Retain(now sim.VTimeInSec, m *msg.DemoMessage) *msg.DemoMessage
+	// Claim returns the messages retained for a destination
+	Claim(now sim.VTimeInSec, dest string) []*msg.DemoMessage
+	Len() int
+}
+
+// Balancer picks the consumer of a message instead of its producer
+type Balancer interface {
+	Pick(consumers []string, rng *rand.Rand) string
+}
+
+// Rerouting sends the messages of some consumers to others
+type Rerouting interface {
+	// Reroute returns a copy of the message for another consumer, or the
+	// message unchanged
+	Reroute(m *msg.DemoMessage, routes *RoutingTable) *msg.DemoMessage
+	// Routed counts a rerouted message once it has been sent
+	Routed(m *msg.DemoMessage)
+}
+
+// Health tells failed consumers from healthy ones by their heartbeats, and
+// reroutes the messages of the failed ones
+type Health interface {
+	Rerouting
+	// Heartbeat records a heartbeat of a consumer. It returns when the
+	// failure it ends was detected, and false if the consumer was healthy.
+	Heartbeat(now sim.VTimeInSec, consumer string) (sim.VTimeInSec, bool)
+	// Check considers the consumers whose heartbeats are overdue at now
+	// failed, and returns them with the time of their last heartbeat
+	Check(now sim.VTimeInSec) map[string]sim.VTimeInSec
+	// Overdue returns when a heartbeat received at now is overdue, and false
+	// if the checks end before
+	Overdue(now sim.VTimeInSec) (sim.VTimeInSec, bool)
+}
+
+// Windows limits the unacknowledged messages outstanding at every consumer
+type Windows interface {
+	Full(dest string) bool
+	// Block counts a message waiting for the full window of a consumer
+	Block(dest string)
+	Sent(now sim.VTimeInSec, dest string)
+	Acked(now sim.VTimeInSec, dest string)
+	Outstanding(dest string) int
+	// LimitOf returns the window of a consumer, 0 for no limit
+	LimitOf(dest string) int
+}
+
+// Membership measures the joins and leaves of the multicast groups
+type Membership interface {
+	FannedOut(now sim.VTimeInSec, group string)
+	Copied(now sim.VTimeInSec, group, member string)
+	// Joined records a join sent at sent and applied now, and returns the
+	// messages of the group the consumer missed meanwhile
+	Joined(now, sent sim.VTimeInSec, group string) int
+	// Left records a leave sent at sent and applied now, and returns the
+	// copies the consumer was sent meanwhile
+	Left(now, sent sim.VTimeInSec, group, member string) int
+	// Abandon counts a copy not sent to a member that left during the
+	// fan-out of a message
+	Abandon()
+}
+
+// Topics is the subscription table of the topics messages are published to
+type Topics interface {
+	// Topics returns the topics a pattern matches
+	Topics(pattern string) []string
+	Subscribe(consumer, pattern string) bool
+	Unsubscribe(consumer, pattern string) bool
+	// Subscribers returns the subscribers of a topic, and false if there is
+	// no such topic
+	Subscribers(topic string) ([]string, bool)
+	// Publish records a message on a topic fanned out to its subscribers
+	Publish(topic string, members []string, copies int)
+	// Subscriptions returns the patterns of every consumer
+	Subscriptions() map[string][]string
+}
+
+// ConsumerGroups lets consumers share a destination name
+type ConsumerGroups interface {
+	// Has reports whether a destination name is a consumer group
+	Has(name string) bool
+	// Assign picks the member of a group a message goes to and the
+	// partition of the message, -1 if it has none. It returns false if the
+	// group has no members.
+	Assign(group string, m *msg.DemoMessage) (string, int, bool)
+	Routed(m *msg.DemoMessage)
+	// Destination returns the name a consumer is discovered under
+	Destination(consumer string) string
+	// Destinations returns the names the consumers are discovered under
+	Destinations(consumers []string) []string
+	// Leave takes a consumer out of its group, and returns the group
+	Leave(consumer string) (string, bool)
+	// Reassign rebalances the groups whose routable members changed, and
+	// describes every rebalance
+	Reassign(now sim.VTimeInSec, routable func(string) bool) []string
+	// Owners returns the owner of every partition of each group
+	Owners() map[string][]string
+}
+
+// Rules route the messages by their content
+type Rules interface {
+	// Apply returns the message addressed to the consumer of the rule it
+	// matches and the index of the rule, -1 if it matches none
+	Apply(m *msg.DemoMessage) (*msg.DemoMessage, int)
+	// Drops reports whether a rule drops the messages it matches
+	Drops(rule int) bool
+	// Drop counts a message a rule dropped
+	Drop(rule int)
+	// Sent counts the message for the rule that routed it
+	Sent(m *msg.DemoMessage)
+}
+
+// SharedBuffer is the buffer pool that holds the RX queues of the consumers
+type SharedBuffer interface {
+	// Admits reports whether there is room for another message for the
+	// consumer
+	Admits(consumer string) bool
+	// Evict pushes out a queued message of a lower priority to make room
+	// for m, and returns false if there is none
+	Evict(now sim.VTimeInSec, m *msg.DemoMessage) bool
+	// Drop counts a message dropped for lack of room
+	Drop(m *msg.DemoMessage)
+}
+
+// Faults stalls the distributor
+type Faults interface {
+	// Stalled returns the end of the stall of the distributor at now, and
+	// false if it is not stalled
+	Stalled(now sim.VTimeInSec, distributor string) (sim.VTimeInSec, bool)
+	// Stalls returns the number of times the distributor stalls
+	Stalls(distributor string) int
+}
+
+// Option configures a distributor
+type Option func(d *Distributor)
+
+// WithFreq sets the frequency the distributor ticks at
+func WithFreq(freq sim.Freq) Option {
+	return func(d *Distributor) { d.Freq = freq }
+}
+
+// WithCapacity sets the messages the input port holds
+func WithCapacity(capacity int) Option {
+	return func(d *Distributor) { d.capacity = capacity }
+}
+
+// WithSeed seeds the random source of the balancer
+func WithSeed(seed int64) Option {
+	return func(d *Distributor) {
+		d.source = component.NewCountingSource(seed)
+		d.rand = rand.New(d.source)
+	}
+}
+
+// WithDeadLetters sends the undeliverable messages to the input port of a
+// dead-letter sink instead of dropping them
+func WithDeadLetters(sink sim.Port) Option {
+	return func(d *Distributor) { d.deadLetterDst = sink }
+}
+
+// WithTimestamps corrects the creation stamps of skewed producers
+func WithTimestamps(timestamps Timestamps) Option {
+	return func(d *Distributor) { d.timestamps = timestamps }
+}
+
+// WithCoalescer notifies the consumers of their messages in batches
+func WithCoalescer(coalescer Coalescer) Option {
+	return func(d *Distributor) { d.coalescer = coalescer }
+}
+
+// WithRetention keeps the messages of consumers that register late
+func WithRetention(retention Retention) Option {
+	return func(d *Distributor) { d.retention = retention }
+}
+
+// WithBalancer lets the balancer pick the consumer of every message
+func WithBalancer(balancer Balancer) Option {
+	return func(d *Distributor) { d.balancer = balancer }
+}
+
+// WithGroups sets the members of the multicast groups
+func WithGroups(groups map[string][]string) Option {
+	return func(d *Distributor) { d.groups = groups }
+}
+
+// WithMembership lets the consumers join and leave the multicast groups
+func WithMembership(membership Membership) Option {
+	return func(d *Distributor) { d.membership = membership }
+}
+
+// WithTopics copies every message to the subscribers of its topic instead
+// of the members of a group
+func WithTopics(topics Topics) Option {
+	return func(d *Distributor) { d.subscriptions = topics }
+}
+
+// WithConsumerGroups assigns the messages of consumer groups to their
+// members
+func WithConsumerGroups(groups ConsumerGroups) Option {
+	return func(d *Distributor) { d.consumerGroups = groups }
+}
+
+// WithOverflow reroutes the messages of overloaded consumers
+func WithOverflow(overflow Rerouting) Option {
+	return func(d *Distributor) { d.overflow = overflow }
+}
+
+// WithHealth reroutes the messages of consumers that stopped sending
+// heartbeats
+func WithHealth(health Health) Option {
+	return func(d *Distributor) { d.health = health }
+}
+
+// WithWindows limits the unacknowledged messages at every consumer
+func WithWindows(windows Windows) Option {
+	return func(d *Distributor) { d.windows = windows }
+}
+
+// WithRules routes the messages by their content before anything else
+// picks a consumer
+func WithRules(rules Rules) Option {
+	return func(d *Distributor) { d.rules = rules }
+}
+
+// WithSharedBuffer drops the messages of consumers without room in the
+// shared buffer
+func WithSharedBuffer(buffer SharedBuffer) Option {
+	return func(d *Distributor) { d.sharedBuffer = buffer }
+}
+
+// WithWorkPool queues every message in the work pool behind the input port
+func WithWorkPool(pool sim.Port) Option {
+	return func(d *Distributor) { d.workPool = pool }
+}
+
+// WithMiddleware passes the messages through the chain before they are
+// routed
+func WithMiddleware(chain *middleware.Chain) Option {
+	return func(d *Distributor) { d.middleware = chain }
+}
+
+// WithFaults stalls the distributor as the faults say
+func WithFaults(faults Faults) Option {
+	return func(d *Distributor) { d.faults = faults }
+}
+
+// WithStats sets the counters the distributor records its messages in
+func WithStats(stats Stats) Option {
+	return func(d *Distributor) { d.stats = stats }
+}
+
+// WithEvents records the routing decisions
+func WithEvents(events Events) Option {
+	return func(d *Distributor) { d.events = events }
+}
+
+// WithErrors reports the errors to the collector instead of logging them
+func WithErrors(errors Errors) Option {
+	return func(d *Distributor) { d.errors = errors }
+}
+
+// WithLogger sets the logger of the events of the distributor
+func WithLogger(log component.Logger) Option {
+	return func(d *Distributor) { d.log = log }
+}
@@ -0,0 +1,58 @@
+package distributor
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// RemoveDestination stops routing to a destination: its route and output
+// port are removed, and the producers that discovered the destinations are
+// told. The messages still addressed to it are redirected from then on. It
+// returns the messages routed to the destination so far, and the redirected
+// ones by the destination they go to, which fills up as they are sent.
+func (d *Distributor) RemoveDestination(now sim.VTimeInSec, name string) (int, map[string]int) {
+	redistributed := make(map[string]int)
+	if d.removals == nil {
+		d.removals = make(map[string]map[string]int)
+	}
+	d.removals[name] = redistributed
+	delete(d.outputPorts, name)
+	d.routes.Remove(name)
+	d.rebalanceGroups(now)
+
+	if len(d.subscribers) > 0 {
+		d.queueAnnouncements()
+		d.TickLater(now)
+	}
+	return d.routed[name], redistributed
+}
+
+// redirect returns a copy of a message for a removed destination addressed
+// to the next remaining destination in turn. The copy keeps its sequence
+// number and remembers the removed destination. The message is returned
+// unchanged if no destination remains.
+func (d *Distributor) redirect(m *msg.DemoMessage) *msg.DemoMessage {
+	var candidates []string
+	for _, name := range d.routes.Names() {
+		if _, ok := d.outputPorts[name]; ok {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return m
+	}
+
+	redirected := m.Clone()
+	redirected.Destination = candidates[d.redirects%len(candidates)]
+	redirected.RedirectedFrom = m.Destination
+	d.redirects++
+	return redirected
+}
+
+// redirected counts a redirected message once it has been sent
+func (d *Distributor) redirected(m *msg.DemoMessage) {
+	if m.RedirectedFrom == "" {
+		return
+	}
+	d.removals[m.RedirectedFrom][m.Destination]++
+}
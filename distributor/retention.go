@@ -0,0 +1,27 @@
+package distributor
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// replayRetained forwards the next retained message claimed by a newly
+// registered consumer
+func (d *Distributor) replayRetained(now sim.VTimeInSec) bool {
+	m := d.replay[0]
+	outputPort := d.outputPorts[m.Destination]
+	dstPorts, _ := d.routes.Lookup(m.Destination)
+
+	if d.windows != nil && d.windows.Full(m.Destination) {
+		// Wait for an ACK of the consumer, which wakes the distributor up
+		d.windows.Block(m.Destination)
+		return false
+	}
+	if !d.forward(now, m, outputPort, dstPorts) {
+		// Output port busy, will be woken up when it becomes free
+		return false
+	}
+
+	d.replay = d.replay[1:]
+	d.decide(now, m.ID, "replayed from retention to %s", m.Destination)
+	return len(d.replay) > 0 || d.inputPort.Peek() != nil
+}
@@ -0,0 +1,50 @@
+package distributor
+
+import (
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// RoutingTable maps destination names to the ports that receive messages for
+// them. A destination may have several ports (e.g., the RX queues of a
+// multi-queue consumer).
+type RoutingTable struct {
+	entries map[string][]sim.Port
+}
+
+// NewRoutingTable creates an empty routing table
+func NewRoutingTable() *RoutingTable {
+	return &RoutingTable{
+		entries: make(map[string][]sim.Port),
+	}
+}
+
+// Add maps a destination name to its ports, replacing any previous mapping
+func (t *RoutingTable) Add(name string, ports ...sim.Port) {
+	t.entries[name] = ports
+}
+
+// Remove deletes the mapping of a destination name
+func (t *RoutingTable) Remove(name string) {
+	delete(t.entries, name)
+}
+
+// Lookup returns the ports of a destination name
+func (t *RoutingTable) Lookup(name string) ([]sim.Port, bool) {
+	ports, ok := t.entries[name]
+	if !ok || len(ports) == 0 {
+		return nil, false
+	}
+	return ports, true
+}
+
+// Names returns the sorted names of all the routable destinations
+func (t *RoutingTable) Names() []string {
+	names := make([]string, 0, len(t.entries))
+	for name := range t.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
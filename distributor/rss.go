@@ -0,0 +1,21 @@
+package distributor
+
+import (
+	"hash/fnv"
+)
+
+// RSSHash hashes a flow ID, similar to the receive-side scaling hash a NIC
+// computes over the packet header
+func RSSHash(flowID int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte{
+		byte(flowID), byte(flowID >> 8), byte(flowID >> 16), byte(flowID >> 24),
+	})
+	return h.Sum32()
+}
+
+// SteerToQueue picks the RX queue of a flow. All messages of the same flow
+// are steered to the same queue.
+func SteerToQueue(flowID int, numQueues int) int {
+	return int(RSSHash(flowID) % uint32(numQueues))
+}
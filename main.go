@@ -1,290 +1,254 @@
 package main
 
 import (
-	"flag"
 	"fmt"
-	"log"
-	"math/rand"
-	"time"
+	"os"
+	"strings"
 
 	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/demo"
 )
 
-// DemoMessage represents a message with a destination consumer
-type DemoMessage struct {
-	meta        sim.MsgMeta
-	Content     string
-	Destination string
-	RemotePort  sim.Port // Final destination port (remote port)
-}
-
-// Meta returns the message metadata
-func (m *DemoMessage) Meta() *sim.MsgMeta {
-	return &m.meta
-}
-
-// Clone creates a copy of the message
-func (m *DemoMessage) Clone() sim.Msg {
-	clone := *m
-	return &clone
-}
-
-// Producer generates messages randomly and sends to distributor
-type Producer struct {
-	*sim.TickingComponent
-	outputPort    sim.Port
-	dstPort       sim.Port                 // Distributor's input port (immediate hop)
-	consumerPorts map[string]sim.Port      // Map consumer name to their input port (remote ports)
-	consumers     []string
-	rand          *rand.Rand
-	stopTime      sim.VTimeInSec
-}
-
-// NewProducer creates a new producer component
-func NewProducer(name string, engine sim.Engine, consumers []string, stopTime sim.VTimeInSec) *Producer {
-	p := &Producer{
-		consumers:     consumers,
-		consumerPorts: make(map[string]sim.Port),
-		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
-		stopTime:      stopTime,
+func main() {
+	// The first argument names the command, the simulation runs without one
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	c, ok := lookupCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage(newRunFlags(demo.DefaultConfig()))
+		os.Exit(2)
 	}
-	p.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, p)
-	p.outputPort = sim.NewLimitNumMsgPort(p, 1, name+".Out")
-	return p
+	cfg := demo.DefaultConfig()
+	defer func() { closeOutput(cfg.Out()) }()
+	c.run(cfg, args)
 }
 
-// Tick generates messages randomly
-func (p *Producer) Tick(now sim.VTimeInSec) bool {
-	// Stop generating after stopTime
-	if now >= p.stopTime {
-		return false
+// run runs the simulation of cfg, or the scenario or experiment it selects,
+// and reports the results. The program fails if the run missed a budget or
+// lost messages.
+func run(cfg *demo.Config, replayed *demo.Checkpoint) {
+	// The RPC server takes the standard output for its answers, so the log of
+	// its runs goes nowhere unless it goes to a file
+	if cfg.RPC && cfg.Output == "console" {
+		cfg.Sink = demo.NullSink{}
+	}
+	out := cfg.Out()
+	if cfg.RPC {
+		if err := demo.NewRPCServer(cfg).Serve(os.Stdin, os.Stdout); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		return
 	}
 	
-	// Random generation: 30% chance to generate a message each tick
-	if p.rand.Float64() < 0.3 {
-		// Pick a random consumer as destination
-		dest := p.consumers[p.rand.Intn(len(p.consumers))]
-		
-		// Get the remote port for the destination
-		remotePort, ok := p.consumerPorts[dest]
-		if !ok {
-			// Consumer port not registered, skip this message
-			fmt.Printf("[%.2f] Producer: Consumer port not found for %s\n", now, dest)
-			return true
+	// The benchmark runs the simulation at growing scales
+	if cfg.Bench > 0 {
+		results, err := demo.RunBench(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
 		}
-		
-		msg := &DemoMessage{
-			Content:     fmt.Sprintf("Message at time %.2f", now),
-			Destination: dest,
-			RemotePort:  remotePort, // Store the final destination port
+		demo.PrintBench(out, results)
+		return
+	}
+	
+	// Monte Carlo runs replicate the simulation with independent seeds
+	if cfg.Runs > 1 {
+		replications, err := demo.RunReplications(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
 		}
-		msg.Meta().Src = p.outputPort
-		msg.Meta().Dst = p.dstPort // Send to distributor (immediate hop)
-		msg.Meta().SendTime = now
-		
-		err := p.outputPort.Send(msg)
+		demo.PrintReplications(out, replications)
+		return
+	}
+	
+	// Built-in scenarios run several simulations and compare them
+	if cfg.Scenario == "batch-vs-streaming" {
+		results, err := demo.RunBatchVsStreaming(cfg)
 		if err != nil {
-			return false
+			fatalf(out, "Error: %v", err)
+		}
+		if err := demo.ReportComparison(cfg, "Batch vs. Streaming", results); err != nil {
+			fatalf(out, "Error: %v", err)
 		}
-		fmt.Printf("[%.2f] Producer: Generated message for %s\n", now, dest)
+		return
 	}
-	return true
-}
-
-// Distributor routes messages to the correct consumer
-type Distributor struct {
-	*sim.TickingComponent
-	inputPort   sim.Port
-	outputPorts map[string]sim.Port
-}
-
-// NewDistributor creates a new distributor component
-func NewDistributor(name string, engine sim.Engine, consumers []string) *Distributor {
-	d := &Distributor{
-		outputPorts: make(map[string]sim.Port),
+	if cfg.Scenario == "dest-policy" {
+		results, err := demo.RunDestinationPolicies(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		if err := demo.ReportComparison(cfg, "Routing Policies", results); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		return
 	}
-	d.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, d)
-	d.inputPort = sim.NewLimitNumMsgPort(d, 10, name+".In")
-	
-	for _, consumer := range consumers {
-		d.outputPorts[consumer] = sim.NewLimitNumMsgPort(d, 1, name+".Out."+consumer)
+	if cfg.Scenario == "gc-pauses" {
+		results, err := demo.RunPauseImpact(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		if err := demo.ReportComparison(cfg, "Consumer Pauses", results); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		return
 	}
-	
-	return d
-}
-
-// Tick processes messages from input and routes to output
-func (d *Distributor) Tick(now sim.VTimeInSec) bool {
-	msg := d.inputPort.Peek()
-	if msg == nil {
-		// No messages available, return false to stop ticking
-		return false
+	if cfg.Scenario == "work-pool" {
+		results, err := demo.RunWorkPool(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		if err := demo.ReportComparison(cfg, "Dedicated Queues vs. Work Pool", results); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		return
 	}
-	
-	demoMsg, ok := msg.(*DemoMessage)
-	if !ok {
-		d.inputPort.Retrieve(now)
-		// Invalid message consumed, continue ticking if more messages available
-		return d.inputPort.Peek() != nil
+	if cfg.Scenario == "buffer-sharing" {
+		results, err := demo.RunBufferSharing(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		demo.PrintBufferSharing(out, results)
+		return
 	}
-	
-	outputPort, ok := d.outputPorts[demoMsg.Destination]
-	if !ok {
-		fmt.Printf("[%.2f] Distributor: Unknown destination %s\n", now, demoMsg.Destination)
-		d.inputPort.Retrieve(now)
-		// Invalid destination, continue ticking if more messages available
-		return d.inputPort.Peek() != nil
+	if cfg.Scenario == "buffer-admission" {
+		results, err := demo.RunBufferAdmission(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		demo.PrintBufferAdmission(out, results)
+		return
 	}
-	
-	// Validate that RemotePort is set
-	if demoMsg.RemotePort == nil {
-		fmt.Printf("[%.2f] Distributor: RemotePort not set for message to %s\n", now, demoMsg.Destination)
-		d.inputPort.Retrieve(now)
-		// Invalid message, continue ticking if more messages available
-		return d.inputPort.Peek() != nil
+	if cfg.Scenario == "seed-sweep" {
+		results, err := demo.RunSeedSweep(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		collisions := demo.FindSeedCollisions(results)
+		demo.PrintSeedSweep(out, results, collisions)
+		if len(collisions) > 0 {
+			exit(out, 1)
+		}
+		return
 	}
-	
-	// Forward the message using the RemotePort (final destination)
-	// No need to look up consumer port - it's already in the message
-	newMsg := &DemoMessage{
-		Content:     demoMsg.Content,
-		Destination: demoMsg.Destination,
-		// RemotePort is not needed in forwarded message - it's only used for routing
+	if cfg.Scenario == "engine-check" {
+		runs, err := demo.RunEngineCheck(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		if !demo.PrintEngineCheck(out, runs) {
+			exit(out, 1)
+		}
+		return
 	}
-	newMsg.Meta().Src = outputPort
-	newMsg.Meta().Dst = demoMsg.RemotePort // Use the remote port from the message
-	newMsg.Meta().SendTime = now
-	
-	err := outputPort.Send(newMsg)
-	if err == nil {
-		d.inputPort.Retrieve(now)
-		fmt.Printf("[%.2f] Distributor: Routed message to %s\n", now, demoMsg.Destination)
-		// Successfully sent message, continue ticking if more messages available
-		return d.inputPort.Peek() != nil
+	if cfg.Scenario == "capacity-sweep" {
+		results, err := demo.RunCapacitySweep(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		demo.PrintCapacitySweep(out, results)
+		return
 	}
-	
-	// Failed to send message (output port full), return false to stop ticking
-	// Will be woken up when the port becomes free
-	return false
-}
-
-// Consumer consumes messages at a fixed rate
-type Consumer struct {
-	*sim.TickingComponent
-	inputPort     sim.Port
-	name          string
-	lastConsumed  sim.VTimeInSec
-	consumeRate   sim.VTimeInSec // Time between consuming messages
-}
-
-// NewConsumer creates a new consumer component
-func NewConsumer(name string, engine sim.Engine, consumeRate sim.VTimeInSec) *Consumer {
-	c := &Consumer{
-		name:          name,
-		consumeRate:   consumeRate,
-		lastConsumed:  -1000, // Start with a large negative value
+	if cfg.Scenario == "param-sweep" {
+		results, err := demo.RunParamSweep(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		demo.PrintParamSweep(out, results)
+		if cfg.SweepCSV != "" {
+			if err := demo.ExportParamSweep(cfg.SweepCSV, results); err != nil {
+				fatalf(out, "Error: %v", err)
+			}
+			out.Printf("Results matrix written to %s\n", cfg.SweepCSV)
+		}
+		return
 	}
-	c.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, c)
-	c.inputPort = sim.NewLimitNumMsgPort(c, 10, name+".In")
-	return c
-}
-
-// Tick processes messages at a fixed rate
-func (c *Consumer) Tick(now sim.VTimeInSec) bool {
-	// Check if enough time has passed since last consumption
-	if now-c.lastConsumed < c.consumeRate {
-		// Not ready to consume yet, return false to stop ticking
-		return false
+	if cfg.Scenario == "topology-fuzz" {
+		results, err := demo.RunTopologyFuzz(cfg)
+		if err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		if !demo.PrintTopologyFuzz(out, results) {
+			exit(out, 1)
+		}
+		return
 	}
 	
-	msg := c.inputPort.Peek()
-	if msg == nil {
-		// No messages available, return false to stop ticking
-		return false
+	// Build the components and connections of the run
+	simulation, err := demo.NewSimulation(cfg)
+	if err != nil {
+		fatalf(out, "Error: %v", err)
 	}
 	
-	demoMsg, ok := msg.(*DemoMessage)
-	if !ok {
-		c.inputPort.Retrieve(now)
-		// Invalid message consumed, continue ticking if more messages available
-		return c.inputPort.Peek() != nil
+	// A dry run checks the model instead of running it
+	if cfg.DryRun {
+		if !simulation.DryRun() {
+			exit(out, 1)
+		}
+		return
 	}
 	
-	c.inputPort.Retrieve(now)
-	c.lastConsumed = now
-	fmt.Printf("[%.2f] Consumer %s: Consumed message: %s\n", now, c.name, demoMsg.Content)
-	
-	// Message consumed, continue ticking if more messages available
-	return c.inputPort.Peek() != nil
-}
-
-func main() {
-	// Parse command-line flags
-	cycles := flag.Int("cycles", 20, "Number of simulation cycles (seconds) to run")
-	flag.Parse()
-	
-	// Validate cycles value
-	if *cycles <= 0 {
-		log.Fatal("Error: cycles must be a positive number")
+	// Write the wiring before running, so that it can be checked even if the
+	// run fails
+	if cfg.DotFile != "" {
+		if err := simulation.Topology().ExportDOT(cfg.DotFile); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		out.Printf("Topology written to %s\n", cfg.DotFile)
 	}
 	
-	// Create simulation engine
-	engine := sim.NewSerialEngine()
-	
-	// Define consumers
-	consumerNames := []string{"Consumer1", "Consumer2", "Consumer3"}
-	
-	// Create components with configurable stop time
-	producer := NewProducer("Producer", engine, consumerNames, sim.VTimeInSec(*cycles))
-	distributor := NewDistributor("Distributor", engine, consumerNames)
-	
-	// Create consumers with fixed consumption rate (1 message per second)
-	consumers := make([]*Consumer, len(consumerNames))
-	for i, name := range consumerNames {
-		consumers[i] = NewConsumer(name, engine, 1.0) // 1 second between messages
+	// Serve the monitoring UI before the run starts
+	if cfg.Monitor != "" {
+		if err := simulation.StartMonitor(cfg.Monitor); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
 	}
-	
-	// Register consumer ports with producer (remote ports)
-	for i, consumer := range consumers {
-		producer.consumerPorts[consumerNames[i]] = consumer.inputPort
+	if cfg.Metrics != "" {
+		if err := simulation.ServeMetrics(cfg.Metrics); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		out.Printf("Metrics served at %s\n", demo.ServerURL(cfg.Metrics)+"/metrics")
 	}
-	
-	// Set producer's destination to distributor's input port (immediate hop)
-	producer.dstPort = distributor.inputPort
-	
-	// Connect producer to distributor
-	conn := sim.NewDirectConnection("ProducerToDistributor", engine, 1*sim.Hz)
-	conn.PlugIn(producer.outputPort, 1)
-	conn.PlugIn(distributor.inputPort, 1)
-	
-	// Connect distributor to consumers
-	for i, consumer := range consumers {
-		conn := sim.NewDirectConnection(
-			fmt.Sprintf("DistributorTo%s", consumerNames[i]),
-			engine,
-			1*sim.Hz,
-		)
-		conn.PlugIn(distributor.outputPorts[consumerNames[i]], 1)
-		conn.PlugIn(consumer.inputPort, 1)
+	if cfg.Control != "" {
+		if err := simulation.StartControl(cfg.Control, cfg.ControlPaused); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+		out.Printf("Control API served at %s\n", demo.ServerURL(cfg.Control))
 	}
 	
-	// Kick off the ticking components
-	// Only the producer starts ticking at time 0
-	// Distributor and consumers will be woken up by message arrivals
-	producer.TickNow(0)
-	
 	// Run simulation
-	fmt.Println("=== Starting Akita Demo Simulation ===")
-	fmt.Printf("Simulation Duration: %d cycles (seconds)\n", *cycles)
-	fmt.Println("Producer: Randomly generates messages (30% chance per tick)")
-	fmt.Println("Distributor: Routes messages to correct consumer")
-	fmt.Println("Consumers: Process messages at fixed rate (1 per second)")
-	fmt.Println()
-	
-	err := engine.Run()
+	if cfg.Checkpoint != "" {
+		simulation.SaveCheckpoint(cfg.Checkpoint, sim.VTimeInSec(cfg.CheckpointAt))
+	}
+	simulation.PrintSetup()
+	if replayed != nil {
+		if err := simulation.Restore(replayed); err != nil {
+			fatalf(out, "Error: %v", err)
+		}
+	}
+	if cfg.Interactive {
+		err = simulation.RunInteractive(os.Stdin, os.Stdout)
+	} else if len(cfg.Segments) > 0 {
+		err = simulation.RunSegments(cfg.Segments)
+	} else {
+		err = simulation.Run()
+	}
+	if err == demo.ErrQuit {
+		return
+	}
 	if err != nil {
-		log.Fatal(err)
+		fatalf(out, "%v", err)
 	}
 	
-	fmt.Println("\n=== Simulation Complete ===")
+	out.Println("\n=== Simulation Complete ===")
+	if err := simulation.PrintReport(); err != nil {
+		fatalf(out, "Error: %v", err)
+	}
+	
+	// Compare against the declared budgets and check the delivery of the
+	// messages
+	if simulation.ReportFailures() {
+		exit(out, 1)
+	}
 }
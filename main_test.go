@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/consumer"
+	"github.com/syifan/akita_demo/distributor"
+	"github.com/syifan/akita_demo/msg"
 )
 
 // TestDistributorReturnsFalseWhenNoMessages verifies that the distributor
@@ -11,10 +14,10 @@ import (
 func TestDistributorReturnsFalseWhenNoMessages(t *testing.T) {
 	engine := sim.NewSerialEngine()
 	consumerNames := []string{"Consumer1"}
-	distributor := NewDistributor("Distributor", engine, consumerNames)
+	d := distributor.New("Distributor", engine, consumerNames)
 	
 	// Tick with no messages
-	result := distributor.Tick(0)
+	result := d.Tick(0)
 	
 	if result != false {
 		t.Errorf("Expected Distributor.Tick() to return false when no messages, got %v", result)
@@ -25,121 +28,160 @@ func TestDistributorReturnsFalseWhenNoMessages(t *testing.T) {
 // returns false when there are no messages to consume
 func TestConsumerReturnsFalseWhenNoMessages(t *testing.T) {
 	engine := sim.NewSerialEngine()
-	consumer := NewConsumer("Consumer1", engine, 1.0)
+	c := consumer.New("Consumer1", engine, 1.0)
 	
 	// Tick with no messages
-	result := consumer.Tick(0)
+	result := c.Tick(0)
 	
 	if result != false {
 		t.Errorf("Expected Consumer.Tick() to return false when no messages, got %v", result)
 	}
 }
 
-// TestConsumerReturnsFalseWhenRateLimiting verifies that the consumer
-// returns false when rate limiting prevents consumption
-func TestConsumerReturnsFalseWhenRateLimiting(t *testing.T) {
-	engine := sim.NewSerialEngine()
-	consumer := NewConsumer("Consumer1", engine, 1.0)
-	
-	// Simulate that a message was just consumed
-	consumer.lastConsumed = 0
-	
-	// Create and send a message to the consumer
-	msg := &DemoMessage{
-		Content:     "Test message",
-		Destination: "Consumer1",
-	}
-	msg.Meta().Src = nil
-	msg.Meta().Dst = consumer.inputPort
-	
-	// Send the message
-	consumer.inputPort.Recv(msg)
-	
-	// Try to tick before consume rate has passed (at time 0.5)
-	result := consumer.Tick(0.5)
-	
-	if result != false {
-		t.Errorf("Expected Consumer.Tick() to return false when rate limiting, got %v", result)
-	}
-}
-
 // TestDistributorReturnsFalseWhenSendFails verifies that the distributor
 // returns false when it fails to send a message (output port full)
 func TestDistributorReturnsFalseWhenSendFails(t *testing.T) {
 	engine := sim.NewSerialEngine()
 	consumerNames := []string{"Consumer1"}
-	distributor := NewDistributor("Distributor", engine, consumerNames)
-	consumer := NewConsumer("Consumer1", engine, 1.0)
+	d := distributor.New("Distributor", engine, consumerNames)
+	c := consumer.New("Consumer1", engine, 1.0)
 	
 	// Connect distributor output to consumer input
 	conn := sim.NewDirectConnection("TestConnection", engine, 1*sim.Hz)
-	conn.PlugIn(distributor.outputPorts["Consumer1"], 1)
-	conn.PlugIn(consumer.inputPort, 1)
+	conn.PlugIn(d.OutputPort("Consumer1"), 1)
+	conn.PlugIn(c.InputPort(), 1)
+	d.Routes().Add("Consumer1", c.InputPort())
 	
 	// Fill up the distributor's output port (capacity is 1)
-	fillMsg := &DemoMessage{
+	fillMsg := &msg.DemoMessage{
 		Content:     "Fill message",
 		Destination: "Consumer1",
-		RemotePort:  consumer.inputPort,
 	}
-	fillMsg.Meta().Src = distributor.outputPorts["Consumer1"]
-	fillMsg.Meta().Dst = consumer.inputPort
-	distributor.outputPorts["Consumer1"].Send(fillMsg)
+	fillMsg.Meta().Src = d.OutputPort("Consumer1")
+	fillMsg.Meta().Dst = c.InputPort()
+	d.OutputPort("Consumer1").Send(fillMsg)
 	
-	// Now send a message to distributor's input with final destination set
-	msg := &DemoMessage{
+	// Now send a message to distributor's input
+	m := &msg.DemoMessage{
 		Content:     "Test message",
 		Destination: "Consumer1",
-		RemotePort:  consumer.inputPort, // Set remote port
 	}
-	msg.Meta().Src = nil
-	msg.Meta().Dst = distributor.inputPort
-	distributor.inputPort.Recv(msg)
+	m.Meta().Src = nil
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
 	
 	// Try to tick - should fail because output port is full
-	result := distributor.Tick(0)
+	result := d.Tick(0)
 	
 	if result != false {
 		t.Errorf("Expected Distributor.Tick() to return false when send fails, got %v", result)
 	}
 }
 
+// recvRecorder records the messages received at a port
+type recvRecorder struct {
+	msgs []sim.Msg
+}
+
+func (r *recvRecorder) Func(ctx sim.HookCtx) {
+	if ctx.Pos == sim.HookPosPortMsgRecvd {
+		r.msgs = append(r.msgs, ctx.Item.(sim.Msg))
+	}
+}
+
+// TestDistributorForwardsMessageInPlace verifies that the consumer receives
+// the message the distributor received, updated for the hop, and that a
+// failed send leaves the message as it was received
+func TestDistributorForwardsMessageInPlace(t *testing.T) {
+	// The consumer releases the message, which must stay readable here
+	msg.Pooling = false
+	defer func() { msg.Pooling = true }()
+	
+	engine := sim.NewSerialEngine()
+	d := distributor.New("Distributor", engine, []string{"Consumer1"})
+	c := consumer.New("Consumer1", engine, 1.0)
+	outputPort := d.OutputPort("Consumer1")
+	conn := sim.NewDirectConnection("TestConnection", engine, 1*sim.Hz)
+	conn.PlugIn(outputPort, 1)
+	conn.PlugIn(c.InputPort(), 1)
+	d.Routes().Add("Consumer1", c.InputPort())
+	recorder := &recvRecorder{}
+	c.InputPort().AcceptHook(recorder)
+	
+	fillMsg := &msg.DemoMessage{Destination: "Consumer1"}
+	fillMsg.Meta().Src = outputPort
+	fillMsg.Meta().Dst = c.InputPort()
+	outputPort.Send(fillMsg)
+	
+	m := &msg.DemoMessage{ID: 1, Destination: "Consumer1"}
+	m.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(m)
+	
+	d.Tick(0)
+	if m.Meta().Src != nil || m.Meta().Dst != d.InputPort() {
+		t.Fatalf("Expected the metadata as received after a failed send, got %v", m.Meta())
+	}
+	
+	// The freed output port wakes the distributor up
+	engine.Run()
+	if len(recorder.msgs) != 2 || recorder.msgs[1] != m {
+		t.Fatalf("Expected the consumer to receive the message itself, got %v", recorder.msgs)
+	}
+	if m.Meta().Src != outputPort || m.Meta().Dst != c.InputPort() {
+		t.Errorf("Expected the metadata of the hop, got %v", m.Meta())
+	}
+}
+
 // TestDistributorContinuesTickingWhenMoreMessages verifies that the distributor
 // returns true when it successfully processes a message and more are available
 func TestDistributorContinuesTickingWhenMoreMessages(t *testing.T) {
 	engine := sim.NewSerialEngine()
 	consumerNames := []string{"Consumer1"}
-	distributor := NewDistributor("Distributor", engine, consumerNames)
-	consumer := NewConsumer("Consumer1", engine, 1.0)
+	d := distributor.New("Distributor", engine, consumerNames)
+	c := consumer.New("Consumer1", engine, 1.0)
 	
 	// Connect distributor output to consumer input
 	conn := sim.NewDirectConnection("TestConnection", engine, 1*sim.Hz)
-	conn.PlugIn(distributor.outputPorts["Consumer1"], 1)
-	conn.PlugIn(consumer.inputPort, 1)
+	conn.PlugIn(d.OutputPort("Consumer1"), 1)
+	conn.PlugIn(c.InputPort(), 1)
+	d.Routes().Add("Consumer1", c.InputPort())
 	
-	// Send two messages to distributor's input with final destination set
-	msg1 := &DemoMessage{
+	// Send two messages to distributor's input
+	msg1 := &msg.DemoMessage{
 		Content:     "Test message 1",
 		Destination: "Consumer1",
-		RemotePort:  consumer.inputPort, // Set remote port
 	}
 	msg1.Meta().Src = nil
-	msg1.Meta().Dst = distributor.inputPort
-	distributor.inputPort.Recv(msg1)
+	msg1.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(msg1)
 	
-	msg2 := &DemoMessage{
+	msg2 := &msg.DemoMessage{
 		Content:     "Test message 2",
 		Destination: "Consumer1",
-		RemotePort:  consumer.inputPort, // Set remote port
 	}
 	msg2.Meta().Src = nil
-	msg2.Meta().Dst = distributor.inputPort
-	distributor.inputPort.Recv(msg2)
+	msg2.Meta().Dst = d.InputPort()
+	d.InputPort().Recv(msg2)
 	
 	// Tick - should return true because more messages are available
-	result := distributor.Tick(0)
+	result := d.Tick(0)
 	
 	if result != true {
 		t.Errorf("Expected Distributor.Tick() to return true when more messages available, got %v", result)
 	}
 }
+
+// TestPollingConsumerKeepsTicking verifies that a polling consumer keeps
+// ticking with empty queues until the end of the run
+func TestPollingConsumerKeepsTicking(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	c := consumer.New("Consumer1", engine, 1.0, consumer.WithPolling(10))
+	
+	if result := c.Tick(0); result != true {
+		t.Errorf("Expected polling Consumer.Tick() to return true before the end of the run, got %v", result)
+	}
+	
+	if result := c.Tick(10); result != false {
+		t.Errorf("Expected polling Consumer.Tick() to return false after the end of the run, got %v", result)
+	}
+}
@@ -0,0 +1,177 @@
+// Package middleware lets experiments intercept the messages of the demo as
+// they are produced, routed, and consumed, and change, hold, duplicate, or
+// drop them without changing the components.
+package middleware
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Stage is a point of the pipeline where middlewares intercept messages
+type Stage int
+
+// Stages a message passes, in order
+const (
+	StageProduce Stage = iota // The producer generated it and is about to send it
+	StageRoute                // The distributor is about to route it
+	StageConsume              // The consumer is about to serve it
+	NumStages
+)
+
+func (s Stage) String() string {
+	return [...]string{"produce", "route", "consume"}[s]
+}
+
+// Interception is a message a middleware intercepted and what it decided for
+// it. A middleware may change the message in place, and sets the fields
+// below to drop, hold, or duplicate it; the middlewares after it see the
+// decision so far.
+type Interception struct {
+	Now       sim.VTimeInSec
+	Stage     Stage
+	Component string
+	Msg       *msg.DemoMessage
+
+	Drop   bool           // Take the message out of the pipeline
+	Delay  sim.VTimeInSec // Hold the message, and the ones behind it, this long
+	Copies int            // Extra copies to send along, not made when consuming
+
+	id uint64 // ID of the message once intercepted
+}
+
+// ReadyAt returns the time the message may go on
+func (i *Interception) ReadyAt() sim.VTimeInSec {
+	return i.Now + i.Delay
+}
+
+// Holds reports whether the interception is about the given message. The
+// messages are pooled, so a message is told apart by its ID as well.
+func (i *Interception) Holds(m *msg.DemoMessage) bool {
+	return i != nil && i.Msg == m && i.id == m.ID
+}
+
+// Middleware intercepts every message as it is produced, routed, and
+// consumed
+type Middleware interface {
+	OnProduce(i *Interception)
+	OnRoute(i *Interception)
+	OnConsume(i *Interception)
+}
+
+// Funcs is a middleware made of functions, nil ones leave the message alone
+type Funcs struct {
+	Produce func(i *Interception)
+	Route   func(i *Interception)
+	Consume func(i *Interception)
+}
+
+// OnProduce calls Produce
+func (m Funcs) OnProduce(i *Interception) {
+	if m.Produce != nil {
+		m.Produce(i)
+	}
+}
+
+// OnRoute calls Route
+func (m Funcs) OnRoute(i *Interception) {
+	if m.Route != nil {
+		m.Route(i)
+	}
+}
+
+// OnConsume calls Consume
+func (m Funcs) OnConsume(i *Interception) {
+	if m.Consume != nil {
+		m.Consume(i)
+	}
+}
+
+// Chain runs the middlewares in the order they were added and counts their
+// decisions per stage. Its counting methods are safe to call on a nil chain.
+type Chain struct {
+	middlewares []Middleware
+
+	Seen       [NumStages]int // Messages intercepted
+	Dropped    [NumStages]int // Messages dropped
+	Delayed    [NumStages]int // Messages held
+	Duplicated [NumStages]int // Copies sent
+}
+
+// Use appends a middleware to the chain
+func (c *Chain) Use(m Middleware) {
+	c.middlewares = append(c.middlewares, m)
+}
+
+// Len returns the number of middlewares in the chain
+func (c *Chain) Len() int {
+	return len(c.middlewares)
+}
+
+// Active reports whether the chain has a middleware to pass messages
+// through, which a nil chain has not
+func (c *Chain) Active() bool {
+	return c != nil && len(c.middlewares) > 0
+}
+
+// Intercept passes a message through the middlewares until one drops it
+func (c *Chain) Intercept(now sim.VTimeInSec, stage Stage, component string, m *msg.DemoMessage) *Interception {
+	i := &Interception{Now: now, Stage: stage, Component: component, Msg: m}
+	for _, mw := range c.middlewares {
+		switch stage {
+		case StageProduce:
+			mw.OnProduce(i)
+		case StageRoute:
+			mw.OnRoute(i)
+		case StageConsume:
+			mw.OnConsume(i)
+		}
+		if i.Drop {
+			break
+		}
+	}
+	i.id = m.ID
+	if i.Delay < 0 {
+		i.Delay = 0
+	}
+	if stage == StageConsume || i.Copies < 0 {
+		i.Copies = 0
+	}
+
+	c.Seen[stage]++
+	if i.Drop {
+		c.Dropped[stage]++
+	} else if i.Delay > 0 {
+		c.Delayed[stage]++
+	}
+	return i
+}
+
+// Copied counts a copy sent at the stage
+func (c *Chain) Copied(stage Stage) {
+	c.Duplicated[stage]++
+}
+
+// TotalDropped returns the messages the middlewares dropped
+func (c *Chain) TotalDropped() int {
+	if c == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range c.Dropped {
+		total += n
+	}
+	return total
+}
+
+// TotalCopies returns the copies sent for the middlewares
+func (c *Chain) TotalCopies() int {
+	if c == nil {
+		return 0
+	}
+	total := 0
+	for _, n := range c.Duplicated {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,117 @@
+package msg
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// RegisterMsg is sent by a consumer to the distributor to announce the name
+// it serves and the ports that receive its messages
+type RegisterMsg struct {
+	meta  sim.MsgMeta
+	Name  string
+	Ports []sim.Port
+}
+
+// Meta returns the message metadata
+func (m *RegisterMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// DiscoverReq is sent by a producer to ask the distributor which
+// destinations it serves
+type DiscoverReq struct {
+	meta sim.MsgMeta
+}
+
+// Meta returns the message metadata
+func (m *DiscoverReq) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// DiscoverRsp answers a DiscoverReq with the destination names served by the
+// distributor
+type DiscoverRsp struct {
+	meta  sim.MsgMeta
+	Names []string
+}
+
+// Meta returns the message metadata
+func (m *DiscoverRsp) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// AckMsg is sent by a consumer to the producer once a message is consumed
+type AckMsg struct {
+	meta     sim.MsgMeta
+	MsgID    uint64 // ID of the consumed DemoMessage
+	Consumer string // Name of the consumer that consumed it
+}
+
+// Meta returns the message metadata
+func (m *AckMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// HeartbeatMsg tells the distributor over the control plane that a consumer
+// is up
+type HeartbeatMsg struct {
+	meta     sim.MsgMeta
+	Consumer string
+}
+
+// Meta returns the message metadata
+func (m *HeartbeatMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// LeaveGroupMsg is sent by a consumer to the distributor to leave its
+// consumer group
+type LeaveGroupMsg struct {
+	meta     sim.MsgMeta
+	Consumer string
+}
+
+// Meta returns the message metadata
+func (m *LeaveGroupMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// MembershipMsg is sent by a consumer to the distributor to join a
+// multicast group or to leave it
+type MembershipMsg struct {
+	meta     sim.MsgMeta
+	Consumer string
+	Group    string
+	Leave    bool
+}
+
+// Meta returns the message metadata
+func (m *MembershipMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// SubscribeMsg is sent by a consumer to the distributor to subscribe to the
+// topics that match a pattern, or to unsubscribe from them
+type SubscribeMsg struct {
+	meta        sim.MsgMeta
+	Consumer    string
+	Pattern     string
+	Unsubscribe bool
+}
+
+// Meta returns the message metadata
+func (m *SubscribeMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// PullMsg is sent by a consumer to the work pool to ask for its next
+// message
+type PullMsg struct {
+	meta     sim.MsgMeta
+	Consumer string
+}
+
+// Meta returns the message metadata
+func (m *PullMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
@@ -0,0 +1,28 @@
+package msg
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// DeadLetterReason explains why a message could not be delivered
+type DeadLetterReason string
+
+// Reasons for dead-lettering a message at the distributor
+const (
+	ReasonInvalidType        DeadLetterReason = "invalid message type"
+	ReasonUnknownDestination DeadLetterReason = "unknown destination"
+	ReasonNoRoute            DeadLetterReason = "no registered consumer"
+	ReasonNoSubscriber       DeadLetterReason = "no subscriber"
+)
+
+// DeadLetterMsg carries an undeliverable message to the dead-letter sink
+type DeadLetterMsg struct {
+	meta   sim.MsgMeta
+	Msg    sim.Msg
+	Reason DeadLetterReason
+}
+
+// Meta returns the message metadata
+func (m *DeadLetterMsg) Meta() *sim.MsgMeta {
+	return &m.meta
+}
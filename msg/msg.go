@@ -0,0 +1,134 @@
+// Package msg defines the messages the producers, the distributor, and the
+// consumers of the demo exchange, so that other Akita simulations can send
+// and receive them
+package msg
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// DemoMessage represents a message with a destination consumer
+type DemoMessage struct {
+	meta           sim.MsgMeta
+	ID             uint64 // Unique ID assigned by the producer, kept across hops
+	Source         string // Name of the producer that generated the message
+	Content        string
+	Destination    string
+	Size           int            // Payload size in bytes
+	ServiceScale   float64        // Multiple of the consumer's interval it takes to serve, 0 for one interval
+	CreateTime     sim.VTimeInSec // Time the producer generated the message
+	RawCreateTime  sim.VTimeInSec // CreateTime by the producer's clock, before the ingress corrected it
+	FlowID         int            // Flow the message belongs to, used for RX queue steering
+	TTL            sim.VTimeInSec // Lifetime after CreateTime, 0 never expires
+	SeqNum         uint64         // Per-destination sequence number assigned by the producer
+	Priority       int            // Higher values are more urgent
+	OverflowFrom   string         // Consumer the message was addressed to if it overflowed, else empty
+	RedirectedFrom string         // Removed consumer the message was addressed to, else empty
+	FailoverFrom   string         // Failed consumer the message was addressed to, else empty
+	Duplicate      bool           // Copy a middleware sent along with the message
+	Held           sim.VTimeInSec // Time the middlewares held the message
+	Retransmits    int            // Times the producer sent the message again before this copy
+	Group          string         // Group the message was multicast to, "all" for all consumers, else empty
+	ConsumerGroup  string         // Consumer group the message was addressed to, else empty
+	RuleFrom       string         // Consumer the message was addressed to if a routing rule sent it elsewhere, else empty
+	Rule           int            // Routing rule that picked the destination, counted from 1, 0 if none
+	ingressed      bool           // Whether the ingress corrected CreateTime already
+}
+
+// Option sets a field of a message created with New
+type Option func(m *DemoMessage)
+
+// WithID sets the unique ID of the message
+func WithID(id uint64) Option {
+	return func(m *DemoMessage) { m.ID = id }
+}
+
+// WithSource sets the name of the producer that generated the message
+func WithSource(source string) Option {
+	return func(m *DemoMessage) { m.Source = source }
+}
+
+// WithDestination sets the consumer the message is addressed to
+func WithDestination(destination string) Option {
+	return func(m *DemoMessage) { m.Destination = destination }
+}
+
+// WithContent sets the content of the message
+func WithContent(content string) Option {
+	return func(m *DemoMessage) { m.Content = content }
+}
+
+// WithSize sets the payload size in bytes
+func WithSize(size int) Option {
+	return func(m *DemoMessage) { m.Size = size }
+}
+
+// WithPriority sets the priority, higher values are more urgent
+func WithPriority(priority int) Option {
+	return func(m *DemoMessage) { m.Priority = priority }
+}
+
+// WithTTL sets the lifetime of the message after its creation
+func WithTTL(ttl sim.VTimeInSec) Option {
+	return func(m *DemoMessage) { m.TTL = ttl }
+}
+
+// CreatedAt sets the time the message was generated
+func CreatedAt(now sim.VTimeInSec) Option {
+	return func(m *DemoMessage) { m.CreateTime = now }
+}
+
+// Meta returns the message metadata
+func (m *DemoMessage) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// Addressee returns the consumer the message was addressed to before any
+// routing rule, redirection, failover, or overflow rerouting, whose sequence
+// it is numbered in. Copies of a multicast message are numbered in the
+// sequence of their group, which every member follows on its own.
+func (m *DemoMessage) Addressee() string {
+	if m.Group != "" {
+		return m.Group + "@" + m.Destination
+	}
+	if m.RuleFrom != "" {
+		return m.RuleFrom
+	}
+	if m.RedirectedFrom != "" {
+		return m.RedirectedFrom
+	}
+	if m.FailoverFrom != "" {
+		return m.FailoverFrom
+	}
+	if m.OverflowFrom != "" {
+		return m.OverflowFrom
+	}
+	return m.Destination
+}
+
+// Clone creates a copy of the message that has not been sent yet. The
+// distributor forwards messages as they are and only clones one to change
+// its destination.
+func (m *DemoMessage) Clone() *DemoMessage {
+	clone := New()
+	*clone = *m
+	clone.meta = sim.MsgMeta{}
+	return clone
+}
+
+// Ingressed reports whether the ingress of the distributor corrected
+// CreateTime already
+func (m *DemoMessage) Ingressed() bool {
+	return m.ingressed
+}
+
+// MarkIngressed records that the ingress corrected CreateTime, so that it is
+// not corrected again
+func (m *DemoMessage) MarkIngressed() {
+	m.ingressed = true
+}
+
+// Expired reports whether the message's TTL ran out before now
+func (m *DemoMessage) Expired(now sim.VTimeInSec) bool {
+	return m.TTL > 0 && now-m.CreateTime > m.TTL
+}
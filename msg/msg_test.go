@@ -0,0 +1,42 @@
+package msg
+
+import (
+	"testing"
+)
+
+// TestReleaseRecyclesZeroedMessages verifies that a released message comes
+// back from the allocator without any of its old fields
+func TestReleaseRecyclesZeroedMessages(t *testing.T) {
+	m := New()
+	m.ID = 7
+	m.Content = "hello"
+	m.MarkIngressed()
+	m.Release()
+	
+	for i := 0; i < 10; i++ {
+		reused := New()
+		if reused.ID != 0 || reused.Content != "" || reused.Ingressed() {
+			t.Fatalf("Expected a zeroed message, got %+v", reused)
+		}
+	}
+}
+
+// TestNewAppliesOptions verifies that a new message carries the fields its
+// options set, and that a clone keeps them but not the metadata
+func TestNewAppliesOptions(t *testing.T) {
+	m := New(WithID(3), WithSource("Producer"), WithDestination("Consumer2"),
+		WithContent("hello"), WithSize(64), WithPriority(2), WithTTL(5), CreatedAt(1.5))
+	m.Meta().ID = "sent"
+	
+	if m.ID != 3 || m.Source != "Producer" || m.Destination != "Consumer2" || m.Content != "hello" ||
+		m.Size != 64 || m.Priority != 2 || m.TTL != 5 || m.CreateTime != 1.5 {
+		t.Fatalf("Expected the fields of the options, got %+v", m)
+	}
+	if m.Expired(6) || !m.Expired(7) {
+		t.Errorf("Expected the message to expire 5 s after 1.5 s")
+	}
+	clone := m.Clone()
+	if clone.ID != 3 || clone.Destination != "Consumer2" || clone.Meta().ID != "" {
+		t.Errorf("Expected a clone with the fields and fresh metadata, got %+v", clone)
+	}
+}
@@ -0,0 +1,31 @@
+package msg
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// StealReq is sent by an idle consumer to a peer to ask for part of its
+// backlog
+type StealReq struct {
+	meta  sim.MsgMeta
+	Thief string
+	Room  int // Messages the thief can queue
+}
+
+// Meta returns the message metadata
+func (m *StealReq) Meta() *sim.MsgMeta {
+	return &m.meta
+}
+
+// StealRsp answers a steal request with the messages the victim gave away,
+// none if it refused
+type StealRsp struct {
+	meta   sim.MsgMeta
+	Victim string
+	Msgs   []*DemoMessage
+}
+
+// Meta returns the message metadata
+func (m *StealRsp) Meta() *sim.MsgMeta {
+	return &m.meta
+}
@@ -0,0 +1,74 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// OutputPort returns the port the producer sends its messages from
+func (p *Producer) OutputPort() sim.Port {
+	return p.outputPort
+}
+
+// CtrlPort returns the port the producer discovers the consumers and
+// receives the ACKs on
+func (p *Producer) CtrlPort() sim.Port {
+	return p.ctrlPort
+}
+
+// Seed returns the seed of the random source of the producer
+func (p *Producer) Seed() int64 {
+	return p.seed
+}
+
+// Traffic returns the traffic model that decides when a message is
+// generated
+func (p *Producer) Traffic() Traffic {
+	return p.traffic
+}
+
+// SetTraffic replaces the traffic model during the run
+func (p *Producer) SetTraffic(traffic Traffic) {
+	p.traffic = traffic
+}
+
+// DestinationPolicy returns the policy that picks the consumer of a
+// generated message
+func (p *Producer) DestinationPolicy() DestinationPolicy {
+	return p.destPolicy
+}
+
+// SetDestinationPolicy replaces the destination policy during the run
+func (p *Producer) SetDestinationPolicy(policy DestinationPolicy) {
+	p.destPolicy = policy
+}
+
+// Discovered reports whether the producer learned the consumers from the
+// distributor
+func (p *Producer) Discovered() bool {
+	return p.discovered
+}
+
+// Consumers returns the consumers the producer sends to
+func (p *Producer) Consumers() []string {
+	return p.consumers
+}
+
+// Sent returns the number of messages the producer generated
+func (p *Producer) Sent() uint64 {
+	return p.nextID
+}
+
+// SeqNum returns the sequence number of the last message sent to dest
+func (p *Producer) SeqNum(dest string) uint64 {
+	return p.seqNums[dest]
+}
+
+// Unacked returns the number of messages sent and not acknowledged yet
+func (p *Producer) Unacked() int {
+	return len(p.outstanding)
+}
+
+// Window returns the sliding window of the producer, nil if it has none
+func (p *Producer) Window() *Window {
+	return p.window
+}
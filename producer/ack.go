@@ -0,0 +1,73 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// handleAcks retires the outstanding messages acknowledged by consumers and
+// records their round-trip times. Consumers the distributor announces after
+// the discovery are added on the way. It returns true if any ACK was
+// processed.
+func (p *Producer) handleAcks(now sim.VTimeInSec) bool {
+	madeProgress := false
+
+	for {
+		if rsp, ok := p.ctrlPort.Peek().(*msg.DiscoverRsp); ok && p.discovered {
+			p.ctrlPort.Retrieve(now)
+			p.consumers = rsp.Names
+			p.log.Printf("[%.2f] Producer: Discovered consumers %v\n", now, rsp.Names)
+			continue
+		}
+
+		ack, ok := p.ctrlPort.Peek().(*msg.AckMsg)
+		if !ok {
+			return madeProgress
+		}
+		p.ctrlPort.Retrieve(now)
+		madeProgress = true
+
+		sendTime, ok := p.outstanding[ack.MsgID]
+		if !ok {
+			continue
+		}
+		delete(p.outstanding, ack.MsgID)
+		if p.retransmitter != nil {
+			p.retransmitter.Acked(ack.MsgID)
+		}
+		component.EndTask(p, "generate", ack.MsgID)
+		if p.stats != nil {
+			p.stats.RecordAcked(sendTime, now-sendTime)
+		}
+		p.adjustWindow(now, now-sendTime)
+		p.destPolicy.Observe(ack.Consumer, now-sendTime)
+	}
+}
+
+// adjustWindow updates the sliding window with the round-trip time of an
+// acknowledged message
+func (p *Producer) adjustWindow(now, rtt sim.VTimeInSec) {
+	if p.window == nil {
+		return
+	}
+
+	switch delta := p.window.Ack(rtt); {
+	case delta > 0:
+		p.log.Printf("[%.2f] Producer: Window grew to %d\n", now, p.window.Size())
+	case delta < 0:
+		if p.backpressure != nil {
+			p.backpressure.ProducerReacted(now)
+		}
+		p.log.Printf("[%.2f] Producer: Window shrank to %d (RTT %.2f)\n", now, p.window.Size(), rtt)
+	}
+}
+
+// canSend reports whether the in-flight limit and the sliding window allow
+// another message
+func (p *Producer) canSend() bool {
+	if p.maxInFlight > 0 && len(p.outstanding) >= p.maxInFlight {
+		return false
+	}
+	return p.window == nil || len(p.outstanding) < p.window.Size()
+}
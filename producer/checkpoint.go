@@ -0,0 +1,36 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+)
+
+// Checkpoint is the state of a producer saved in checkpoints
+type Checkpoint struct {
+	QuerySent   bool
+	Discovered  bool
+	Consumers   []string
+	NextID      uint64
+	SeqNums     map[string]uint64
+	Outstanding map[uint64]sim.VTimeInSec
+	Window      int
+	Random      *component.CountingSource
+}
+
+// CheckpointState returns the discovery, sequencing, and flow-control state
+// of the producer
+func (p *Producer) CheckpointState() interface{} {
+	state := Checkpoint{
+		QuerySent:   p.querySent,
+		Discovered:  p.discovered,
+		Consumers:   p.consumers,
+		NextID:      p.nextID,
+		SeqNums:     p.seqNums,
+		Outstanding: p.outstanding,
+		Random:      p.source,
+	}
+	if p.window != nil {
+		state.Window = p.window.Size()
+	}
+	return state
+}
@@ -0,0 +1,21 @@
+package producer
+
+import (
+	"math"
+)
+
+// sampleSizeService draws the size of a message and the multiple of the
+// consumer's interval it takes to serve. Both are lognormal with the
+// configured spread, a mean of msgSize bytes and of one interval, and
+// log-values whose correlation is the configured coefficient, so that large
+// messages also take long to serve.
+func (p *Producer) sampleSizeService() (int, float64) {
+	rho := p.sizeCorrelation
+	z1 := p.rand.NormFloat64()
+	z2 := rho*z1 + math.Sqrt(1-rho*rho)*p.rand.NormFloat64()
+
+	s := p.sizeSpread
+	size := float64(p.msgSize) * math.Exp(s*z1-s*s/2)
+	scale := math.Exp(s*z2 - s*s/2)
+	return int(math.Max(1, math.Round(size))), scale
+}
@@ -0,0 +1,51 @@
+package producer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+func mean(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// TestSizeServiceSamplesAreCorrelated verifies that the sizes and service
+// scales keep their means and that their log-values are correlated as
+// configured
+func TestSizeServiceSamplesAreCorrelated(t *testing.T) {
+	for _, rho := range []float64{0, 0.8, -0.8} {
+		p := New("Producer", sim.NewSerialEngine(), 100, WithSeed(1), WithSize(100, 0.5, rho))
+		var sizes, scales, logSizes, logScales []float64
+		for i := 0; i < 20000; i++ {
+			size, scale := p.sampleSizeService()
+			sizes = append(sizes, float64(size))
+			scales = append(scales, scale)
+			logSizes = append(logSizes, math.Log(float64(size)))
+			logScales = append(logScales, math.Log(scale))
+		}
+		
+		if m := mean(sizes); math.Abs(m-100) > 2 {
+			t.Errorf("Expected a mean size of 100 bytes, got %.1f", m)
+		}
+		if m := mean(scales); math.Abs(m-1) > 0.02 {
+			t.Errorf("Expected a mean service scale of 1, got %.3f", m)
+		}
+		mx, my := mean(logSizes), mean(logScales)
+		var sxy, sxx, syy float64
+		for i := range logSizes {
+			dx, dy := logSizes[i]-mx, logScales[i]-my
+			sxy += dx * dy
+			sxx += dx * dx
+			syy += dy * dy
+		}
+		if r := sxy / math.Sqrt(sxx*syy); math.Abs(r-rho) > 0.03 {
+			t.Errorf("Expected a correlation of %.1f, got %.3f", rho, r)
+		}
+	}
+}
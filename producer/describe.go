@@ -0,0 +1,71 @@
+package producer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/syifan/akita_demo/component"
+)
+
+// Describe lists the traffic and the destinations of the producer
+func (p *Producer) Describe() []component.Parameter {
+	destinations := component.Summary(p.destPolicy)
+	if t, ok := p.destPolicy.(Traffic); ok && t == p.traffic {
+		destinations = "as the traffic model"
+	}
+	params := []component.Parameter{
+		{Name: "Frequency", Value: component.FormatFreq(p.Freq)},
+		{Name: "Traffic", Value: component.Summary(p.traffic)},
+		{Name: "Destinations", Value: destinations},
+	}
+	if p.registry != nil {
+		params = append(params, component.Parameter{Name: "Consumers",
+			Value: fmt.Sprintf("discovered from %s at %.2f", p.registry.Component().Name(), float64(p.discoverTime))})
+	} else {
+		params = append(params, component.Parameter{Name: "Consumers", Value: strings.Join(p.consumers, ", ")})
+	}
+	if p.topics != nil {
+		params = append(params, component.Parameter{Name: "Topics", Value: strings.Join(p.topics, ", ")})
+	}
+	if p.multicast > 0 {
+		params = append(params, component.Parameter{Name: "Multicast",
+			Value: fmt.Sprintf("%.0f%% to %s", p.multicast*100, strings.Join(p.groups, ", "))})
+	}
+	if p.window != nil {
+		params = append(params, component.Parameter{Name: "Window",
+			Value: fmt.Sprintf("up to %d messages, target RTT %.2f s", p.window.maxSize, float64(p.window.targetRTT))})
+	} else if p.maxInFlight > 0 {
+		params = append(params, component.Parameter{Name: "In flight", Value: fmt.Sprintf("up to %d messages", p.maxInFlight)})
+	}
+	if p.numFlows > 1 {
+		params = append(params, component.Parameter{Name: "Flows", Value: fmt.Sprint(p.numFlows)})
+	}
+	if p.msgSize > 0 {
+		params = append(params, component.Parameter{Name: "Message size", Value: fmt.Sprintf("%d bytes", p.msgSize)})
+	}
+	if p.ttl > 0 {
+		params = append(params, component.Parameter{Name: "TTL", Value: fmt.Sprintf("%.2f s", float64(p.ttl))})
+	}
+	if p.priorities > 1 {
+		params = append(params, component.Parameter{Name: "Priorities", Value: fmt.Sprint(p.priorities)})
+	}
+	if p.retransmitter != nil {
+		params = append(params, component.Parameter{Name: "Retransmission", Value: component.Summary(p.retransmitter)})
+	}
+	if p.clockSkew != 0 {
+		params = append(params, component.Parameter{Name: "Clock skew", Value: fmt.Sprintf("%+.2f s", float64(p.clockSkew))})
+	}
+	return append(params, component.Parameter{Name: "Stops at", Value: fmt.Sprintf("%.2f", float64(p.stopTime))})
+}
+
+// Describe lists the trace the producer replays
+func (t *TraceProducer) Describe() []component.Parameter {
+	traffic := fmt.Sprintf("trace, %d records scheduled", len(t.records))
+	if t.stream != nil {
+		traffic = fmt.Sprintf("trace, streamed %d records at a time", t.chunk)
+	}
+	return []component.Parameter{
+		{Name: "Frequency", Value: component.FormatFreq(t.Freq)},
+		{Name: "Traffic", Value: traffic},
+	}
+}
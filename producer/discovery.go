@@ -0,0 +1,40 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// discover queries the distributor for the served destinations once the
+// registration period is over, and waits for the answer. It returns true if
+// the producer made progress.
+func (p *Producer) discover(now sim.VTimeInSec) bool {
+	if rsp, ok := p.ctrlPort.Peek().(*msg.DiscoverRsp); ok {
+		p.ctrlPort.Retrieve(now)
+		p.consumers = rsp.Names
+		p.discovered = true
+		p.log.Printf("[%.2f] Producer: Discovered consumers %v\n", now, rsp.Names)
+		return len(p.consumers) > 0
+	}
+
+	if p.querySent {
+		// Wait for the response, the arrival wakes the producer up
+		return false
+	}
+
+	if now < p.discoverTime {
+		p.TickNow(p.discoverTime)
+		return false
+	}
+
+	req := &msg.DiscoverReq{}
+	req.Meta().Src = p.ctrlPort
+	req.Meta().Dst = p.registry
+	req.Meta().SendTime = now
+	if err := p.ctrlPort.Send(req); err != nil {
+		return false
+	}
+
+	p.querySent = true
+	return false
+}
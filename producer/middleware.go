@@ -0,0 +1,67 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// heldSend is a message the producer holds for the middlewares before
+// sending it
+type heldSend struct {
+	msg     *msg.DemoMessage
+	readyAt sim.VTimeInSec
+	copy    bool
+}
+
+// intercept passes a generated message through the middlewares and queues
+// it, with its copies, to be sent once its delay is over
+func (p *Producer) intercept(now sim.VTimeInSec, m *msg.DemoMessage) {
+	i := p.middleware.Intercept(now, middleware.StageProduce, p.Name(), m)
+	if p.stats != nil {
+		p.stats.RecordProduced(now, m)
+	}
+	if i.Drop {
+		p.log.Printf("[%.2f] Producer: Middleware dropped message for %s\n", now, m.Destination)
+		m.Release()
+		return
+	}
+	if i.Delay > 0 {
+		p.log.Printf("[%.2f] Producer: Middleware holds message for %s for %.2f s\n", now, m.Destination, float64(i.Delay))
+		m.Held += i.Delay
+	}
+	p.held = append(p.held, heldSend{msg: m, readyAt: i.ReadyAt()})
+	for n := 0; n < i.Copies; n++ {
+		dup := m.Clone()
+		*dup.Meta() = *m.Meta()
+		dup.Duplicate = true
+		p.held = append(p.held, heldSend{msg: dup, readyAt: i.ReadyAt(), copy: true})
+	}
+	p.sendHeld(now)
+}
+
+// sendHeld sends the held messages whose delay is over, in order. It reports
+// whether none is left.
+func (p *Producer) sendHeld(now sim.VTimeInSec) bool {
+	for len(p.held) > 0 {
+		h := p.held[0]
+		if now < h.readyAt {
+			component.ScheduleWakeup(p.TickingComponent, h.readyAt)
+			return false
+		}
+		h.msg.Meta().SendTime = now
+		if err := p.outputPort.Send(h.msg); err != nil {
+			// Port busy, will be woken up when it becomes free
+			return false
+		}
+		p.held = p.held[1:]
+		if h.copy {
+			p.middleware.Copied(middleware.StageProduce)
+			p.log.Printf("[%.2f] Producer: Sent copy of message %d for %s\n", now, h.msg.ID, h.msg.Destination)
+		} else {
+			p.sent(now, h.msg)
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package producer
+
+import (
+	"math/rand"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Stats counts the messages of the producer
+type Stats interface {
+	RecordProduced(now sim.VTimeInSec, m *msg.DemoMessage)
+	RecordAcked(sendTime, rtt sim.VTimeInSec)
+	RecordInFlightStall()
+	RecordLost()
+	RecordTick(name string, outcome component.TickOutcome)
+}
+
+// Backpressure measures how fast the producer reacts to congestion
+type Backpressure interface {
+	ProducerReacted(now sim.VTimeInSec)
+}
+
+// Auditor checks that every message sent is consumed once
+type Auditor interface {
+	Produced(m *msg.DemoMessage)
+}
+
+// Retransmitter keeps a copy of every message sent until it is
+// acknowledged, to send it again when its ACK is overdue
+type Retransmitter interface {
+	// Sent starts waiting for the ACK of a message sent for the first time,
+	// and returns the time it is overdue
+	Sent(now sim.VTimeInSec, m *msg.DemoMessage) sim.VTimeInSec
+	// Acked stops waiting for the ACK of a message
+	Acked(id uint64)
+	// Overdue returns the copies of the messages of a producer whose ACK is
+	// overdue at now, by ID. Their Retransmits count the retransmissions so
+	// far.
+	Overdue(now sim.VTimeInSec, producer string) []*msg.DemoMessage
+	// Exhausted reports whether an overdue message was sent again as often
+	// as allowed
+	Exhausted(m *msg.DemoMessage) bool
+	// Resent waits for the ACK of a message sent again, and returns the time
+	// it is overdue
+	Resent(now sim.VTimeInSec, id uint64) sim.VTimeInSec
+	// Forget stops waiting for a message, counting it as given up on if the
+	// producer still waited for its ACK
+	Forget(id uint64, gaveUp bool)
+}
+
+// Option configures a producer
+type Option func(p *Producer)
+
+// WithFreq sets the frequency the producer ticks at
+func WithFreq(freq sim.Freq) Option {
+	return func(p *Producer) { p.Freq = freq }
+}
+
+// WithSeed seeds the random source of the producer
+func WithSeed(seed int64) Option {
+	return func(p *Producer) {
+		p.source = component.NewCountingSource(seed)
+		p.rand = rand.New(p.source)
+		p.seed = seed
+	}
+}
+
+// WithConsumers sets the consumers the producer sends to
+func WithConsumers(consumers []string) Option {
+	return func(p *Producer) { p.consumers = consumers }
+}
+
+// WithDiscovery makes the producer learn the consumers from the control port
+// of a distributor at the given time, after their registration
+func WithDiscovery(registry sim.Port, at sim.VTimeInSec) Option {
+	return func(p *Producer) {
+		p.registry = registry
+		p.discoverTime = at
+	}
+}
+
+// WithTraffic sets the traffic model that decides when a message is
+// generated
+func WithTraffic(traffic Traffic) Option {
+	return func(p *Producer) { p.traffic = traffic }
+}
+
+// WithDestinationPolicy sets the policy that picks the consumer of a
+// generated message
+func WithDestinationPolicy(policy DestinationPolicy) Option {
+	return func(p *Producer) { p.destPolicy = policy }
+}
+
+// WithMulticast makes a share of the generated messages multicast to one of
+// the groups
+func WithMulticast(probability float64, groups []string) Option {
+	return func(p *Producer) {
+		p.multicast = probability
+		p.groups = groups
+	}
+}
+
+// WithTopics makes the producer publish every message to one of the topics
+// instead of addressing a consumer
+func WithTopics(topics []string) Option {
+	return func(p *Producer) { p.topics = topics }
+}
+
+// WithFlows spreads the messages over the given number of flows
+func WithFlows(flows int) Option {
+	return func(p *Producer) { p.numFlows = flows }
+}
+
+// WithSize sets the payload size of the messages in bytes. A positive spread
+// draws lognormal sizes and service times around it, whose log-values are
+// correlated by the given coefficient.
+func WithSize(size int, spread, correlation float64) Option {
+	return func(p *Producer) {
+		p.msgSize = size
+		p.sizeSpread = spread
+		p.sizeCorrelation = correlation
+	}
+}
+
+// WithMaxInFlight limits the unacknowledged messages, 0 for no limit
+func WithMaxInFlight(n int) Option {
+	return func(p *Producer) { p.maxInFlight = n }
+}
+
+// WithWindow limits the unacknowledged messages by a sliding window
+func WithWindow(w *Window) Option {
+	return func(p *Producer) { p.window = w }
+}
+
+// WithTTL sets the lifetime of the generated messages, 0 never expires
+func WithTTL(ttl sim.VTimeInSec) Option {
+	return func(p *Producer) { p.ttl = ttl }
+}
+
+// WithPriorities spreads the messages over the given number of priority
+// levels
+func WithPriorities(levels int) Option {
+	return func(p *Producer) { p.priorities = levels }
+}
+
+// WithIDs makes the producer draw every stride-th message ID, from the
+// given offset, so that the producers of a run share the IDs
+func WithIDs(stride, offset uint64) Option {
+	return func(p *Producer) {
+		p.idStride = stride
+		p.idOffset = offset
+	}
+}
+
+// WithClockSkew offsets the clock the producer stamps CreateTime with
+func WithClockSkew(skew sim.VTimeInSec) Option {
+	return func(p *Producer) { p.clockSkew = skew }
+}
+
+// WithStats sets the counters the producer records its messages in
+func WithStats(stats Stats) Option {
+	return func(p *Producer) { p.stats = stats }
+}
+
+// WithBackpressure measures how fast the producer reacts to congestion
+func WithBackpressure(backpressure Backpressure) Option {
+	return func(p *Producer) { p.backpressure = backpressure }
+}
+
+// WithMiddleware passes the generated messages through the chain
+func WithMiddleware(chain *middleware.Chain) Option {
+	return func(p *Producer) { p.middleware = chain }
+}
+
+// WithRetransmitter sends the messages again whose ACK is overdue
+func WithRetransmitter(r Retransmitter) Option {
+	return func(p *Producer) { p.retransmitter = r }
+}
+
+// WithAuditor reports every message sent to the auditor
+func WithAuditor(auditor Auditor) Option {
+	return func(p *Producer) { p.auditor = auditor }
+}
+
+// WithLogger sets the logger of the events of the producer
+func WithLogger(log component.Logger) Option {
+	return func(p *Producer) { p.log = log }
+}
+
+// WithDestination sends the messages to the given input port of the
+// distributor
+func WithDestination(port sim.Port) Option {
+	return func(p *Producer) { p.dstPort = port }
+}
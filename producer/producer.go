@@ -0,0 +1,258 @@
+// Package producer holds the component of the demo that generates the
+// messages and sends them to the distributor. What it generates, and what
+// it reports to, is given as options.
+package producer
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/middleware"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// Producer generates messages randomly and sends to distributor
+type Producer struct {
+	*sim.TickingComponent
+	component.BaseLifecycle
+	outputPort      sim.Port
+	dstPort         sim.Port       // Distributor's input port (immediate hop)
+	ctrlPort        sim.Port       // Control-plane port for service discovery
+	registry        sim.Port       // Distributor's control port, nil if consumers are given
+	discoverTime    sim.VTimeInSec // Time to query the registry, after the registration period
+	querySent       bool
+	discovered      bool
+	nextID          uint64
+	idStride        uint64                    // Producers sharing a run draw every idStride-th message ID
+	idOffset        uint64                    // Position of this producer's IDs within the stride
+	seqNums         map[string]uint64         // Last sequence number per destination
+	outstanding     map[uint64]sim.VTimeInSec // Send time of unacknowledged messages
+	maxInFlight     int                       // Limit of unacknowledged messages, 0 for no limit
+	window          *Window                   // Sliding flow-control window, nil for no window
+	consumers       []string
+	rand            *rand.Rand
+	source          *component.CountingSource // Source of rand
+	seed            int64                     // Seed of rand
+	stopTime        sim.VTimeInSec
+	traffic         Traffic           // Decides when a message is generated
+	destPolicy      DestinationPolicy // Picks the consumer of a generated message
+	multicast       float64           // Probability that a generated message is multicast
+	groups          []string          // Groups multicast messages are addressed to
+	topics          []string          // Topics messages are published to, nil addresses consumers
+	numFlows        int               // Number of distinct flows messages are spread over
+	msgSize         int               // Payload size of generated messages in bytes
+	sizeSpread      float64           // Spread of lognormal sizes and service times, 0 keeps them fixed
+	sizeCorrelation float64           // Correlation of the sizes and the service times
+	ttl             sim.VTimeInSec    // Lifetime of generated messages, 0 never expires
+	clockSkew       sim.VTimeInSec    // Offset of the producer's clock, which stamps CreateTime
+	priorities      int               // Number of priority levels messages are spread over
+	backpressure    Backpressure      // Measures how fast the producer reacts to congestion, nil measures nothing
+	middleware      *middleware.Chain // Intercepts generated messages, nil sends them as generated
+	held            []heldSend        // Messages the middlewares hold, sent in order
+	retransmitter   Retransmitter     // Sends messages again whose ACK is overdue, nil never does
+	auditor         Auditor           // Checks that every message sent is consumed once, nil checks none
+	stats           Stats             // Counts the messages, nil counts none
+	log             component.Logger
+}
+
+// New creates a producer that generates messages until stopTime. Without
+// options, it ticks at 1 Hz with a 30% chance of a message per tick, sends
+// to no consumer, and is seeded by the wall clock.
+func New(name string, engine sim.Engine, stopTime sim.VTimeInSec, opts ...Option) *Producer {
+	p := &Producer{
+		outstanding: make(map[uint64]sim.VTimeInSec),
+		seqNums:     make(map[string]uint64),
+		idStride:    1,
+		stopTime:    stopTime,
+		traffic:     &RandomTraffic{Probability: 0.3},
+		destPolicy:  RandomDestination{},
+		numFlows:    1,
+		priorities:  1,
+		log:         component.Discard{},
+	}
+	WithSeed(time.Now().UnixNano())(p)
+	p.TickingComponent = sim.NewTickingComponent(name, engine, 1*sim.Hz, p)
+	p.outputPort = sim.NewLimitNumMsgPort(p, 1, name+".Out")
+	p.ctrlPort = sim.NewLimitNumMsgPort(p, 4, name+".Ctrl")
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Tick generates messages according to the traffic model
+func (p *Producer) Tick(now sim.VTimeInSec) bool {
+	outcome := component.TickIdle
+	if p.stats != nil {
+		defer func() { p.stats.RecordTick(p.Name(), outcome) }()
+	}
+
+	// ACKs are processed even after generation stopped
+	if p.handleAcks(now) {
+		outcome = component.TickBusy
+	}
+
+	// Messages whose ACK is overdue go out again before new ones, also
+	// after generation stopped
+	if !p.retransmit(now) {
+		return false
+	}
+
+	// Messages the middlewares hold go out before new ones, also after
+	// generation stopped
+	if n := len(p.held); n > 0 {
+		done := p.sendHeld(now)
+		if len(p.held) < n {
+			outcome = component.TickBusy
+		}
+		if !done {
+			return false
+		}
+	}
+
+	// Stop generating after stopTime
+	if now >= p.stopTime {
+		return false
+	}
+
+	// Learn the consumers from the distributor before generating traffic
+	if p.registry != nil && !p.discovered {
+		return p.discover(now)
+	}
+
+	// Stall while too many messages are unacknowledged or the window is
+	// full, the next ACK or expiry wakes the producer up
+	p.retireExpired(now)
+	if !p.canSend() {
+		if outcome == component.TickIdle {
+			outcome = component.TickBlocked
+		}
+		if p.stats != nil {
+			p.stats.RecordInFlightStall()
+		}
+		if p.backpressure != nil {
+			p.backpressure.ProducerReacted(now)
+		}
+		p.wakeAtExpiry()
+		return false
+	}
+
+	// Let the traffic model decide whether to generate a message this tick
+	if p.traffic.ShouldGenerate(now, p.rand) {
+		// Let the destination policy pick the consumer, unless the message
+		// is multicast to a group or published to a topic
+		multicast := p.multicast > 0 && p.rand.Float64() < p.multicast
+		var dest string
+		if p.topics != nil {
+			multicast = true
+			dest = p.topics[p.rand.Intn(len(p.topics))]
+		} else if multicast {
+			dest = p.multicastTarget()
+		} else {
+			dest = p.destPolicy.Pick(p.consumers, p.rand)
+		}
+
+		m := p.newMessage(now, dest)
+		if multicast {
+			m.Group = dest
+		}
+
+		if p.middleware.Active() {
+			outcome = component.TickBusy
+			p.intercept(now, m)
+			return true
+		}
+
+		err := p.outputPort.Send(m)
+		if err != nil {
+			p.discard(m)
+			if outcome == component.TickIdle {
+				outcome = component.TickBlocked
+			}
+			return false
+		}
+		outcome = component.TickBusy
+		if p.stats != nil {
+			p.stats.RecordProduced(now, m)
+		}
+		p.sent(now, m)
+	}
+	return true
+}
+
+// sent records a generated message that left for the distributor
+func (p *Producer) sent(now sim.VTimeInSec, m *msg.DemoMessage) {
+	p.outstanding[m.ID] = now
+	p.waitForAck(now, m)
+	if p.auditor != nil {
+		p.auditor.Produced(m)
+	}
+	component.StartTask(p, "generate", m.ID)
+	if p.topics != nil {
+		p.log.Printf("[%.2f] Producer: Published message to %s\n", now, m.Destination)
+	} else if m.Group != "" {
+		p.log.Printf("[%.2f] Producer: Generated multicast message for %s\n", now, m.Destination)
+	} else {
+		p.log.Printf("[%.2f] Producer: Generated message for %s\n", now, m.Destination)
+	}
+}
+
+// newMessage creates a message for dest that is sent to the distributor
+func (p *Producer) newMessage(now sim.VTimeInSec, dest string) *msg.DemoMessage {
+	p.nextID++
+	p.seqNums[dest]++
+	m := msg.New()
+	*m = msg.DemoMessage{
+		ID:          (p.nextID-1)*p.idStride + p.idOffset + 1,
+		Source:      p.Name(),
+		Content:     fmt.Sprintf("Message at time %.2f", now),
+		Destination: dest,
+		CreateTime:  now + p.clockSkew,
+		FlowID:      p.rand.Intn(p.numFlows),
+		TTL:         p.ttl,
+		SeqNum:      p.seqNums[dest],
+		Size:        p.msgSize,
+	}
+	if p.priorities > 1 {
+		m.Priority = p.rand.Intn(p.priorities)
+	}
+	if p.sizeSpread > 0 {
+		m.Size, m.ServiceScale = p.sampleSizeService()
+	}
+	m.Meta().Src = p.outputPort
+	m.Meta().Dst = p.dstPort // Send to distributor (immediate hop)
+	m.Meta().SendTime = now
+	m.Meta().TrafficBytes = m.Size
+	return m
+}
+
+// discard gives back the ID and the sequence number of a message that could
+// not be sent, so that the next message reuses them
+func (p *Producer) discard(m *msg.DemoMessage) {
+	p.nextID--
+	p.seqNums[m.Destination]--
+	if t, ok := p.traffic.(unsender); ok {
+		t.Unsend(m.Destination)
+	}
+	m.Release()
+}
+
+// multicastTarget picks the group of a multicast message
+func (p *Producer) multicastTarget() string {
+	return p.groups[p.rand.Intn(len(p.groups))]
+}
+
+// Init checks that the producer is connected to a distributor
+func (p *Producer) Init() error {
+	if p.dstPort == nil {
+		return fmt.Errorf("%s: not connected to a distributor", p.Name())
+	}
+	return nil
+}
+
+// SelfStarting makes the producer tick from the beginning, it discovers the
+// consumers and starts generating
+func (p *Producer) SelfStarting() {}
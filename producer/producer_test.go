@@ -0,0 +1,189 @@
+package producer
+
+import (
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// countingStats counts what the producer records
+type countingStats struct {
+	produced, acked, stalls, lost int
+	rtt                           sim.VTimeInSec
+}
+
+func (s *countingStats) RecordProduced(now sim.VTimeInSec, m *msg.DemoMessage) { s.produced++ }
+func (s *countingStats) RecordAcked(sendTime, rtt sim.VTimeInSec)              { s.acked++; s.rtt += rtt }
+func (s *countingStats) RecordInFlightStall()                                  { s.stalls++ }
+func (s *countingStats) RecordLost()                                           { s.lost++ }
+func (s *countingStats) RecordTick(name string, outcome component.TickOutcome) {}
+
+// reactions records when the producer reacted to congestion
+type reactions []sim.VTimeInSec
+
+func (r *reactions) ProducerReacted(now sim.VTimeInSec) { *r = append(*r, now) }
+
+// sink is a component that accepts the messages of the producer
+type sink struct {
+	*sim.ComponentBase
+}
+
+func (s *sink) Handle(e sim.Event) error                      { return nil }
+func (s *sink) NotifyRecv(now sim.VTimeInSec, p sim.Port)     {}
+func (s *sink) NotifyPortFree(now sim.VTimeInSec, p sim.Port) {}
+
+// connect connects the producer to the input port of a sink
+func connect(engine sim.Engine, p *Producer) sim.Port {
+	s := &sink{ComponentBase: sim.NewComponentBase("Sink")}
+	in := sim.NewLimitNumMsgPort(s, 16, "Sink.In")
+	p.dstPort = in
+	conn := sim.NewDirectConnection("ProducerToSink", engine, 1*sim.Hz)
+	conn.PlugIn(p.outputPort, 1)
+	conn.PlugIn(in, 1)
+	return in
+}
+
+// ack delivers the ACK of a message to the control port of the producer
+func ack(p *Producer, id uint64) {
+	a := &msg.AckMsg{MsgID: id}
+	a.Meta().Dst = p.ctrlPort
+	p.ctrlPort.Recv(a)
+}
+
+// TestNewAppliesOptions verifies that the options configure the producer
+// and that the seed makes the producer reproducible
+func TestNewAppliesOptions(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	traffic := &RandomTraffic{Probability: 1}
+	p := New("Producer", engine, 100,
+		WithFreq(2*sim.Hz),
+		WithConsumers([]string{"Consumer1"}),
+		WithTraffic(traffic),
+		WithIDs(3, 1),
+		WithSeed(7))
+	
+	if p.Freq != 2*sim.Hz || p.Traffic() != traffic || p.Seed() != 7 {
+		t.Errorf("Expected 2 Hz, the given traffic, and seed 7, got %v, %v, and %d", p.Freq, p.Traffic(), p.Seed())
+	}
+	m := p.newMessage(0, "Consumer1")
+	if m.ID != 2 || m.SeqNum != 1 || p.SeqNum("Consumer1") != 1 {
+		t.Errorf("Expected message 2 with sequence number 1, got message %d with %d", m.ID, m.SeqNum)
+	}
+}
+
+// TestProducerStallsAtInFlightLimit verifies that the producer stops
+// generating once the in-flight limit is reached
+func TestProducerStallsAtInFlightLimit(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	p := New("Producer", engine, 100, WithConsumers([]string{"Consumer1"}), WithMaxInFlight(1))
+	p.outstanding[1] = 0
+	
+	result := p.Tick(1)
+	
+	if result != false {
+		t.Errorf("Expected Producer.Tick() to return false at the in-flight limit, got %v", result)
+	}
+}
+
+// TestProducerStallAnswersCongestion verifies that a producer stalled at its
+// in-flight limit counts as a reaction to congestion
+func TestProducerStallAnswersCongestion(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	var reacted reactions
+	p := New("Producer", engine, 100, WithConsumers([]string{"Consumer1"}), WithMaxInFlight(1),
+		WithBackpressure(&reacted))
+	p.outstanding[1] = 0
+	
+	p.Tick(5)
+	
+	if len(reacted) != 1 || reacted[0] != 5 {
+		t.Errorf("Expected a reaction at 5, got %v", reacted)
+	}
+}
+
+// TestProducerRetiresAckedMessages verifies that an ACK removes the message
+// from the outstanding set and records its round-trip time
+func TestProducerRetiresAckedMessages(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := &countingStats{}
+	p := New("Producer", engine, 100, WithConsumers([]string{"Consumer1"}), WithStats(stats))
+	p.outstanding[7] = 2
+	
+	ack(p, 7)
+	p.handleAcks(5)
+	
+	if p.Unacked() != 0 {
+		t.Errorf("Expected no outstanding messages, got %v", p.outstanding)
+	}
+	if stats.acked != 1 || stats.rtt != 3 {
+		t.Errorf("Expected 1 ACK with an RTT of 3, got %d ACKs with RTT %v", stats.acked, stats.rtt)
+	}
+}
+
+// TestProducerRetiresExpiredOutstanding verifies that the producer stops
+// waiting for the ACK of a message one TTL after the message expired
+func TestProducerRetiresExpiredOutstanding(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := &countingStats{}
+	p := New("Producer", engine, 100, WithConsumers([]string{"Consumer1"}), WithStats(stats), WithTTL(2))
+	p.outstanding[1] = 0
+	p.outstanding[2] = 3
+	
+	p.retireExpired(5)
+	
+	if _, ok := p.outstanding[1]; ok || len(p.outstanding) != 1 {
+		t.Errorf("Expected only message 1 to be retired, got %v", p.outstanding)
+	}
+	if stats.lost != 1 {
+		t.Errorf("Expected 1 lost message, got %d", stats.lost)
+	}
+}
+
+// TestProducerStallsAndResumesWithWindow verifies that the producer stalls
+// when the window is full and resumes generating once an ACK arrives
+func TestProducerStallsAndResumesWithWindow(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	stats := &countingStats{}
+	p := New("Producer", engine, 100,
+		WithConsumers([]string{"Consumer1"}),
+		WithTraffic(&RandomTraffic{Probability: 1}),
+		WithStats(stats),
+		WithWindow(NewWindow(4, 5)))
+	p.outstanding[1] = 0
+	connect(engine, p)
+	
+	if p.Tick(1) {
+		t.Fatal("Expected the producer to stall while the window is full")
+	}
+	if stats.stalls != 1 {
+		t.Errorf("Expected 1 in-flight stall, got %d", stats.stalls)
+	}
+	
+	ack(p, 1)
+	
+	if !p.Tick(3) {
+		t.Fatal("Expected the producer to resume after the ACK")
+	}
+	if stats.produced != 1 || p.Window().Size() != 2 {
+		t.Errorf("Expected 1 message produced with a window of 2, got %d messages and a window of %d",
+			stats.produced, p.Window().Size())
+	}
+}
+
+// TestInitRequiresDistributor verifies that an unconnected producer fails
+// before the run starts
+func TestInitRequiresDistributor(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	p := New("Producer", engine, 10, WithConsumers([]string{"Consumer1"}))
+	
+	if err := p.Init(); err == nil {
+		t.Errorf("Expected Init to fail without a distributor")
+	}
+	
+	connect(engine, p)
+	if err := p.Init(); err != nil {
+		t.Errorf("Expected Init to succeed, got %v", err)
+	}
+}
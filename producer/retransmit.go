@@ -0,0 +1,63 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+	"github.com/syifan/akita_demo/msg"
+)
+
+// waitForAck starts waiting for the ACK of a message sent for the first time
+// and wakes the producer up when it is overdue
+func (p *Producer) waitForAck(now sim.VTimeInSec, m *msg.DemoMessage) {
+	if p.retransmitter == nil {
+		return
+	}
+	deadline := p.retransmitter.Sent(now, m)
+	component.ScheduleWakeup(p.TickingComponent, p.Freq.ThisTick(deadline))
+}
+
+// retransmit sends the messages whose ACK is overdue again, or gives up on
+// them, and wakes the producer up when the ACK of a message sent again is
+// overdue. It returns false if the output port is busy.
+func (p *Producer) retransmit(now sim.VTimeInSec) bool {
+	if p.retransmitter == nil {
+		return true
+	}
+	for _, first := range p.retransmitter.Overdue(now, p.Name()) {
+		id := first.ID
+		if _, ok := p.outstanding[id]; !ok {
+			// Acknowledged by another consumer, or retired when it expired
+			p.retransmitter.Forget(id, false)
+			continue
+		}
+		if p.retransmitter.Exhausted(first) {
+			p.retransmitter.Forget(id, true)
+			delete(p.outstanding, id)
+			if p.stats != nil {
+				p.stats.RecordLost()
+			}
+			component.EndTask(p, "generate", id)
+			p.log.Printf("[%.2f] Producer: Gave up on message %d for %s after %d retransmissions\n",
+				now, id, first.Destination, first.Retransmits)
+			continue
+		}
+
+		m := first.Clone()
+		m.Retransmits = first.Retransmits + 1
+		m.Held = 0
+		m.Meta().Src = p.outputPort
+		m.Meta().Dst = p.dstPort
+		m.Meta().SendTime = now
+		m.Meta().TrafficBytes = m.Size
+		if err := p.outputPort.Send(m); err != nil {
+			// Port busy, will be woken up when it becomes free
+			m.Release()
+			return false
+		}
+		deadline := p.retransmitter.Resent(now, id)
+		component.ScheduleWakeup(p.TickingComponent, p.Freq.ThisTick(deadline))
+		p.log.Printf("[%.2f] Producer: Retransmitted message %d for %s (retry %d)\n",
+			now, id, m.Destination, m.Retransmits)
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package producer
+
+import (
+	"sort"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// TraceRecord is a single message injection recorded in a trace file
+type TraceRecord struct {
+	Time        sim.VTimeInSec
+	Destination string
+	Size        int
+}
+
+// RecordSource yields the records of a trace in order of time
+type RecordSource interface {
+	// Next returns up to n more records sorted by time, fewer once the
+	// trace ends
+	Next(n int) []TraceRecord
+}
+
+// TraceProducer replays the messages of a trace, injecting each message at
+// its recorded virtual time
+type TraceProducer struct {
+	*Producer
+	records []TraceRecord // Scheduled records, sorted by time
+	next    int           // Index of the next record to inject
+	pending []TraceRecord // Due records waiting for the output port
+	stream  RecordSource  // Source of further records, nil once exhausted
+	chunk   int           // Records scheduled from the stream at a time
+}
+
+// NewTraceProducer creates a producer that replays the given records
+func NewTraceProducer(name string, engine sim.Engine, records []TraceRecord, stopTime sim.VTimeInSec, opts ...Option) *TraceProducer {
+	t := &TraceProducer{}
+	t.Producer = New(name, engine, stopTime, opts...)
+	// Ticks must be handled by the TraceProducer rather than the Producer
+	freq := t.Freq
+	t.TickingComponent = sim.NewTickingComponent(name, engine, freq, t)
+	t.outputPort = sim.NewLimitNumMsgPort(t, 1, name+".Out")
+	t.ScheduleBatch(records)
+	return t
+}
+
+// NewStreamingTraceProducer creates a producer that replays the records of a
+// trace stream. Only chunk records are held at a time; the next chunk is read
+// once the previous one has been injected, and reading stops at the stop
+// time.
+func NewStreamingTraceProducer(name string, engine sim.Engine, stream RecordSource, chunk int, stopTime sim.VTimeInSec, opts ...Option) *TraceProducer {
+	t := NewTraceProducer(name, engine, nil, stopTime, opts...)
+	t.stream = stream
+	t.chunk = chunk
+	t.refill()
+	return t
+}
+
+// refill schedules the next chunk of the stream once all the scheduled
+// records have been injected
+func (t *TraceProducer) refill() {
+	if t.stream == nil || t.next < len(t.records) {
+		return
+	}
+	t.records = t.records[:0]
+	t.next = 0
+	records := t.stream.Next(t.chunk)
+	if t.ScheduleBatch(records) < len(records) || len(records) < t.chunk {
+		// The stream ended or reached the stop time
+		t.stream = nil
+	}
+}
+
+// injectionEvent injects the scheduled records due at its time
+type injectionEvent struct {
+	*sim.EventBase
+}
+
+// ScheduleBatch schedules the injection of a batch of future records, sorted
+// by time and not earlier than the records scheduled before. The records are
+// injected by engine events, one per distinct time, so that the producer
+// does not tick to wait for them. Only the event of the next time is in the
+// engine's queue at any time, so a batch of millions of records does not
+// slow down the queue. Records at or after the stop time are left out. It
+// returns the number of records scheduled.
+func (t *TraceProducer) ScheduleBatch(records []TraceRecord) int {
+	n := sort.Search(len(records), func(i int) bool {
+		return records[i].Time >= t.stopTime
+	})
+	idle := t.next == len(t.records)
+	t.records = append(t.records, records[:n]...)
+	if idle && n > 0 {
+		t.scheduleNext()
+	}
+	return n
+}
+
+func (t *TraceProducer) scheduleNext() {
+	evt := &injectionEvent{sim.NewEventBase(t.records[t.next].Time, t)}
+	t.Engine.Schedule(evt)
+}
+
+// Handle injects the records due at an injection event and passes ticks on
+func (t *TraceProducer) Handle(e sim.Event) error {
+	if _, ok := e.(*injectionEvent); !ok {
+		return t.TickingComponent.Handle(e)
+	}
+
+	now := e.Time()
+	for t.next < len(t.records) && t.records[t.next].Time <= now {
+		t.pending = append(t.pending, t.records[t.next])
+		t.next++
+	}
+	if t.next < len(t.records) {
+		t.scheduleNext()
+	} else {
+		t.refill()
+	}
+	t.inject(now)
+	return nil
+}
+
+// Tick handles the ACKs and injects the records that waited for the output
+// port
+func (t *TraceProducer) Tick(now sim.VTimeInSec) bool {
+	t.handleAcks(now)
+	t.inject(now)
+	return false
+}
+
+// inject sends the pending records until the output port is busy, in which
+// case the producer is woken up when it becomes free
+func (t *TraceProducer) inject(now sim.VTimeInSec) {
+	for len(t.pending) > 0 {
+		record := t.pending[0]
+		m := t.newMessage(now, record.Destination)
+		m.Size = record.Size
+		m.Meta().TrafficBytes = record.Size
+
+		err := t.outputPort.Send(m)
+		if err != nil {
+			t.discard(m)
+			return
+		}
+		t.pending = t.pending[1:]
+		t.outstanding[m.ID] = now
+		if t.auditor != nil {
+			t.auditor.Produced(m)
+		}
+		if t.stats != nil {
+			t.stats.RecordProduced(now, m)
+		}
+		t.log.Printf("[%.2f] Producer: Replayed message for %s (%d bytes)\n", now, record.Destination, record.Size)
+	}
+}
@@ -0,0 +1,135 @@
+package producer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// sendTimeRecorder is a hook that records the send time of every message
+// sent out of a port
+type sendTimeRecorder struct {
+	times []sim.VTimeInSec
+}
+
+func (r *sendTimeRecorder) Func(ctx sim.HookCtx) {
+	if ctx.Pos == sim.HookPosPortMsgSend {
+		r.times = append(r.times, ctx.Item.(sim.Msg).Meta().SendTime)
+	}
+}
+
+// tickCounter is a hook that counts the ticks of every component
+type tickCounter struct {
+	ticks map[string]int
+}
+
+func (c *tickCounter) Func(ctx sim.HookCtx) {
+	if evt, ok := ctx.Item.(sim.TickEvent); ok && ctx.Pos == sim.HookPosBeforeEvent {
+		c.ticks[evt.Handler().(sim.Named).Name()]++
+	}
+}
+
+// recordSlice is a trace held in memory
+type recordSlice []TraceRecord
+
+func (s *recordSlice) Next(n int) []TraceRecord {
+	if n > len(*s) {
+		n = len(*s)
+	}
+	records := (*s)[:n]
+	*s = (*s)[n:]
+	return records
+}
+
+// TestTraceProducerInjectsAtRecordedTimes verifies that messages are
+// injected at the virtual times recorded in the trace
+func TestTraceProducerInjectsAtRecordedTimes(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	records := []TraceRecord{
+		{Time: 2, Destination: "Consumer1", Size: 64},
+		{Time: 4.5, Destination: "Consumer1", Size: 128},
+	}
+	p := NewTraceProducer("Producer", engine, records, 100)
+	connect(engine, p.Producer)
+	
+	recorder := &sendTimeRecorder{}
+	p.outputPort.AcceptHook(recorder)
+	
+	p.TickNow(0)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if len(recorder.times) != 2 || recorder.times[0] != 2 || recorder.times[1] != 4.5 {
+		t.Errorf("Expected messages injected at [2 4.5], got %v", recorder.times)
+	}
+}
+
+// TestScheduleBatchInjectsWithoutTicks verifies that batches scheduled one
+// after the other are injected at their times, that records past the stop
+// time are left out, and that the producer does not tick to wait for them
+func TestScheduleBatchInjectsWithoutTicks(t *testing.T) {
+	engine := sim.NewSerialEngine()
+	p := NewTraceProducer("Producer", engine, []TraceRecord{
+		{Time: 3, Destination: "Consumer1"},
+		{Time: 3, Destination: "Consumer1"},
+	}, 50)
+	scheduled := p.ScheduleBatch([]TraceRecord{
+		{Time: 10, Destination: "Consumer1"},
+		{Time: 60, Destination: "Consumer1"},
+	})
+	if scheduled != 1 {
+		t.Errorf("Expected the record past the stop time to be left out, got %d scheduled", scheduled)
+	}
+	connect(engine, p.Producer)
+	
+	recorder := &sendTimeRecorder{}
+	p.outputPort.AcceptHook(recorder)
+	counter := &tickCounter{ticks: make(map[string]int)}
+	engine.AcceptHook(counter)
+	
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	// The second message at time 3 waits for the output port
+	if len(recorder.times) != 3 || recorder.times[0] != 3 || recorder.times[1] != 4 || recorder.times[2] != 10 {
+		t.Errorf("Expected messages injected at [3 4 10], got %v", recorder.times)
+	}
+	if counter.ticks["Producer"] != 1 {
+		t.Errorf("Expected a single tick to retry the busy port, got %d", counter.ticks["Producer"])
+	}
+}
+
+// TestStreamingTraceProducerRefills verifies that a producer reading a few
+// records at a time injects every record of the trace at its time and stops
+// reading at the stop time
+func TestStreamingTraceProducerRefills(t *testing.T) {
+	stream := &recordSlice{
+		{Time: 1, Destination: "Consumer1", Size: 1},
+		{Time: 2, Destination: "Consumer1", Size: 1},
+		{Time: 2, Destination: "Consumer1", Size: 1},
+		{Time: 5, Destination: "Consumer1", Size: 1},
+		{Time: 7, Destination: "Consumer1", Size: 1},
+		{Time: 50, Destination: "Consumer1", Size: 1},
+		{Time: 60, Destination: "Consumer1", Size: 1},
+	}
+	
+	engine := sim.NewSerialEngine()
+	p := NewStreamingTraceProducer("Producer", engine, stream, 2, 40)
+	connect(engine, p.Producer)
+	
+	recorder := &sendTimeRecorder{}
+	p.outputPort.AcceptHook(recorder)
+	if err := engine.Run(); err != nil {
+		t.Fatal(err)
+	}
+	
+	if fmt.Sprint(recorder.times) != "[1 2 3 5 7]" {
+		t.Errorf("Expected messages injected at [1 2 3 5 7], got %v", recorder.times)
+	}
+	if p.stream != nil || len(p.records) > 2 {
+		t.Errorf("Expected the stream to end at the stop time with at most 2 records held, got %d", len(p.records))
+	}
+}
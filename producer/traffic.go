@@ -0,0 +1,58 @@
+package producer
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Traffic decides whether the Producer generates a message on a tick
+type Traffic interface {
+	ShouldGenerate(now sim.VTimeInSec, rng *rand.Rand) bool
+}
+
+// unsender is a traffic model that counts the messages it generated, so it
+// takes back a message the producer could not send
+type unsender interface {
+	Unsend(dest string)
+}
+
+// RandomTraffic generates a message with a fixed probability on every tick
+type RandomTraffic struct {
+	Probability float64
+}
+
+// ShouldGenerate returns true with the configured probability
+func (t *RandomTraffic) ShouldGenerate(now sim.VTimeInSec, rng *rand.Rand) bool {
+	return rng.Float64() < t.Probability
+}
+
+// String names the traffic model and its probability
+func (t *RandomTraffic) String() string {
+	return fmt.Sprintf("random, %.0f%% chance per tick", t.Probability*100)
+}
+
+// DestinationPolicy picks the consumer a generated message is sent to
+type DestinationPolicy interface {
+	Pick(consumers []string, rng *rand.Rand) string
+	// Observe learns the round-trip time of a message acknowledged by a
+	// consumer
+	Observe(consumer string, rtt sim.VTimeInSec)
+}
+
+// RandomDestination picks a consumer uniformly at random
+type RandomDestination struct{}
+
+// Pick returns a random consumer
+func (RandomDestination) Pick(consumers []string, rng *rand.Rand) string {
+	return consumers[rng.Intn(len(consumers))]
+}
+
+// Observe ignores the round-trip times
+func (RandomDestination) Observe(consumer string, rtt sim.VTimeInSec) {}
+
+// String names the policy
+func (RandomDestination) String() string {
+	return "random"
+}
@@ -0,0 +1,41 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+	"github.com/syifan/akita_demo/component"
+)
+
+// retireExpired gives up on the outstanding messages that expired one TTL
+// ago without being acknowledged. An expired message is dropped and its ACK
+// never arrives, so it must not hold the in-flight limit forever.
+func (p *Producer) retireExpired(now sim.VTimeInSec) {
+	if p.ttl == 0 {
+		return
+	}
+
+	for id, sendTime := range p.outstanding {
+		if now-sendTime > 2*p.ttl {
+			delete(p.outstanding, id)
+			if p.stats != nil {
+				p.stats.RecordLost()
+			}
+			component.EndTask(p, "generate", id)
+		}
+	}
+}
+
+// wakeAtExpiry makes a stalled producer tick again when its oldest
+// outstanding message can be retired, in case no ACK arrives before
+func (p *Producer) wakeAtExpiry() {
+	if p.ttl == 0 || len(p.outstanding) == 0 {
+		return
+	}
+
+	oldest := sim.VTimeInSec(-1)
+	for _, sendTime := range p.outstanding {
+		if oldest < 0 || sendTime < oldest {
+			oldest = sendTime
+		}
+	}
+	component.ScheduleWakeup(p.TickingComponent, p.Freq.NextTick(oldest+2*p.ttl))
+}
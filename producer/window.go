@@ -0,0 +1,75 @@
+package producer
+
+import (
+	"github.com/sarchlab/akita/v3/sim"
+)
+
+// Window is a sliding window that limits the unacknowledged messages of the
+// producer. It follows additive increase, multiplicative decrease: every ACK
+// with a round-trip time within the target grows the window by one message
+// per window of ACKs, and an ACK slower than the target halves it.
+type Window struct {
+	size      float64
+	maxSize   int
+	targetRTT sim.VTimeInSec
+
+	Grows   int
+	Shrinks int
+	Peak    int
+}
+
+// NewWindow creates a window that starts at one message and grows up to
+// maxSize messages while round-trip times stay within targetRTT
+func NewWindow(maxSize int, targetRTT sim.VTimeInSec) *Window {
+	return &Window{
+		size:      1,
+		maxSize:   maxSize,
+		targetRTT: targetRTT,
+		Peak:      1,
+	}
+}
+
+// Size returns the number of messages that may be unacknowledged
+func (w *Window) Size() int {
+	return int(w.size)
+}
+
+// Ack adjusts the window to the round-trip time of an acknowledged message.
+// It returns the change in the window size in whole messages.
+func (w *Window) Ack(rtt sim.VTimeInSec) int {
+	before := w.Size()
+
+	if rtt > w.targetRTT {
+		w.size /= 2
+		if w.size < 1 {
+			w.size = 1
+		}
+	} else {
+		w.size += 1 / w.size
+		if w.size > float64(w.maxSize) {
+			w.size = float64(w.maxSize)
+		}
+	}
+
+	delta := w.Size() - before
+	switch {
+	case delta > 0:
+		w.Grows++
+		if w.Size() > w.Peak {
+			w.Peak = w.Size()
+		}
+	case delta < 0:
+		w.Shrinks++
+	}
+	return delta
+}
+
+// MaxSize returns the number of messages the window grows up to
+func (w *Window) MaxSize() int {
+	return w.maxSize
+}
+
+// TargetRTT returns the round-trip time the window grows within
+func (w *Window) TargetRTT() sim.VTimeInSec {
+	return w.targetRTT
+}
@@ -0,0 +1,43 @@
+package producer
+
+import (
+	"testing"
+)
+
+// TestWindowGrowsAdditively verifies that the window grows by one message
+// per window of fast ACKs and never exceeds its maximum
+func TestWindowGrowsAdditively(t *testing.T) {
+	w := NewWindow(3, 5)
+	
+	sizes := []int{}
+	for i := 0; i < 6; i++ {
+		w.Ack(1)
+		sizes = append(sizes, w.Size())
+	}
+	
+	expected := []int{2, 2, 2, 3, 3, 3}
+	for i := range expected {
+		if sizes[i] != expected[i] {
+			t.Fatalf("Expected window sizes %v, got %v", expected, sizes)
+		}
+	}
+	if w.Grows != 2 || w.Peak != 3 {
+		t.Errorf("Expected 2 grows and a peak of 3, got %d grows and a peak of %d", w.Grows, w.Peak)
+	}
+}
+
+// TestWindowShrinksOnSlowAck verifies that an ACK slower than the target
+// halves the window, but never below one message
+func TestWindowShrinksOnSlowAck(t *testing.T) {
+	w := NewWindow(8, 5)
+	w.size = 4
+	
+	if delta := w.Ack(6); delta != -2 || w.Size() != 2 {
+		t.Errorf("Expected the window to shrink to 2, got %d (delta %d)", w.Size(), delta)
+	}
+	w.Ack(6)
+	w.Ack(6)
+	if w.Size() != 1 {
+		t.Errorf("Expected the window to stay at 1 message, got %d", w.Size())
+	}
+}